@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/drellem2/pogo/internal/agent"
+	"github.com/drellem2/pogo/internal/client"
+)
+
+// attachHeader is what `pogo agent attach` prints before the session starts:
+// the agent, the keys that matter in the role being attached in, and who is
+// already there. Someone attached read-write and holding the keyboard is the
+// line that stops a pairing partner typing into the agent mid-sentence.
+func attachHeader(info *agent.AgentInfo, opts client.AttachOptions) string {
+	var b strings.Builder
+	if opts.ReadOnly {
+		fmt.Fprintf(&b, "Watching agent %s (pid=%d) read-only. Detach with Ctrl-\\.\n", info.Name, info.PID)
+	} else {
+		fmt.Fprintf(&b, "Attaching to agent %s (pid=%d). Detach with Ctrl-\\; take the keyboard with Ctrl-].\n", info.Name, info.PID)
+	}
+	if len(info.Attached) == 0 {
+		return b.String()
+	}
+	var others []string
+	for _, c := range info.Attached {
+		who := c.Who
+		if who == "" {
+			who = "anonymous"
+		}
+		role := "read-only"
+		switch {
+		case c.Writer:
+			role = "has the keyboard"
+		case c.Role == agent.AttachReadWrite:
+			role = "read-write"
+		}
+		others = append(others, fmt.Sprintf("%s (%s, %s)", who, c.Via, role))
+	}
+	fmt.Fprintf(&b, "Also attached: %s.\n", strings.Join(others, ", "))
+	return b.String()
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/drellem2/pogo/internal/agent"
+	"github.com/drellem2/pogo/internal/client"
+)
+
+func TestAttachHeaderSaysWhoHoldsTheKeyboard(t *testing.T) {
+	info := &agent.AgentInfo{Name: "crew-1", PID: 42, Attached: []agent.AttachedClient{
+		{ID: 1, Who: "alice@box", Role: agent.AttachReadWrite, Via: "socket", Writer: true},
+		{ID: 2, Who: "127.0.0.1:5000", Role: agent.AttachReadOnly, Via: "websocket"},
+	}}
+	got := attachHeader(info, client.AttachOptions{})
+	for _, want := range []string{"Ctrl-]", "alice@box (socket, has the keyboard)", "127.0.0.1:5000 (websocket, read-only)"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("header missing %q:\n%s", want, got)
+		}
+	}
+	if got := attachHeader(&agent.AgentInfo{Name: "crew-1"}, client.AttachOptions{ReadOnly: true}); strings.Contains(got, "Also attached") || !strings.Contains(got, "read-only") {
+		t.Errorf("read-only header with nobody attached:\n%s", got)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+// Helpers for `pogo events list`: its time bounds and its --count output. The
+// query itself — sidecar indexes and the --where predicate — lives in
+// internal/events (Select, Count).
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/drellem2/pogo/internal/cli"
+	"github.com/drellem2/pogo/internal/events"
+)
+
+// eventsTimeBound resolves a --since or --until value: empty is no bound, and
+// otherwise it takes the forms `pogo refinery history --since` does — a
+// duration back from now ("90m", "7d") or a date or timestamp. --since is
+// held to the past like parseSince holds it; an --until in the future is just
+// no bound yet.
+func eventsTimeBound(flag, s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if flag == "--since" {
+		return parseSince(s)
+	}
+	if d, ok := parseSinceDuration(s); ok {
+		if d < 0 {
+			return time.Time{}, fmt.Errorf("%s: duration must not be negative, got %q", flag, s)
+		}
+		return time.Now().Add(-d), nil
+	}
+	for _, layout := range sinceDateLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%s: %q is neither a duration (90m, 24h, 7d) nor a date (2026-07-01, 2026-07-01T12:00:00Z)", flag, s)
+}
+
+// eventCounts is `pogo events list --count --json`.
+type eventCounts struct {
+	Total   int                 `json:"total"`
+	GroupBy string              `json:"group_by,omitempty"`
+	Groups  []events.GroupCount `json:"groups,omitempty"`
+}
+
+// printEventCounts prints the total, and one line per group when grouped:
+// count first, so the column lines up, then the value ("(none)" for events
+// without the field).
+func printEventCounts(jsonOutput bool, groupBy string, groups []events.GroupCount, total int) {
+	if jsonOutput {
+		cli.PrintJSON(eventCounts{Total: total, GroupBy: groupBy, Groups: groups})
+		return
+	}
+	if groupBy == "" {
+		fmt.Println(total)
+		return
+	}
+	for _, g := range groups {
+		value := g.Value
+		if value == "" {
+			value = "(none)"
+		}
+		fmt.Printf("%8d  %s\n", g.Count, value)
+	}
+	fmt.Printf("%8d  total\n", total)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventsTimeBound(t *testing.T) {
+	if b, err := eventsTimeBound("--until", ""); err != nil || !b.IsZero() {
+		t.Errorf("empty = %v, %v; want no bound", b, err)
+	}
+	b, err := eventsTimeBound("--until", "7d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ago := time.Since(b); ago < 7*24*time.Hour-time.Minute || ago > 7*24*time.Hour+time.Minute {
+		t.Errorf("7d = %s ago, want about a week", ago)
+	}
+	if b, err := eventsTimeBound("--until", "2026-07-01T12:00:00Z"); err != nil || !b.Equal(time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("timestamp = %v, %v", b, err)
+	}
+	// --until may be in the future; --since may not.
+	future := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+	if _, err := eventsTimeBound("--until", future); err != nil {
+		t.Errorf("future --until: %v", err)
+	}
+	if _, err := eventsTimeBound("--since", future); err == nil {
+		t.Error("future --since was accepted")
+	}
+	if _, err := eventsTimeBound("--until", "yesterday"); err == nil || !strings.HasPrefix(err.Error(), "--until:") {
+		t.Errorf("garbage = %v, want an error naming --until", err)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/drellem2/pogo/internal/config"
+	"github.com/drellem2/pogo/internal/platform/unixsock"
+	"github.com/drellem2/pogo/internal/refinery"
+)
+
+// newGateRunnerCmd builds `pogo gate-runner`, the process the refinery hands
+// quality gates to when `[refinery] gate_runner` names its socket.
+//
+// It is deliberately a plain foreground process with no daemon of its own:
+// what makes it worth having is that the operator chooses how it is started —
+// as another user, inside a container, under a sandbox profile — and anything
+// it did to start itself would be a choice made for them.
+func newGateRunnerCmd() *cobra.Command {
+	var socket, mode, tmpDir string
+	var copyTree bool
+	cmd := &cobra.Command{
+		Use:   "gate-runner",
+		Short: "Run refinery quality gates handed over a unix socket",
+		Long: `Run the refinery's quality gates in this process instead of in pogod.
+
+By default every gate — the branch's own build and test scripts — runs as a
+child of pogod, as the user pogod runs as, in the refinery's clone. Start this
+command however the gates should be isolated (as a different user, in a
+container that mounts the refinery's worktree directory) and point pogod at it:
+
+  # ~/.config/pogo/config.toml
+  [refinery]
+  gate_runner = "~/.pogo/gate-runner.sock"
+
+pogod still rebases, pushes and judges every result; only the gate command is
+run here. Its output streams back live, so 'pogo refinery queue' shows the same
+heartbeat and excerpt it always has. A gate is killed when pogod hangs up — its
+timeout, or 'pogo refinery cancel'.
+
+--copy gates a temporary copy of the worktree rather than the worktree itself,
+so nothing a gate writes reaches the refinery's clone. The copy is removed when
+the gate exits.
+
+Anyone who can connect to the socket can run commands as this process's user.
+--socket-mode sets its permissions (default 0600, owner only); to serve a pogod
+running as another user, use 0660 and a group the two share.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			perm, err := strconv.ParseUint(mode, 8, 32)
+			if err != nil {
+				return fmt.Errorf("--socket-mode %q: not an octal file mode", mode)
+			}
+			// A socket file left by a runner that did not exit cleanly is
+			// replaced; nothing else is expected at this path.
+			if fi, err := os.Lstat(socket); err == nil && fi.Mode()&os.ModeSocket == 0 {
+				return fmt.Errorf("%s exists and is not a socket", socket)
+			}
+			// The socket has its mode before it is reachable at all: bound
+			// and chmod'ed, it would be open to anyone the umask let in
+			// until the chmod.
+			ln, err := unixsock.Listen(socket, os.FileMode(perm))
+			if err != nil {
+				return err
+			}
+			defer ln.Close()
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			how := "in the worktree pogod names"
+			if copyTree {
+				how = "in a temporary copy of each worktree"
+			}
+			fmt.Printf("gate-runner: listening on %s; gates run as uid %d, %s\n", socket, os.Getuid(), how)
+			return refinery.ServeGateRunner(ctx, ln, refinery.GateRunnerOptions{Copy: copyTree, TempDir: tmpDir})
+		},
+	}
+	cmd.Flags().StringVar(&socket, "socket", filepath.Join(config.PogoHome(), "gate-runner.sock"), "unix socket to listen on")
+	cmd.Flags().StringVar(&mode, "socket-mode", "0600", "permissions for the socket file, in octal")
+	cmd.Flags().BoolVar(&copyTree, "copy", false, "gate a temporary copy of each worktree instead of the worktree itself")
+	cmd.Flags().StringVar(&tmpDir, "tmp", "", "directory for --copy copies (default: the system temp dir)")
+	return cmd
+}
@@ -56,9 +56,13 @@ for it before planning several dispatches into the same repository.
 
 'worker_budget' is the share of this host the NEXT worker spawned here will be
 told it may use. It is a policy division of the core count, not a measurement,
-and nothing enforces it — it exists because both dispatch gates count workers,
-so one worker whose toolchain self-parallelises can hold the box while a cap of
-3 reads one-of-three.`,
+and by default nothing enforces it — it exists because both dispatch gates
+count workers, so one worker whose toolchain self-parallelises can hold the box
+while a cap of 3 reads one-of-three.
+
+With [dispatch] cgroup_root set, pogod enforces it instead: each worker and each
+refinery gate runs in a cgroup limited to the budget, and 'cgroups' lists what
+each is using against its limits.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			resp, err := client.GetHostLoad(repo)
 			if err != nil {
@@ -76,6 +80,7 @@ so one worker whose toolchain self-parallelises can hold the box while a cap of
 				// The budget is a policy division of the core count, not a
 				// sample, so it answers when the host could not be measured.
 				printWorkerBudget(os.Stdout, resp.WorkerBudget)
+				printCgroups(os.Stdout, resp)
 				// The repo cap is a count, not a sample: it still answers when
 				// the host could not be measured, and suppressing it here would
 				// hide the refusal the caller is most likely about to hit.
@@ -106,6 +111,7 @@ so one worker whose toolchain self-parallelises can hold the box while a cap of
 					"for ANY repo — this gate does not read the --repo argument.\n")
 			}
 			printWorkerBudget(os.Stdout, resp.WorkerBudget)
+			printCgroups(os.Stdout, resp)
 			printRepoOccupancy(os.Stdout, resp.RepoOccupancy, resp.WouldRefuseDispatch)
 			return nil
 		},
@@ -150,12 +156,53 @@ func printWorkerBudget(w io.Writer, b agent.WorkerBudget) {
 		return
 	}
 	fmt.Fprintf(w, "\nWorker budget: %d of %d cores per worker (%s).\n", b.Cores, b.HostCores, b.Basis)
+	if b.Enforced {
+		fmt.Fprintf(w, "               Enforced. Each worker runs in a cgroup held to it (cpu.max,\n"+
+			"               memory.max, pids.max) — see the cgroups below.\n")
+		fmt.Fprintf(w, "               It reaches a worker as $%s.\n", agent.WorkerCoresEnv)
+		return
+	}
 	fmt.Fprintf(w, "               Advisory. Nothing enforces it — a toolchain that ignores it takes\n"+
 		"               the box, and the host gate above is still what notices.\n")
 	fmt.Fprintf(w, "               It reaches a worker as $%s.\n", agent.WorkerCoresEnv)
 	fmt.Fprintf(w, "               Which prompts read that variable: pogo agent env\n")
 }
 
+// printCgroups renders each polecat's and gate's cgroup against its limits,
+// or nothing when pogod is not enforcing the budget (user-013). Cumulative
+// CPU and throttled time rather than a rate: a throttled figure that keeps
+// rising is the limit holding a worker back, which is the thing to see.
+func printCgroups(w io.Writer, resp *agent.HostLoadResponse) {
+	if resp.CgroupRoot == "" {
+		return
+	}
+	fmt.Fprintf(w, "\nCgroups:    %s\n", resp.CgroupRoot)
+	if resp.CgroupErr != "" {
+		fmt.Fprintf(w, "            UNREADABLE (%s) — not an empty subtree.\n", resp.CgroupErr)
+		return
+	}
+	if len(resp.Cgroups) == 0 {
+		fmt.Fprintf(w, "            none — no worker or gate is running in one.\n")
+		return
+	}
+	for _, u := range resp.Cgroups {
+		fmt.Fprintf(w, "  %-28s cpu %7.1fs (throttled %.1fs) of %s  mem %s of %s  pids %d of %s\n",
+			u.Name, u.CPUSeconds, u.ThrottledSeconds, limitOr(u.CPUCores > 0, fmt.Sprintf("%g cores", u.CPUCores)),
+			mib(u.MemoryBytes), limitOr(u.MemoryMaxBytes > 0, mib(u.MemoryMaxBytes)),
+			u.Pids, limitOr(u.PidsMax > 0, fmt.Sprint(u.PidsMax)))
+	}
+}
+
+// limitOr is s when a limit is set and "max" when it is not.
+func limitOr(set bool, s string) string {
+	if !set {
+		return "max"
+	}
+	return s
+}
+
+func mib(n int64) string { return fmt.Sprintf("%dMiB", n>>20) }
+
 // printRepoOccupancy renders the per-repo cap's view, or nothing when --repo
 // was not given.
 //
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/drellem2/pogo/internal/agent"
+	"github.com/drellem2/pogo/internal/platform/cgroup"
 )
 
 // TestRepoOccupancyRendersEveryState. The reader of this command is deciding
@@ -169,3 +170,40 @@ func TestWorkerBudgetRendersBothStatesAndItsOwnLimits(t *testing.T) {
 		t.Errorf("rendered a zero budget as a number; got:\n%s", unknown.String())
 	}
 }
+
+// TestEnforcedBudgetAndCgroupsRender. Once a cgroup holds the worker to the
+// budget, "Advisory. Nothing enforces it" would be the false statement, and
+// the cgroups are what let a reader check that it is true.
+func TestEnforcedBudgetAndCgroupsRender(t *testing.T) {
+	var enforced bytes.Buffer
+	printWorkerBudget(&enforced, agent.WorkerBudget{Cores: 3, HostCores: 10, Basis: "division", Enforced: true})
+	if !strings.Contains(enforced.String(), "Enforced") || strings.Contains(enforced.String(), "Nothing enforces it") {
+		t.Errorf("an enforced budget rendered as:\n%s", enforced.String())
+	}
+
+	var none bytes.Buffer
+	printCgroups(&none, &agent.HostLoadResponse{})
+	if none.Len() != 0 {
+		t.Errorf("cgroups rendered with none configured:\n%s", none.String())
+	}
+
+	var groups bytes.Buffer
+	printCgroups(&groups, &agent.HostLoadResponse{
+		CgroupRoot: "/sys/fs/cgroup/pogo",
+		Cgroups: []cgroup.Usage{{
+			Name: "polecat-ember", CPUSeconds: 120, ThrottledSeconds: 45, CPUCores: 3,
+			MemoryBytes: 512 << 20, MemoryMaxBytes: 6 << 30, Pids: 14, PidsMax: 1536,
+		}},
+	})
+	for _, want := range []string{"/sys/fs/cgroup/pogo", "polecat-ember", "throttled 45.0s", "of 3 cores", "512MiB of 6144MiB", "pids 14 of 1536"} {
+		if !strings.Contains(groups.String(), want) {
+			t.Errorf("missing %q; got:\n%s", want, groups.String())
+		}
+	}
+
+	var broken bytes.Buffer
+	printCgroups(&broken, &agent.HostLoadResponse{CgroupRoot: "/sys/fs/cgroup/pogo", CgroupErr: "permission denied"})
+	if !strings.Contains(broken.String(), "UNREADABLE") {
+		t.Errorf("an unreadable subtree rendered as:\n%s", broken.String())
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/drellem2/pogo/internal/cli"
+	"github.com/drellem2/pogo/internal/config"
+	"github.com/drellem2/pogo/internal/tracing"
+)
+
+// traceBarWidth is the waterfall's width in columns: wide enough to tell a
+// one-minute gate from a five-minute one on a three-hour trace, narrow enough
+// to leave the span names room on an 80-column terminal.
+const traceBarWidth = 32
+
+// newItemCmd builds `pogo item`, the commands about one work item across
+// every part of pogod that touched it.
+func newItemCmd(jsonOutput *bool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "item",
+		Short: "Inspect one work item's path through the fleet",
+	}
+	cmd.AddCommand(newItemTraceCmd(jsonOutput))
+	return cmd
+}
+
+// newItemTraceCmd builds `pogo item trace <id>` (user-023).
+//
+// It reads the span file directly, as `pogo events list` reads the event log:
+// the spans are on this host's disk whether or not pogod is up, and a trace is
+// most often wanted after something went wrong with it.
+func newItemTraceCmd(jsonOutput *bool) *cobra.Command {
+	return &cobra.Command{
+		Use:   "trace <work-item-id>",
+		Short: "Show a work item's dispatch, run and merge as a waterfall",
+		Long: `Show every span pogod recorded for a work item — its dispatch and the
+dispatch's phases, the polecat's start verification, run and reap, and the
+refinery's queue wait, attempts and gates — as a waterfall, so where the time
+went is one look rather than a search of the event log.
+
+Spans nest under the span that contained them. A merge is a root of its own,
+because the refinery does not know which dispatch produced the branch it is
+merging; a re-dispatched item has a dispatch root per attempt. Spans that
+ended in error are marked with "!" and say why.
+
+Spans are read from $POGO_HOME/traces.jsonl, which pogod writes unless
+[tracing] enabled = false. An item that ran before tracing was on, or whose
+spans have rotated out, has none.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := config.TracesPath()
+			spans, err := tracing.ReadTrace(path, args[0])
+			if err != nil {
+				return err
+			}
+			if *jsonOutput {
+				if spans == nil {
+					spans = []tracing.Span{}
+				}
+				cli.PrintJSON(spans)
+				return nil
+			}
+			if len(spans) == 0 {
+				fmt.Printf("No spans recorded for %s in %s.\n", args[0], path)
+				return nil
+			}
+			renderTrace(os.Stdout, args[0], spans, traceBarWidth)
+			return nil
+		},
+	}
+}
+
+// traceNode is a span and the spans it contains, in start order.
+type traceNode struct {
+	span     tracing.Span
+	children []*traceNode
+}
+
+// traceTree nests spans under their parents. A span whose parent is not in
+// the set — a root, or a child whose parent rotated out of the file or was
+// never ended — is a root, so nothing recorded is left out.
+func traceTree(spans []tracing.Span) []*traceNode {
+	nodes := make(map[tracing.SpanID]*traceNode, len(spans))
+	ordered := make([]*traceNode, len(spans))
+	for i, s := range spans {
+		ordered[i] = &traceNode{span: s}
+		nodes[s.SpanID] = ordered[i]
+	}
+	var roots []*traceNode
+	for _, n := range ordered {
+		if p, ok := nodes[n.span.ParentID]; ok && !n.span.ParentID.IsZero() && p != n {
+			p.children = append(p.children, n)
+		} else {
+			roots = append(roots, n)
+		}
+	}
+	var sortNodes func([]*traceNode)
+	sortNodes = func(ns []*traceNode) {
+		sort.SliceStable(ns, func(i, j int) bool { return ns[i].span.Start.Before(ns[j].span.Start) })
+		for _, n := range ns {
+			sortNodes(n.children)
+		}
+	}
+	sortNodes(roots)
+	return roots
+}
+
+// renderTrace writes the waterfall: per span its offset from the trace's
+// first start, its duration, a bar placing it within the whole trace, and
+// its name indented by depth, followed by its attributes.
+func renderTrace(w io.Writer, workItemID string, spans []tracing.Span, width int) {
+	first, last := spans[0].Start, spans[0].End
+	errors := 0
+	for _, s := range spans {
+		if s.Start.Before(first) {
+			first = s.Start
+		}
+		if s.End.After(last) {
+			last = s.End
+		}
+		if s.Status == tracing.StatusError {
+			errors++
+		}
+	}
+	total := last.Sub(first)
+	fmt.Fprintf(w, "Work item %s — %d span(s) over %s, from %s\n",
+		workItemID, len(spans), traceDuration(total), first.UTC().Format("2006-01-02 15:04:05Z"))
+	if errors > 0 {
+		fmt.Fprintf(w, "%d span(s) ended in error.\n", errors)
+	}
+	fmt.Fprintf(w, "\n%-9s %-9s %-*s  %s\n", "OFFSET", "DURATION", width, "", "SPAN")
+
+	var walk func(n *traceNode, depth int)
+	walk = func(n *traceNode, depth int) {
+		s := n.span
+		mark := " "
+		if s.Status == tracing.StatusError {
+			mark = "!"
+		}
+		indent := strings.Repeat("  ", depth)
+		fmt.Fprintf(w, "%-9s %-9s %s %s%s%s%s\n",
+			"+"+traceDuration(s.Start.Sub(first)), traceDuration(s.Duration()),
+			traceBar(s.Start.Sub(first), s.End.Sub(first), total, width),
+			mark, indent, s.Name, traceAttrs(s))
+		if s.Status == tracing.StatusError && s.Message != "" {
+			fmt.Fprintf(w, "%*s %s   %s\n", 9+1+9+1+width, "", indent, s.Message)
+		}
+		for _, c := range n.children {
+			walk(c, depth+1)
+		}
+	}
+	for _, r := range traceTree(spans) {
+		walk(r, 0)
+	}
+}
+
+// traceBar draws [start, end) of a trace total long in width columns. Every
+// span gets at least one column, so an instant phase is still visible.
+func traceBar(start, end, total time.Duration, width int) string {
+	if total <= 0 {
+		return strings.Repeat("█", width)
+	}
+	from := int(float64(start) / float64(total) * float64(width))
+	to := int(math.Ceil(float64(end) / float64(total) * float64(width)))
+	from = min(max(from, 0), width-1)
+	to = min(max(to, from+1), width)
+	return strings.Repeat("·", from) + strings.Repeat("█", to-from) + strings.Repeat("·", width-to)
+}
+
+// traceAttrs renders a span's attributes as " key=value …", sorted.
+func traceAttrs(s tracing.Span) string {
+	var b strings.Builder
+	for _, k := range s.AttrKeys() {
+		v := fmt.Sprint(s.Attributes[k])
+		if v == "" {
+			continue
+		}
+		fmt.Fprintf(&b, " %s=%s", k, v)
+	}
+	return b.String()
+}
+
+// traceDuration is d at a precision that reads at a glance: milliseconds
+// under a second, tenths under a minute, whole seconds beyond.
+func traceDuration(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	case d < time.Minute:
+		return d.Round(100 * time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
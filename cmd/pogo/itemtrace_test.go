@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drellem2/pogo/internal/tracing"
+)
+
+// TestRenderTraceNestsAndMarksErrors: the waterfall is read to find where the
+// time went and what failed, so children sit under their parents in start
+// order, a span whose parent is missing still shows, and an error says why.
+func TestRenderTraceNestsAndMarksErrors(t *testing.T) {
+	t0 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	tr := tracing.TraceIDFor("mg-1")
+	dispatch := tracing.Span{TraceID: tr, SpanID: tracing.NewSpanID(), Name: "dispatch",
+		Start: t0, End: t0.Add(2 * time.Second), Status: tracing.StatusOK,
+		Attributes: map[string]any{"agent": "c1"}}
+	spawn := tracing.Span{TraceID: tr, SpanID: tracing.NewSpanID(), ParentID: dispatch.SpanID, Name: "dispatch.spawn",
+		Start: t0.Add(time.Second), End: t0.Add(2 * time.Second), Status: tracing.StatusOK}
+	gates := tracing.Span{TraceID: tr, SpanID: tracing.NewSpanID(), ParentID: dispatch.SpanID, Name: "dispatch.gates",
+		Start: t0, End: t0.Add(time.Second), Status: tracing.StatusOK}
+	merge := tracing.Span{TraceID: tr, SpanID: tracing.NewSpanID(), Name: "refinery.merge",
+		Start: t0.Add(time.Hour), End: t0.Add(2 * time.Hour), Status: tracing.StatusError, Message: "gate failed"}
+	orphan := tracing.Span{TraceID: tr, SpanID: tracing.NewSpanID(), ParentID: tracing.NewSpanID(), Name: "polecat.run",
+		Start: t0.Add(time.Minute), End: t0.Add(time.Hour), Status: tracing.StatusOK}
+
+	var buf bytes.Buffer
+	renderTrace(&buf, "mg-1", []tracing.Span{merge, spawn, dispatch, orphan, gates}, 20)
+	out := buf.String()
+
+	for _, want := range []string{
+		"Work item mg-1 — 5 span(s) over 2h0m0s",
+		"1 span(s) ended in error.",
+		" dispatch agent=c1",
+		"   dispatch.gates",
+		"!refinery.merge",
+		"gate failed",
+		"+1h0m0s",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	order := []string{"dispatch agent", "dispatch.gates", "dispatch.spawn", "polecat.run", "refinery.merge"}
+	last := -1
+	for _, name := range order {
+		i := strings.Index(out, name)
+		if i < last {
+			t.Errorf("%q out of order:\n%s", name, out)
+		}
+		last = i
+	}
+}
+
+func TestTraceBarPlacesSpansAndNeverVanishes(t *testing.T) {
+	for _, tc := range []struct {
+		start, end, total time.Duration
+		want              string
+	}{
+		{0, 10, 10, "██████████"},
+		{0, 5, 10, "█████·····"},
+		{5, 10, 10, "·····█████"},
+		{10, 10, 10, "·········█"},
+		{3, 3, 10, "···█······"},
+		{0, 0, 0, "██████████"},
+	} {
+		if got := traceBar(tc.start, tc.end, tc.total, 10); got != tc.want {
+			t.Errorf("traceBar(%d, %d, %d) = %q, want %q", tc.start, tc.end, tc.total, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+// The state column of `pogo agent list` (user-020).
+//
+// The list used to say "running" for every live agent, and an operator had to
+// run diagnose on each one to learn whether it was working, waiting or wedged.
+// The lifecycle state answers that in the row, with how long the agent has been
+// in it: "starting 12s" and "stalled 12s" are different fleets, and so are
+// "idle 40s" and "idle 3h".
+
+import (
+	"time"
+
+	"github.com/drellem2/pogo/internal/agent"
+)
+
+// stateCell is the per-row rendering of an agent's lifecycle state and time in
+// it. An agent from a pogod that predates states has none, and renders nothing
+// rather than a guess.
+func stateCell(a agent.AgentInfo) string {
+	if a.State == nil || a.State.Name == "" {
+		return ""
+	}
+	return "  state=" + string(a.State.Name) + " " + a.State.InState().Truncate(time.Second).String()
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/drellem2/pogo/internal/agent"
+)
+
+func TestStateCellShowsTimeInState(t *testing.T) {
+	a := agent.AgentInfo{Name: "mayor", Status: agent.StatusRunning}
+	if got := stateCell(a); got != "" {
+		t.Fatalf("stateCell with no state = %q, want nothing", got)
+	}
+	a.State = &agent.StateInfo{Name: agent.StateStarting, SinceS: 12}
+	if got := stateCell(a); got != "  state=starting 12s" {
+		t.Fatalf("stateCell = %q", got)
+	}
+	a.State = &agent.StateInfo{Name: agent.StateIdle, SinceS: 3*3600 + 5}
+	if got := stateCell(a); got != "  state=idle 3h0m5s" {
+		t.Fatalf("stateCell = %q", got)
+	}
+}
@@ -9,10 +9,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"strings"
 	"syscall"
 	"time"
@@ -171,7 +173,13 @@ func main() {
 	// names come from the live Default* consts until the migration guard pins
 	// the frozen legacy ones. `pogo install` runs that guard and re-resolves
 	// before it synthesizes prompts; see pinAndResolveRoles (mg-bc47).
-	resolveRoles()
+	startupCfg := resolveRoles()
+
+	// [providers.<id>] tables (user-018): a harness declared in config is
+	// known to every command that resolves one — --provider validation,
+	// doctor's PATH check — not only to pogod. A table that does not build is
+	// left out here and reported by `pogo doctor`.
+	providers.Configure(startupCfg.Providers)
 
 	var jsonOutput bool
 
@@ -2057,6 +2065,15 @@ listing, and 'pogo agent roster' is the full view. --json is unchanged: it
 emits the registry array exactly as before, because eight callers consume it
 and assume every element has a process behind it (mg-7d20).
 
+Each row carries state=<state> <time in it>, the agent's lifecycle state:
+
+  starting  spawned, and has shown no output yet
+  healthy   output within the last 30s
+  idle      alive and quiet, short of its stall threshold
+  stalled   quiet past its stall threshold, or never produced output within its
+            stall threshold
+  stopped   the process has exited
+
 Each running row carries mail-warn=<state>, and a summary under the listing
 says how many running agents are ARMED with the mg-d924 dead-recipient mail
 warning. That warning is a harness hook installed at SPAWN, so it protects an
@@ -2098,8 +2115,8 @@ running kept the old silent behaviour, and the only way to find out was to run
 					if a.WorkItemID != "" {
 						workItem = "  work-item=" + a.WorkItemID
 					}
-					fmt.Printf("%-20s  pid=%-6d  type=%-8s  status=%-10s  uptime=%s%s%s%s\n",
-						a.Name, a.PID, a.Type, a.Status, a.Uptime, mailWarnCell(a), activity, workItem)
+					fmt.Printf("%-20s  pid=%-6d  type=%-8s  status=%-10s  uptime=%s%s%s%s%s\n",
+						a.Name, a.PID, a.Type, a.Status, a.Uptime, stateCell(a), mailWarnCell(a), activity, workItem)
 				}
 				printMailWarnSummary(os.Stdout, agents)
 				printAbsentFooter()
@@ -2299,6 +2316,7 @@ adds are keyed on (agent, id), so nothing stacks duplicates.`,
 		},
 	}
 
+	var attachOpts client.AttachOptions
 	var cmdAgentAttach = &cobra.Command{
 		Use:   "attach <name>",
 		Short: "Attach terminal to a running agent",
@@ -2306,6 +2324,15 @@ adds are keyed on (agent, id), so nothing stacks duplicates.`,
 The agent's output streams to your terminal and your input goes to the agent.
 Detach with Ctrl-\ to leave the agent running and restore your terminal.
 
+Any number of people may attach to one agent at once, here or from the web
+dashboard, and all of them see its output. Only one types: the first
+read-write client to press a key holds the keyboard until it detaches, and
+input from everyone else is dropped. Ctrl-] takes the keyboard from whoever
+holds it (--takeover takes it on connect). --ro attaches as an observer that
+can neither type nor resize the agent's terminal — the safe way to watch a
+live polecat or to demo the fleet. Who else is attached is printed before the
+session starts and kept in the terminal's title while it runs.
+
 Detaching restores both your terminal's input modes and any display modes the
 agent's TUI turned on (alternate screen, mouse and focus reporting, cursor
 visibility), so the shell prompt you return to is clean. If the agent exits or
@@ -2317,8 +2344,8 @@ leaving you on a frozen screen.`,
 			if err != nil {
 				cli.ExitWithError(jsonOutput, err.Error(), cli.ExitError)
 			}
-			fmt.Printf("Attaching to agent %s (pid=%d). Detach with Ctrl-\\.\n", info.Name, info.PID)
-			if err := client.AttachAgent(info.SocketPath); err != nil {
+			fmt.Print(attachHeader(info, attachOpts))
+			if err := client.AttachAgentWith(info.SocketPath, attachOpts); err != nil {
 				cli.ExitWithError(jsonOutput, err.Error(), cli.ExitError)
 			}
 			// Say so explicitly. A detach can be the user's Ctrl-\ or the agent
@@ -2327,9 +2354,13 @@ leaving you on a frozen screen.`,
 			fmt.Printf("Detached from agent %s.\n", info.Name)
 		},
 	}
+	cmdAgentAttach.Flags().BoolVar(&attachOpts.ReadOnly, "ro", false, "Attach read-only: watch without typing or resizing")
+	cmdAgentAttach.Flags().BoolVar(&attachOpts.Takeover, "takeover", false, "Take the keyboard from whoever holds it on connect")
+	cmdAgentAttach.MarkFlagsMutuallyExclusive("ro", "takeover")
 
-	var outputPlain bool
+	var outputPlain, outputFollow bool
 	var outputBytes, outputLines int
+	var outputOffset int64
 	var cmdAgentOutput = &cobra.Command{
 		Use:   "output <name>",
 		Short: "Show recent output from an agent",
@@ -2343,7 +2374,14 @@ the whole ring. The two are mutually exclusive.
 
 Sizing note: pogod's wedge detector judges an agent on the last %d bytes
 (wedgewatch.OutputScanBytes). Reproducing what it saw takes --bytes %d; the
-default is a quarter of that.`,
+default is a quarter of that.
+
+-f follows the output as the agent prints it, over pogod's streaming endpoint
+(/agents/<name>/output/stream) instead of polling, until the agent exits or you
+press Ctrl-C. It starts with the same default window, or --bytes back. With
+--plain it prints logical lines instead of raw bytes: escapes stripped, blank
+lines and lines repeated by a TUI redraw dropped. --json prints one event per
+line, each with the offset it ends at; --offset <id> resumes from one.`,
 			agent.DefaultOutputBytes, agent.OutputRingBytes,
 			wedgewatch.OutputScanBytes, wedgewatch.OutputScanBytes),
 		Args: cobra.ExactArgs(1),
@@ -2357,6 +2395,30 @@ default is a quarter of that.`,
 						fmt.Sprintf("--%s must be positive, got %d", f, v), cli.ExitError)
 				}
 			}
+			if cmd.Flags().Changed("offset") && !outputFollow {
+				cli.ExitWithError(jsonOutput, "--offset needs -f", cli.ExitError)
+			}
+			if outputFollow {
+				if outputLines > 0 {
+					cli.ExitWithError(jsonOutput, "--lines cannot be used with -f; use --bytes", cli.ExitError)
+				}
+				if outputOffset < 0 {
+					cli.ExitWithError(jsonOutput, fmt.Sprintf("--offset must not be negative, got %d", outputOffset), cli.ExitError)
+				}
+				opts := client.AgentOutputStreamOptions{
+					Lines:  outputPlain,
+					Resume: cmd.Flags().Changed("offset"),
+					Offset: outputOffset,
+					Bytes:  agent.DefaultOutputBytes,
+				}
+				if outputBytes > 0 {
+					opts.Bytes = outputBytes
+				}
+				if err := followAgentOutput(args[0], opts, jsonOutput); err != nil {
+					cli.ExitWithError(jsonOutput, err.Error(), cli.ExitError)
+				}
+				return
+			}
 			output, err := client.GetAgentOutput(args[0], client.AgentOutputOptions{
 				Plain: outputPlain,
 				Bytes: outputBytes,
@@ -2377,6 +2439,37 @@ default is a quarter of that.`,
 		fmt.Sprintf("Return the last N bytes (default %d, max %d)", agent.DefaultOutputBytes, agent.OutputRingBytes))
 	cmdAgentOutput.Flags().IntVar(&outputLines, "lines", 0, "Return the last N lines from the whole retained ring")
 	cmdAgentOutput.MarkFlagsMutuallyExclusive("bytes", "lines")
+	cmdAgentOutput.Flags().BoolVarP(&outputFollow, "follow", "f", false, "Stream output as the agent prints it")
+	cmdAgentOutput.Flags().Int64Var(&outputOffset, "offset", 0, "With -f, resume from this stream offset (an event id from --json)")
+	cmdAgentOutput.MarkFlagsMutuallyExclusive("bytes", "offset")
+
+	var cmdAgentScreen = &cobra.Command{
+		Use:   "screen <name>",
+		Short: "Show what is on an agent's terminal now",
+		Long: `Show an agent's screen as a terminal attached to it would show it now.
+
+pogod runs every agent's PTY output through a terminal emulator of the PTY's
+size, so this is the rendered grid — cursor moves applied, erased text gone,
+the alternate screen if the harness is on it — where "pogo agent output" is the
+raw stream the screen was drawn from. A differential-render TUI's stream is
+redraw fragments; its screen is what a human would read.
+
+Blank rows below the last line with anything on it are not printed. --json
+returns every row with the screen's size, the cursor position, whether the
+cursor is shown and whether the alternate screen is up.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			snap, err := client.GetAgentScreen(args[0])
+			if err != nil {
+				cli.ExitWithError(jsonOutput, err.Error(), cli.ExitError)
+			}
+			if jsonOutput {
+				cli.PrintJSON(snap)
+			} else {
+				fmt.Print(snap.Text())
+			}
+		},
+	}
 
 	var cmdAgentStatus = &cobra.Command{
 		Use:   "status [name]",
@@ -2463,7 +2556,8 @@ default is a quarter of that.`,
 		Use:   "diagnose <name>",
 		Short: "Diagnose agent health (stall detection, process checks)",
 		Long: `Run diagnostics on a specific agent. Checks last-activity timestamps,
-process health, idle duration, and stall detection thresholds.
+process health, idle duration, and stall detection thresholds, and names the
+sandbox profile the agent was spawned under ("none" when it is unconfined).
 
 Health states:
   healthy      — produced output within the last 30s (actively working)
@@ -2505,6 +2599,27 @@ down. To keep it down, park it.`,
 				fmt.Printf("PID:            %d\n", diag.PID)
 				fmt.Printf("Process alive:  %v\n", diag.ProcessAlive)
 				fmt.Printf("Uptime:         %s\n", diag.Uptime)
+				// Unconfined is said, not left out: it is the answer to "what
+				// can this agent write?" (user-012).
+				if diag.Sandbox != nil {
+					fmt.Printf("Sandbox:        %s\n", diag.Sandbox)
+				} else {
+					fmt.Printf("Sandbox:        none\n")
+				}
+				// A stream-mode agent's turn state is what its harness
+				// reported, not a reading of its screen (user-019).
+				if st := diag.Stream; st != nil {
+					turn := "between turns"
+					if st.InTurn {
+						turn = "in a turn"
+					}
+					fmt.Printf("Mode:           stream (%s)\n", turn)
+					fmt.Printf("Turns:          %d, %d tool calls, %d errors\n", st.Turns, st.ToolCalls, st.Errors)
+					fmt.Printf("Usage:          %d in / %d out tokens, $%.4f\n", st.Usage.InputTokens, st.Usage.OutputTokens, st.Usage.CostUSD)
+					if st.LastError != "" {
+						fmt.Printf("Last error:     %s\n", st.LastError)
+					}
+				}
 				if !diag.LastActivity.IsZero() {
 					fmt.Printf("Last activity:  %s ago\n", diag.IdleDuration)
 				} else {
@@ -3761,6 +3876,24 @@ Exits with code 1 if any critical check fails (--check mode only).`,
 					pass(provider.Binary+" in PATH", p)
 				}
 			}
+			// Every [providers.<id>] table, configured for an agent type or
+			// not (user-018): one that does not build is left out of the
+			// registry, and pogod only says so in its log. Its binary is a
+			// soft check for the same reason the configured harness's is.
+			providerDefs := config.Load().Providers
+			for _, id := range slices.Sorted(maps.Keys(providerDefs)) {
+				name := "provider " + id
+				p, err := providers.FromConfig(providerDefs[id])
+				if err != nil {
+					fail(name, err.Error())
+					continue
+				}
+				if path, err := exec.LookPath(p.Binary); err != nil {
+					warn(name, fmt.Sprintf("%s not found in PATH", p.Binary))
+				} else {
+					pass(name, "declared in config; "+path)
+				}
+			}
 
 			// 4. Repos configured
 			projs, projErr := client.GetProjects()
@@ -4577,6 +4710,13 @@ branches; work items and mail live in mg/macguffin (the task-store CLI).`,
 	// because whether anyone asks is the measurement it exists to take.
 	rootCmd.AddCommand(newInvestigationsCmd(&jsonOutput))
 	rootCmd.AddCommand(newHostCmd(&jsonOutput))
+	// item trace (user-023): one work item's spans, dispatch to merge, as a
+	// waterfall. Read from the span file pogod writes, like events list.
+	rootCmd.AddCommand(newItemCmd(&jsonOutput))
+	// ui (user-024): the web dashboard's URL, token in the fragment.
+	rootCmd.AddCommand(newUICmd(&jsonOutput))
+	// top (user-025): the fleet on one live screen.
+	rootCmd.AddCommand(newTopCmd(&jsonOutput))
 	cmdServer.AddCommand(cmdServerStart)
 	cmdServer.AddCommand(cmdServerStop)
 	cmdServer.AddCommand(cmdServerStatus)
@@ -4663,6 +4803,8 @@ report; it is safe from anywhere and never acts.`,
 	cmdAgent.AddCommand(cmdAgentDiagnose)
 	cmdAgent.AddCommand(cmdAgentAttach)
 	cmdAgent.AddCommand(cmdAgentOutput)
+	cmdAgent.AddCommand(cmdAgentScreen)
+	cmdAgent.AddCommand(newAgentReplayCmd(&jsonOutput))
 	cmdAgent.AddCommand(cmdAgentWitness)
 	cmdAgent.AddCommand(newAgentEnvCmd(&jsonOutput))
 	cmdAgentPrompt.AddCommand(cmdAgentPromptList)
@@ -4696,6 +4838,7 @@ report; it is safe from anywhere and never acts.`,
 	var submitPostMergeTag string
 	var submitVerdict string
 	var submitVerdictFile string
+	var submitAfter string
 	var cmdRefinerySubmit = &cobra.Command{
 		Use:   "submit <branch>",
 		Short: "Submit a branch to the merge queue",
@@ -4809,6 +4952,19 @@ The work item itself is untouched by that record, on purpose: the drop-detector
 reads any unexpected sidecar key as "an outcome was written down", so a marker
 saying "no verdict here" would have made a verdict-free close read as answered.
 
+--after <mr-id> submits a branch STACKED on another one still in the queue. The
+refinery holds it until that merge request has merged, then merges it onto the
+result; if the one it builds on fails or is cancelled, this one is cancelled
+too, with a record naming the dependency, instead of rebasing onto a target
+that lacks the work it was built on and burning attempts on that. Other
+branches for the repo are not held behind it. The dependency must be for the
+same repo and target. Submit the bottom of the stack first:
+
+  A=$(pogo refinery submit part-1 --repo=. --json | jq -r .id)
+  pogo refinery submit part-2 --repo=. --after=$A
+
+'pogo refinery queue' shows the chain.
+
 Example:
   pogo refinery submit polecat-a3f --repo=/path/to/repo`,
 		Args: cobra.ExactArgs(1),
@@ -4840,6 +4996,7 @@ Example:
 				DeferDone:           submitDeferDone,
 				PostMergeTag:        submitPostMergeTag,
 				Verdict:             verdict,
+				DependsOn:           submitAfter,
 			})
 			if err != nil {
 				cli.ExitWithError(jsonOutput, err.Error(), cli.ExitError)
@@ -4848,6 +5005,9 @@ Example:
 				cli.PrintJSON(map[string]string{"id": id, "branch": branch, "status": "queued"})
 			} else {
 				fmt.Printf("Submitted %s to merge queue (id=%s)\n", branch, id)
+				if submitAfter != "" {
+					fmt.Printf("It waits for %s to merge first, and is cancelled if that one does not.\n", submitAfter)
+				}
 			}
 		},
 	}
@@ -4858,6 +5018,7 @@ Example:
 	cmdRefinerySubmit.Flags().BoolVar(&submitDeferDone, "defer-done", false, "Skip pogod's auto-done/auto-stop at merge so the polecat owns its post-merge lifecycle and calls 'mg done' itself (already implied when --target is not the repo's default branch; a bounded backstop reaps a deferred polecat that never completes)")
 	cmdRefinerySubmit.Flags().StringVar(&submitVerdict, "verdict", "", "YOUR OWN result for the work item, as a non-empty JSON object, carried through the merge and written into the item's result sidecar under \"verdict\" (mg-dfea). On the auto-done path this is the only moment you can record one — pogod closes the item at merge and mg refuses your later 'mg done --result' as already-done")
 	cmdRefinerySubmit.Flags().StringVar(&submitVerdictFile, "verdict-file", "", "Read --verdict from this file, or from stdin when it is \"-\" (avoids shell-quoting a JSON object)")
+	cmdRefinerySubmit.Flags().StringVar(&submitAfter, "after", "", "Merge request ID this branch is stacked on: hold it until that one has merged, and cancel it if that one fails or is cancelled")
 	cmdRefinerySubmit.Flags().StringVar(&submitPostMergeTag, "post-merge-tag", "", "Have the REFINERY create this git tag on the commit the merge lands as and push it, before the author is reaped (use for release cuts — the refinery is the only actor that both sees the merged SHA and outlives the author; a failure here blocks auto-done and mails the mayor)")
 
 	var cmdRefineryStatus = &cobra.Command{
@@ -5176,6 +5337,9 @@ Examples:
 				if note := mr.FailureClass.TriageNote(); note != "" && mr.Status == refinery.StatusFailed {
 					fmt.Printf("           %s\n", note)
 				}
+				if mr.DependsOn != "" {
+					fmt.Printf("After:     %s (stacked: does not start until that merge request has merged)\n", mr.DependsOn)
+				}
 				if mr.PRFlow {
 					fmt.Printf("PR flow:   yes — %s is an integration branch, not the repo default.\n", mr.TargetRef)
 					fmt.Printf("           Merging is an integration step, not completion: the author still\n")
@@ -5187,6 +5351,14 @@ Examples:
 				if mr.MergedSHA != "" {
 					fmt.Printf("Merged as: %s\n", mr.MergedSHA)
 				}
+				// A train's gate output is shared, so say whose verdict it was
+				// before a reader takes it as this branch's alone.
+				if mr.Train != "" {
+					fmt.Printf("Train:     %s\n", mr.Train)
+					if mr.TrainNote != "" {
+						fmt.Printf("           %s\n", mr.TrainNote)
+					}
+				}
 				// Print the post-merge step in both directions (mg-6879). A
 				// declared-but-failed step is the one case where Status reads
 				// "merged" and the deliverable does not exist, so it must be
@@ -5233,6 +5405,11 @@ Examples:
 				// printed before the gate output, since it is what an operator
 				// is looking for while the MR is still in flight.
 				fmt.Print(formatMRProgress(mr.Progress, time.Now()))
+				// Which gates the repo's path rules chose, so "no gates ran"
+				// reads as a decision with a reason and not as a skipped step.
+				if mr.GateRule != "" {
+					fmt.Printf("Gates:     %s\n", mr.GateRule)
+				}
 				if mr.GateOutput != "" {
 					fmt.Printf("\n--- Gate Output ---\n%s\n", mr.GateOutput)
 				}
@@ -5322,6 +5499,7 @@ Example:
 	cmdRefinery.AddCommand(cmdRefineryPrune)
 	cmdRefinery.AddCommand(cmdRefineryCancel)
 	rootCmd.AddCommand(cmdRefinery)
+	rootCmd.AddCommand(newGateRunnerCmd())
 
 	// Cross-repo operations
 	var cmdDeps = &cobra.Command{
@@ -5475,24 +5653,43 @@ Example:
 	cmdEvents.AddCommand(cmdEventsEmit)
 
 	var (
-		listSince string
-		listType  string
-		listAgent string
-		listFile  string
+		listSince   string
+		listUntil   string
+		listType    string
+		listAgent   string
+		listWhere   string
+		listGroupBy string
+		listCount   bool
+		listFile    string
 	)
 	var cmdEventsList = &cobra.Command{
 		Use:   "list",
 		Short: "List events from ~/.pogo/events.log",
-		Long: `Print events from the log, optionally filtered by age, type, and agent.
+		Long: `Print events from the log and its rotated files, optionally filtered by
+time, type, agent and a --where predicate over any field.
 
 By default prints a pretty one-line-per-event view (timestamp, event_type,
 agent, work_item_id, repo, summarized details). With --json each matching
-event is dumped as raw JSONL on stdout for piping into jq, etc.
+event is dumped as raw JSONL on stdout for piping into jq, etc. --count prints
+how many events match instead, and --group-by FIELD how many per value.
+
+--since and --until take a duration back from now (1h, 30m) or an RFC3339
+time. --where takes comparisons over event_type, agent, work_item_id, repo,
+timestamp and details.KEY, joined with and/or/not:
+
+  details.exit_code != 0
+  type = agent_stopped and details.reason ~ 'stall'
+
+Each log file keeps a small sidecar index (events.log.idx/), so a query for a
+recent window or a given type, agent or work item reads only the parts of the
+log that can hold a match.
 
 Examples:
   pogo events list --since=1h
   pogo events list --since=24h --type=refinery_merged
-  pogo events list --since=30m --agent=mayor --json | jq .`,
+  pogo events list --since=30m --agent=mayor --json | jq .
+  pogo events list --since=7d --where 'details.exit_code != 0'
+  pogo events list --since=24h --type=agent_crashed --group-by agent --count`,
 		Args: cobra.NoArgs,
 		Run: func(cmd *cobra.Command, args []string) {
 			path := listFile
@@ -5504,20 +5701,31 @@ Examples:
 				path = p
 			}
 
-			filter := events.Filter{Type: listType, Agent: listAgent}
-			if listSince != "" {
-				d, err := time.ParseDuration(listSince)
+			var q events.Query
+			var err error
+			if q.Since, err = eventsTimeBound("--since", listSince); err != nil {
+				cli.ExitWithError(jsonOutput, err.Error(), cli.ExitError)
+			}
+			if q.Until, err = eventsTimeBound("--until", listUntil); err != nil {
+				cli.ExitWithError(jsonOutput, err.Error(), cli.ExitError)
+			}
+			where, err := events.ParsePredicate(listWhere)
+			if err != nil {
+				cli.ExitWithError(jsonOutput, err.Error(), cli.ExitError)
+			}
+			q.Where = events.And(events.Filter{Type: listType, Agent: listAgent}.Query().Where, where)
+
+			if listCount || listGroupBy != "" {
+				groups, total, err := events.Count(path, q, listGroupBy)
 				if err != nil {
-					cli.ExitWithError(jsonOutput, fmt.Sprintf("--since: invalid duration %q: %v", listSince, err), cli.ExitError)
+					cli.ExitWithError(jsonOutput, "read log: "+err.Error(), cli.ExitError)
 				}
-				if d <= 0 {
-					cli.ExitWithError(jsonOutput, "--since must be a positive duration (e.g. 1h, 30m)", cli.ExitError)
-				}
-				filter.SinceMin = time.Now().Add(-d)
+				printEventCounts(jsonOutput, listGroupBy, groups, total)
+				return
 			}
 
-			matches, err := events.ReadFiltered(path, filter)
-			if err != nil {
+			var matches []events.Event
+			if err := events.Select(path, q, func(ev events.Event) { matches = append(matches, ev) }); err != nil {
 				cli.ExitWithError(jsonOutput, "read log: "+err.Error(), cli.ExitError)
 			}
 
@@ -5535,9 +5743,13 @@ Examples:
 			}
 		},
 	}
-	cmdEventsList.Flags().StringVar(&listSince, "since", "", "only show events newer than duration (e.g. 1h, 30m, 24h)")
+	cmdEventsList.Flags().StringVar(&listSince, "since", "", "only show events newer than this: a duration back from now (1h, 7d) or an RFC3339 time")
+	cmdEventsList.Flags().StringVar(&listUntil, "until", "", "only show events older than this: a duration back from now or an RFC3339 time")
 	cmdEventsList.Flags().StringVar(&listType, "type", "", "filter by event_type (exact match)")
 	cmdEventsList.Flags().StringVar(&listAgent, "agent", "", "filter by agent identity (exact match)")
+	cmdEventsList.Flags().StringVar(&listWhere, "where", "", "filter by a predicate, e.g. 'details.exit_code != 0'")
+	cmdEventsList.Flags().StringVar(&listGroupBy, "group-by", "", "count matching events per value of this field (agent, event_type, details.KEY, ...)")
+	cmdEventsList.Flags().BoolVar(&listCount, "count", false, "print how many events match instead of the events")
 	cmdEventsList.Flags().StringVar(&listFile, "file", "", "log file path (default: ~/.pogo/events.log)")
 	cmdEvents.AddCommand(cmdEventsList)
 
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/drellem2/pogo/internal/agent"
+	"github.com/drellem2/pogo/internal/client"
+)
+
+// followAgentOutput is `pogo agent output -f`: it streams name's output to
+// stdout until the agent exits or the user interrupts.
+//
+// Raw output goes to stdout as the agent printed it and lines one per line;
+// gaps and the exit are noted on stderr, so stdout stays the agent's output
+// alone. With --json every event is one JSON object per line, IDs included,
+// so a script can resume with --offset where it stopped.
+func followAgentOutput(name string, opts client.AgentOutputStreamOptions, jsonOutput bool) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	err := client.StreamAgentOutput(ctx, name, opts, func(ev agent.OutputEvent) error {
+		return printOutputEvent(os.Stdout, os.Stderr, ev, jsonOutput)
+	})
+	if err == context.Canceled {
+		return nil
+	}
+	return err
+}
+
+// printOutputEvent writes one stream event for followAgentOutput.
+func printOutputEvent(stdout, stderr io.Writer, ev agent.OutputEvent, jsonOutput bool) error {
+	if jsonOutput {
+		return json.NewEncoder(stdout).Encode(ev)
+	}
+	var err error
+	switch ev.Type {
+	case "output":
+		_, err = io.WriteString(stdout, ev.Data)
+	case "line":
+		_, err = fmt.Fprintln(stdout, ev.Text)
+	case "gap":
+		if ev.To > ev.From {
+			fmt.Fprintf(stderr, "[pogo: %d bytes of output were overwritten before they could be sent]\n", ev.To-ev.From)
+		} else {
+			fmt.Fprintf(stderr, "[pogo: offset %d is not in this run of the agent; continuing from %d]\n", ev.From, ev.To)
+		}
+	case "exit":
+		fmt.Fprintf(stderr, "[pogo: agent exited at offset %d]\n", ev.Offset)
+	}
+	return err
+}
@@ -208,11 +208,18 @@ func formatQueueFiltered(queue []refinery.MergeRequest, filter repoFilter, now t
 			continue
 		}
 		// A long-queued row must read as "waiting behind N in its own repo"
-		// rather than as "ignored".
-		line += "  (" + aheadNote(aheadInLane[mr.ID]) + " in this repo)"
+		// rather than as "ignored". A stacked row waits on one request in
+		// particular, and says which — its place in line is not what holds it.
+		if dep := findQueued(queue, mr.DependsOn); dep != nil {
+			line += fmt.Sprintf("  (waiting for %s to merge — stacked on it)", dep.ID)
+		} else {
+			line += "  (" + aheadNote(aheadInLane[mr.ID]) + " in this repo)"
+		}
 		fmt.Fprintln(&b, line)
 	}
 
+	fmt.Fprint(&b, formatStacks(shown))
+
 	if n := hiddenNote(filter, dropped, queue); n != "" {
 		fmt.Fprint(&b, "\n"+n)
 	}
@@ -230,6 +237,68 @@ func formatQueueFiltered(queue []refinery.MergeRequest, filter repoFilter, now t
 	return b.String()
 }
 
+// findQueued returns the pipeline row with the given ID, or nil — including
+// for an empty ID.
+func findQueued(queue []refinery.MergeRequest, id string) *refinery.MergeRequest {
+	if id == "" {
+		return nil
+	}
+	for i := range queue {
+		if queue[i].ID == id {
+			return &queue[i]
+		}
+	}
+	return nil
+}
+
+// formatStacks renders the dependency chains among the shown rows — branches
+// submitted with --after — as trees rooted at the bottom of each stack, so the
+// order they will merge in is read off the page rather than reconstructed from
+// IDs. Empty when nothing shown is stacked.
+func formatStacks(shown []refinery.MergeRequest) string {
+	children := make(map[string][]*refinery.MergeRequest)
+	stacked := false
+	for i := range shown {
+		if dep := shown[i].DependsOn; dep != "" {
+			children[dep] = append(children[dep], &shown[i])
+			stacked = true
+		}
+	}
+	if !stacked {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "\nStacked branches (each merges only after the one above it):\n")
+	var walk func(mr *refinery.MergeRequest, depth int)
+	walk = func(mr *refinery.MergeRequest, depth int) {
+		prefix := "  "
+		if depth > 0 {
+			prefix = strings.Repeat("  ", depth) + "└ "
+		}
+		line := fmt.Sprintf("%s%s  %s  %s", prefix, mr.ID, mr.Branch, mr.StatusLabel())
+		if depth == 0 && mr.DependsOn != "" {
+			// What it was stacked on has left the pipeline. Had that one
+			// failed, this one would have been cancelled with it.
+			line += fmt.Sprintf("  (after %s, already merged)", mr.DependsOn)
+		}
+		fmt.Fprintln(&b, line)
+		for _, c := range children[mr.ID] {
+			walk(c, depth+1)
+		}
+	}
+	// Roots in pipeline order, so the output is stable across polls.
+	for i := range shown {
+		mr := &shown[i]
+		if findQueued(shown, mr.DependsOn) != nil {
+			continue // printed under the request it is stacked on
+		}
+		if mr.DependsOn != "" || len(children[mr.ID]) > 0 {
+			walk(mr, 0)
+		}
+	}
+	return b.String()
+}
+
 // formatQueuePosition explains why a queued merge request has not moved, by
 // naming what is in front of it. Fetching the pipeline is a second round trip,
 // so a failure here degrades to a stated "could not read" rather than to
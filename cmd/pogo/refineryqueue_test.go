@@ -367,3 +367,32 @@ func TestQueueListsEveryRunningMergeWithItsRepo(t *testing.T) {
 		t.Errorf("two merges are running; the view must not report an idle refinery:\n%s", out)
 	}
 }
+
+// TestQueueShowsStackedBranches: a request submitted with --after must say it
+// is waiting on its dependency, not on the merge ahead of it, and the stack
+// must be drawn in the order it will merge.
+func TestQueueShowsStackedBranches(t *testing.T) {
+	now := time.Now()
+	queue := []refinery.MergeRequest{
+		{ID: "mr-a", Branch: "split-1", Author: "mg-aaaa", Status: refinery.StatusProcessing,
+			SubmitTime: now.Add(-5 * time.Minute), StartTime: now.Add(-time.Minute)},
+		{ID: "mr-b", Branch: "split-2", Author: "mg-aaaa", Status: refinery.StatusQueued,
+			SubmitTime: now.Add(-4 * time.Minute), DependsOn: "mr-a"},
+		{ID: "mr-c", Branch: "split-3", Author: "mg-aaaa", Status: refinery.StatusQueued,
+			SubmitTime: now.Add(-3 * time.Minute), DependsOn: "mr-b"},
+	}
+	out := formatQueue(queue, now)
+	t.Logf("QUEUE:\n%s", out)
+
+	if !strings.Contains(out, "waiting for mr-a to merge") {
+		t.Errorf("the dependent must name what it waits for, got:\n%s", out)
+	}
+	if !strings.Contains(out, "Stacked branches") {
+		t.Fatalf("the stack must be drawn, got:\n%s", out)
+	}
+	stack := out[strings.Index(out, "Stacked branches"):]
+	a, b, c := strings.Index(stack, "mr-a"), strings.Index(stack, "└ mr-b"), strings.Index(stack, "└ mr-c")
+	if a < 0 || b < a || c < b {
+		t.Errorf("stack must read mr-a, then mr-b, then mr-c beneath it, got:\n%s", stack)
+	}
+}
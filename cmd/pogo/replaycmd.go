@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/drellem2/pogo/internal/agent"
+	"github.com/drellem2/pogo/internal/cli"
+	"github.com/drellem2/pogo/internal/recording"
+)
+
+// newAgentReplayCmd builds `pogo agent replay`.
+//
+// It reads the recordings from disk rather than asking pogod, on purpose: the
+// sessions worth replaying are a crashed polecat's, long since out of the
+// registry, and the occasions for a post-mortem include the ones where pogod
+// itself is what went down.
+func newAgentReplayCmd(jsonOutput *bool) *cobra.Command {
+	var session string
+	var speed float64
+	var maxWait time.Duration
+	var dump, list bool
+	cmd := &cobra.Command{
+		Use:   "replay <name>",
+		Short: "Play back a recorded agent session",
+		Long: `Play back an agent's PTY session from its recording.
+
+With [recording] enabled = true, pogod keeps every agent's PTY stream as
+asciicast v2 under $POGO_HOME/agents/<name>/sessions, one file per session,
+named for the moment it began. A session is one run of the agent's process —
+a spawn, a respawn or an adoption after a pogod restart — and a long one
+continues in a new file each time it passes [recording] rotate_bytes.
+
+By default the latest session is played to this terminal at the pace it was
+recorded. --speed 4 plays it four times as fast; a pause longer than
+--max-wait is cut short, so an hour the agent sat idle does not play as an
+hour. Ctrl-C stops playback. The recording was made at the agent's PTY size;
+a smaller terminal will show it wrapped.
+
+--session picks another session by its name, or by any prefix of the name
+that matches exactly one (20261017T14 for the one that began in that hour).
+--list shows every session kept for the agent. --dump prints the session's
+whole output at once with escape sequences stripped, for grep and less.
+
+The files are plain asciicast v2: asciinema play, or any player that reads
+the format, plays them too.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			dir := recording.Dir(args[0])
+			if list {
+				sessions, err := recording.List(dir)
+				if err != nil {
+					cli.ExitWithError(*jsonOutput, err.Error(), cli.ExitError)
+				}
+				if *jsonOutput {
+					if sessions == nil {
+						sessions = []recording.Session{}
+					}
+					cli.PrintJSON(sessions)
+					return
+				}
+				if len(sessions) == 0 {
+					fmt.Printf("No recordings for %s in %s.\n", args[0], dir)
+					return
+				}
+				printSessions(os.Stdout, sessions)
+				return
+			}
+			if *jsonOutput && !dump {
+				cli.ExitWithError(*jsonOutput, "--json needs --list or --dump", cli.ExitError)
+			}
+
+			s, err := recording.Find(dir, session)
+			if err != nil {
+				cli.ExitWithError(*jsonOutput, err.Error(), cli.ExitError)
+			}
+			f, err := os.Open(s.Path)
+			if err != nil {
+				cli.ExitWithError(*jsonOutput, err.Error(), cli.ExitError)
+			}
+			defer f.Close()
+			d, err := recording.NewDecoder(f)
+			if err != nil {
+				cli.ExitWithError(*jsonOutput, fmt.Sprintf("%s: %v", s.Path, err), cli.ExitError)
+			}
+
+			if dump {
+				out, err := recording.Output(d)
+				if err != nil {
+					cli.ExitWithError(*jsonOutput, fmt.Sprintf("%s: %v", s.Path, err), cli.ExitError)
+				}
+				text := string(agent.StripANSI(out))
+				if *jsonOutput {
+					cli.PrintJSON(map[string]any{"session": s, "output": text})
+				} else {
+					fmt.Print(text)
+				}
+				return
+			}
+
+			if speed <= 0 {
+				cli.ExitWithError(*jsonOutput, fmt.Sprintf("--speed must be positive, got %g", speed), cli.ExitError)
+			}
+			if cols, rows, err := term.GetSize(int(os.Stdout.Fd())); err == nil && (cols < d.Header.Width || rows < d.Header.Height) {
+				fmt.Fprintf(os.Stderr, "Recorded at %dx%d; this terminal is %dx%d.\n", d.Header.Width, d.Header.Height, cols, rows)
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			if err := recording.Play(ctx, os.Stdout, d, speed, maxWait); err != nil && ctx.Err() == nil {
+				cli.ExitWithError(*jsonOutput, fmt.Sprintf("%s: %v", s.Path, err), cli.ExitError)
+			}
+		},
+	}
+	cmd.Flags().StringVar(&session, "session", "", "Session to play, by name or unique prefix (default: the latest)")
+	cmd.Flags().Float64Var(&speed, "speed", 1, "Playback speed multiplier")
+	cmd.Flags().DurationVar(&maxWait, "max-wait", 2*time.Second, "Cut any pause longer than this (0 keeps every pause)")
+	cmd.Flags().BoolVar(&dump, "dump", false, "Print the session's output at once, escape sequences stripped")
+	cmd.Flags().BoolVar(&list, "list", false, "List the agent's recorded sessions")
+	cmd.MarkFlagsMutuallyExclusive("dump", "list")
+	return cmd
+}
+
+// printSessions writes one line per session, oldest first.
+func printSessions(w io.Writer, sessions []recording.Session) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SESSION\tSTARTED\tLAST WRITTEN\tSIZE")
+	for _, s := range sessions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.ID,
+			s.Start.UTC().Format("2006-01-02 15:04:05Z"),
+			s.Modified.UTC().Format("2006-01-02 15:04:05Z"),
+			humanBytes(s.Size))
+	}
+	tw.Flush()
+}
+
+// humanBytes renders n as B, KB or MB, one decimal above bytes.
+func humanBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fKB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", n)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/drellem2/pogo/internal/recording"
+)
+
+// TestAgentReplayListsAndDumps reads a recording straight off disk — no pogod
+// — the way a post-mortem on a reaped polecat has to.
+func TestAgentReplayListsAndDumps(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("POGO_HOME", home)
+	rec, err := recording.Start(recording.Dir("polecat-x"), 80, 24, "polecat-x", recording.Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Write([]byte("\x1b[1;32mbuild ok\x1b[0m\r\n"))
+	rec.Close()
+	id := strings.TrimSuffix(filepath.Base(rec.Path()), recording.Ext)
+
+	run := func(args ...string) string {
+		json := false
+		cmd := newAgentReplayCmd(&json)
+		cmd.SetArgs(args)
+		return captureStdout(t, func() {
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("replay %v: %v", args, err)
+			}
+		})
+	}
+	if out := run("polecat-x", "--list"); !strings.Contains(out, id) {
+		t.Errorf("--list = %q, want session %s", out, id)
+	}
+	if out := run("polecat-x", "--dump", "--session", id[:8]); out != "build ok\r\n" {
+		t.Errorf("--dump = %q", out)
+	}
+	if out := run("nobody", "--list"); !strings.Contains(out, "No recordings for nobody") {
+		t.Errorf("--list of an agent with none = %q", out)
+	}
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+
+	"github.com/drellem2/pogo/internal/agent"
+	"github.com/drellem2/pogo/internal/cli"
+	"github.com/drellem2/pogo/internal/client"
+	"github.com/drellem2/pogo/internal/refinery"
+)
+
+// topEventWindow is how far back the event pane looks.
+const topEventWindow = "1h"
+
+// topOutputLines is how much of an agent's output `o` hands the pager.
+const topOutputLines = 2000
+
+// newTopCmd builds `pogo top` (user-025): the fleet on one live screen.
+//
+// It is a client of the same endpoints as `pogo agent list`, `pogo refinery
+// status`, `pogo events` and `pogo hostload`, fetched on a ticker in the
+// background so a slow pogod stalls the numbers, never the keys. The keys
+// reach for what an operator watching the screen does next — attach, read
+// the output, nudge, park or wake, cancel a merge — through the same client
+// calls those commands make.
+func newTopCmd(jsonOutput *bool) *cobra.Command {
+	var interval time.Duration
+	cmd := &cobra.Command{
+		Use:   "top",
+		Short: "Watch the fleet live: agents, refinery lanes and events",
+		Long: `Show the fleet on one full-screen view that refreshes on its own:
+
+  AGENTS    every agent with its state and how long it has been in it, its
+            share of the host's CPU (100 = one core, as top counts it), its
+            model, work item, restart count and last activity
+  REFINERY  the merges in flight, lane by lane, and what is queued behind them
+  EVENTS    the tail of the event log for the last hour
+
+The header line is the host sample pogod's dispatch gate reads: the fleet's
+cores, everything else's, the load average, and whether dispatch is held.
+
+Keys:
+  ↑/↓ j/k   select          Tab   switch between agents and refinery
+  a, Enter  attach to the agent (detach returns here)
+  o         page the agent's recent output ($PAGER, default less)
+  n         nudge the agent with a line you type
+  p / w     park / wake the agent (park asks first)
+  c         cancel the selected merge (asks first)
+  r         refresh now     q     quit
+
+With --json, prints one snapshot of the same data and exits.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if *jsonOutput {
+				snap := fetchTopSnapshot()
+				load, loadErr := client.GetHostLoad("")
+				cli.PrintJSON(topJSON(snap, load, loadErr))
+				return nil
+			}
+			if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+				cli.ExitWithError(false, "pogo top needs a terminal; use --json for a snapshot", cli.ExitError)
+			}
+			if interval < 500*time.Millisecond {
+				interval = 500 * time.Millisecond
+			}
+			return runTop(interval)
+		},
+	}
+	cmd.Flags().DurationVar(&interval, "interval", 2*time.Second, "how often to refresh")
+	return cmd
+}
+
+// topJSON is the --json shape: each source beside the error that replaced
+// it, so one unreachable endpoint does not hide the others.
+func topJSON(s topSnapshot, load *agent.HostLoadResponse, loadErr error) map[string]any {
+	errString := func(err error) string {
+		if err == nil {
+			return ""
+		}
+		return err.Error()
+	}
+	return map[string]any{
+		"at":             s.At.UTC(),
+		"mode":           s.Mode,
+		"agents":         s.Agents,
+		"agents_error":   errString(s.AgentsErr),
+		"refinery":       s.Refinery,
+		"queue":          s.Queue,
+		"refinery_error": errString(s.RefineryErr),
+		"events":         s.Events,
+		"events_error":   errString(s.EventsErr),
+		"hostload":       load,
+		"hostload_error": errString(loadErr),
+	}
+}
+
+// fetchTopSnapshot reads everything but the host sample. Each source fails
+// on its own: the pane shows the error and the rest of the screen stays live.
+func fetchTopSnapshot() topSnapshot {
+	s := topSnapshot{At: time.Now()}
+	s.Mode, _ = client.GetServerMode()
+	s.Agents, s.AgentsErr = client.ListAgents()
+	s.Refinery, s.RefineryErr = client.GetRefineryStatus()
+	if s.RefineryErr == nil {
+		queue, err := client.GetRefineryQueue()
+		if err != nil {
+			s.RefineryErr = err
+		}
+		for _, mr := range queue {
+			if mr.Status != refinery.StatusProcessing {
+				s.Queue = append(s.Queue, mr)
+			}
+		}
+	}
+	s.Events, s.EventsErr = client.RecentEvents(url.Values{"since": {topEventWindow}, "limit": {"200"}})
+	return s
+}
+
+type topLoad struct {
+	load *agent.HostLoadResponse
+	err  error
+}
+
+// topTerm is the terminal `pogo top` draws on: raw, on the alternate screen,
+// cursor hidden. suspend hands it back as it was for an attach or a pager.
+type topTerm struct {
+	in, out int
+	saved   *term.State
+}
+
+func (t *topTerm) enter() error {
+	st, err := term.MakeRaw(t.in)
+	if err != nil {
+		return err
+	}
+	t.saved = st
+	fmt.Print("\x1b[?1049h\x1b[?25l\x1b[2J")
+	return nil
+}
+
+func (t *topTerm) suspend() {
+	fmt.Print("\x1b[?25h\x1b[?1049l")
+	if t.saved != nil {
+		term.Restore(t.in, t.saved)
+		t.saved = nil
+	}
+}
+
+func (t *topTerm) size() (int, int) {
+	w, h, err := term.GetSize(t.out)
+	if err != nil || w <= 0 || h <= 0 {
+		return 80, 24
+	}
+	return w, h
+}
+
+func (t *topTerm) draw(lines []string) {
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, l := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(l)
+		b.WriteString("\x1b[K")
+	}
+	b.WriteString("\x1b[J")
+	os.Stdout.WriteString(b.String())
+}
+
+// readKeys waits up to wait for input and returns the keys in it. Polling
+// rather than a reader goroutine leaves nothing reading stdin while an
+// attach or a pager owns the terminal.
+func (t *topTerm) readKeys(wait time.Duration) []string {
+	fds := []unix.PollFd{{Fd: int32(t.in), Events: unix.POLLIN}}
+	n, err := unix.Poll(fds, int(wait/time.Millisecond))
+	if err != nil || n == 0 {
+		return nil
+	}
+	buf := make([]byte, 256)
+	m, err := os.Stdin.Read(buf)
+	if err != nil || m == 0 {
+		return nil
+	}
+	return parseTopKeys(buf[:m])
+}
+
+func runTop(interval time.Duration) error {
+	t := &topTerm{in: int(os.Stdin.Fd()), out: int(os.Stdout.Fd())}
+	if err := t.enter(); err != nil {
+		return err
+	}
+	defer t.suspend()
+
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(winch)
+	defer signal.Stop(stop)
+
+	snaps := make(chan topSnapshot, 1)
+	loads := make(chan topLoad, 1)
+	refresh := make(chan struct{}, 1)
+	results := make(chan topResult, 4)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+		for {
+			select {
+			case snaps <- fetchTopSnapshot():
+			case <-done:
+				return
+			}
+			select {
+			case <-tick.C:
+			case <-refresh:
+			case <-done:
+				return
+			}
+		}
+	}()
+	// The host sample measures over a second of its own, so it is fetched
+	// apart from the rest rather than holding every refresh to its pace.
+	go func() {
+		for {
+			load, err := client.GetHostLoad("")
+			select {
+			case loads <- topLoad{load, err}:
+			case <-done:
+				return
+			}
+			select {
+			case <-time.After(interval):
+			case <-done:
+				return
+			}
+		}
+	}()
+	requestRefresh := func() {
+		select {
+		case refresh <- struct{}{}:
+		default:
+		}
+	}
+
+	v := &topView{
+		nudge: func(name, msg string) (string, error) {
+			if err := client.NudgeAgent(name, msg, nil); err != nil {
+				return "", err
+			}
+			return "nudged " + name, nil
+		},
+		park: func(name string) (string, error) {
+			r, err := client.ParkAgent(name)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("parked %s (%d schedules paused)", r.Agent, r.SchedulesPaused), nil
+		},
+		wake: func(name string) (string, error) {
+			r, err := client.WakeAgent(name)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("woke %s (pid %d)", r.Agent, r.PID), nil
+		},
+		cancel: func(id string) (string, error) {
+			r, err := client.CancelMerge(id)
+			if err != nil {
+				return "", err
+			}
+			msg := fmt.Sprintf("%s: %s", r.ID, r.Status)
+			if r.Note != "" {
+				msg += " — " + r.Note
+			}
+			return msg, nil
+		},
+	}
+	dirty := true
+	for {
+		select {
+		case s := <-snaps:
+			v.setSnapshot(s)
+			dirty = true
+		case l := <-loads:
+			v.load, v.loadErr = l.load, l.err
+			dirty = true
+		case r := <-results:
+			if r.err != nil {
+				v.say(r.err.Error(), true)
+			} else {
+				v.say(r.msg, false)
+			}
+			requestRefresh()
+			dirty = true
+		case <-winch:
+			dirty = true
+		case <-stop:
+			return nil
+		default:
+		}
+		if dirty {
+			w, h := t.size()
+			t.draw(v.render(w, h, time.Now()))
+			dirty = false
+		}
+		for _, k := range t.readKeys(100 * time.Millisecond) {
+			dirty = true
+			act := v.key(k)
+			switch act.kind {
+			case topQuit:
+				return nil
+			case topRefresh:
+				v.say("refreshing…", false)
+				requestRefresh()
+			case topRun:
+				v.say("working…", false)
+				go func(run func() (string, error)) {
+					msg, err := run()
+					results <- topResult{msg, err}
+				}(act.run)
+			case topAttach, topOutput:
+				err := t.handOver(act)
+				if err != nil {
+					v.say(err.Error(), true)
+				} else {
+					v.say("", false)
+				}
+				requestRefresh()
+			}
+		}
+	}
+}
+
+type topResult struct {
+	msg string
+	err error
+}
+
+// handOver gives the terminal to an attach or a pager and takes it back
+// when that exits. The attach is `pogo agent attach` run as a child, so it
+// is the attach a human would get typing it, header and detach key included.
+func (t *topTerm) handOver(act topAction) error {
+	var c *exec.Cmd
+	switch act.kind {
+	case topAttach:
+		self, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		c = exec.Command(self, "agent", "attach", act.agent)
+	case topOutput:
+		out, err := client.GetAgentOutput(act.agent, client.AgentOutputOptions{Plain: true, Lines: topOutputLines})
+		if err != nil {
+			return err
+		}
+		pager := os.Getenv("PAGER")
+		if pager == "" {
+			pager = "less -R +G"
+		}
+		c = exec.Command("sh", "-c", pager)
+		c.Stdin = strings.NewReader(out)
+	}
+	if c.Stdin == nil {
+		c.Stdin = os.Stdin
+	}
+	c.Stdout, c.Stderr = os.Stdout, os.Stderr
+
+	t.suspend()
+	runErr := c.Run()
+	if err := t.enter(); err != nil {
+		return err
+	}
+	if runErr != nil && act.kind == topAttach {
+		return fmt.Errorf("attach to %s ended: %v", act.agent, runErr)
+	}
+	return runErr
+}
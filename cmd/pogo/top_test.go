@@ -0,0 +1,161 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/drellem2/pogo/internal/agent"
+	"github.com/drellem2/pogo/internal/hostload"
+	"github.com/drellem2/pogo/internal/refinery"
+)
+
+var topNow = time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+func topFixture() *topView {
+	v := &topView{}
+	v.setSnapshot(topSnapshot{
+		At:   topNow,
+		Mode: "normal",
+		Agents: []agent.AgentInfo{
+			{Name: "cat-b", Type: agent.TypePolecat, Status: agent.StatusRunning, WorkItemID: "mg-1",
+				State: &agent.StateInfo{Name: agent.StateStalled, SinceTS: topNow.Add(-5 * time.Minute)}},
+			{Name: "mayor", Type: agent.TypeCrew, Status: agent.StatusRunning, Model: "opus", RestartCount: 2,
+				State: &agent.StateInfo{Name: agent.StateHealthy, SinceTS: topNow.Add(-90 * time.Second)}},
+			{Name: "cat-a", Type: agent.TypePolecat, Status: agent.StatusExited},
+		},
+		Refinery: &refinery.Status{
+			MaxConcurrentMerges: 2, ProcessingCount: 1, QueueLen: 1,
+			InFlight: []refinery.LaneStatus{{ID: "mr-7", Repo: "/src/pogo", Branch: "polecat-b", Author: "cat-b", Since: topNow.Add(-time.Minute)}},
+		},
+		Queue: []refinery.MergeRequest{{ID: "mr-8", RepoPath: "/src/pogo", Branch: "polecat-c", Author: "cat-c", Status: refinery.StatusQueued}},
+	})
+	v.load = &agent.HostLoadResponse{
+		Measured:   true,
+		Sample:     hostload.Sample{Cores: 8, FleetCores: 1.5, ExternalCores: 0.25, LoadAvg1: 2},
+		AgentCores: map[string]float64{"mayor": 1.25},
+	}
+	return v
+}
+
+// TestTopRendersTheFleetInTheScreen. Every line fits the terminal, the count
+// of lines is the terminal's height, and the columns an operator scans for —
+// state, time in it, CPU share, restarts, lanes — are on it.
+func TestTopRendersTheFleetInTheScreen(t *testing.T) {
+	v := topFixture()
+	lines := v.render(100, 30, topNow)
+	if len(lines) != 30 {
+		t.Fatalf("rendered %d lines, want 30", len(lines))
+	}
+	for i, l := range lines {
+		if w := utf8.RuneCountInString(stripANSIText(l)); w > 100 {
+			t.Errorf("line %d is %d wide, want <= 100: %q", i, w, l)
+		}
+	}
+	if !strings.Contains(lines[29], "q quit") {
+		t.Errorf("last line is %q, want the key help", lines[29])
+	}
+	screen := stripANSIText(strings.Join(lines, "\n"))
+	for _, want := range []string{
+		"fleet 1.5 / 8 cores",
+		"AGENTS  3, 2 running",
+		"healthy       1m    125", // mayor: time in state, and 1.25 cores as top counts them
+		"stalled",
+		"1 of 2 lanes busy, 1 queued",
+		"mr-7       merging",
+		"mr-8       queued",
+		"None in the last 1h.",
+	} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("screen is missing %q:\n%s", want, screen)
+		}
+	}
+	// Crew sorts first, as in `pogo agent list`.
+	if m, c := strings.Index(screen, "mayor"), strings.Index(screen, "cat-a"); m > c {
+		t.Errorf("mayor rendered after cat-a:\n%s", screen)
+	}
+}
+
+// TestTopKeepsTheSelectionOnTheSameAgent. The rows reorder as agents come and
+// go; a park aimed at the row under the cursor must still land on the agent
+// the operator chose.
+func TestTopKeepsTheSelectionOnTheSameAgent(t *testing.T) {
+	v := topFixture()
+	v.key("down") // cat-a
+	if got := v.selectedAgent(); got != "cat-a" {
+		t.Fatalf("selected %q after down, want cat-a", got)
+	}
+	s := v.snap
+	s.Agents = append([]agent.AgentInfo{{Name: "aaa", Type: agent.TypePolecat}}, s.Agents...)
+	v.setSnapshot(s)
+	if got := v.selectedAgent(); got != "cat-a" {
+		t.Errorf("selected %q after a refresh, want cat-a still", got)
+	}
+}
+
+// TestTopAsksBeforeParkingOrCancelling. Park and cancel stop something that
+// is working, so each asks first and anything but y leaves it running.
+func TestTopAsksBeforeParkingOrCancelling(t *testing.T) {
+	v := topFixture()
+	var parked, cancelled []string
+	v.park = func(name string) (string, error) { parked = append(parked, name); return "ok", nil }
+	v.cancel = func(id string) (string, error) { cancelled = append(cancelled, id); return "ok", nil }
+
+	if act := v.key("p"); act.kind != topNone || v.prompt == nil {
+		t.Fatalf("p did not ask first: %+v", act)
+	}
+	if act := v.key("n"); act.kind != topNone || v.prompt != nil {
+		t.Fatalf("n at the park prompt did not decline: %+v", act)
+	}
+	v.key("p")
+	act := v.key("y")
+	if act.kind != topRun {
+		t.Fatalf("y at the park prompt = %+v, want a run", act)
+	}
+	act.run()
+	if !reflect.DeepEqual(parked, []string{"mayor"}) {
+		t.Errorf("parked %v, want [mayor]", parked)
+	}
+
+	// c on the agents pane is refused: there is no merge under the cursor.
+	if v.key("c"); v.prompt != nil || !v.messageErr {
+		t.Errorf("c on the agents pane asked anyway")
+	}
+	v.key("tab")
+	v.key("j") // mr-8
+	v.key("c")
+	v.key("y").run()
+	if !reflect.DeepEqual(cancelled, []string{"mr-8"}) {
+		t.Errorf("cancelled %v, want [mr-8]", cancelled)
+	}
+}
+
+// TestTopNudgeTakesATypedLine. The nudge prompt collects the keys as text —
+// including the ones that are commands outside it.
+func TestTopNudgeTakesATypedLine(t *testing.T) {
+	v := topFixture()
+	var got string
+	v.nudge = func(name, msg string) (string, error) { got = name + ": " + msg; return "ok", nil }
+	v.key("n")
+	for _, k := range parseTopKeys([]byte("check mq\x7fail")) {
+		v.key(k)
+	}
+	act := v.key("enter")
+	if act.kind != topRun {
+		t.Fatalf("enter = %+v, want a run", act)
+	}
+	act.run()
+	if want := "mayor: check mail"; got != want {
+		t.Errorf("nudged %q, want %q", got, want)
+	}
+}
+
+func TestParseTopKeys(t *testing.T) {
+	got := parseTopKeys([]byte("j\x1b[A\x1b[B\x1b[1;5C\t\r\x03\x1b"))
+	want := []string{"j", "up", "down", "tab", "enter", "ctrl-c", "esc"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseTopKeys = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,527 @@
+package main
+
+// The model and the drawing of `pogo top` (user-025). Everything here is pure
+// — a snapshot and a key in, lines and an action out — so the screen and the
+// keys can be tested without a terminal or a pogod. top.go owns the terminal,
+// the fetching and the actions that reach pogod.
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/drellem2/pogo/internal/agent"
+	"github.com/drellem2/pogo/internal/events"
+	"github.com/drellem2/pogo/internal/refinery"
+)
+
+// topSnapshot is one fetch of everything `pogo top` shows but the host
+// sample, which takes a second to measure and so arrives on its own.
+type topSnapshot struct {
+	At          time.Time
+	Mode        string
+	Agents      []agent.AgentInfo
+	AgentsErr   error
+	Refinery    *refinery.Status
+	Queue       []refinery.MergeRequest // waiting only; the in-flight are in Refinery.InFlight
+	RefineryErr error
+	Events      []events.Event
+	EventsErr   error
+}
+
+// topMerge is one row of the refinery pane: an in-flight lane or a queued
+// request, which are the two things a cancel can be aimed at.
+type topMerge struct {
+	ID, Repo, Branch, Author string
+	State                    string // "merging", or the queued request's status
+	Since                    time.Time
+}
+
+func (s topSnapshot) merges() []topMerge {
+	var out []topMerge
+	if s.Refinery != nil {
+		for _, l := range s.Refinery.InFlight {
+			out = append(out, topMerge{ID: l.ID, Repo: l.Repo, Branch: l.Branch, Author: l.Author, State: "merging", Since: l.Since})
+		}
+	}
+	for _, mr := range s.Queue {
+		out = append(out, topMerge{ID: mr.ID, Repo: mr.RepoPath, Branch: mr.Branch, Author: mr.Author, State: string(mr.Status), Since: mr.SubmitTime})
+	}
+	return out
+}
+
+type topPane int
+
+const (
+	paneAgents topPane = iota
+	paneMerges
+)
+
+// topPrompt is a question on the bottom line: free text for a nudge, or
+// y/N before anything that stops or cancels something.
+type topPrompt struct {
+	label   string
+	confirm bool
+	text    []rune
+	submit  func(text string) topAction
+}
+
+// topAction is what a key asks top.go to do.
+type topAction struct {
+	kind  topActionKind
+	agent string
+	// run is the call to pogod for a topRun, and says what it did.
+	run func() (string, error)
+}
+
+type topActionKind int
+
+const (
+	topNone topActionKind = iota
+	topQuit
+	topRefresh
+	topAttach
+	topOutput
+	topRun
+)
+
+// topView is the screen's state: the data and where the cursor is.
+type topView struct {
+	snap    topSnapshot
+	load    *agent.HostLoadResponse
+	loadErr error
+
+	focus  topPane
+	sel    [2]int
+	scroll [2]int
+	prompt *topPrompt
+
+	message    string
+	messageErr bool
+
+	// Actions, as top.go wires them; tests substitute their own.
+	nudge  func(name, msg string) (string, error)
+	park   func(name string) (string, error)
+	wake   func(name string) (string, error)
+	cancel func(id string) (string, error)
+}
+
+// setSnapshot takes a new fetch, keeping the cursor on the same agent or
+// merge when it is still there.
+func (v *topView) setSnapshot(s topSnapshot) {
+	agentName, mergeID := v.selectedAgent(), v.selectedMerge()
+	sortTopAgents(s.Agents)
+	v.snap = s
+	v.sel[paneAgents] = indexOr(len(s.Agents), v.sel[paneAgents], func(i int) bool { return s.Agents[i].Name == agentName })
+	ms := s.merges()
+	v.sel[paneMerges] = indexOr(len(ms), v.sel[paneMerges], func(i int) bool { return ms[i].ID == mergeID })
+}
+
+// indexOr is the index matching want, else cur clamped to [0, n).
+func indexOr(n, cur int, want func(int) bool) int {
+	for i := 0; i < n; i++ {
+		if want(i) {
+			return i
+		}
+	}
+	return max(0, min(cur, n-1))
+}
+
+// sortTopAgents puts crew first, then by name, as `pogo agent list` does.
+func sortTopAgents(as []agent.AgentInfo) {
+	sort.SliceStable(as, func(i, j int) bool {
+		if as[i].Type != as[j].Type {
+			return as[i].Type == agent.TypeCrew
+		}
+		return as[i].Name < as[j].Name
+	})
+}
+
+func (v *topView) selectedAgent() string {
+	if i := v.sel[paneAgents]; i < len(v.snap.Agents) {
+		return v.snap.Agents[i].Name
+	}
+	return ""
+}
+
+func (v *topView) selectedMerge() string {
+	ms := v.snap.merges()
+	if i := v.sel[paneMerges]; i < len(ms) {
+		return ms[i].ID
+	}
+	return ""
+}
+
+func (v *topView) say(msg string, isErr bool) {
+	v.message, v.messageErr = msg, isErr
+}
+
+// key handles one key (see parseTopKeys) and returns what top.go should do.
+func (v *topView) key(k string) topAction {
+	if p := v.prompt; p != nil {
+		return v.promptKey(p, k)
+	}
+	switch k {
+	case "q", "ctrl-c":
+		return topAction{kind: topQuit}
+	case "r":
+		return topAction{kind: topRefresh}
+	case "tab":
+		v.focus = 1 - v.focus
+	case "down", "j":
+		v.move(1)
+	case "up", "k":
+		v.move(-1)
+	case "a", "enter", "o", "n", "p", "w":
+		return v.agentKey(k)
+	case "c":
+		id := v.selectedMerge()
+		if v.focus != paneMerges || id == "" {
+			v.say("c cancels the selected merge: Tab to the refinery pane first", true)
+			break
+		}
+		v.ask(fmt.Sprintf("Cancel %s? [y/N] ", id), true, func(string) topAction {
+			return topAction{kind: topRun, run: func() (string, error) { return v.cancel(id) }}
+		})
+	}
+	return topAction{}
+}
+
+func (v *topView) agentKey(k string) topAction {
+	name := v.selectedAgent()
+	if v.focus != paneAgents || name == "" {
+		v.say("that key acts on the selected agent: Tab to the agents pane first", true)
+		return topAction{}
+	}
+	switch k {
+	case "a", "enter":
+		return topAction{kind: topAttach, agent: name}
+	case "o":
+		return topAction{kind: topOutput, agent: name}
+	case "n":
+		v.ask("Nudge "+name+": ", false, func(msg string) topAction {
+			if strings.TrimSpace(msg) == "" {
+				return topAction{}
+			}
+			return topAction{kind: topRun, run: func() (string, error) { return v.nudge(name, msg) }}
+		})
+	case "p":
+		v.ask(fmt.Sprintf("Park %s? It stays down until woken. [y/N] ", name), true, func(string) topAction {
+			return topAction{kind: topRun, run: func() (string, error) { return v.park(name) }}
+		})
+	case "w":
+		return topAction{kind: topRun, run: func() (string, error) { return v.wake(name) }}
+	}
+	return topAction{}
+}
+
+func (v *topView) ask(label string, confirm bool, submit func(string) topAction) {
+	v.prompt = &topPrompt{label: label, confirm: confirm, submit: submit}
+	v.message = ""
+}
+
+func (v *topView) promptKey(p *topPrompt, k string) topAction {
+	if p.confirm {
+		v.prompt = nil
+		if k == "y" || k == "Y" {
+			return p.submit("")
+		}
+		v.say("not done", false)
+		return topAction{}
+	}
+	switch {
+	case k == "esc" || k == "ctrl-c":
+		v.prompt = nil
+		v.say("not sent", false)
+	case k == "enter":
+		v.prompt = nil
+		return p.submit(string(p.text))
+	case k == "backspace":
+		if len(p.text) > 0 {
+			p.text = p.text[:len(p.text)-1]
+		}
+	case k == "space":
+		p.text = append(p.text, ' ')
+	case len([]rune(k)) == 1:
+		p.text = append(p.text, []rune(k)...)
+	}
+	return topAction{}
+}
+
+func (v *topView) move(d int) {
+	n := len(v.snap.Agents)
+	if v.focus == paneMerges {
+		n = len(v.snap.merges())
+	}
+	v.sel[v.focus] = max(0, min(v.sel[v.focus]+d, n-1))
+}
+
+// parseTopKeys splits what one read of the terminal returned into keys:
+// names for the keys top uses that are not characters, and the characters
+// themselves. An escape sequence top does not know is dropped whole.
+func parseTopKeys(b []byte) []string {
+	var keys []string
+	s := string(b)
+	for len(s) > 0 {
+		switch {
+		case strings.HasPrefix(s, "\x1b[A"), strings.HasPrefix(s, "\x1bOA"):
+			keys, s = append(keys, "up"), s[3:]
+		case strings.HasPrefix(s, "\x1b[B"), strings.HasPrefix(s, "\x1bOB"):
+			keys, s = append(keys, "down"), s[3:]
+		case strings.HasPrefix(s, "\x1b["), strings.HasPrefix(s, "\x1bO"):
+			// Skip to the sequence's final byte.
+			i := 2
+			for i < len(s) && (s[i] < 0x40 || s[i] > 0x7e) {
+				i++
+			}
+			s = s[min(i+1, len(s)):]
+		case s[0] == 0x1b:
+			keys, s = append(keys, "esc"), s[1:]
+		case s[0] == '\r' || s[0] == '\n':
+			keys, s = append(keys, "enter"), s[1:]
+		case s[0] == '\t':
+			keys, s = append(keys, "tab"), s[1:]
+		case s[0] == 0x7f || s[0] == 0x08:
+			keys, s = append(keys, "backspace"), s[1:]
+		case s[0] == 0x03:
+			keys, s = append(keys, "ctrl-c"), s[1:]
+		case s[0] == ' ':
+			keys, s = append(keys, "space"), s[1:]
+		case s[0] < 0x20:
+			s = s[1:]
+		default:
+			r := []rune(s)[0]
+			keys, s = append(keys, string(r)), s[len(string(r)):]
+		}
+	}
+	return keys
+}
+
+// topHelp is the key legend on the last line.
+const topHelp = "↑↓ select  Tab pane  a attach  o output  n nudge  p park  w wake  c cancel MR  r refresh  q quit"
+
+// render draws the whole screen as exactly height lines, none wider than
+// width. The agents pane gets the room it needs up to half the screen, the
+// refinery pane up to a quarter, and the event tail what is left.
+func (v *topView) render(width, height int, now time.Time) []string {
+	var head []string
+	title := "pogo top"
+	if v.snap.Mode != "" {
+		title += " · " + v.snap.Mode
+	}
+	if !v.snap.At.IsZero() {
+		title += " · " + v.snap.At.UTC().Format("15:04:05Z")
+	}
+	head = append(head, bold(title), v.hostLine())
+
+	foot := []string{v.footLine(), dim(topHelp)}
+	body := height - len(head) - len(foot)
+
+	agentRows := len(v.snap.Agents)
+	if v.snap.AgentsErr != nil || agentRows == 0 {
+		agentRows = 1
+	}
+	merges := v.snap.merges()
+	mergeRows := len(merges)
+	if v.snap.RefineryErr != nil || mergeRows == 0 {
+		mergeRows = 1
+	}
+	// Each pane is a header, a column line and its rows.
+	agentH := min(agentRows, max(1, body/2-2)) + 2
+	mergeH := min(mergeRows, max(1, body/4-2)) + 2
+	eventH := max(0, body-agentH-mergeH)
+
+	panes := append(v.agentPane(agentH, now), v.mergePane(merges, mergeH, now)...)
+	panes = append(panes, v.eventPane(eventH)...)
+	// Pad or cut the panes to keep the footer on the last lines.
+	for len(panes) < body {
+		panes = append(panes, "")
+	}
+	panes = panes[:max(0, body)]
+	lines := append(append(head, panes...), foot...)
+	lines = lines[:max(0, height)]
+	for i, l := range lines {
+		lines[i] = truncateVisible(l, width-1)
+	}
+	return lines
+}
+
+func (v *topView) hostLine() string {
+	switch {
+	case v.loadErr != nil:
+		return "host: " + v.loadErr.Error()
+	case v.load == nil:
+		return "host: measuring…"
+	case !v.load.Measured:
+		return "host: not measured"
+	case !v.load.Resolved():
+		return "host: " + v.load.Unresolvable
+	}
+	s := v.load.Sample
+	line := fmt.Sprintf("host: fleet %.1f / %d cores · other %.1f · load %.1f", s.FleetCores, s.Cores, s.ExternalCores, s.LoadAvg1)
+	if v.load.WouldRefuseDispatch {
+		line += " · " + red("dispatch held")
+	}
+	return line
+}
+
+func (v *topView) footLine() string {
+	if p := v.prompt; p != nil {
+		return bold(p.label) + string(p.text) + "█"
+	}
+	if v.messageErr {
+		return red(v.message)
+	}
+	return v.message
+}
+
+// paneTitle marks the focused pane.
+func (v *topView) paneTitle(p topPane, title string) string {
+	if v.focus == p {
+		return bold("▸ " + title)
+	}
+	return "  " + title
+}
+
+// window returns the [from, to) of n rows that fits rows lines and keeps the
+// pane's selection in view.
+func (v *topView) window(p topPane, n, rows int) (int, int) {
+	off := v.scroll[p]
+	sel := v.sel[p]
+	if sel < off {
+		off = sel
+	}
+	if sel >= off+rows {
+		off = sel - rows + 1
+	}
+	off = max(0, min(off, n-rows))
+	v.scroll[p] = off
+	return off, min(n, off+rows)
+}
+
+func (v *topView) agentPane(h int, now time.Time) []string {
+	as := v.snap.Agents
+	running := 0
+	for _, a := range as {
+		if a.Status == agent.StatusRunning {
+			running++
+		}
+	}
+	lines := []string{
+		v.paneTitle(paneAgents, fmt.Sprintf("AGENTS  %d, %d running", len(as), running)),
+		dim(fmt.Sprintf("  %-20s %-7s %-9s %6s %6s %-16s %-10s %4s  %s",
+			"NAME", "TYPE", "STATE", "FOR", "CPU%", "MODEL", "WORK ITEM", "RST", "ACTIVITY")),
+	}
+	switch {
+	case v.snap.At.IsZero():
+		return append(lines, "  fetching…")
+	case v.snap.AgentsErr != nil:
+		return append(lines, "  (unavailable: "+v.snap.AgentsErr.Error()+")")
+	case len(as) == 0:
+		return append(lines, "  No agents running.")
+	}
+	from, to := v.window(paneAgents, len(as), h-2)
+	for i := from; i < to; i++ {
+		a := as[i]
+		state, since := string(a.Status), ""
+		if a.State != nil && a.Status == agent.StatusRunning {
+			state = string(a.State.Name)
+			since = shortDur(now.Sub(a.State.SinceTS))
+		}
+		if a.RateLimited {
+			state = "limited"
+		}
+		cpu := ""
+		if v.load != nil {
+			if c, ok := v.load.AgentCores[a.Name]; ok {
+				cpu = fmt.Sprintf("%.0f", c*100)
+			}
+		}
+		rst := ""
+		if a.RestartCount > 0 {
+			rst = fmt.Sprint(a.RestartCount)
+		}
+		row := fmt.Sprintf("  %-20s %-7s %-9s %6s %6s %-16s %-10s %4s  %s",
+			truncateRunes(a.Name, 19), a.Type, state, since, cpu,
+			truncateRunes(a.Model, 15), truncateRunes(a.WorkItemID, 9), rst, a.LastActivity)
+		switch {
+		case v.focus == paneAgents && i == v.sel[paneAgents]:
+			row = reverse(row)
+		case state == string(agent.StateStalled) || a.RateLimited:
+			row = red(row)
+		case a.Status != agent.StatusRunning:
+			row = dim(row)
+		}
+		lines = append(lines, row)
+	}
+	return lines
+}
+
+func (v *topView) mergePane(ms []topMerge, h int, now time.Time) []string {
+	title := "REFINERY"
+	if st := v.snap.Refinery; st != nil {
+		title += fmt.Sprintf("  %d of %d lanes busy, %d queued", st.ProcessingCount, st.MaxConcurrentMerges, st.QueueLen)
+	}
+	lines := []string{
+		v.paneTitle(paneMerges, title),
+		dim(fmt.Sprintf("  %-10s %-8s %6s  %-18s %-16s %s", "MR", "", "FOR", "REPO", "AUTHOR", "BRANCH")),
+	}
+	switch {
+	case v.snap.At.IsZero():
+		return append(lines, "  fetching…")
+	case v.snap.RefineryErr != nil:
+		return append(lines, "  (unavailable: "+v.snap.RefineryErr.Error()+")")
+	case len(ms) == 0:
+		return append(lines, "  Idle: nothing merging or queued.")
+	}
+	from, to := v.window(paneMerges, len(ms), h-2)
+	for i := from; i < to; i++ {
+		m := ms[i]
+		age := ""
+		if !m.Since.IsZero() {
+			age = shortDur(now.Sub(m.Since))
+		}
+		repo := m.Repo
+		if j := strings.LastIndex(repo, "/"); j >= 0 {
+			repo = repo[j+1:]
+		}
+		row := fmt.Sprintf("  %-10s %-8s %6s  %-18s %-16s %s",
+			m.ID, m.State, age, truncateRunes(repo, 17), truncateRunes(m.Author, 15), m.Branch)
+		if v.focus == paneMerges && i == v.sel[paneMerges] {
+			row = reverse(row)
+		}
+		lines = append(lines, row)
+	}
+	return lines
+}
+
+// eventPane is the tail of the event log: the newest at the bottom, as
+// `pogo events tail` scrolls.
+func (v *topView) eventPane(h int) []string {
+	if h <= 0 {
+		return nil
+	}
+	lines := []string{"  EVENTS"}
+	switch {
+	case v.snap.At.IsZero():
+		return append(lines, "  fetching…")
+	case v.snap.EventsErr != nil:
+		return append(lines, "  (unavailable: "+v.snap.EventsErr.Error()+")")
+	case len(v.snap.Events) == 0:
+		return append(lines, "  None in the last "+topEventWindow+".")
+	}
+	evs := v.snap.Events
+	if len(evs) > h-1 {
+		evs = evs[len(evs)-(h-1):]
+	}
+	for _, ev := range evs {
+		lines = append(lines, "  "+events.FormatPretty(ev))
+	}
+	return lines
+}
+
+func bold(s string) string    { return "\x1b[1m" + s + "\x1b[0m" }
+func dim(s string) string     { return "\x1b[2m" + s + "\x1b[0m" }
+func red(s string) string     { return "\x1b[31m" + s + "\x1b[0m" }
+func reverse(s string) string { return "\x1b[7m" + s + "\x1b[0m" }
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/drellem2/pogo/internal/cli"
+	"github.com/drellem2/pogo/internal/config"
+	"github.com/drellem2/pogo/internal/webui"
+)
+
+// newUICmd builds `pogo ui` (user-024): the dashboard's address, with the
+// human's API token in its fragment when there is one.
+//
+// It prints rather than opens: the browser that wants the page is as often on
+// a teammate's laptop as on this host, and a URL is what gets pasted there.
+func newUICmd(jsonOutput *bool) *cobra.Command {
+	var noToken bool
+	cmd := &cobra.Command{
+		Use:   "ui",
+		Short: "Print the URL of pogod's web dashboard",
+		Long: `Print the URL of the dashboard pogod serves at /ui/: agents with their
+health and time in state, the refinery queue and history, schedules and their
+ack counts, work items and recent events, and a terminal for any agent.
+
+When pogod has written an API token ($POGO_HOME/api-token), it is put in the
+URL's fragment (#token=...). Browsers do not send the fragment to the server;
+the page moves the token into the tab's session storage and out of the
+address bar, and sends it with every request. With [server] require_token
+the dashboard's data needs it; without, the token still lets the page's
+terminal take the keyboard as the human rather than as an unnamed caller.
+
+The token is the human's: anyone holding the URL can do what you can. Share
+the bare URL (--no-token) with a teammate and let them use their own.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			u := config.Load().ServerURL() + webui.Prefix
+			if !noToken {
+				if data, err := os.ReadFile(config.APITokenPath()); err == nil {
+					if token := strings.TrimSpace(string(data)); token != "" {
+						u += "#token=" + url.QueryEscape(token)
+					}
+				}
+			}
+			if *jsonOutput {
+				cli.PrintJSON(map[string]string{"url": u})
+				return nil
+			}
+			fmt.Println(u)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&noToken, "no-token", false, "print the URL without the API token")
+	return cmd
+}
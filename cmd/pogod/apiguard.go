@@ -0,0 +1,98 @@
+package main
+
+// pogod's API guard (user-021): the token issuer, the per-role policy, and the
+// unix socket the API is served on beside TCP. The mechanism is
+// internal/apiauth; this file is where pogod's config and registry meet it.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/drellem2/pogo/internal/agent"
+	"github.com/drellem2/pogo/internal/apiauth"
+	"github.com/drellem2/pogo/internal/config"
+	"github.com/drellem2/pogo/internal/events"
+	"github.com/drellem2/pogo/internal/platform/unixsock"
+	"github.com/drellem2/pogo/internal/webui"
+)
+
+// newAPIGuard loads (or makes) the token key, writes the human's token for the
+// CLI, gives reg the issuer for the agents it starts, and returns the guard
+// for pogod's mux. It returns nil when there is no key to sign with, and pogod
+// then serves its API unguarded, as it did before tokens.
+//
+// A policy that does not parse is not fatal: the shipped policy is used in its
+// place, loudly. Refusing to start would take the fleet down over a typo in
+// the one section meant to protect it.
+func newAPIGuard(cfg config.APIConfig, reg *agent.Registry) *apiauth.Guard {
+	iss, err := apiauth.LoadIssuer(config.APIKeyPath())
+	if err != nil {
+		log.Printf("WARNING: pogod: no API token key (%v); serving the API without tokens", err)
+		return nil
+	}
+	if err := iss.WriteHumanToken(config.APITokenPath()); err != nil {
+		log.Printf("WARNING: pogod: %v; the CLI will be refused on the socket until it can be written", err)
+	}
+	policy, err := apiauth.ParsePolicy(cfg.Policy)
+	if err != nil {
+		log.Printf("WARNING: pogod: [server.policy]: %v; using the shipped policy", err)
+		policy = apiauth.DefaultPolicy()
+	}
+	if reg != nil {
+		reg.SetAPITokens(iss)
+	}
+	return &apiauth.Guard{
+		Issuer:       iss,
+		Policy:       policy,
+		RequireToken: cfg.RequireToken,
+		Public:       []string{webui.Prefix},
+		OnDeny:       emitAPIDenied,
+	}
+}
+
+// emitAPIDenied puts a refused request on the event log. A refusal is an agent
+// trying something its role does not allow, or a caller with a bad token, and
+// either is worth a line someone can find.
+func emitAPIDenied(r *http.Request, id apiauth.Identity, status int, reason string) {
+	details := map[string]any{
+		"method": r.Method,
+		"path":   r.URL.Path,
+		"status": status,
+		"reason": reason,
+	}
+	if id.Role != "" {
+		details["caller"] = id.String()
+	}
+	events.Emit(context.Background(), events.Event{
+		EventType: "api_request_denied",
+		Agent:     "pogod",
+		Details:   details,
+	})
+}
+
+// listenAPISocket listens on the API socket at path, readable and writable by
+// this user only.
+//
+// A file already at path is removed first: the pogod singleton lock is held
+// by now, so a socket there belongs to a pogod that is gone. Anything that is
+// not a socket is left alone and is an error.
+func listenAPISocket(path string) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	// Bound and then chmod'ed, the socket would be open to anyone the umask
+	// let in until the chmod; unixsock gives it its mode first.
+	return unixsock.Listen(path, 0600)
+}
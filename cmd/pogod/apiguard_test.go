@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drellem2/pogo/internal/apiauth"
+	"github.com/drellem2/pogo/internal/config"
+	"github.com/drellem2/pogo/internal/scheduler"
+)
+
+// TestDefaultGuardRefusesATokenlessPark is the guard as pogod builds it from
+// the shipped config, with require_token off. A polecat that drops
+// $POGO_API_TOKEN and calls the TCP port must not get further than it would
+// with the token: reading and searching still work, parking the coordinator
+// does not.
+func TestDefaultGuardRefusesATokenlessPark(t *testing.T) {
+	t.Setenv("POGO_HOME", t.TempDir())
+	g := newAPIGuard(config.DefaultAPIConfig(), nil)
+	if g == nil {
+		t.Fatal("no guard from the default config")
+	}
+	g.OnDeny = nil
+	h := g.Wrap(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	for _, tc := range []struct {
+		method, path string
+		want         int
+	}{
+		{"POST", "/agents/x/park", http.StatusForbidden},
+		{"POST", "/server/mode", http.StatusForbidden},
+		{"GET", "/agents/x/terminal", http.StatusForbidden},
+		{"GET", "/projects", http.StatusOK},
+		{"POST", "/plugin", http.StatusOK},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+		if rec.Code != tc.want {
+			t.Errorf("tokenless %s %s over TCP: %d, want %d", tc.method, tc.path, rec.Code, tc.want)
+		}
+	}
+}
+
+// TestPolecatSchedulesOnlyForItself: the policy lets a polecat POST to
+// /scheduler/schedules to register and ack its mail-check. A schedule's
+// message is a nudge to the agent it names, so naming another agent is
+// refused, and the polecat's own schedule still goes through.
+func TestPolecatSchedulesOnlyForItself(t *testing.T) {
+	t.Setenv("POGO_HOME", t.TempDir())
+	g := newAPIGuard(config.DefaultAPIConfig(), nil)
+	if g == nil {
+		t.Fatal("no guard from the default config")
+	}
+	g.OnDeny = nil
+	s, err := scheduler.New(filepath.Join(t.TempDir(), "schedules.json"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Add(scheduler.Entry{Agent: "mayor", Cron: "*/10 * * * *", ID: "mail-check"}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	s.RegisterHandlers(mux)
+	h := g.Wrap(mux)
+	cat, err := g.Issuer.Issue(apiauth.RolePolecat, "cat-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	do := func(method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+cat)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := do("POST", "/scheduler/schedules", `{"agent":"mayor","one_shot":true,"in":"1m","message":"stop everything"}`); code != http.StatusForbidden {
+		t.Errorf("polecat scheduling a message to the mayor: %d, want 403", code)
+	}
+	if n := len(s.List("mayor")); n != 1 {
+		t.Errorf("the mayor has %d schedules after a refused add, want 1", n)
+	}
+	if code := do("POST", "/scheduler/schedules/mail-check/ack", `{"agent":"mayor","token":"x"}`); code != http.StatusForbidden {
+		t.Errorf("polecat acking the mayor's schedule: %d, want 403", code)
+	}
+	if code := do("POST", "/scheduler/schedules", `{"agent":"cat-a","cron":"*/10 * * * *","id":"mail-check-mg-1"}`); code != http.StatusCreated {
+		t.Errorf("polecat registering its own mail-check: %d, want 201", code)
+	}
+}
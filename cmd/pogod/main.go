@@ -30,6 +30,7 @@ import (
 	"github.com/drellem2/pogo/internal/absentwatch"
 	"github.com/drellem2/pogo/internal/ackwatch"
 	"github.com/drellem2/pogo/internal/agent"
+	"github.com/drellem2/pogo/internal/apiauth"
 	"github.com/drellem2/pogo/internal/apimount"
 	"github.com/drellem2/pogo/internal/claude"
 	"github.com/drellem2/pogo/internal/client"
@@ -47,6 +48,8 @@ import (
 	"github.com/drellem2/pogo/internal/health"
 	"github.com/drellem2/pogo/internal/heartbeat"
 	"github.com/drellem2/pogo/internal/pathenv"
+	"github.com/drellem2/pogo/internal/platform/cgroup"
+	"github.com/drellem2/pogo/internal/platform/sandbox"
 	"github.com/drellem2/pogo/internal/platform/sleep"
 	"github.com/drellem2/pogo/internal/progresswatch"
 	"github.com/drellem2/pogo/internal/project"
@@ -54,6 +57,7 @@ import (
 	"github.com/drellem2/pogo/internal/providers"
 	"github.com/drellem2/pogo/internal/reaper"
 	"github.com/drellem2/pogo/internal/reconcile"
+	"github.com/drellem2/pogo/internal/recording"
 	"github.com/drellem2/pogo/internal/refinery"
 	"github.com/drellem2/pogo/internal/reviewdecl"
 	"github.com/drellem2/pogo/internal/scheduler"
@@ -63,9 +67,11 @@ import (
 	"github.com/drellem2/pogo/internal/staleness"
 	"github.com/drellem2/pogo/internal/stallwatch"
 	"github.com/drellem2/pogo/internal/synthwatch"
+	"github.com/drellem2/pogo/internal/tracing"
 	"github.com/drellem2/pogo/internal/turnlog"
 	"github.com/drellem2/pogo/internal/turnwatch"
 	"github.com/drellem2/pogo/internal/version"
+	"github.com/drellem2/pogo/internal/webui"
 	"github.com/drellem2/pogo/internal/wedgewatch"
 	"github.com/drellem2/pogo/internal/workitem"
 
@@ -276,6 +282,15 @@ func (l registryLiveness) AgentState(scheduleAgent string) scheduler.AgentState
 	// whether we have any surviving evidence about what IS — a polecat's
 	// persisted (pid, start_time) outlives the pogod that recorded it, so a
 	// restarted pogod can still look at the process itself (mg-13a3).
+	//
+	// A PTY holder is the first such evidence (user-011): an agent running
+	// under one outlives pogod by design, and AdoptHeld puts it back in the
+	// registry at startup. One that is held but not registered — adoption
+	// failed, or has not happened yet — is alive as far as anything here can
+	// see, so it is UNKNOWN for the same reason a live witness is.
+	if l.reg != nil && l.reg.HeldAlive(scheduleAgent) {
+		return scheduler.AgentUnknown
+	}
 	switch v := agent.AgentWitness(scheduleAgent); v {
 	case agent.WitnessAlive:
 		// OUR process — matched on pid AND start time — is running. The
@@ -932,6 +947,11 @@ func registerHandlers() {
 	http.HandleFunc("/version", versionHandler)
 	http.HandleFunc("/status", status)
 	http.HandleFunc("/workitems", workitem.HandleWorkItems)
+	http.HandleFunc("/events", events.HandleEvents)
+	// The dashboard's assets, outside the orchestration guard: in index-only
+	// mode the page still loads and says why its agent sections are empty.
+	http.Handle(webui.Prefix, webui.Handler())
+	registerMetrics()
 
 	// Agent and refinery endpoints behind orchestration guard.
 	// When the server is in index-only mode, these return 503.
@@ -1408,6 +1428,17 @@ Flags:
 `)
 		flag.PrintDefaults()
 	}
+	// A PTY holder is this binary re-executed by the agent registry (user-011),
+	// not a daemon: it must not parse pogod's flags, take the lock or touch
+	// any state.
+	if len(os.Args) > 1 && os.Args[1] == agent.HolderArg {
+		os.Exit(agent.RunHolder(os.Stdin, os.Stdout))
+	}
+	// Nor is the sandbox wrapper (user-012): it confines itself and execs the
+	// agent or gate command it was handed.
+	if len(os.Args) > 1 && os.Args[1] == sandbox.Arg {
+		os.Exit(sandbox.Run(os.Args[2:]))
+	}
 	flag.Parse()
 
 	// Before anything else, and before any state is touched: this must answer
@@ -1643,6 +1674,10 @@ Flags:
 	// Apply index-scope limits from config (mg-d205).
 	search.SearchService.SetMaxFilesPerTree(cfg.MaxFilesPerTree)
 	project.SetIndexRoots(cfg.IndexRoots)
+	// Symbol extraction looks ctags up on PATH, so it follows the PATH repair
+	// above.
+	search.SearchService.SetSymbols(cfg.SymbolIndexing, cfg.CtagsPath)
+	search.SearchService.SetBranches(cfg.IndexBranches)
 
 	// Configure agent command templates and the harness providers.
 	agentRegistry.SetCommandConfig(&cfg.Agents)
@@ -1699,6 +1734,19 @@ Flags:
 			"nothing limits how many workers enter one repo")
 	}
 
+	// [dispatch] cgroup_root: hold each worker, and each refinery gate, to the
+	// worker budget with a cgroup of its own (user-013). Checked once here, so
+	// a host that cannot enforce runs with the budget advisory and says why
+	// rather than refusing every spawn.
+	if root := cfg.DispatchCap.CgroupRoot; root != "" {
+		if m, err := cgroup.Open(root); err != nil {
+			log.Printf("dispatch: [dispatch] cgroup_root: %v; the worker budget stays advisory", err)
+		} else {
+			agentRegistry.SetCgroups(m)
+			log.Printf("dispatch: workers run in cgroups under %s, held to %s", m.Root(), agentRegistry.WorkerBudget())
+		}
+	}
+
 	// The refinery half of that cap. The queue is reached through a THUNK, not
 	// captured by value: an orchestration restart replaces *mergeQueue
 	// (SetRefineryStarter, below), and a closure over the old pointer would
@@ -1811,11 +1859,55 @@ Flags:
 	//     modal and dismisses each via its menu keystroke. It survives
 	//     schedule-substrate failures by living inside pogod's per-agent PTY
 	//     goroutine — see mg-ef6b §7 / mg-5a3d §4.
+	//
+	// [providers.<id>] tables join the built-in set here (user-018). One
+	// that does not build is not registered, so an agent configured for it
+	// resolves like any unknown id: the fallback, and row A7's condition.
+	for _, err := range providers.Configure(cfg.Providers) {
+		log.Printf("WARNING: %v; provider not registered (see pogo doctor)", err)
+	}
 	for _, p := range providers.All() {
 		agentRegistry.RegisterProvider(p)
 	}
 	agentRegistry.SetDefaultProvider(cfg.Agents.Provider)
 
+	// [agents] sandbox and [agents.<type>] sandbox: the profile each spawn is
+	// confined to unless its prompt's frontmatter names one (user-012).
+	agentRegistry.SetSandboxConfig(&cfg.Agents)
+
+	// [agents] mode and [agents.<type>] mode: whether each spawn drives its
+	// harness's TUI or its structured event stream, unless its prompt's
+	// frontmatter says (user-019).
+	agentRegistry.SetModeConfig(&cfg.Agents)
+
+	// [server] require_token and [server.policy]: every agent gets a token
+	// scoped to its role, and the API holds each caller to it (user-021).
+	apiGuard := newAPIGuard(cfg.API, agentRegistry)
+
+	// [agents] pty_holder: start each agent under a holder process that owns
+	// its PTY, so a pogod restart no longer hangs the fleet up (user-011).
+	if cfg.Agents.PTYHolder {
+		if exe, err := os.Executable(); err != nil {
+			log.Printf("pogod: [agents] pty_holder is set but this binary cannot find itself (%v); agents will be pogod's own children", err)
+		} else {
+			agentRegistry.SetPTYHolder(exe)
+			log.Printf("pogod: agents run under PTY holders (%s %s)", exe, agent.HolderArg)
+		}
+	}
+
+	// [recording]: keep every agent's PTY stream as asciicast v2 under
+	// $POGO_HOME/agents/<name>/sessions (user-015). Set before AdoptHeld so an
+	// adopted agent records too.
+	if cfg.Recording.Enabled {
+		agentRegistry.SetRecording(recordingLimits(cfg.Recording))
+		log.Printf("pogod: recording agent sessions under %s (rotate at %d bytes, keep %d bytes and %s per agent)",
+			recording.Root(), cfg.Recording.RotateBytes, cfg.Recording.MaxBytes, cfg.Recording.MaxAge)
+	}
+
+	// [tracing]: record each work item's phases as spans (user-023). Before
+	// anything can dispatch, so the first dispatch is traced.
+	startTracing(cfg.Tracing)
+
 	// Install the wake-cycle policy's limit-episode query (mg-8184). This is the
 	// composition root doing the wiring on purpose: internal/agent ASKS
 	// internal/claude at the moment it is about to wake an agent, and
@@ -2034,13 +2126,19 @@ Flags:
 			// stranded work no pushed-commit guard can see, and without the id
 			// the notice cannot name the item that is now unsafe to dispatch at
 			// (mg-32e3). It was available here all along.
-			cleanupAgentWorktree(exitedAgent{
+			//
+			// The reap is the last span of the polecat's part in its work
+			// item's trace (user-023), under the dispatch that made it.
+			reap := tracing.Start(a.WorkItemID, "polecat.reap", a.TraceParent())
+			reap.SetAttr("agent", a.Name)
+			worktree := cleanupAgentWorktree(exitedAgent{
 				Name:        a.Name,
 				EventAgent:  a.EventAgent(),
 				WorkItemID:  a.WorkItemID,
 				SourceRepo:  a.SourceRepo,
 				WorktreeDir: a.WorktreeDir,
 			}, coordinator, client.SendMGMail)
+			reap.SetAttr("worktree", worktree.String())
 			a.Cleanup()
 			// Removes the spawn's expanded prompt file along with the registry
 			// entry — this is the branch on which the owner is not coming back,
@@ -2055,6 +2153,11 @@ Flags:
 					log.Printf("agent %s: reaped %d stale mail-check schedule(s)", a.Name, n)
 				}
 			}
+			var reapErr error
+			if worktree == worktreeCleanupFailed {
+				reapErr = errors.New("worktree removal failed")
+			}
+			reap.End(reapErr)
 		}
 	})
 
@@ -2081,6 +2184,14 @@ Flags:
 	// load is the one where hand-sent mail is the only channel left.
 	agentRegistry.SetMailboxRegistrar(mgMailboxRegistrar{})
 
+	// Adopt the agents an earlier pogod left running under PTY holders
+	// (user-011) before the scheduler's GC or the auto-start sweep can look at
+	// the registry: both would otherwise find them absent. Unconditional, so
+	// turning pty_holder off does not strand agents started while it was on.
+	if adopted := agentRegistry.AdoptHeld(); len(adopted) > 0 {
+		log.Printf("pogod: adopted %d held agent(s): %s", len(adopted), strings.Join(adopted, ", "))
+	}
+
 	// Start the scheduler. Schedules in ~/.pogo/schedules.json drive a
 	// Tick() call from the heartbeat loop — wall-clock jumps are absorbed
 	// for free because the scheduler stores absolute fire times and the
@@ -3335,6 +3446,7 @@ Flags:
 	// periodic ticker that deletes stale polecat-* branches and reclaims
 	// leaked worktrees once their work items have concluded. mg-30d5.
 	startGitGC(hbCtx, agentRegistry, cfg.GitGC, coordinator)
+	startRecordingGC(hbCtx, cfg.Recording)
 
 	// Reclaim the per-spawn prompt files of polecats a previous pogod died
 	// holding. The same gap as the git GC's startup sweep, at the same moment,
@@ -3379,6 +3491,51 @@ Flags:
 		if cfg.Refinery.MaxConcurrentMerges > 0 {
 			refineCfg.MaxConcurrentMerges = cfg.Refinery.MaxConcurrentMerges
 		}
+		if cfg.Refinery.GateRunner != "" {
+			refineCfg.GateRunner = cfg.Refinery.GateRunner
+			log.Printf("refinery: quality gates run by the gate runner at %s", cfg.Refinery.GateRunner)
+		}
+		if sb := cfg.Refinery.Sandbox; sb.Mode != "" {
+			mode, err := sandbox.ParseMode(sb.Mode)
+			if err != nil {
+				// Kept as written, so every gate fails on it rather than
+				// running unconfined.
+				log.Printf("refinery: [refinery] sandbox: %v; quality gates will fail until it is fixed", err)
+				mode = sandbox.Mode(sb.Mode)
+			}
+			refineCfg.Sandbox = sandbox.Profile{Mode: mode, ReadOnly: sb.ReadOnly, Writable: sb.Writable,
+				Hidden: config.APISecretPaths()}
+			if refineCfg.Sandbox.Enabled() {
+				if refineCfg.GateRunner != "" {
+					log.Printf("refinery: [refinery] sandbox %s does not apply to gates run by the gate runner", mode)
+				} else {
+					log.Printf("refinery: quality gates run under sandbox %s", mode)
+				}
+			}
+		}
+		if wh := cfg.Refinery.Webhooks; len(wh.URLs) > 0 {
+			refineCfg.Webhooks.URLs = wh.URLs
+			refineCfg.Webhooks.Secret = wh.Secret
+			if wh.Secret == "" && wh.SecretFile != "" {
+				if data, err := os.ReadFile(wh.SecretFile); err != nil {
+					log.Printf("refinery: [refinery.webhooks] secret_file: %v", err)
+				} else {
+					refineCfg.Webhooks.Secret = strings.TrimSpace(string(data))
+				}
+			}
+			refineCfg.Webhooks.Events = wh.Events
+			refineCfg.Webhooks.MaxAttempts = wh.MaxAttempts
+			refineCfg.Webhooks.Timeout = wh.Timeout
+		}
+		// The same subtree, and a worker's share: a gate is a worker's build
+		// run once more, and must not take the box the workers are held off.
+		if m := agentRegistry.Cgroups(); m != nil {
+			refineCfg.Cgroups = m
+			refineCfg.GateLimits = agentRegistry.WorkerBudget().CgroupLimits(cgroup.HostMemory())
+			if refineCfg.GateRunner != "" {
+				log.Printf("refinery: cgroups do not apply to gates run by the gate runner")
+			}
+		}
 		var refErr error
 		mergeQueue, refErr = refinery.New(refineCfg)
 		if refErr != nil {
@@ -3629,6 +3786,21 @@ Flags:
 	}
 	fmt.Printf("pogod listening on %s\n", addr)
 
+	// The same API on a unix socket under $POGO_HOME, mode 0600: the CLI
+	// prefers it, sending the human's token from $POGO_HOME/api-token
+	// (user-021).
+	var socketLn net.Listener
+	if cfg.API.Socket {
+		if sock := config.APISocketPath(); sock == "" {
+			log.Printf("pogod: $POGO_HOME is too deep for a unix socket path; serving the API on TCP only")
+		} else if socketLn, listenErr = listenAPISocket(sock); listenErr != nil {
+			log.Printf("WARNING: pogod: API socket %s: %v; serving the API on TCP only", sock, listenErr)
+			socketLn = nil
+		} else {
+			fmt.Printf("pogod listening on %s\n", sock)
+		}
+	}
+
 	// Now start background work: indexing and repo scanning.
 	// The server is already accepting connections above.
 	go func() {
@@ -3807,6 +3979,15 @@ Flags:
 		ReadTimeout:       1 * time.Minute,
 		WriteTimeout:      5 * time.Minute,
 		IdleTimeout:       2 * time.Minute,
+		ConnContext:       apiauth.ConnContext,
+	}
+	if apiGuard != nil {
+		httpServer.Handler = apiGuard.Wrap(http.DefaultServeMux)
+	}
+	if socketLn != nil {
+		go func() {
+			log.Fatal(httpServer.Serve(netutil.LimitListener(socketLn, maxHTTPConns)))
+		}()
 	}
 	log.Fatal(httpServer.Serve(netutil.LimitListener(ln, maxHTTPConns)))
 }
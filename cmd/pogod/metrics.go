@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/drellem2/pogo/internal/metrics"
+	"github.com/drellem2/pogo/internal/refinery"
+	"github.com/drellem2/pogo/internal/search"
+)
+
+// registerMetrics mounts /metrics (user-022) and points its collected gauges
+// at pogod's live agent registry, refinery, scheduler and search index. The
+// counters and histograms need no wiring: they are recorded by the packages
+// that own them from the moment those packages load.
+//
+// /metrics sits outside the orchestration guard, beside /health: a pogod in
+// index-only mode is still worth scraping, and simply has no agents or merges
+// to report.
+func registerMetrics() {
+	agentRegistry.RegisterMetrics()
+	refinery.RegisterMetricsFunc(func() *refinery.Refinery { return mergeQueue })
+	if sched != nil {
+		sched.RegisterMetrics()
+	}
+	search.SearchService.RegisterMetrics()
+	http.Handle("/metrics", metrics.Handler())
+}
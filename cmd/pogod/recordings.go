@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/drellem2/pogo/internal/config"
+	"github.com/drellem2/pogo/internal/recording"
+)
+
+// recordingLimits is the [recording] section as the limits internal/recording
+// records and prunes under.
+func recordingLimits(cfg config.RecordingConfig) *recording.Limits {
+	return &recording.Limits{
+		RotateBytes: cfg.RotateBytes,
+		MaxBytes:    cfg.MaxBytes,
+		MaxAge:      cfg.MaxAge,
+	}
+}
+
+// startRecordingGC wires session-recording retention into pogod (user-015),
+// the way startGitGC wires the polecat git GC: one sweep immediately, then one
+// every cfg.Interval. The startup sweep is the one that matters after a long
+// outage, when nothing rotated and so nothing pruned; the ticker is what ages
+// out the recordings of agents that are gone and will never rotate again.
+//
+// It runs only while recording is on. Turning recording off keeps the files
+// already written, and nothing removes them — an operator who turned it off to
+// stop writing did not necessarily mean to lose what was kept.
+func startRecordingGC(ctx context.Context, cfg config.RecordingConfig) {
+	if !cfg.Enabled {
+		return
+	}
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = config.DefaultRecordingInterval
+	}
+	limits := *recordingLimits(cfg)
+	go func() {
+		runRecordingSweep(recording.Root(), limits) // startup sweep
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				runRecordingSweep(recording.Root(), limits)
+			}
+		}
+	}()
+}
+
+// runRecordingSweep prunes every agent's recordings under root once and logs
+// what it removed.
+func runRecordingSweep(root string, limits recording.Limits) {
+	res, err := recording.Sweep(root, limits, time.Now())
+	if err != nil {
+		log.Printf("pogod: recording sweep of %s failed: %v", root, err)
+		return
+	}
+	if len(res.Removed) > 0 || len(res.Errors) > 0 {
+		log.Printf("pogod: recording sweep — removed %d recordings across %d agents, %d errors",
+			len(res.Removed), res.Agents, len(res.Errors))
+	}
+	for _, e := range res.Errors {
+		log.Printf("pogod: recording sweep error: %s", e)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"log"
+
+	"github.com/drellem2/pogo/internal/config"
+	"github.com/drellem2/pogo/internal/tracing"
+)
+
+// startTracing installs the [tracing] exporters (user-023): the span file
+// `pogo item trace` reads, and the OTLP collector when one is configured.
+//
+// There is no matching stop. pogod has no shutdown path that runs code (see
+// the note on StopAll in main), so the spans still queued when it dies — at
+// most one batch interval's worth — are lost, which a trace survives: the
+// phases that were cut short are the ones that never ended anyway.
+func startTracing(cfg config.TracingConfig) {
+	if !cfg.Enabled {
+		log.Printf("pogod: work-item tracing is off ([tracing] enabled = false)")
+		return
+	}
+	exporters := []tracing.Exporter{&tracing.FileExporter{Path: config.TracesPath()}}
+	if cfg.OTLPEndpoint != "" {
+		exporters = append(exporters, &tracing.OTLPExporter{
+			Endpoint: cfg.OTLPEndpoint,
+			Headers:  cfg.Headers(),
+		})
+	}
+	tracing.Configure(exporters...)
+	for _, ex := range exporters {
+		log.Printf("pogod: tracing work items to %s", ex.Name())
+	}
+}
@@ -31,6 +31,23 @@ const (
 	worktreeNone
 )
 
+// String names the outcome, for the polecat.reap span (user-023).
+func (o worktreeCleanupOutcome) String() string {
+	switch o {
+	case worktreeReaped:
+		return "reaped"
+	case worktreePreserved:
+		return "preserved"
+	case worktreeUndetermined:
+		return "undetermined"
+	case worktreeCleanupFailed:
+		return "cleanup_failed"
+	case worktreeNone:
+		return "none"
+	}
+	return fmt.Sprintf("worktreeCleanupOutcome(%d)", int(o))
+}
+
 // exitedAgent is the identity of the agent whose worktree is being cleaned up.
 //
 // IT IS A STRUCT BECAUSE THE DEFECT IT FIXES WAS A MISSING ARGUMENT (mg-32e3).
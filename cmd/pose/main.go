@@ -6,10 +6,12 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
 
@@ -39,6 +41,11 @@ func newRootCmd() *cobra.Command {
 	var jsonOutput bool
 	var searchAll bool
 	var findRefs bool
+	var symbol bool
+	var globs []string
+	var lang, cursor, branch string
+	var caseSensitive, ignoreCase, fixedStrings bool
+	var contextLines, maxFiles int
 
 	var rootCmd = &cobra.Command{Use: "pose QUERY [PATH]", Version: version.Get().Describe("pose")}
 	// pose takes its query as a positional arg, but registering the
@@ -50,6 +57,16 @@ func newRootCmd() *cobra.Command {
 	rootCmd.Flags().BoolVar(&jsonOutput, "json", false, "Output in JSON format")
 	rootCmd.Flags().BoolVar(&searchAll, "all", false, "Search across all known projects")
 	rootCmd.Flags().BoolVar(&findRefs, "refs", false, "Find cross-repo references (definitions, imports, calls)")
+	rootCmd.Flags().BoolVar(&symbol, "sym", false, "Look the query up as a symbol: definitions first, then references")
+	rootCmd.Flags().StringArrayVarP(&globs, "glob", "g", nil, "Only search files matching this glob; a leading ! excludes them (repeatable)")
+	rootCmd.Flags().StringVar(&lang, "lang", "", "Only search files in this language (go, python, ts, ...)")
+	rootCmd.Flags().BoolVarP(&caseSensitive, "case-sensitive", "s", false, "Match case exactly")
+	rootCmd.Flags().BoolVarP(&ignoreCase, "ignore-case", "i", false, "Ignore case")
+	rootCmd.Flags().BoolVarP(&fixedStrings, "fixed-strings", "F", false, "Search for the query as a literal string, not a regular expression")
+	rootCmd.Flags().IntVarP(&contextLines, "context", "C", 0, "Show this many lines around each match")
+	rootCmd.Flags().IntVarP(&maxFiles, "max", "m", 0, fmt.Sprintf("Show at most this many files, best first (default: all; %d with --all)", searchAllLimit))
+	rootCmd.Flags().StringVar(&cursor, "cursor", "", "Continue a --max search from where the previous page stopped")
+	rootCmd.Flags().StringVar(&branch, "branch", "", "Search this indexed branch (origin/main, polecat-...) instead of the working tree")
 
 	rootCmd.Run = func(cobraCmd *cobra.Command, args []string) {
 		if len(args) == 0 {
@@ -61,13 +78,51 @@ func newRootCmd() *cobra.Command {
 			cli.ExitWithError(jsonOutput, err.Error(), cli.ExitError)
 		}
 
+		opts := client.QueryOptions{
+			Globs:   globs,
+			Lang:    lang,
+			Literal: fixedStrings,
+			Context: contextLines,
+			Limit:   maxFiles,
+			Cursor:  cursor,
+			Branch:  branch,
+		}
+		switch {
+		case caseSensitive && ignoreCase:
+			cli.ExitWithError(jsonOutput, "--case-sensitive and --ignore-case cannot be combined", cli.ExitError)
+		case caseSensitive:
+			opts.Case = "yes"
+		case ignoreCase:
+			opts.Case = "no"
+		}
+		if branch != "" && (findRefs || symbol) {
+			cli.ExitWithError(jsonOutput, "--branch cannot be combined with --refs or --sym, which search the working tree", cli.ExitError)
+		}
+		if findRefs || symbol {
+			if ignored := symbolModeIgnores(cobraCmd.Flags().Changed, findRefs); len(ignored) > 0 {
+				cli.ExitWithError(jsonOutput, strings.Join(ignored, ", ")+" cannot be combined with --refs or --sym, which look up a symbol rather than match text", cli.ExitError)
+			}
+		}
+
 		if findRefs {
 			runFindRefs(args[0], jsonOutput)
 			return
 		}
 
+		if symbol {
+			var root string
+			if !searchAll {
+				root = projectRootFor(args[1:], jsonOutput)
+			}
+			runSymbolSearch(args[0], root, maxFiles, jsonOutput)
+			return
+		}
+
 		if searchAll {
-			runSearchAll(args[0], jsonOutput, list)
+			if opts.Limit == 0 {
+				opts.Limit = searchAllLimit
+			}
+			runSearchAll(args[0], opts, jsonOutput, list)
 			return
 		}
 
@@ -85,10 +140,13 @@ func newRootCmd() *cobra.Command {
 			}
 			path = cwd
 		}
-		results, err := client.Search(args[0], path)
+		results, err := client.SearchWith(args[0], path, opts)
 		if err != nil {
 			cli.ExitWithError(jsonOutput, err.Error(), cli.ExitError)
 		}
+		if results.Error != "" {
+			cli.ExitWithError(jsonOutput, results.Error, cli.ExitError)
+		}
 		files := results.Results.Files
 
 		if jsonOutput {
@@ -111,30 +169,87 @@ func newRootCmd() *cobra.Command {
 				fmt.Println(path)
 			}
 		} else {
-			sort.Slice(files, func(i, j int) bool {
-				return len(files[i].Matches) > len(files[j].Matches)
-			})
-
+			// Files arrive best ranked first; keep that order, so a --max
+			// page is the best of what matched.
 			for _, file := range files {
 				fmt.Printf("%s\n", file.Path)
 				for _, match := range file.Matches {
-					fmt.Printf("\t%d:\t%s\n", match.Line, match.Content)
+					printMatch("\t", match)
 				}
 			}
 		}
+		if results.Results.NextCursor != "" {
+			// A hint, not a result: on stdout it would reach `pose -l | xargs`.
+			fmt.Fprintf(os.Stderr, "pose: more files match; next page: --cursor %s\n", results.Results.NextCursor)
+		}
 	}
 
 	return rootCmd
 }
 
-func runSearchAll(query string, jsonOutput bool, list bool) {
+// symbolModeIgnores returns the flags set (per changed) that --refs or --sym
+// would otherwise drop without a word. Both look a name up in the symbol and
+// reference indexes, so the text-matching flags have nothing to act on;
+// --sym still honors --max, --refs does not page at all.
+func symbolModeIgnores(changed func(name string) bool, refs bool) []string {
+	names := []string{"glob", "lang", "context", "fixed-strings", "case-sensitive", "ignore-case", "cursor"}
+	if refs {
+		names = append(names, "max")
+	}
+	var set []string
+	for _, name := range names {
+		if changed(name) {
+			set = append(set, "--"+name)
+		}
+	}
+	return set
+}
+
+// searchAllLimit caps how many files `pose --all` prints. The files are the
+// best-ranked across every repo, so the cap drops the weakest matches in the
+// fleet, not whole repos.
+const searchAllLimit = 200
+
+// printMatch prints one match under indent. A chunk with context lines is
+// printed a line at a time, grep-style: `N:` marks a matching line and `N-` a
+// context line.
+func printMatch(indent string, match client.PogoChunkMatch) {
+	if len(match.MatchLines) == 0 {
+		fmt.Printf("%s%d:\t%s\n", indent, match.Line, match.Content)
+		return
+	}
+	matched := make(map[uint32]bool, len(match.MatchLines))
+	for _, l := range match.MatchLines {
+		matched[l] = true
+	}
+	for i, text := range strings.Split(match.Content, "\n") {
+		line := match.Line + uint32(i)
+		sep := "-"
+		if matched[line] {
+			sep = ":"
+		}
+		fmt.Printf("%s%d%s\t%s\n", indent, line, sep, text)
+	}
+}
+
+// pageNote is the line printed after a page of results that did not hold
+// everything that matched.
+func pageNote(page client.SearchPage, limit int) string {
+	if page.NextCursor != "" {
+		return fmt.Sprintf("showing the best %d files; next page: --cursor %s", limit, page.NextCursor)
+	}
+	return fmt.Sprintf("showing the best %d files; narrow the query to see the rest", limit)
+}
+
+func runSearchAll(query string, opts client.QueryOptions, jsonOutput bool, list bool) {
 	first := true
-	count := 0
+	var page client.SearchPage
+	var err error
 
 	if jsonOutput {
-		// Use newline-delimited JSON: emit each repo's result object as it arrives
-		err := client.SearchAllStreaming(query, func(resp *client.SearchResponse) {
-			count++
+		// Use newline-delimited JSON: one object per repo, repos in the order
+		// of their best-ranked file
+		page, err = client.SearchAllRanked(query, opts, func(resp *client.SearchResponse) {
 			data, err := json.Marshal(resp)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, `{"error": "failed to marshal JSON: %s"}`+"\n", err)
@@ -145,11 +260,13 @@ func runSearchAll(query string, jsonOutput bool, list bool) {
 		if err != nil {
 			cli.ExitWithError(jsonOutput, err.Error(), cli.ExitError)
 		}
+		if page.Truncated {
+			fmt.Fprintf(os.Stderr, "pose: %s\n", pageNote(page, opts.Limit))
+		}
 		return
 	}
 
-	err := client.SearchAllStreaming(query, func(resp *client.SearchResponse) {
-		count++
+	page, err = client.SearchAllRanked(query, opts, func(resp *client.SearchResponse) {
 		if !first {
 			fmt.Println()
 		}
@@ -161,6 +278,7 @@ func runSearchAll(query string, jsonOutput bool, list bool) {
 			return
 		}
 
+		// Files arrive best first, ranked across every repo; keep that order.
 		files := resp.Results.Files
 		if list {
 			uniqueFiles := make(map[string]struct{})
@@ -176,20 +294,105 @@ func runSearchAll(query string, jsonOutput bool, list bool) {
 				fmt.Printf("  %s\n", path)
 			}
 		} else {
-			sort.Slice(files, func(i, j int) bool {
-				return len(files[i].Matches) > len(files[j].Matches)
-			})
 			for _, file := range files {
 				fmt.Printf("  %s\n", file.Path)
 				for _, match := range file.Matches {
-					fmt.Printf("    %d:\t%s\n", match.Line, match.Content)
+					printMatch("    ", match)
+				}
+			}
+		}
+	})
+	if err != nil {
+		cli.ExitWithError(jsonOutput, err.Error(), cli.ExitError)
+	}
+	if page.Truncated {
+		fmt.Fprintf(os.Stderr, "pose: %s\n", pageNote(page, opts.Limit))
+	}
+}
+
+// projectRootFor resolves the project holding the optional PATH argument, or
+// the working directory when there is none.
+func projectRootFor(args []string, jsonOutput bool) string {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		cli.ExitWithError(jsonOutput, err.Error(), cli.ExitError)
+	}
+	resp, err := client.Visit(dir)
+	if err != nil {
+		cli.ExitWithError(jsonOutput, err.Error(), cli.ExitError)
+	}
+	if resp == nil || resp.ParentProject.Path == "" {
+		cli.ExitWithError(jsonOutput, "no pogo project contains "+dir, cli.ExitError)
+	}
+	return resp.ParentProject.Path
+}
+
+// runSymbolSearch prints `pose --sym`: per repo, the definitions of name,
+// then its references. Repos indexed without symbols cannot tell the two
+// apart, so their matches are printed as plain matches after both.
+func runSymbolSearch(name, root string, limit int, jsonOutput bool) {
+	if limit == 0 {
+		limit = searchAllLimit
+	}
+	first := true
+	truncated, err := client.SymbolSearch(root, name, limit, func(resp *client.SearchResponse) {
+		if jsonOutput {
+			data, err := json.Marshal(resp)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, `{"error": "failed to marshal JSON: %s"}`+"\n", err)
+				return
+			}
+			fmt.Println(string(data))
+			return
+		}
+
+		if !first {
+			fmt.Println()
+		}
+		first = false
+
+		fmt.Printf("=== %s ===\n", resp.Index.Root)
+		if resp.Error != "" {
+			fmt.Printf("  error: %s\n", resp.Error)
+			return
+		}
+		for _, section := range []struct{ kind, title string }{
+			{"definition", "definitions"},
+			{"reference", "references"},
+			{"", "matches"},
+		} {
+			header := false
+			for _, file := range resp.Results.Files {
+				for _, match := range file.Matches {
+					if match.Kind != section.kind {
+						continue
+					}
+					if !header {
+						fmt.Printf("  [%s]\n", section.title)
+						header = true
+					}
+					line := fmt.Sprintf("    %s:%d\t%s", file.Path, match.Line, match.Content)
+					if match.Symbol != nil && match.Symbol.Kind != "" {
+						line += "\t(" + match.Symbol.Kind + ")"
+					}
+					fmt.Println(line)
 				}
 			}
 		}
 	})
+	if errors.Is(err, client.ErrSymbolSearchUnsupported) {
+		cli.ExitWithError(jsonOutput, err.Error()+"; restart it on this version, or use --refs", cli.ExitError)
+	}
 	if err != nil {
 		cli.ExitWithError(jsonOutput, err.Error(), cli.ExitError)
 	}
+	if truncated {
+		fmt.Fprintf(os.Stderr, "pose: %s\n", pageNote(client.SearchPage{Truncated: true}, limit))
+	}
 }
 
 func runFindRefs(symbol string, jsonOutput bool) {
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/spf13/cobra"
@@ -71,3 +72,29 @@ func TestCompletionSubcommandStillResolves(t *testing.T) {
 		t.Fatal("completion subcommand was shadowed by root Run")
 	}
 }
+
+// TestSymbolModesRejectTextFlags. --refs and --sym look a name up rather than
+// match text, so a -g or -C given with them would be dropped; it is refused
+// instead, as --branch is, so nobody reads unfiltered results as filtered.
+func TestSymbolModesRejectTextFlags(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		refs bool
+		want []string
+	}{
+		{args: []string{"--sym", "Foo"}},
+		{args: []string{"--sym", "-m", "5", "Foo"}},
+		{args: []string{"--refs", "-m", "5", "Foo"}, refs: true, want: []string{"--max"}},
+		{args: []string{"--sym", "-g", "*.go", "-C", "2", "-i", "Foo"}, want: []string{"--glob", "--context", "--ignore-case"}},
+		{args: []string{"--refs", "--lang", "go", "-F", "--cursor", "x", "Foo"}, refs: true, want: []string{"--lang", "--fixed-strings", "--cursor"}},
+	} {
+		rootCmd := newRootCmd()
+		if err := rootCmd.ParseFlags(tc.args); err != nil {
+			t.Fatalf("ParseFlags(%v): %v", tc.args, err)
+		}
+		got := symbolModeIgnores(rootCmd.Flags().Changed, tc.refs)
+		if strings.Join(got, " ") != strings.Join(tc.want, " ") {
+			t.Errorf("%v: refused %v, want %v", tc.args, got, tc.want)
+		}
+	}
+}
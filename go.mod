@@ -18,6 +18,7 @@ require (
 )
 
 require (
+	github.com/go-enry/go-enry/v2 v2.8.4
 	github.com/rs/xid v1.5.0
 	golang.org/x/net v0.17.0
 )
@@ -26,7 +27,6 @@ require (
 	github.com/RoaringBitmap/roaring v1.3.0 // indirect
 	github.com/bits-and-blooms/bitset v1.8.0 // indirect
 	github.com/fatih/color v1.15.0 // indirect
-	github.com/go-enry/go-enry/v2 v2.8.4 // indirect
 	github.com/go-enry/go-oniguruma v1.2.1 // indirect
 	github.com/golang/protobuf v1.5.3 // indirect
 	github.com/grafana/regexp v0.0.0-20221123153739-15dc172cd2db // indirect
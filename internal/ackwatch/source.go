@@ -50,37 +50,36 @@ func SampleEntries(entries []scheduler.Entry, ref time.Time) []Sample {
 // redeploy guarantees one, so a storm's deficit is erased by the restart that
 // follows it and only the events log retains it).
 //
-// Two reads rather than one unfiltered read: events.Filter carries a single
-// Type, and this log is tens of megabytes on a live box, most of it neither
-// event.
+// One indexed read for both types (events.Select): this log is tens of
+// megabytes on a live box, most of it neither event, and the sidecar index
+// reads only the blocks of the window that hold one.
 func ReadFireTimeline(logPath string, since, until time.Time) ([]FireEvent, error) {
+	kinds := map[string]FireEventKind{
+		"scheduler_fire_delivered": FireDelivered,
+		"scheduler_fire_completed": FireCompleted,
+	}
+	q := events.Query{Since: since, Until: until, Where: events.Or(
+		events.Equals("event_type", "scheduler_fire_delivered"),
+		events.Equals("event_type", "scheduler_fire_completed"),
+	)}
 	var out []FireEvent
-	for kind, evType := range map[FireEventKind]string{
-		FireDelivered: "scheduler_fire_delivered",
-		FireCompleted: "scheduler_fire_completed",
-	} {
-		evs, err := events.ReadFiltered(logPath, events.Filter{SinceMin: since, Type: evType})
-		if err != nil {
-			return nil, err
-		}
-		for _, ev := range evs {
-			at, perr := time.Parse(time.RFC3339Nano, ev.Timestamp)
-			if perr != nil {
-				continue
-			}
-			if !until.IsZero() && !at.Before(until) {
-				continue
-			}
-			out = append(out, FireEvent{
-				At:    at,
-				Kind:  kind,
-				Agent: detailString(ev.Details, "to"),
-				ID:    detailString(ev.Details, "schedule_id"),
-				Token: detailString(ev.Details, "fire_token"),
-				Due:   detailTime(ev.Details, "original_due"),
-				Fired: detailTime(ev.Details, "fired_at"),
-			})
+	err := events.Select(logPath, q, func(ev events.Event) {
+		at, perr := time.Parse(time.RFC3339Nano, ev.Timestamp)
+		if perr != nil {
+			return
 		}
+		out = append(out, FireEvent{
+			At:    at,
+			Kind:  kinds[ev.EventType],
+			Agent: detailString(ev.Details, "to"),
+			ID:    detailString(ev.Details, "schedule_id"),
+			Token: detailString(ev.Details, "fire_token"),
+			Due:   detailTime(ev.Details, "original_due"),
+			Fired: detailTime(ev.Details, "fired_at"),
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 	sort.SliceStable(out, func(i, j int) bool { return out[i].At.Before(out[j].At) })
 	return out, nil
@@ -129,9 +128,9 @@ func detailTime(details map[string]any, key string) time.Time {
 // close it at the last event read, which would quietly acquit every fire after
 // that point on the strength of having stopped looking.
 func ReadFailureEpisodes(logPath string, since, until time.Time) ([]FailureEpisode, error) {
-	// One flat, time-ordered stream of transitions. The two reads below are
-	// separate scans (events.Filter carries a single Type), so they arrive
-	// interleaved-by-type and must be re-sorted before they can be paired.
+	// One flat, time-ordered stream of transitions. The read below returns
+	// them in file order, which across rotated files and concurrent writers
+	// is only nearly time order, so they are sorted before they are paired.
 	type transition struct {
 		at      time.Time
 		agent   string
@@ -139,27 +138,25 @@ func ReadFailureEpisodes(logPath string, since, until time.Time) ([]FailureEpiso
 	}
 	var stream []transition
 
-	for _, evType := range []string{"synthetic_failure_detected", "synthetic_failure_cleared"} {
-		evs, err := events.ReadFiltered(logPath, events.Filter{SinceMin: since, Type: evType})
-		if err != nil {
-			return nil, err
+	q := events.Query{Since: since, Until: until, Where: events.Or(
+		events.Equals("event_type", "synthetic_failure_detected"),
+		events.Equals("event_type", "synthetic_failure_cleared"),
+	)}
+	err := events.Select(logPath, q, func(ev events.Event) {
+		at, perr := time.Parse(time.RFC3339Nano, ev.Timestamp)
+		if perr != nil {
+			return
 		}
-		for _, ev := range evs {
-			at, perr := time.Parse(time.RFC3339Nano, ev.Timestamp)
-			if perr != nil {
-				continue
-			}
-			if !until.IsZero() && !at.Before(until) {
-				continue
-			}
-			agent := detailString(ev.Details, "target")
-			if agent == "" {
-				continue
-			}
-			stream = append(stream, transition{
-				at: at, agent: agent, cleared: evType == "synthetic_failure_cleared",
-			})
+		agent := detailString(ev.Details, "target")
+		if agent == "" {
+			return
 		}
+		stream = append(stream, transition{
+			at: at, agent: agent, cleared: ev.EventType == "synthetic_failure_cleared",
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 	sort.SliceStable(stream, func(i, j int) bool { return stream[i].at.Before(stream[j].at) })
 
@@ -300,24 +297,17 @@ const DisruptionEventType = "system_wake"
 // toward alerting rather than toward silence, which is the correct direction
 // for a detector whose entire premise is that silence hid a fault for a week.
 func LastDisruption(logPath string, now time.Time) (time.Time, string) {
-	evs, err := events.ReadFiltered(logPath, events.Filter{
+	var latest time.Time
+	err := events.Select(logPath, events.Filter{
 		SinceMin: now.Add(-DisruptionWindow),
 		Type:     DisruptionEventType,
-	})
-	if err != nil {
-		return time.Time{}, ""
-	}
-	var latest time.Time
-	for _, ev := range evs {
+	}.Query(), func(ev events.Event) {
 		ts, perr := time.Parse(time.RFC3339Nano, ev.Timestamp)
-		if perr != nil {
-			continue
-		}
-		if ts.After(latest) {
+		if perr == nil && ts.After(latest) {
 			latest = ts
 		}
-	}
-	if latest.IsZero() {
+	})
+	if err != nil || latest.IsZero() {
 		return time.Time{}, ""
 	}
 	return latest, DisruptionEventType
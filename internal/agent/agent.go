@@ -10,6 +10,7 @@ import (
 	"bufio"
 	"context"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -26,9 +27,16 @@ import (
 	"time"
 
 	"github.com/creack/pty"
+	"golang.org/x/term"
 
+	"github.com/drellem2/pogo/internal/apiauth"
 	"github.com/drellem2/pogo/internal/config"
 	"github.com/drellem2/pogo/internal/events"
+	"github.com/drellem2/pogo/internal/platform/cgroup"
+	"github.com/drellem2/pogo/internal/platform/sandbox"
+	"github.com/drellem2/pogo/internal/recording"
+	"github.com/drellem2/pogo/internal/tracing"
+	"github.com/drellem2/pogo/internal/vt"
 )
 
 // AgentType distinguishes long-running crew agents from ephemeral polecats.
@@ -112,6 +120,24 @@ type Agent struct {
 	// Registry.startedSignal.
 	ClaimedAtSpawn bool `json:"claimed_at_spawn,omitempty"`
 
+	// Sandbox is the profile this agent was confined to at spawn (user-012),
+	// nil when it runs unconfined. Carried to a respawn and through a
+	// holder's record, so an agent comes back as confined as it went.
+	// Immutable after construction.
+	Sandbox *sandbox.Profile `json:"sandbox,omitempty"`
+
+	// Mode is how this agent's harness is driven (stream.go, user-019):
+	// ModeTUI or ModeStream. Carried to a respawn and through a holder's
+	// record. Immutable after construction.
+	Mode string `json:"mode,omitempty"`
+
+	// Cgroup is the cgroup this agent runs in (cgroups.go, user-013), and
+	// CgroupLimits what it is held to; empty for an agent pogod placed in
+	// none. Carried through a holder's record, so a restarted pogod still
+	// removes the group when the agent exits. Immutable after construction.
+	Cgroup       string         `json:"cgroup,omitempty"`
+	CgroupLimits *cgroup.Limits `json:"cgroup_limits,omitempty"`
+
 	// RateLimited is set by the modal watcher when the agent is suspected to
 	// have hit a provider usage limit — the rate-limit-options modal is visible
 	// and the agent's event log has been stale past the usage-limit threshold
@@ -136,6 +162,14 @@ type Agent struct {
 	slave *os.File
 	cmd   *exec.Cmd
 
+	// holder is the connection to the holder process that owns this agent's
+	// PTY and child, when it runs under one (holder.go); master, slave and cmd
+	// are then unused. Immutable after construction. holderClosed records,
+	// under mu, that Cleanup has dropped the connection — the held twin of
+	// master going nil.
+	holder       *holderConn
+	holderClosed bool
+
 	// nudge is the provider's PTY-input dialect, captured at spawn from this
 	// agent's resolved provider (or DefaultNudgeProfile when none is set).
 	// Immutable after construction, so it is safe to read without a.mu.
@@ -160,6 +194,33 @@ type Agent struct {
 	// outputBuf holds recent output for monitoring.
 	outputBuf *RingBuffer
 
+	// screen is what outputBuf's bytes leave on a terminal of the PTY's size
+	// (user-014, see screen.go). Nil for an Agent a test builds by hand.
+	screen *vt.Screen
+
+	// recorder, when session recording is on, keeps this agent's PTY stream
+	// on disk (user-015, see recording.go). Nil when it is off. Immutable
+	// after construction.
+	recorder *recording.Recorder
+
+	// traceParent is the span of the dispatch that made this polecat
+	// (user-023), so its run, start verification and reap join that dispatch
+	// in the work item's trace. Zero for an untraced spawn. Immutable after
+	// construction; a respawn inherits it.
+	traceParent tracing.SpanID
+
+	// stream follows the harness's structured output when Mode is ModeStream
+	// (stream.go); nil in the TUI. Immutable after construction.
+	stream *streamState
+
+	// Lifecycle state (lifecycle.go). stateMu is its own lock, not mu: mu is
+	// held across a nudge's sleeps, and the output reader moves the state on
+	// every read.
+	stateMu     sync.Mutex
+	state       State
+	stateSince  time.Time
+	stateReason string
+
 	// promptReadySeen latches true the first time this agent's harness is
 	// observed at a ready composer (the provider's prompt-ready sentinel or an
 	// alternate). It latches rather than being re-derived because outputBuf is
@@ -202,6 +263,13 @@ type Agent struct {
 	attachConns map[io.Writer]struct{}
 	attachMu    sync.Mutex
 
+	// attachClients are the people attached over the socket or the WebSocket,
+	// and attachWriter the one holding the writer lock, if any
+	// (attachclients.go). Guarded by attachMu.
+	attachClients []*attachClient
+	attachWriter  *attachClient
+	attachNextID  int
+
 	// done is closed when the agent process exits and output is drained.
 	done chan struct{}
 	// readerAttached is closed by readOutput immediately before its first
@@ -608,6 +676,36 @@ type Registry struct {
 	// above, the routing holds without any wiring. See templateroute.go.
 	workItemTyper WorkItemTyper
 
+	// holderExe, when set, is the binary Spawn and respawn start each agent's
+	// PTY holder from — pogod's own — so the agent survives pogod (holder.go,
+	// user-011). Empty, the default, keeps pogod the parent of every agent and
+	// the owner of every PTY master. Guarded by mu.
+	holderExe string
+
+	// sandboxCfg supplies the configured sandbox profile per agent type
+	// (sandboxprofile.go, user-012). Nil means no agent is confined unless its
+	// prompt asks. Guarded by mu.
+	sandboxCfg AgentSandboxConfig
+
+	// modeCfg supplies the configured execution mode per agent type
+	// (stream.go, user-019). Nil runs every agent in the TUI unless its spawn
+	// asks otherwise. Guarded by mu.
+	modeCfg AgentModeConfig
+
+	// apiTokens issues each agent it starts a bearer token scoped to its
+	// type's role (apitoken.go, user-021). Nil issues none. Guarded by mu.
+	apiTokens *apiauth.Issuer
+
+	// cgroups, when set, is the delegated cgroup v2 subtree each polecat is
+	// started in a group of its own under, limited to its worker budget
+	// (cgroups.go, user-013). Nil leaves the budget advisory. Guarded by mu.
+	cgroups *cgroup.Manager
+
+	// recording, when set, is the limits every agent's session recording is
+	// kept under (recording.go, user-015). Nil, the default, records nothing.
+	// Guarded by mu.
+	recording *recording.Limits
+
 	// draining, when true, makes handleSpawnPolecat refuse to dispatch new
 	// polecats — the drain half of the pogo self-deploy path (mg-cae1 /
 	// mg-6afa). Only pogod knows its children and controls dispatch, so the
@@ -871,7 +969,7 @@ func spawnDefaults(p *Provider) (NudgeProfile, *pty.Winsize) {
 // restarted agent re-arms its own provider's watcher, never a registry-global
 // one. Called from Spawn / Respawn while r.mu is already held exclusively.
 func (r *Registry) invokeSessionHook(a *Agent) {
-	if a.provider == nil || a.provider.SessionHook == nil {
+	if a.provider == nil || a.provider.SessionHook == nil || a.stream != nil {
 		return
 	}
 	fn := a.provider.SessionHook
@@ -975,6 +1073,24 @@ type SpawnRequest struct {
 	// the deliberate default; see internal/agent/model.go for the outage
 	// behind it.
 	Model string
+
+	// Sandbox is the sandbox mode the agent's prompt frontmatter asked for.
+	// Empty defers to the configured one; see resolveSandboxLocked.
+	Sandbox string
+
+	// Mode is the execution mode the agent's prompt frontmatter asked for,
+	// ModeTUI or ModeStream. Empty defers to the configured one; see
+	// resolveModeLocked.
+	Mode string
+
+	// Budget is the worker budget a polecat was told, dispatcher override
+	// included. It is what its cgroup is limited to when the registry has
+	// cgroups; unknown falls back to the registry's own division.
+	Budget WorkerBudget
+
+	// TraceParent is the dispatch span this spawn belongs to, for the
+	// polecat's own spans. Zero when the dispatch was not traced.
+	TraceParent tracing.SpanID
 }
 
 // ErrAgentAlreadyRunning is returned by Spawn when a live agent is already
@@ -1063,7 +1179,18 @@ func (r *Registry) Spawn(req SpawnRequest) (*Agent, error) {
 	// there is no idle-window race. The command is copied, not appended in
 	// place, so the caller's slice is never mutated; the stored a.Command
 	// carries the prompt so restart-on-crash re-delivers it via re-exec.
-	promptViaArgv := provider != nil && provider.InitialPromptViaArgv && req.InitialNudge != ""
+	// Resolve the execution mode. A stream-mode harness is started with its
+	// dialect's flags, and takes every message — the initial one included —
+	// as structured input, never as argv (stream.go).
+	mode, err := r.resolveModeLocked(req, provider)
+	if err != nil {
+		return nil, err
+	}
+	if mode == ModeStream {
+		command = append(append([]string(nil), command...), provider.Stream.Flags...)
+	}
+
+	promptViaArgv := provider != nil && provider.InitialPromptViaArgv && req.InitialNudge != "" && mode != ModeStream
 	if promptViaArgv {
 		// Extend `command`, NOT req.Command: the model argv applied just above
 		// lives only in `command`, and rebuilding from req.Command here would
@@ -1073,6 +1200,20 @@ func (r *Registry) Spawn(req SpawnRequest) (*Agent, error) {
 		log.Printf("agent %s: delivering initial prompt via argv (provider %q)", req.Name, provider.ID)
 	}
 
+	// Resolve the sandbox profile before anything is written for the agent:
+	// one that cannot be honoured fails the spawn here (sandboxprofile.go).
+	profile, err := r.resolveSandboxLocked(req)
+	if err != nil {
+		return nil, err
+	}
+	// And its cgroup, for the same reason: with [dispatch] cgroup_root set, a
+	// polecat that cannot be held to its budget is not started (cgroups.go).
+	group, limits, err := r.createCgroupLocked(req.Name, req.Type, req.Budget, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer group.Close()
+
 	cmd := exec.Command(command[0], command[1:]...)
 	if req.Dir != "" {
 		cmd.Dir = req.Dir
@@ -1094,7 +1235,7 @@ func (r *Registry) Spawn(req SpawnRequest) (*Agent, error) {
 	// appearing in the report — which is the property mg-fbaf was filed about:
 	// an environment that exists and is believed absent.
 	procName := ProcessName(req.Type, req.Name)
-	injectedEnv := envAssignments(agentIdentityEnv(req.Name, req.Type, procName, req.PromptFile, receiptFile))
+	injectedEnv := envAssignments(agentIdentityEnv(req.Name, req.Type, procName, req.PromptFile, receiptFile, r.apiTokenLocked(req.Name, req.Type)))
 
 	cmd.Env = append(os.Environ(), append(injectedEnv, req.Env...)...)
 
@@ -1120,14 +1261,15 @@ func (r *Registry) Spawn(req SpawnRequest) (*Agent, error) {
 	// group with the PTY slave as its controlling terminal. A signal aimed
 	// at one agent's group (or at pogod's) therefore never cascades to
 	// pogod or sibling agents. TestSpawnProcessGroupIsolation guards this.
-	master, slave, err := startPTY(cmd, winsize)
+	pid, master, slave, held, err := r.startProcessLocked(req.Name, cmd, winsize, mode == ModeStream, profile, group)
 	if err != nil {
-		return nil, fmt.Errorf("pty start: %w", err)
+		discardCgroup(group)
+		return nil, err
 	}
 
 	a := &Agent{
 		Name:           req.Name,
-		PID:            cmd.Process.Pid,
+		PID:            pid,
 		Type:           req.Type,
 		StartTime:      time.Now(),
 		Command:        command,
@@ -1140,13 +1282,21 @@ func (r *Registry) Spawn(req SpawnRequest) (*Agent, error) {
 		WorkItemID:     req.WorkItemID,
 		Model:          req.Model,
 		ClaimedAtSpawn: req.ClaimedAtSpawn,
+		Sandbox:        profile,
+		Mode:           mode,
+		Cgroup:         cgroupPath(group),
+		CgroupLimits:   limits,
 		master:         master,
 		slave:          slave,
 		cmd:            cmd,
+		holder:         held,
 		nudge:          nudge,
 		provider:       provider,
 		receiptFile:    receiptFile,
 		outputBuf:      NewRingBuffer(OutputRingBytes), // 64KB rolling buffer
+		screen:         newScreen(winsize),
+		recorder:       r.startRecordingLocked(req.Name, winsize),
+		traceParent:    req.TraceParent,
 		attachConns:    make(map[io.Writer]struct{}),
 		socketPath:     filepath.Join(r.socketDir, req.Name+".sock"),
 		done:           make(chan struct{}),
@@ -1162,6 +1312,8 @@ func (r *Registry) Spawn(req SpawnRequest) (*Agent, error) {
 	// an undrained tty loses its output to revocation. startPTY makes that
 	// survivable rather than fatal, but there is no reason to spend the socket
 	// bind inside the window as well.
+	a.enterState(a.StartTime, "spawned")
+	a.armStream()
 	go a.readOutput()
 
 	// Bind the attach socket before the agent enters the registry, so a
@@ -1176,9 +1328,13 @@ func (r *Registry) Spawn(req SpawnRequest) (*Agent, error) {
 	// losing it for good (mg-d216). See mg-ef80.
 	if err := a.startListener(); err != nil {
 		if isFatalListenErr(err) {
-			_ = cmd.Process.Kill()
-			_ = cmd.Wait()
+			_ = a.signal(os.Kill)
+			_ = a.waitProcess()
+			a.setState(StateStopped, "attach socket unusable", time.Now())
 			a.Cleanup()
+			if held != nil {
+				held.removeFiles()
+			}
 			return nil, fmt.Errorf("%w: agent %s at %s: %w", ErrAttachSocketUnusable, req.Name, a.socketPath, err)
 		}
 		log.Printf("agent %s: attach listener failed: %v — supervisor will retry", req.Name, err)
@@ -1186,6 +1342,7 @@ func (r *Registry) Spawn(req SpawnRequest) (*Agent, error) {
 
 	// Start process reaper — waits for exit, fires onExit callback
 	go r.waitAndHandle(a)
+	go r.watchState(a)
 
 	r.agents[req.Name] = a
 	log.Printf("agent %s: spawned pid=%d type=%s proc=%s", req.Name, a.PID, req.Type, procName)
@@ -1208,7 +1365,8 @@ func (r *Registry) Spawn(req SpawnRequest) (*Agent, error) {
 	// Run post-spawn hook (e.g. trust dialog dismissal) if this agent's
 	// resolved provider declares one. Read off a.provider, not a registry
 	// global, so each agent runs its own provider's hook.
-	if a.provider != nil && a.provider.PostSpawnHook != nil {
+	// A stream-mode agent has no screen to watch, so it runs neither hook.
+	if a.provider != nil && a.provider.PostSpawnHook != nil && a.stream == nil {
 		go a.provider.PostSpawnHook(a)
 	}
 
@@ -1226,8 +1384,9 @@ func (r *Registry) Spawn(req SpawnRequest) (*Agent, error) {
 	// wait-idle. Gated on the provider's NeedsInitialNudge — a harness that
 	// takes the persona prompt as a command-line arg needs no nudge — and
 	// skipped when the prompt already went out via argv above (a.InitialNudge
-	// then stays empty, so Respawn re-delivers via re-exec, not re-nudge).
-	if req.InitialNudge != "" && a.nudge.NeedsInitialNudge && !promptViaArgv {
+	// then stays empty, so Respawn re-delivers via re-exec, not re-nudge). A
+	// stream-mode agent always takes it, as its first input message.
+	if req.InitialNudge != "" && (a.nudge.NeedsInitialNudge || a.stream != nil) && !promptViaArgv {
 		a.InitialNudge = req.InitialNudge
 		go func() {
 			if err := a.NudgeWithMode(req.InitialNudge, NudgeWaitReady, a.nudge.InitialNudgeTimeout); err != nil {
@@ -1242,6 +1401,10 @@ func (r *Registry) Spawn(req SpawnRequest) (*Agent, error) {
 		}()
 	}
 
+	// Last, so the record carries InitialNudge: a held agent is adoptable by
+	// the next pogod from here on.
+	a.saveHolderRecord()
+
 	return a, nil
 }
 
@@ -1385,7 +1548,7 @@ func (r *Registry) StopWithCause(name string, timeout time.Duration, cause strin
 		agent.stopCause = cause
 		agent.mu.Unlock()
 		// Send SIGTERM via the process
-		if err := agent.cmd.Process.Signal(os.Interrupt); err != nil {
+		if err := agent.signal(os.Interrupt); err != nil {
 			// Process may have exited between check and signal — that's OK
 			<-agent.done
 		} else {
@@ -1394,7 +1557,7 @@ func (r *Registry) StopWithCause(name string, timeout time.Duration, cause strin
 				// Clean exit
 			case <-time.After(timeout):
 				// Force kill
-				agent.cmd.Process.Kill()
+				agent.signal(os.Kill)
 				<-agent.done
 			}
 		}
@@ -1657,7 +1820,7 @@ func (r *Registry) respawn(name string, gen uint64, checkGen bool) (*Agent, erro
 	// Same catalogue as the spawn path — a restart that injected a different
 	// set would be an environment nothing reports.
 	procName := ProcessName(old.Type, old.Name)
-	injectedEnv := envAssignments(agentIdentityEnv(old.Name, old.Type, procName, old.PromptFile, receiptFile))
+	injectedEnv := envAssignments(agentIdentityEnv(old.Name, old.Type, procName, old.PromptFile, receiptFile, r.apiTokenLocked(old.Name, old.Type)))
 
 	cmd.Env = append(os.Environ(), injectedEnv...)
 
@@ -1675,14 +1838,23 @@ func (r *Registry) respawn(name string, gen uint64, checkGen bool) (*Agent, erro
 
 	nudge, winsize := spawnDefaults(provider)
 
-	master, slave, err := startPTY(cmd, winsize)
+	// The limits the agent had, not a fresh division: a dispatcher's budget
+	// override outlives a restart.
+	group, limits, err := r.createCgroupLocked(old.Name, old.Type, WorkerBudget{}, old.CgroupLimits)
+	if err != nil {
+		return nil, err
+	}
+	defer group.Close()
+
+	pid, master, slave, held, err := r.startProcessLocked(old.Name, cmd, winsize, old.Mode == ModeStream, old.Sandbox, group)
 	if err != nil {
-		return nil, fmt.Errorf("pty start: %w", err)
+		discardCgroup(group)
+		return nil, err
 	}
 
 	a := &Agent{
 		Name:           old.Name,
-		PID:            cmd.Process.Pid,
+		PID:            pid,
 		Type:           old.Type,
 		StartTime:      time.Now(),
 		Command:        old.Command,
@@ -1698,13 +1870,21 @@ func (r *Registry) respawn(name string, gen uint64, checkGen bool) (*Agent, erro
 		// Carried, not re-resolved: old.Command already carries the model argv,
 		// so a respawn must report the same model it re-execs with.
 		Model:          old.Model,
+		Sandbox:        old.Sandbox,
+		Mode:           old.Mode,
+		Cgroup:         cgroupPath(group),
+		CgroupLimits:   limits,
 		master:         master,
 		slave:          slave,
 		cmd:            cmd,
+		holder:         held,
 		nudge:          nudge,
 		provider:       provider,
 		receiptFile:    receiptFile,
 		outputBuf:      NewRingBuffer(OutputRingBytes),
+		screen:         newScreen(winsize),
+		recorder:       r.startRecordingLocked(old.Name, winsize),
+		traceParent:    old.traceParent,
 		attachConns:    make(map[io.Writer]struct{}),
 		socketPath:     filepath.Join(r.socketDir, old.Name+".sock"),
 		done:           make(chan struct{}),
@@ -1712,8 +1892,11 @@ func (r *Registry) respawn(name string, gen uint64, checkGen bool) (*Agent, erro
 		readerAttached: make(chan struct{}),
 	}
 
+	a.enterState(a.StartTime, fmt.Sprintf("respawned (restart %d)", restartCount))
+	a.armStream()
 	go a.readOutput()
 	go r.waitAndHandle(a)
+	go r.watchState(a)
 
 	if err := a.startListener(); err != nil {
 		log.Printf("agent %s: attach listener failed on respawn: %v", a.Name, err)
@@ -1721,6 +1904,7 @@ func (r *Registry) respawn(name string, gen uint64, checkGen bool) (*Agent, erro
 
 	r.agents[name] = a
 	log.Printf("agent %s: respawned pid=%d restart=%d", name, a.PID, restartCount)
+	restartsTotal.Inc(name)
 
 	// Re-arm the rename guard on the new pid; the old one's exit cleared it.
 	noteCoordinatorStart(a)
@@ -1762,6 +1946,8 @@ func (r *Registry) respawn(name string, gen uint64, checkGen bool) (*Agent, erro
 		}()
 	}
 
+	a.saveHolderRecord()
+
 	return a, nil
 }
 
@@ -1781,22 +1967,29 @@ func (r *Registry) respawn(name string, gen uint64, checkGen bool) (*Agent, erro
 // enough to span Node.js's read loop and Ink's re-render cycle (~16ms) without
 // noticeably slowing nudge throughput. Both the gap and the terminator come
 // from the provider's NudgeProfile (see provider.go).
+//
+// A stream-mode agent takes the message as one structured input message
+// instead, and there is no terminator to send (stream.go).
 func (a *Agent) Nudge(message string) error {
+	if a.stream != nil {
+		return a.streamInput(message)
+	}
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	if a.master == nil {
+	w := a.ptyLocked()
+	if w == nil {
 		return fmt.Errorf("agent %q has no PTY", a.Name)
 	}
 
 	if message != "" {
-		if _, err := a.master.WriteString(message); err != nil {
+		if _, err := w.WriteString(message); err != nil {
 			return fmt.Errorf("write to PTY: %w", err)
 		}
 		time.Sleep(a.nudge.SubmitDelay)
 	}
 
-	if _, err := a.master.WriteString(a.nudge.SubmitTerminator); err != nil {
+	if _, err := w.WriteString(a.nudge.SubmitTerminator); err != nil {
 		return fmt.Errorf("write submit to PTY: %w", err)
 	}
 	return nil
@@ -1857,10 +2050,11 @@ func (a *Agent) EventAgent() string {
 func (a *Agent) SendRaw(s string) error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	if a.master == nil {
+	w := a.ptyLocked()
+	if w == nil {
 		return fmt.Errorf("agent %q has no PTY", a.Name)
 	}
-	_, err := a.master.WriteString(s)
+	_, err := w.WriteString(s)
 	return err
 }
 
@@ -1885,18 +2079,35 @@ var readOutputStartHook func()
 // It fans out output to the ring buffer AND any active attach connections.
 func (a *Agent) readOutput() {
 	defer close(a.outputDone)
+	if a.recorder != nil {
+		defer a.recorder.Close()
+	}
 	if readOutputStartHook != nil {
 		readOutputStartHook()
 	}
 	// Announce attachment BEFORE the first Read: waitAndHandle holds the tty
 	// open until it sees this.
 	close(a.readerAttached)
+	var src io.Reader = a.master
+	if a.holder != nil {
+		src = a.holder
+	}
 	buf := make([]byte, 4096)
 	for {
-		n, err := a.master.Read(buf)
+		n, err := src.Read(buf)
 		if n > 0 {
 			data := buf[:n]
 			a.outputBuf.Write(data)
+			if a.screen != nil {
+				a.screen.Write(data)
+			}
+			if a.recorder != nil {
+				a.recorder.Write(data)
+			}
+			if a.stream != nil {
+				a.stream.feed(data)
+			}
+			a.noteOutput(time.Now())
 
 			// Fan out to attached connections
 			a.attachMu.Lock()
@@ -1961,7 +2172,11 @@ func (a *Agent) readOutput() {
 // every agent its own session and Setctty makes the slave its controlling
 // terminal, so a signal aimed at one agent's process group never reaches pogod
 // or a sibling. TestSpawnProcessGroupIsolation guards it.
-func startPTY(cmd *exec.Cmd, winsize *pty.Winsize) (master, slave *os.File, err error) {
+//
+// raw puts the tty in raw mode before the child starts, for a stream-mode
+// agent whose input and output are JSON lines rather than keystrokes and a
+// screen (stream.go).
+func startPTY(cmd *exec.Cmd, winsize *pty.Winsize, raw bool) (master, slave *os.File, err error) {
 	master, slave, err = pty.Open()
 	if err != nil {
 		return nil, nil, err
@@ -1973,6 +2188,13 @@ func startPTY(cmd *exec.Cmd, winsize *pty.Winsize) (master, slave *os.File, err
 			return nil, nil, err
 		}
 	}
+	if raw {
+		if _, err := term.MakeRaw(int(slave.Fd())); err != nil {
+			master.Close()
+			slave.Close()
+			return nil, nil, fmt.Errorf("raw mode: %w", err)
+		}
+	}
 	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
 	if cmd.SysProcAttr == nil {
 		cmd.SysProcAttr = &syscall.SysProcAttr{}
@@ -1987,6 +2209,79 @@ func startPTY(cmd *exec.Cmd, winsize *pty.Winsize) (master, slave *os.File, err
 	return master, slave, nil
 }
 
+// startProcessLocked starts cmd on a PTY and returns the child's pid. A direct
+// child comes back with the master and slave pogod holds (startPTY); with
+// SetPTYHolder in effect it comes back with the connection to the holder that
+// holds them instead. raw starts the PTY in raw mode (startPTY). A non-nil
+// profile confines the child: here for a direct child, in the holder for a
+// held one. Called with r.mu held.
+func (r *Registry) startProcessLocked(name string, cmd *exec.Cmd, winsize *pty.Winsize, raw bool, profile *sandbox.Profile, group *cgroup.Group) (pid int, master, slave *os.File, held *holderConn, err error) {
+	if r.holderExe != "" {
+		held, pid, err = r.startHolderLocked(name, cmd, winsize, raw, profile, group)
+		if err != nil {
+			return 0, nil, nil, nil, fmt.Errorf("holder start: %w", err)
+		}
+		return pid, nil, nil, held, nil
+	}
+	if profile != nil {
+		if err := sandbox.Apply(cmd, *profile); err != nil {
+			return 0, nil, nil, nil, err
+		}
+	}
+	group.Attach(cmd)
+	master, slave, err = startPTY(cmd, winsize, raw)
+	if err != nil {
+		return 0, nil, nil, nil, fmt.Errorf("pty start: %w", err)
+	}
+	return cmd.Process.Pid, master, slave, nil, nil
+}
+
+// ptyWriter is where an agent's input goes: its PTY master, or the connection
+// to its holder.
+type ptyWriter interface {
+	io.Writer
+	WriteString(s string) (int, error)
+}
+
+// ptyLocked returns a's ptyWriter, or nil once Cleanup has released it. Called
+// with a.mu held.
+func (a *Agent) ptyLocked() ptyWriter {
+	if a.holder != nil {
+		if a.holderClosed {
+			return nil
+		}
+		return a.holder
+	}
+	if a.master == nil {
+		return nil
+	}
+	return a.master
+}
+
+// signal sends sig to a's process: through exec for a direct child, and by pid
+// for a held one, which is its holder's child rather than pogod's.
+func (a *Agent) signal(sig os.Signal) error {
+	if a.holder != nil {
+		s, ok := sig.(syscall.Signal)
+		if !ok {
+			return fmt.Errorf("cannot send %v to a held agent", sig)
+		}
+		return syscall.Kill(a.PID, s)
+	}
+	return a.cmd.Process.Signal(sig)
+}
+
+// waitProcess blocks until a's process has exited and returns how. A holder
+// hangs up on pogod only after recording the child's exit, and readOutput
+// returns on that hangup.
+func (a *Agent) waitProcess() error {
+	if a.holder != nil {
+		<-a.outputDone
+		return a.holder.wait()
+	}
+	return a.cmd.Wait()
+}
+
 // readerAttachTimeout bounds how long waitAndHandle will hold the PTY open
 // waiting for readOutput to reach its first Read.
 //
@@ -2012,7 +2307,7 @@ func (a *Agent) closeSlave() {
 
 // waitAndHandle waits for the agent process to exit and fires the onExit callback.
 func (r *Registry) waitAndHandle(a *Agent) {
-	a.exitErr = a.cmd.Wait()
+	a.exitErr = a.waitProcess()
 
 	// The child is reaped, so nothing more can be written to this tty. Release
 	// the parent's slave fd: it was held so the child's exit could not discard
@@ -2043,8 +2338,10 @@ func (r *Registry) waitAndHandle(a *Agent) {
 	a.ExitTime = time.Now()
 	if a.exitErr != nil {
 		a.ExitCode = -1
-		if exitErr, ok := a.exitErr.(*exec.ExitError); ok {
-			a.ExitCode = exitErr.ExitCode()
+		// *exec.ExitError for a direct child, *HeldExitError for a held one.
+		var coded interface{ ExitCode() int }
+		if errors.As(a.exitErr, &coded) {
+			a.ExitCode = coded.ExitCode()
 		}
 	}
 	stopRequested := a.stopRequested
@@ -2055,6 +2352,8 @@ func (r *Registry) waitAndHandle(a *Agent) {
 
 	log.Printf("agent %s: exited (err=%v)", a.Name, a.exitErr)
 
+	a.setState(StateStopped, exitReason(stopRequested, exitCode), a.ExitTime)
+
 	// Disarm the rename guard: a stopped coordinator may be renamed (mg-cf9e).
 	noteCoordinatorExit(a)
 
@@ -2064,6 +2363,17 @@ func (r *Registry) waitAndHandle(a *Agent) {
 	noteWitnessExit(a)
 
 	a.emitExit(stopRequested, stopCause, exitCode, duration)
+	a.traceRun(stopRequested, stopCause, exitCode)
+
+	// The group goes with the process it was made for, before any respawn
+	// makes the next one.
+	removeCgroup(a)
+
+	// A held agent's exit has been seen, so there is nothing left for a later
+	// pogod to adopt.
+	if a.holder != nil {
+		a.holder.removeFiles()
+	}
 
 	// Fire onExit callback BEFORE closing done, so that callers waiting on
 	// Done() (e.g. Stop/StopAll during shutdown) block until cleanup
@@ -2079,6 +2389,18 @@ func (r *Registry) waitAndHandle(a *Agent) {
 	close(a.done)
 }
 
+// exitReason is the stopped state's reason for an exit.
+func exitReason(stopRequested bool, exitCode int) string {
+	switch {
+	case stopRequested:
+		return "stop requested"
+	case exitCode == 0:
+		return "exited"
+	default:
+		return fmt.Sprintf("exited with code %d", exitCode)
+	}
+}
+
 // emitExit records either agent_stopped (clean / requested exit) or
 // agent_crashed (unexpected exit). Best-effort: errors never propagate.
 func (a *Agent) emitExit(stopRequested bool, stopCause string, exitCode int, durationSeconds float64) {
@@ -2139,6 +2461,12 @@ func (a *Agent) Cleanup() {
 		a.slave.Close()
 		a.slave = nil
 	}
+	// Dropping the connection leaves the holder, and a child that is still
+	// running, to carry on without pogod.
+	if a.holder != nil && !a.holderClosed {
+		a.holder.Close()
+		a.holderClosed = true
+	}
 	a.retireListenerLocked()
 }
 
@@ -2520,7 +2848,7 @@ func (a *Agent) handleAttach(conn net.Conn) {
 	defer conn.Close()
 
 	a.mu.Lock()
-	master := a.master
+	master := a.ptyLocked()
 	a.mu.Unlock()
 
 	if master == nil {
@@ -2533,13 +2861,18 @@ func (a *Agent) handleAttach(conn net.Conn) {
 		conn.Write(recent)
 	}
 
-	// Register for output fanout (after replay, so we don't double-send)
+	// Register for output fanout (after replay, so we don't double-send),
+	// and as one of the agent's attached clients. Until it says hello the
+	// client is an anonymous read-write one that is told nothing; see
+	// attachclients.go.
 	a.attachMu.Lock()
 	a.attachConns[conn] = struct{}{}
 	a.attachMu.Unlock()
+	client := a.joinAttach("", AttachReadWrite, "socket", nil)
 
 	// Deregister on exit
 	defer func() {
+		a.leaveAttach(client)
 		a.attachMu.Lock()
 		delete(a.attachConns, conn)
 		a.attachMu.Unlock()
@@ -2573,13 +2906,44 @@ func (a *Agent) handleAttach(conn net.Conn) {
 	// Read input from conn and forward to PTY master.
 	// New clients send a leading FrameTypeResize byte to enter framed mode;
 	// legacy clients send raw bytes. See attach_proto.go for the wire format.
-	a.readAttachInput(conn, master)
+	a.readAttachInput(conn, master, client)
 }
 
 // readAttachInput dispatches a unix-socket attach connection to either
 // framed-mode parsing or legacy raw-byte streaming based on the first byte.
 // Blocks until conn closes (user detaches) or master closes (agent exits).
-func (a *Agent) readAttachInput(conn net.Conn, master io.Writer) {
+// Input and resizes pass the writer lock (attachclients.go) first.
+func (a *Agent) readAttachInput(conn net.Conn, master io.Writer, client *attachClient) {
+	serveAttachInput(conn, attachSink{
+		input: gatedInput{a: a, c: client, pty: master},
+		resize: func(cols, rows uint16) {
+			if a.attachMayResize(client) {
+				a.applyResize(cols, rows)
+			}
+		},
+		hello: func(h AttachHello) {
+			a.helloAttach(client, h, func(p AttachPresence) { conn.Write(presenceAPC(p)) })
+		},
+		takeover: func() { a.takeoverAttach(client) },
+	})
+}
+
+// attachSink is where serveAttachInput delivers what a client sends.
+type attachSink struct {
+	input  io.Writer
+	resize func(cols, rows uint16)
+	// hello and takeover receive FrameTypeHello and FrameTypeTakeover. A
+	// server with no multi-client state (the holder, whose one client is
+	// pogod) leaves them nil, and the frames are protocol errors there.
+	hello    func(AttachHello)
+	takeover func()
+}
+
+// serveAttachInput is readAttachInput for any PTY owner: pogod's agents, and
+// the holder process (holder.go), which serves the same protocol and applies
+// resizes its own way.
+func serveAttachInput(conn io.Reader, sink attachSink) {
+	master, resize := sink.input, sink.resize
 	br := bufio.NewReaderSize(conn, 4096)
 
 	first, err := br.ReadByte()
@@ -2600,7 +2964,7 @@ func (a *Agent) readAttachInput(conn net.Conn, master io.Writer) {
 	if _, err := io.ReadFull(br, hdr[:]); err != nil {
 		return
 	}
-	a.applyResize(binary.LittleEndian.Uint16(hdr[0:2]), binary.LittleEndian.Uint16(hdr[2:4]))
+	resize(binary.LittleEndian.Uint16(hdr[0:2]), binary.LittleEndian.Uint16(hdr[2:4]))
 
 	// Continue reading framed messages.
 	for {
@@ -2613,7 +2977,7 @@ func (a *Agent) readAttachInput(conn net.Conn, master io.Writer) {
 			if _, err := io.ReadFull(br, hdr[:]); err != nil {
 				return
 			}
-			a.applyResize(binary.LittleEndian.Uint16(hdr[0:2]), binary.LittleEndian.Uint16(hdr[2:4]))
+			resize(binary.LittleEndian.Uint16(hdr[0:2]), binary.LittleEndian.Uint16(hdr[2:4]))
 		case FrameTypeData:
 			var lenBuf [2]byte
 			if _, err := io.ReadFull(br, lenBuf[:]); err != nil {
@@ -2630,6 +2994,28 @@ func (a *Agent) readAttachInput(conn net.Conn, master io.Writer) {
 			if _, err := master.Write(data); err != nil {
 				return
 			}
+		case FrameTypeHello:
+			if sink.hello == nil {
+				return
+			}
+			var lenBuf [2]byte
+			if _, err := io.ReadFull(br, lenBuf[:]); err != nil {
+				return
+			}
+			data := make([]byte, binary.LittleEndian.Uint16(lenBuf[:]))
+			if _, err := io.ReadFull(br, data); err != nil {
+				return
+			}
+			var h AttachHello
+			if err := json.Unmarshal(data, &h); err != nil {
+				return
+			}
+			sink.hello(h)
+		case FrameTypeTakeover:
+			if sink.takeover == nil {
+				return
+			}
+			sink.takeover()
 		default:
 			// Unknown frame type — protocol error, drop the connection so the
 			// client can reconnect cleanly.
@@ -2664,8 +3050,21 @@ func (a *Agent) applyResize(cols, rows uint16) {
 	if cols == 0 || rows == 0 {
 		return
 	}
+	if a.screen != nil {
+		a.screen.Resize(int(cols), int(rows))
+	}
+	if a.recorder != nil {
+		a.recorder.Resize(int(cols), int(rows))
+	}
 	a.mu.Lock()
 	defer a.mu.Unlock()
+	if a.holder != nil {
+		// The holder owns the winsize and applies the same two rules.
+		if !a.holderClosed {
+			a.holder.writeResize(cols, rows)
+		}
+		return
+	}
 	master := a.master
 	if master == nil {
 		return
@@ -19,6 +19,7 @@ import (
 	"github.com/drellem2/pogo/internal/events"
 	"github.com/drellem2/pogo/internal/gitgc"
 	"github.com/drellem2/pogo/internal/hookarm"
+	"github.com/drellem2/pogo/internal/platform/sandbox"
 	"github.com/drellem2/pogo/internal/synthfail"
 )
 
@@ -43,6 +44,19 @@ type AgentInfo struct {
 	// and the harness's own configuration decides (mg-e7f5). Omitted when empty:
 	// absence here means "not pinned by pogo", which is the normal case.
 	Model string `json:"model,omitempty"`
+	// Sandbox is the profile the agent was confined to at spawn (user-012);
+	// omitted when it runs unconfined.
+	Sandbox *sandbox.Profile `json:"sandbox,omitempty"`
+	// Mode is how the agent's harness is driven, "tui" or "stream" (user-019);
+	// omitted for an agent from before modes existed, which is the TUI.
+	Mode string `json:"mode,omitempty"`
+	// Stream is what a stream-mode agent's harness has reported: whether it is
+	// in a turn, its turns, tool calls, errors and usage. Omitted in the TUI.
+	Stream *StreamStatus `json:"stream,omitempty"`
+	// State is the agent's lifecycle state (user-020): starting, healthy,
+	// idle, stalled or stopped, when it was entered and why. Omitted for an
+	// agent the registry did not start, which has none.
+	State *StateInfo `json:"state,omitempty"`
 	// RateLimited is true when the modal watcher has flagged the agent as
 	// suspected-usage-limited (rate-limit modal visible + event log stale). It
 	// is a distinct condition from idle/stalled: the agent is alive but wedged
@@ -72,6 +86,10 @@ type AgentInfo struct {
 	// run. A state with no reason gets argued with; a state carrying its own
 	// evidence gets acted on.
 	MailWarnDetail string `json:"mail_warn_detail,omitempty"`
+	// Attached lists the clients attached to the agent's terminal right now,
+	// and which of them holds the writer lock (user-016); omitted when nobody
+	// is attached.
+	Attached []AttachedClient `json:"attached,omitempty"`
 }
 
 // SpawnAPIRequest is the JSON body for POST /agents.
@@ -306,8 +324,9 @@ type DiagnoseInfo struct {
 	// destroying the transcript the diagnosis rests on.
 	RestartSuppressed bool `json:"restart_suppressed,omitempty"`
 
-	// Health is a summary string: "healthy", "idle", "stalled",
+	// Health is a summary string: "starting", "healthy", "idle", "stalled",
 	// "failing_turns", "rate_limited", "no_mail_loop", "exited", or "dead".
+	// The first four are the agent's lifecycle state (State, user-020).
 	//
 	// It is a TOKEN, not a sentence: every value here is a label a machine
 	// matches on, and several of them ("failing_turns" above all) are summaries
@@ -441,6 +460,19 @@ func diagnoseAgentAt(a *Agent, now time.Time, windows []CronWindow, mailLoop mai
 	cronCovered := idlePastThreshold && withinCronInterval(now, windows)
 	stalled := idlePastThreshold && !cronCovered
 
+	// The lifecycle machine (lifecycle.go) has the last word on the quiet end
+	// of the cascade: it is the only one that knows an agent with no output
+	// yet is starting rather than healthy, and that one starting for longer
+	// than StartingMaxFor its type is stalled. Projecting it to now keeps this
+	// report and the state the machine emits from disagreeing by up to a tick,
+	// without the report moving the machine itself. An agent built outside the
+	// registry has no state, and keeps the cascade's answer.
+	state := a.projectedState(now, windows)
+	if state.Name != "" {
+		stalled = state.Name == StateStalled
+		info.State = &state
+	}
+
 	// Determine overall health. "healthy" means the agent produced output within
 	// ActiveRecencyWindow (actively working); past that window but within the
 	// stall threshold it is "idle" (alive, between cycles); past the threshold it
@@ -490,7 +522,11 @@ func diagnoseAgentAt(a *Agent, now time.Time, windows []CronWindow, mailLoop mai
 		health = "no_mail_loop"
 	case stalled:
 		health = "stalled"
-	case !lastWrite.IsZero() && idleDur >= ActiveRecencyWindow:
+	case state.Name == StateStarting:
+		health = "starting"
+	case state.Name == StateIdle:
+		health = "idle"
+	case state.Name == "" && !lastWrite.IsZero() && idleDur >= ActiveRecencyWindow:
 		health = "idle"
 	}
 
@@ -560,6 +596,7 @@ func agentInfo(a *Agent) AgentInfo {
 		info.MailWarn = string(state)
 		info.MailWarnDetail = why
 	}
+	info.Attached = a.Attached()
 	return info
 }
 
@@ -585,8 +622,16 @@ func agentInfoLocked(a *Agent) (AgentInfo, string) {
 		Uptime:         agentUptime(a),
 		WorkItemID:     a.WorkItemID,
 		Model:          a.Model,
+		Sandbox:        a.Sandbox,
+		Mode:           a.Mode,
 		RateLimited:    a.RateLimited,
 	}
+	if st, ok := a.StreamStatus(); ok {
+		info.Stream = &st
+	}
+	if st := a.State(); st.Name != "" {
+		info.State = &st
+	}
 	if a.RateLimited {
 		info.RateLimitedSince = a.RateLimitedSince
 	}
@@ -656,6 +701,8 @@ func (r *Registry) routes() []route {
 		{"/agents/{name}/diagnose", r.handleDiagnose},
 		{"/agents/{name}/nudge", r.handleNudge},
 		{"/agents/{name}/output", r.handleOutput},
+		{"/agents/{name}/output/stream", r.handleOutputStream},
+		{"/agents/{name}/screen", r.handleScreen},
 		{"/agents/{name}/terminal", r.handleTerminal},
 	}
 }
@@ -1333,10 +1380,12 @@ func (r *Registry) StartCrewAgent(name string) (*Agent, error) {
 	// (nudge_on_start). A parse error is non-fatal: meta stays a usable zero
 	// value and the type defaults apply.
 	meta, _, _ := ParsePromptFrontmatter(promptFile)
-	var fmProvider, fmModel string
+	var fmProvider, fmModel, fmSandbox, fmMode string
 	if meta != nil {
 		fmProvider = meta.Provider
 		fmModel = meta.Model
+		fmSandbox = meta.Sandbox
+		fmMode = meta.Mode
 	}
 
 	// Resolve the harness provider for this crew agent. Precedence: provider:
@@ -1404,6 +1453,8 @@ func (r *Registry) StartCrewAgent(name string) (*Agent, error) {
 		RestartOnCrash: ResolveRestartOnCrashWithStub(stubFile, promptFile, TypeCrew),
 		Provider:       provider,
 		Model:          model,
+		Sandbox:        fmSandbox,
+		Mode:           fmMode,
 	})
 }
 
@@ -1479,6 +1530,12 @@ func (r *Registry) handleSpawnPolecat(w http.ResponseWriter, req *http.Request)
 		return
 	}
 
+	// Trace the dispatch from here (user-023): a request that did not decode
+	// has no work item to trace. w is wrapped so the span learns how the
+	// request ended from whichever failPolecatSpawn answers it.
+	trace, w := startDispatchTrace(w, spawnReq)
+	defer trace.finish()
+
 	// Drain gate: while the self-deploy driver is quiescing the fleet for a
 	// redeploy, refuse new polecat dispatch so the live count can reach zero
 	// (mg-6afa mechanism 3). 503 is retryable — the caller (mayor) can redispatch
@@ -1717,6 +1774,8 @@ func (r *Registry) handleSpawnPolecat(w http.ResponseWriter, req *http.Request)
 		return
 	}
 
+	trace.enter("dispatch.prepare")
+
 	// Validate before the worktree, agent dir, and expanded prompt file get
 	// created — a rejected name should leave nothing behind (mg-ef80).
 	if err := ValidateAgentName(spawnReq.Name); err != nil {
@@ -1860,9 +1919,10 @@ func (r *Registry) handleSpawnPolecat(w http.ResponseWriter, req *http.Request)
 	// gates count workers, so a worker whose toolchain self-parallelises can
 	// hold the whole box while the per-repo cap reads one-of-three — measured
 	// on 2026-08-12 at 9.0 of 10 cores from a single Lean build (mg-eb47). The
-	// budget is advice: it reaches the worker as env vars and as prompt prose,
-	// and nothing enforces it. See workerbudget.go for what that does and does
-	// not claim.
+	// budget reaches the worker as env vars and as prompt prose, and is
+	// enforced only where [dispatch] cgroup_root gives pogod a cgroup to hold
+	// it to (cgroups.go). See workerbudget.go for what that does and does not
+	// claim.
 	budget := r.WorkerBudget()
 
 	// The `declares-remainder` warning, prepended to the rendered prompt when
@@ -1910,6 +1970,8 @@ func (r *Registry) handleSpawnPolecat(w http.ResponseWriter, req *http.Request)
 	// polecatSpawnEnv, which owns it so the precedence can be asserted.
 	env := polecatSpawnEnv(budget, spawnReq.Env)
 
+	trace.enter("dispatch.worktree")
+
 	// Create git worktree for polecat isolation
 	if createWorktree {
 		sourceRepo = spawnReq.Repo
@@ -2032,6 +2094,7 @@ func (r *Registry) handleSpawnPolecat(w http.ResponseWriter, req *http.Request)
 	// item's own state and retrying it unchanged is refused identically forever.
 	// Everything else about a failed claim fails open; see
 	// MGWorkItemClaimer.ClaimForSpawn for why the two directions differ.
+	trace.enter("dispatch.claim")
 	claimVerdict, claimRefusal := r.claimForSpawn(spawnReq)
 	if claimRefusal != "" {
 		os.Remove(promptFile)
@@ -2047,6 +2110,7 @@ func (r *Registry) handleSpawnPolecat(w http.ResponseWriter, req *http.Request)
 	// on success and by releaseSpawnClaim on failure.
 	defer r.endSpawnClaim(spawnReq.Id)
 
+	trace.enter("dispatch.spawn")
 	a, err := r.Spawn(SpawnRequest{
 		Name:           spawnReq.Name,
 		Type:           TypePolecat,
@@ -2069,6 +2133,10 @@ func (r *Registry) handleSpawnPolecat(w http.ResponseWriter, req *http.Request)
 		ClaimedAtSpawn: claimVerdict.Held(),
 		Provider:       provider,
 		Model:          model,
+		Sandbox:        tmplMeta.Sandbox,
+		Mode:           tmplMeta.Mode,
+		Budget:         budget.withOverride(env),
+		TraceParent:    trace.parent(),
 	})
 	if err != nil {
 		os.Remove(promptFile) // Clean up temp file on spawn failure
@@ -0,0 +1,40 @@
+package agent
+
+import (
+	"log"
+
+	"github.com/drellem2/pogo/internal/apiauth"
+)
+
+// SetAPITokens sets the issuer every agent the registry starts gets its API
+// token from (user-021). pogod passes the one it verifies requests with; nil
+// issues none, and an agent without a token is whatever pogod makes of a
+// tokenless request.
+func (r *Registry) SetAPITokens(iss *apiauth.Issuer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.apiTokens = iss
+}
+
+// APIRole is the role an agent of type t is held to: a polecat is a polecat,
+// and every other agent — crew, the coordinator among them — is crew.
+func APIRole(t AgentType) apiauth.Role {
+	if t == TypePolecat {
+		return apiauth.RolePolecat
+	}
+	return apiauth.RoleCrew
+}
+
+// apiTokenLocked returns the token for a new process of agent name, or "" with
+// no issuer. Called with r.mu held.
+func (r *Registry) apiTokenLocked(name string, typ AgentType) string {
+	if r.apiTokens == nil {
+		return ""
+	}
+	token, err := r.apiTokens.Issue(APIRole(typ), name)
+	if err != nil {
+		log.Printf("agent %s: no API token: %v", name, err)
+		return ""
+	}
+	return token
+}
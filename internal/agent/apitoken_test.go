@@ -0,0 +1,58 @@
+package agent
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drellem2/pogo/internal/apiauth"
+)
+
+// Every agent the registry starts is handed a token for its own name in its
+// type's role.
+func TestSpawnHandsTheAgentItsAPIToken(t *testing.T) {
+	reg, err := NewRegistry(shortSocketDir(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reg.StopAll(2 * time.Second)
+	iss, err := apiauth.LoadIssuer(filepath.Join(t.TempDir(), "api.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.SetAPITokens(iss)
+
+	for _, tc := range []struct {
+		name string
+		typ  AgentType
+		role apiauth.Role
+	}{
+		{"tokcat", TypePolecat, apiauth.RolePolecat},
+		{"tokcrew", TypeCrew, apiauth.RoleCrew},
+	} {
+		a, err := reg.Spawn(SpawnRequest{
+			Name:    tc.name,
+			Type:    tc.typ,
+			Command: []string{"sh", "-c", `echo "token=$` + APITokenEnv + `="; sleep 30`},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var token string
+		deadline := time.Now().Add(5 * time.Second)
+		for token == "" && time.Now().Before(deadline) {
+			out := string(a.RecentOutput(4096))
+			if _, rest, ok := strings.Cut(out, "token="); ok {
+				if tok, _, ok := strings.Cut(rest, "="); ok {
+					token = tok
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		id, err := iss.Verify(token)
+		if err != nil || id != (apiauth.Identity{Role: tc.role, Subject: tc.name}) {
+			t.Errorf("%s: token %q verifies as %+v, %v", tc.name, token, id, err)
+		}
+	}
+}
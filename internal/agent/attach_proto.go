@@ -35,8 +35,25 @@ package agent
 // keystrokes, corrupting the target. See docs/investigations/pty-investigation-2026-05-09.md
 // for the rationale.
 //
-// Server → client traffic is unchanged: raw PTY output bytes, no framing.
+// Two more client frames serve multi-client attach (attachclients.go):
+//
+//	hello:    0x03 + len(u16 LE) + JSON AttachHello           (3+N bytes)
+//	takeover: 0x04                                            (1 byte)
+//
+// A client that wants a role other than read-write, a name in the presence
+// list or to be told who else is attached sends hello right after the
+// handshake; until it does it is an anonymous read-write client. An older
+// server drops the connection at the unknown frame type, so an --ro client
+// talking to one is disconnected rather than attached with a keyboard.
+// takeover moves the writer lock to the sending client.
+//
+// Server → client traffic is raw PTY output bytes, no framing. A client that
+// sent hello also receives AttachPresence updates in that stream, each
+// wrapped as an APC string (PresencePrefix … PresenceSuffix), and strips them
+// before its terminal sees them.
 const (
-	FrameTypeResize byte = 0x01
-	FrameTypeData   byte = 0x02
+	FrameTypeResize   byte = 0x01
+	FrameTypeData     byte = 0x02
+	FrameTypeHello    byte = 0x03
+	FrameTypeTakeover byte = 0x04
 )
@@ -0,0 +1,283 @@
+package agent
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Multi-client attach (user-016).
+//
+// Any number of clients may be attached to one agent at once — `pogo agent
+// attach` over the unix socket and browsers over /agents/{name}/terminal —
+// and every one of them sees the same output. Before this, every one of them
+// could also type: each was handed the PTY master, so two people attached
+// raced their keystrokes into the agent, and a demo or a pairing session was
+// one stray key away from typing into a live polecat's prompt.
+//
+// Who may type is now decided per agent, by a writer lock:
+//
+//   - A read-only client (AttachReadOnly: `pogo agent attach --ro`, or
+//     ?role=ro on the WebSocket) never types and never resizes. Its input is
+//     dropped, not queued.
+//   - A read-write client takes the lock by typing when nobody holds it, and
+//     keeps it until it detaches or another read-write client takes it over
+//     (FrameTypeTakeover, Ctrl-] in `pogo agent attach`). Input from a
+//     read-write client that does not hold the lock is dropped.
+//   - Only the writer resizes the PTY, or any read-write client while nobody
+//     holds the lock. An observer whose window is a different size must not
+//     make the agent's TUI redraw for everyone else.
+//
+// The lock is taken by input rather than by attaching so that the common case
+// — one person, attached — behaves exactly as it always did, and so that a
+// client that attached and never typed does not hold the keyboard away from
+// the next one. Clients that predate this protocol take part as read-write
+// clients; they are simply not told who else is there.
+//
+// Every client that said hello (FrameTypeHello, or any WebSocket client) is
+// sent an AttachPresence whenever somebody attaches, detaches or takes the
+// lock, so a client can show who else is watching and who holds the keyboard.
+// Agent.Attached reports the same list, and /agents carries it, so `pogo agent
+// attach` can print it before the session starts.
+//
+// An agent in stream mode (user-019) takes no typing at all: its PTY carries
+// newline-delimited JSON, written by streamInput, and a keystroke would land
+// in the harness's stdin as a malformed message — or in the middle of one.
+// Every client attaches to it read-only, whatever role it asked for.
+
+// Attach roles.
+const (
+	AttachReadWrite = "rw"
+	AttachReadOnly  = "ro"
+)
+
+// AttachHello is the payload of a FrameTypeHello frame: who the client is and
+// the role it attaches in. An unknown Role is read as AttachReadWrite.
+type AttachHello struct {
+	Role string `json:"role"`
+	Who  string `json:"who,omitempty"`
+	// Takeover asks for the writer lock at once, as FrameTypeTakeover would.
+	Takeover bool `json:"takeover,omitempty"`
+}
+
+// AttachedClient describes one client attached to an agent.
+type AttachedClient struct {
+	// ID is unique among this agent's clients for the life of the process.
+	ID int `json:"id"`
+	// Who is what the client said it was (user@host for `pogo agent attach`),
+	// or the peer address for a WebSocket that did not say.
+	Who  string `json:"who"`
+	Role string `json:"role"`
+	// Via is "socket" or "websocket".
+	Via    string    `json:"via"`
+	Since  time.Time `json:"since"`
+	Writer bool      `json:"writer,omitempty"`
+}
+
+// AttachPresence is what a client is told when the set of attached clients or
+// the writer changes.
+type AttachPresence struct {
+	Agent string `json:"agent"`
+	// You is the receiving client's ID.
+	You int `json:"you"`
+	// Writer is the ID of the client holding the writer lock, or 0.
+	Writer  int              `json:"writer"`
+	Clients []AttachedClient `json:"clients"`
+}
+
+// PresencePrefix and PresenceSuffix bracket an AttachPresence on the attach
+// socket. Output on that socket is the PTY's raw bytes, so presence rides in
+// the stream as an APC string — which a terminal that is handed one anyway
+// ignores — and a client that sent a hello strips it before the terminal
+// sees it. The JSON carries no ESC byte (encoding/json escapes control
+// characters), so the suffix cannot occur inside it.
+const (
+	PresencePrefix = "\x1b_pogo-attach "
+	PresenceSuffix = "\x1b\\"
+)
+
+// attachClient is one attached client. Its fields change only under
+// a.attachMu; info.Writer is not kept here but computed from a.attachWriter.
+type attachClient struct {
+	info AttachedClient
+	// presence, when set, is how this client is told of changes. Nil for a
+	// client that did not say hello.
+	presence func(AttachPresence)
+}
+
+// joinAttach registers a client. presence may be nil.
+func (a *Agent) joinAttach(who, role, via string, presence func(AttachPresence)) *attachClient {
+	a.attachMu.Lock()
+	a.attachNextID++
+	c := &attachClient{
+		info:     AttachedClient{ID: a.attachNextID, Who: who, Role: a.attachRole(role), Via: via, Since: time.Now()},
+		presence: presence,
+	}
+	a.attachClients = append(a.attachClients, c)
+	a.attachMu.Unlock()
+	a.broadcastPresence()
+	return c
+}
+
+// leaveAttach deregisters c, releasing the writer lock if it held it.
+func (a *Agent) leaveAttach(c *attachClient) {
+	a.attachMu.Lock()
+	for i, x := range a.attachClients {
+		if x == c {
+			a.attachClients = append(a.attachClients[:i], a.attachClients[i+1:]...)
+			break
+		}
+	}
+	if a.attachWriter == c {
+		a.attachWriter = nil
+	}
+	a.attachMu.Unlock()
+	a.broadcastPresence()
+}
+
+// helloAttach applies a client's hello: its name, its role, whether it wants
+// the lock, and how it is to be told of changes from now on.
+func (a *Agent) helloAttach(c *attachClient, h AttachHello, presence func(AttachPresence)) {
+	a.attachMu.Lock()
+	if h.Who != "" {
+		c.info.Who = h.Who
+	}
+	c.info.Role = a.attachRole(h.Role)
+	c.presence = presence
+	if c.info.Role == AttachReadOnly && a.attachWriter == c {
+		a.attachWriter = nil
+	}
+	if h.Takeover && c.info.Role == AttachReadWrite {
+		a.attachWriter = c
+	}
+	a.attachMu.Unlock()
+	a.broadcastPresence()
+}
+
+// takeoverAttach gives c the writer lock. A read-only client cannot take it.
+func (a *Agent) takeoverAttach(c *attachClient) {
+	a.attachMu.Lock()
+	changed := c.info.Role == AttachReadWrite && a.attachWriter != c
+	if changed {
+		a.attachWriter = c
+	}
+	a.attachMu.Unlock()
+	if changed {
+		a.broadcastPresence()
+	}
+}
+
+// attachMayWrite reports whether c's input reaches the PTY, taking the free
+// lock for a read-write client.
+func (a *Agent) attachMayWrite(c *attachClient) bool {
+	a.attachMu.Lock()
+	if c.info.Role != AttachReadWrite {
+		a.attachMu.Unlock()
+		return false
+	}
+	if a.attachWriter == c {
+		a.attachMu.Unlock()
+		return true
+	}
+	if a.attachWriter != nil {
+		a.attachMu.Unlock()
+		return false
+	}
+	a.attachWriter = c
+	a.attachMu.Unlock()
+	a.broadcastPresence()
+	return true
+}
+
+// attachMayResize reports whether c's resizes reach the PTY.
+func (a *Agent) attachMayResize(c *attachClient) bool {
+	a.attachMu.Lock()
+	defer a.attachMu.Unlock()
+	return c.info.Role == AttachReadWrite && (a.attachWriter == nil || a.attachWriter == c)
+}
+
+// Attached returns the clients attached to the agent now, in the order they
+// attached.
+func (a *Agent) Attached() []AttachedClient {
+	a.attachMu.Lock()
+	defer a.attachMu.Unlock()
+	return a.attachedLocked()
+}
+
+func (a *Agent) attachedLocked() []AttachedClient {
+	if len(a.attachClients) == 0 {
+		return nil
+	}
+	out := make([]AttachedClient, len(a.attachClients))
+	for i, c := range a.attachClients {
+		out[i] = c.info
+		out[i].Writer = c == a.attachWriter
+	}
+	return out
+}
+
+// broadcastPresence tells every client that said hello who is attached now.
+// The sends happen outside attachMu: a slow client delays the others' notice,
+// never the agent's output.
+func (a *Agent) broadcastPresence() {
+	a.attachMu.Lock()
+	clients := a.attachedLocked()
+	writer := 0
+	if a.attachWriter != nil {
+		writer = a.attachWriter.info.ID
+	}
+	type send struct {
+		fn func(AttachPresence)
+		id int
+	}
+	var sends []send
+	for _, c := range a.attachClients {
+		if c.presence != nil {
+			sends = append(sends, send{c.presence, c.info.ID})
+		}
+	}
+	a.attachMu.Unlock()
+	for _, s := range sends {
+		s.fn(AttachPresence{Agent: a.Name, You: s.id, Writer: writer, Clients: clients})
+	}
+}
+
+// gatedInput is a client's input path to the PTY: its writes reach pty only
+// while the client may write, and are otherwise dropped.
+type gatedInput struct {
+	a   *Agent
+	c   *attachClient
+	pty io.Writer
+}
+
+func (g gatedInput) Write(p []byte) (int, error) {
+	if !g.a.attachMayWrite(g.c) {
+		return len(p), nil
+	}
+	return g.pty.Write(p)
+}
+
+// presenceAPC returns p framed for the attach socket; see PresencePrefix.
+func presenceAPC(p AttachPresence) []byte {
+	data, _ := json.Marshal(p)
+	out := make([]byte, 0, len(PresencePrefix)+len(data)+len(PresenceSuffix))
+	out = append(out, PresencePrefix...)
+	out = append(out, data...)
+	return append(out, PresenceSuffix...)
+}
+
+// attachRole is the role a client asking for role gets on this agent: the one
+// it asked for, or AttachReadOnly on an agent in stream mode.
+func (a *Agent) attachRole(role string) string {
+	if a.stream != nil {
+		return AttachReadOnly
+	}
+	return normalizeRole(role)
+}
+
+func normalizeRole(role string) string {
+	if role == AttachReadOnly {
+		return AttachReadOnly
+	}
+	return AttachReadWrite
+}
@@ -0,0 +1,151 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Coverage for multi-client attach (user-016): several clients on one `cat`
+// agent, where whatever reaches the PTY is echoed back, so dropped input is
+// directly observable as missing output.
+
+func helloFrame(h AttachHello) []byte {
+	data, _ := json.Marshal(h)
+	frame := make([]byte, 3, 3+len(data))
+	frame[0] = FrameTypeHello
+	binary.LittleEndian.PutUint16(frame[1:3], uint16(len(data)))
+	return append(frame, data...)
+}
+
+// connReader collects everything a client is sent.
+type connReader struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func readAll(conn net.Conn) *connReader {
+	r := &connReader{}
+	go func() {
+		b := make([]byte, 4096)
+		for {
+			n, err := conn.Read(b)
+			r.mu.Lock()
+			r.buf.Write(b[:n])
+			r.mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return r
+}
+
+// lastPresence returns the last complete presence update received, if any.
+func (r *connReader) lastPresence() (AttachPresence, bool) {
+	r.mu.Lock()
+	s := r.buf.String()
+	r.mu.Unlock()
+	var p AttachPresence
+	i := strings.LastIndex(s, PresencePrefix)
+	if i < 0 {
+		return p, false
+	}
+	body := s[i+len(PresencePrefix):]
+	j := strings.Index(body, PresenceSuffix)
+	if j < 0 {
+		return p, false
+	}
+	return p, json.Unmarshal([]byte(body[:j]), &p) == nil
+}
+
+func attachedWith(a *Agent, ok func([]AttachedClient) bool) []AttachedClient {
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got := a.Attached()
+		if ok(got) || time.Now().After(deadline) {
+			return got
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// An observer's keystrokes and resizes never reach the agent, only the writer
+// types, a second read-write client's input is dropped until it takes the
+// lock over, and everyone who said hello is told who holds it.
+func TestAttachWriterLockAndObservers(t *testing.T) {
+	a := spawnAgent(t, "multi-attach", "cat")
+
+	// Each client is attached before the next dials, so they are listed in
+	// this order.
+	dial := func(h AttachHello) net.Conn {
+		n := len(a.Attached())
+		conn := dialFramed(t, a, 0, 0)
+		t.Cleanup(func() { conn.Close() })
+		conn.Write(helloFrame(h))
+		attachedWith(a, func(cs []AttachedClient) bool { return len(cs) == n+1 && cs[n].Who == h.Who })
+		return conn
+	}
+	ro := dial(AttachHello{Role: AttachReadOnly, Who: "watcher"})
+	roOut := readAll(ro)
+	alice := dial(AttachHello{Who: "alice"})
+	bob := dial(AttachHello{Who: "bob"})
+
+	clients := a.Attached()
+	if len(clients) != 3 || clients[0].Role != AttachReadOnly || clients[2].Who != "bob" {
+		t.Fatalf("attached = %+v, want watcher, alice and bob", clients)
+	}
+	bobID := clients[2].ID
+
+	ro.Write(dataFrame([]byte("observer-typed\n")))
+	ro.Write(resizeFrame(150, 45))
+	alice.Write(dataFrame([]byte("alpha\n")))
+	if got := waitForOutput(a, "alpha", 2*time.Second); !strings.Contains(got, "alpha") {
+		t.Fatalf("the first read-write client's input did not reach the agent: %q", got)
+	}
+	if cs := attachedWith(a, func(cs []AttachedClient) bool { return cs[1].Writer }); !cs[1].Writer {
+		t.Errorf("alice typed first but does not hold the lock: %+v", cs)
+	}
+
+	bob.Write(dataFrame([]byte("bravo\n")))
+	bob.Write([]byte{FrameTypeTakeover})
+	bob.Write(dataFrame([]byte("charlie\n")))
+	got := waitForOutput(a, "charlie", 2*time.Second)
+	if !strings.Contains(got, "charlie") {
+		t.Fatalf("input after takeover did not reach the agent: %q", got)
+	}
+	if strings.Contains(got, "bravo") {
+		t.Errorf("input from a client without the lock reached the agent: %q", got)
+	}
+	if strings.Contains(got, "observer-typed") {
+		t.Errorf("an observer's input reached the agent: %q", got)
+	}
+	if cols, rows := waitForPTYSize(t, a, 150, 45, 300*time.Millisecond); cols == 150 && rows == 45 {
+		t.Error("an observer resized the agent's PTY")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		p, ok := roOut.lastPresence()
+		if ok && p.Writer == bobID && len(p.Clients) == 3 && p.You == clients[0].ID {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("observer's last presence = %+v (ok=%v), want bob as writer", p, ok)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The writer leaving frees the lock for the next one to type.
+	bob.Close()
+	attachedWith(a, func(cs []AttachedClient) bool { return len(cs) == 2 })
+	alice.Write(dataFrame([]byte("delta\n")))
+	if got := waitForOutput(a, "delta", 2*time.Second); !strings.Contains(got, "delta") {
+		t.Errorf("the lock was not freed when its holder detached: %q", got)
+	}
+}
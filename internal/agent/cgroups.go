@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+
+	"github.com/drellem2/pogo/internal/platform/cgroup"
+)
+
+// Cgroups for polecats (user-013).
+//
+// The worker budget was advice and said so (workerbudget.go): a toolchain
+// that ignored $POGO_WORKER_CORES took the box exactly as before, and the host
+// gate noticed only once it was full. With [dispatch] cgroup_root set, each
+// polecat is started in a group of its own under that delegated subtree —
+// polecat-<name> — whose cpu.max, memory.max and pids.max come from the budget
+// it was told. A Lean build that self-parallelises into eleven compilers still
+// gets one worker's share of the CPU, divided eleven ways.
+//
+// Only polecats. Crew agents are long-lived and few, their load is a model
+// harness rather than a build, and a limit sized for a worker would be wrong
+// for them. The refinery places its gates in groups of their own from the same
+// subtree (internal/refinery/gateexec.go).
+//
+// A polecat whose group cannot be made is not started: the operator asked for
+// the budget to be enforced, and an unenforced worker is the outcome that
+// asked-for control exists to prevent. Whether the subtree is usable at all is
+// checked once, when pogod opens it, so this is not a per-spawn hazard on a
+// host that cannot enforce — such a host runs with the budget advisory and a
+// boot log line saying why.
+
+// cgroupPidsPerCore sizes pids.max. Generous: the limit is there to stop a
+// fork bomb, not a parallel build, and a build's process count scales with
+// the cores it was given.
+const cgroupPidsPerCore = 512
+
+// CgroupLimits is b as the limits of the group a worker is started in: its
+// cores as CPU bandwidth, the same share of hostMemory, and pids in
+// proportion. Nothing is limited for a budget that could not be derived, and
+// memory is not limited when hostMemory is unknown.
+func (b WorkerBudget) CgroupLimits(hostMemory int64) cgroup.Limits {
+	if !b.Known() {
+		return cgroup.Limits{}
+	}
+	l := cgroup.Limits{Cores: b.Cores, Pids: b.Cores * cgroupPidsPerCore}
+	if hostMemory > 0 {
+		l.MemoryBytes = hostMemory / int64(b.HostCores) * int64(b.Cores)
+	}
+	return l
+}
+
+// SetCgroups sets the delegated subtree polecats are placed under. pogod
+// opens it from [dispatch] cgroup_root; nil, the default, places nothing and
+// leaves the budget advisory.
+func (r *Registry) SetCgroups(m *cgroup.Manager) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cgroups = m
+}
+
+// Cgroups returns the subtree polecats are placed under, or nil.
+func (r *Registry) Cgroups() *cgroup.Manager {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cgroups
+}
+
+// createCgroupLocked makes the group an agent called name is started in, and
+// returns it with the limits it was given — nil and nil for an agent that is
+// not placed in one. limits, when set, is what a respawn carries over;
+// otherwise they are derived from budget, or from the registry's own division
+// when budget is unknown. Called with r.mu held.
+func (r *Registry) createCgroupLocked(name string, t AgentType, budget WorkerBudget, limits *cgroup.Limits) (*cgroup.Group, *cgroup.Limits, error) {
+	if r.cgroups == nil || t != TypePolecat {
+		return nil, nil, nil
+	}
+	if limits == nil {
+		if !budget.Known() {
+			// Not r.WorkerBudget(): that takes r.mu, which is held.
+			budget = WorkerBudgetFor(runtime.NumCPU(), r.dispatchCap)
+		}
+		l := budget.CgroupLimits(cgroup.HostMemory())
+		limits = &l
+	}
+	g, err := r.cgroups.Create(string(t)+"-"+name, *limits)
+	if err != nil {
+		return nil, nil, fmt.Errorf("agent %s: %w", name, err)
+	}
+	log.Printf("agent %s: cgroup %s (%s)", name, g.Path, limits)
+	return g, limits, nil
+}
+
+// cgroupPath is g's directory, or "" for none.
+func cgroupPath(g *cgroup.Group) string {
+	if g == nil {
+		return ""
+	}
+	return g.Path
+}
+
+// discardCgroup removes a group whose process never started.
+func discardCgroup(g *cgroup.Group) {
+	if g == nil {
+		return
+	}
+	g.Close()
+	if err := cgroup.Remove(g.Path); err != nil {
+		log.Printf("cgroup: %v", err)
+	}
+}
+
+// removeCgroup removes a's group once a has exited, killing anything it left
+// running there.
+func removeCgroup(a *Agent) {
+	if a.Cgroup == "" {
+		return
+	}
+	if err := cgroup.Remove(a.Cgroup); err != nil {
+		log.Printf("agent %s: %v", a.Name, err)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/drellem2/pogo/internal/hostload"
+	"github.com/drellem2/pogo/internal/platform/cgroup"
 )
 
 // LoadGate answers, at the moment of dispatch, whether this host has room for
@@ -72,6 +73,10 @@ type HostLoadGate struct {
 	// Timeout bounds the sample. A dispatch decision must not hang on an
 	// observability call.
 	Timeout time.Duration
+	// Split, when set, names the pids whose subtrees the sample also reports
+	// one by one (hostload.Sample.Subtrees): the running agents, so
+	// /agents/hostload can say which agent the fleet's cores are going to.
+	Split func() []int
 }
 
 // DispatchLoad implements LoadGate.
@@ -80,6 +85,11 @@ func (g HostLoadGate) DispatchLoad() (hostload.Sample, bool) {
 	if rd == nil {
 		rd = &hostload.Reader{Roots: []int{os.Getpid()}}
 	}
+	if g.Split != nil {
+		split := *rd
+		split.Split = g.Split()
+		rd = &split
+	}
 	timeout := g.Timeout
 	if timeout <= 0 {
 		timeout = 5 * time.Second
@@ -106,11 +116,33 @@ func (r *Registry) getLoadGate() LoadGate {
 	g := r.loadGate
 	r.mu.RUnlock()
 	if g == nil {
-		return HostLoadGate{}
+		return HostLoadGate{Split: r.agentPIDs}
 	}
 	return g
 }
 
+// agentPIDs returns the pids of the agents that are running.
+func (r *Registry) agentPIDs() []int {
+	var pids []int
+	for pid := range r.agentsByPID() {
+		pids = append(pids, pid)
+	}
+	return pids
+}
+
+// agentsByPID maps each running agent's pid to its name.
+func (r *Registry) agentsByPID() map[int]string {
+	out := map[int]string{}
+	for _, a := range r.List() {
+		a.mu.Lock()
+		if a.Status == StatusRunning && a.PID > 0 {
+			out[a.PID] = a.Name
+		}
+		a.mu.Unlock()
+	}
+	return out
+}
+
 // loadGateRefusal returns the refusal message when this host has no room for
 // another worker, or "" when dispatch may proceed.
 //
@@ -179,6 +211,22 @@ type HostLoadResponse struct {
 	// division of the core count, unaffected by what the host is doing right
 	// now. See WorkerBudgetFor.
 	WorkerBudget WorkerBudget `json:"worker_budget"`
+
+	// Cgroups is what each polecat and refinery gate is using against the
+	// limits it was started under, when pogod enforces the budget with cgroups
+	// (cgroups.go, user-013). Absent when it does not. A cumulative reading
+	// rather than a rate — ThrottledSeconds rising is the limit at work.
+	Cgroups []cgroup.Usage `json:"cgroups,omitempty"`
+	// CgroupRoot is the subtree they were read from, and CgroupErr why they
+	// could not be; a failed read is not an empty subtree.
+	CgroupRoot string `json:"cgroup_root,omitempty"`
+	CgroupErr  string `json:"cgroup_error,omitempty"`
+
+	// AgentCores is the cores each running agent's process subtree used over
+	// the sample's window, by agent name (user-025). It is FleetCores broken
+	// down, for `pogo top`; pogod's own work, such as refinery gates, is in
+	// FleetCores and under no agent.
+	AgentCores map[string]float64 `json:"agent_cores,omitempty"`
 }
 
 // handleHostLoad serves the host's current fleet-attributable CPU.
@@ -203,6 +251,23 @@ func (r *Registry) handleHostLoad(w http.ResponseWriter, req *http.Request) {
 		occ := r.RepoOccupancyFor(repo)
 		resp.RepoOccupancy = &occ
 	}
+	if m := r.Cgroups(); m != nil {
+		resp.CgroupRoot = m.Root()
+		if usage, err := m.Usage(); err != nil {
+			resp.CgroupErr = err.Error()
+		} else {
+			resp.Cgroups = usage
+		}
+	}
+	if len(s.Subtrees) > 0 {
+		names := r.agentsByPID()
+		resp.AgentCores = make(map[string]float64, len(s.Subtrees))
+		for pid, cores := range s.Subtrees {
+			if name, ok := names[pid]; ok {
+				resp.AgentCores[name] = cores
+			}
+		}
+	}
 	if ok {
 		resp.Advice = s.DispatchAdvice()
 		resp.FleetHeavy = s.FleetHeavy()
@@ -215,6 +215,31 @@ func TestHostLoadEndpointAgreesWithTheGate(t *testing.T) {
 	}
 }
 
+// TestHostLoadEndpointNamesEachAgentsCores: `pogo top` shows each agent's
+// share of the fleet's CPU, so the sample's per-pid subtrees come back by agent
+// name, and a pid that is no running agent's is left out.
+func TestHostLoadEndpointNamesEachAgentsCores(t *testing.T) {
+	reg := newDrainTestRegistry(t)
+	reg.agents["cat"] = &Agent{Name: "cat", Type: TypePolecat, PID: 4242, Status: StatusRunning, done: make(chan struct{})}
+	reg.agents["gone"] = &Agent{Name: "gone", Type: TypePolecat, PID: 4343, Status: StatusExited, done: make(chan struct{})}
+	if got := reg.agentPIDs(); len(got) != 1 || got[0] != 4242 {
+		t.Errorf("agentPIDs = %v, want [4242]: only running agents are split out", got)
+	}
+	s := fiveIdleWorkers()
+	s.Subtrees = map[int]float64{4242: 0.3, 4343: 0.1}
+	reg.SetLoadGate(&fakeLoadGate{sample: s, ok: true})
+
+	rr := httptest.NewRecorder()
+	reg.handleHostLoad(rr, httptest.NewRequest("GET", "/agents/hostload", nil))
+	var resp HostLoadResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.AgentCores) != 1 || resp.AgentCores["cat"] != 0.3 {
+		t.Errorf("agent_cores = %v, want cat at 0.3 and nothing else", resp.AgentCores)
+	}
+}
+
 func TestHostLoadEndpointRejectsNonGET(t *testing.T) {
 	reg := newDrainTestRegistry(t)
 	rr := httptest.NewRecorder()
@@ -0,0 +1,717 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/creack/pty"
+
+	"github.com/drellem2/pogo/internal/events"
+	"github.com/drellem2/pogo/internal/platform/cgroup"
+	"github.com/drellem2/pogo/internal/platform/sandbox"
+)
+
+// The PTY holder (user-011).
+//
+// By default pogod is every agent's parent and holds its PTY master, so
+// pogod's death hangs the whole fleet up — every nightly restart and every
+// `pogo server stop` kills every agent mid-turn (ARCHITECTURE.md, "pogod does
+// not stop its agents when it shuts down — it hangs them up"). With
+// [agents] pty_holder = true each agent is instead started under a holder: a
+// small process — pogod's own binary, re-executed with HolderArg — that owns
+// the PTY and the child, and outlives pogod. The holder
+//
+//   - runs in its own session, so nothing aimed at pogod's process group
+//     reaches it, and holds the PTY master, so the child keeps its terminal
+//     when pogod goes away;
+//   - keeps the last OutputRingBytes of output, as Agent.outputBuf does;
+//   - serves the framed attach protocol (attach_proto.go) on
+//     <socketDir>/<name>.hold, replaying its ring to every client on connect;
+//   - writes the child's exit status to <socketDir>/<name>.hold.exit when the
+//     child exits, and then exits itself.
+//
+// pogod is one client of that socket. A held Agent reads its output from the
+// connection where a direct one reads the master, writes input as data frames
+// and forwards resizes as resize frames; everything above readOutput — the
+// ring, attach fanout, nudges, idle detection — is the same for both. pogod
+// records what it knows about the agent beside the socket, in
+// <socketDir>/<name>.hold.json, so that a restarted pogod can adopt it
+// (AdoptHeld): reconnect, refill the ring from the holder's replay, and
+// register the agent again under its original pid, start time and metadata.
+//
+// Each file has one writer. pogod writes the record and removes all three once
+// it has seen the agent exit; the holder writes the exit file and removes only
+// its socket.
+
+// HolderArg is the argv[1] that makes pogod's binary run as a PTY holder
+// instead of the daemon. It is not a user-facing command: Registry starts it.
+const HolderArg = "__pty-holder"
+
+// holderStartTimeout bounds how long startHolder waits for a new holder to
+// report its child's pid.
+const holderStartTimeout = 10 * time.Second
+
+// holderSpec is what pogod hands a new holder on its stdin.
+type holderSpec struct {
+	Command []string `json:"command"`
+	Dir     string   `json:"dir,omitempty"`
+	Env     []string `json:"env"`
+	Cols    uint16   `json:"cols,omitempty"`
+	Rows    uint16   `json:"rows,omitempty"`
+	// Socket is where the holder serves attach; ExitFile is where it records
+	// the child's exit.
+	Socket   string `json:"socket"`
+	ExitFile string `json:"exit_file"`
+	// Sandbox is the profile the holder confines the child to, if any.
+	Sandbox *sandbox.Profile `json:"sandbox,omitempty"`
+	// Raw starts the PTY in raw mode, for a stream-mode agent.
+	Raw bool `json:"raw,omitempty"`
+}
+
+// holderReply is the one line a holder writes to its stdout once the child has
+// started, or failed to.
+type holderReply struct {
+	PID   int    `json:"pid,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// holderExit is the holder's record of how the child exited.
+type holderExit struct {
+	ExitCode int       `json:"exit_code"`
+	ExitTime time.Time `json:"exit_time"`
+	// Error is the wait error's text, empty on a clean exit.
+	Error string `json:"error,omitempty"`
+}
+
+// holderRecord is pogod's record of a held agent: everything AdoptHeld needs to
+// register it again. Agent carries the exported metadata; the rest is what the
+// Agent keeps unexported.
+type holderRecord struct {
+	HolderPID    int    `json:"holder_pid"`
+	Agent        *Agent `json:"agent"`
+	Provider     string `json:"provider,omitempty"`
+	ReceiptFile  string `json:"receipt_file,omitempty"`
+	InitialNudge string `json:"initial_nudge,omitempty"`
+}
+
+// holderPaths are the holder socket, pogod's record and the holder's exit file
+// for the agent called name.
+func holderPaths(socketDir, name string) (socket, record, exit string) {
+	base := filepath.Join(socketDir, name+".hold")
+	return base, base + ".json", base + ".exit"
+}
+
+// holderConn is pogod's end of a held agent's PTY: the connection to its
+// holder. It is what readOutput reads and what input is written to in place of
+// the PTY master.
+type holderConn struct {
+	conn      net.Conn
+	holderPID int
+	socket    string
+	record    string
+	exitFile  string
+
+	// wmu keeps one frame's header and payload together when two writers —
+	// a nudge and an attached user, say — write at once.
+	wmu sync.Mutex
+}
+
+// dialHolder connects to the holder serving socket and completes the framed
+// handshake. The handshake carries no size, so connecting never resizes the
+// agent.
+func dialHolder(socket, record, exitFile string, holderPID int) (*holderConn, error) {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	h := &holderConn{conn: conn, holderPID: holderPID, socket: socket, record: record, exitFile: exitFile}
+	if err := h.writeResize(0, 0); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *holderConn) Read(p []byte) (int, error) {
+	return h.conn.Read(p)
+}
+
+// Write sends p to the holder as data frames, which it writes to the PTY.
+func (h *holderConn) Write(p []byte) (int, error) {
+	const maxChunk = 65535
+	h.wmu.Lock()
+	defer h.wmu.Unlock()
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), maxChunk)
+		var hdr [3]byte
+		hdr[0] = FrameTypeData
+		binary.LittleEndian.PutUint16(hdr[1:3], uint16(n))
+		if _, err := h.conn.Write(hdr[:]); err != nil {
+			return written, err
+		}
+		if _, err := h.conn.Write(p[:n]); err != nil {
+			return written, err
+		}
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (h *holderConn) WriteString(s string) (int, error) {
+	return h.Write([]byte(s))
+}
+
+// writeResize forwards a resize to the holder, which applies it with the same
+// rules applyResize does.
+func (h *holderConn) writeResize(cols, rows uint16) error {
+	var frame [5]byte
+	frame[0] = FrameTypeResize
+	binary.LittleEndian.PutUint16(frame[1:3], cols)
+	binary.LittleEndian.PutUint16(frame[3:5], rows)
+	h.wmu.Lock()
+	defer h.wmu.Unlock()
+	_, err := h.conn.Write(frame[:])
+	return err
+}
+
+// Close drops pogod's connection. The holder and the agent carry on; this is
+// also what happens, without anyone calling it, when pogod dies.
+func (h *holderConn) Close() error {
+	return h.conn.Close()
+}
+
+// wait returns how the child exited once pogod's connection has reached EOF:
+// the holder closes every connection only after writing the exit file. A
+// connection lost any other way leaves no exit file, and is reported as an
+// error rather than as a clean exit, since pogod cannot know what the child
+// did.
+func (h *holderConn) wait() error {
+	ex, err := readHolderExit(h.exitFile)
+	if err != nil {
+		return fmt.Errorf("holder pid %d went away without recording an exit: %w", h.holderPID, err)
+	}
+	if ex.ExitCode == 0 && ex.Error == "" {
+		return nil
+	}
+	return &HeldExitError{Code: ex.ExitCode, Msg: ex.Error}
+}
+
+// removeFiles drops the holder's socket, record and exit file once pogod has
+// seen the agent exit.
+func (h *holderConn) removeFiles() {
+	os.Remove(h.socket)
+	os.Remove(h.record)
+	os.Remove(h.exitFile)
+}
+
+// HeldExitError is a held agent's non-zero or signalled exit, as its holder
+// recorded it. It plays the part *exec.ExitError plays for a direct child.
+type HeldExitError struct {
+	Code int
+	Msg  string
+}
+
+func (e *HeldExitError) Error() string {
+	if e.Msg != "" {
+		return e.Msg
+	}
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// ExitCode is the child's exit code, -1 when it was killed by a signal.
+func (e *HeldExitError) ExitCode() int { return e.Code }
+
+func readHolderExit(path string) (holderExit, error) {
+	var ex holderExit
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ex, err
+	}
+	err = json.Unmarshal(data, &ex)
+	return ex, err
+}
+
+// writeFileAtomic writes data beside path and renames it into place, so a
+// reader sees the old file or the new one, never half of either.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// SetPTYHolder makes later spawns run each agent under a holder started from
+// exe — pogod's own binary — so that it survives pogod's death. "" (the
+// default) keeps pogod the parent of every agent. Agents already running keep
+// whichever arrangement they were started with.
+func (r *Registry) SetPTYHolder(exe string) {
+	r.mu.Lock()
+	r.holderExe = exe
+	r.mu.Unlock()
+}
+
+// startHolderLocked starts a holder for cmd's command and returns pogod's connection
+// to it and the child's pid. Called with r.mu held.
+func (r *Registry) startHolderLocked(name string, cmd *exec.Cmd, winsize *pty.Winsize, raw bool, profile *sandbox.Profile, group *cgroup.Group) (*holderConn, int, error) {
+	socket, record, exitFile := holderPaths(r.socketDir, name)
+	// Leftovers from an agent of the same name whose exit was already seen.
+	os.Remove(record)
+	os.Remove(exitFile)
+
+	spec := holderSpec{
+		Command:  cmd.Args,
+		Dir:      cmd.Dir,
+		Env:      cmd.Env,
+		Socket:   socket,
+		ExitFile: exitFile,
+		Sandbox:  profile,
+		Raw:      raw,
+	}
+	if winsize != nil {
+		spec.Cols, spec.Rows = winsize.Cols, winsize.Rows
+	}
+	input, err := json.Marshal(spec)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	logFile, err := os.OpenFile(socket+".log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, 0, fmt.Errorf("holder log: %w", err)
+	}
+	defer logFile.Close()
+
+	hc := exec.Command(r.holderExe, HolderArg)
+	hc.Stdin = bytes.NewReader(input)
+	hc.Stderr = logFile
+	stdout, err := hc.StdoutPipe()
+	if err != nil {
+		return nil, 0, err
+	}
+	// Its own session, so the holder is in no process group pogod's death or
+	// a signal to pogod's group reaches.
+	hc.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	// The holder goes in the agent's cgroup and the agent inherits it. The
+	// holder's own share of the limit is a few megabytes and one pid.
+	group.Attach(hc)
+	if err := hc.Start(); err != nil {
+		return nil, 0, fmt.Errorf("start holder: %w", err)
+	}
+	// Reap the holder whenever it exits, so it does not linger as a zombie
+	// under a pogod that outlives it.
+	go hc.Wait()
+
+	replyc := make(chan holderReply, 1)
+	go func() {
+		var reply holderReply
+		line, err := bufio.NewReader(stdout).ReadBytes('\n')
+		if err != nil {
+			reply.Error = fmt.Sprintf("holder exited before reporting: %v", err)
+		} else if err := json.Unmarshal(line, &reply); err != nil {
+			reply.Error = fmt.Sprintf("holder reply %q: %v", line, err)
+		}
+		replyc <- reply
+	}()
+	var reply holderReply
+	select {
+	case reply = <-replyc:
+	case <-time.After(holderStartTimeout):
+		hc.Process.Kill()
+		return nil, 0, fmt.Errorf("holder did not report a pid within %v", holderStartTimeout)
+	}
+	if reply.Error != "" {
+		return nil, 0, errors.New(reply.Error)
+	}
+
+	h, err := dialHolder(socket, record, exitFile, hc.Process.Pid)
+	if err != nil {
+		syscall.Kill(reply.PID, syscall.SIGKILL)
+		return nil, 0, fmt.Errorf("connect to holder: %w", err)
+	}
+	return h, reply.PID, nil
+}
+
+// saveHolderRecord persists what AdoptHeld needs to register a back again.
+// Best-effort: an agent whose record cannot be written still runs, and is
+// merely not adopted by the next pogod.
+func (a *Agent) saveHolderRecord() {
+	h := a.holder
+	if h == nil {
+		return
+	}
+	a.mu.Lock()
+	data, err := json.Marshal(holderRecord{
+		HolderPID:    h.holderPID,
+		Agent:        a,
+		Provider:     a.ProviderID(),
+		ReceiptFile:  a.receiptFile,
+		InitialNudge: a.InitialNudge,
+	})
+	a.mu.Unlock()
+	if err == nil {
+		err = writeFileAtomic(h.record, data)
+	}
+	if err != nil {
+		log.Printf("agent %s: could not record holder for adoption: %v", a.Name, err)
+	}
+}
+
+// AdoptHeld registers every agent still running under a holder from an
+// earlier pogod, and returns their names. Call it once at startup, before
+// auto-start, so a crew agent that survived is found running rather than
+// spawned a second time.
+//
+// An adopted agent gets back its pid, start time, restart count and the rest
+// of its recorded metadata, its provider's nudge dialect and session hook, and
+// the holder's replay as the start of its output ring. It is not nudged again:
+// it is mid-session, not starting. A record whose holder is gone is an agent
+// that exited while no pogod was watching; its exit is recorded as
+// agent_stopped or agent_crashed, its witness dropped and its files removed.
+func (r *Registry) AdoptHeld() []string {
+	entries, err := os.ReadDir(r.socketDir)
+	if err != nil {
+		return nil
+	}
+	var adopted []string
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".hold.json")
+		if !ok {
+			continue
+		}
+		if a, err := r.adoptHeld(name); err != nil {
+			log.Printf("agent %s: not adopted: %v", name, err)
+		} else if a != nil {
+			adopted = append(adopted, a.Name)
+		}
+	}
+	return adopted
+}
+
+// adoptHeld adopts the held agent recorded under name. It returns nil, nil when
+// the record names an agent that has already exited.
+func (r *Registry) adoptHeld(name string) (*Agent, error) {
+	socket, record, exitFile := holderPaths(r.socketDir, name)
+	data, err := os.ReadFile(record)
+	if err != nil {
+		return nil, err
+	}
+	var rec holderRecord
+	if err := json.Unmarshal(data, &rec); err != nil || rec.Agent == nil || rec.Agent.Name != name {
+		os.Remove(record)
+		return nil, fmt.Errorf("unreadable holder record %s", record)
+	}
+	a := rec.Agent
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing := r.agents[name]; existing != nil && existing.alive() {
+		return nil, fmt.Errorf("agent %q %w", name, ErrAgentAlreadyRunning)
+	}
+
+	var h *holderConn
+	if pidAlive(rec.HolderPID) {
+		h, err = dialHolder(socket, record, exitFile, rec.HolderPID)
+		if err != nil {
+			return nil, fmt.Errorf("connect to holder pid %d: %w", rec.HolderPID, err)
+		}
+	}
+	if h == nil {
+		// The child exited while no pogod was connected; the holder left its
+		// exit status behind. Record the exit once and forget the agent.
+		a.outputBuf = NewRingBuffer(OutputRingBytes)
+		exitCode := -1
+		exitTime := time.Now()
+		if ex, err := readHolderExit(exitFile); err == nil {
+			exitCode, exitTime = ex.ExitCode, ex.ExitTime
+		}
+		noteWitnessExit(a)
+		a.emitExit(false, "", exitCode, exitTime.Sub(a.StartTime).Seconds())
+		removeCgroup(a)
+		os.Remove(socket)
+		os.Remove(record)
+		os.Remove(exitFile)
+		log.Printf("agent %s: exited (code %d) while pogod was down", name, exitCode)
+		return nil, nil
+	}
+
+	provider := r.providers[rec.Provider]
+	// The screen starts at the spawn size; a client that resized the PTY
+	// while pogod was down is not recorded, and the next resize corrects it.
+	nudge, winsize := spawnDefaults(provider)
+	a.Status = StatusRunning
+	a.holder = h
+	a.nudge = nudge
+	a.provider = provider
+	a.receiptFile = rec.ReceiptFile
+	a.InitialNudge = rec.InitialNudge
+	a.outputBuf = NewRingBuffer(OutputRingBytes)
+	a.screen = newScreen(winsize)
+	a.recorder = r.startRecordingLocked(name, winsize)
+	a.attachConns = make(map[io.Writer]struct{})
+	a.socketPath = filepath.Join(r.socketDir, name+".sock")
+	a.done = make(chan struct{})
+	a.outputDone = make(chan struct{})
+	a.readerAttached = make(chan struct{})
+
+	a.enterState(time.Now(), "adopted from holder")
+	a.armStream()
+	go a.readOutput()
+	if err := a.startListener(); err != nil {
+		log.Printf("agent %s: attach listener failed on adoption: %v — supervisor will retry", name, err)
+	}
+	go r.waitAndHandle(a)
+	go r.watchState(a)
+	r.agents[name] = a
+	log.Printf("agent %s: adopted pid=%d from holder pid=%d", name, a.PID, rec.HolderPID)
+
+	noteCoordinatorStart(a)
+	r.invokeSessionHook(a)
+	events.Emit(context.Background(), events.Event{
+		EventType: "agent_adopted",
+		Agent:     a.eventAgent(),
+		Repo:      a.SourceRepo,
+		Details: map[string]any{
+			"pid":        a.PID,
+			"holder_pid": rec.HolderPID,
+			"start_time": a.StartTime.Format(time.RFC3339),
+		},
+	})
+	return a, nil
+}
+
+// HeldAlive reports whether a holder from this or an earlier pogod is running
+// the agent scheduleAgent names — by registry name or event identity — even if
+// it is not (yet) in the registry. The scheduler's mail-check GC asks this
+// before it concludes an unregistered agent is gone: with holders, surviving
+// pogod is what agents do.
+func (r *Registry) HeldAlive(scheduleAgent string) bool {
+	entries, err := os.ReadDir(r.socketDir)
+	if err != nil {
+		return false
+	}
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".hold.json")
+		if !ok {
+			continue
+		}
+		_, record, exitFile := holderPaths(r.socketDir, name)
+		data, err := os.ReadFile(record)
+		if err != nil {
+			continue
+		}
+		var rec holderRecord
+		if json.Unmarshal(data, &rec) != nil || rec.Agent == nil {
+			continue
+		}
+		if name != scheduleAgent && rec.Agent.eventAgent() != scheduleAgent {
+			continue
+		}
+		if _, err := os.Stat(exitFile); err == nil {
+			return false
+		}
+		return pidAlive(rec.HolderPID) && pidAlive(rec.Agent.PID)
+	}
+	return false
+}
+
+// RunHolder is the holder process's main, entered when pogod's binary is run
+// with HolderArg. It reads a holderSpec from stdin, starts the child on a PTY,
+// reports the child's pid on stdout, and serves attach until the child exits.
+// It returns the process's exit status.
+func RunHolder(stdin io.Reader, stdout io.Writer) int {
+	reply := func(r holderReply) {
+		data, _ := json.Marshal(r)
+		stdout.Write(append(data, '\n'))
+	}
+	var spec holderSpec
+	if err := json.NewDecoder(stdin).Decode(&spec); err != nil || len(spec.Command) == 0 {
+		reply(holderReply{Error: fmt.Sprintf("bad holder spec: %v", err)})
+		return 2
+	}
+	h := &holder{spec: spec, ring: NewRingBuffer(OutputRingBytes), conns: make(map[net.Conn]struct{})}
+	if err := h.start(); err != nil {
+		reply(holderReply{Error: err.Error()})
+		return 1
+	}
+	reply(holderReply{PID: h.cmd.Process.Pid})
+	if c, ok := stdout.(io.Closer); ok {
+		c.Close()
+	}
+	h.run()
+	return 0
+}
+
+// holder is the state of a running holder process.
+type holder struct {
+	spec   holderSpec
+	cmd    *exec.Cmd
+	master *os.File
+	slave  *os.File
+	ln     net.Listener
+	ring   *RingBuffer
+
+	// mu guards conns and the master's winsize. Output is written to the ring
+	// and to every conn under it, so a client's replay and its first live
+	// bytes cannot overlap or leave a gap.
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+// start binds the socket and starts the child.
+func (h *holder) start() error {
+	os.Remove(h.spec.Socket)
+	ln, err := net.Listen("unix", h.spec.Socket)
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	h.ln = ln
+
+	cmd := exec.Command(h.spec.Command[0], h.spec.Command[1:]...)
+	cmd.Dir = h.spec.Dir
+	cmd.Env = h.spec.Env
+	if h.spec.Sandbox != nil {
+		if err := sandbox.Apply(cmd, *h.spec.Sandbox); err != nil {
+			ln.Close()
+			return err
+		}
+	}
+	var ws *pty.Winsize
+	if h.spec.Cols > 0 && h.spec.Rows > 0 {
+		ws = &pty.Winsize{Cols: h.spec.Cols, Rows: h.spec.Rows}
+	}
+	master, slave, err := startPTY(cmd, ws, h.spec.Raw)
+	if err != nil {
+		ln.Close()
+		return fmt.Errorf("pty start: %w", err)
+	}
+	h.cmd, h.master, h.slave = cmd, master, slave
+	return nil
+}
+
+// run serves until the child has exited and its output is drained, then
+// records the exit and hangs up every client.
+func (h *holder) run() {
+	outputDone := make(chan struct{})
+	go func() {
+		defer close(outputDone)
+		buf := make([]byte, 4096)
+		for {
+			n, err := h.master.Read(buf)
+			if n > 0 {
+				h.mu.Lock()
+				h.ring.Write(buf[:n])
+				for c := range h.conns {
+					c.Write(buf[:n])
+				}
+				h.mu.Unlock()
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	go h.accept()
+
+	waitErr := h.cmd.Wait()
+	// As in waitAndHandle: the parent's slave fd kept the tty alive across the
+	// child's exit; releasing it is what lets the reader reach EOF.
+	h.slave.Close()
+	<-outputDone
+
+	ex := holderExit{ExitTime: time.Now()}
+	if waitErr != nil {
+		ex.ExitCode = -1
+		ex.Error = waitErr.Error()
+		var ee *exec.ExitError
+		if errors.As(waitErr, &ee) {
+			ex.ExitCode = ee.ExitCode()
+		}
+	}
+	if data, err := json.Marshal(ex); err == nil {
+		if err := writeFileAtomic(h.spec.ExitFile, data); err != nil {
+			log.Printf("holder: record exit: %v", err)
+		}
+	}
+
+	h.ln.Close()
+	os.Remove(h.spec.Socket)
+	h.mu.Lock()
+	for c := range h.conns {
+		c.Close()
+	}
+	h.mu.Unlock()
+	h.master.Close()
+}
+
+func (h *holder) accept() {
+	for {
+		conn, err := h.ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			if isRetryableAcceptErr(err) {
+				time.Sleep(attachAcceptMinBackoff)
+				continue
+			}
+			log.Printf("holder: accept: %v", err)
+			return
+		}
+		go h.serve(conn)
+	}
+}
+
+// serve replays the ring to conn, adds it to the output fanout, and feeds its
+// input to the PTY until it hangs up.
+func (h *holder) serve(conn net.Conn) {
+	defer conn.Close()
+	h.mu.Lock()
+	if recent := h.ring.Last(h.ring.Len()); len(recent) > 0 {
+		conn.Write(recent)
+	}
+	h.conns[conn] = struct{}{}
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.conns, conn)
+		h.mu.Unlock()
+	}()
+	serveAttachInput(conn, attachSink{input: h.master, resize: h.resize})
+}
+
+// resize applies a client's resize with applyResize's rules: a 0 dimension is
+// "unknown" and a resize to the current size is skipped.
+func (h *holder) resize(cols, rows uint16) {
+	if cols == 0 || rows == 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if cur, err := pty.GetsizeFull(h.master); err == nil && cur.Cols == cols && cur.Rows == rows {
+		return
+	}
+	pty.Setsize(h.master, &pty.Winsize{Cols: cols, Rows: rows})
+}
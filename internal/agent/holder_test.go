@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newHeldRegistry is a registry that starts its agents under holders run from
+// this test binary (see TestMain).
+func newHeldRegistry(t *testing.T, socketDir string) *Registry {
+	t.Helper()
+	reg, err := NewRegistry(socketDir)
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.SetPTYHolder(exe)
+	t.Cleanup(func() { reg.StopAll(2 * time.Second) })
+	return reg
+}
+
+// TestHeldAgentIsAdoptedByANewRegistry: a second registry on the same socket
+// dir — a restarted pogod — finds the held agent under its original pid,
+// with the output it wrote before the restart, and can drive it and see it
+// exit.
+func TestHeldAgentIsAdoptedByANewRegistry(t *testing.T) {
+	dir := shortSocketDir(t)
+	first := newHeldRegistry(t, dir)
+	a, err := first.Spawn(SpawnRequest{
+		Name:    "held",
+		Type:    TypePolecat,
+		Command: []string{"sh", "-c", "echo before-restart; read line; echo got-$line; exit 3"},
+	})
+	if err != nil {
+		t.Fatalf("Spawn: %v", err)
+	}
+	if a.holder == nil || a.cmd.Process != nil {
+		t.Fatal("agent was not started under a holder")
+	}
+	if got := waitForOutput(a, "before-restart", 5*time.Second); !strings.Contains(got, "before-restart") {
+		t.Fatalf("output through the holder = %q", got)
+	}
+	if !first.HeldAlive("held") || !first.HeldAlive("cat-held") {
+		t.Error("HeldAlive does not see the running holder")
+	}
+
+	second := newHeldRegistry(t, dir)
+	if got := second.AdoptHeld(); len(got) != 1 || got[0] != "held" {
+		t.Fatalf("AdoptHeld = %v, want [held]", got)
+	}
+	b := second.Get("held")
+	if b == nil || b.PID != a.PID || !b.StartTime.Equal(a.StartTime) || b.Type != TypePolecat {
+		t.Fatalf("adopted %+v, want pid %d started %s", b, a.PID, a.StartTime)
+	}
+	if got := waitForOutput(b, "before-restart", 5*time.Second); !strings.Contains(got, "before-restart") {
+		t.Errorf("adopted ring = %q, want the output from before the restart", got)
+	}
+
+	if err := b.SendRaw("hi\r"); err != nil {
+		t.Fatalf("SendRaw: %v", err)
+	}
+	select {
+	case <-b.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("adopted agent never exited")
+	}
+	if !strings.Contains(string(b.RecentOutput(1024)), "got-hi") {
+		t.Errorf("input did not reach the held child: %q", b.RecentOutput(1024))
+	}
+	if b.ExitCode != 3 {
+		t.Errorf("exit code %d, want 3", b.ExitCode)
+	}
+	if second.HeldAlive("held") {
+		t.Error("HeldAlive still true after the agent exited")
+	}
+}
+
+// TestAdoptHeldRecordsAnExitThatHappenedWhileDown: a record whose holder is
+// gone is an agent that exited with no pogod watching. It is not registered,
+// and its files are cleared.
+func TestAdoptHeldRecordsAnExitThatHappenedWhileDown(t *testing.T) {
+	dir := shortSocketDir(t)
+	gone := exec.Command("true")
+	if err := gone.Run(); err != nil {
+		t.Fatal(err)
+	}
+	socket, record, exitFile := holderPaths(dir, "ghost")
+	data, _ := json.Marshal(holderRecord{
+		HolderPID: gone.Process.Pid,
+		Agent:     &Agent{Name: "ghost", PID: gone.Process.Pid, Type: TypePolecat, StartTime: time.Now().Add(-time.Hour)},
+	})
+	os.WriteFile(record, data, 0o600)
+	data, _ = json.Marshal(holderExit{ExitCode: 3, ExitTime: time.Now()})
+	os.WriteFile(exitFile, data, 0o600)
+
+	reg := newHeldRegistry(t, dir)
+	if got := reg.AdoptHeld(); len(got) != 0 {
+		t.Errorf("AdoptHeld = %v, want nothing", got)
+	}
+	if reg.Get("ghost") != nil {
+		t.Error("an exited agent was registered")
+	}
+	for _, p := range []string{socket, record, exitFile} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("%s left behind", p)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/drellem2/pogo/internal/events"
+)
+
+// Agent lifecycle states (user-020).
+//
+// diagnose used to work an agent's health out on demand, from a priority
+// cascade over last-output age, and so did every consumer that copied it. The
+// one question the cascade could not answer was "has this agent started yet?":
+// a harness that had been up for ten seconds and not drawn anything yet had
+// the same zero last-output time as one wedged at init, and the stall-watch
+// and external dashboards read both as stalled.
+//
+// An agent now carries an explicit state, entered at a known time, that
+// changes only along the transitions below and emits agent_state_changed each
+// time it does. The design is docs/design/agent-state-machine-design.md; the
+// thresholds are the ones diagnose already used.
+//
+//	spawn ──▶ starting ── first output ──▶ healthy ◀──── output ────┐
+//	             │                            │                    │
+//	             │ silent past StartingMaxFor │ quiet past         │
+//	             │                            ▼ ActiveRecencyWindow │
+//	             │                          idle ───────────────────┤
+//	             │                            │ quiet past the      │
+//	             ▼                            ▼ stall threshold     │
+//	          stalled ◀───────────────────────┘ ───── output ──────┘
+//
+//	any state ── process gone ──▶ stopped
+//
+// An agent whose quiet a cron schedule explains (withinCronInterval) is idle
+// rather than stalled, as diagnose has always reported it, or still starting
+// if it has shown nothing yet. Output is anything read from the PTY, which for
+// a stream-mode agent is its event lines.
+
+// State is an agent's lifecycle state.
+type State string
+
+const (
+	// StateStarting is entered at spawn, respawn and adoption: the process
+	// runs and has shown nothing yet.
+	StateStarting State = "starting"
+	// StateHealthy means output within ActiveRecencyWindow.
+	StateHealthy State = "healthy"
+	// StateIdle means alive and quiet, short of the stall threshold.
+	StateIdle State = "idle"
+	// StateStalled means quiet past the stall threshold, or never started.
+	StateStalled State = "stalled"
+	// StateStopped means the process has exited or is gone. It is final: a
+	// respawn is a new Agent, which starts again at StateStarting.
+	StateStopped State = "stopped"
+)
+
+// StartingMaxFor is how long an agent of type t may stay starting with no
+// output before it is stalled: its stall threshold. A cold start under a
+// sandbox profile or a throttled cgroup can take minutes, and one that took
+// less than the threshold to show nothing is no more wedged than a running
+// agent that went quiet for as long; one hung in init still ends up stalled.
+func StartingMaxFor(t AgentType) time.Duration {
+	return StallThresholdFor(t)
+}
+
+// stateTick is how often an agent's state is re-evaluated against the clock.
+// Output moves an agent to healthy at once; only the quiet transitions wait
+// for the tick.
+const stateTick = 5 * time.Second
+
+// transitions are the moves the machine allows. StateStopped is reachable
+// from every state and is left out.
+var transitions = map[State][]State{
+	StateStarting: {StateHealthy, StateStalled},
+	StateHealthy:  {StateIdle, StateStalled},
+	StateIdle:     {StateHealthy, StateStalled},
+	StateStalled:  {StateHealthy, StateIdle},
+}
+
+func canTransition(from, to State) bool {
+	if to == StateStopped {
+		return from != StateStopped
+	}
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// StateInfo is an agent's state as reported: its name, when it was entered,
+// and why. It is AgentInfo's "state".
+type StateInfo struct {
+	Name    State     `json:"name"`
+	SinceTS time.Time `json:"since_ts"`
+	// SinceS is the time in state, in whole seconds, when the report was made.
+	SinceS int64  `json:"since_s"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// InState returns how long the agent had been in its state when the report
+// was made.
+func (s StateInfo) InState() time.Duration {
+	return time.Duration(s.SinceS) * time.Second
+}
+
+// enterState puts a new agent in StateStarting. Called once, wherever an
+// Agent is built, before its output reader starts.
+func (a *Agent) enterState(now time.Time, reason string) {
+	a.stateMu.Lock()
+	a.state, a.stateSince, a.stateReason = StateStarting, now, reason
+	a.stateMu.Unlock()
+	a.emitStateChanged("", StateStarting, reason, 0)
+}
+
+// State reports the agent's lifecycle state as of now.
+func (a *Agent) State() StateInfo {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	return a.stateInfoLocked(time.Now())
+}
+
+func (a *Agent) stateInfoLocked(now time.Time) StateInfo {
+	info := StateInfo{Name: a.state, SinceTS: a.stateSince, Reason: a.stateReason}
+	if !a.stateSince.IsZero() {
+		info.SinceS = int64(now.Sub(a.stateSince) / time.Second)
+	}
+	return info
+}
+
+// setState moves the agent to state to, and emits agent_state_changed. A move
+// the machine does not allow is logged and refused, and so reports false; a
+// move to the state the agent is already in does nothing.
+func (a *Agent) setState(to State, reason string, now time.Time) bool {
+	a.stateMu.Lock()
+	from := a.state
+	if from == to || from == "" {
+		a.stateMu.Unlock()
+		return false
+	}
+	if !canTransition(from, to) {
+		a.stateMu.Unlock()
+		log.Printf("agent %s: refused state transition %s -> %s (%s)", a.Name, from, to, reason)
+		return false
+	}
+	previous := now.Sub(a.stateSince)
+	a.state, a.stateSince, a.stateReason = to, now, reason
+	a.stateMu.Unlock()
+	a.emitStateChanged(from, to, reason, previous)
+	return true
+}
+
+// noteOutput is the output reader's half of the machine: any output makes a
+// running agent healthy.
+func (a *Agent) noteOutput(now time.Time) {
+	a.stateMu.Lock()
+	s := a.state
+	a.stateMu.Unlock()
+	switch s {
+	case StateStarting:
+		a.setState(StateHealthy, "first output", now)
+	case StateIdle, StateStalled:
+		a.setState(StateHealthy, "output resumed", now)
+	}
+}
+
+// advanceState is the clock's half of the machine: it moves the agent along
+// whatever quiet transition now calls for, and returns the state it is in.
+func (a *Agent) advanceState(now time.Time, windows []CronWindow) StateInfo {
+	a.stateMu.Lock()
+	cur, since := a.state, a.stateSince
+	a.stateMu.Unlock()
+	if to, reason := nextState(cur, since, a.LastOutputAt(), now, StallThresholdFor(a.Type), windows); to != cur {
+		a.setState(to, reason, now)
+	}
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	return a.stateInfoLocked(now)
+}
+
+// projectedState is the state advanceState would leave the agent in at now,
+// without moving it: a report reads the machine and does not drive it, so a
+// GET never emits agent_state_changed. A transition it foresees is reported
+// as entered at now, which is where the next tick will put it.
+func (a *Agent) projectedState(now time.Time, windows []CronWindow) StateInfo {
+	a.stateMu.Lock()
+	info := a.stateInfoLocked(now)
+	a.stateMu.Unlock()
+	to, reason := nextState(info.Name, info.SinceTS, a.LastOutputAt(), now, StallThresholdFor(a.Type), windows)
+	if to == info.Name || !canTransition(info.Name, to) {
+		return info
+	}
+	return StateInfo{Name: to, SinceTS: now, Reason: reason}
+}
+
+// nextState is the state a running agent in cur, entered at since, should be
+// in at now given its last activity. stallAfter is the agent's stall
+// threshold, which is also how long it may be starting (StartingMaxFor). It
+// never returns StateStopped, which only an exit can cause.
+func nextState(cur State, since, last, now time.Time, stallAfter time.Duration, windows []CronWindow) (State, string) {
+	switch {
+	case cur == StateStopped || cur == "":
+		return cur, ""
+	case cur == StateStarting && (last.IsZero() || last.Before(since)):
+		if now.Sub(since) >= stallAfter && !withinCronInterval(now, windows) {
+			return StateStalled, fmt.Sprintf("no output within %s of start", stallAfter)
+		}
+		return cur, ""
+	case cur == StateStalled && (last.IsZero() || last.Before(since)):
+		// Stalled from starting, or stalled and still quiet: only output
+		// moves it, and output goes through noteOutput.
+		return cur, ""
+	}
+	quiet := now.Sub(last)
+	switch {
+	case quiet < ActiveRecencyWindow:
+		return StateHealthy, "output within " + ActiveRecencyWindow.String()
+	case quiet < stallAfter:
+		return StateIdle, fmt.Sprintf("no output for %s", quiet.Truncate(time.Second))
+	case withinCronInterval(now, windows):
+		return StateIdle, fmt.Sprintf("no output for %s, within a cron interval of its last firing", quiet.Truncate(time.Second))
+	default:
+		return StateStalled, fmt.Sprintf("no output for %s; stall threshold %s", quiet.Truncate(time.Second), stallAfter)
+	}
+}
+
+// watchState re-evaluates a's state every stateTick until it exits, against
+// the same cron windows diagnose reads.
+func (r *Registry) watchState(a *Agent) {
+	ticker := time.NewTicker(stateTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.done:
+			return
+		case now := <-ticker.C:
+			a.advanceState(now, r.cronWindows(a))
+		}
+	}
+}
+
+// cronWindows returns a's cron windows, or nil with no schedule provider.
+func (r *Registry) cronWindows(a *Agent) []CronWindow {
+	r.mu.RLock()
+	provider := r.stallSchedules
+	r.mu.RUnlock()
+	if provider == nil {
+		return nil
+	}
+	return provider.CronWindowsForAgent(a.EventAgent())
+}
+
+func (a *Agent) emitStateChanged(from, to State, reason string, previous time.Duration) {
+	stateTransitionsTotal.Inc(string(a.Type), string(to))
+	details := map[string]any{
+		"agent_type": string(a.Type),
+		"from":       string(from),
+		"to":         string(to),
+		"reason":     reason,
+	}
+	if from != "" {
+		details["previous_s"] = int64(previous / time.Second)
+	}
+	if a.WorkItemID != "" {
+		details["work_item_id"] = a.WorkItemID
+	}
+	events.Emit(context.Background(), events.Event{
+		EventType: "agent_state_changed",
+		Agent:     a.eventAgent(),
+		Repo:      a.SourceRepo,
+		Details:   details,
+	})
+}
@@ -0,0 +1,153 @@
+package agent
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLifecycleTransitions(t *testing.T) {
+	for _, tc := range []struct {
+		from, to State
+		ok       bool
+	}{
+		{StateStarting, StateHealthy, true},
+		{StateStarting, StateStalled, true},
+		{StateStarting, StateIdle, false},
+		{StateHealthy, StateIdle, true},
+		{StateIdle, StateHealthy, true},
+		{StateIdle, StateStalled, true},
+		{StateStalled, StateHealthy, true},
+		{StateHealthy, StateStarting, false},
+		{StateIdle, StateStopped, true},
+		{StateStopped, StateHealthy, false},
+		{StateStopped, StateStopped, false},
+	} {
+		if got := canTransition(tc.from, tc.to); got != tc.ok {
+			t.Errorf("canTransition(%s, %s) = %v, want %v", tc.from, tc.to, got, tc.ok)
+		}
+	}
+}
+
+func TestNextStateFollowsTheClock(t *testing.T) {
+	since := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	stall := StallThresholdPolecat
+	cron := []CronWindow{{Interval: time.Hour, LastFire: since}}
+	for _, tc := range []struct {
+		name    string
+		cur     State
+		last    time.Time
+		at      time.Duration
+		windows []CronWindow
+		want    State
+	}{
+		{"starting, quiet, a slow cold start", StateStarting, time.Time{}, 2 * time.Minute, nil, StateStarting},
+		{"starting, quiet past StartingMaxFor", StateStarting, time.Time{}, StartingMaxFor(TypePolecat), nil, StateStalled},
+		{"starting, quiet past it inside a cron interval", StateStarting, time.Time{}, StartingMaxFor(TypePolecat), cron, StateStarting},
+		{"starting, output just now", StateStarting, since.Add(time.Second), 2 * time.Second, nil, StateHealthy},
+		{"healthy, quiet past the recency window", StateHealthy, since, ActiveRecencyWindow, nil, StateIdle},
+		{"idle, quiet past the stall threshold", StateIdle, since, stall, nil, StateStalled},
+		{"idle, quiet past it inside a cron interval", StateIdle, since, stall, cron, StateIdle},
+		{"stalled from starting stays stalled", StateStalled, time.Time{}, time.Hour, nil, StateStalled},
+		{"stopped is final", StateStopped, since, time.Hour, nil, StateStopped},
+	} {
+		got, _ := nextState(tc.cur, since, tc.last, since.Add(tc.at), stall, tc.windows)
+		if got != tc.want {
+			t.Errorf("%s: nextState = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+// diagnose reports where the machine is headed but leaves moving it to the
+// clock: a GET that emitted agent_state_changed would put transitions in the
+// log at whatever moment somebody happened to look.
+func TestDiagnoseDoesNotMoveTheMachine(t *testing.T) {
+	path := useTempEventLog(t)
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	a := &Agent{
+		Name:      "cold",
+		Type:      TypeCrew,
+		Status:    StatusRunning,
+		StartTime: now,
+		outputBuf: NewRingBuffer(1024),
+		done:      make(chan struct{}),
+	}
+	a.enterState(now, "spawned")
+
+	if d := diagnoseAgentAt(a, now.Add(5*time.Minute), nil, mailLoopUnknown, nil); d.Health != "starting" || d.Stalled {
+		t.Errorf("crew silent 5m after spawn: health %q, stalled %v, want starting", d.Health, d.Stalled)
+	}
+	late := now.Add(StartingMaxFor(TypeCrew))
+	d := diagnoseAgentAt(a, late, nil, mailLoopUnknown, nil)
+	if d.Health != "stalled" || d.State == nil || d.State.Name != StateStalled {
+		t.Errorf("crew silent past StartingMaxFor: health %q, state %+v, want stalled", d.Health, d.State)
+	}
+	if st := a.State(); st.Name != StateStarting {
+		t.Errorf("diagnose moved the agent to %s", st.Name)
+	}
+	for _, e := range readEventLines(t, path) {
+		if e["event_type"] == "agent_state_changed" && e["details"].(map[string]any)["to"] != string(StateStarting) {
+			t.Errorf("diagnose emitted %v", e["details"])
+		}
+	}
+}
+
+// A spawned agent is starting until it shows output, healthy once it does, and
+// stopped when it exits, and each move is an agent_state_changed event.
+func TestLifecycleOfASpawnedAgent(t *testing.T) {
+	path := useTempEventLog(t)
+	reg, err := NewRegistry(shortSocketDir(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reg.StopAll(2 * time.Second)
+
+	a, err := reg.Spawn(SpawnRequest{
+		Name:    "lifer",
+		Type:    TypePolecat,
+		Command: []string{"sh", "-c", "sleep 0.5; echo ready; sleep 30"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := a.State(); st.Name != StateStarting {
+		t.Fatalf("state at spawn = %+v, want starting", st)
+	}
+	if d := diagnoseAgent(a); d.Health != "starting" || d.Stalled {
+		t.Errorf("diagnose of a starting agent: health %q, stalled %v", d.Health, d.Stalled)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for a.State().Name != StateHealthy {
+		if time.Now().After(deadline) {
+			t.Fatalf("never healthy: %+v", a.State())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if info, _ := agentInfoLocked(a); info.State == nil || info.State.Name != StateHealthy || info.State.Reason != "first output" {
+		t.Errorf("AgentInfo state = %+v", info.State)
+	}
+
+	if err := reg.Stop("lifer", 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if st := a.State(); st.Name != StateStopped {
+		t.Errorf("state after stop = %+v, want stopped", st)
+	}
+
+	var seen []string
+	for _, e := range readEventLines(t, path) {
+		if e["event_type"] == "agent_state_changed" && e["agent"] == "cat-lifer" {
+			d := e["details"].(map[string]any)
+			seen = append(seen, d["from"].(string)+">"+d["to"].(string))
+		}
+	}
+	want := []string{">starting", "starting>healthy", "healthy>stopped"}
+	if len(seen) != len(want) {
+		t.Fatalf("agent_state_changed transitions = %v, want %v", seen, want)
+	}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Fatalf("agent_state_changed transitions = %v, want %v", seen, want)
+		}
+	}
+}
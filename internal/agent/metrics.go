@@ -0,0 +1,41 @@
+package agent
+
+import (
+	"github.com/drellem2/pogo/internal/metrics"
+)
+
+// The agent half of pogod's /metrics (user-022). Restarts and state changes
+// are counted where they happen; how many agents are in each state, and for
+// how long, is read off the registry at scrape time by RegisterMetrics.
+var (
+	restartsTotal = metrics.NewCounter("pogo_agent_restarts_total",
+		"Crew agent respawns after an exit, since pogod started.", "agent")
+	stateTransitionsTotal = metrics.NewCounter("pogo_agent_state_transitions_total",
+		"Agent lifecycle state changes, by agent type and the state entered.", "type", "to")
+)
+
+// RegisterMetrics publishes the registry's live agents on /metrics:
+// pogo_agents counts them by type and lifecycle state, and
+// pogo_agent_state_seconds says how long each has been in its state, which is
+// what an alert on an agent stuck starting or stalled reads.
+func (r *Registry) RegisterMetrics() {
+	metrics.SetGaugeFunc("pogo_agents",
+		"Running agents, by type and lifecycle state.",
+		[]string{"type", "state"}, func(emit metrics.Emit) {
+			counts := map[[2]string]int{}
+			for _, a := range r.List() {
+				counts[[2]string{string(a.Type), string(a.State().Name)}]++
+			}
+			for k, n := range counts {
+				emit(float64(n), k[0], k[1])
+			}
+		})
+	metrics.SetGaugeFunc("pogo_agent_state_seconds",
+		"Seconds each running agent has been in its lifecycle state.",
+		[]string{"agent", "type", "state"}, func(emit metrics.Emit) {
+			for _, a := range r.List() {
+				st := a.State()
+				emit(float64(st.SinceS), a.Name, string(a.Type), string(st.Name))
+			}
+		})
+}
@@ -0,0 +1,48 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drellem2/pogo/internal/metrics"
+)
+
+func TestRegisterMetricsReportsAgentsByState(t *testing.T) {
+	useTempEventLog(t)
+	reg, err := NewRegistry(shortSocketDir(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	healthy := livePolecat("m-cat", "mg-m")
+	healthy.enterState(now.Add(-2*time.Minute), "spawned")
+	healthy.setState(StateHealthy, "first output", now.Add(-90*time.Second))
+	starting := livePolecat("n-cat", "mg-n")
+	starting.enterState(now, "spawned")
+	reg.agents[healthy.Name] = healthy
+	reg.agents[starting.Name] = starting
+
+	before := stateTransitionsTotal.Value("polecat", "healthy")
+	healthy.setState(StateIdle, "quiet", now.Add(-time.Minute))
+	healthy.setState(StateHealthy, "output resumed", now.Add(-time.Minute))
+	if got := stateTransitionsTotal.Value("polecat", "healthy") - before; got != 1 {
+		t.Errorf("counted %v transitions to healthy, want 1", got)
+	}
+
+	reg.RegisterMetrics()
+	var b strings.Builder
+	if err := metrics.Default.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		`pogo_agents{type="polecat",state="healthy"} 1`,
+		`pogo_agents{type="polecat",state="starting"} 1`,
+		`pogo_agent_state_seconds{agent="m-cat",type="polecat",state="healthy"} 60`,
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("/metrics lacks %s:\n%s", want, out)
+		}
+	}
+}
@@ -73,6 +73,12 @@ func validNameComponent(name string) bool {
 // fails the spawn instead of silently losing attach (mg-ef80).
 //
 // The comparison is on bytes, not runes: sun_path is a byte budget.
+//
+// A name may not be one of the static routes under /agents ("drain",
+// "spawn-polecat", ...; see reservedAgentNames). The mux serves the static
+// route, so such an agent could never be addressed by name — and its token's
+// "/agents/{self}" rule (internal/apiauth) would let a polecat of that name
+// call the route pogod-wide: a polecat called "drain" could drain the fleet.
 func ValidateAgentName(name string) error {
 	if name == "" {
 		return fmt.Errorf("%w: name is required", ErrInvalidAgentName)
@@ -85,5 +91,23 @@ func ValidateAgentName(name string) error {
 		return fmt.Errorf("%w: %q is %d bytes, over the %d-byte limit; the agent's attach socket path must fit the AF_UNIX sun_path limit",
 			ErrInvalidAgentName, name, len(name), config.MaxAgentNameLen)
 	}
+	if reservedAgentNames()[name] {
+		return fmt.Errorf("%w: %q is an API route under /agents", ErrInvalidAgentName, name)
+	}
 	return nil
 }
+
+// reservedAgentNames returns the second path segment of every static route
+// under /agents — the names /agents/<name> cannot reach, because the route
+// answers instead. It is read off routes so a new route is reserved with it.
+func reservedAgentNames() map[string]bool {
+	out := map[string]bool{}
+	for _, p := range RoutePatterns() {
+		seg, ok := strings.CutPrefix(p, "/agents/")
+		if !ok || strings.ContainsAny(seg, "{/") {
+			continue
+		}
+		out[seg] = true
+	}
+	return out
+}
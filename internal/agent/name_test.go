@@ -12,6 +12,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/drellem2/pogo/internal/apiauth"
 	"github.com/drellem2/pogo/internal/config"
 	"github.com/drellem2/pogo/internal/testsandbox"
 )
@@ -59,6 +60,11 @@ func TestValidateAgentName(t *testing.T) {
 		{"dotdot inside a name", "pm..pogo", false},
 		{"leading dot", ".hidden", false},
 		{"dotdot prefix", "..hidden", false},
+
+		// Static routes under /agents: the route answers, not the agent.
+		{"static route", "drain", true},
+		{"static route with a dash", "spawn-polecat", true},
+		{"static route as a prefix", "drainer", false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -94,6 +100,30 @@ func TestValidateAgentNameAcceptsLiveNames(t *testing.T) {
 	}
 }
 
+// TestAgentNamesCannotBeStaticRoutes is the policy half of the reservation. A
+// polecat's "/agents/{self}" rule matches its own name wherever it stands, so a
+// polecat named after a static route would be allowed that route for the whole
+// fleet. The name is what stops it; the policy is not route-aware.
+func TestAgentNamesCannotBeStaticRoutes(t *testing.T) {
+	policy := apiauth.DefaultPolicy()
+	for _, p := range RoutePatterns() {
+		name, ok := strings.CutPrefix(p, "/agents/")
+		if !ok || strings.ContainsAny(name, "{/") {
+			continue
+		}
+		cat := apiauth.Identity{Role: apiauth.RolePolecat, Subject: name}
+		if allowed, _ := policy.Allows(cat, "POST", p); !allowed {
+			continue
+		}
+		if err := ValidateAgentName(name); err == nil {
+			t.Errorf("%q is a valid agent name, and a polecat of that name may POST %s", name, p)
+		}
+	}
+	if err := ValidateAgentName("drain"); err == nil {
+		t.Error(`"drain" is a valid agent name`)
+	}
+}
+
 // TestSpawnRejectsTraversingName pins mg-edb2 at the layer that matters. The
 // unit table proves the predicate; this proves the predicate is actually on
 // Spawn's path, so "../x" never reaches filepath.Join(socketDir, name+".sock")
@@ -90,7 +90,14 @@ var (
 // (verifyStartAndRenudge) is the failure-mode-agnostic backstop: it gates on the
 // HARD started-signal (the work item leaving available/), never on quiescence,
 // precisely because a quiescence re-check would reproduce this same false-idle.
+//
+// A stream-mode agent is idle when its harness has reported something and is
+// not in a turn, whatever quiescence says: that is not a guess (stream.go).
 func (a *Agent) IsIdle(quiescence time.Duration) bool {
+	if a.stream != nil {
+		st := a.stream.snapshot()
+		return !st.InTurn && !st.LastEventAt.IsZero()
+	}
 	lastWrite := a.outputBuf.LastWriteTime()
 	if lastWrite.IsZero() {
 		return false
@@ -270,6 +277,17 @@ func (a *Agent) NudgeWithMode(msg string, mode NudgeMode, timeout time.Duration)
 // so callers with no fire to correlate (manual nudges, mail-check kickoffs) are
 // unchanged.
 func (a *Agent) NudgeWithModeCorrelated(msg string, mode NudgeMode, timeout time.Duration, corr string) error {
+	// A stream-mode harness reads whole input messages and queues one that
+	// arrives mid-turn, so there is no composer to wait for and no receipt to
+	// confirm: every mode is delivered at once (stream.go).
+	if a.stream != nil {
+		if err := a.streamInput(msg); err != nil {
+			return err
+		}
+		emitNudgeSent(a, msg, string(mode), corr)
+		return nil
+	}
+
 	if mode == NudgeImmediate {
 		if err := a.Nudge(msg); err != nil {
 			return err
@@ -383,7 +401,12 @@ func (a *Agent) NudgeWithModeCorrelated(msg string, mode NudgeMode, timeout time
 // most important case for the escalation below, since the startup drop is the
 // failure Orc measured. Reading that as "mid-turn" would switch the escalation
 // off at exactly the moment it is needed. Silence is not a turn.
+//
+// For a stream-mode agent it is the harness's own account of the turn.
 func (a *Agent) MidTurn() bool {
+	if a.stream != nil {
+		return a.stream.snapshot().InTurn
+	}
 	last := a.outputBuf.LastWriteTime()
 	if last.IsZero() {
 		return false
@@ -526,10 +549,14 @@ func (a *Agent) deliverConfirmed(msg string, timeout time.Duration, corr string)
 // originating agent identity isn't plumbed through this call site in v1.
 // Best-effort: events.Emit never propagates errors.
 func emitNudgeSent(a *Agent, msg, mode, corr string) {
+	delivery := "pty"
+	if a.stream != nil {
+		delivery = "stream"
+	}
 	details := map[string]any{
 		"to":       a.eventAgent(),
 		"message":  msg,
-		"delivery": "pty",
+		"delivery": delivery,
 		"mode":     mode,
 	}
 	// Correlation id, when the caller has one. Present only for nudges that
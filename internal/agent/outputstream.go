@@ -0,0 +1,363 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Streaming output (user-017).
+//
+// GET /agents/{name}/output answers with a snapshot, so everything that
+// follows an agent — the editor plugins, dashboards, watchers — polls it, and
+// with twenty agents that is a steady load on pogod for output that mostly
+// has not changed. GET /agents/{name}/output/stream is the push version: a
+// Server-Sent Events stream that sends what the agent prints as it prints it.
+//
+// Every byte an agent prints has a stream offset, counted from the start of
+// its ring (RingBuffer.Offset), and every event carries the offset it ends at
+// as its SSE id. A client that drops the connection reconnects with that id —
+// as Last-Event-ID, which EventSource sends by itself, or as ?offset= — and
+// the stream resumes at the byte after the last one it saw. The stream is read
+// out of the ring, not queued per client, so a slow client costs pogod nothing
+// but its socket: if it falls more than the ring's size behind, or asks for an
+// offset the ring no longer holds, it is sent a "gap" event saying which
+// bytes it missed and the stream carries on from the oldest one kept.
+//
+// Events:
+//
+//	output  {"offset":N,"data":"…"}  raw PTY bytes from offset N (format=raw)
+//	line    {"offset":N,"text":"…"}  one logical line ending at N (format=lines)
+//	gap     {"from":A,"to":B}        bytes A..B are gone
+//	exit    {"offset":N}             the agent's process is gone; the stream ends
+//
+// Offsets belong to one run of the agent: a respawn starts a new ring, and
+// its offsets start again at 0. A stream always ends with exit when the run it
+// follows does, and a client that reconnects after one should start afresh
+// rather than resume — an old offset is at best a gap, and at worst a
+// position in the new run that means nothing.
+//
+// format=lines is for reading, not reproducing: escape sequences stripped, a
+// line overwritten by carriage returns reduced to what it ended as, blank
+// lines dropped, and a line repeated within the last outputLineWindow lines —
+// which is what a TUI redrawing its screen looks like as text — sent once.
+
+// outputLineWindow is how many recent lines format=lines remembers to drop
+// repeats of. A TUI redraw re-emits at most a screen's worth of lines.
+const outputLineWindow = 200
+
+// maxOutputLine bounds how long a line format=lines will wait for its newline.
+// A full-screen TUI can go a long time addressing rows by cursor motion
+// without printing one.
+const maxOutputLine = 16 << 10
+
+// outputKeepalive is how often an idle stream is sent an SSE comment, so a
+// proxy or client timeout does not mistake a quiet agent for a dead stream.
+const outputKeepalive = 15 * time.Second
+
+// OutputEvent is one event of an output stream: Type is the SSE event name,
+// ID its SSE id — the offset a client resumes from — and the rest its data.
+// Offset is where an output event's Data begins and where a line event's Text
+// ends.
+type OutputEvent struct {
+	Type   string `json:"type"`
+	ID     int64  `json:"id"`
+	Offset int64  `json:"offset"`
+	Data   string `json:"data,omitempty"`
+	Text   string `json:"text,omitempty"`
+	From   int64  `json:"from"`
+	To     int64  `json:"to"`
+}
+
+// payload is the event's SSE data: the fields its type carries.
+func (ev OutputEvent) payload() any {
+	switch ev.Type {
+	case "output":
+		return map[string]any{"offset": ev.Offset, "data": ev.Data}
+	case "line":
+		return map[string]any{"offset": ev.Offset, "text": ev.Text}
+	case "gap":
+		return map[string]any{"from": ev.From, "to": ev.To}
+	}
+	return map[string]any{"offset": ev.Offset}
+}
+
+// Output stream formats.
+const (
+	OutputFormatRaw   = "raw"
+	OutputFormatLines = "lines"
+)
+
+// handleOutputStream serves GET /agents/{name}/output/stream.
+//
+// Query params:
+//
+//	?format=raw|lines  raw PTY bytes (the default) or logical lines
+//	?offset=N          start at stream offset N
+//	?bytes=N           start N bytes back from the newest byte
+//
+// With neither offset nor bytes the stream starts with the next byte the agent
+// prints. A Last-Event-ID header wins over both: it is a reconnect.
+func (r *Registry) handleOutputStream(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		http.Error(w, "", http.StatusMethodNotAllowed)
+		return
+	}
+	name := req.PathValue("name")
+	a := r.Get(name)
+	if a == nil {
+		http.Error(w, fmt.Sprintf("agent %q not found", name), http.StatusNotFound)
+		return
+	}
+
+	q := req.URL.Query()
+	format := q.Get("format")
+	switch format {
+	case "":
+		format = OutputFormatRaw
+	case OutputFormatRaw, OutputFormatLines:
+	default:
+		http.Error(w, fmt.Sprintf("format: %q is not raw or lines", format), http.StatusBadRequest)
+		return
+	}
+	if q.Has("offset") && q.Has("bytes") {
+		http.Error(w, "offset and bytes are mutually exclusive; send at most one", http.StatusBadRequest)
+		return
+	}
+	off := a.outputBuf.Offset()
+	switch {
+	case req.Header.Get("Last-Event-ID") != "":
+		n, err := strconv.ParseInt(req.Header.Get("Last-Event-ID"), 10, 64)
+		if err != nil || n < 0 {
+			http.Error(w, fmt.Sprintf("Last-Event-ID: %q is not an offset", req.Header.Get("Last-Event-ID")), http.StatusBadRequest)
+			return
+		}
+		off = n
+	case q.Has("offset"):
+		n, err := strconv.ParseInt(q.Get("offset"), 10, 64)
+		if err != nil || n < 0 {
+			http.Error(w, fmt.Sprintf("offset: %q is not an offset", q.Get("offset")), http.StatusBadRequest)
+			return
+		}
+		off = n
+	case q.Has("bytes"):
+		n, err := positiveParam(q.Get("bytes"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("bytes: %v", err), http.StatusBadRequest)
+			return
+		}
+		off = max(off-int64(n), 0)
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	// pogod's WriteTimeout bounds a whole response, which a stream is meant
+	// to outlive.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	// Subscribe before the first read of the ring, so nothing printed in
+	// between goes unnoticed.
+	notify := make(chan struct{}, 1)
+	unsubscribe := a.Subscribe(outputNotifier(notify))
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	s := &outputStreamer{format: format, off: off, lines: newLineAssembler(off)}
+	send := func(ev OutputEvent) error {
+		data, _ := json.Marshal(ev.payload())
+		_, err := fmt.Fprintf(w, "event: %s\nid: %d\ndata: %s\n\n", ev.Type, ev.ID, data)
+		return err
+	}
+	keepalive := time.NewTicker(outputKeepalive)
+	defer keepalive.Stop()
+	for {
+		exited := false
+		select {
+		case <-a.done:
+			exited = true
+		default:
+		}
+		for _, ev := range s.next(a.outputBuf, exited) {
+			if err := send(ev); err != nil {
+				return
+			}
+		}
+		if exited {
+			send(OutputEvent{Type: "exit", ID: s.off, Offset: s.off})
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-notify:
+		case <-a.done:
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+// outputNotifier is the Subscribe writer behind a stream: it does not keep
+// the output, which the stream reads from the ring, only notes that there is
+// some. It never blocks readOutput.
+type outputNotifier chan struct{}
+
+func (n outputNotifier) Write(p []byte) (int, error) {
+	select {
+	case n <- struct{}{}:
+	default:
+	}
+	return len(p), nil
+}
+
+// outputStreamer turns what the ring holds past off into stream events.
+type outputStreamer struct {
+	format string
+	// off is the offset of the next byte to send.
+	off   int64
+	lines *lineAssembler
+}
+
+// next returns the events for what the ring holds past s.off and advances it.
+// final is set once the agent has exited, when nothing more will arrive to
+// complete a held-back rune or line.
+func (s *outputStreamer) next(rb *RingBuffer, final bool) []OutputEvent {
+	data, from := rb.Since(s.off)
+	var evs []OutputEvent
+	if from != s.off {
+		evs = append(evs, OutputEvent{Type: "gap", ID: from, From: s.off, To: from})
+		s.off = from
+		s.lines = newLineAssembler(from)
+	}
+	if s.format == OutputFormatLines {
+		evs = append(evs, s.lines.feed(data, final)...)
+		s.off = from + int64(len(data))
+		return evs
+	}
+	// Hold back a rune split across reads: the event is JSON, and half a rune
+	// would arrive as U+FFFD. The next read completes it.
+	n := len(data)
+	if !final {
+		n = completeUTF8(data)
+	}
+	if n > 0 {
+		evs = append(evs, OutputEvent{Type: "output", ID: from + int64(n), Offset: from, Data: string(data[:n])})
+	}
+	s.off = from + int64(n)
+	return evs
+}
+
+// completeUTF8 returns the length of b without a trailing incomplete rune.
+func completeUTF8(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if !utf8.RuneStart(b[i]) {
+			continue
+		}
+		if !utf8.FullRune(b[i:]) {
+			return i
+		}
+		break
+	}
+	return len(b)
+}
+
+// lineAssembler cuts a byte stream into the logical lines format=lines sends.
+type lineAssembler struct {
+	// partial is the line in progress, which began at offset start.
+	partial []byte
+	start   int64
+	// recent is the last outputLineWindow lines sent, oldest first, and
+	// counts how often each appears in it.
+	recent []string
+	counts map[string]int
+}
+
+func newLineAssembler(off int64) *lineAssembler {
+	return &lineAssembler{start: off, counts: map[string]int{}}
+}
+
+// feed takes the next bytes of the stream and returns the lines they finish.
+// final also finishes the line in progress.
+func (l *lineAssembler) feed(data []byte, final bool) []OutputEvent {
+	var evs []OutputEvent
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			l.partial = append(l.partial, data...)
+			if len(l.partial) > maxOutputLine {
+				evs = l.finish(evs)
+			}
+			break
+		}
+		l.partial = append(l.partial, data[:i+1]...)
+		data = data[i+1:]
+		evs = l.finish(evs)
+	}
+	if final && len(l.partial) > 0 {
+		evs = l.finish(evs)
+	}
+	return evs
+}
+
+// finish ends the line in progress, appending its event to evs unless it is
+// blank or a recent repeat.
+func (l *lineAssembler) finish(evs []OutputEvent) []OutputEvent {
+	end := l.start + int64(len(l.partial))
+	text := logicalLine(l.partial)
+	l.partial = l.partial[:0]
+	l.start = end
+	if text == "" || l.counts[text] > 0 {
+		return evs
+	}
+	l.recent = append(l.recent, text)
+	l.counts[text]++
+	if len(l.recent) > outputLineWindow {
+		old := l.recent[0]
+		l.recent = l.recent[1:]
+		if l.counts[old]--; l.counts[old] == 0 {
+			delete(l.counts, old)
+		}
+	}
+	return append(evs, OutputEvent{Type: "line", ID: end, Offset: end, Text: text})
+}
+
+// csiPattern matches every CSI sequence, including the private-mode ones
+// (ESC [ ? 25 l) ansiPattern leaves behind.
+var csiPattern = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]`)
+
+// logicalLine is what a raw line reads as: escapes stripped, only what the
+// last carriage return left, no other control characters, no trailing space.
+func logicalLine(raw []byte) string {
+	s := string(StripANSI(csiPattern.ReplaceAll(raw, nil)))
+	s = strings.TrimRight(s, "\r\n")
+	if i := strings.LastIndexByte(s, '\r'); i >= 0 {
+		s = s[i+1:]
+	}
+	s = strings.Map(func(r rune) rune {
+		if r != '\t' && unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+	return strings.TrimRightFunc(s, unicode.IsSpace)
+}
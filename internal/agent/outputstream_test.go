@@ -0,0 +1,165 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// streamTestAgent returns a registry served over HTTP holding one constructed
+// agent whose ring is ringSize bytes, and a func that prints to it the way
+// readOutput does: into the ring, then to the subscribers.
+func streamTestAgent(t *testing.T, name string, ringSize int) (*Agent, *httptest.Server, func(string)) {
+	t.Helper()
+	a := &Agent{
+		Name:        name,
+		Type:        TypePolecat,
+		outputBuf:   NewRingBuffer(ringSize),
+		attachConns: map[io.Writer]struct{}{},
+		done:        make(chan struct{}),
+	}
+	reg := &Registry{agents: map[string]*Agent{name: a}}
+	mux := http.NewServeMux()
+	reg.RegisterHandlers(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	emit := func(s string) {
+		a.outputBuf.Write([]byte(s))
+		a.attachMu.Lock()
+		for w := range a.attachConns {
+			w.Write([]byte(s))
+		}
+		a.attachMu.Unlock()
+	}
+	return a, srv, emit
+}
+
+// streamEvents opens the stream and delivers its events, in order, on the
+// returned channel, which is closed when the stream ends.
+func streamEvents(t *testing.T, srv *httptest.Server, name, query, lastID string) <-chan OutputEvent {
+	t.Helper()
+	req, _ := http.NewRequest("GET", srv.URL+"/agents/"+name+"/output/stream?"+query, nil)
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("stream: %s", resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	ch := make(chan OutputEvent, 16)
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+		sc := bufio.NewScanner(resp.Body)
+		var ev OutputEvent
+		for sc.Scan() {
+			line := sc.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				ev.Type = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "id: "):
+				ev.ID, _ = strconv.ParseInt(strings.TrimPrefix(line, "id: "), 10, 64)
+			case strings.HasPrefix(line, "data: "):
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev)
+			case line == "" && ev.Type != "":
+				ch <- ev
+				ev = OutputEvent{}
+			}
+		}
+	}()
+	return ch
+}
+
+func nextEvent(t *testing.T, ch <-chan OutputEvent) OutputEvent {
+	t.Helper()
+	select {
+	case ev, ok := <-ch:
+		if !ok {
+			t.Fatal("stream ended early")
+		}
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a stream event")
+	}
+	return OutputEvent{}
+}
+
+// Output arrives as it is printed, a rune split across two writes arrives
+// whole, every event's id is the offset it ends at, and a reconnect with that
+// id picks up exactly where the last stream stopped.
+func TestOutputStreamPushesAndResumes(t *testing.T) {
+	a, srv, emit := streamTestAgent(t, "streamer", OutputRingBytes)
+	emit("old\n")
+	ch := streamEvents(t, srv, "streamer", "offset=0", "")
+
+	if ev := nextEvent(t, ch); ev.Type != "output" || ev.Data != "old\n" || ev.Offset != 0 || ev.ID != 4 {
+		t.Fatalf("backlog event = %+v", ev)
+	}
+	emit("h\xc3")
+	if ev := nextEvent(t, ch); ev.Data != "h" || ev.ID != 5 {
+		t.Fatalf("event before the split rune = %+v", ev)
+	}
+	emit("\xa9llo\n")
+	if ev := nextEvent(t, ch); ev.Data != "éllo\n" || ev.Offset != 5 || ev.ID != 11 {
+		t.Fatalf("event completing the rune = %+v", ev)
+	}
+	emit("more\n")
+	nextEvent(t, ch)
+	close(a.done)
+	if ev := nextEvent(t, ch); ev.Type != "exit" || ev.Offset != 16 {
+		t.Fatalf("final event = %+v, want exit at 16", ev)
+	}
+
+	// A reconnect after the 11 above gets only what followed it.
+	again := streamEvents(t, srv, "streamer", "", "11")
+	if ev := nextEvent(t, again); ev.Data != "more\n" || ev.Offset != 11 {
+		t.Errorf("resumed stream began with %+v", ev)
+	}
+}
+
+// A client further behind than the ring holds is told what it missed.
+func TestOutputStreamReportsAGap(t *testing.T) {
+	a, srv, emit := streamTestAgent(t, "gapped", 16)
+	emit(strings.Repeat("x", 30) + "tail")
+	close(a.done)
+	ch := streamEvents(t, srv, "gapped", "offset=2", "")
+	if ev := nextEvent(t, ch); ev.Type != "gap" || ev.From != 2 || ev.To != 18 {
+		t.Errorf("first event = %+v, want a gap from 2 to 18", ev)
+	}
+	if ev := nextEvent(t, ch); ev.Type != "output" || ev.Offset != 18 || !strings.HasSuffix(ev.Data, "tail") {
+		t.Errorf("after the gap = %+v", ev)
+	}
+}
+
+// Lines are what a reader would see: no escapes, a carriage-return progress
+// line as it ended, no blank lines and no lines a redraw repeated.
+func TestOutputLinesCleanAndDeduplicate(t *testing.T) {
+	l := newLineAssembler(0)
+	in := "\x1b[?25l\x1b[2K> working\r\n" +
+		"\x1b[1A\x1b[2K> work" // split mid-line
+	evs := l.feed([]byte(in), false)
+	evs = append(evs, l.feed([]byte("ing\r\n\rprogress 10%\rprogress 100%  \r\n\r\n\x1b[32mdone\x1b[0m"), false)...)
+	evs = append(evs, l.feed(nil, true)...)
+	var got []string
+	for _, ev := range evs {
+		got = append(got, ev.Text)
+	}
+	if want := []string{"> working", "progress 100%", "done"}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+	if total := int64(len(in) + len("ing\r\n\rprogress 10%\rprogress 100%  \r\n\r\n\x1b[32mdone\x1b[0m")); evs[len(evs)-1].Offset != total {
+		t.Errorf("last line ends at %d, want the end of the stream, %d", evs[len(evs)-1].Offset, total)
+	}
+}
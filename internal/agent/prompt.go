@@ -1015,6 +1015,8 @@ const (
 	metaFieldWorktree
 	metaFieldProvider
 	metaFieldModel
+	metaFieldSandbox
+	metaFieldMode
 )
 
 // metaFieldByKey maps a TOML key name to its bitmask flag. The second return
@@ -1034,6 +1036,10 @@ func metaFieldByKey(key string) (metaFieldFlag, bool) {
 		return metaFieldProvider, true
 	case "model":
 		return metaFieldModel, true
+	case "sandbox":
+		return metaFieldSandbox, true
+	case "mode":
+		return metaFieldMode, true
 	}
 	return 0, false
 }
@@ -1057,6 +1063,13 @@ func metaFieldByKey(key string) (metaFieldFlag, bool) {
 //     every dispatch typing it. Note the chain has no third tier: an absent
 //     model: means pogo passes no model argument at all, which is deliberate.
 //     See internal/agent/model.go.
+//   - sandbox:          sandbox profile ("none", "landlock", "namespaces") this
+//     agent runs under, beating [agents.<type>] and [agents] sandbox. Like
+//     model it is checked at spawn, not here, and an unknown value fails the
+//     spawn rather than running the agent unconfined (user-012).
+//   - mode:             execution mode ("tui", "stream") this agent's harness
+//     is driven in, beating [agents.<type>] and [agents] mode. Checked at
+//     spawn, like sandbox (user-019).
 //
 // provider and model are orthogonal: provider picks which harness binary runs,
 // model picks what that binary talks to.
@@ -1067,6 +1080,8 @@ type AgentMeta struct {
 	Worktree       bool   `json:"worktree,omitempty"`
 	Provider       string `json:"provider,omitempty"`
 	Model          string `json:"model,omitempty"`
+	Sandbox        string `json:"sandbox,omitempty"`
+	Mode           string `json:"mode,omitempty"`
 
 	// explicit is a bitmask of recognized keys that appeared in the
 	// frontmatter. Unexported so it stays out of JSON output; uint8 so
@@ -1269,6 +1284,18 @@ func assignMetaField(meta *AgentMeta, key, raw string) error {
 		// both spawn paths, fatal in both) keeps the blast radius at exactly the
 		// field that is wrong.
 		meta.Model = s
+	case "sandbox":
+		s, err := parseFrontmatterString(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		meta.Sandbox = s
+	case "mode":
+		s, err := parseFrontmatterString(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		meta.Mode = s
 	}
 	meta.explicit |= flag
 	return nil
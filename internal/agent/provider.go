@@ -93,6 +93,11 @@ type Provider struct {
 	// (Claude: the mid-session modal-dismissal watcher.)
 	SessionHook SessionHookFunc
 
+	// Stream is how this harness speaks its structured event-stream mode, for
+	// agents spawned in ModeStream (stream.go, user-019). nil means it has
+	// none, and a stream-mode spawn on it fails.
+	Stream *StreamDialect
+
 	// PTYSize overrides pogo's default PTY winsize. nil = pogo default
 	// (defaultPTYCols × defaultPTYRows).
 	PTYSize *PTYSize
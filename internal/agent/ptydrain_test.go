@@ -50,7 +50,7 @@ func TestStartPTYSurvivesOutputLeftUndrainedPastRevocation(t *testing.T) {
 	const msg = "written into a tty that nobody is draining"
 	for i := 0; i < 3; i++ {
 		cmd := exec.Command("echo", msg)
-		m, s, err := startPTY(cmd, &pty.Winsize{Rows: 40, Cols: 120})
+		m, s, err := startPTY(cmd, &pty.Winsize{Rows: 40, Cols: 120}, false)
 		if err != nil {
 			t.Fatalf("startPTY: %v", err)
 		}
@@ -0,0 +1,52 @@
+package agent
+
+import (
+	"log"
+
+	"github.com/creack/pty"
+
+	"github.com/drellem2/pogo/internal/recording"
+)
+
+// Session recordings (user-015).
+//
+// outputBuf forgets: it is 64KB, and an agent that exits takes it along. When
+// [recording] is on, every agent also has a recorder that keeps its whole PTY
+// stream under $POGO_HOME/agents/<name>/sessions as asciicast v2 — see
+// internal/recording for the format, the layout and retention. readOutput
+// feeds the recorder the chunk it feeds the ring, applyResize records every
+// change of size, and readOutput closes the recording when the stream ends, so
+// a session's file ends where the agent's output did.
+//
+// Each spawn, respawn and adoption starts a new session. An adopted agent's
+// session begins with the holder's replay, which is the only part of what it
+// printed while pogod was down that anything kept.
+
+// SetRecording turns session recording on for every agent started or adopted
+// after it, under limits. nil turns it off; an agent already recording keeps
+// its recorder until its session ends.
+func (r *Registry) SetRecording(limits *recording.Limits) {
+	r.mu.Lock()
+	r.recording = limits
+	r.mu.Unlock()
+}
+
+// startRecordingLocked begins a recording for the agent named name at
+// winsize's size, or returns nil when recording is off or cannot start. A
+// recording that cannot start is logged and the agent starts without one.
+// Called with r.mu held.
+func (r *Registry) startRecordingLocked(name string, winsize *pty.Winsize) *recording.Recorder {
+	if r.recording == nil {
+		return nil
+	}
+	cols, rows := defaultPTYCols, defaultPTYRows
+	if winsize != nil && winsize.Cols > 0 && winsize.Rows > 0 {
+		cols, rows = winsize.Cols, winsize.Rows
+	}
+	rec, err := recording.Start(recording.Dir(name), int(cols), int(rows), name, *r.recording)
+	if err != nil {
+		log.Printf("agent %s: not recording: %v", name, err)
+		return nil
+	}
+	return rec
+}
@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drellem2/pogo/internal/recording"
+)
+
+// TestSpawnedAgentIsRecorded is the wiring: with recording on, a spawned
+// agent's output and resizes reach a recording in its sessions directory, and
+// the recording is closed when the agent's output ends.
+func TestSpawnedAgentIsRecorded(t *testing.T) {
+	reg, err := NewRegistry(shortSocketDir(t))
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	defer reg.StopAll(2 * time.Second)
+	reg.SetRecording(&recording.Limits{})
+
+	name := "rec-cat"
+	t.Cleanup(func() { os.RemoveAll(recording.Dir(name)) })
+	a, err := reg.Spawn(SpawnRequest{
+		Name:    name,
+		Type:    TypePolecat,
+		Command: []string{"sh", "-c", `printf 'recorded line'; read x`},
+	})
+	if err != nil {
+		t.Fatalf("Spawn: %v", err)
+	}
+	if a.recorder == nil {
+		t.Fatal("agent spawned with recording on has no recorder")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !a.ScreenContains("recorded line") {
+		if time.Now().After(deadline) {
+			t.Fatal("output never arrived")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	a.applyResize(90, 30)
+	a.SendRaw("\n")
+	select {
+	case <-a.outputDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent output never ended")
+	}
+
+	s, err := recording.Find(recording.Dir(name), "")
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	f, err := os.Open(s.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	d, err := recording.NewDecoder(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Header.Width != int(defaultPTYCols) || d.Header.Height != int(defaultPTYRows) || d.Header.Title != name {
+		t.Errorf("header = %+v", d.Header)
+	}
+	var out, resizes []string
+	for {
+		ev, err := d.Next()
+		if err != nil {
+			break
+		}
+		if ev.Kind == "r" {
+			resizes = append(resizes, ev.Data)
+		} else {
+			out = append(out, ev.Data)
+		}
+	}
+	if !strings.Contains(strings.Join(out, ""), "recorded line") {
+		t.Errorf("output = %q", out)
+	}
+	if len(resizes) != 1 || resizes[0] != "90x30" {
+		t.Errorf("resizes = %q, want one to 90x30", resizes)
+	}
+}
@@ -189,10 +189,10 @@ func TestTheImportQueryDoesNotReachTheDownloadPath(t *testing.T) {
 	if _, err := exec.LookPath("go"); err != nil {
 		t.Skip("go binary not available")
 	}
-	if !sandbox.ModulePinned() {
+	if !testSandbox.ModulePinned() {
 		t.Skipf("the package sandbox pinned no module cache — the pin fails open by "+
 			"design and there is no cache on this box to share (sandbox root %s)",
-			sandbox.Root)
+			testSandbox.Root)
 	}
 
 	cmd := exec.Command("go", "list", "-f", "{{ join .Imports \"\\n\" }}",
@@ -27,6 +27,9 @@ type RingBuffer struct {
 	pos       int
 	full      bool
 	lastWrite time.Time
+	// total is every byte ever written, so total is the stream offset of the
+	// byte after the newest one retained (see Offset).
+	total int64
 }
 
 // NewRingBuffer creates a ring buffer of the given size.
@@ -43,6 +46,7 @@ func (r *RingBuffer) Write(p []byte) (int, error) {
 	size := len(r.buf)
 
 	r.lastWrite = time.Now()
+	r.total += int64(n)
 
 	if n >= size {
 		// Data larger than buffer — keep only the tail
@@ -80,14 +84,11 @@ func (r *RingBuffer) LastWriteTime() time.Time {
 func (r *RingBuffer) Last(n int) []byte {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	return r.lastLocked(n)
+}
 
-	var total int
-	if r.full {
-		total = len(r.buf)
-	} else {
-		total = r.pos
-	}
-
+func (r *RingBuffer) lastLocked(n int) []byte {
+	total := r.lenLocked()
 	if n > total {
 		n = total
 	}
@@ -116,8 +117,37 @@ func (r *RingBuffer) Last(n int) []byte {
 func (r *RingBuffer) Len() int {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	return r.lenLocked()
+}
+
+func (r *RingBuffer) lenLocked() int {
 	if r.full {
 		return len(r.buf)
 	}
 	return r.pos
 }
+
+// Offset returns how many bytes have ever been written: the stream offset the
+// next write will start at. Offsets count from the ring's creation, so they
+// start over when a respawn gives the agent a new ring.
+func (r *RingBuffer) Offset() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.total
+}
+
+// Since returns the output from stream offset off up to the newest byte, and
+// the offset the returned bytes start at. That is off itself when the ring
+// still holds it; when the ring has already overwritten it, the result starts
+// at the oldest byte retained, and the caller can see the gap as from > off.
+// An off past the newest byte — from a ring this one replaced — is treated
+// the same way, as a gap back to the oldest byte retained.
+func (r *RingBuffer) Since(off int64) (data []byte, from int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	oldest := r.total - int64(r.lenLocked())
+	if off < oldest || off > r.total {
+		off = oldest
+	}
+	return r.lastLocked(int(r.total - off)), off
+}
@@ -98,3 +98,25 @@ func TestRingBufferLastWriteTimeInvariant(t *testing.T) {
 		t.Errorf("write did not advance lastWrite: %v not after %v", got, afterWrite)
 	}
 }
+
+func TestRingBufferSince(t *testing.T) {
+	rb := NewRingBuffer(10)
+	rb.Write([]byte("0123456789abc"))
+	if rb.Offset() != 13 {
+		t.Errorf("Offset() = %d, want 13", rb.Offset())
+	}
+	if data, from := rb.Since(5); string(data) != "56789abc" || from != 5 {
+		t.Errorf("Since(5) = %q from %d", data, from)
+	}
+	// Offset 1 was overwritten: the answer starts at the oldest byte kept.
+	if data, from := rb.Since(1); string(data) != "3456789abc" || from != 3 {
+		t.Errorf("Since(1) = %q from %d, want the whole ring from 3", data, from)
+	}
+	// An offset from a ring this one replaced is a gap too.
+	if _, from := rb.Since(99); from != 3 {
+		t.Errorf("Since(99) from %d, want 3", from)
+	}
+	if data, from := rb.Since(13); len(data) != 0 || from != 13 {
+		t.Errorf("Since(13) = %q from %d, want nothing", data, from)
+	}
+}
@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/drellem2/pogo/internal/config"
+	"github.com/drellem2/pogo/internal/platform/sandbox"
+)
+
+// Sandbox profiles for agents (user-012).
+//
+// Every agent used to run with pogod's whole authority, in a worktree that was
+// a convention rather than a boundary: a polecat acting on a prompt-injected
+// ticket body could write ~/.ssh/authorized_keys or ~/.zshrc as easily as its
+// own checkout. A profile confines it — see internal/platform/sandbox for what
+// each mode allows — and is chosen per spawn:
+//
+//  1. [agents.<type>] sandbox
+//  2. [agents] sandbox
+//  3. none
+//
+// sandbox: in the agent prompt's frontmatter may tighten that and may not
+// loosen it. A prompt is text anyone with a branch can edit, and a single
+// "sandbox: none" line must not undo the profile the operator configured. A
+// looser value is logged and ignored.
+//
+// The trees a profile adds (sandbox_read_only, sandbox_writable) come from
+// [agents] whichever tier picked the mode. The tree it confines the agent TO is
+// the agent's own: its worktree, or its working directory when it has none.
+//
+// Unlike the provider chain, which warns and falls back on an unknown id, an
+// unknown mode at any tier fails the spawn. Falling back would mean running
+// unconfined an agent someone asked to confine, and that is the one outcome
+// this must never produce quietly.
+
+// AgentSandboxConfig supplies the configured sandbox profile for an agent type.
+// *config.AgentsConfig implements it.
+type AgentSandboxConfig interface {
+	AgentSandbox(agentType string) config.SandboxConfig
+}
+
+// SetSandboxConfig sets where spawns read the configured sandbox profile from.
+// pogod passes its [agents] config; nil confines no agent whose prompt does
+// not ask.
+func (r *Registry) SetSandboxConfig(c AgentSandboxConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sandboxCfg = c
+}
+
+// resolveSandboxLocked returns the profile req's agent runs under, or nil for
+// none. Called by Spawn with r.mu held.
+func (r *Registry) resolveSandboxLocked(req SpawnRequest) (*sandbox.Profile, error) {
+	var cfg config.SandboxConfig
+	if r.sandboxCfg != nil {
+		cfg = r.sandboxCfg.AgentSandbox(string(req.Type))
+	}
+	mode, err := sandbox.ParseMode(cfg.Mode)
+	if err != nil {
+		return nil, fmt.Errorf("agent %s: config: %w", req.Name, err)
+	}
+	tier := "config"
+	if req.Sandbox != "" {
+		asked, err := sandbox.ParseMode(req.Sandbox)
+		if err != nil {
+			return nil, fmt.Errorf("agent %s: sandbox: frontmatter: %w", req.Name, err)
+		}
+		if asked.Confines(mode) {
+			mode, tier = asked, "sandbox: frontmatter"
+		} else {
+			log.Printf("WARNING: agent %s: prompt asks for sandbox %s, looser than the configured %s; keeping %s",
+				req.Name, asked, mode, mode)
+		}
+	}
+	if mode == sandbox.ModeNone {
+		return nil, nil
+	}
+	root := req.WorktreeDir
+	if root == "" {
+		root = req.Dir
+	}
+	if root == "" {
+		return nil, fmt.Errorf("agent %s: sandbox %s needs a working directory to confine it to", req.Name, mode)
+	}
+	p := &sandbox.Profile{Mode: mode, Root: root, ReadOnly: cfg.ReadOnly, Writable: cfg.Writable,
+		Hidden: config.APISecretPaths()}
+	log.Printf("agent %s: sandbox %s (from %s)", req.Name, p, tier)
+	return p, nil
+}
@@ -0,0 +1,129 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drellem2/pogo/internal/config"
+	"github.com/drellem2/pogo/internal/platform/sandbox"
+)
+
+type sandboxConfigStub map[string]config.SandboxConfig
+
+func (s sandboxConfigStub) AgentSandbox(agentType string) config.SandboxConfig {
+	return s[agentType]
+}
+
+// TestUnknownSandboxFailsTheSpawn: a profile name nobody recognises, from
+// frontmatter or from config, is a failed spawn — never an unconfined agent.
+func TestUnknownSandboxFailsTheSpawn(t *testing.T) {
+	reg, err := NewRegistry(shortSocketDir(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { reg.StopAll(2 * time.Second) })
+	reg.SetSandboxConfig(sandboxConfigStub{"crew": {Mode: "landlok"}})
+
+	for _, req := range []SpawnRequest{
+		{Name: "fm-typo", Type: TypePolecat, Dir: t.TempDir(), Sandbox: "namespace", Command: []string{"true"}},
+		{Name: "cfg-typo", Type: TypeCrew, Dir: t.TempDir(), Command: []string{"true"}},
+	} {
+		if _, err := reg.Spawn(req); err == nil || !strings.Contains(err.Error(), "unknown sandbox profile") {
+			t.Errorf("Spawn(%s) = %v, want an unknown-profile error", req.Name, err)
+		}
+		if reg.Get(req.Name) != nil {
+			t.Errorf("%s is registered after a failed spawn", req.Name)
+		}
+	}
+}
+
+// TestFrontmatterCannotLoosenTheSandbox: a prompt's sandbox: may ask for more
+// confinement than config gives its type, never less.
+func TestFrontmatterCannotLoosenTheSandbox(t *testing.T) {
+	reg, err := NewRegistry(shortSocketDir(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.SetSandboxConfig(sandboxConfigStub{"polecat": {Mode: "landlock"}})
+	for _, tc := range []struct {
+		typ         AgentType
+		frontmatter string
+		want        sandbox.Mode
+	}{
+		{TypePolecat, "", sandbox.ModeLandlock},
+		{TypePolecat, "none", sandbox.ModeLandlock},
+		{TypePolecat, "landlock", sandbox.ModeLandlock},
+		{TypePolecat, "namespaces", sandbox.ModeNamespaces},
+		{TypeCrew, "landlock", sandbox.ModeLandlock},
+		{TypeCrew, "none", sandbox.ModeNone},
+	} {
+		p, err := reg.resolveSandboxLocked(SpawnRequest{Name: "x", Type: tc.typ, Dir: t.TempDir(), Sandbox: tc.frontmatter})
+		if err != nil {
+			t.Fatalf("%s with sandbox: %q: %v", tc.typ, tc.frontmatter, err)
+		}
+		got := sandbox.ModeNone
+		if p != nil {
+			got = p.Mode
+		}
+		if got != tc.want {
+			t.Errorf("%s with sandbox: %q runs under %s, want %s", tc.typ, tc.frontmatter, got, tc.want)
+		}
+	}
+}
+
+// TestSandboxedPolecatKeepsToItsWorktree: a polecat spawned under the
+// configured landlock profile writes its worktree and not the home directory
+// around it, and reports the profile it runs under.
+func TestSandboxedPolecatKeepsToItsWorktree(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	wt := filepath.Join(home, "worktree")
+	if err := os.MkdirAll(wt, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// $TMPDIR is writable under landlock, and the home above sits in the
+	// test's own temp dir; point it into the worktree.
+	t.Setenv("TMPDIR", wt)
+	reg, err := NewRegistry(shortSocketDir(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { reg.StopAll(2 * time.Second) })
+	reg.SetSandboxConfig(sandboxConfigStub{"polecat": {Mode: "landlock"}})
+
+	a, err := reg.Spawn(SpawnRequest{
+		Name:        "confined",
+		Type:        TypePolecat,
+		WorktreeDir: wt,
+		Dir:         wt,
+		Command: []string{"sh", "-c",
+			`echo x > "$HOME/escape" 2>/dev/null && echo ESCAPED; echo x > inside && echo INSIDE-OK`},
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "not available") {
+			t.Skip(err)
+		}
+		t.Fatalf("Spawn: %v", err)
+	}
+	if a.Sandbox == nil || a.Sandbox.Mode != sandbox.ModeLandlock || a.Sandbox.Root != wt {
+		t.Errorf("agent sandbox = %+v, want landlock rooted at %s", a.Sandbox, wt)
+	}
+	select {
+	case <-a.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("confined agent never exited")
+	}
+	out := string(a.RecentOutput(4096))
+	if !strings.Contains(out, "INSIDE-OK") {
+		t.Errorf("the worktree was not writable: %q", out)
+	}
+	if strings.Contains(out, "ESCAPED") {
+		t.Errorf("the agent wrote outside its worktree: %q", out)
+	}
+	if _, err := os.Stat(filepath.Join(home, "escape")); err == nil {
+		t.Error("a file was written in the home directory")
+	}
+}
@@ -0,0 +1,98 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/creack/pty"
+
+	"github.com/drellem2/pogo/internal/vt"
+)
+
+// The rendered screen (user-014).
+//
+// outputBuf keeps the bytes an agent's harness wrote; a.screen keeps what
+// those bytes would leave on a terminal. The two answer different questions.
+// The ring is the record — what the harness said, in order, for a human to
+// replay or a detector to count. The screen is the state — what a human
+// attached right now would see — and it is the only honest input to a
+// detector that asks "is this dialog up?" of a differential-render TUI, whose
+// stream is redraw fragments addressed by cursor movement (see internal/vt).
+//
+// readOutput feeds the screen before it fans the chunk out, so a subscriber
+// reading the screen from its Write sees the chunk it was handed already
+// applied. The screen is sized with the PTY at spawn and follows every resize
+// applyResize makes; it has its own lock, so reading it never waits on a.mu.
+
+// Screen returns what is on the agent's terminal now. An agent with no screen
+// — one built by a test, or a record of an agent that exited while pogod was
+// down — returns a zero Snapshot.
+func (a *Agent) Screen() vt.Snapshot {
+	if a.screen == nil {
+		return vt.Snapshot{}
+	}
+	return a.screen.Snapshot()
+}
+
+// ScreenContains reports whether marker is on the agent's terminal now,
+// compared as vt.Snapshot.Contains compares: whitespace-insensitive, across
+// rows. It is the matcher a provider's modal detector should use in place of
+// scanning StripANSI output.
+func (a *Agent) ScreenContains(marker string) bool {
+	if a.screen == nil {
+		return false
+	}
+	return a.screen.Contains(marker)
+}
+
+// ScreenGeneration returns the screen's vt.Screen.Generation: a counter that
+// moves when the text on the agent's terminal may have changed. A reader
+// matching against Screen on every chunk uses it to skip the render when
+// nothing changed. An agent with no screen returns 0.
+func (a *Agent) ScreenGeneration() uint64 {
+	if a.screen == nil {
+		return 0
+	}
+	return a.screen.Generation()
+}
+
+// newScreen returns a screen the size of winsize, or pogo's default PTY size
+// when winsize is nil.
+func newScreen(winsize *pty.Winsize) *vt.Screen {
+	cols, rows := defaultPTYCols, defaultPTYRows
+	if winsize != nil && winsize.Cols > 0 && winsize.Rows > 0 {
+		cols, rows = winsize.Cols, winsize.Rows
+	}
+	return vt.New(int(cols), int(rows))
+}
+
+// handleScreen serves GET /agents/{name}/screen: the agent's rendered screen,
+// as plain text by default or as a vt.Snapshot with ?format=json. The text
+// form is what a human would see attached, less the blank rows at the bottom;
+// the JSON form adds the size, the cursor, whether the cursor is shown and
+// whether the alternate screen is up.
+func (r *Registry) handleScreen(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		http.Error(w, "", http.StatusMethodNotAllowed)
+		return
+	}
+	name := req.PathValue("name")
+	a := r.Get(name)
+	if a == nil {
+		http.Error(w, fmt.Sprintf("agent %q not found", name), http.StatusNotFound)
+		return
+	}
+	snap := a.Screen()
+	switch format := req.URL.Query().Get("format"); format {
+	case "", "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, snap.Text())
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(snap)
+	default:
+		http.Error(w, fmt.Sprintf("format %q: want text or json", format), http.StatusBadRequest)
+	}
+}
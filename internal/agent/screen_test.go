@@ -0,0 +1,98 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/drellem2/pogo/internal/vt"
+)
+
+func getScreen(reg *Registry, name, query string) *httptest.ResponseRecorder {
+	url := "/agents/" + name + "/screen"
+	if query != "" {
+		url += "?" + query
+	}
+	req := httptest.NewRequest("GET", url, nil)
+	req.SetPathValue("name", name)
+	rr := httptest.NewRecorder()
+	reg.handleScreen(rr, req)
+	return rr
+}
+
+// TestScreenEndpointRendersTheGrid drives /agents/{name}/screen against an
+// agent whose harness drew a footer the way a TUI does — columns placed with
+// cursor-forward escapes — and then redrew part of the screen: the endpoint
+// answers with what is on the screen, not with the stream.
+func TestScreenEndpointRendersTheGrid(t *testing.T) {
+	a := &Agent{Name: "tui", Type: TypePolecat, screen: vt.New(40, 6)}
+	a.screen.Write([]byte("\x1b[?1049h\x1b[1;1HWorking on it\x1b[3;1H1:Bad\x1b[3C2:Fine\x1b[1;1H\x1b[2KDone"))
+	reg := &Registry{agents: map[string]*Agent{"tui": a}}
+
+	rr := getScreen(reg, "tui", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rr.Code, rr.Body)
+	}
+	if got, want := rr.Body.String(), "Done\n\n1:Bad   2:Fine\n"; got != want {
+		t.Errorf("text = %q, want %q", got, want)
+	}
+
+	rr = getScreen(reg, "tui", "format=json")
+	var snap vt.Snapshot
+	if err := json.Unmarshal(rr.Body.Bytes(), &snap); err != nil {
+		t.Fatalf("decode %q: %v", rr.Body, err)
+	}
+	if snap.Cols != 40 || snap.Rows != 6 || len(snap.Lines) != 6 || !snap.AltScreen {
+		t.Errorf("snapshot = %+v, want a 40x6 alternate screen", snap)
+	}
+	if snap.Cursor != (vt.Cursor{Row: 0, Col: 4}) {
+		t.Errorf("cursor = %+v, want 0,4", snap.Cursor)
+	}
+
+	if rr := getScreen(reg, "tui", "format=html"); rr.Code != http.StatusBadRequest {
+		t.Errorf("format=html: status = %d, want 400", rr.Code)
+	}
+	if rr := getScreen(reg, "nobody", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("unknown agent: status = %d, want 404", rr.Code)
+	}
+}
+
+// TestSpawnedAgentKeepsAScreen is the wiring: a spawned agent's PTY output
+// reaches its screen, at the PTY's size, and a resize reaches it too.
+func TestSpawnedAgentKeepsAScreen(t *testing.T) {
+	reg, err := NewRegistry(shortSocketDir(t))
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	defer reg.StopAll(2 * time.Second)
+
+	a, err := reg.Spawn(SpawnRequest{
+		Name:    "screen-cat",
+		Type:    TypePolecat,
+		Command: []string{"sh", "-c", `printf '\033[2J\033[5;10HStop\033[3Cand wait'; exec cat`},
+	})
+	if err != nil {
+		t.Fatalf("Spawn: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !a.ScreenContains("Stop and wait") {
+		if time.Now().After(deadline) {
+			t.Fatalf("marker never reached the screen: %q", a.Screen().Lines)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	snap := a.Screen()
+	if snap.Cols != int(defaultPTYCols) || snap.Rows != int(defaultPTYRows) {
+		t.Errorf("screen is %dx%d, want the PTY's %dx%d", snap.Cols, snap.Rows, defaultPTYCols, defaultPTYRows)
+	}
+	if got := snap.Lines[4]; got != "         Stop   and wait" {
+		t.Errorf("row 4 = %q", got)
+	}
+
+	a.applyResize(80, 24)
+	if snap := a.Screen(); snap.Cols != 80 || snap.Rows != 24 {
+		t.Errorf("after resize the screen is %dx%d, want 80x24", snap.Cols, snap.Rows)
+	}
+}
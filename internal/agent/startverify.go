@@ -2,6 +2,8 @@ package agent
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"time"
 
@@ -118,6 +120,11 @@ func (r *Registry) startVerifyMaxAttemptsOrDefault() int {
 // input, and it is delivered ONLY while the agent is provably unstarted —
 // never on a quiescence heuristic (see the package doc).
 func (r *Registry) verifyStartAndRenudge(a *Agent) {
+	// A stream-mode agent's kickoff is a message its harness reads whole;
+	// there is no paste buffer for a bare CR to flush (stream.go).
+	if a.stream != nil {
+		return
+	}
 	started, reason, ok := r.startedSignal(a)
 	if !ok {
 		return
@@ -126,11 +133,32 @@ func (r *Registry) verifyStartAndRenudge(a *Agent) {
 	delay := r.startVerifyDelayOrDefault()
 	maxAttempts := r.startVerifyMaxAttemptsOrDefault()
 
+	// The work item's trace shows how long the polecat took to start and how
+	// many renudges that took (user-023). It ends in error when the kickoff
+	// provably never took: unstarted at the last attempt, or a renudge that
+	// could not be delivered.
+	span := a.startSpan("polecat.start_verify")
+	span.SetAttr("signal", reason)
+	outcome, renudges := "unstarted", 0
+	defer func() {
+		span.SetAttr("outcome", outcome)
+		span.SetAttr("renudges", renudges)
+		var err error
+		switch outcome {
+		case "unstarted":
+			err = fmt.Errorf("not started after %d renudge(s)", renudges)
+		case "renudge_failed":
+			err = errors.New("renudge could not be delivered")
+		}
+		span.End(err)
+	}()
+
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
 		// Wait out the start window, but abandon at once if the agent exits —
 		// there is no PTY to renudge and no work will ever be done.
 		select {
 		case <-a.done:
+			outcome = "exited"
 			return
 		case <-time.After(delay):
 		}
@@ -142,9 +170,11 @@ func (r *Registry) verifyStartAndRenudge(a *Agent) {
 			// watcher and the mayor's own unstarted-check. Stop here.
 			log.Printf("agent %s: start-verify query for %s failed: %v — skipping auto-renudge",
 				a.Name, a.WorkItemID, err)
+			outcome = "inconclusive"
 			return
 		}
 		if hasStarted {
+			outcome = "started"
 			return // started — healthy, nothing to do.
 		}
 
@@ -165,8 +195,10 @@ func (r *Registry) verifyStartAndRenudge(a *Agent) {
 		}
 		if err := a.Nudge(""); err != nil {
 			log.Printf("agent %s: auto-renudge failed: %v", a.Name, err)
+			outcome = "renudge_failed"
 			return
 		}
+		renudges++
 		emitAutoRenudge(a, attempt, maxAttempts, reason)
 	}
 }
@@ -0,0 +1,377 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/drellem2/pogo/internal/config"
+	"github.com/drellem2/pogo/internal/events"
+)
+
+// Structured-stream execution mode (user-019).
+//
+// A polecat has nobody watching its terminal, yet pogod drives it the way a
+// person drives a TUI: it types a nudge, waits for the screen to settle, and
+// infers from receipt hooks, transcripts and screen heuristics whether the
+// harness took it. Every wedge, deaf-agent and stall detector in this package
+// is a guess about a state the harness knows exactly and was never asked for.
+//
+// In stream mode it is asked. The harness runs in its structured mode —
+// newline-delimited JSON events on stdout, structured messages on stdin — and
+// pogod reads turns, tool calls, usage and errors off the stream instead of
+// off the screen. A nudge is written as one input message; "mid-turn" is the
+// harness saying so; and the turn's usage is an event, not a transcript scrape.
+//
+// The child still runs on a PTY, in raw mode, so everything else about an
+// agent — the holder, attach, the output ring, recording — is unchanged: it
+// simply carries JSON. Raw mode is what makes that safe. A cooked tty would
+// echo every input message back into the output, rewrite newlines, and cap an
+// input line at the canonical-mode limit (1024 bytes on macOS), which a
+// nudge with a task in it exceeds.
+//
+// The mode is chosen per spawn — the prompt's mode: frontmatter, then
+// [agents.<type>] mode, then [agents] mode, then tui — and, like the sandbox, an unknown mode
+// or a stream spawn on a provider that declares no StreamDialect fails the
+// spawn rather than quietly running the TUI someone asked to replace.
+
+// Execution modes, as written in config and SpawnRequest.Mode.
+const (
+	ModeTUI    = "tui"
+	ModeStream = "stream"
+)
+
+// maxStreamLine bounds one stream line. A tool result can be large; a line
+// past this is dropped and counted rather than buffered without limit.
+const maxStreamLine = 4 << 20
+
+// StreamDialect is how a provider speaks its structured mode: the flags that
+// turn it on, how one line of its output reads as StreamEvents, and how a
+// message is written to its input.
+type StreamDialect struct {
+	// Flags are appended to the spawn command to select the structured mode.
+	Flags []string
+	// Decode reads one output line. A line that is not an event — a warning
+	// the harness printed, a half line from before an adoption — returns
+	// ok false; one that is an event of no interest returns no events.
+	Decode func(line []byte) (evs []StreamEvent, ok bool)
+	// Encode renders a message as one input line, without the newline.
+	Encode func(message string) ([]byte, error)
+}
+
+// StreamEventKind is what a StreamEvent reports.
+type StreamEventKind string
+
+const (
+	// StreamInit is the harness's session header: SessionID and Model.
+	StreamInit StreamEventKind = "init"
+	// StreamText is assistant text.
+	StreamText StreamEventKind = "text"
+	// StreamToolCall is a tool the harness is about to run, named by Tool.
+	StreamToolCall StreamEventKind = "tool_call"
+	// StreamToolResult is a tool's result; IsError when the tool failed.
+	StreamToolResult StreamEventKind = "tool_result"
+	// StreamTurnEnd ends a turn, with its Usage; IsError when the turn failed.
+	StreamTurnEnd StreamEventKind = "turn_end"
+	// StreamError is an error the harness reported outside a turn's result.
+	StreamError StreamEventKind = "error"
+)
+
+// StreamEvent is one thing a harness's stream reported, in pogo's terms.
+type StreamEvent struct {
+	Kind      StreamEventKind `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	Tool      string          `json:"tool,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+	Usage     *StreamUsage    `json:"usage,omitempty"`
+	SessionID string          `json:"session_id,omitempty"`
+	Model     string          `json:"model,omitempty"`
+}
+
+// StreamUsage is what a turn cost, or the sum over a session.
+type StreamUsage struct {
+	InputTokens     int64   `json:"input_tokens,omitempty"`
+	OutputTokens    int64   `json:"output_tokens,omitempty"`
+	CacheReadTokens int64   `json:"cache_read_tokens,omitempty"`
+	CostUSD         float64 `json:"cost_usd,omitempty"`
+}
+
+func (u *StreamUsage) add(v StreamUsage) {
+	u.InputTokens += v.InputTokens
+	u.OutputTokens += v.OutputTokens
+	u.CacheReadTokens += v.CacheReadTokens
+	u.CostUSD += v.CostUSD
+}
+
+// NativeStream is pogo's own dialect, for a harness written to speak it (a
+// config-declared provider with stream = true, or a test's fake harness):
+// each output line is a StreamEvent as JSON, and each input line is
+// {"type":"user","text":"<message>"}.
+var NativeStream = StreamDialect{
+	Decode: func(line []byte) ([]StreamEvent, bool) {
+		var ev StreamEvent
+		if err := json.Unmarshal(line, &ev); err != nil || ev.Kind == "" {
+			return nil, false
+		}
+		return []StreamEvent{ev}, true
+	},
+	Encode: func(message string) ([]byte, error) {
+		return json.Marshal(struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}{"user", message})
+	},
+}
+
+// StreamStatus is a stream-mode agent's state as its harness reported it. It
+// is on AgentInfo as "stream".
+type StreamStatus struct {
+	SessionID string `json:"session_id,omitempty"`
+	Model     string `json:"model,omitempty"`
+	// InTurn is true from the moment a message is written until the harness
+	// ends the turn it started.
+	InTurn        bool        `json:"in_turn"`
+	TurnStartedAt time.Time   `json:"turn_started_at,omitempty"`
+	Turns         int         `json:"turns"`
+	ToolCalls     int         `json:"tool_calls"`
+	LastTool      string      `json:"last_tool,omitempty"`
+	Errors        int         `json:"errors,omitempty"`
+	LastError     string      `json:"last_error,omitempty"`
+	Usage         StreamUsage `json:"usage"`
+	LastEventAt   time.Time   `json:"last_event_at,omitempty"`
+	// Undecoded counts output lines that were not events, and lines dropped
+	// for length. A harness whose every line lands here is not in its
+	// structured mode at all — its flags are wrong.
+	Undecoded int `json:"undecoded,omitempty"`
+}
+
+// streamState follows one agent's stream. readOutput feeds it every chunk;
+// it keeps the partial line between chunks, and the status under its own lock
+// so AgentInfo never waits on the reader.
+type streamState struct {
+	a       *Agent
+	dialect *StreamDialect
+
+	partial  []byte
+	dropping bool
+
+	mu     sync.Mutex
+	status StreamStatus
+}
+
+// armStream attaches a streamState to a when it runs in stream mode. Called
+// before readOutput starts, wherever an agent is built.
+func (a *Agent) armStream() {
+	if a.Mode != ModeStream || a.provider == nil || a.provider.Stream == nil {
+		return
+	}
+	a.stream = &streamState{a: a, dialect: a.provider.Stream}
+}
+
+// StreamStatus returns the agent's stream state, and false when it does not
+// run in stream mode.
+func (a *Agent) StreamStatus() (StreamStatus, bool) {
+	if a.stream == nil {
+		return StreamStatus{}, false
+	}
+	return a.stream.snapshot(), true
+}
+
+func (s *streamState) snapshot() StreamStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// feed takes one chunk of output. Only readOutput calls it.
+func (s *streamState) feed(data []byte) {
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			if !s.dropping {
+				s.partial = append(s.partial, data...)
+			}
+			if len(s.partial) > maxStreamLine {
+				s.partial, s.dropping = nil, true
+				s.mu.Lock()
+				s.status.Undecoded++
+				s.mu.Unlock()
+			}
+			return
+		}
+		line := data[:i]
+		if len(s.partial) > 0 {
+			line = append(s.partial, line...)
+		}
+		data = data[i+1:]
+		if s.dropping {
+			s.dropping = false
+		} else {
+			s.line(bytes.TrimRight(line, "\r"))
+		}
+		s.partial = s.partial[:0]
+	}
+}
+
+func (s *streamState) line(line []byte) {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return
+	}
+	evs, ok := s.dialect.Decode(line)
+	now := time.Now()
+	s.mu.Lock()
+	if !ok {
+		s.status.Undecoded++
+		s.mu.Unlock()
+		return
+	}
+	var emit []events.Event
+	for _, ev := range evs {
+		s.status.LastEventAt = now
+		switch ev.Kind {
+		case StreamInit:
+			s.status.SessionID, s.status.Model = ev.SessionID, ev.Model
+		case StreamToolCall:
+			s.status.ToolCalls++
+			s.status.LastTool = ev.Tool
+			emit = append(emit, s.event("agent_tool_call", map[string]any{"tool": ev.Tool}))
+		case StreamTurnEnd:
+			details := map[string]any{"turn": s.status.Turns + 1, "is_error": ev.IsError}
+			if !s.status.TurnStartedAt.IsZero() {
+				details["duration_ms"] = now.Sub(s.status.TurnStartedAt).Milliseconds()
+			}
+			if ev.Usage != nil {
+				s.status.Usage.add(*ev.Usage)
+				details["input_tokens"] = ev.Usage.InputTokens
+				details["output_tokens"] = ev.Usage.OutputTokens
+				details["cache_read_tokens"] = ev.Usage.CacheReadTokens
+				details["cost_usd"] = ev.Usage.CostUSD
+			}
+			if ev.IsError {
+				s.noteErrorLocked(ev.Text)
+				details["error"] = ev.Text
+			}
+			s.status.Turns++
+			s.status.InTurn = false
+			s.status.TurnStartedAt = time.Time{}
+			emit = append(emit, s.event("agent_turn_completed", details))
+		case StreamError:
+			s.noteErrorLocked(ev.Text)
+			emit = append(emit, s.event("agent_stream_error", map[string]any{"error": ev.Text}))
+		}
+	}
+	s.mu.Unlock()
+	for _, e := range emit {
+		events.Emit(context.Background(), e)
+	}
+}
+
+func (s *streamState) noteErrorLocked(text string) {
+	s.status.Errors++
+	s.status.LastError = text
+}
+
+func (s *streamState) event(eventType string, details map[string]any) events.Event {
+	if s.a.WorkItemID != "" {
+		details["work_item_id"] = s.a.WorkItemID
+	}
+	return events.Event{
+		EventType: eventType,
+		Agent:     s.a.eventAgent(),
+		Repo:      s.a.SourceRepo,
+		Details:   details,
+	}
+}
+
+// streamInput writes message to a stream-mode agent as one input message and
+// marks a turn started. An empty message is a no-op: the TUI's bare submit
+// has no meaning to a harness that reads whole messages.
+func (a *Agent) streamInput(message string) error {
+	if message == "" {
+		return nil
+	}
+	line, err := a.stream.dialect.Encode(message)
+	if err != nil {
+		return fmt.Errorf("encode stream input: %w", err)
+	}
+	// The turn starts before the write, not after it: a harness can answer
+	// faster than this goroutine gets back from Write, and a turn_end read
+	// before InTurn was set would leave the agent mid-turn forever.
+	s := a.stream
+	s.mu.Lock()
+	started := !s.status.InTurn
+	if started {
+		s.status.InTurn = true
+		s.status.TurnStartedAt = time.Now()
+	}
+	turn := s.status.Turns + 1
+	s.mu.Unlock()
+
+	a.mu.Lock()
+	w := a.ptyLocked()
+	if w == nil {
+		err = fmt.Errorf("agent %q has no PTY", a.Name)
+	} else if _, err = w.Write(append(line, '\n')); err != nil {
+		err = fmt.Errorf("write to stream: %w", err)
+	}
+	a.mu.Unlock()
+	if err != nil {
+		if started {
+			s.mu.Lock()
+			s.status.InTurn = false
+			s.status.TurnStartedAt = time.Time{}
+			s.mu.Unlock()
+		}
+		return err
+	}
+	if started {
+		events.Emit(context.Background(), s.event("agent_turn_started", map[string]any{"turn": turn}))
+	}
+	return nil
+}
+
+// AgentModeConfig supplies the configured execution mode for an agent type.
+// *config.AgentsConfig implements it.
+type AgentModeConfig interface {
+	AgentMode(agentType string) string
+}
+
+var _ AgentModeConfig = (*config.AgentsConfig)(nil)
+
+// SetModeConfig sets where spawns read the configured execution mode from.
+// pogod passes its [agents] config; nil runs every agent in the TUI unless its
+// prompt asks otherwise.
+func (r *Registry) SetModeConfig(c AgentModeConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.modeCfg = c
+}
+
+// resolveModeLocked returns the mode req's agent runs in under provider.
+// Called by Spawn with r.mu held.
+func (r *Registry) resolveModeLocked(req SpawnRequest, provider *Provider) (string, error) {
+	mode, tier := req.Mode, "mode: frontmatter"
+	if mode == "" && r.modeCfg != nil {
+		mode, tier = r.modeCfg.AgentMode(string(req.Type)), "config"
+	}
+	switch mode {
+	case "", ModeTUI:
+		return ModeTUI, nil
+	case ModeStream:
+		if provider == nil || provider.Stream == nil {
+			id := DefaultProviderID
+			if provider != nil {
+				id = provider.ID
+			}
+			return "", fmt.Errorf("agent %s: %s: mode stream: provider %q has no structured stream mode", req.Name, tier, id)
+		}
+		log.Printf("agent %s: stream mode (from %s)", req.Name, tier)
+		return ModeStream, nil
+	default:
+		return "", fmt.Errorf("agent %s: %s: unknown mode %q (want %q or %q)", req.Name, tier, mode, ModeTUI, ModeStream)
+	}
+}
@@ -0,0 +1,209 @@
+package agent
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeStreamHarness writes a harness that speaks NativeStream only when
+// started with --stream: it announces itself, and answers each input line with
+// a tool call and a turn end, after appending the line to in.
+func fakeStreamHarness(t *testing.T, in string) []string {
+	t.Helper()
+	script := filepath.Join(t.TempDir(), "harness.sh")
+	body := `#!/bin/sh
+[ "$1" = --stream ] || { echo "interactive mode"; sleep 30; exit 1; }
+echo 'starting up'
+echo '{"type":"init","session_id":"s-1","model":"fake-1"}'
+while IFS= read -r line; do
+  printf '%s\n' "$line" >> '` + in + `'
+  echo '{"type":"tool_call","tool":"Bash"}'
+  echo '{"type":"tool_result"}'
+  echo '{"type":"turn_end","usage":{"input_tokens":10,"output_tokens":5,"cost_usd":0.25}}'
+done
+`
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return []string{"sh", script}
+}
+
+func fakeStreamProvider() *Provider {
+	stream := NativeStream
+	stream.Flags = []string{"--stream"}
+	return &Provider{ID: "fake", Binary: "sh", Stream: &stream}
+}
+
+func waitStream(t *testing.T, a *Agent, what string, cond func(StreamStatus) bool) StreamStatus {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		st, ok := a.StreamStatus()
+		if !ok {
+			t.Fatal("agent is not in stream mode")
+		}
+		if cond(st) {
+			return st
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stream never reached %s: %+v\noutput: %q", what, st, a.RecentOutput(4096))
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// A stream-mode agent is started with its dialect's flags on a raw PTY, takes
+// its initial nudge and every later one as a structured message, and its turns,
+// tool calls and usage come off the stream into its state and the event log.
+func TestStreamModeDrivesAFakeHarness(t *testing.T) {
+	path := useTempEventLog(t)
+	reg, err := NewRegistry(shortSocketDir(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reg.StopAll(2 * time.Second)
+
+	in := filepath.Join(t.TempDir(), "input.jsonl")
+	a, err := reg.Spawn(SpawnRequest{
+		Name:         "streamer",
+		Type:         TypePolecat,
+		Command:      fakeStreamHarness(t, in),
+		Provider:     fakeStreamProvider(),
+		Mode:         ModeStream,
+		InitialNudge: "do the thing",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Mode != ModeStream || a.Command[len(a.Command)-1] != "--stream" {
+		t.Fatalf("mode %q, command %q", a.Mode, a.Command)
+	}
+
+	st := waitStream(t, a, "one turn", func(s StreamStatus) bool { return s.Turns == 1 })
+	if st.SessionID != "s-1" || st.Model != "fake-1" || st.InTurn || st.ToolCalls != 1 || st.LastTool != "Bash" {
+		t.Errorf("after the first turn: %+v", st)
+	}
+	if st.Undecoded != 1 {
+		t.Errorf("Undecoded = %d, want 1 (the startup line)", st.Undecoded)
+	}
+	if a.MidTurn() || !a.IsIdle(time.Hour) {
+		t.Error("an agent between turns reads as mid-turn")
+	}
+
+	if err := a.NudgeWithMode("and a \"quoted\" second\nline", NudgeWaitReady, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	st = waitStream(t, a, "two turns", func(s StreamStatus) bool { return s.Turns == 2 })
+	if st.Usage != (StreamUsage{InputTokens: 20, OutputTokens: 10, CostUSD: 0.5}) {
+		t.Errorf("Usage = %+v", st.Usage)
+	}
+
+	data, err := os.ReadFile(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("the harness read %d lines, want 2: %q", len(lines), data)
+	}
+	for i, want := range []string{"do the thing", "and a \"quoted\" second\nline"} {
+		var msg struct{ Type, Text string }
+		if err := json.Unmarshal([]byte(lines[i]), &msg); err != nil || msg.Type != "user" || msg.Text != want {
+			t.Errorf("input line %d = %q (%v), want a user message %q", i, lines[i], err, want)
+		}
+	}
+
+	info, _ := agentInfoLocked(a)
+	if info.Mode != ModeStream || info.Stream == nil || info.Stream.Turns != 2 {
+		t.Errorf("AgentInfo mode %q, stream %+v", info.Mode, info.Stream)
+	}
+
+	done := waitForEvent(t, path, "agent_turn_completed", "cat-streamer", 5*time.Second)
+	if done == nil {
+		t.Fatal("no agent_turn_completed event")
+	}
+	if d := done["details"].(map[string]any); d["output_tokens"] != float64(5) || d["cost_usd"] != 0.25 {
+		t.Errorf("agent_turn_completed details = %v", d)
+	}
+	for _, typ := range []string{"agent_turn_started", "agent_tool_call"} {
+		if waitForEvent(t, path, typ, "cat-streamer", time.Second) == nil {
+			t.Errorf("no %s event", typ)
+		}
+	}
+	sent := waitForEvent(t, path, "nudge_sent", "pogod", time.Second)
+	if sent == nil || sent["details"].(map[string]any)["delivery"] != "stream" {
+		t.Errorf("nudge_sent = %v, want delivery stream", sent)
+	}
+}
+
+// A client attached to a stream-mode agent is read-only whatever it asks for:
+// the harness reads JSON messages off the PTY, and a keystroke there is a
+// corrupt one. Only the nudge reaches the harness.
+func TestStreamModeAttachCannotWrite(t *testing.T) {
+	reg, err := NewRegistry(shortSocketDir(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reg.StopAll(2 * time.Second)
+
+	in := filepath.Join(t.TempDir(), "input.jsonl")
+	a, err := reg.Spawn(SpawnRequest{
+		Name:         "streamer",
+		Type:         TypePolecat,
+		Command:      fakeStreamHarness(t, in),
+		Provider:     fakeStreamProvider(),
+		Mode:         ModeStream,
+		InitialNudge: "first",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitStream(t, a, "one turn", func(s StreamStatus) bool { return s.Turns == 1 })
+
+	conn := dialFramed(t, a, 0, 0)
+	defer conn.Close()
+	conn.Write(helloFrame(AttachHello{Role: AttachReadWrite, Who: "alice", Takeover: true}))
+	cs := attachedWith(a, func(cs []AttachedClient) bool { return len(cs) == 1 && cs[0].Who == "alice" })
+	if len(cs) != 1 || cs[0].Role != AttachReadOnly || cs[0].Writer {
+		t.Fatalf("attached = %+v, want alice read-only without the lock", cs)
+	}
+	conn.Write(dataFrame([]byte("typed\n")))
+	conn.Write([]byte{FrameTypeTakeover})
+	conn.Write(dataFrame([]byte("typed after takeover\n")))
+
+	if err := a.NudgeWithMode("second", NudgeWaitReady, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	waitStream(t, a, "two turns", func(s StreamStatus) bool { return s.Turns == 2 })
+	data, err := os.ReadFile(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 2 || strings.Contains(string(data), "typed") {
+		t.Errorf("the harness read %q, want only the two nudges", data)
+	}
+}
+
+// A mode nobody knows, and stream mode on a provider without one, fail the
+// spawn instead of starting the TUI.
+func TestStreamModeRefusesWhatItCannotRun(t *testing.T) {
+	reg, err := NewRegistry(shortSocketDir(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reg.StopAll(2 * time.Second)
+
+	for _, req := range []SpawnRequest{
+		{Name: "bad-mode", Mode: "headless", Provider: fakeStreamProvider()},
+		{Name: "no-stream", Mode: ModeStream, Provider: &Provider{ID: "tui-only", Binary: "cat"}},
+	} {
+		req.Type, req.Command = TypePolecat, []string{"cat"}
+		if a, err := reg.Spawn(req); err == nil {
+			t.Errorf("%s: spawned %v", req.Name, a.Command)
+		}
+	}
+}
@@ -9,16 +9,30 @@ import (
 	"nhooyr.io/websocket"
 )
 
-// terminalControl is a JSON control message sent over text WebSocket frames.
+// TerminalSubprotocol is the WebSocket subprotocol /agents/{name}/terminal
+// answers with. A browser that offers its API token as a subprotocol
+// (apiauth.TokenProtocolPrefix) must also offer one the server will choose,
+// or its handshake fails; the token itself is never echoed back. A client
+// that offers none is served as before.
+const TerminalSubprotocol = "pogo-terminal"
+
+// terminalControl is a JSON control message sent over text WebSocket frames:
+// "resize" and "takeover" from the client, "presence" from the server.
 type terminalControl struct {
-	Type string `json:"type"`
-	Cols uint16 `json:"cols,omitempty"`
-	Rows uint16 `json:"rows,omitempty"`
+	Type     string          `json:"type"`
+	Cols     uint16          `json:"cols,omitempty"`
+	Rows     uint16          `json:"rows,omitempty"`
+	Presence *AttachPresence `json:"presence,omitempty"`
 }
 
 // HandleTerminal upgrades an HTTP request to a WebSocket and bridges it
 // to an agent's PTY master fd. Binary frames carry raw PTY data in both
 // directions. Text frames carry JSON control messages (e.g. resize).
+//
+// The client is one of the agent's attached clients (attachclients.go):
+// ?role=ro attaches it read-only and ?who= names it in the presence list,
+// which it is sent as a "presence" control message whenever that list or the
+// writer changes. {"type":"takeover"} takes the writer lock.
 func (r *Registry) HandleTerminal(w http.ResponseWriter, req *http.Request) {
 	name := req.PathValue("name")
 	agent := r.Get(name)
@@ -28,7 +42,7 @@ func (r *Registry) HandleTerminal(w http.ResponseWriter, req *http.Request) {
 	}
 
 	agent.mu.Lock()
-	master := agent.master
+	master := agent.ptyLocked()
 	status := agent.Status
 	agent.mu.Unlock()
 
@@ -41,6 +55,7 @@ func (r *Registry) HandleTerminal(w http.ResponseWriter, req *http.Request) {
 		// Allow any origin — pogod is localhost-only and the React dashboard
 		// may be served from a different port.
 		InsecureSkipVerify: true,
+		Subprotocols:       []string{TerminalSubprotocol},
 	})
 	if err != nil {
 		log.Printf("agent %s: websocket accept: %v", name, err)
@@ -67,7 +82,19 @@ func (r *Registry) HandleTerminal(w http.ResponseWriter, req *http.Request) {
 	agent.attachConns[pw] = struct{}{}
 	agent.attachMu.Unlock()
 
+	who := req.URL.Query().Get("who")
+	if who == "" {
+		who = req.RemoteAddr
+	}
+	client := agent.joinAttach(who, req.URL.Query().Get("role"), "websocket", func(p AttachPresence) {
+		if msg, err := json.Marshal(terminalControl{Type: "presence", Presence: &p}); err == nil {
+			conn.Write(ctx, websocket.MessageText, msg)
+		}
+	})
+	input := gatedInput{a: agent, c: client}
+
 	defer func() {
+		agent.leaveAttach(client)
 		agent.attachMu.Lock()
 		delete(agent.attachConns, pw)
 		agent.attachMu.Unlock()
@@ -102,13 +129,14 @@ func (r *Registry) HandleTerminal(w http.ResponseWriter, req *http.Request) {
 		case websocket.MessageBinary:
 			// Raw terminal input → PTY master
 			agent.mu.Lock()
-			m := agent.master
+			m := agent.ptyLocked()
 			agent.mu.Unlock()
 			if m == nil {
 				conn.Close(websocket.StatusGoingAway, "agent exited")
 				return
 			}
-			if _, err := m.Write(data); err != nil {
+			input.pty = m
+			if _, err := input.Write(data); err != nil {
 				return
 			}
 
@@ -123,7 +151,11 @@ func (r *Registry) HandleTerminal(w http.ResponseWriter, req *http.Request) {
 				// Route through applyResize so the websocket bridge shares the
 				// unix-socket attach path's validation and idempotence: a
 				// 0×0 or no-op resize must not trigger a SIGWINCH-driven redraw.
-				agent.applyResize(ctrl.Cols, ctrl.Rows)
+				if agent.attachMayResize(client) {
+					agent.applyResize(ctrl.Cols, ctrl.Rows)
+				}
+			case "takeover":
+				agent.takeoverAttach(client)
 			}
 		}
 	}
@@ -6,10 +6,11 @@ import (
 	"testing"
 
 	"github.com/drellem2/pogo/internal/events"
+	"github.com/drellem2/pogo/internal/platform/sandbox"
 	"github.com/drellem2/pogo/internal/testsandbox"
 )
 
-// sandbox is the package's private, CHECKED envelope, established by TestMain
+// testSandbox is the package's private, CHECKED envelope, established by TestMain
 // before a single test runs. See internal/testsandbox: HOME, XDG_CONFIG_HOME,
 // POGO_HOME and MG_ROOT are pinned under a throwaway root, read back out of the
 // process, and refused if any of them resolves onto the developer's live tree.
@@ -34,7 +35,7 @@ import (
 // claimrelease.go reads $MG_ROOT directly and falls back to the home directory,
 // so a claim/release test under a pinned HOME alone could still reach the live
 // ~/.macguffin store.
-var sandbox *testsandbox.Sandbox
+var testSandbox *testsandbox.Sandbox
 
 // sandboxEventLog is the package-wide throwaway event log TestMain installs.
 // Per-test redirects (useTempEventLog) must restore THIS path on cleanup, not
@@ -43,8 +44,19 @@ var sandbox *testsandbox.Sandbox
 var sandboxEventLog string
 
 func TestMain(m *testing.M) {
+	// holder_test.go starts PTY holders from this test binary, as pogod starts
+	// them from its own.
+	if len(os.Args) > 1 && os.Args[1] == HolderArg {
+		os.Exit(RunHolder(os.Stdin, os.Stdout))
+	}
+	// sandboxprofile_test.go confines agents through the sandbox wrapper, which
+	// is this test binary re-executed, as it is pogod in production.
+	if len(os.Args) > 1 && os.Args[1] == sandbox.Arg {
+		os.Exit(sandbox.Run(os.Args[2:]))
+	}
+
 	sb, down := testsandbox.Main("agent")
-	sandbox = sb
+	testSandbox = sb
 
 	// The event log is redirected separately because it is not addressed by an
 	// environment variable: events.Emit resolves its path ONCE, before any test
@@ -81,13 +93,13 @@ func TestMain(m *testing.M) {
 // rest of the package green while the suite goes back to reading the machine's
 // real park state.
 func TestPackageIsolationIsEstablished(t *testing.T) {
-	testsandbox.Verify(t, sandbox)
+	testsandbox.Verify(t, testSandbox)
 
 	// And the package-specific half: the path the park lookups actually run
 	// through has to land inside the sandbox, not merely near it.
-	if got := ParkFilePath("pm-dealdesk"); !sandbox.Contains(got) {
+	if got := ParkFilePath("pm-dealdesk"); !testSandbox.Contains(got) {
 		t.Errorf("ParkFilePath(pm-dealdesk) = %s, want a path under the sandbox root %s; "+
-			"the package is resolving park state through the real home", got, sandbox.Root)
+			"the package is resolving park state through the real home", got, testSandbox.Root)
 	}
 
 	// And the half no environment variable can pin: the drift sink is an
@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/drellem2/pogo/internal/tracing"
+)
+
+// The agent package's spans in a work item's trace (user-023): the dispatch
+// that makes a polecat, then the polecat's own start verification and run.
+// All of them need a work item id; an agent without one is not traced.
+
+// dispatchTrace records one POST /agents/spawn-polecat as a "dispatch" span
+// with a child span per phase (user-023), so a slow dispatch says whether it
+// was the gates, the template, the worktree, the claim or the process start.
+//
+// The handler has some thirty ways out, each a failPolecatSpawn; rather than
+// thread the span through every one, trace wraps the ResponseWriter and the
+// deferred finish reads how the request ended from what was written to it —
+// the same status and message agent_spawn_failed records.
+type dispatchTrace struct {
+	item  string
+	root  *tracing.Active
+	phase *tracing.Active
+	rec   *statusRecorder
+}
+
+// startDispatchTrace begins the dispatch span for spawnReq, already in its
+// first phase, and returns w wrapped to record the response. Without a work
+// item id it traces nothing and returns w unchanged.
+func startDispatchTrace(w http.ResponseWriter, spawnReq SpawnPolecatAPIRequest) (*dispatchTrace, http.ResponseWriter) {
+	root := tracing.Start(spawnReq.Id, "dispatch", tracing.SpanID{})
+	if root == nil {
+		return &dispatchTrace{}, w
+	}
+	root.SetAttr("agent", spawnReq.Name)
+	root.SetAttr("repo", spawnReq.Repo)
+	dt := &dispatchTrace{item: spawnReq.Id, root: root, rec: &statusRecorder{ResponseWriter: w}}
+	dt.enter("dispatch.gates")
+	return dt, dt.rec
+}
+
+// parent is the dispatch span's id, handed to the polecat so its own spans
+// (run, start verification, reap) hang under the dispatch that made it.
+func (dt *dispatchTrace) parent() tracing.SpanID { return dt.root.ID() }
+
+// enter ends the current phase and starts the next.
+func (dt *dispatchTrace) enter(name string) {
+	if dt.root == nil {
+		return
+	}
+	dt.phase.End(nil)
+	dt.phase = tracing.Start(dt.item, name, dt.root.ID())
+}
+
+// finish ends the open phase and the dispatch span, in error when the handler
+// answered anything but 201.
+func (dt *dispatchTrace) finish() {
+	if dt.root == nil {
+		return
+	}
+	var err error
+	status := dt.rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if status >= 400 {
+		err = fmt.Errorf("%d: %s", status, dt.rec.message)
+	}
+	dt.phase.End(err)
+	dt.root.SetAttr("status_code", status)
+	dt.root.End(err)
+}
+
+// statusRecorder remembers the status a handler wrote and, for an error, the
+// first line of its message.
+type statusRecorder struct {
+	http.ResponseWriter
+	status  int
+	message string
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	if s.status >= 400 && s.message == "" {
+		s.message, _, _ = strings.Cut(strings.TrimSpace(string(b)), "\n")
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// startSpan begins one of a's spans under the dispatch that made it. Nil —
+// and so inert — for an agent without a work item.
+func (a *Agent) startSpan(name string) *tracing.Active {
+	span := tracing.Start(a.WorkItemID, name, a.traceParent)
+	span.SetAttr("agent", a.Name)
+	return span
+}
+
+// TraceParent is the dispatch span a was spawned under, for spans pogod
+// records about a from outside this package — its reap.
+func (a *Agent) TraceParent() tracing.SpanID { return a.traceParent }
+
+// traceRun records the process's life, spawn to exit, once it has exited. A
+// respawned polecat records one per process, each with its restart count. It
+// ends in error when the process crashed: an exit nobody asked for, non-zero.
+func (a *Agent) traceRun(stopRequested bool, stopCause string, exitCode int) {
+	if a.WorkItemID == "" {
+		return
+	}
+	attrs := map[string]any{
+		"agent":         a.Name,
+		"pid":           a.PID,
+		"exit_code":     exitCode,
+		"restart_count": a.RestartCount,
+	}
+	if stopCause != "" {
+		attrs["stop_cause"] = stopCause
+	}
+	span := tracing.Span{
+		WorkItemID: a.WorkItemID,
+		ParentID:   a.traceParent,
+		Name:       "polecat.run",
+		Start:      a.StartTime,
+		End:        a.ExitTime,
+		Attributes: attrs,
+	}
+	if !stopRequested && exitCode != 0 {
+		span.Status = tracing.StatusError
+		span.Message = exitReason(false, exitCode)
+	}
+	tracing.Record(span)
+}
@@ -0,0 +1,123 @@
+package agent
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drellem2/pogo/internal/tracing"
+)
+
+// spanSink is a tracing.Exporter that keeps every span it is given.
+type spanSink struct {
+	mu    sync.Mutex
+	spans []tracing.Span
+}
+
+func (s *spanSink) Name() string { return "test" }
+func (s *spanSink) Export(batch []tracing.Span) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spans = append(s.spans, batch...)
+	return nil
+}
+
+func (s *spanSink) flushed(t *testing.T) []tracing.Span {
+	t.Helper()
+	if !tracing.Flush(5 * time.Second) {
+		t.Fatal("tracing.Flush timed out")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]tracing.Span(nil), s.spans...)
+}
+
+// TestRefusedDispatchIsTracedInError: the trace learns how a dispatch ended
+// from the response, so a refusal from any of the handler's failPolecatSpawn
+// calls ends the phase it happened in, and the dispatch, in error.
+func TestRefusedDispatchIsTracedInError(t *testing.T) {
+	useTempEventLog(t)
+	sink := &spanSink{}
+	t.Cleanup(tracing.Configure(sink))
+
+	req := SpawnPolecatAPIRequest{Name: "c1", Id: "mg-1", Repo: "/src/pogo"}
+	trace, w := startDispatchTrace(httptest.NewRecorder(), req)
+	trace.enter("dispatch.prepare")
+	failPolecatSpawn(w, req, http.StatusConflict, "item is parked\nsecond line")
+	trace.finish()
+
+	spans := sink.flushed(t)
+	if len(spans) != 3 {
+		t.Fatalf("recorded %d spans, want gates, prepare, dispatch: %+v", len(spans), spans)
+	}
+	gates, prepare, dispatch := spans[0], spans[1], spans[2]
+	if gates.Name != "dispatch.gates" || gates.Status != tracing.StatusOK {
+		t.Errorf("gates = %+v", gates)
+	}
+	if prepare.Name != "dispatch.prepare" || prepare.Status != tracing.StatusError || prepare.Message != "409: item is parked" {
+		t.Errorf("prepare = %+v", prepare)
+	}
+	if dispatch.Name != "dispatch" || dispatch.Status != tracing.StatusError || dispatch.Attributes["status_code"] != 409 {
+		t.Errorf("dispatch = %+v", dispatch)
+	}
+	if gates.ParentID != dispatch.SpanID || prepare.ParentID != dispatch.SpanID || !dispatch.ParentID.IsZero() {
+		t.Error("phases are not under the dispatch, or the dispatch is not a root")
+	}
+	if trace.parent() != dispatch.SpanID {
+		t.Error("the polecat would not be parented to its dispatch")
+	}
+	if dispatch.TraceID != tracing.TraceIDFor("mg-1") {
+		t.Error("dispatch is not in the work item's trace")
+	}
+}
+
+func TestDispatchWithoutAWorkItemIsNotTraced(t *testing.T) {
+	sink := &spanSink{}
+	t.Cleanup(tracing.Configure(sink))
+
+	rec := httptest.NewRecorder()
+	trace, w := startDispatchTrace(rec, SpawnPolecatAPIRequest{Name: "c1"})
+	if w != http.ResponseWriter(rec) {
+		t.Error("an untraced dispatch wrapped the writer")
+	}
+	trace.enter("dispatch.spawn")
+	w.WriteHeader(http.StatusCreated)
+	trace.finish()
+	if !trace.parent().IsZero() {
+		t.Error("an untraced dispatch handed the polecat a parent")
+	}
+	if spans := sink.flushed(t); len(spans) != 0 {
+		t.Errorf("recorded %+v", spans)
+	}
+}
+
+// TestPolecatRunSpan: the run hangs under the dispatch that made it, and only
+// a crash — an exit nobody asked for, non-zero — is an error.
+func TestPolecatRunSpan(t *testing.T) {
+	sink := &spanSink{}
+	t.Cleanup(tracing.Configure(sink))
+
+	parent := tracing.NewSpanID()
+	start := time.Now().Add(-time.Hour)
+	a := &Agent{Name: "c1", WorkItemID: "mg-1", StartTime: start, ExitTime: start.Add(time.Hour), traceParent: parent}
+	a.traceRun(false, "", 2)
+	a.traceRun(true, "operator", 143)
+	(&Agent{Name: "crew"}).traceRun(false, "", 1)
+
+	spans := sink.flushed(t)
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want 2 (the crew agent has no work item)", len(spans))
+	}
+	crashed, stopped := spans[0], spans[1]
+	if crashed.Name != "polecat.run" || crashed.ParentID != parent || crashed.Duration() != time.Hour {
+		t.Errorf("run = %+v", crashed)
+	}
+	if crashed.Status != tracing.StatusError || crashed.Message != "exited with code 2" {
+		t.Errorf("crash status = %s %q", crashed.Status, crashed.Message)
+	}
+	if stopped.Status != tracing.StatusOK || stopped.Attributes["stop_cause"] != "operator" {
+		t.Errorf("requested stop = %+v", stopped)
+	}
+}
@@ -63,12 +63,12 @@ func mgStore(t *testing.T) string {
 	if _, err := exec.LookPath("jq"); err != nil {
 		t.Skip("jq is not on PATH")
 	}
-	root := filepath.Join(sandbox.Root, "triagepacket", t.Name(), "macguffin")
+	root := filepath.Join(testSandbox.Root, "triagepacket", t.Name(), "macguffin")
 	if err := os.MkdirAll(root, 0o755); err != nil {
 		t.Fatalf("mkdir store root: %v", err)
 	}
-	if !sandbox.Contains(root) {
-		t.Fatalf("store root %s is outside the sandbox %s", root, sandbox.Root)
+	if !testSandbox.Contains(root) {
+		t.Fatalf("store root %s is outside the sandbox %s", root, testSandbox.Root)
 	}
 	t.Setenv("MG_ROOT", root)
 	if out, err := exec.Command("mg", "init").CombinedOutput(); err != nil {
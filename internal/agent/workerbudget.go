@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"runtime"
 	"strconv"
+	"strings"
 
 	"github.com/drellem2/pogo/internal/config"
 	"github.com/drellem2/pogo/internal/hostload"
@@ -40,6 +41,12 @@ import (
 // changes is that a self-parallelising toolchain now has a number to be told,
 // where previously there was nothing to tell it.
 //
+// Unless the host can do better. With [dispatch] cgroup_root naming a
+// delegated cgroup v2 subtree, pogod starts each polecat in a cgroup whose
+// cpu.max, memory.max and pids.max are this budget (cgroups.go, user-013), and
+// the number stops being a request. Enforced says which of the two a worker
+// got.
+//
 // Deliberately toolchain-agnostic. `LAKE_JOBS` would fix the measured incident
 // and nothing else; the general shape is "a worker whose toolchain
 // self-parallelises", which already includes `go test ./...` (it parallelises
@@ -65,6 +72,10 @@ type WorkerBudget struct {
 	// Basis names how Cores was derived, so a worker or a coordinator reading a
 	// surprising number can argue with it rather than guess.
 	Basis string `json:"basis"`
+	// Enforced reports that the worker will be held to it: pogod starts
+	// polecats in cgroups limited to their budget ([dispatch] cgroup_root,
+	// cgroups.go). False is the advisory budget described above.
+	Enforced bool `json:"enforced,omitempty"`
 }
 
 // Environment variables carrying the budget into a worker's process. Named as
@@ -195,5 +206,32 @@ func polecatSpawnEnv(budget WorkerBudget, reqEnv []string) []string {
 // reason is the same: an advisory number that could drift from the injected one
 // lets a coordinator plan against a fleet pogod is configuring differently.
 func (r *Registry) WorkerBudget() WorkerBudget {
-	return WorkerBudgetFor(runtime.NumCPU(), r.dispatchCapPolicy())
+	b := WorkerBudgetFor(runtime.NumCPU(), r.dispatchCapPolicy())
+	b.Enforced = b.Known() && r.Cgroups() != nil
+	return b
+}
+
+// withOverride is b as the worker will actually be told it: env is the
+// worker's assembled environment (polecatSpawnEnv), in which a dispatcher's
+// `--env POGO_WORKER_CORES=N` comes after the budget and wins. The cgroup is
+// sized from this, so a worker is held to the number it was given rather
+// than to one it was told to ignore.
+func (b WorkerBudget) withOverride(env []string) WorkerBudget {
+	for i := len(env) - 1; i >= 0; i-- {
+		v, ok := strings.CutPrefix(env[i], WorkerCoresEnv+"=")
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || n < 1 || n == b.Cores {
+			return b
+		}
+		if b.HostCores > 0 && n > b.HostCores {
+			n = b.HostCores
+		}
+		b.Cores = n
+		b.Basis = fmt.Sprintf("dispatcher override: %s=%s", WorkerCoresEnv, v)
+		return b
+	}
+	return b
 }
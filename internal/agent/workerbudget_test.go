@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/drellem2/pogo/internal/config"
+	"github.com/drellem2/pogo/internal/platform/cgroup"
 )
 
 // TestWorkerBudgetDividesTheBoxByTheEnforcedCap is the shape of the fix: on the
@@ -241,3 +242,48 @@ func TestHostLoadEndpointServesTheInjectedBudget(t *testing.T) {
 		t.Errorf("the budget went unknown because the SAMPLE failed: %+v", resp.WorkerBudget)
 	}
 }
+
+// TestCgroupLimitsAreTheBudget: the cgroup a worker is started in is its
+// budget and nothing else — its cores as CPU bandwidth and the same share of
+// memory — so `pogo host load` and the kernel agree on what it may take.
+func TestCgroupLimitsAreTheBudget(t *testing.T) {
+	b := WorkerBudget{Cores: 3, HostCores: 12}
+	got := b.CgroupLimits(24 << 30)
+	want := cgroup.Limits{Cores: 3, MemoryBytes: 6 << 30, Pids: 3 * cgroupPidsPerCore}
+	if got != want {
+		t.Errorf("CgroupLimits = %+v, want %+v", got, want)
+	}
+	if got := b.CgroupLimits(0); got.MemoryBytes != 0 || got.Cores != 3 {
+		t.Errorf("with host memory unknown = %+v, want cores limited and memory not", got)
+	}
+	if got := (WorkerBudget{Basis: "unknown"}).CgroupLimits(24 << 30); got != (cgroup.Limits{}) {
+		t.Errorf("an underived budget limited %+v; it must limit nothing", got)
+	}
+}
+
+// TestTheCgroupFollowsTheDispatcherOverride: `--env POGO_WORKER_CORES=N` wins
+// over the division in the worker's environment (polecatSpawnEnv), so it must
+// win in the cgroup too — a worker held to a number it was told to ignore is
+// a worker configured two ways at once.
+func TestTheCgroupFollowsTheDispatcherOverride(t *testing.T) {
+	b := WorkerBudget{Cores: 3, HostCores: 12, Basis: "division"}
+	for _, tc := range []struct {
+		name string
+		env  []string
+		want int
+	}{
+		{"no override", nil, 3},
+		{"override", []string{WorkerCoresEnv + "=8"}, 8},
+		{"last one wins", []string{WorkerCoresEnv + "=8", WorkerCoresEnv + "=5"}, 5},
+		{"clamped to the host", []string{WorkerCoresEnv + "=64"}, 12},
+		{"garbage is ignored", []string{WorkerCoresEnv + "=lots"}, 3},
+		{"zero is ignored", []string{WorkerCoresEnv + "=0"}, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			env := polecatSpawnEnv(b, tc.env)
+			if got := b.withOverride(env); got.Cores != tc.want {
+				t.Errorf("withOverride(%v).Cores = %d, want %d", env, got.Cores, tc.want)
+			}
+		})
+	}
+}
@@ -29,6 +29,9 @@ const (
 	SubmitReceiptEnv = "POGO_SUBMIT_RECEIPT"
 	// RoleEnv is the frozen cross-tool role identifier (mg-6a24 §1.1).
 	RoleEnv = "POGO_ROLE"
+	// APITokenEnv is the agent's bearer token for pogod's API, scoped to its
+	// type's role (user-021). internal/client sends it on every request.
+	APITokenEnv = "POGO_API_TOKEN"
 )
 
 // InjectedEnv is one environment variable pogod sets on an agent process: what
@@ -129,6 +132,13 @@ var agentIdentitySpecs = []envSpec{
 		When:        "the provider has a submit hook that could be installed",
 		Note:        "absent means this agent cannot prove delivery; nudges to it fall back to wait-idle",
 	},
+	{
+		Name:        APITokenEnv,
+		Placeholder: "<a token for the agent's role>",
+		Why:         "the agent's pogod API token: `pogo` sends it, and pogod holds the agent to its role's policy",
+		When:        "pogod issues API tokens",
+		Note:        "a limit on what the agent's own pogo commands may do, not a sandbox: the agent runs as pogod's user",
+	},
 }
 
 // workerBudgetSpecs is the catalogue for the two budget variables. Kept beside
@@ -173,13 +183,14 @@ func (s envSpec) entry(value string) InjectedEnv {
 // injection order. Entries whose value is empty are reported unset and are
 // skipped by envAssignments — an unset variable and one assigned the empty
 // string are different facts to the process receiving them.
-func agentIdentityEnv(name string, typ AgentType, procName, promptFile, receiptFile string) []InjectedEnv {
+func agentIdentityEnv(name string, typ AgentType, procName, promptFile, receiptFile, apiToken string) []InjectedEnv {
 	values := map[string]string{
 		AgentNameEnv:     name,
 		AgentTypeEnv:     string(typ),
 		ProcessNameEnv:   procName,
 		AgentPromptEnv:   promptFile,
 		SubmitReceiptEnv: receiptFile,
+		APITokenEnv:      apiToken,
 	}
 	out := make([]InjectedEnv, 0, len(agentIdentitySpecs))
 	for _, s := range agentIdentitySpecs {
@@ -214,7 +225,7 @@ func envAssignments(vars []InjectedEnv) []string {
 // inherits pogod's entire environment (os.Environ()), and a dispatcher may add
 // anything it likes with --env. Those are open sets and no list can close them.
 func PolecatEnv(budget WorkerBudget) []InjectedEnv {
-	out := agentIdentityEnv("", TypePolecat, "", "", "")
+	out := agentIdentityEnv("", TypePolecat, "", "", "", "")
 	// The catalogue view knows the type even though it knows no name.
 	for i := range out {
 		if out[i].Name == AgentTypeEnv {
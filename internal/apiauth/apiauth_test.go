@@ -0,0 +1,219 @@
+package apiauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testIssuer(t *testing.T) *Issuer {
+	t.Helper()
+	iss, err := LoadIssuer(filepath.Join(t.TempDir(), "api.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return iss
+}
+
+func TestTokensCarryTheirIdentityAndCannotBeForged(t *testing.T) {
+	iss := testIssuer(t)
+	token, err := iss.Issue(RolePolecat, "mg-1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := iss.Verify(token)
+	if err != nil || id != (Identity{Role: RolePolecat, Subject: "mg-1234"}) {
+		t.Fatalf("Verify = %+v, %v", id, err)
+	}
+
+	parts := strings.Split(token, ".")
+	crewPayload := strings.Split(mustIssue(t, iss, RoleCrew, "mg-1234"), ".")[1]
+	for name, forged := range map[string]string{
+		"another key":          mustIssue(t, testIssuer(t), RolePolecat, "mg-1234"),
+		"a promoted payload":   parts[0] + "." + crewPayload + "." + parts[2],
+		"a truncated token":    parts[0] + "." + parts[1],
+		"something else again": "Bearer nonsense",
+	} {
+		if _, err := iss.Verify(forged); err != ErrBadToken {
+			t.Errorf("%s: Verify err = %v, want ErrBadToken", name, err)
+		}
+	}
+	if _, err := iss.Issue("admin", "x"); err == nil {
+		t.Error("issued a token for an unknown role")
+	}
+}
+
+func mustIssue(t *testing.T, iss *Issuer, role Role, subject string) string {
+	t.Helper()
+	token, err := iss.Issue(role, subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// The key survives a restart, so the tokens agents already hold do too.
+func TestLoadIssuerKeepsItsKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.key")
+	first, err := LoadIssuer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil || fi.Mode().Perm() != 0600 {
+		t.Fatalf("key file %v, %v; want mode 0600", fi, err)
+	}
+	second, err := LoadIssuer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.Verify(mustIssue(t, first, RoleCrew, "mayor")); err != nil {
+		t.Errorf("a token from before the restart: %v", err)
+	}
+
+	tokenPath := filepath.Join(t.TempDir(), "api-token")
+	if err := first.WriteHumanToken(tokenPath); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(tokenPath)
+	if id, err := first.Verify(strings.TrimSpace(string(data))); err != nil || id.Role != RoleHuman {
+		t.Errorf("human token verifies as %+v, %v", id, err)
+	}
+}
+
+func TestDefaultPolicyLetsAPolecatSubmitButNotParkTheCoordinator(t *testing.T) {
+	p := DefaultPolicy()
+	cat := Identity{Role: RolePolecat, Subject: "mg-1234"}
+	crew := Identity{Role: RoleCrew, Subject: "mayor"}
+	for _, tc := range []struct {
+		id           Identity
+		method, path string
+		want         bool
+	}{
+		{cat, "POST", "/refinery/submit", true},
+		{cat, "GET", "/refinery/queue", true},
+		{cat, "HEAD", "/agents", true},
+		{cat, "POST", "/scheduler/schedules", true},
+		{cat, "POST", "/scheduler/schedules/mail-check-mg-1234/ack", true},
+		{cat, "POST", "/agents/mg-1234/nudge", true},
+		{cat, "POST", "/agents/mayor/park", false},
+		{cat, "DELETE", "/agents/mayor", false},
+		{cat, "POST", "/agents/mg-12345/nudge", false},
+		{cat, "POST", "/refinery/cancel", false},
+		{cat, "POST", "/server/mode", false},
+		{cat, "GET", "/agents/mayor/terminal", false},
+		{cat, "POST", "/refinery/submit/../../agents/mayor/park", false},
+		{crew, "POST", "/agents/mayor/park", true},
+		{crew, "POST", "/server/stop-orchestration", true},
+	} {
+		if got, _ := p.Allows(tc.id, tc.method, tc.path); got != tc.want {
+			t.Errorf("%s %s %s: allowed %v, want %v", tc.id, tc.method, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestParsePolicyReplacesOnlyTheRolesItNames(t *testing.T) {
+	p, err := ParsePolicy(map[string][]string{"crew": {"/", "!POST /server"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	crew := Identity{Role: RoleCrew, Subject: "mayor"}
+	if ok, rule := p.Allows(crew, "POST", "/server/mode"); ok || rule == nil || rule.String() != "!POST /server" {
+		t.Errorf("crew POST /server/mode: allowed %v by %v", ok, rule)
+	}
+	if ok, _ := p.Allows(Identity{Role: RolePolecat, Subject: "x"}, "POST", "/refinery/submit"); !ok {
+		t.Error("overriding crew changed the polecat policy")
+	}
+	for _, bad := range []map[string][]string{
+		{"admin": {"/"}},
+		{"crew": {"agents"}},
+		{"crew": {"GET POST /agents"}},
+	} {
+		if _, err := ParsePolicy(bad); err == nil {
+			t.Errorf("ParsePolicy(%v) accepted it", bad)
+		}
+	}
+}
+
+func TestGuard(t *testing.T) {
+	iss := testIssuer(t)
+	var denied []string
+	g := &Guard{
+		Issuer: iss,
+		Policy: DefaultPolicy(),
+		OnDeny: func(r *http.Request, id Identity, status int, reason string) {
+			denied = append(denied, id.String())
+		},
+	}
+	var seen Identity
+	h := g.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = FromContext(r.Context())
+	}))
+	do := func(method, path, token string, local bool) int {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if local {
+			req = req.WithContext(context.WithValue(req.Context(), localKey, true))
+		}
+		seen = Identity{}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	cat := mustIssue(t, iss, RolePolecat, "mg-1234")
+
+	if code := do("POST", "/refinery/submit", cat, false); code != 200 || seen.Subject != "mg-1234" {
+		t.Errorf("polecat submit: %d as %+v", code, seen)
+	}
+	if code := do("POST", "/agents/mayor/park", cat, true); code != http.StatusForbidden {
+		t.Errorf("polecat park on the socket: %d, want 403 — a token is judged wherever it arrives", code)
+	}
+	if code := do("POST", "/agents/mayor/park", "pogo1.x.y", false); code != http.StatusUnauthorized {
+		t.Errorf("bad token: %d, want 401", code)
+	}
+	if code := do("POST", "/agents/mayor/park", "", true); code != http.StatusUnauthorized {
+		t.Errorf("socket without a token: %d, want 401 — an agent that drops its token must not be the human", code)
+	}
+	human := mustIssue(t, iss, RoleHuman, string(RoleHuman))
+	if code := do("POST", "/agents/mayor/park", human, true); code != 200 || seen.Role != RoleHuman {
+		t.Errorf("socket with the human token: %d as %+v, want the human", code, seen)
+	}
+	if code := do("POST", "/agents/mayor/park", "", false); code != http.StatusForbidden {
+		t.Errorf("tokenless TCP park with tokens optional: %d, want 403 — an agent that drops its token must not gain by it", code)
+	}
+	if code := do("GET", "/projects", "", false); code != 200 || seen.Role != RoleAnonymous {
+		t.Errorf("tokenless TCP read with tokens optional: %d as %+v, want anonymous", code, seen)
+	}
+	if code := do("POST", "/plugin", "", false); code != 200 {
+		t.Errorf("tokenless TCP search with tokens optional: %d", code)
+	}
+	g.RequireToken = true
+	if code := do("POST", "/agents/mayor/park", "", false); code != http.StatusUnauthorized {
+		t.Errorf("tokenless TCP with tokens required: %d, want 401", code)
+	}
+	g.Public = []string{"/ui/"}
+	if code := do("GET", "/ui/app.js", "", false); code != 200 {
+		t.Errorf("public path with tokens required: %d, want 200", code)
+	}
+	if code := do("GET", "/ui/../agents/mayor/park", "", false); code != http.StatusUnauthorized {
+		t.Errorf("unclean path under a public prefix: %d, want 401", code)
+	}
+
+	// A browser's WebSocket carries its token as a subprotocol.
+	req := httptest.NewRequest("GET", "/agents/mayor/terminal", nil)
+	req.Header.Set("Sec-WebSocket-Protocol", "pogo-terminal, "+TokenProtocolPrefix+cat)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("polecat terminal via subprotocol token: %d, want 403", rec.Code)
+	}
+	if len(denied) != 7 || denied[0] != "polecat:mg-1234" || denied[3] != "anonymous" || denied[6] != "polecat:mg-1234" {
+		t.Errorf("OnDeny saw %v", denied)
+	}
+}
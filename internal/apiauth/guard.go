@@ -0,0 +1,152 @@
+package apiauth
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Guard is the middleware in front of pogod's mux.
+//
+// A request is judged by where its identity came from:
+//
+//   - a bearer token is verified and its role checked against the Policy,
+//     whichever listener it arrived on — a bad token is 401, a route its role
+//     may not call is 403;
+//   - no token on the unix socket is refused. The socket once stood for the
+//     human by itself, but every agent runs as the human's user and can dial
+//     it, and no sandbox profile stops a connect() to a socket: an agent that
+//     dropped its own token would have been the human. The CLI sends the
+//     human's token from $POGO_HOME/api-token, which sandboxed agents cannot
+//     read;
+//   - no token over TCP is refused when RequireToken is set, and otherwise
+//     judged as RoleAnonymous. Editor plugins and scripts that have never
+//     heard of tokens can still read and search, but a polecat that drops its
+//     token gets less than its own role, not more.
+type Guard struct {
+	Issuer *Issuer
+	Policy Policy
+	// RequireToken refuses a tokenless request over TCP.
+	RequireToken bool
+	// Public lists path prefixes served to anyone, token or not, unjudged:
+	// the dashboard's page and scripts (user-024), which carry no fleet data
+	// and have to load before the page can ask for a token to send.
+	Public []string
+	// OnDeny, when set, is told about every refused request.
+	OnDeny func(r *http.Request, id Identity, status int, reason string)
+}
+
+type ctxKey int
+
+const (
+	localKey ctxKey = iota
+	identityKey
+)
+
+// ConnContext marks a connection accepted on a unix socket. It is meant for
+// http.Server.ConnContext, which is how the guard learns which listener a
+// request arrived on.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	if _, ok := c.LocalAddr().(*net.UnixAddr); ok {
+		return context.WithValue(ctx, localKey, true)
+	}
+	return ctx
+}
+
+func isLocal(ctx context.Context) bool {
+	local, _ := ctx.Value(localKey).(bool)
+	return local
+}
+
+// FromContext returns the identity the Guard attached to a request. A
+// tokenless TCP request carries RoleAnonymous; ok is false only for a public
+// path, which the guard does not judge.
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey).(Identity)
+	return id, ok
+}
+
+// TokenProtocolPrefix marks a token offered as a WebSocket subprotocol,
+// "pogo-token.<token>". A browser cannot set headers on a WebSocket, and the
+// subprotocol list is the one header it lets a page choose, so this is how the
+// dashboard's terminal presents a token. Tokens are base64url and dots, which
+// a subprotocol name may hold.
+const TokenProtocolPrefix = "pogo-token."
+
+// bearer returns the request's bearer token, or "": the Authorization
+// header's, or else one offered as a TokenProtocolPrefix subprotocol.
+func bearer(r *http.Request) string {
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	for _, h := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(h, ",") {
+			if token, ok := strings.CutPrefix(strings.TrimSpace(p), TokenProtocolPrefix); ok {
+				return token
+			}
+		}
+	}
+	return ""
+}
+
+// public reports whether p is under one of g.Public. Only a clean path is:
+// "/ui/../agents/x/park" is not the dashboard's.
+func (g *Guard) public(p string) bool {
+	if c := path.Clean(p); c != p && c+"/" != p {
+		return false
+	}
+	for _, prefix := range g.Public {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Wrap returns next behind the guard.
+func (g *Guard) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if g.public(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var id Identity
+		switch token := bearer(r); {
+		case token != "":
+			var err error
+			if id, err = g.Issuer.Verify(token); err != nil {
+				g.deny(w, r, Identity{}, http.StatusUnauthorized, err.Error())
+				return
+			}
+		case isLocal(r.Context()):
+			g.deny(w, r, Identity{}, http.StatusUnauthorized,
+				"pogod's socket requires an API token; the CLI sends the one in $POGO_HOME/api-token")
+			return
+		case g.RequireToken:
+			g.deny(w, r, Identity{}, http.StatusUnauthorized,
+				"pogod requires an API token over TCP; send $POGO_API_TOKEN or the one in $POGO_HOME/api-token")
+			return
+		default:
+			id = Identity{Role: RoleAnonymous}
+		}
+		if ok, _ := g.Policy.Allows(id, r.Method, r.URL.Path); !ok {
+			reason := fmt.Sprintf("%s may not %s %s", id, r.Method, r.URL.Path)
+			if id.Role == RoleAnonymous {
+				reason += "; send $POGO_API_TOKEN or the one in $POGO_HOME/api-token"
+			}
+			g.deny(w, r, id, http.StatusForbidden, reason)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey, id)))
+	})
+}
+
+func (g *Guard) deny(w http.ResponseWriter, r *http.Request, id Identity, status int, reason string) {
+	if g.OnDeny != nil {
+		g.OnDeny(r, id, status, reason)
+	}
+	http.Error(w, reason, status)
+}
@@ -0,0 +1,185 @@
+package apiauth
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Rule is one line of a role's policy:
+//
+//	[!][METHOD] /path/prefix
+//
+// The path matches a request path it is a prefix of, segment by segment, so
+// "/agents/x" covers "/agents/x/nudge" and not "/agents/xy". A "*" segment
+// matches any one segment, and "{self}" matches only the caller's own subject
+// — the agent's name. The match knows nothing of routes: "/agents/{self}" is
+// safe only because internal/agent refuses a name that is a static route under
+// /agents, such as "drain". With no method the rule covers every method; GET also
+// covers HEAD. A leading "!" makes it a denial, and a denial that matches wins
+// over any number of allows.
+type Rule struct {
+	Deny   bool
+	Method string
+	Path   []string
+	raw    string
+}
+
+// ParseRule parses one policy line.
+func ParseRule(s string) (Rule, error) {
+	r := Rule{raw: strings.TrimSpace(s)}
+	rest := r.raw
+	if strings.HasPrefix(rest, "!") {
+		r.Deny = true
+		rest = strings.TrimSpace(rest[1:])
+	}
+	fields := strings.Fields(rest)
+	switch len(fields) {
+	case 1:
+	case 2:
+		r.Method = strings.ToUpper(fields[0])
+		fields = fields[1:]
+	default:
+		return Rule{}, fmt.Errorf("policy rule %q: want [!][METHOD] /path", s)
+	}
+	if !strings.HasPrefix(fields[0], "/") {
+		return Rule{}, fmt.Errorf("policy rule %q: path must start with /", s)
+	}
+	r.Path = segments(fields[0])
+	return r, nil
+}
+
+func (r Rule) String() string { return r.raw }
+
+func segments(p string) []string {
+	p = strings.Trim(path.Clean("/"+p), "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+func (r Rule) matches(method string, segs []string, subject string) bool {
+	if r.Method != "" && r.Method != method && !(r.Method == "GET" && method == "HEAD") {
+		return false
+	}
+	if len(r.Path) > len(segs) {
+		return false
+	}
+	for i, p := range r.Path {
+		switch p {
+		case "*":
+		case "{self}":
+			if subject == "" || segs[i] != subject {
+				return false
+			}
+		default:
+			if segs[i] != p {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Policy maps each role to its rules. A role with no rules may call nothing.
+type Policy map[Role][]Rule
+
+// DefaultRules is the shipped policy, as config would spell it.
+//
+// The human and crew may call everything: crew agents are the coordinator and
+// the PMs, whose job is to start, stop, park and nudge the fleet. A polecat may
+// read anything but an agent's terminal, and write only what its prompt tells
+// it to: register and ack its mail-check schedule, search and visit code, and
+// submit its branch to the refinery. Anything under its own /agents/<name>
+// is its own business. It may not park, stop, nudge or spawn another agent,
+// cancel or prune MRs, or change the server's mode. A path cannot say whose
+// schedule a POST names, so the scheduler itself refuses a polecat one naming
+// another agent: its message would be a nudge.
+//
+// A tokenless caller gets less than a polecat: what an editor plugin needs to
+// read, visit files and search, and nothing that acts on an agent, the
+// refinery or the server. An agent that drops its token lands here, so this
+// must never be more than a polecat may do.
+var DefaultRules = map[Role][]string{
+	RoleHuman: {"/"},
+	RoleCrew:  {"/"},
+	RolePolecat: {
+		"GET /",
+		"!GET /agents/*/terminal",
+		"POST /health",
+		"POST /file",
+		"POST /plugin",
+		"POST /refinery/submit",
+		"POST /scheduler/schedules",
+		"/agents/{self}",
+	},
+	RoleAnonymous: {
+		"GET /",
+		"!GET /agents/*/terminal",
+		"POST /file",
+		"POST /plugin",
+	},
+}
+
+// DefaultPolicy returns the shipped policy.
+func DefaultPolicy() Policy {
+	p, err := ParsePolicy(nil)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// ParsePolicy returns the shipped policy with each role named in overrides
+// replaced by the rules given for it. An unknown role or a malformed rule is
+// an error: a policy that silently dropped a line would grant or refuse
+// something nobody asked for.
+func ParsePolicy(overrides map[string][]string) (Policy, error) {
+	lines := make(map[Role][]string, len(DefaultRules))
+	for role, rules := range DefaultRules {
+		lines[role] = rules
+	}
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if role := Role(name); !validRole(role) && role != RoleAnonymous {
+			return nil, fmt.Errorf("policy names unknown role %q (want human, crew, polecat or anonymous)", name)
+		}
+		lines[Role(name)] = overrides[name]
+	}
+	p := make(Policy, len(lines))
+	for role, rules := range lines {
+		for _, line := range rules {
+			r, err := ParseRule(line)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", role, err)
+			}
+			p[role] = append(p[role], r)
+		}
+	}
+	return p, nil
+}
+
+// Allows reports whether id may call method on urlPath, and the rule that
+// decided it: the denial that matched, else the first allow, else nil.
+func (p Policy) Allows(id Identity, method, urlPath string) (bool, *Rule) {
+	segs := segments(urlPath)
+	var allowedBy *Rule
+	for i, r := range p[id.Role] {
+		if !r.matches(method, segs, id.Subject) {
+			continue
+		}
+		if r.Deny {
+			return false, &p[id.Role][i]
+		}
+		if allowedBy == nil {
+			allowedBy = &p[id.Role][i]
+		}
+	}
+	return allowedBy != nil, allowedBy
+}
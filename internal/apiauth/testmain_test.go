@@ -0,0 +1,28 @@
+package apiauth
+
+import (
+	"os"
+	"testing"
+
+	"github.com/drellem2/pogo/internal/testsandbox"
+)
+
+// sandbox is the package's private, CHECKED envelope (internal/testsandbox).
+// The tests sign with keys they generate and never open api.key, but the key
+// and the human's token live under $POGO_HOME, and a test that reached for the
+// real ones would be minting tokens the running pogod accepts.
+var sandbox *testsandbox.Sandbox
+
+func TestMain(m *testing.M) {
+	sb, down := testsandbox.Main("apiauth")
+	sandbox = sb
+
+	code := m.Run()
+
+	down()
+	os.Exit(code)
+}
+
+func TestSandboxIsVerified(t *testing.T) {
+	testsandbox.Verify(t, sandbox)
+}
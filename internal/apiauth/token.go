@@ -0,0 +1,200 @@
+// Package apiauth decides who may call which pogod route (user-021).
+//
+// pogod's API used to be open to anything that could reach its TCP port, and
+// every agent pogod runs can: a polecat sent to fix one test could stop the
+// coordinator, cancel another agent's MR or flip /server/mode, and nothing in
+// the request said who it came from. This package gives each caller an
+// identity and each identity a role, and the Policy maps roles to the routes
+// they may call.
+//
+// An identity comes from a bearer token. It names its role and subject and is
+// signed with a key only pogod holds, so it cannot be minted by an agent;
+// pogod issues one to every agent it spawns ($POGO_API_TOKEN) and one for the
+// human ($POGO_HOME/api-token). The unix socket takes a token like TCP does.
+// It is mode 0600, which says the peer is this user — but so is every agent,
+// and no sandbox profile can stop a connect() to a socket, so the socket alone
+// is not the human.
+//
+// What this does not buy, stated where the mechanism is: agents run as the
+// same user as pogod, and one that reads the human's token file or pogod's
+// key is the human as far as pogod can tell. The token keeps an agent's own
+// pogo commands inside its role — which is what stops a confused polecat
+// parking the coordinator — and a sandbox profile (user-012), which hides
+// both files, is what makes it hold against one that tries.
+package apiauth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Role is what an identity is allowed to do, through the Policy.
+type Role string
+
+const (
+	// RoleHuman is the operator: the CLI, with the human token.
+	RoleHuman Role = "human"
+	// RoleCrew is a long-lived crew agent, the coordinator among them.
+	RoleCrew Role = "crew"
+	// RolePolecat is a worker spawned for one work item.
+	RolePolecat Role = "polecat"
+	// RoleAnonymous is a tokenless caller over TCP: an editor plugin or a
+	// script, or an agent that dropped its token. No token carries it.
+	RoleAnonymous Role = "anonymous"
+)
+
+// Roles lists the roles a token can carry.
+var Roles = []Role{RoleHuman, RoleCrew, RolePolecat}
+
+func validRole(r Role) bool {
+	for _, known := range Roles {
+		if r == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Identity is who a request came from.
+type Identity struct {
+	Role Role `json:"role"`
+	// Subject is the agent name for an agent's token, "human" for the
+	// human's, and empty for RoleAnonymous.
+	Subject string `json:"subject,omitempty"`
+}
+
+func (id Identity) String() string {
+	if id.Subject == "" {
+		return string(id.Role)
+	}
+	return string(id.Role) + ":" + id.Subject
+}
+
+// tokenPrefix marks a pogo API token and its format version.
+const tokenPrefix = "pogo1"
+
+// keyBytes is the length of the signing key.
+const keyBytes = 32
+
+// ErrBadToken is returned for a token that is malformed, names an unknown
+// role, or was not signed with this pogod's key.
+var ErrBadToken = errors.New("invalid API token")
+
+// Issuer signs and verifies tokens with one key.
+//
+// Tokens are stateless: a token is its role and subject plus an HMAC over
+// them, so pogod keeps no table of issued tokens and a token stays good across
+// a pogod restart for as long as the key file does. That matters because
+// agents outlive pogod (user-011 holders): an agent adopted by the next pogod
+// still carries the token the last one gave it. Deleting the key file revokes
+// every token at the next start.
+type Issuer struct {
+	key []byte
+}
+
+// NewIssuer returns an Issuer signing with key.
+func NewIssuer(key []byte) *Issuer {
+	return &Issuer{key: append([]byte(nil), key...)}
+}
+
+// LoadIssuer reads the signing key at path, creating it (mode 0600) when it
+// does not exist yet.
+func LoadIssuer(path string) (*Issuer, error) {
+	key, err := os.ReadFile(path)
+	if err == nil {
+		if len(key) < keyBytes {
+			return nil, fmt.Errorf("API key %s is %d bytes, want %d; delete it to make a new one", path, len(key), keyBytes)
+		}
+		return NewIssuer(key), nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read API key: %w", err)
+	}
+	key = make([]byte, keyBytes)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generate API key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("create API key dir: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			// Another process made it first; use theirs.
+			return LoadIssuer(path)
+		}
+		return nil, fmt.Errorf("create API key: %w", err)
+	}
+	if _, err := f.Write(key); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("write API key: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("write API key: %w", err)
+	}
+	return NewIssuer(key), nil
+}
+
+// Issue returns a token for subject in role.
+func (iss *Issuer) Issue(role Role, subject string) (string, error) {
+	if !validRole(role) {
+		return "", fmt.Errorf("unknown role %q", role)
+	}
+	if subject == "" {
+		return "", errors.New("a token needs a subject")
+	}
+	payload := base64.RawURLEncoding.EncodeToString([]byte(string(role) + ":" + subject))
+	return tokenPrefix + "." + payload + "." + iss.sign(payload), nil
+}
+
+// Verify returns the identity a token was issued to.
+func (iss *Issuer) Verify(token string) (Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != tokenPrefix {
+		return Identity{}, ErrBadToken
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(iss.sign(parts[1]))) {
+		return Identity{}, ErrBadToken
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Identity{}, ErrBadToken
+	}
+	role, subject, ok := strings.Cut(string(raw), ":")
+	if !ok || subject == "" || !validRole(Role(role)) {
+		return Identity{}, ErrBadToken
+	}
+	return Identity{Role: Role(role), Subject: subject}, nil
+}
+
+func (iss *Issuer) sign(payload string) string {
+	mac := hmac.New(sha256.New, iss.key)
+	mac.Write([]byte(tokenPrefix + "." + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// WriteHumanToken issues the human's token and writes it to path, mode 0600,
+// for the CLI to read. It is rewritten on every start so a rotated key is
+// picked up.
+func (iss *Issuer) WriteHumanToken(path string) error {
+	token, err := iss.Issue(RoleHuman, string(RoleHuman))
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(token+"\n"), 0600); err != nil {
+		return fmt.Errorf("write human token: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write human token: %w", err)
+	}
+	return nil
+}
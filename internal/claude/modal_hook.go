@@ -1,9 +1,10 @@
 // Modal-dismissal watcher (mg-4421 — combined impl of mg-ef6b and mg-5a3d).
 //
 // Lives in pogod's PTY-managing goroutine for each agent. Subscribes to the
-// tee'd PTY-output stream, byte-scans for any of the configured modal markers,
-// and on a confirmed wedge writes the modal's dismissal keystroke directly to
-// the agent's PTY stdin. No `pogo schedule` involvement; the watcher survives
+// tee'd PTY-output stream, checks the agent's rendered screen for any of the
+// configured modal markers whenever a chunk changes it (user-014), and on a
+// confirmed wedge writes the modal's dismissal keystroke directly to the
+// agent's PTY stdin. No `pogo schedule` involvement; the watcher survives
 // schedule-substrate failures (mg-8e5d class).
 //
 // Why one watcher with a matcher table rather than one watcher per modal:
@@ -25,7 +26,6 @@
 package claude
 
 import (
-	"bytes"
 	"context"
 	"io"
 	"log"
@@ -35,22 +35,22 @@ import (
 	"github.com/drellem2/pogo/internal/agent"
 	"github.com/drellem2/pogo/internal/client"
 	"github.com/drellem2/pogo/internal/events"
+	"github.com/drellem2/pogo/internal/vt"
 )
 
 // RatingDialogMarker matches Claude Code's mid-session session-rating prompt.
 //
-// Marker matching is whitespace-insensitive (see matchNormalize): the spaces
-// written here are only for readability. This is load-bearing, not cosmetic —
-// Claude Code renders the option row as a TUI footer whose columns are placed
-// with cursor-forward escapes (ESC[<n>C), NOT literal spaces. StripANSI deletes
-// those escapes outright rather than substituting a space, so the on-screen
-// "1:Bad 2:Fine 3:Good 0:Dismiss" reaches the scan buffer as the run-together
-// "1:Bad2:Fine3:Good0:Dismiss". A literal bytes.Contains against the spaced
-// string therefore never matched in production — the watcher logged zero
-// rating-dialog dismissals between its 2026-05-19 merge and the 2026-07-13
-// wedge (mg-f36b) despite the dialog appearing. Normalizing whitespace out of
-// both the buffer and the marker fixes that and absorbs lesser drift (a space
-// after each colon, doubled spaces) for free.
+// Marker matching is whitespace-insensitive (vt.Snapshot.Contains): the spaces
+// written here are only for readability. Claude Code renders the option row as
+// a TUI footer whose columns are placed with cursor-forward escapes (ESC[<n>C),
+// NOT literal spaces. When the watcher scanned StripANSI output those escapes
+// were deleted outright, so the on-screen "1:Bad 2:Fine 3:Good 0:Dismiss"
+// reached the scan buffer as the run-together "1:Bad2:Fine3:Good0:Dismiss" and
+// a literal compare never matched in production — zero rating-dialog
+// dismissals between the watcher's 2026-05-19 merge and the 2026-07-13 wedge
+// (mg-f36b). The watcher now reads the rendered screen, where those escapes are
+// blank cells, and the whitespace-insensitive compare absorbs the drift that
+// remains (a space after each colon, doubled spaces, a column moved).
 const RatingDialogMarker = "1:Bad 2:Fine 3:Good 0:Dismiss"
 
 // RateLimitMarker matches the first menu option of Claude Code's API
@@ -167,9 +167,13 @@ const markerRecency = 60 * time.Second
 // mutating a shared global.
 const defaultEventsStalePollInterval = 30 * time.Second
 
-// scanBufBytes is the size of the per-watcher sliding output buffer. Must
-// comfortably exceed the longest marker; 8 KiB is plenty.
-const scanBufBytes = 8 * 1024
+// scanScreenCols and scanScreenRows size the screen a watcher keeps for itself
+// when its deps supply none: pogo's default PTY winsize, which is what Claude
+// runs at (its Provider sets no PTYSize).
+const (
+	scanScreenCols = 200
+	scanScreenRows = 50
+)
 
 // ActivityTracker reports the last time we observed an event-log line for
 // a given agent identity. Exported so tests can inject deterministic values.
@@ -189,6 +193,18 @@ type ModalHookDeps struct {
 	EmitEvent func(ev events.Event)      // dismissal observability
 	NotifyPM  func(agentID, matcherName string)
 
+	// Screen returns the agent's rendered screen (user-014), which the
+	// markers are matched against. The agent feeds its screen before the
+	// chunk reaches Subscribe's writer, so a read from the watcher's Write
+	// includes that chunk. Nil makes the watcher keep a screen of its own,
+	// fed from Subscribe — what tests that drive raw bytes want.
+	Screen func() vt.Snapshot
+	// ScreenGeneration returns Screen's vt.Screen.Generation. The watcher
+	// renders and matches the screen only when it has moved, so a chatty
+	// agent does not pay for a full render on every chunk. Nil with a
+	// non-nil Screen makes the watcher render on every chunk.
+	ScreenGeneration func() uint64
+
 	// WorkItemID is the agent's mg work item (e.g. "mg-7ffa"), stamped into the
 	// usage_limit_hit / usage_limit_cleared events and the coordinator roster.
 	// Empty for agents not tied to an item.
@@ -215,6 +231,9 @@ func ModalHook(ctx context.Context, a *agent.Agent) {
 		Now:       time.Now,
 		EmitEvent: func(ev events.Event) { events.Emit(context.Background(), ev) },
 		NotifyPM:  defaultNotifyPM,
+		Screen:    a.Screen,
+
+		ScreenGeneration: a.ScreenGeneration,
 
 		WorkItemID:     a.WorkItemID,
 		SetRateLimited: a.SetRateLimited,
@@ -248,6 +267,10 @@ func RunModalHook(ctx context.Context, deps ModalHookDeps, matchers []ModalMatch
 	}
 
 	scanner := newModalScanner(matchers, deps.Now)
+	if deps.Screen != nil {
+		scanner.own, scanner.screen = nil, deps.Screen
+		scanner.generation = deps.ScreenGeneration
+	}
 	if deps.Subscribe != nil {
 		unsubscribe := deps.Subscribe(scanner)
 		defer unsubscribe()
@@ -264,25 +287,40 @@ func RunModalHook(ctx context.Context, deps ModalHookDeps, matchers []ModalMatch
 	wg.Wait()
 }
 
-// modalScanner accumulates a sliding window of PTY output (ANSI-stripped) and
-// signals per-matcher channels when each matcher's marker is currently visible
-// or when any chunk has arrived (the latter resets ModeScannerIdle's idle
+// modalScanner checks the agent's rendered screen on every PTY chunk that
+// changed it and signals per-matcher channels when each matcher's marker is
+// currently visible or when any chunk has arrived (the latter resets ModeScannerIdle's idle
 // window). All state is protected by mu; observed/output channels are
 // buffered (1) and dropped if full — dispatchMatcher re-reads scanner state
 // on wake, so dropping a signal can only delay a fire by one chunk.
+//
+// It used to keep an 8 KiB window of StripANSI output instead, which held
+// whatever Claude had drawn recently rather than what was showing: a marker
+// drawn and then erased stayed "visible" until 8 KiB more output pushed it
+// out, and one a redraw painted in pieces was never in the window whole.
 type modalScanner struct {
 	mu       sync.Mutex
-	rawBuf   []byte
 	matchers []ModalMatcher
 	now      func() time.Time
 
-	// normMarkers[i] is matcher i's LineMarker with whitespace stripped
-	// (matchNormalize), precomputed once so Write doesn't re-normalize each
-	// marker on every chunk. Compared against the whitespace-stripped buffer.
-	normMarkers [][]byte
+	// screen returns what the markers are matched against. own is the screen
+	// behind it when the watcher keeps its own (see ModalHookDeps.Screen),
+	// fed by Write; nil when it reads the agent's.
+	screen func() vt.Snapshot
+	own    *vt.Screen
+
+	// generation returns the screen's vt.Screen.Generation, nil when
+	// unknown. checkedGen is its value at the last render, and visible[i]
+	// whether matcher i's marker was on the screen then; until the
+	// generation moves, a chunk reuses the answer rather than rendering
+	// again.
+	generation func() uint64
+	checked    bool
+	checkedGen uint64
+	visible    []bool
 
 	// markerLastSeen[i] records the most recent time matcher i's marker was
-	// observed in the cleaned buffer. Read by dispatchMatcher; updated by
+	// observed on the screen. Read by dispatchMatcher; updated by
 	// Write under mu.
 	markerLastSeen []time.Time
 
@@ -300,20 +338,21 @@ type modalScanner struct {
 }
 
 func newModalScanner(matchers []ModalMatcher, now func() time.Time) *modalScanner {
+	own := vt.New(scanScreenCols, scanScreenRows)
 	s := &modalScanner{
 		matchers:       matchers,
 		now:            now,
+		screen:         own.Snapshot,
+		own:            own,
+		generation:     own.Generation,
+		visible:        make([]bool, len(matchers)),
 		markerLastSeen: make([]time.Time, len(matchers)),
-		normMarkers:    make([][]byte, len(matchers)),
 		observed:       make([]chan struct{}, len(matchers)),
 		output:         make(chan struct{}, 1),
 	}
 	for i := range s.observed {
 		s.observed[i] = make(chan struct{}, 1)
 	}
-	for i, m := range matchers {
-		s.normMarkers[i] = matchNormalize([]byte(m.LineMarker))
-	}
 	return s
 }
 
@@ -324,19 +363,18 @@ func (s *modalScanner) Write(p []byte) (int, error) {
 	if len(p) == 0 {
 		return 0, nil
 	}
-	cp := make([]byte, len(p))
-	copy(cp, p)
 
 	s.mu.Lock()
-	s.rawBuf = append(s.rawBuf, cp...)
-	if len(s.rawBuf) > scanBufBytes {
-		s.rawBuf = s.rawBuf[len(s.rawBuf)-scanBufBytes:]
+	if s.own != nil {
+		s.own.Write(p)
 	}
-	clean := matchNormalize(agent.StripANSI(s.rawBuf))
+	s.check()
 	now := s.now()
 	s.lastChunk = now
-	for i := range s.matchers {
-		if bytes.Contains(clean, s.normMarkers[i]) {
+	// A marker still showing counts as observed again on every chunk, render
+	// or no: ModeEventsStale wants to know it was seen recently.
+	for i, v := range s.visible {
+		if v {
 			s.markerLastSeen[i] = now
 			nbSend(s.observed[i])
 		}
@@ -347,13 +385,31 @@ func (s *modalScanner) Write(p []byte) (int, error) {
 	return len(p), nil
 }
 
-// MarkerVisible reports whether matcher idx's marker is currently visible in
-// the cleaned buffer. Used by dispatchMatcher to re-verify before firing.
+// check renders the screen and matches every marker against it, unless the
+// screen's generation has not moved since the last check. The generation is
+// read before the render, so a change that lands in between is caught by the
+// next check. Called with mu held.
+func (s *modalScanner) check() {
+	var gen uint64
+	if s.generation != nil {
+		gen = s.generation()
+		if s.checked && gen == s.checkedGen {
+			return
+		}
+	}
+	snap := s.screen()
+	for i, m := range s.matchers {
+		s.visible[i] = snap.Contains(m.LineMarker)
+	}
+	s.checked, s.checkedGen = true, gen
+}
+
+// MarkerVisible reports whether matcher idx's marker is on the screen now.
+// Used by dispatchMatcher to re-verify before firing.
 func (s *modalScanner) MarkerVisible(idx int) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	clean := matchNormalize(agent.StripANSI(s.rawBuf))
-	return bytes.Contains(clean, s.normMarkers[idx])
+	return s.screen().Contains(s.matchers[idx].LineMarker)
 }
 
 // MarkerLastSeen returns the most recent time matcher idx's marker was seen.
@@ -379,31 +435,6 @@ func nbSend(ch chan struct{}) {
 	}
 }
 
-// matchNormalize strips ASCII whitespace so a marker matches the scan buffer
-// regardless of how the TUI spaced the on-screen text. It is applied to BOTH
-// the ANSI-stripped buffer and the marker before comparison. See the
-// RatingDialogMarker doc comment for why this is required rather than cosmetic:
-// modal footers arrive with their inter-column spaces already deleted by
-// StripANSI (the cursor-move escapes that produced them are gone, not turned
-// into spaces), so only a whitespace-insensitive compare reliably fires.
-//
-// Collapsing whitespace to nothing (rather than to a single space) is what
-// makes the compare robust to the column-move case; the markers are specific
-// enough ("1:Bad2:Fine3:Good0:Dismiss", "Stopandwaitforlimittoreset") that
-// run-together forms don't coincide with ordinary agent output, and the idle
-// gate is the real guard against transcript mentions regardless.
-func matchNormalize(b []byte) []byte {
-	out := make([]byte, 0, len(b))
-	for _, c := range b {
-		switch c {
-		case ' ', '\t', '\n', '\r', '\v', '\f':
-			continue
-		}
-		out = append(out, c)
-	}
-	return out
-}
-
 // dispatchMatcher is one goroutine per matcher. It owns the idle-gate state
 // machine for its mode and calls fireDismissal exactly once when the gate
 // passes (then waits dismissalCooldown before considering re-fire).
@@ -11,6 +11,7 @@ import (
 
 	"github.com/drellem2/pogo/internal/agent"
 	"github.com/drellem2/pogo/internal/events"
+	"github.com/drellem2/pogo/internal/vt"
 )
 
 // --- helpers ----------------------------------------------------------------
@@ -298,7 +299,7 @@ func TestModalHook_Case2_RatingDialogMentionedNoFalsePositive(t *testing.T) {
 	for i := range pad {
 		pad[i] = 'x'
 	}
-	// A few pads of trailing output — the marker stays within scanBufBytes, so
+	// A few pads of trailing output — the marker stays on the screen, so
 	// MarkerVisible remains true throughout the idle gap below.
 	for i := 0; i < 4; i++ {
 		rig.writeOutput(pad)
@@ -382,7 +383,7 @@ func TestModalHook_Case5_UserInvokedNoFire(t *testing.T) {
 		rig.tracker.set("cat-test", clock.Now())
 	}
 	// User-pick: simulated by output that doesn't contain the marker anymore.
-	rig.writeOutput(make([]byte, 4096)) // push 4 KiB to scroll marker out
+	rig.writeOutput([]byte("\x1b[2J\x1b[H")) // the picked menu clears the screen
 	for i := 0; i < 3; i++ {
 		time.Sleep(20 * time.Millisecond)
 		rig.tracker.set("cat-test", clock.Now())
@@ -678,21 +679,110 @@ func TestModalHook_ColumnMoveRatingDialogFires(t *testing.T) {
 	rig.mu.Unlock()
 }
 
-// TestMatchNormalize covers the whitespace-stripping helper directly, including
-// the drift variants it is meant to absorb (space after colon, doubled spaces).
-func TestMatchNormalize(t *testing.T) {
-	cases := []struct {
-		in, want string
-	}{
-		{"1:Bad 2:Fine 3:Good 0:Dismiss", "1:Bad2:Fine3:Good0:Dismiss"},
-		{"1: Bad  2: Fine\t3:Good\n0:Dismiss", "1:Bad2:Fine3:Good0:Dismiss"},
-		{"Stop and wait for limit to reset", "Stopandwaitforlimittoreset"},
-		{"", ""},
-		{"   \t\n\r", ""},
-	}
-	for _, c := range cases {
-		if got := string(matchNormalize([]byte(c.in))); got != c.want {
-			t.Errorf("matchNormalize(%q) = %q, want %q", c.in, got, c.want)
+// TestMarkerMatchAbsorbsSpacingDrift covers the whitespace-insensitive compare
+// against the drift variants it is meant to absorb — a space after each colon,
+// doubled spaces, a tab, the row broken in two, the spaces gone — drawn on the
+// watcher's screen.
+func TestMarkerMatchAbsorbsSpacingDrift(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	for _, drawn := range []string{
+		"1:Bad 2:Fine 3:Good 0:Dismiss",
+		"1: Bad  2: Fine\t3:Good\r\n0:Dismiss",
+		"1:Bad2:Fine3:Good0:Dismiss",
+	} {
+		scanner := newModalScanner(testMatchers(), clock.Now)
+		scanner.Write([]byte(drawn))
+		if !scanner.MarkerVisible(0) {
+			t.Errorf("marker not visible for %q", drawn)
 		}
 	}
 }
+
+// TestModalScannerErasedMarkerIsGone is what reading the screen buys over
+// reading the stream (user-014): once Claude erases the dialog, the marker is
+// no longer visible, however little output followed. The stripped 8 KiB window
+// this replaced would have reported it visible until 8 KiB more arrived.
+func TestModalScannerErasedMarkerIsGone(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	scanner := newModalScanner(testMatchers(), clock.Now)
+	scanner.Write([]byte("\x1b[10;1H" + columnMoveRatingFooter))
+	if !scanner.MarkerVisible(0) {
+		t.Fatal("expected the footer visible once drawn")
+	}
+	scanner.Write([]byte("\x1b[10;1H\x1b[2K"))
+	if scanner.MarkerVisible(0) {
+		t.Error("marker still visible after its row was erased")
+	}
+}
+
+// TestModalHookReadsTheAgentsScreen pins the production wiring: with
+// ModalHookDeps.Screen set, the watcher matches against that screen rather
+// than the bytes Subscribe hands it.
+func TestModalHookReadsTheAgentsScreen(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	rig := newTestRig(clock.Now)
+	rig.tracker.set("cat-test", clock.Now())
+	screen := vt.New(80, 24)
+	deps := rig.deps("cat-test")
+	deps.Screen = screen.Snapshot
+	deps.ScreenGeneration = screen.Generation
+	rig.startHook(t, deps, testMatchers())
+
+	// The stream names the marker; the screen does not show it.
+	rig.writeOutput([]byte(RatingDialogMarker + "\n"))
+	clock.Advance(200 * time.Millisecond)
+	time.Sleep(150 * time.Millisecond)
+	if rig.dismissals() != 0 {
+		t.Fatalf("dismissed on a marker that was never on the screen")
+	}
+
+	screen.Write([]byte(columnMoveRatingFooter))
+	rig.writeOutput([]byte("x"))
+	clock.Advance(200 * time.Millisecond)
+	if !waitFor(t, time.Second, func() bool { return rig.dismissals() >= 1 }) {
+		t.Fatalf("expected a dismissal once the screen showed the dialog, got %d", rig.dismissals())
+	}
+}
+
+// TestModalScannerRendersOnlyAChangedScreen: a chunk that leaves the screen's
+// text as it was — a dialog repainted over itself, a cursor move — reuses
+// the last match instead of rendering the screen again, but still counts a
+// marker that is showing as seen now.
+func TestModalScannerRendersOnlyAChangedScreen(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	scanner := newModalScanner(testMatchers(), clock.Now)
+	own := scanner.own
+	renders := 0
+	scanner.screen = func() vt.Snapshot {
+		renders++
+		return own.Snapshot()
+	}
+
+	scanner.Write([]byte("\x1b[10;1H" + columnMoveRatingFooter))
+	if renders != 1 || !scanner.MarkerVisible(0) {
+		t.Fatalf("renders = %d, visible = %v after drawing the footer", renders, scanner.MarkerVisible(0))
+	}
+	renders = 0
+	first := scanner.MarkerLastSeen(0)
+	for i := 0; i < 5; i++ {
+		clock.Advance(time.Second)
+		// The footer written over itself, without erasing it first.
+		scanner.Write([]byte("\x1b[10;1H\x1b[38;5;244m1:Bad\x1b[3C2:Fine\x1b[3C3:Good\x1b[3C0:Dismiss\x1b[0m\x1b[1;1H"))
+	}
+	if renders != 0 {
+		t.Errorf("renders = %d for chunks that left the screen as it was, want 0", renders)
+	}
+	if got := scanner.MarkerLastSeen(0); !got.Equal(first.Add(5 * time.Second)) {
+		t.Errorf("MarkerLastSeen = %v, want %v", got, first.Add(5*time.Second))
+	}
+
+	scanner.Write([]byte("\x1b[10;1H\x1b[2K"))
+	if renders != 1 {
+		t.Errorf("renders = %d after erasing the footer, want 1", renders)
+	}
+	clock.Advance(time.Second)
+	scanner.Write([]byte("\x1b[1;1H"))
+	if got := scanner.MarkerLastSeen(0); !got.Equal(first.Add(5 * time.Second)) {
+		t.Errorf("MarkerLastSeen moved to %v once the footer was gone", got)
+	}
+}
@@ -81,6 +81,10 @@ var Provider = agent.Provider{
 	// can finally be named. mg cannot say it (it does not own liveness) and the
 	// send cannot say it (it succeeds identically either way) — mg-d924.
 	MailRecipientHook: InstallMailRecipientHook,
+
+	// Claude Code's print mode with stream-json on both ends: the structured
+	// mode a stream-mode agent runs in (stream.go, user-019).
+	Stream: &Stream,
 }
 
 // SessionTranscriptGlob returns the home-relative glob matching the Claude Code
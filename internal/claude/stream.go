@@ -0,0 +1,132 @@
+package claude
+
+import (
+	"encoding/json"
+
+	"github.com/drellem2/pogo/internal/agent"
+)
+
+// Stream is Claude Code's structured mode: `-p` with stream-json input and
+// output. In it Claude Code reads one user message per stdin line for as long
+// as stdin is open, and writes one JSON object per line for everything it does
+// — a system/init header, each assistant message with its text and tool_use
+// blocks, each tool's result as a user message, and a result object that ends
+// the turn with its usage and cost. --verbose is not optional: without it
+// stream-json output is refused in print mode.
+var Stream = agent.StreamDialect{
+	Flags:  []string{"-p", "--input-format", "stream-json", "--output-format", "stream-json", "--verbose"},
+	Decode: decodeStreamLine,
+	Encode: encodeStreamInput,
+}
+
+// streamLine is the part of a stream-json line pogo reads.
+type streamLine struct {
+	Type      string `json:"type"`
+	Subtype   string `json:"subtype"`
+	SessionID string `json:"session_id"`
+	Model     string `json:"model"`
+	Message   struct {
+		Content json.RawMessage `json:"content"`
+	} `json:"message"`
+
+	// The result line.
+	IsError      bool    `json:"is_error"`
+	Result       string  `json:"result"`
+	TotalCostUSD float64 `json:"total_cost_usd"`
+	Usage        struct {
+		InputTokens          int64 `json:"input_tokens"`
+		OutputTokens         int64 `json:"output_tokens"`
+		CacheReadInputTokens int64 `json:"cache_read_input_tokens"`
+	} `json:"usage"`
+}
+
+// contentBlock is one block of a message's content.
+type contentBlock struct {
+	Type    string          `json:"type"`
+	Text    string          `json:"text"`
+	Name    string          `json:"name"`
+	IsError bool            `json:"is_error"`
+	Content json.RawMessage `json:"content"`
+}
+
+func decodeStreamLine(line []byte) ([]agent.StreamEvent, bool) {
+	var l streamLine
+	if err := json.Unmarshal(line, &l); err != nil || l.Type == "" {
+		return nil, false
+	}
+	switch l.Type {
+	case "system":
+		if l.Subtype == "init" {
+			return []agent.StreamEvent{{Kind: agent.StreamInit, SessionID: l.SessionID, Model: l.Model}}, true
+		}
+	case "assistant":
+		var evs []agent.StreamEvent
+		for _, b := range contentBlocks(l.Message.Content) {
+			switch b.Type {
+			case "text":
+				evs = append(evs, agent.StreamEvent{Kind: agent.StreamText, Text: b.Text})
+			case "tool_use":
+				evs = append(evs, agent.StreamEvent{Kind: agent.StreamToolCall, Tool: b.Name})
+			}
+		}
+		return evs, true
+	case "user":
+		var evs []agent.StreamEvent
+		for _, b := range contentBlocks(l.Message.Content) {
+			if b.Type == "tool_result" {
+				evs = append(evs, agent.StreamEvent{Kind: agent.StreamToolResult, IsError: b.IsError})
+			}
+		}
+		return evs, true
+	case "result":
+		ev := agent.StreamEvent{
+			Kind:    agent.StreamTurnEnd,
+			IsError: l.IsError,
+			Usage: &agent.StreamUsage{
+				InputTokens:     l.Usage.InputTokens,
+				OutputTokens:    l.Usage.OutputTokens,
+				CacheReadTokens: l.Usage.CacheReadInputTokens,
+				CostUSD:         l.TotalCostUSD,
+			},
+		}
+		// An error result names itself in its subtype (error_max_turns,
+		// error_during_execution) and may carry no text at all.
+		if l.IsError {
+			ev.Text = l.Result
+			if ev.Text == "" {
+				ev.Text = l.Subtype
+			}
+		}
+		return []agent.StreamEvent{ev}, true
+	}
+	return nil, true
+}
+
+// contentBlocks reads a message's content, which is either a list of blocks or
+// a bare string.
+func contentBlocks(raw json.RawMessage) []contentBlock {
+	var blocks []contentBlock
+	if json.Unmarshal(raw, &blocks) == nil {
+		return blocks
+	}
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return []contentBlock{{Type: "text", Text: text}}
+	}
+	return nil
+}
+
+func encodeStreamInput(message string) ([]byte, error) {
+	type block struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	type msg struct {
+		Role    string  `json:"role"`
+		Content []block `json:"content"`
+	}
+	return json.Marshal(struct {
+		Type    string `json:"type"`
+		Message msg    `json:"message"`
+	}{"user", msg{"user", []block{{"text", message}}}})
+}
@@ -0,0 +1,54 @@
+package claude
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/drellem2/pogo/internal/agent"
+)
+
+// Claude Code's stream-json lines read as pogo's stream events, and a message
+// goes back as a stream-json user message.
+func TestStreamDialectDecodesClaudeStreamJSON(t *testing.T) {
+	for _, tc := range []struct {
+		line string
+		want []agent.StreamEvent
+	}{
+		{`{"type":"system","subtype":"init","session_id":"abc","model":"claude-x","tools":["Bash"]}`,
+			[]agent.StreamEvent{{Kind: agent.StreamInit, SessionID: "abc", Model: "claude-x"}}},
+		{`{"type":"assistant","message":{"content":[{"type":"text","text":"on it"},{"type":"tool_use","name":"Bash","input":{"command":"ls"}}]}}`,
+			[]agent.StreamEvent{{Kind: agent.StreamText, Text: "on it"}, {Kind: agent.StreamToolCall, Tool: "Bash"}}},
+		{`{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"t1","content":"boom","is_error":true}]}}`,
+			[]agent.StreamEvent{{Kind: agent.StreamToolResult, IsError: true}}},
+		{`{"type":"result","subtype":"success","is_error":false,"result":"done","total_cost_usd":0.12,"usage":{"input_tokens":7,"output_tokens":3,"cache_read_input_tokens":100}}`,
+			[]agent.StreamEvent{{Kind: agent.StreamTurnEnd, Usage: &agent.StreamUsage{InputTokens: 7, OutputTokens: 3, CacheReadTokens: 100, CostUSD: 0.12}}}},
+		{`{"type":"result","subtype":"error_max_turns","is_error":true,"usage":{}}`,
+			[]agent.StreamEvent{{Kind: agent.StreamTurnEnd, IsError: true, Text: "error_max_turns", Usage: &agent.StreamUsage{}}}},
+		{`{"type":"rate_limit_event"}`, nil},
+	} {
+		got, ok := Stream.Decode([]byte(tc.line))
+		if !ok || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Decode(%s) = %+v, %v\nwant %+v", tc.line, got, ok, tc.want)
+		}
+	}
+	if _, ok := Stream.Decode([]byte("Warning: something")); ok {
+		t.Error("a line that is not JSON decoded as an event")
+	}
+
+	line, err := Stream.Encode("fix \"it\"")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg struct {
+		Type    string
+		Message struct {
+			Role    string
+			Content []struct{ Type, Text string }
+		}
+	}
+	if err := json.Unmarshal(line, &msg); err != nil || msg.Type != "user" || msg.Message.Role != "user" ||
+		len(msg.Message.Content) != 1 || msg.Message.Content[0].Text != "fix \"it\"" {
+		t.Errorf("Encode = %s", line)
+	}
+}
@@ -17,12 +17,13 @@ import (
 
 	"github.com/drellem2/pogo/internal/agent"
 	"github.com/drellem2/pogo/internal/config"
+	"github.com/drellem2/pogo/internal/vt"
 	"github.com/drellem2/pogo/internal/workitem"
 )
 
 // ListAgents returns all running agents from pogod.
 func ListAgents() ([]agent.AgentInfo, error) {
-	r, err := http.Get(serverURL + "/agents")
+	r, err := pogodClient.Get(serverURL + "/agents")
 	if err != nil {
 		return nil, err
 	}
@@ -44,7 +45,7 @@ func ListAgents() ([]agent.AgentInfo, error) {
 // not a complete one, and callers must not render it as though everybody were
 // present.
 func AgentRoster() (*agent.RosterReport, error) {
-	r, err := http.Get(serverURL + "/agents/roster")
+	r, err := pogodClient.Get(serverURL + "/agents/roster")
 	if err != nil {
 		return nil, err
 	}
@@ -66,7 +67,7 @@ func AgentRoster() (*agent.RosterReport, error) {
 
 // GetAgent returns details for a specific agent.
 func GetAgent(name string) (*agent.AgentInfo, error) {
-	r, err := http.Get(serverURL + "/agents/" + name)
+	r, err := pogodClient.Get(serverURL + "/agents/" + name)
 	if err != nil {
 		return nil, err
 	}
@@ -87,7 +88,7 @@ func SpawnAgent(req agent.SpawnAPIRequest) (*agent.AgentInfo, error) {
 	if err != nil {
 		return nil, err
 	}
-	r, err := http.Post(serverURL+"/agents", "application/json", bytes.NewReader(body))
+	r, err := pogodClient.Post(serverURL+"/agents", "application/json", bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
@@ -109,7 +110,7 @@ func StartAgent(name string) (*agent.AgentInfo, error) {
 	if err != nil {
 		return nil, err
 	}
-	r, err := http.Post(serverURL+"/agents/start", "application/json", bytes.NewReader(body))
+	r, err := pogodClient.Post(serverURL+"/agents/start", "application/json", bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
@@ -130,7 +131,7 @@ func StopAgent(name string) error {
 	if err != nil {
 		return err
 	}
-	r, err := http.DefaultClient.Do(req)
+	r, err := pogodClient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -148,7 +149,7 @@ func StopAgent(name string) error {
 // ParkAgent asks pogod to park a crew agent: stop it, persist a park flag
 // that suppresses respawn and auto-start, and pause its schedules.
 func ParkAgent(name string) (*agent.ParkAPIResponse, error) {
-	r, err := http.Post(serverURL+"/agents/"+name+"/park", "application/json", nil)
+	r, err := pogodClient.Post(serverURL+"/agents/"+name+"/park", "application/json", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -167,7 +168,7 @@ func ParkAgent(name string) (*agent.ParkAPIResponse, error) {
 // WakeAgent asks pogod to wake a parked crew agent: start it, restore its
 // recorded schedules, and clear the park flag.
 func WakeAgent(name string) (*agent.WakeAPIResponse, error) {
-	r, err := http.Post(serverURL+"/agents/"+name+"/wake", "application/json", nil)
+	r, err := pogodClient.Post(serverURL+"/agents/"+name+"/wake", "application/json", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -203,7 +204,7 @@ func NudgeAgent(name, message string, opts *NudgeOpts) error {
 	if err != nil {
 		return err
 	}
-	r, err := http.Post(serverURL+"/agents/"+name+"/nudge", "application/json", bytes.NewReader(body))
+	r, err := pogodClient.Post(serverURL+"/agents/"+name+"/nudge", "application/json", bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
@@ -236,7 +237,7 @@ func GetHostLoad(repo string) (*agent.HostLoadResponse, error) {
 	if strings.TrimSpace(repo) != "" {
 		u += "?repo=" + url.QueryEscape(repo)
 	}
-	r, err := http.Get(u)
+	r, err := pogodClient.Get(u)
 	if err != nil {
 		return nil, err
 	}
@@ -258,7 +259,7 @@ func SpawnPolecat(req agent.SpawnPolecatAPIRequest) (*agent.AgentInfo, error) {
 	if err != nil {
 		return nil, err
 	}
-	r, err := http.Post(serverURL+"/agents/spawn-polecat", "application/json", bytes.NewReader(body))
+	r, err := pogodClient.Post(serverURL+"/agents/spawn-polecat", "application/json", bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
@@ -325,7 +326,7 @@ func isEndpointMissingBody(body string) bool {
 
 // ListPrompts returns all discovered prompt files from pogod.
 func ListPrompts() ([]agent.PromptInfo, error) {
-	r, err := http.Get(serverURL + "/agents/prompts")
+	r, err := pogodClient.Get(serverURL + "/agents/prompts")
 	if err != nil {
 		return nil, err
 	}
@@ -1112,7 +1113,7 @@ func execCommandFunc(name string, args ...string) *exec.Cmd {
 // DiagnoseAgent returns diagnostic information for a specific agent,
 // including stall detection, process health, and recent activity.
 func DiagnoseAgent(name string) (*agent.DiagnoseInfo, error) {
-	r, err := http.Get(serverURL + "/agents/" + name + "/diagnose")
+	r, err := pogodClient.Get(serverURL + "/agents/" + name + "/diagnose")
 	if err != nil {
 		return nil, err
 	}
@@ -1135,7 +1136,7 @@ func DiagnoseAgent(name string) (*agent.DiagnoseInfo, error) {
 // it when it has no basis to judge, and a caller that rendered that as "nothing
 // missing" would be doing exactly what this whole feature exists to prevent.
 func MailLoopReport() (*agent.MailLoopReport, error) {
-	r, err := http.Get(serverURL + "/agents/mail-loops")
+	r, err := pogodClient.Get(serverURL + "/agents/mail-loops")
 	if err != nil {
 		return nil, err
 	}
@@ -1154,6 +1155,29 @@ func MailLoopReport() (*agent.MailLoopReport, error) {
 	return &rep, nil
 }
 
+// GetAgentScreen returns what is on an agent's terminal now: the screen pogod
+// keeps from its PTY output (user-014), with the cursor and the screen's size.
+func GetAgentScreen(name string) (*vt.Snapshot, error) {
+	u := serverURL + "/agents/" + name + "/screen?format=json"
+	r, err := pogodClient.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("agent %q not found", name)
+	}
+	if r.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(r.Body)
+		return nil, fmt.Errorf("pogod returned %s for %s: %s", r.Status, u, strings.TrimSpace(string(body)))
+	}
+	var snap vt.Snapshot
+	if err := json.NewDecoder(r.Body).Decode(&snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
 // AgentOutputOptions selects how much of an agent's PTY ring to retrieve, and
 // in what form. The zero value asks for the server's default window
 // (agent.DefaultOutputBytes) with escape sequences intact.
@@ -1187,7 +1211,7 @@ func GetAgentOutput(name string, opts AgentOutputOptions) (string, error) {
 	if len(q) > 0 {
 		u += "?" + q.Encode()
 	}
-	r, err := http.Get(u)
+	r, err := pogodClient.Get(u)
 	if err != nil {
 		return "", err
 	}
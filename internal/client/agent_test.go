@@ -508,3 +508,31 @@ func TestGetAgentOutput_NotFound(t *testing.T) {
 		t.Errorf("404 should surface as a not-found error; got %v", err)
 	}
 }
+
+// TestGetAgentScreen_AsksForJSON pins that the client always asks for the
+// structured screen, which the CLI renders itself, and surfaces a rejection as
+// an error.
+func TestGetAgentScreen_AsksForJSON(t *testing.T) {
+	var gotQuery, gotPath string
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		gotQuery, gotPath = r.URL.RawQuery, r.URL.Path
+		w.Write([]byte(`{"cols":80,"rows":2,"lines":["$ claude",""],"cursor":{"row":1,"col":0},"cursor_visible":true,"alt_screen":false}`))
+	})
+	snap, err := GetAgentScreen("mayor")
+	if err != nil {
+		t.Fatalf("GetAgentScreen: %v", err)
+	}
+	if gotPath != "/agents/mayor/screen" || gotQuery != "format=json" {
+		t.Errorf("request = %s?%s, want /agents/mayor/screen?format=json", gotPath, gotQuery)
+	}
+	if snap.Cols != 80 || snap.Text() != "$ claude\n" || snap.Cursor.Row != 1 {
+		t.Errorf("snapshot = %+v", snap)
+	}
+
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "agent \"ghost\" not found", http.StatusNotFound)
+	})
+	if _, err := GetAgentScreen("ghost"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("404 should surface as a not-found error; got %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package client
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
@@ -25,6 +26,25 @@ import (
 // a stray Ctrl-\ into the agent's TUI and leave the user stuck attached).
 const detachByte = 0x1c
 
+// takeoverByte is the keystroke that takes the agent's writer lock from
+// whoever holds it: Ctrl-] (ASCII GS, 0x1d), telnet's escape key. Like the
+// detach byte it is consumed here and never reaches the agent; see
+// internal/agent/attachclients.go for the lock.
+const takeoverByte = 0x1d
+
+// AttachOptions are how a client attaches. The zero value is an ordinary
+// read-write attach.
+type AttachOptions struct {
+	// ReadOnly attaches as an observer: no keystroke and no resize is sent,
+	// so the agent cannot be typed into or redrawn from this terminal. The
+	// detach key still works.
+	ReadOnly bool
+	// Takeover takes the writer lock on connect instead of on first input.
+	Takeover bool
+	// Who names this client in the presence list; empty means $USER@host.
+	Who string
+}
+
 // attachIO is the terminal AttachAgent drives. Production wiring is the
 // process's real stdin/stdout plus golang.org/x/term; tests substitute pipes
 // and stubs so the attach path — including the detach-time terminal restore —
@@ -57,10 +77,18 @@ func stdAttachIO() attachIO {
 // mode, after which input bytes are wrapped in data frames and
 // SIGWINCH-triggered resizes ride the same channel.
 func AttachAgent(socketPath string) error {
-	return attachAgent(socketPath, stdAttachIO())
+	return AttachAgentWith(socketPath, AttachOptions{})
 }
 
-func attachAgent(socketPath string, tio attachIO) error {
+// AttachAgentWith is AttachAgent with a role and a name. Right after the
+// handshake it sends a hello frame saying both, and from then on strips the
+// server's presence updates out of the output, showing the latest in the
+// terminal's title; Ctrl-] (takeoverByte) asks for the writer lock.
+func AttachAgentWith(socketPath string, opts AttachOptions) error {
+	return attachAgent(socketPath, stdAttachIO(), opts)
+}
+
+func attachAgent(socketPath string, tio attachIO, opts AttachOptions) error {
 	conn, err := net.Dial("unix", socketPath)
 	if err != nil {
 		return fmt.Errorf("connect to agent socket: %w", err)
@@ -91,13 +119,19 @@ func attachAgent(socketPath string, tio attachIO) error {
 	// still send the frame, with 0×0 dimensions: the server treats 0×0 as
 	// "size unknown — keep the current winsize", so framed mode is
 	// established unambiguously and the agent keeps its spawn-time default.
+	//
+	// An observer always sends 0×0: its window's size is not the agent's
+	// business.
 	cols, rows, gerr := tio.getSize(stdinFd)
-	if gerr != nil {
+	if gerr != nil || opts.ReadOnly {
 		cols, rows = 0, 0
 	}
 	if err := sendHandshakeFrame(conn, &writeMu, cols, rows); err != nil {
 		return fmt.Errorf("send attach handshake: %w", err)
 	}
+	if err := sendHelloFrame(conn, &writeMu, helloFor(opts)); err != nil {
+		return fmt.Errorf("send attach hello: %w", err)
+	}
 
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGWINCH)
@@ -107,23 +141,28 @@ func attachAgent(socketPath string, tio attachIO) error {
 
 	// SIGWINCH → resize frame. Reads current terminal size on each fire and
 	// forwards it to the server so the agent's PTY mirrors the user's window.
-	go func() {
-		defer func() { done <- struct{}{} }()
-		for range sigCh {
-			cols, rows, err := tio.getSize(stdinFd)
-			if err != nil {
-				continue
-			}
-			if err := sendResizeFrame(conn, &writeMu, cols, rows); err != nil {
-				return
+	// An observer sends none.
+	if !opts.ReadOnly {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for range sigCh {
+				cols, rows, err := tio.getSize(stdinFd)
+				if err != nil {
+					continue
+				}
+				if err := sendResizeFrame(conn, &writeMu, cols, rows); err != nil {
+					return
+				}
 			}
-		}
-	}()
+		}()
+	}
 
 	// stdin → data frames → conn. Each chunk is scanned for the detach byte
 	// (Ctrl-\): bytes before it are forwarded, then the goroutine returns so
 	// AttachAgent unwinds and the deferred term.Restore leaves the terminal
 	// sane. The detach byte itself is consumed, never forwarded to the agent.
+	// So is the takeover byte, which becomes a takeover frame where it fell.
+	// An observer forwards nothing but still watches for the detach byte.
 	go func() {
 		defer func() { done <- struct{}{} }()
 		buf := make([]byte, 4096)
@@ -131,8 +170,8 @@ func attachAgent(socketPath string, tio attachIO) error {
 			n, err := tio.in.Read(buf)
 			if n > 0 {
 				forward, detach := splitDetach(buf[:n])
-				if len(forward) > 0 {
-					if werr := sendDataFrame(conn, &writeMu, forward); werr != nil {
+				if !opts.ReadOnly {
+					if werr := sendInput(conn, &writeMu, forward); werr != nil {
 						return
 					}
 				}
@@ -152,13 +191,16 @@ func attachAgent(socketPath string, tio attachIO) error {
 	// modes the agent's TUI turns on (alt screen, mouse, focus reporting, …) so
 	// the detach below can turn them back off. Nothing is filtered on the way
 	// through: the agent's output must reach the terminal byte-for-byte or its
-	// TUI renders wrong.
+	// TUI renders wrong. The one exception is the server's presence updates,
+	// which are ours, not the agent's (presenceFilter).
 	tstate := newTermState()
+	title := newPresenceTitle(tio.out)
+	defer title.restore()
 	outDone := make(chan struct{})
 	go func() {
 		defer close(outDone)
 		defer func() { done <- struct{}{} }()
-		io.Copy(io.MultiWriter(tio.out, tstate), conn)
+		io.Copy(&presenceFilter{out: io.MultiWriter(tio.out, tstate), onPresence: title.show}, conn)
 	}()
 
 	<-done
@@ -192,6 +234,70 @@ func splitDetach(chunk []byte) (forward []byte, detach bool) {
 	return chunk, false
 }
 
+// sendInput forwards a chunk of keystrokes as data frames, with a takeover
+// frame in place of each takeover byte.
+func sendInput(conn net.Conn, mu *sync.Mutex, chunk []byte) error {
+	for len(chunk) > 0 {
+		i := bytes.IndexByte(chunk, takeoverByte)
+		if i < 0 {
+			return sendDataFrame(conn, mu, chunk)
+		}
+		if err := sendDataFrame(conn, mu, chunk[:i]); err != nil {
+			return err
+		}
+		mu.Lock()
+		_, err := conn.Write([]byte{agent.FrameTypeTakeover})
+		mu.Unlock()
+		if err != nil {
+			return err
+		}
+		chunk = chunk[i+1:]
+	}
+	return nil
+}
+
+// helloFor returns the hello opts asks for.
+func helloFor(opts AttachOptions) agent.AttachHello {
+	h := agent.AttachHello{Role: agent.AttachReadWrite, Who: opts.Who, Takeover: opts.Takeover}
+	if opts.ReadOnly {
+		h.Role = agent.AttachReadOnly
+		h.Takeover = false
+	}
+	if h.Who == "" {
+		h.Who = defaultWho()
+	}
+	return h
+}
+
+// defaultWho is $USER@host, or as much of it as can be found.
+func defaultWho() string {
+	user := os.Getenv("USER")
+	if user == "" {
+		user = "?"
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return user
+	}
+	return user + "@" + host
+}
+
+// sendHelloFrame writes a hello frame (FrameTypeHello + len u16 LE + JSON).
+func sendHelloFrame(conn net.Conn, mu *sync.Mutex, h agent.AttachHello) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	frame := make([]byte, 3, 3+len(data))
+	frame[0] = agent.FrameTypeHello
+	binary.LittleEndian.PutUint16(frame[1:3], uint16(len(data)))
+	frame = append(frame, data...)
+	mu.Lock()
+	defer mu.Unlock()
+	_, err = conn.Write(frame)
+	return err
+}
+
 // sendHandshakeFrame writes the mandatory connect-time resize frame that puts
 // the server into framed mode. Unlike sendResizeFrame it always writes a
 // frame: out-of-range or unknown dimensions are clamped to 0, which the
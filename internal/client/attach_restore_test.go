@@ -75,6 +75,14 @@ func newAttachHarness(t *testing.T, server func(conn net.Conn)) *attachHarness {
 		if _, err := io.ReadFull(conn, hs); err != nil {
 			return
 		}
+		// The hello frame follows the handshake (user-016).
+		hello := make([]byte, 3)
+		if _, err := io.ReadFull(conn, hello); err != nil || hello[0] != agent.FrameTypeHello {
+			return
+		}
+		if _, err := io.ReadFull(conn, make([]byte, binary.LittleEndian.Uint16(hello[1:3]))); err != nil {
+			return
+		}
 		handshake <- hs
 		server(conn)
 	}()
@@ -106,7 +114,7 @@ func newAttachHarness(t *testing.T, server func(conn net.Conn)) *attachHarness {
 		getSize: func(int) (int, int, error) { return 0, 0, os.ErrInvalid },
 	}
 
-	go func() { h.errCh <- attachAgent(sock, tio) }()
+	go func() { h.errCh <- attachAgent(sock, tio, AttachOptions{}) }()
 
 	select {
 	case hs := <-handshake:
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/drellem2/pogo/internal/agent"
+)
+
+// maxPresence bounds how much of an unterminated presence update
+// presenceFilter holds back. A real update is a few hundred bytes; anything
+// that runs on this long was never one, and is passed through as output.
+const maxPresence = 64 << 10
+
+// presenceFilter passes an attach connection's output through to out with the
+// server's presence updates (agent.PresencePrefix … agent.PresenceSuffix)
+// taken out and handed to onPresence. An update split across reads is held
+// back until its end arrives, and so is a trailing fragment that could be the
+// start of one; everything else is written through as it comes.
+type presenceFilter struct {
+	out        io.Writer
+	onPresence func(agent.AttachPresence)
+	pending    []byte
+}
+
+func (f *presenceFilter) Write(p []byte) (int, error) {
+	data := append(f.pending, p...)
+	f.pending = nil
+	for len(data) > 0 {
+		i := bytes.Index(data, []byte(agent.PresencePrefix))
+		if i < 0 {
+			keep := partialPrefix(data)
+			if _, err := f.out.Write(data[:len(data)-keep]); err != nil {
+				return 0, err
+			}
+			f.pending = append([]byte(nil), data[len(data)-keep:]...)
+			break
+		}
+		if _, err := f.out.Write(data[:i]); err != nil {
+			return 0, err
+		}
+		body := data[i+len(agent.PresencePrefix):]
+		j := bytes.Index(body, []byte(agent.PresenceSuffix))
+		if j < 0 {
+			if len(data)-i > maxPresence {
+				if _, err := f.out.Write(data[i:]); err != nil {
+					return 0, err
+				}
+				break
+			}
+			f.pending = append([]byte(nil), data[i:]...)
+			break
+		}
+		var pr agent.AttachPresence
+		if json.Unmarshal(body[:j], &pr) == nil && f.onPresence != nil {
+			f.onPresence(pr)
+		}
+		data = body[j+len(agent.PresenceSuffix):]
+	}
+	return len(p), nil
+}
+
+// partialPrefix returns the length of the longest tail of data that is a
+// proper prefix of agent.PresencePrefix.
+func partialPrefix(data []byte) int {
+	n := len(agent.PresencePrefix) - 1
+	if n > len(data) {
+		n = len(data)
+	}
+	for ; n > 0; n-- {
+		if bytes.HasPrefix([]byte(agent.PresencePrefix), data[len(data)-n:]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// presenceTitle shows the latest presence update in the terminal's title,
+// the one place a line can be put on screen without drawing over the agent's
+// TUI. The title in force before the attach is pushed onto the terminal's
+// title stack on the first update and popped back on restore; a terminal
+// without the stack ignores both.
+type presenceTitle struct {
+	mu     sync.Mutex
+	out    io.Writer
+	pushed bool
+}
+
+func newPresenceTitle(out io.Writer) *presenceTitle {
+	return &presenceTitle{out: out}
+}
+
+func (t *presenceTitle) show(p agent.AttachPresence) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.pushed {
+		io.WriteString(t.out, "\x1b[22;0t")
+		t.pushed = true
+	}
+	fmt.Fprintf(t.out, "\x1b]2;%s\x07", PresenceLine(p))
+}
+
+func (t *presenceTitle) restore() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pushed {
+		io.WriteString(t.out, "\x1b[23;0t")
+		t.pushed = false
+	}
+}
+
+// PresenceLine renders who is attached to an agent on one line: each client
+// by name and role, the writer marked, and the receiving client as "you" when
+// p.You names it.
+func PresenceLine(p agent.AttachPresence) string {
+	var parts []string
+	for _, c := range p.Clients {
+		who := c.Who
+		if who == "" {
+			who = "anonymous"
+		}
+		if c.ID == p.You {
+			who += " (you)"
+		}
+		role := c.Role
+		if c.Writer || (p.Writer != 0 && c.ID == p.Writer) {
+			role = "writer"
+		}
+		parts = append(parts, who+" "+role)
+	}
+	return fmt.Sprintf("%s: %d attached — %s", p.Agent, len(p.Clients), strings.Join(parts, ", "))
+}
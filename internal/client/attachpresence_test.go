@@ -0,0 +1,43 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/drellem2/pogo/internal/agent"
+)
+
+// Presence updates are stripped from the agent's output however the reads
+// split them, and everything around them passes through byte-for-byte.
+func TestPresenceFilterStripsUpdatesAcrossReads(t *testing.T) {
+	p := agent.AttachPresence{Agent: "crew-1", You: 2, Writer: 1, Clients: []agent.AttachedClient{
+		{ID: 1, Who: "alice@box", Role: agent.AttachReadWrite, Via: "socket", Writer: true},
+		{ID: 2, Who: "bob@box", Role: agent.AttachReadOnly, Via: "socket"},
+	}}
+	data, _ := json.Marshal(p)
+	apc := agent.PresencePrefix + string(data) + agent.PresenceSuffix
+	stream := "\x1b[2Jbefore " + apc + "\x1b[1mafter\x1b[0m" + apc + "\x1b"
+
+	for size := 1; size <= len(stream); size++ {
+		var out bytes.Buffer
+		var got []agent.AttachPresence
+		f := &presenceFilter{out: &out, onPresence: func(p agent.AttachPresence) { got = append(got, p) }}
+		for i := 0; i < len(stream); i += size {
+			f.Write([]byte(stream[i:min(i+size, len(stream))]))
+		}
+		// The trailing ESC could begin another update, so it is held back.
+		if want := "\x1b[2Jbefore \x1b[1mafter\x1b[0m"; out.String() != want {
+			t.Fatalf("reads of %d: output = %q, want %q", size, out.String(), want)
+		}
+		if len(got) != 2 || got[1].Writer != 1 || len(got[1].Clients) != 2 {
+			t.Fatalf("reads of %d: presence = %+v", size, got)
+		}
+	}
+
+	line := PresenceLine(p)
+	if !strings.Contains(line, "alice@box writer") || !strings.Contains(line, "bob@box (you) ro") {
+		t.Errorf("PresenceLine = %q", line)
+	}
+}
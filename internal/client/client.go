@@ -14,6 +14,7 @@ import (
 	"net/http"
 	"net/url"
 	"os/exec"
+	"sort"
 	"strings"
 	"sync"
 	"syscall"
@@ -45,6 +46,21 @@ type ClientResp interface {
 type PogoChunkMatch struct {
 	Line    uint32 `json:"line"`
 	Content string `json:"content"`
+	// MatchLines lists which lines of a multi-line chunk (one with context
+	// lines) hold a match.
+	MatchLines []uint32 `json:"matchLines,omitempty"`
+	// Kind is "definition" or "reference" when the server knows which the
+	// match is — see SymbolSearch — and empty otherwise.
+	Kind   string      `json:"kind,omitempty"`
+	Symbol *PogoSymbol `json:"symbol,omitempty"`
+}
+
+// PogoSymbol is the ctags tag behind a definition match.
+type PogoSymbol struct {
+	Name       string `json:"name"`
+	Kind       string `json:"kind,omitempty"`
+	Parent     string `json:"parent,omitempty"`
+	ParentKind string `json:"parentKind,omitempty"`
 }
 
 type PogoFileMatch struct {
@@ -53,7 +69,9 @@ type PogoFileMatch struct {
 }
 
 type SearchResults struct {
-	Files []PogoFileMatch `json:"files"`
+	Files      []PogoFileMatch `json:"files"`
+	Truncated  bool            `json:"truncated,omitempty"`
+	NextCursor string          `json:"nextCursor,omitempty"`
 }
 
 type IndexedProject struct {
@@ -69,23 +87,72 @@ type SearchResponse struct {
 }
 
 type SearchRequest struct {
-	// Values: "search" or "files"
+	// Values: "search", "search_all", "symbol" or "files"
 	Type        string `json:"type"`
 	ProjectRoot string `json:"projectRoot"`
 	// Command timeout duration - only for 'search'-type requests
 	Duration string `json:"string"`
 	Data     string `json:"data"`
+	QueryOptions
+}
+
+// QueryOptions narrow and page a search; see the search plugin's type of the
+// same name. A 'symbol' request only honours Limit. A pogod that predates
+// them ignores all but Limit.
+type QueryOptions struct {
+	Globs   []string `json:"globs,omitempty"`
+	Lang    string   `json:"lang,omitempty"`
+	Case    string   `json:"case,omitempty"`
+	Literal bool     `json:"literal,omitempty"`
+	Context int      `json:"context,omitempty"`
+	// Limit caps how many files come back. 0 means no limit.
+	Limit  int    `json:"limit,omitempty"`
+	Cursor string `json:"cursor,omitempty"`
+	// Branch searches an indexed ref instead of the working tree.
+	Branch string `json:"branch,omitempty"`
+}
+
+// SearchPage says where a limited search stopped.
+type SearchPage struct {
+	// Truncated is set when more files matched than were returned.
+	Truncated bool
+	// NextCursor, when set, is the QueryOptions.Cursor for the next page.
+	NextCursor string
+}
+
+type RankedFileMatch struct {
+	Root string `json:"root"`
+	PogoFileMatch
+	Score float64 `json:"score"`
+}
+
+type SearchAllResults struct {
+	Files      []RankedFileMatch `json:"files"`
+	Searched   int               `json:"searched"`
+	Truncated  bool              `json:"truncated"`
+	NextCursor string            `json:"nextCursor,omitempty"`
+	Errors     map[string]string `json:"errors,omitempty"`
+}
+
+// SearchAllResponse is the search plugin's answer to a "search_all" or
+// "symbol" request.
+// ErrorCode is only set when the plugin refused the request — 404 from a
+// pogod that predates it.
+type SearchAllResponse struct {
+	Results   SearchAllResults `json:"results"`
+	Error     string           `json:"error"`
+	ErrorCode int              `json:"errorCode"`
 }
 
 func HealthCheck() error {
-	_, err := http.Post(serverURL+"/health", "application/json",
+	_, err := pogodClient.Post(serverURL+"/health", "application/json",
 		nil)
 	return err
 }
 
 // GetFullHealth fetches the structured /health/full report from pogod.
 func GetFullHealth() (*health.FullResponse, error) {
-	resp, err := http.Get(serverURL + "/health/full")
+	resp, err := pogodClient.Get(serverURL + "/health/full")
 	if err != nil {
 		return nil, err
 	}
@@ -110,7 +177,7 @@ func GetFullHealth() (*health.FullResponse, error) {
 // reading. Collapsing those two is the exact defect the detector exists to
 // remove, so it must not be re-introduced by its own client.
 func GetFleetProgress() (*progresswatch.Reading, error) {
-	resp, err := http.Get(serverURL + "/health/progress")
+	resp, err := pogodClient.Get(serverURL + "/health/progress")
 	if err != nil {
 		return nil, err
 	}
@@ -283,7 +350,7 @@ func (l *lockedWriter) Write(p []byte) (int, error) {
 
 // GetServerMode returns the current run mode of the server ("full" or "index-only").
 func GetServerMode() (string, error) {
-	resp, err := http.Get(serverURL + "/server/mode")
+	resp, err := pogodClient.Get(serverURL + "/server/mode")
 	if err != nil {
 		return "", fmt.Errorf("failed to contact server: %w", err)
 	}
@@ -410,7 +477,7 @@ func RunWithHealthCheck[T ClientResp](run func() (T, error)) (T, error) {
 
 func GetProjects() ([]project.Project, error) {
 	projs, err := RunWithHealthCheck(func() ([]project.Project, error) {
-		r, err := http.Get(serverURL + "/projects")
+		r, err := pogodClient.Get(serverURL + "/projects")
 		if err != nil {
 			return nil, err
 		}
@@ -433,7 +500,7 @@ func GetProjects() ([]project.Project, error) {
 
 func GetPlugins() ([]string, error) {
 	plugins, err := RunWithHealthCheck(func() ([]string, error) {
-		r, err := http.Get(serverURL + "/plugins")
+		r, err := pogodClient.Get(serverURL + "/plugins")
 		if err != nil {
 			return nil, err
 		}
@@ -473,9 +540,19 @@ func GetSearchPlugin() (string, error) {
 // the default keep-alive client, so consecutive calls reuse one TCP
 // connection instead of paying a fresh handshake each time (gh #39).
 func searchProject(searchPluginPath string, searchRequest SearchRequest) (*SearchResponse, error) {
+	var results SearchResponse
+	if err := executeSearchPlugin(searchPluginPath, searchRequest, &results); err != nil {
+		return nil, err
+	}
+	return &results, nil
+}
+
+// executeSearchPlugin sends one request to the search plugin and decodes its
+// answer into out.
+func executeSearchPlugin(searchPluginPath string, searchRequest SearchRequest, out any) error {
 	searchRequestJson, err := json.Marshal(searchRequest)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	dataObj := pogoPlugin.DataObject{
 		Plugin: searchPluginPath,
@@ -483,38 +560,38 @@ func searchProject(searchPluginPath string, searchRequest SearchRequest) (*Searc
 	}
 	dataObjJson, err := json.Marshal(dataObj)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	req, err := http.NewRequest("POST", serverURL+"/plugin",
 		strings.NewReader(string(dataObjJson)))
 	if err != nil {
-		return nil, err
+		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
-	r, err := http.DefaultClient.Do(req)
+	r, err := pogodClient.Do(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer r.Body.Close()
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	var dataObject pogoPlugin.DataObject
 	err = json.Unmarshal(body, &dataObject)
 	if err != nil {
-		return nil, err
-	}
-	var results SearchResponse
-	err = json.Unmarshal([]byte(dataObject.Value), &results)
-	if err != nil {
-		return nil, err
+		return err
 	}
-	return &results, nil
+	return json.Unmarshal([]byte(dataObject.Value), out)
 }
 
 // dir may be inside of a project path. First we have to look up the
 func Search(query string, dir string) (*SearchResponse, error) {
+	return SearchWith(query, dir, QueryOptions{})
+}
+
+// SearchWith searches the project containing dir, narrowed and paged by opts.
+func SearchWith(query string, dir string, opts QueryOptions) (*SearchResponse, error) {
 	// corresponding project root, if any
 	projectResp, err := Visit(dir)
 	if err != nil {
@@ -529,10 +606,11 @@ func Search(query string, dir string) (*SearchResponse, error) {
 		return nil, err
 	}
 	var searchRequest = SearchRequest{
-		Type:        "search",
-		ProjectRoot: projectRoot,
-		Duration:    "10s",
-		Data:        query,
+		Type:         "search",
+		ProjectRoot:  projectRoot,
+		Duration:     "10s",
+		Data:         query,
+		QueryOptions: opts,
 	}
 	results, err := RunWithHealthCheck(func() (*SearchResponse, error) {
 		return searchProject(searchPluginPath, searchRequest)
@@ -545,7 +623,7 @@ func Search(query string, dir string) (*SearchResponse, error) {
 
 func GetStatus() ([]ProjectStatusResponse, error) {
 	statuses, err := RunWithHealthCheck(func() ([]ProjectStatusResponse, error) {
-		r, err := http.Get(serverURL + "/status")
+		r, err := pogodClient.Get(serverURL + "/status")
 		if err != nil {
 			return nil, err
 		}
@@ -577,34 +655,139 @@ func SearchAll(query string) ([]*SearchResponse, error) {
 }
 
 // searchAllConcurrency bounds the parallel per-project fan-out of
-// SearchAllStreaming. Enough to hide per-request latency at fleet scale,
+// searchAllFanOut. Enough to hide per-request latency at fleet scale,
 // small enough not to stampede pogod.
 const searchAllConcurrency = 8
 
-// SearchAllStreaming searches across all known projects, calling onResult for
-// each repo's results as soon as they are available. This allows callers to
-// display results incrementally instead of waiting for every repo to finish.
-//
-// Server liveness is established once by the initial project listing; the
-// per-project requests then fan out in parallel over kept-alive connections
-// with no per-call health probe — previously each project cost two serial
-// round-trips on a fresh connection, the dominant CLI latency at fleet scale
-// (gh #39). onResult calls are serialized, so callers need no locking, but
-// arrival order is not the registry order.
+// SearchAllStreaming searches across all known projects, calling onResult
+// once per repo that matched, with no limit on the results. See
+// SearchAllRanked.
 func SearchAllStreaming(query string, onResult func(*SearchResponse)) error {
+	_, err := SearchAllRanked(query, QueryOptions{}, onResult)
+	return err
+}
+
+// SearchAllRanked searches every known project in one request and calls
+// onResult once per repo that matched, repos in the order of their best file.
+// Files are ranked across all repos, narrowed and paged by opts; the page
+// says whether opts.Limit cut any off and where to continue. onResult calls
+// are serialized, so callers need no locking.
+//
+// pogod searches every project's already-open shard in a single pass and
+// ranks the union, so this is one round trip however many repos are
+// registered. A pogod that predates the "search_all" request is searched the
+// old way, one request per project (searchAllFanOut); the limit does not apply
+// there.
+func SearchAllRanked(query string, opts QueryOptions, onResult func(*SearchResponse)) (SearchPage, error) {
 	projs, err := GetProjects()
 	if err != nil {
-		return fmt.Errorf("failed to list projects: %w", err)
+		return SearchPage{}, fmt.Errorf("failed to list projects: %w", err)
 	}
 	if len(projs) == 0 {
-		return errors.New("no projects registered with pogo")
+		return SearchPage{}, errors.New("no projects registered with pogo")
 	}
 
 	searchPluginPath, err := GetSearchPlugin()
 	if err != nil {
-		return err
+		return SearchPage{}, err
+	}
+
+	var resp SearchAllResponse
+	err = executeSearchPlugin(searchPluginPath, SearchRequest{
+		Type:         "search_all",
+		Duration:     "10s",
+		Data:         query,
+		QueryOptions: opts,
+	}, &resp)
+	if err != nil {
+		return SearchPage{}, err
+	}
+	if resp.ErrorCode == http.StatusNotFound {
+		return SearchPage{}, searchAllFanOut(searchPluginPath, projs, query, opts, onResult)
 	}
+	if resp.ErrorCode != 0 || resp.Error != "" {
+		return SearchPage{}, errors.New(resp.Error)
+	}
+
+	groupByRepo(resp.Results, onResult)
+	return SearchPage{Truncated: resp.Results.Truncated, NextCursor: resp.Results.NextCursor}, nil
+}
 
+// groupByRepo hands ranked results to onResult one repo at a time, without
+// disturbing their order: each repo's block lists its files best first and
+// the repo holding the best file comes first. Repos whose shard could not be
+// searched follow, sorted.
+func groupByRepo(results SearchAllResults, onResult func(*SearchResponse)) {
+	var order []string
+	byRoot := map[string]*SearchResponse{}
+	for _, f := range results.Files {
+		r, ok := byRoot[f.Root]
+		if !ok {
+			r = &SearchResponse{Index: IndexedProject{Root: f.Root}}
+			byRoot[f.Root] = r
+			order = append(order, f.Root)
+		}
+		r.Results.Files = append(r.Results.Files, f.PogoFileMatch)
+	}
+	for _, root := range order {
+		onResult(byRoot[root])
+	}
+	failed := make([]string, 0, len(results.Errors))
+	for root := range results.Errors {
+		failed = append(failed, root)
+	}
+	sort.Strings(failed)
+	for _, root := range failed {
+		onResult(&SearchResponse{Index: IndexedProject{Root: root}, Error: results.Errors[root]})
+	}
+}
+
+// ErrSymbolSearchUnsupported is returned by SymbolSearch when pogod predates
+// the "symbol" request.
+var ErrSymbolSearchUnsupported = errors.New("pogod does not support symbol search")
+
+// SymbolSearch looks name up as a symbol in projectRoot, or in every project
+// when projectRoot is empty, and calls onResult once per repo that matched.
+// Files that define the name come before files that only use it; matches are
+// labelled "definition" or "reference" in repos indexed with symbols. At most
+// limit files are returned (0 means no limit); it reports whether the limit
+// cut any off.
+func SymbolSearch(projectRoot, name string, limit int, onResult func(*SearchResponse)) (bool, error) {
+	searchPluginPath, err := GetSearchPlugin()
+	if err != nil {
+		return false, err
+	}
+	var resp SearchAllResponse
+	err = executeSearchPlugin(searchPluginPath, SearchRequest{
+		Type:         "symbol",
+		ProjectRoot:  projectRoot,
+		Duration:     "10s",
+		Data:         name,
+		QueryOptions: QueryOptions{Limit: limit},
+	}, &resp)
+	if err != nil {
+		return false, err
+	}
+	if resp.ErrorCode == http.StatusNotFound {
+		return false, ErrSymbolSearchUnsupported
+	}
+	if resp.ErrorCode != 0 || resp.Error != "" {
+		return false, errors.New(resp.Error)
+	}
+	groupByRepo(resp.Results, onResult)
+	return resp.Results.Truncated, nil
+}
+
+// searchAllFanOut searches each project with its own request, calling
+// onResult for each repo's results as soon as they are available.
+//
+// Server liveness is established once by the initial project listing; the
+// per-project requests then fan out in parallel over kept-alive connections
+// with no per-call health probe — previously each project cost two serial
+// round-trips on a fresh connection, the dominant CLI latency at fleet scale
+// (gh #39). onResult calls are serialized, so callers need no locking, but
+// arrival order is not the registry order.
+func searchAllFanOut(searchPluginPath string, projs []project.Project, query string, opts QueryOptions, onResult func(*SearchResponse)) error {
 	sem := make(chan struct{}, searchAllConcurrency)
 	var resultMu sync.Mutex
 	var wg sync.WaitGroup
@@ -615,10 +798,11 @@ func SearchAllStreaming(query string, onResult func(*SearchResponse)) error {
 			sem <- struct{}{}
 			defer func() { <-sem }()
 			resp, err := searchProject(searchPluginPath, SearchRequest{
-				Type:        "search",
-				ProjectRoot: proj.Path,
-				Duration:    "10s",
-				Data:        query,
+				Type:         "search",
+				ProjectRoot:  proj.Path,
+				Duration:     "10s",
+				Data:         query,
+				QueryOptions: opts,
 			})
 			resultMu.Lock()
 			defer resultMu.Unlock()
@@ -651,7 +835,7 @@ func RemoveProject(path string) error {
 		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := pogodClient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -668,7 +852,7 @@ func RemoveProject(path string) error {
 
 func Visit(path string) (*project.VisitResponse, error) {
 	visitResp, err := RunWithHealthCheck(func() (*project.VisitResponse, error) {
-		r, err := http.Post(serverURL+"/file",
+		r, err := pogodClient.Post(serverURL+"/file",
 			"application/json",
 			strings.NewReader(
 				fmt.Sprintf(`{"path": "%s"}`, path)))
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/drellem2/pogo/internal/events"
+)
+
+// RecentEvents returns the newest events matching params from pogod's
+// GET /events, oldest first. params are the endpoint's: since, type, agent,
+// where and limit.
+//
+// `pogo events list` reads the log file itself; this is for a caller that
+// wants pogod's answer wherever it is running, as `pogo top` does.
+func RecentEvents(params url.Values) ([]events.Event, error) {
+	u := serverURL + "/events"
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	r, err := pogodClient.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(r.Body)
+		return nil, fmt.Errorf("events: %s: %s", r.Status, strings.TrimSpace(string(b)))
+	}
+	var evs []events.Event
+	if err := json.NewDecoder(r.Body).Decode(&evs); err != nil {
+		return nil, err
+	}
+	return evs, nil
+}
@@ -32,7 +32,7 @@ func postAttributed(url string) (*http.Response, error) {
 	for k, v := range callerAttribution() {
 		req.Header.Set(k, v)
 	}
-	return http.DefaultClient.Do(req)
+	return pogodClient.Do(req)
 }
 
 // callerAttribution describes this process to the daemon.
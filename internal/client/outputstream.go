@@ -0,0 +1,118 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/drellem2/pogo/internal/agent"
+)
+
+// AgentOutputStreamOptions are how StreamAgentOutput follows an agent's
+// output. The zero value streams raw bytes from the next one the agent prints.
+type AgentOutputStreamOptions struct {
+	// Lines asks for logical lines — escapes stripped, repeats dropped —
+	// instead of raw bytes (agent.OutputFormatLines).
+	Lines bool
+	// Resume starts the stream at Offset, an event ID from an earlier stream.
+	Resume bool
+	Offset int64
+	// Bytes, when > 0 and not resuming, starts the stream N bytes back.
+	Bytes int
+}
+
+// maxStreamEvent bounds one SSE data line. An output event is at most the
+// whole ring, JSON-escaped.
+const maxStreamEvent = 8 * agent.OutputRingBytes
+
+// StreamAgentOutput follows an agent's output over GET
+// /agents/{name}/output/stream, calling fn with each event in order. It
+// returns nil after the agent's exit event, ctx's error when ctx ends first,
+// and fn's error if fn returns one.
+func StreamAgentOutput(ctx context.Context, name string, opts AgentOutputStreamOptions, fn func(agent.OutputEvent) error) error {
+	q := url.Values{}
+	if opts.Lines {
+		q.Set("format", agent.OutputFormatLines)
+	}
+	switch {
+	case opts.Resume:
+		q.Set("offset", strconv.FormatInt(opts.Offset, 10))
+	case opts.Bytes > 0:
+		q.Set("bytes", strconv.Itoa(opts.Bytes))
+	}
+	u := serverURL + "/agents/" + name + "/output/stream"
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	r, err := pogodClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if r.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("agent %q not found", name)
+	}
+	if r.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(r.Body)
+		return fmt.Errorf("pogod returned %s for %s: %s", r.Status, u, strings.TrimSpace(string(body)))
+	}
+	err = readOutputEvents(r.Body, fn)
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// readOutputEvents parses an SSE body into OutputEvents for fn. It returns nil
+// after an exit event, and io.ErrUnexpectedEOF if the body ends before one.
+func readOutputEvents(body io.Reader, fn func(agent.OutputEvent) error) error {
+	sc := bufio.NewScanner(body)
+	sc.Buffer(make([]byte, 0, 64<<10), maxStreamEvent)
+	var typ, id, data string
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			if data == "" {
+				typ, id = "", ""
+				continue
+			}
+			var ev agent.OutputEvent
+			if err := json.Unmarshal([]byte(data), &ev); err != nil {
+				return fmt.Errorf("output stream: bad %s event: %w", typ, err)
+			}
+			ev.Type = typ
+			ev.ID, _ = strconv.ParseInt(id, 10, 64)
+			if err := fn(ev); err != nil {
+				return err
+			}
+			if typ == "exit" {
+				return nil
+			}
+			typ, id, data = "", "", ""
+		case strings.HasPrefix(line, ":"):
+			// Comment: a keepalive.
+		case strings.HasPrefix(line, "event: "):
+			typ = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			data += strings.TrimPrefix(line, "data: ")
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
@@ -0,0 +1,34 @@
+package client
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/drellem2/pogo/internal/agent"
+)
+
+func TestReadOutputEventsStopsAtExit(t *testing.T) {
+	body := ": keepalive\n\n" +
+		"event: output\nid: 3\ndata: {\"offset\":0,\"data\":\"hi\\n\"}\n\n" +
+		"event: gap\nid: 90\ndata: {\"from\":3,\"to\":90}\n\n" +
+		"event: exit\nid: 90\ndata: {\"offset\":90}\n\n" +
+		"event: output\nid: 99\ndata: {\"offset\":90,\"data\":\"late\"}\n\n"
+	var got []agent.OutputEvent
+	err := readOutputEvents(strings.NewReader(body), func(ev agent.OutputEvent) error {
+		got = append(got, ev)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("readOutputEvents: %v", err)
+	}
+	if len(got) != 3 || got[0].Data != "hi\n" || got[0].ID != 3 || got[1].Type != "gap" || got[1].To != 90 || got[2].Type != "exit" {
+		t.Errorf("events = %+v", got)
+	}
+
+	// A stream cut before its exit is not a clean end.
+	err = readOutputEvents(strings.NewReader("event: output\nid: 3\ndata: {\"offset\":0,\"data\":\"hi\"}\n\n"), func(agent.OutputEvent) error { return nil })
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("cut stream: err = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
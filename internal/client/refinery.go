@@ -13,7 +13,7 @@ import (
 
 // GetRefineryStatus returns the refinery status summary.
 func GetRefineryStatus() (*refinery.Status, error) {
-	r, err := http.Get(serverURL + "/refinery/status")
+	r, err := pogodClient.Get(serverURL + "/refinery/status")
 	if err != nil {
 		return nil, err
 	}
@@ -27,7 +27,7 @@ func GetRefineryStatus() (*refinery.Status, error) {
 
 // GetRefineryQueue returns all queued merge requests.
 func GetRefineryQueue() ([]refinery.MergeRequest, error) {
-	r, err := http.Get(serverURL + "/refinery/queue")
+	r, err := pogodClient.Get(serverURL + "/refinery/queue")
 	if err != nil {
 		return nil, err
 	}
@@ -58,7 +58,7 @@ func GetRefineryQueue() ([]refinery.MergeRequest, error) {
 // observes should read the oldest retained DoneTime — an observation — rather
 // than infer completeness from the truncation state.
 func GetRefineryHistory() ([]refinery.MergeRequest, error) {
-	r, err := http.Get(serverURL + "/refinery/history")
+	r, err := pogodClient.Get(serverURL + "/refinery/history")
 	if err != nil {
 		return nil, err
 	}
@@ -77,7 +77,7 @@ func GetRefineryHistory() ([]refinery.MergeRequest, error) {
 // — including `pogo refinery show --json` poll loops — can see status=lost
 // and auto-resubmit.
 func GetRefineryMR(id string) (*refinery.MergeRequest, error) {
-	r, err := http.Get(serverURL + "/refinery/mr/" + id)
+	r, err := pogodClient.Get(serverURL + "/refinery/mr/" + id)
 	if err != nil {
 		return nil, err
 	}
@@ -118,7 +118,7 @@ func GetRefineryMR(id string) (*refinery.MergeRequest, error) {
 
 // PruneWorktrees asks the refinery to prune merged branches from worktree clones.
 func PruneWorktrees() ([]refinery.PruneResult, error) {
-	r, err := http.Post(serverURL+"/refinery/prune", "application/json", nil)
+	r, err := pogodClient.Post(serverURL+"/refinery/prune", "application/json", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -143,7 +143,7 @@ func CancelMerge(id string) (*refinery.CancelResponse, error) {
 	if err != nil {
 		return nil, err
 	}
-	r, err := http.Post(serverURL+"/refinery/cancel", "application/json", bytes.NewReader(body))
+	r, err := pogodClient.Post(serverURL+"/refinery/cancel", "application/json", bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
@@ -167,7 +167,7 @@ func SubmitMerge(req refinery.SubmitRequest) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	r, err := http.Post(serverURL+"/refinery/submit", "application/json", bytes.NewReader(body))
+	r, err := pogodClient.Post(serverURL+"/refinery/submit", "application/json", bytes.NewReader(body))
 	if err != nil {
 		return "", err
 	}
@@ -20,7 +20,7 @@ func AddSchedule(req scheduler.AddRequest) (*scheduler.Entry, error) {
 	if err != nil {
 		return nil, err
 	}
-	r, err := http.Post(serverURL+"/scheduler/schedules", "application/json", bytes.NewReader(body))
+	r, err := pogodClient.Post(serverURL+"/scheduler/schedules", "application/json", bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
@@ -42,7 +42,7 @@ func ListSchedules(agent string) ([]scheduler.Entry, error) {
 	if agent != "" {
 		u += "?agent=" + url.QueryEscape(agent)
 	}
-	r, err := http.Get(u)
+	r, err := pogodClient.Get(u)
 	if err != nil {
 		return nil, err
 	}
@@ -70,7 +70,7 @@ func AckSchedule(agent, id, token string) (*scheduler.AckResult, error) {
 		return nil, err
 	}
 	u := serverURL + "/scheduler/schedules/" + url.PathEscape(id) + "/ack"
-	r, err := http.Post(u, "application/json", bytes.NewReader(body))
+	r, err := pogodClient.Post(u, "application/json", bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
@@ -103,7 +103,7 @@ func SchedulerCompletion(agent string, threshold int) (*scheduler.CompletionStat
 	if len(q) > 0 {
 		u += "?" + q.Encode()
 	}
-	r, err := http.Get(u)
+	r, err := pogodClient.Get(u)
 	if err != nil {
 		return nil, err
 	}
@@ -132,7 +132,7 @@ func RemoveSchedule(agent, id string) error {
 	if err != nil {
 		return err
 	}
-	r, err := http.DefaultClient.Do(req)
+	r, err := pogodClient.Do(req)
 	if err != nil {
 		return err
 	}
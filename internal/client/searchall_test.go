@@ -29,8 +29,15 @@ func newFakePogod(t *testing.T, projectCount int, handler http.HandlerFunc) *htt
 	return srv
 }
 
+// answerAsOldPogod answers a "search_all" request the way a pogod that
+// predates it does, which sends the client down the per-project fan-out.
+func answerAsOldPogod(w http.ResponseWriter) {
+	json.NewEncoder(w).Encode(pogoPlugin.DataObject{Value: `{"errorCode":404,"error":"Unknown request type."}`})
+}
+
 // TestSearchAllStreamingParallelKeepAlive verifies the gh #39 SearchAll
-// rework: the per-project fan-out runs in parallel, health is probed a
+// rework, still used against a pogod without "search_all": the per-project
+// fan-out runs in parallel, health is probed a
 // constant number of times regardless of project count, and every project
 // still produces a result.
 func TestSearchAllStreamingParallelKeepAlive(t *testing.T) {
@@ -53,6 +60,21 @@ func TestSearchAllStreamingParallelKeepAlive(t *testing.T) {
 		case "/plugins":
 			json.NewEncoder(w).Encode([]string{"/plugins/pogo-plugin-search"})
 		case "/plugin":
+			var dataObj pogoPlugin.DataObject
+			if err := json.NewDecoder(r.Body).Decode(&dataObj); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			var req SearchRequest
+			if err := json.Unmarshal([]byte(dataObj.Value), &req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if req.Type == "search_all" {
+				answerAsOldPogod(w)
+				return
+			}
+
 			pluginCalls.Add(1)
 			cur := inFlight.Add(1)
 			defer inFlight.Add(-1)
@@ -66,16 +88,6 @@ func TestSearchAllStreamingParallelKeepAlive(t *testing.T) {
 			// demonstrably overlaps.
 			time.Sleep(30 * time.Millisecond)
 
-			var dataObj pogoPlugin.DataObject
-			if err := json.NewDecoder(r.Body).Decode(&dataObj); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
-			var req SearchRequest
-			if err := json.Unmarshal([]byte(dataObj.Value), &req); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
 			resp := SearchResponse{
 				Index: IndexedProject{Root: req.ProjectRoot},
 				Results: SearchResults{Files: []PogoFileMatch{
@@ -127,7 +139,8 @@ func TestSearchAllStreamingParallelKeepAlive(t *testing.T) {
 }
 
 // TestSearchAllStreamingReportsPerProjectErrors verifies a failing project
-// search surfaces as an error result without aborting the other projects.
+// search in the fan-out surfaces as an error result without aborting the
+// other projects.
 func TestSearchAllStreamingReportsPerProjectErrors(t *testing.T) {
 	handler := func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
@@ -145,6 +158,10 @@ func TestSearchAllStreamingReportsPerProjectErrors(t *testing.T) {
 			json.NewDecoder(r.Body).Decode(&dataObj)
 			var req SearchRequest
 			json.Unmarshal([]byte(dataObj.Value), &req)
+			if req.Type == "search_all" {
+				answerAsOldPogod(w)
+				return
+			}
 			if req.ProjectRoot == "/repo/bad/" {
 				// Malformed payload -> client-side unmarshal error for this repo.
 				fmt.Fprint(w, "not json")
@@ -184,3 +201,134 @@ func TestSearchAllStreamingReportsPerProjectErrors(t *testing.T) {
 		t.Error("failing project should surface an error result")
 	}
 }
+
+// TestSearchAllRankedIsOneRequest: against a pogod that answers "search_all",
+// the whole fleet is one plugin request, the query options travel with it,
+// and the ranked files come back grouped by repo in rank order.
+func TestSearchAllRankedIsOneRequest(t *testing.T) {
+	var pluginCalls atomic.Int32
+	var gotReq atomic.Pointer[SearchRequest]
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.WriteHeader(http.StatusOK)
+		case "/projects":
+			json.NewEncoder(w).Encode([]project.Project{{Id: 1, Path: "/repo/a/"}, {Id: 2, Path: "/repo/b/"}, {Id: 3, Path: "/repo/c/"}})
+		case "/plugins":
+			json.NewEncoder(w).Encode([]string{"/plugins/pogo-plugin-search"})
+		case "/plugin":
+			pluginCalls.Add(1)
+			var dataObj pogoPlugin.DataObject
+			json.NewDecoder(r.Body).Decode(&dataObj)
+			var req SearchRequest
+			json.Unmarshal([]byte(dataObj.Value), &req)
+			gotReq.Store(&req)
+			resp := SearchAllResponse{Results: SearchAllResults{
+				Files: []RankedFileMatch{
+					{Root: "/repo/b/", PogoFileMatch: PogoFileMatch{Path: "best.go"}, Score: 9},
+					{Root: "/repo/a/", PogoFileMatch: PogoFileMatch{Path: "next.go"}, Score: 5},
+					{Root: "/repo/b/", PogoFileMatch: PogoFileMatch{Path: "third.go"}, Score: 2},
+				},
+				Searched:   2,
+				Truncated:  true,
+				NextCursor: "3",
+				Errors:     map[string]string{"/repo/c/": "shard unreadable"},
+			}}
+			respJSON, _ := json.Marshal(resp)
+			json.NewEncoder(w).Encode(pogoPlugin.DataObject{Value: string(respJSON)})
+		default:
+			http.Error(w, "unexpected path", http.StatusNotFound)
+		}
+	}
+	newFakePogod(t, 3, handler)
+
+	var got []string
+	page, err := SearchAllRanked("x", QueryOptions{Limit: 3, Globs: []string{"*.go"}}, func(resp *SearchResponse) {
+		line := resp.Index.Root + ":"
+		for _, f := range resp.Results.Files {
+			line += " " + f.Path
+		}
+		if resp.Error != "" {
+			line += " error=" + resp.Error
+		}
+		got = append(got, line)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := pluginCalls.Load(); n != 1 {
+		t.Errorf("%d plugin requests, want 1 for the whole fleet", n)
+	}
+	if req := gotReq.Load(); req.Limit != 3 || len(req.Globs) != 1 {
+		t.Errorf("options %+v reached pogod, want the limit and glob", req.QueryOptions)
+	}
+	want := []string{"/repo/b/: best.go third.go", "/repo/a/: next.go", "/repo/c/: error=shard unreadable"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("results %q, want %q", got, want)
+	}
+	if !page.Truncated || page.NextCursor != "3" {
+		t.Errorf("page %+v, want the truncation and cursor pogod reported", page)
+	}
+}
+
+// TestFindReferencesUsesSymbolSearch: find-references asks pogod for a symbol
+// search, and the definition/reference labels it returns decide the kinds —
+// a Python definition is a definition, not a call. A pogod without symbol
+// search is asked a plain "search_all" instead.
+func TestFindReferencesUsesSymbolSearch(t *testing.T) {
+	var types []string
+	var mu sync.Mutex
+	oldPogod := false
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.WriteHeader(http.StatusOK)
+		case "/projects":
+			json.NewEncoder(w).Encode([]project.Project{{Id: 1, Path: "/repo/a/"}})
+		case "/plugins":
+			json.NewEncoder(w).Encode([]string{"/plugins/pogo-plugin-search"})
+		case "/plugin":
+			var dataObj pogoPlugin.DataObject
+			json.NewDecoder(r.Body).Decode(&dataObj)
+			var req SearchRequest
+			json.Unmarshal([]byte(dataObj.Value), &req)
+			mu.Lock()
+			types = append(types, req.Type)
+			mu.Unlock()
+			if req.Type == "symbol" && oldPogod {
+				answerAsOldPogod(w)
+				return
+			}
+			resp := SearchAllResponse{Results: SearchAllResults{Files: []RankedFileMatch{
+				{Root: "/repo/a/", PogoFileMatch: PogoFileMatch{Path: "lib.py", Matches: []PogoChunkMatch{
+					{Line: 4, Content: "def frobnicate(x):", Kind: "definition"},
+					{Line: 7, Content: "value = frobnicate(1)", Kind: "reference"},
+				}}},
+			}}}
+			respJSON, _ := json.Marshal(resp)
+			json.NewEncoder(w).Encode(pogoPlugin.DataObject{Value: string(respJSON)})
+		default:
+			http.Error(w, "unexpected path", http.StatusNotFound)
+		}
+	}
+	newFakePogod(t, 1, handler)
+
+	res, err := FindReferencesAll("frobnicate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(types) != "[symbol]" {
+		t.Errorf("requests %v, want one symbol search", types)
+	}
+	if res.Total != 2 || res.Refs[0].Refs[0].Kind != "definition" || res.Refs[0].Refs[1].Kind != "call" {
+		t.Errorf("refs %+v, want the definition then a call", res.Refs)
+	}
+
+	types, oldPogod = nil, true
+	if _, err := FindReferencesAll("frobnicate"); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(types) != "[symbol search_all]" {
+		t.Errorf("requests %v against an old pogod, want symbol then search_all", types)
+	}
+}
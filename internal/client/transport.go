@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/drellem2/pogo/internal/agent"
+	"github.com/drellem2/pogo/internal/config"
+)
+
+// pogodClient is the http.Client every request to pogod goes through
+// (user-021). It does two things the bare http.Get this package used to call
+// did not:
+//
+//   - it sends this process's API token — $POGO_API_TOKEN, which pogod sets
+//     in every agent it spawns, or else the human's token from
+//     $POGO_HOME/api-token — so pogod knows who is asking and holds an agent
+//     to its role;
+//   - it reaches pogod over $POGO_HOME/pogod.sock when that socket answers,
+//     and over TCP when it does not.
+//
+// The socket is used only for the configured address. A caller that points
+// serverURL somewhere else, as the tests here do, gets exactly that address.
+var pogodClient = &http.Client{Transport: &pogodTransport{base: socketTransport()}}
+
+type pogodTransport struct {
+	base      http.RoundTripper
+	tokenOnce sync.Once
+	token     string
+}
+
+func (t *pogodTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.tokenOnce.Do(func() { t.token = apiToken() })
+	if t.token != "" && req.Header.Get("Authorization") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// apiToken is the token this process sends: its own, when pogod spawned it,
+// else the human's, else none.
+func apiToken() string {
+	if token := os.Getenv(agent.APITokenEnv); token != "" {
+		return token
+	}
+	data, err := os.ReadFile(config.APITokenPath())
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// socketTransport is the default transport, dialing pogod's unix socket in
+// place of its configured TCP address whenever the socket accepts.
+func socketTransport() http.RoundTripper {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tcpAddr := strings.TrimPrefix(serverURL, "http://")
+	sock := config.APISocketPath()
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if sock != "" && addr == tcpAddr {
+			if c, err := dialer.DialContext(ctx, "unix", sock); err == nil {
+				return c, nil
+			}
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+	return tr
+}
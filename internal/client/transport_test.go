@@ -0,0 +1,77 @@
+package client
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/drellem2/pogo/internal/agent"
+)
+
+// An agent's pogo commands carry the token pogod gave it, and a human's carry
+// the token pogod wrote for them, without any caller passing either.
+func TestRequestsCarryTheAPIToken(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("POGO_HOME", home)
+	if err := os.WriteFile(filepath.Join(home, "api-token"), []byte("pogo1.human\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+	}))
+	defer ts.Close()
+
+	for _, tc := range []struct{ env, want string }{
+		{"pogo1.agent", "Bearer pogo1.agent"},
+		{"", "Bearer pogo1.human"},
+	} {
+		t.Setenv(agent.APITokenEnv, tc.env)
+		c := &http.Client{Transport: &pogodTransport{base: http.DefaultTransport}}
+		resp, err := c.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got != tc.want {
+			t.Errorf("$%s=%q: Authorization %q, want %q", agent.APITokenEnv, tc.env, got, tc.want)
+		}
+	}
+}
+
+// The configured address is reached over $POGO_HOME/pogod.sock when pogod
+// serves one, and any other address is left alone.
+func TestTheConfiguredAddressPrefersTheSocket(t *testing.T) {
+	home, err := os.MkdirTemp("", "pogo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	t.Setenv("POGO_HOME", home)
+	ln, err := net.Listen("unix", filepath.Join(home, "pogod.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("socket"))
+	})}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	old := serverURL
+	serverURL = "http://127.0.0.1:1"
+	defer func() { serverURL = old }()
+	c := &http.Client{Transport: socketTransport()}
+	resp, err := c.Get(serverURL + "/health")
+	if err != nil {
+		t.Fatalf("the configured address did not go to the socket: %v", err)
+	}
+	resp.Body.Close()
+
+	if _, err := c.Get("http://127.0.0.1:2/health"); err == nil {
+		t.Error("another address reached the socket")
+	}
+}
@@ -1,33 +1,54 @@
 package client
 
 import (
+	"errors"
+
 	"github.com/drellem2/pogo/internal/xref"
 )
 
 // adaptSearchAll wraps SearchAllStreaming into the xref.SearchAllFunc signature.
 func adaptSearchAll(query string, onResult func(*xref.SearchResponse)) error {
 	return SearchAllStreaming(query, func(resp *SearchResponse) {
-		// Convert client types to xref types
-		xresp := &xref.SearchResponse{
-			Index: xref.IndexedProject{
-				Root:   resp.Index.Root,
-				Paths:  resp.Index.Paths,
-				Status: resp.Index.Status,
-			},
-			Error: resp.Error,
-		}
-		for _, f := range resp.Results.Files {
-			xf := xref.FileMatch{Path: f.Path}
-			for _, m := range f.Matches {
-				xf.Matches = append(xf.Matches, xref.ChunkMatch{
-					Line:    m.Line,
-					Content: m.Content,
-				})
-			}
-			xresp.Results.Files = append(xresp.Results.Files, xf)
-		}
-		onResult(xresp)
+		onResult(toXrefResponse(resp))
+	})
+}
+
+// adaptSymbolSearch is the xref.SearchAllFunc for find-references: a symbol
+// search across every repo, so matches in repos indexed with symbols arrive
+// labelled definition or reference. Against a pogod without symbol search it
+// falls back to a plain search for the name.
+func adaptSymbolSearch(symbol string, onResult func(*xref.SearchResponse)) error {
+	_, err := SymbolSearch("", symbol, 0, func(resp *SearchResponse) {
+		onResult(toXrefResponse(resp))
 	})
+	if errors.Is(err, ErrSymbolSearchUnsupported) {
+		return adaptSearchAll(symbol, onResult)
+	}
+	return err
+}
+
+// toXrefResponse converts client types to xref types.
+func toXrefResponse(resp *SearchResponse) *xref.SearchResponse {
+	xresp := &xref.SearchResponse{
+		Index: xref.IndexedProject{
+			Root:   resp.Index.Root,
+			Paths:  resp.Index.Paths,
+			Status: resp.Index.Status,
+		},
+		Error: resp.Error,
+	}
+	for _, f := range resp.Results.Files {
+		xf := xref.FileMatch{Path: f.Path}
+		for _, m := range f.Matches {
+			xf.Matches = append(xf.Matches, xref.ChunkMatch{
+				Line:    m.Line,
+				Content: m.Content,
+				Kind:    m.Kind,
+			})
+		}
+		xresp.Results.Files = append(xresp.Results.Files, xf)
+	}
+	return xresp
 }
 
 // adaptGetProjects wraps GetProjects into the xref.GetProjectsFunc signature.
@@ -44,14 +65,15 @@ func adaptGetProjects() ([]xref.ProjectInfo, error) {
 }
 
 // FindReferences searches for a symbol across all indexed repos and returns
-// classified references (definition, import, call). Results stream per-repo.
+// classified references (definition, import, call). Results stream per-repo,
+// the repos with definitions first.
 func FindReferences(symbol string, onRepo func(*xref.RepoRefs)) error {
-	return xref.FindReferences(adaptSearchAll, symbol, onRepo)
+	return xref.FindReferences(adaptSymbolSearch, symbol, onRepo)
 }
 
 // FindReferencesAll collects all cross-repo references for a symbol.
 func FindReferencesAll(symbol string) (*xref.RefsResult, error) {
-	return xref.FindReferencesAll(adaptSearchAll, symbol)
+	return xref.FindReferencesAll(adaptSymbolSearch, symbol)
 }
 
 // BuildDepGraph constructs a dependency graph across all indexed repos
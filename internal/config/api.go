@@ -0,0 +1,69 @@
+package config
+
+import "path/filepath"
+
+// APIConfig is how pogod guards its API (user-021, internal/apiauth): the
+// [server] keys `socket` and `require_token`, and the [server.policy] table.
+//
+//	[server]
+//	socket = true          # also serve on $POGO_HOME/pogod.sock, mode 0600
+//	require_token = false  # refuse tokenless requests over TCP
+//
+//	[server.policy]
+//	polecat = ["GET /", "POST /refinery/submit", "/agents/{self}"]
+//
+// require_token ships off so the editor plugins and scripts that talk to the
+// TCP port keep working. Off does not mean open: a tokenless TCP request is
+// held to the anonymous role — read, visit and search — so an agent that drops
+// its token gets less than its own role. On refuses such requests outright.
+type APIConfig struct {
+	// Socket serves the API on APISocketPath as well as on TCP.
+	Socket bool
+	// RequireToken refuses a request over TCP that carries no token, rather
+	// than judging it as the anonymous role.
+	RequireToken bool
+	// Policy replaces the shipped rules for each role it names
+	// (apiauth.DefaultRules); the others keep theirs.
+	Policy map[string][]string
+}
+
+// DefaultAPIConfig returns the shipped API settings: socket on, tokenless TCP
+// held to the anonymous role, the shipped policy.
+func DefaultAPIConfig() APIConfig {
+	return APIConfig{Socket: true}
+}
+
+// parseAPIPolicyKey records one [server.policy] line.
+func parseAPIPolicyKey(cfg *parsedConfig, key, val string) {
+	if cfg.API.Policy == nil {
+		cfg.API.Policy = map[string][]string{}
+	}
+	cfg.API.Policy[key] = parseStringArray(val)
+}
+
+// APISocketPath is where pogod serves its API on a unix socket:
+// $POGO_HOME/pogod.sock. It returns "" when that path is too long to bind —
+// a deep POGO_HOME, as tests use — in which case pogod serves TCP only.
+func APISocketPath() string {
+	p := filepath.Join(PogoHome(), "pogod.sock")
+	if len(p) > maxUnixSocketPathLen {
+		return ""
+	}
+	return p
+}
+
+// APIKeyPath is the key pogod signs API tokens with.
+func APIKeyPath() string {
+	return filepath.Join(PogoHome(), "api.key")
+}
+
+// APITokenPath is where pogod writes the human's API token for the CLI.
+func APITokenPath() string {
+	return filepath.Join(PogoHome(), "api-token")
+}
+
+// APISecretPaths are the files that make whoever reads them the human to
+// pogod: the signing key and the human's token. Sandbox profiles hide them.
+func APISecretPaths() []string {
+	return []string{APIKeyPath(), APITokenPath()}
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAPIShipsWithTheSocketAndOptionalTokens(t *testing.T) {
+	cfg := loadWithConfigDir(t, t.TempDir())
+	if !cfg.API.Socket || cfg.API.RequireToken || cfg.API.Policy != nil {
+		t.Errorf("API = %+v, want the socket on, tokens optional, the shipped policy", cfg.API)
+	}
+}
+
+func TestAPIKeysParse(t *testing.T) {
+	dir := t.TempDir()
+	writeCapConfig(t, dir, `[server]
+port = 10123
+socket = false
+require_token = true
+
+[server.policy]
+polecat = ["GET /", "POST /refinery/submit"]
+crew = ["/", "!/server/mode"]
+`)
+	cfg := loadWithConfigDir(t, dir)
+	if cfg.Port != 10123 || cfg.API.Socket || !cfg.API.RequireToken {
+		t.Errorf("port %d, API = %+v", cfg.Port, cfg.API)
+	}
+	want := map[string][]string{
+		"polecat": {"GET /", "POST /refinery/submit"},
+		"crew":    {"/", "!/server/mode"},
+	}
+	if !reflect.DeepEqual(cfg.API.Policy, want) {
+		t.Errorf("Policy = %v, want %v", cfg.API.Policy, want)
+	}
+}
@@ -786,6 +786,18 @@ type Config struct {
 	// zero-config behavior: any visited git repo may be auto-registered,
 	// bounded by MaxFilesPerTree and the default-exclude patterns.
 	IndexRoots []string
+	// IndexBranches are globs over ref short names (`[search] branches`,
+	// e.g. "origin/main", "polecat-*") whose branches are indexed beside
+	// each repo's working tree, for `pose --branch`. Empty means the
+	// working tree only.
+	IndexBranches []string
+	// SymbolIndexing turns ctags symbol extraction on for search shards
+	// (`[search] symbols`, default true). It only takes effect where a
+	// universal-ctags binary is found; see CtagsPath.
+	SymbolIndexing bool
+	// CtagsPath names the universal-ctags binary (`[search] ctags`). Empty
+	// means look for `universal-ctags`, then `ctags`, on PATH.
+	CtagsPath  string
 	Refinery   RefineryConfig
 	Agents     AgentsConfig
 	Heartbeat  HeartbeatConfig
@@ -833,6 +845,20 @@ type Config struct {
 	// successor inside a window. Zero value = no repos = inert. This is a
 	// DETECTOR and never refuses anything — see auditsuccessor.go.
 	AuditSuccessor AuditSuccessorConfig
+	// Recording keeps every agent's PTY stream on disk as asciicast v2
+	// (user-015). Off unless enabled; see recording.go.
+	Recording RecordingConfig
+	// API is how pogod guards its API: the unix socket, whether TCP needs a
+	// token, and the per-role policy (user-021). See api.go.
+	API APIConfig
+	// Tracing is where the spans recorded for each work item go: a local
+	// JSONL file and, optionally, an OTLP collector (user-023). See
+	// tracing.go.
+	Tracing TracingConfig
+	// Providers are the harnesses declared by [providers.<id>] tables, keyed
+	// by id (user-018). internal/providers builds and validates them; see
+	// providers.go.
+	Providers map[string]ProviderConfig
 	// Source is the path of the highest-precedence config file Load read, or
 	// "" when no config file was found and everything is defaults + env. pogod
 	// uses this to gate crew auto-start: a daemon with no config file is
@@ -1707,6 +1733,21 @@ type AgentsConfig struct {
 	// gh #25). Set via [agents] extra_path or POGO_EXTRA_PATH
 	// (list-separator-joined, i.e. colon-separated on unix).
 	ExtraPath []string
+	// PTYHolder starts every agent under a per-agent holder process that owns
+	// its PTY and outlives pogod, so a pogod restart adopts running agents
+	// instead of hanging them up ([agents] pty_holder; user-011). Off by
+	// default: pogod then owns every PTY master, as it always has.
+	PTYHolder bool
+	// Sandbox is the profile agents are confined to ([agents] sandbox,
+	// sandbox_read_only, sandbox_writable; user-012). A prompt's sandbox
+	// frontmatter and [agents.<type>] sandbox pick the mode per agent; the
+	// paths are shared. The zero value is no sandbox.
+	Sandbox SandboxConfig
+	// Mode is how agents are driven ([agents] mode; user-019): "tui", the
+	// harness's interactive terminal UI, or "stream", its structured event
+	// stream. [agents.<type>] mode and a spawn's own request override it. Empty
+	// means tui.
+	Mode string
 	// Crew overrides the command template for crew agents.
 	Crew AgentTypeConfig
 	// Polecat overrides the command template for polecat agents.
@@ -1723,6 +1764,19 @@ type AgentTypeConfig struct {
 	// is what lets a mixed fleet run — e.g. [agents.polecat] provider = "pi"
 	// while crew agents stay on Claude. See mg-b31b.
 	Provider string
+	// Sandbox overrides the [agents] sandbox mode for this agent type.
+	Sandbox string
+	// Mode overrides the [agents] mode for this agent type.
+	Mode string
+}
+
+// SandboxConfig is a sandbox profile as configured: a mode ("none",
+// "landlock", "namespaces") and the trees it adds to what the confined process
+// may read or write. Paths may start with ~. See internal/platform/sandbox.
+type SandboxConfig struct {
+	Mode     string
+	ReadOnly []string
+	Writable []string
 }
 
 // AgentCommand returns the explicitly-configured command template for a given
@@ -1807,6 +1861,39 @@ func (c *AgentsConfig) AgentProvider(agentType string) string {
 	return c.Provider
 }
 
+// AgentSandbox returns the sandbox profile configured for an agent type: the
+// [agents] profile, with [agents.<type>] sandbox overriding its mode.
+func (c *AgentsConfig) AgentSandbox(agentType string) SandboxConfig {
+	sb := c.Sandbox
+	switch agentType {
+	case "crew":
+		if c.Crew.Sandbox != "" {
+			sb.Mode = c.Crew.Sandbox
+		}
+	case "polecat":
+		if c.Polecat.Sandbox != "" {
+			sb.Mode = c.Polecat.Sandbox
+		}
+	}
+	return sb
+}
+
+// AgentMode returns the execution mode configured for an agent type:
+// [agents.<type>] mode, else [agents] mode. Empty means the interactive TUI.
+func (c *AgentsConfig) AgentMode(agentType string) string {
+	switch agentType {
+	case "crew":
+		if c.Crew.Mode != "" {
+			return c.Crew.Mode
+		}
+	case "polecat":
+		if c.Polecat.Mode != "" {
+			return c.Polecat.Mode
+		}
+	}
+	return c.Mode
+}
+
 // RefineryConfig holds merge queue configuration.
 type RefineryConfig struct {
 	Enabled      bool
@@ -1817,6 +1904,36 @@ type RefineryConfig struct {
 	// repos may merge at the same time. Zero means the refinery's own default.
 	// One restores the historic single-slot behaviour.
 	MaxConcurrentMerges int
+	// GateRunner is the unix socket of a `pogo gate-runner` that quality gates
+	// are handed to instead of running as children of pogod. Empty (the
+	// default) runs them in pogod.
+	GateRunner string
+	// Sandbox is the profile quality gates run under when they run in pogod
+	// ([refinery] sandbox, sandbox_read_only, sandbox_writable; user-012). A
+	// gate runner does its own isolating and is not affected.
+	Sandbox SandboxConfig
+	// Webhooks is [refinery.webhooks]: outbound HTTP notifications of merge
+	// request transitions.
+	Webhooks RefineryWebhooksConfig
+}
+
+// RefineryWebhooksConfig is [refinery.webhooks]. No URLs disables webhooks.
+type RefineryWebhooksConfig struct {
+	// URLs each receive a signed POST per selected transition.
+	URLs []string
+	// Secret is the HMAC-SHA256 signing key. SecretFile names a file holding
+	// it instead, so the key need not sit in config.toml; Secret wins when
+	// both are set.
+	Secret     string
+	SecretFile string
+	// Events narrows which transitions are sent (queued, processing, merged,
+	// failed, cancelled, lost). Empty means all of them.
+	Events []string
+	// MaxAttempts bounds POSTs per delivery before it is dead-lettered. Zero
+	// means the refinery's default.
+	MaxAttempts int
+	// Timeout bounds one POST. Zero means the refinery's default.
+	Timeout time.Duration
 }
 
 // parsedConfig is the intermediate result of reading the config layers.
@@ -1830,6 +1947,7 @@ type RefineryConfig struct {
 type parsedConfig struct {
 	Config
 	refineryEnabledSet     bool
+	apiSocketSet           bool
 	gitgcEnabledSet        bool
 	stallWatchEnabledSet   bool
 	priorityWakeEnabledSet bool
@@ -1843,6 +1961,7 @@ type parsedConfig struct {
 	// silenced the digest still receiving it.
 	indefiniteHoldEnabledSet bool
 	agentsAutoStartSet       bool
+	symbolIndexingSet        bool
 	reaperEnabledSet         bool
 	driftWatchEnabledSet     bool
 	credExpiryEnabledSet     bool
@@ -1866,6 +1985,8 @@ type parsedConfig struct {
 	// daemon that still refuses. Same shape as blockedReminderEnabledSet.
 	dispatchCapMaxSet     bool
 	dispatchCapReserveSet bool
+	recordingEnabledSet   bool
+	tracingEnabledSet     bool
 	// sources are the files that were read, lowest precedence first.
 	sources []string
 }
@@ -1886,6 +2007,7 @@ func Load() *Config {
 		Bind:            DefaultBind,
 		MaxFilesPerTree: DefaultMaxFilesPerTree,
 		IndexInterval:   DefaultIndexInterval,
+		SymbolIndexing:  true,
 		Agents: AgentsConfig{
 			AutoStart: true,
 		},
@@ -1903,6 +2025,9 @@ func Load() *Config {
 		// Ships ARMED (mg-3977). See dispatchcap.go for why a per-repo bound is
 		// platform behaviour rather than one deployment's policy.
 		DispatchCap: DefaultDispatchCapConfig(),
+		Recording:   DefaultRecordingConfig(),
+		API:         DefaultAPIConfig(),
+		Tracing:     DefaultTracingConfig(),
 		Reaper: ReaperConfig{
 			Enabled:       true,
 			Interval:      DefaultReaperInterval,
@@ -2051,6 +2176,15 @@ func Load() *Config {
 		if len(fileCfg.IndexRoots) > 0 {
 			cfg.IndexRoots = fileCfg.IndexRoots
 		}
+		if fileCfg.symbolIndexingSet {
+			cfg.SymbolIndexing = fileCfg.SymbolIndexing
+		}
+		if fileCfg.CtagsPath != "" {
+			cfg.CtagsPath = fileCfg.CtagsPath
+		}
+		if len(fileCfg.IndexBranches) > 0 {
+			cfg.IndexBranches = fileCfg.IndexBranches
+		}
 		cfg.Agents = fileCfg.Agents
 		if !fileCfg.agentsAutoStartSet {
 			// The wholesale Agents copy above clobbers the default; restore
@@ -2066,6 +2200,36 @@ func Load() *Config {
 		if fileCfg.Refinery.MaxConcurrentMerges > 0 {
 			cfg.Refinery.MaxConcurrentMerges = fileCfg.Refinery.MaxConcurrentMerges
 		}
+		if fileCfg.Refinery.GateRunner != "" {
+			cfg.Refinery.GateRunner = fileCfg.Refinery.GateRunner
+		}
+		if fileCfg.Refinery.Sandbox.Mode != "" {
+			cfg.Refinery.Sandbox.Mode = fileCfg.Refinery.Sandbox.Mode
+		}
+		if fileCfg.Refinery.Sandbox.ReadOnly != nil {
+			cfg.Refinery.Sandbox.ReadOnly = fileCfg.Refinery.Sandbox.ReadOnly
+		}
+		if fileCfg.Refinery.Sandbox.Writable != nil {
+			cfg.Refinery.Sandbox.Writable = fileCfg.Refinery.Sandbox.Writable
+		}
+		if fileCfg.Refinery.Webhooks.URLs != nil {
+			cfg.Refinery.Webhooks.URLs = fileCfg.Refinery.Webhooks.URLs
+		}
+		if fileCfg.Refinery.Webhooks.Secret != "" {
+			cfg.Refinery.Webhooks.Secret = fileCfg.Refinery.Webhooks.Secret
+		}
+		if fileCfg.Refinery.Webhooks.SecretFile != "" {
+			cfg.Refinery.Webhooks.SecretFile = fileCfg.Refinery.Webhooks.SecretFile
+		}
+		if fileCfg.Refinery.Webhooks.Events != nil {
+			cfg.Refinery.Webhooks.Events = fileCfg.Refinery.Webhooks.Events
+		}
+		if fileCfg.Refinery.Webhooks.MaxAttempts > 0 {
+			cfg.Refinery.Webhooks.MaxAttempts = fileCfg.Refinery.Webhooks.MaxAttempts
+		}
+		if fileCfg.Refinery.Webhooks.Timeout > 0 {
+			cfg.Refinery.Webhooks.Timeout = fileCfg.Refinery.Webhooks.Timeout
+		}
 		if fileCfg.Heartbeat.Interval > 0 {
 			cfg.Heartbeat.Interval = fileCfg.Heartbeat.Interval
 		}
@@ -2424,6 +2588,9 @@ func Load() *Config {
 		if fileCfg.dispatchCapReserveSet {
 			cfg.DispatchCap.RefineryReserve = fileCfg.DispatchCap.RefineryReserve
 		}
+		if fileCfg.DispatchCap.CgroupRoot != "" {
+			cfg.DispatchCap.CgroupRoot = fileCfg.DispatchCap.CgroupRoot
+		}
 
 		// [audit_successor] has no code-side defaults to preserve either — the
 		// zero value is "no repos, detector inert". Window is the one exception:
@@ -2441,6 +2608,45 @@ func Load() *Config {
 		if fileCfg.AuditSuccessor.Window > 0 {
 			cfg.AuditSuccessor.Window = fileCfg.AuditSuccessor.Window
 		}
+
+		// [recording] ships off, so only an explicit key turns it on or back
+		// off; the limits keep their defaults unless a layer sets them.
+		if fileCfg.recordingEnabledSet {
+			cfg.Recording.Enabled = fileCfg.Recording.Enabled
+		}
+		if fileCfg.Recording.RotateBytes > 0 {
+			cfg.Recording.RotateBytes = fileCfg.Recording.RotateBytes
+		}
+		if fileCfg.Recording.MaxBytes > 0 {
+			cfg.Recording.MaxBytes = fileCfg.Recording.MaxBytes
+		}
+		if fileCfg.Recording.MaxAge > 0 {
+			cfg.Recording.MaxAge = fileCfg.Recording.MaxAge
+		}
+		if fileCfg.Recording.Interval > 0 {
+			cfg.Recording.Interval = fileCfg.Recording.Interval
+		}
+
+		// [providers.<id>] tables were already merged key by key as the
+		// layers were parsed; see parseProviderKey.
+		cfg.Providers = fileCfg.Providers
+
+		if fileCfg.apiSocketSet {
+			cfg.API.Socket = fileCfg.API.Socket
+		}
+		cfg.API.RequireToken = fileCfg.API.RequireToken
+		cfg.API.Policy = fileCfg.API.Policy
+
+		// [tracing] ships on, so only an explicit key turns it off.
+		if fileCfg.tracingEnabledSet {
+			cfg.Tracing.Enabled = fileCfg.Tracing.Enabled
+		}
+		if fileCfg.Tracing.OTLPEndpoint != "" {
+			cfg.Tracing.OTLPEndpoint = fileCfg.Tracing.OTLPEndpoint
+		}
+		if fileCfg.Tracing.OTLPHeaders != nil {
+			cfg.Tracing.OTLPHeaders = fileCfg.Tracing.OTLPHeaders
+		}
 	}
 
 	// Environment variables override config file
@@ -2870,6 +3076,13 @@ func parseConfigFileInto(cfg *parsedConfig, path string) error {
 		// Strip surrounding quotes from values
 		unquotedVal := unquote(val)
 
+		// [providers.<id>] tables are named by the operator, so no fixed
+		// case can match them (user-018).
+		if rest, ok := strings.CutPrefix(currentSection, "providers."); ok {
+			parseProviderKey(cfg, rest, key, val)
+			continue
+		}
+
 		switch currentSection {
 		case "server":
 			switch key {
@@ -2879,7 +3092,14 @@ func parseConfigFileInto(cfg *parsedConfig, path string) error {
 				}
 			case "bind":
 				cfg.Bind = unquotedVal
+			case "socket":
+				cfg.API.Socket = val == "true"
+				cfg.apiSocketSet = true
+			case "require_token":
+				cfg.API.RequireToken = val == "true"
 			}
+		case "server.policy":
+			parseAPIPolicyKey(cfg, key, val)
 		case "refinery":
 			switch key {
 			case "enabled":
@@ -2893,6 +3113,33 @@ func parseConfigFileInto(cfg *parsedConfig, path string) error {
 				if n, err := strconv.Atoi(val); err == nil && n > 0 {
 					cfg.Refinery.MaxConcurrentMerges = n
 				}
+			case "gate_runner":
+				cfg.Refinery.GateRunner = expandTildePath(unquotedVal)
+			case "sandbox":
+				cfg.Refinery.Sandbox.Mode = unquotedVal
+			case "sandbox_read_only":
+				cfg.Refinery.Sandbox.ReadOnly = parseStringArray(val)
+			case "sandbox_writable":
+				cfg.Refinery.Sandbox.Writable = parseStringArray(val)
+			}
+		case "refinery.webhooks":
+			switch key {
+			case "urls":
+				cfg.Refinery.Webhooks.URLs = parseStringArray(val)
+			case "secret":
+				cfg.Refinery.Webhooks.Secret = unquotedVal
+			case "secret_file":
+				cfg.Refinery.Webhooks.SecretFile = expandTildePath(unquotedVal)
+			case "events":
+				cfg.Refinery.Webhooks.Events = parseStringArray(val)
+			case "max_attempts":
+				if n, err := strconv.Atoi(val); err == nil && n > 0 {
+					cfg.Refinery.Webhooks.MaxAttempts = n
+				}
+			case "timeout":
+				if d, err := time.ParseDuration(unquotedVal); err == nil && d > 0 {
+					cfg.Refinery.Webhooks.Timeout = d
+				}
 			}
 		case "search":
 			switch key {
@@ -2906,6 +3153,13 @@ func parseConfigFileInto(cfg *parsedConfig, path string) error {
 				}
 			case "index_roots":
 				cfg.IndexRoots = parseStringArray(val)
+			case "symbols":
+				cfg.SymbolIndexing = val == "true"
+				cfg.symbolIndexingSet = true
+			case "ctags":
+				cfg.CtagsPath = unquotedVal
+			case "branches":
+				cfg.IndexBranches = parseStringArray(val)
 			}
 		case "heartbeat":
 			switch key {
@@ -3025,6 +3279,40 @@ func parseConfigFileInto(cfg *parsedConfig, path string) error {
 					cfg.DispatchCap.RefineryReserve = n
 					cfg.dispatchCapReserveSet = true
 				}
+			case "cgroup_root":
+				cfg.DispatchCap.CgroupRoot = unquotedVal
+			}
+		case "recording":
+			switch key {
+			case "enabled":
+				cfg.Recording.Enabled = val == "true"
+				cfg.recordingEnabledSet = true
+			case "rotate_bytes":
+				if n, err := strconv.ParseInt(unquotedVal, 10, 64); err == nil && n > 0 {
+					cfg.Recording.RotateBytes = n
+				}
+			case "max_bytes":
+				if n, err := strconv.ParseInt(unquotedVal, 10, 64); err == nil && n > 0 {
+					cfg.Recording.MaxBytes = n
+				}
+			case "max_age":
+				if d, err := time.ParseDuration(unquotedVal); err == nil && d > 0 {
+					cfg.Recording.MaxAge = d
+				}
+			case "interval":
+				if d, err := time.ParseDuration(unquotedVal); err == nil && d > 0 {
+					cfg.Recording.Interval = d
+				}
+			}
+		case "tracing":
+			switch key {
+			case "enabled":
+				cfg.Tracing.Enabled = val == "true"
+				cfg.tracingEnabledSet = true
+			case "otlp_endpoint":
+				cfg.Tracing.OTLPEndpoint = unquotedVal
+			case "otlp_headers":
+				cfg.Tracing.OTLPHeaders = parseStringArray(val)
 			}
 		case "dispatch_pairing":
 			switch key {
@@ -3374,6 +3662,16 @@ func parseConfigFileInto(cfg *parsedConfig, path string) error {
 				cfg.Agents.SME = unquotedVal
 			case "extra_path":
 				cfg.Agents.ExtraPath = parseStringArray(val)
+			case "pty_holder":
+				cfg.Agents.PTYHolder = val == "true"
+			case "sandbox":
+				cfg.Agents.Sandbox.Mode = unquotedVal
+			case "sandbox_read_only":
+				cfg.Agents.Sandbox.ReadOnly = parseStringArray(val)
+			case "sandbox_writable":
+				cfg.Agents.Sandbox.Writable = parseStringArray(val)
+			case "mode":
+				cfg.Agents.Mode = unquotedVal
 			}
 		case "agents.crew":
 			switch key {
@@ -3381,6 +3679,10 @@ func parseConfigFileInto(cfg *parsedConfig, path string) error {
 				cfg.Agents.Crew.Command = unquotedVal
 			case "provider":
 				cfg.Agents.Crew.Provider = unquotedVal
+			case "sandbox":
+				cfg.Agents.Crew.Sandbox = unquotedVal
+			case "mode":
+				cfg.Agents.Crew.Mode = unquotedVal
 			}
 		case "agents.polecat":
 			switch key {
@@ -3388,6 +3690,10 @@ func parseConfigFileInto(cfg *parsedConfig, path string) error {
 				cfg.Agents.Polecat.Command = unquotedVal
 			case "provider":
 				cfg.Agents.Polecat.Provider = unquotedVal
+			case "sandbox":
+				cfg.Agents.Polecat.Sandbox = unquotedVal
+			case "mode":
+				cfg.Agents.Polecat.Mode = unquotedVal
 			}
 		}
 	}
@@ -610,6 +610,102 @@ index_interval = "30s"
 	}
 }
 
+func TestSymbolIndexingConfig(t *testing.T) {
+	dir := t.TempDir()
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	defer os.Unsetenv("XDG_CONFIG_HOME")
+
+	if cfg := Load(); !cfg.SymbolIndexing || cfg.CtagsPath != "" {
+		t.Errorf("default symbols=%v ctags=%q, want on and looked up on PATH", cfg.SymbolIndexing, cfg.CtagsPath)
+	}
+
+	pogoDir := filepath.Join(dir, "pogo")
+	os.MkdirAll(pogoDir, 0755)
+	os.WriteFile(filepath.Join(pogoDir, "config.toml"), []byte(`
+[search]
+symbols = false
+ctags = "/opt/ctags/bin/ctags"
+`), 0644)
+
+	cfg := Load()
+	if cfg.SymbolIndexing {
+		t.Error("expected symbols = false in the config file to turn symbol indexing off")
+	}
+	if cfg.CtagsPath != "/opt/ctags/bin/ctags" {
+		t.Errorf("expected ctags path from config file, got %q", cfg.CtagsPath)
+	}
+}
+
+func TestIndexBranchesConfig(t *testing.T) {
+	dir := t.TempDir()
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	defer os.Unsetenv("XDG_CONFIG_HOME")
+
+	if cfg := Load(); len(cfg.IndexBranches) != 0 {
+		t.Errorf("default branches = %v, want the working tree only", cfg.IndexBranches)
+	}
+
+	pogoDir := filepath.Join(dir, "pogo")
+	os.MkdirAll(pogoDir, 0755)
+	os.WriteFile(filepath.Join(pogoDir, "config.toml"), []byte(`
+[search]
+branches = ["origin/main", "polecat-*"]
+`), 0644)
+
+	cfg := Load()
+	if len(cfg.IndexBranches) != 2 || cfg.IndexBranches[0] != "origin/main" || cfg.IndexBranches[1] != "polecat-*" {
+		t.Errorf("branches = %v, want [origin/main polecat-*]", cfg.IndexBranches)
+	}
+}
+
+func TestPTYHolderConfig(t *testing.T) {
+	dir := t.TempDir()
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	defer os.Unsetenv("XDG_CONFIG_HOME")
+
+	if Load().Agents.PTYHolder {
+		t.Error("pty_holder is on by default")
+	}
+	pogoDir := filepath.Join(dir, "pogo")
+	os.MkdirAll(pogoDir, 0755)
+	os.WriteFile(filepath.Join(pogoDir, "config.toml"), []byte("[agents]\npty_holder = true\n"), 0644)
+	if !Load().Agents.PTYHolder {
+		t.Error("[agents] pty_holder = true was not read")
+	}
+}
+
+func TestSandboxConfig(t *testing.T) {
+	dir := t.TempDir()
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	defer os.Unsetenv("XDG_CONFIG_HOME")
+
+	pogoDir := filepath.Join(dir, "pogo")
+	os.MkdirAll(pogoDir, 0755)
+	os.WriteFile(filepath.Join(pogoDir, "config.toml"), []byte(`
+[agents]
+sandbox = "namespaces"
+sandbox_writable = ["~/.claude", "~/.macguffin"]
+
+[agents.polecat]
+sandbox = "landlock"
+
+[refinery]
+sandbox = "landlock"
+sandbox_read_only = ["~/go/pkg/mod"]
+`), 0644)
+	cfg := Load()
+	crew, cat := cfg.Agents.AgentSandbox("crew"), cfg.Agents.AgentSandbox("polecat")
+	if crew.Mode != "namespaces" || cat.Mode != "landlock" {
+		t.Errorf("crew mode %q, polecat mode %q; want namespaces, landlock", crew.Mode, cat.Mode)
+	}
+	if len(cat.Writable) != 2 || cat.Writable[0] != "~/.claude" {
+		t.Errorf("polecat writable = %v, want the [agents] list", cat.Writable)
+	}
+	if sb := cfg.Refinery.Sandbox; sb.Mode != "landlock" || len(sb.ReadOnly) != 1 {
+		t.Errorf("[refinery] sandbox = %+v", sb)
+	}
+}
+
 func TestRefineryEnabledDefault(t *testing.T) {
 	os.Setenv("XDG_CONFIG_HOME", t.TempDir())
 	defer os.Unsetenv("XDG_CONFIG_HOME")
@@ -681,6 +777,67 @@ poll_interval = "15s"
 	}
 }
 
+func TestRefineryGateRunnerConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	defer os.Unsetenv("XDG_CONFIG_HOME")
+
+	pogoDir := filepath.Join(dir, "pogo")
+	os.MkdirAll(pogoDir, 0755)
+	os.WriteFile(filepath.Join(pogoDir, "config.toml"), []byte(`
+[refinery]
+gate_runner = "~/gate-runner.sock"
+`), 0644)
+
+	cfg := Load()
+	home, _ := os.UserHomeDir()
+	if want := filepath.Join(home, "gate-runner.sock"); cfg.Refinery.GateRunner != want {
+		t.Errorf("gate_runner = %q, want %q", cfg.Refinery.GateRunner, want)
+	}
+	if !cfg.Refinery.Enabled {
+		t.Errorf("expected refinery to remain enabled when only gate_runner is set, got disabled")
+	}
+}
+
+func TestRefineryWebhooksConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	defer os.Unsetenv("XDG_CONFIG_HOME")
+
+	pogoDir := filepath.Join(dir, "pogo")
+	os.MkdirAll(pogoDir, 0755)
+	os.WriteFile(filepath.Join(pogoDir, "config.toml"), []byte(`
+[refinery]
+max_concurrent_merges = 3
+
+[refinery.webhooks]
+urls = ["http://127.0.0.1:9000/pogo", "https://chat.example/hook"]
+secret_file = "/etc/pogo/webhook.key"
+events = ["merged", "failed"]
+max_attempts = 8
+timeout = "3s"
+`), 0644)
+
+	cfg := Load()
+	wh := cfg.Refinery.Webhooks
+	if len(wh.URLs) != 2 || wh.URLs[1] != "https://chat.example/hook" {
+		t.Errorf("urls = %v", wh.URLs)
+	}
+	if wh.SecretFile != "/etc/pogo/webhook.key" || wh.Secret != "" {
+		t.Errorf("secret = %q, secret_file = %q", wh.Secret, wh.SecretFile)
+	}
+	if len(wh.Events) != 2 || wh.Events[0] != "merged" {
+		t.Errorf("events = %v", wh.Events)
+	}
+	if wh.MaxAttempts != 8 || wh.Timeout != 3*time.Second {
+		t.Errorf("max_attempts = %d, timeout = %s", wh.MaxAttempts, wh.Timeout)
+	}
+	// The subsection must not swallow its parent's keys.
+	if cfg.Refinery.MaxConcurrentMerges != 3 {
+		t.Errorf("max_concurrent_merges = %d, want 3", cfg.Refinery.MaxConcurrentMerges)
+	}
+}
+
 func TestHeartbeatConfigFile(t *testing.T) {
 	dir := t.TempDir()
 	os.Setenv("XDG_CONFIG_HOME", dir)
@@ -66,6 +66,12 @@ type DispatchCapConfig struct {
 	// built by workers dispatched BEFORE the gate starts, and by the time it
 	// starts they cannot be taken back.
 	RefineryReserve int
+	// CgroupRoot is a delegated cgroup v2 directory pogod places each worker
+	// and each refinery gate run under, in a group of its own limited to the
+	// worker budget (user-013). Empty leaves the budget advisory, as it always
+	// was; it is Linux-only and off by default, because it needs a delegation
+	// the operator has to set up.
+	CgroupRoot string
 }
 
 // Cap defaults. Three workers per repo on a 10-core host leaves room for the
@@ -101,6 +101,23 @@ func TestDispatchCapOverride(t *testing.T) {
 	}
 }
 
+// TestDispatchCgroupRoot: enforcement is opt-in, and naming a root leaves the
+// cap's own keys at their defaults.
+func TestDispatchCgroupRoot(t *testing.T) {
+	if got := loadWithConfigDir(t, t.TempDir()).DispatchCap.CgroupRoot; got != "" {
+		t.Errorf("CgroupRoot = %q by default, want empty — the budget is only enforced when asked", got)
+	}
+	dir := t.TempDir()
+	writeCapConfig(t, dir, "[dispatch]\ncgroup_root = \"/sys/fs/cgroup/pogo.slice/work\"\n")
+	cfg := loadWithConfigDir(t, dir)
+	if cfg.DispatchCap.CgroupRoot != "/sys/fs/cgroup/pogo.slice/work" {
+		t.Errorf("CgroupRoot = %q", cfg.DispatchCap.CgroupRoot)
+	}
+	if cfg.DispatchCap.MaxPolecatsPerRepo != DefaultMaxPolecatsPerRepo {
+		t.Errorf("MaxPolecatsPerRepo = %d, want the default", cfg.DispatchCap.MaxPolecatsPerRepo)
+	}
+}
+
 // TestNoConfigFileStillArmsTheCap. A daemon on a box with no config.toml must
 // still enforce this, or the control is one missing file away from absent —
 // the class of gap mg-da48 and mg-6c4b were both about.
@@ -0,0 +1,248 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProviderConfig is one [providers.<id>] table: a harness declared in config
+// rather than in Go (user-018). internal/providers turns it into an
+// agent.Provider and is where it is validated; this package only records what
+// the table said.
+//
+// The fields are the data half of agent.Provider. The behaviour half — the
+// trust and modal hooks a Go provider writes — is reduced to screen rules: a
+// regular expression matched against the agent's rendered screen, and the
+// keys to send when it matches.
+//
+//	[providers.aider]
+//	binary = "aider"
+//	command = "aider --yes-always --read {{.PromptFile}}"
+//	model_flag = "--model"
+//	prompt_injection = "env"
+//	ready_sentinel = "> "
+//	trust_screen = "(?i)add .* to \\.gitignore"
+//	trust_keys = "n\r"
+//
+//	[providers.aider.modals.update]
+//	screen = "(?i)newer aider version"
+//	keys = "n\r"
+//
+// Values that are sent to a terminal or compiled as a pattern — the screen
+// rules, submit_terminator, ready_sentinel — take TOML's string escapes in a
+// basic ("…") string, so "\r" is a carriage return, and none in a literal
+// ('…') one.
+type ProviderConfig struct {
+	// ID is the <id> of the table header. It is what [agents] provider,
+	// --provider and a prompt's provider: frontmatter name.
+	ID string
+
+	Binary              string
+	Command             string
+	ModelFlag           string
+	NonInteractiveFlags []string
+
+	// PromptInjection is how the persona reaches the harness: "flag"
+	// (PromptFlag names the flag), "context_file" (written to ContextFile in
+	// the working directory) or "env" (POGO_AGENT_PROMPT only). Empty picks
+	// flag when PromptFlag is set, context_file when ContextFile is, else env.
+	PromptInjection   string
+	PromptFlag        string
+	ContextFile       string
+	ContextFileHeader string
+
+	InitialPromptViaArgv bool
+	// NeedsInitialNudge is nil when the table does not say, which means
+	// "unless InitialPromptViaArgv".
+	NeedsInitialNudge   *bool
+	InitialNudgeTimeout time.Duration
+	SubmitTerminator    string
+	SubmitDelay         time.Duration
+	IdleThreshold       time.Duration
+	ReadySentinel       string
+	ReadyAlternates     []string
+
+	// PTYCols and PTYRows, both or neither, replace pogo's default winsize.
+	PTYCols int
+	PTYRows int
+
+	// TrustScreen and TrustKeys answer a dialog the harness shows at startup,
+	// such as a workspace-trust prompt: once, within InitialNudgeTimeout of
+	// the spawn, and never after the ready sentinel is on screen.
+	TrustScreen string
+	TrustKeys   string
+
+	// Stream says the harness speaks pogo's native structured stream — one
+	// JSON event per output line, one {"type":"user","text":…} message per
+	// input line — when started with StreamFlags, so its agents can run in
+	// stream mode (user-019).
+	Stream      bool
+	StreamFlags []string
+
+	// Modals are the [providers.<id>.modals.<name>] tables: dialogs that can
+	// come up at any time in a session, answered for its whole life.
+	Modals []ModalRuleConfig
+
+	// Errors are values the table gave that could not be read at all — a
+	// duration that does not parse, a bad escape. They are kept rather than
+	// dropped so `pogo doctor` and pogod can refuse the provider by name
+	// instead of running it with a default nobody wrote.
+	Errors []string
+}
+
+// ModalRuleConfig is one [providers.<id>.modals.<name>] table.
+type ModalRuleConfig struct {
+	Name   string
+	Screen string
+	Keys   string
+	// Idle is how long the screen must have matched before the keys are sent,
+	// so a dialog mid-draw, or text that merely scrolls past, is left alone.
+	// Zero means the default, one second.
+	Idle time.Duration
+}
+
+// DefaultModalIdle is ModalRuleConfig.Idle when a rule does not set one.
+const DefaultModalIdle = time.Second
+
+// parseProviderKey applies one key of a [providers.<id>] or
+// [providers.<id>.modals.<name>] table, where section is the header with the
+// "providers." prefix removed. Tables are filled in place, so a later config
+// layer overrides a provider key by key, exactly as it does every other
+// section.
+func parseProviderKey(cfg *parsedConfig, section, key, val string) {
+	id, modal, isModal := strings.Cut(section, ".modals.")
+	if id == "" || strings.Contains(id, ".") {
+		return
+	}
+	if cfg.Providers == nil {
+		cfg.Providers = map[string]ProviderConfig{}
+	}
+	pc := cfg.Providers[id]
+	pc.ID = id
+	defer func() { cfg.Providers[id] = pc }()
+
+	where := key
+	if isModal {
+		where = "modals." + modal + "." + key
+	}
+	// A layer that sets a key again replaces the value, and with it whatever
+	// was wrong with the one it replaces.
+	pc.Errors = slices.DeleteFunc(pc.Errors, func(e string) bool { return strings.HasPrefix(e, where+": ") })
+	bad := func(err error) {
+		pc.Errors = append(pc.Errors, fmt.Sprintf("%s: %v", where, err))
+	}
+	str := func() string {
+		s, err := unquoteEscaped(val)
+		if err != nil {
+			bad(err)
+		}
+		return s
+	}
+	dur := func() time.Duration {
+		d, err := time.ParseDuration(unquote(val))
+		switch {
+		case err != nil:
+			bad(err)
+		case d < 0:
+			bad(fmt.Errorf("%s is negative", unquote(val)))
+		}
+		return d
+	}
+
+	if isModal {
+		if modal == "" {
+			return
+		}
+		i := len(pc.Modals)
+		for j, m := range pc.Modals {
+			if m.Name == modal {
+				i = j
+			}
+		}
+		if i == len(pc.Modals) {
+			pc.Modals = append(pc.Modals, ModalRuleConfig{Name: modal})
+		}
+		switch key {
+		case "screen":
+			pc.Modals[i].Screen = str()
+		case "keys":
+			pc.Modals[i].Keys = str()
+		case "idle":
+			pc.Modals[i].Idle = dur()
+		}
+		return
+	}
+
+	switch key {
+	case "binary":
+		pc.Binary = unquote(val)
+	case "command":
+		pc.Command = unquote(val)
+	case "model_flag":
+		pc.ModelFlag = unquote(val)
+	case "non_interactive_flags":
+		pc.NonInteractiveFlags = parseStringArray(val)
+	case "prompt_injection":
+		pc.PromptInjection = unquote(val)
+	case "prompt_flag":
+		pc.PromptFlag = unquote(val)
+	case "context_file":
+		pc.ContextFile = unquote(val)
+	case "context_file_header":
+		pc.ContextFileHeader = str()
+	case "initial_prompt_via_argv":
+		pc.InitialPromptViaArgv = val == "true"
+	case "needs_initial_nudge":
+		b := val == "true"
+		pc.NeedsInitialNudge = &b
+	case "initial_nudge_timeout":
+		pc.InitialNudgeTimeout = dur()
+	case "submit_terminator":
+		pc.SubmitTerminator = str()
+	case "submit_delay":
+		pc.SubmitDelay = dur()
+	case "idle_threshold":
+		pc.IdleThreshold = dur()
+	case "ready_sentinel":
+		pc.ReadySentinel = str()
+	case "ready_alternates":
+		pc.ReadyAlternates = parseStringArray(val)
+	case "pty_cols", "pty_rows":
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			bad(err)
+		} else if key == "pty_cols" {
+			pc.PTYCols = n
+		} else {
+			pc.PTYRows = n
+		}
+	case "trust_screen":
+		pc.TrustScreen = str()
+	case "trust_keys":
+		pc.TrustKeys = str()
+	case "stream":
+		pc.Stream = val == "true"
+	case "stream_flags":
+		pc.StreamFlags = parseStringArray(val)
+	}
+}
+
+// unquoteEscaped is unquote for values whose escapes matter: a basic ("…")
+// string has its backslash escapes decoded, as TOML does, and a literal
+// ('…') string or a bare value is taken as written. unquote cannot be made to
+// do this itself — every other key has always read a backslash literally, and
+// a Windows-style path or a regular expression someone wrote for that reading
+// must keep working.
+func unquoteEscaped(val string) (string, error) {
+	if len(val) >= 2 && val[0] == '"' && val[len(val)-1] == '"' {
+		s, err := strconv.Unquote(val)
+		if err != nil {
+			return "", fmt.Errorf("%s: bad escape in a basic string (use '…' to take it literally)", val)
+		}
+		return s, nil
+	}
+	return unquote(val), nil
+}
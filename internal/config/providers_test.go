@@ -0,0 +1,73 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// A [providers.<id>] table and its modal tables parse with TOML's escapes in
+// basic strings, a higher layer overrides a table key by key, and a value that
+// cannot be read is kept as an error rather than dropped.
+func TestProviderTablesParse(t *testing.T) {
+	dir := t.TempDir()
+	lower := filepath.Join(dir, "xdg", "pogo")
+	if err := os.MkdirAll(lower, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(lower, "config.toml"), []byte(`[providers.aider]
+binary = "aider"
+command = "aider {{.PromptFile}}"
+submit_delay = "soon"
+pty_cols = 120
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeCapConfig(t, dir, `[providers.aider]
+command = "aider --yes-always --read {{.PromptFile}}"
+non_interactive_flags = ["--yes-always"]
+needs_initial_nudge = false
+submit_terminator = "\r"
+submit_delay = "80ms"
+ready_sentinel = '\d+ tokens'
+trust_screen = "(?i)add \\.aider\\* to \\.gitignore"
+trust_keys = "n\r"
+
+[providers.aider.modals.update]
+screen = "(?i)newer aider version"
+keys = "n\r"
+idle = "3s"
+
+[providers.aider.modals.bad]
+idle = "forever"
+`)
+	cfg := loadWithConfigDir(t, dir)
+
+	no := false
+	want := ProviderConfig{
+		ID:                  "aider",
+		Binary:              "aider",
+		Command:             "aider --yes-always --read {{.PromptFile}}",
+		NonInteractiveFlags: []string{"--yes-always"},
+		NeedsInitialNudge:   &no,
+		SubmitTerminator:    "\r",
+		SubmitDelay:         80 * time.Millisecond,
+		ReadySentinel:       `\d+ tokens`,
+		PTYCols:             120,
+		TrustScreen:         `(?i)add \.aider\* to \.gitignore`,
+		TrustKeys:           "n\r",
+		Modals: []ModalRuleConfig{
+			{Name: "update", Screen: "(?i)newer aider version", Keys: "n\r", Idle: 3 * time.Second},
+			{Name: "bad"},
+		},
+		Errors: []string{`modals.bad.idle: time: invalid duration "forever"`},
+	}
+	if got := cfg.Providers["aider"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Providers[aider] =\n %+v\nwant\n %+v", got, want)
+	}
+	if len(cfg.Providers) != 1 {
+		t.Errorf("Providers = %v, want only aider", cfg.Providers)
+	}
+}
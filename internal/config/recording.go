@@ -0,0 +1,49 @@
+package config
+
+import "time"
+
+// RecordingConfig is the [recording] section: whether pogod keeps every
+// agent's PTY stream as an asciicast v2 file under
+// $POGO_HOME/agents/<name>/sessions, and how much of it retention keeps
+// (user-015, internal/recording).
+//
+// It ships OFF. A recording is every byte an agent printed, and an agent
+// prints what it reads — file contents, command output, whatever a tool
+// returned — so turning it on is a decision about what this host keeps on
+// disk, not a default pogo can make for it. The limits below apply only once
+// it is on.
+type RecordingConfig struct {
+	// Enabled turns recording on for every agent pogod starts or adopts.
+	Enabled bool
+	// RotateBytes is the size at which a recording closes and the session
+	// continues in a new file.
+	RotateBytes int64
+	// MaxBytes is the most one agent's recordings may hold; retention removes
+	// the oldest files first.
+	MaxBytes int64
+	// MaxAge is how long a recording is kept after it was last written.
+	MaxAge time.Duration
+	// Interval is how often pogod sweeps every agent's recordings.
+	Interval time.Duration
+}
+
+// Recording defaults. 16MB is a few hours of a busy TUI, small enough to open
+// in a player whole; 256MB and a week keep the last several sessions of any
+// one agent, which is what a post-mortem reaches for.
+const (
+	DefaultRecordingRotateBytes = 16 << 20
+	DefaultRecordingMaxBytes    = 256 << 20
+	DefaultRecordingMaxAge      = 7 * 24 * time.Hour
+	DefaultRecordingInterval    = time.Hour
+)
+
+// DefaultRecordingConfig returns the shipped [recording] section: off, with
+// the limits it would run under if turned on.
+func DefaultRecordingConfig() RecordingConfig {
+	return RecordingConfig{
+		RotateBytes: DefaultRecordingRotateBytes,
+		MaxBytes:    DefaultRecordingMaxBytes,
+		MaxAge:      DefaultRecordingMaxAge,
+		Interval:    DefaultRecordingInterval,
+	}
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecordingShipsOff(t *testing.T) {
+	cfg := loadWithConfigDir(t, t.TempDir())
+	if cfg.Recording.Enabled {
+		t.Error("recording is on with no config — it keeps everything every agent prints")
+	}
+	if cfg.Recording != DefaultRecordingConfig() {
+		t.Errorf("Recording = %+v, want the defaults %+v", cfg.Recording, DefaultRecordingConfig())
+	}
+}
+
+func TestRecordingSectionParses(t *testing.T) {
+	dir := t.TempDir()
+	writeCapConfig(t, dir, `[recording]
+enabled = true
+rotate_bytes = 1048576
+max_age = "48h"
+interval = "10m"
+`)
+	cfg := loadWithConfigDir(t, dir)
+	want := RecordingConfig{
+		Enabled:     true,
+		RotateBytes: 1 << 20,
+		MaxBytes:    DefaultRecordingMaxBytes,
+		MaxAge:      48 * time.Hour,
+		Interval:    10 * time.Minute,
+	}
+	if cfg.Recording != want {
+		t.Errorf("Recording = %+v, want %+v", cfg.Recording, want)
+	}
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// TracingConfig is the [tracing] section: where pogod sends the spans it
+// records for each work item (user-023, internal/tracing).
+//
+//	[tracing]
+//	enabled = true                            # spans to $POGO_HOME/traces.jsonl
+//	otlp_endpoint = "http://localhost:4318"   # and to a collector, if set
+//	otlp_headers = ["x-api-key=…"]
+//
+// It ships ON, writing the local file only. A span is a phase name, two
+// timestamps and a few ids — nothing an agent printed — and the file is what
+// `pogo item trace` reads, so a host with no collector still gets the
+// waterfall. The file rotates itself; see tracing.FileExporter.
+type TracingConfig struct {
+	// Enabled records spans at all. Off, neither the file nor the collector
+	// sees anything.
+	Enabled bool
+	// OTLPEndpoint is an OpenTelemetry collector's OTLP/HTTP address, or ""
+	// for the file alone.
+	OTLPEndpoint string
+	// OTLPHeaders are "key=value" headers sent with every export, typically a
+	// backend's API key.
+	OTLPHeaders []string
+}
+
+// DefaultTracingConfig returns the shipped [tracing] section: on, file only.
+func DefaultTracingConfig() TracingConfig {
+	return TracingConfig{Enabled: true}
+}
+
+// Headers returns OTLPHeaders as a map, skipping entries without an "=".
+func (c TracingConfig) Headers() map[string]string {
+	if len(c.OTLPHeaders) == 0 {
+		return nil
+	}
+	h := make(map[string]string, len(c.OTLPHeaders))
+	for _, kv := range c.OTLPHeaders {
+		if k, v, ok := strings.Cut(kv, "="); ok && strings.TrimSpace(k) != "" {
+			h[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return h
+}
+
+// TracesPath is the JSONL file pogod appends spans to and `pogo item trace`
+// reads: $POGO_HOME/traces.jsonl.
+func TracesPath() string {
+	return filepath.Join(PogoHome(), "traces.jsonl")
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTracingShipsOnWithTheFileOnly(t *testing.T) {
+	cfg := loadWithConfigDir(t, t.TempDir())
+	if !reflect.DeepEqual(cfg.Tracing, DefaultTracingConfig()) {
+		t.Errorf("Tracing = %+v, want the defaults %+v", cfg.Tracing, DefaultTracingConfig())
+	}
+	if !cfg.Tracing.Enabled || cfg.Tracing.OTLPEndpoint != "" {
+		t.Errorf("Tracing = %+v, want on with no collector", cfg.Tracing)
+	}
+}
+
+func TestTracingSectionParses(t *testing.T) {
+	dir := t.TempDir()
+	writeCapConfig(t, dir, `[tracing]
+otlp_endpoint = "http://collector:4318"
+otlp_headers = ["x-api-key = abc", "not-a-header"]
+`)
+	cfg := loadWithConfigDir(t, dir)
+	if !cfg.Tracing.Enabled {
+		t.Error("setting an endpoint turned tracing off")
+	}
+	if cfg.Tracing.OTLPEndpoint != "http://collector:4318" {
+		t.Errorf("OTLPEndpoint = %q", cfg.Tracing.OTLPEndpoint)
+	}
+	if got, want := cfg.Tracing.Headers(), map[string]string{"x-api-key": "abc"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Headers() = %v, want %v", got, want)
+	}
+}
+
+func TestTracingCanBeTurnedOff(t *testing.T) {
+	dir := t.TempDir()
+	writeCapConfig(t, dir, "[tracing]\nenabled = false\n")
+	if cfg := loadWithConfigDir(t, dir); cfg.Tracing.Enabled {
+		t.Error("enabled = false left tracing on")
+	}
+}
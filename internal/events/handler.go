@@ -0,0 +1,100 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Bounds of GET /events. The window defaults to the last hour so that a
+// caller who names nothing reads the sidecar-indexed tail of the log, not
+// all six files of it; the limit keeps a busy hour from being a megabyte of
+// JSON on every refresh.
+const (
+	defaultHandlerWindow = time.Hour
+	defaultHandlerLimit  = 200
+	maxHandlerLimit      = 2000
+)
+
+// HandleEvents handles GET /events (user-024): the most recent events in the
+// live log and its rotated files, oldest first, as a JSON array.
+//
+// Query parameters mirror `pogo events list`:
+//
+//   - since: a duration back from now ("30m", "24h") or an RFC3339 time;
+//     default 1h
+//   - type, agent: exact matches on event_type and agent
+//   - where: a predicate, as --where takes it
+//   - limit: keep only the newest this many; default 200, at most 2000
+//
+// It is the dashboard's event feed, and anything else that wants the log and
+// is not on pogod's host.
+func HandleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "", http.StatusMethodNotAllowed)
+		return
+	}
+	q, limit, err := parseHandlerQuery(r, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	path, err := LogPath()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// A ring of the newest limit matches: Select visits oldest first, and
+	// the feed wants the end of the window, not its start.
+	ring := make([]Event, 0, limit)
+	next := 0
+	err = Select(path, q, func(ev Event) {
+		if len(ring) < limit {
+			ring = append(ring, ev)
+			return
+		}
+		ring[next] = ev
+		next = (next + 1) % limit
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	out := append(ring[next:len(ring):len(ring)], ring[:next]...)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// parseHandlerQuery reads GET /events' parameters into a Query and a limit.
+func parseHandlerQuery(r *http.Request, now time.Time) (Query, int, error) {
+	params := r.URL.Query()
+	q := Query{Since: now.Add(-defaultHandlerWindow)}
+	if s := params.Get("since"); s != "" {
+		if d, err := time.ParseDuration(s); err == nil && d > 0 {
+			q.Since = now.Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, s); err == nil {
+			q.Since = t
+		} else {
+			return Query{}, 0, fmt.Errorf("since: %q is neither a positive duration (30m, 24h) nor an RFC3339 time", s)
+		}
+	}
+	where, err := ParsePredicate(params.Get("where"))
+	if err != nil {
+		return Query{}, 0, fmt.Errorf("where: %w", err)
+	}
+	q.Where = And(Filter{Type: params.Get("type"), Agent: params.Get("agent")}.Query().Where, where)
+
+	limit := defaultHandlerLimit
+	if s := params.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return Query{}, 0, fmt.Errorf("limit: %q is not a positive number", s)
+		}
+		limit = min(n, maxHandlerLimit)
+	}
+	return q, limit, nil
+}
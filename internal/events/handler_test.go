@@ -0,0 +1,55 @@
+package events
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestHandleEventsServesTheNewestOfTheWindow: a feed wants the end of the
+// window, oldest first, so the limit drops the oldest matches, not the
+// newest.
+func TestHandleEventsServesTheNewestOfTheWindow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	SetLogPathForTesting(path)
+	t.Cleanup(func() { SetLogPathForTesting("") })
+	evs := writeHistory(t, path, time.Now().Add(-90*time.Minute), 90)
+
+	get := func(query string) (int, []Event) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		HandleEvents(rec, httptest.NewRequest("GET", "/events"+query, nil))
+		var out []Event
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+				t.Fatalf("%s: %v", query, err)
+			}
+		}
+		return rec.Code, out
+	}
+
+	code, got := get("?since=2h&type=agent_spawned&limit=4")
+	if code != http.StatusOK || len(got) != 4 {
+		t.Fatalf("status %d, %d events; want 200 and 4", code, len(got))
+	}
+	if got[3].Timestamp != evs[87].Timestamp || got[0].Timestamp != evs[78].Timestamp {
+		t.Errorf("got %s..%s, want the newest four agent_spawned (%s..%s)",
+			got[0].Timestamp, got[3].Timestamp, evs[78].Timestamp, evs[87].Timestamp)
+	}
+
+	// The default window is the last hour.
+	if _, got := get(""); len(got) < 55 || len(got) > 60 {
+		t.Errorf("default window returned %d events, want the last hour's ~60", len(got))
+	}
+	if _, got := get("?since=2h&where=details.exit_code+%3D+3&agent=cat-1"); len(got) != 22 {
+		t.Errorf("where+agent returned %d events, want 22", len(got))
+	}
+	for _, bad := range []string{"?since=yesterday", "?limit=0", "?where=%3D%3D"} {
+		if code, _ := get(bad); code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", bad, code)
+		}
+	}
+}
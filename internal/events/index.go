@@ -0,0 +1,371 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// The sidecar index (Select).
+//
+// Every reader used to parse every line of every file it read, and the
+// retained log runs to ~600MB: a watcher asking "any spawn failures in the
+// last ten minutes" paid for the whole history on each tick. A segment — the
+// live log or a rotated file — is now summarised once, in blocks of
+// indexBlockEvents consecutive lines: where each block starts, the time range
+// it covers, and, for event_type, agent and work_item_id, which blocks hold
+// each value. A query reads only the blocks its time range and equality
+// predicates allow, and still tests every event it reads, so the index only
+// ever decides what need not be read.
+//
+// Segments are append-only, so an index covers a prefix of its segment and is
+// extended from where it stopped. It is keyed by the segment's inode rather
+// than its name: rotation renames events.log to events.log.1 and so on, and an
+// index kept by inode follows the file without rotate knowing it exists. The
+// first bytes of the segment are recorded too, so a reused inode is not
+// mistaken for the file it once was. Indexes live in a directory beside the
+// log (events.log.idx/) and are dropped once their segment is gone.
+//
+// The index is best-effort in the same way Emit is: a sidecar that cannot be
+// read is rebuilt, and one that cannot be written is rebuilt in memory on the
+// next query. Neither makes a query fail.
+
+// indexVersion is bumped when the sidecar's shape or meaning changes; a
+// sidecar of another version is rebuilt.
+const indexVersion = 1
+
+// indexBlockEvents is how many lines a block holds. Smaller blocks skip more
+// precisely and cost more index; at ~300 bytes a line, a block is ~150KB of
+// log.
+const indexBlockEvents = 512
+
+// indexSaveBytes is how far a segment must have grown past its saved index
+// before the extended index is written back. The live log grows by a few
+// lines at a time between watcher ticks; re-reading a short tail is cheaper
+// than rewriting the sidecar on every query.
+const indexSaveBytes = 1 << 20
+
+// indexHeadBytes is how much of a segment's start identifies it.
+const indexHeadBytes = 64
+
+// indexedFields are the fields with postings.
+var indexedFields = []string{"event_type", "agent", "work_item_id"}
+
+// segmentIndex is one segment's sidecar.
+type segmentIndex struct {
+	Version int    `json:"version"`
+	Inode   uint64 `json:"inode"`
+	Head    string `json:"head"`
+	// Size is how many bytes of the segment are indexed: always whole lines.
+	Size   int64        `json:"size"`
+	Blocks []indexBlock `json:"blocks"`
+	// Postings maps an indexed field to its values, and each value to the
+	// ascending ids of the blocks holding it.
+	Postings map[string]map[string][]int `json:"postings"`
+}
+
+// indexBlock is a run of consecutive lines. It ends where the next block
+// starts, or at the index's Size.
+type indexBlock struct {
+	Offset int64 `json:"offset"`
+	Lines  int   `json:"lines"`
+	// MinTime and MaxTime bound the block's parseable timestamps, in Unix
+	// nanoseconds; both are 0 when none parsed.
+	MinTime int64 `json:"min_time,omitempty"`
+	MaxTime int64 `json:"max_time,omitempty"`
+}
+
+// blockSet is a set of block ids; nil is the set of every block.
+type blockSet map[int]struct{}
+
+func newBlockSet(ids []int) blockSet {
+	s := make(blockSet, len(ids))
+	for _, id := range ids {
+		s[id] = struct{}{}
+	}
+	return s
+}
+
+func (s blockSet) intersect(o blockSet) blockSet {
+	if s == nil {
+		return o
+	}
+	if o == nil {
+		return s
+	}
+	out := make(blockSet)
+	for id := range s {
+		if _, ok := o[id]; ok {
+			out[id] = struct{}{}
+		}
+	}
+	return out
+}
+
+func (s blockSet) union(o blockSet) blockSet {
+	if s == nil || o == nil {
+		return nil
+	}
+	out := make(blockSet, len(s)+len(o))
+	for id := range s {
+		out[id] = struct{}{}
+	}
+	for id := range o {
+		out[id] = struct{}{}
+	}
+	return out
+}
+
+// indexDir is where path's segment indexes live.
+func indexDir(path string) string {
+	return path + ".idx"
+}
+
+func sidecarPath(path string, inode uint64) string {
+	return filepath.Join(indexDir(path), "seg-"+strconv.FormatUint(inode, 10)+".json")
+}
+
+// segmentIdentity is the inode, size and first bytes of the open segment f.
+func segmentIdentity(f *os.File) (inode uint64, size int64, head string, err error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, "", err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, "", errors.New("no inode for " + f.Name())
+	}
+	buf := make([]byte, indexHeadBytes)
+	n, err := f.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return 0, 0, "", err
+	}
+	return uint64(st.Ino), info.Size(), string(buf[:n]), nil
+}
+
+// loadSegmentIndex returns f's index brought up to date with its end, and
+// saves it when it grew enough to be worth saving. logPath names the log the
+// segment belongs to, which decides where the sidecar lives.
+func loadSegmentIndex(logPath string, f *os.File) (*segmentIndex, error) {
+	inode, size, head, err := segmentIdentity(f)
+	if err != nil {
+		return nil, err
+	}
+	sidecar := sidecarPath(logPath, inode)
+	ix := readSidecar(sidecar)
+	if ix == nil || ix.Version != indexVersion || ix.Inode != inode || ix.Size > size ||
+		!strings.HasPrefix(head, ix.Head) {
+		ix = &segmentIndex{Version: indexVersion, Inode: inode, Postings: make(map[string]map[string][]int)}
+	}
+	saved := ix.Size
+	if ix.Size < size {
+		if err := ix.extend(f); err != nil {
+			return nil, err
+		}
+		// The segment only grows, so a head recorded while it was shorter
+		// is a prefix of today's.
+		ix.Head = head
+	}
+	if ix.Size-saved >= indexSaveBytes || (saved == 0 && ix.Size > 0) {
+		writeSidecar(sidecar, ix)
+	}
+	return ix, nil
+}
+
+func readSidecar(path string) *segmentIndex {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var ix segmentIndex
+	if err := json.Unmarshal(data, &ix); err != nil {
+		return nil
+	}
+	if ix.Postings == nil {
+		ix.Postings = make(map[string]map[string][]int)
+	}
+	return &ix
+}
+
+// writeSidecar saves ix beside the live one and renames it into place, so a
+// concurrent reader sees the old index or the new one.
+func writeSidecar(path string, ix *segmentIndex) {
+	data, err := json.Marshal(ix)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		os.Remove(tmp)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+	}
+}
+
+// extend indexes f's whole lines past ix.Size. A trailing line without its
+// newline is a write in progress and is left for the next extension.
+func (ix *segmentIndex) extend(f *os.File) error {
+	if _, err := f.Seek(ix.Size, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReaderSize(f, 256*1024)
+	offset := ix.Size
+	for {
+		line, err := r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			// A line longer than the buffer: gather the rest of it.
+			long := append([]byte(nil), line...)
+			for err == bufio.ErrBufferFull {
+				line, err = r.ReadSlice('\n')
+				long = append(long, line...)
+			}
+			line = long
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		ix.add(offset, line)
+		offset += int64(len(line))
+		ix.Size = offset
+	}
+	return nil
+}
+
+// add records the line at offset in the index's last block, starting a new
+// block when that one is full.
+func (ix *segmentIndex) add(offset int64, line []byte) {
+	if len(ix.Blocks) == 0 || ix.Blocks[len(ix.Blocks)-1].Lines >= indexBlockEvents {
+		ix.Blocks = append(ix.Blocks, indexBlock{Offset: offset})
+	}
+	id := len(ix.Blocks) - 1
+	b := &ix.Blocks[id]
+	b.Lines++
+
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+	ev, err := ParseLine(line)
+	if err != nil {
+		return
+	}
+	if ts, err := time.Parse(time.RFC3339Nano, ev.Timestamp); err == nil {
+		n := ts.UnixNano()
+		if b.MinTime == 0 && b.MaxTime == 0 {
+			b.MinTime, b.MaxTime = n, n
+		} else {
+			b.MinTime, b.MaxTime = min(b.MinTime, n), max(b.MaxTime, n)
+		}
+	}
+	for _, field := range indexedFields {
+		v, ok := fieldValue(ev, field)
+		s, _ := v.(string)
+		if !ok || s == "" {
+			continue
+		}
+		values := ix.Postings[field]
+		if values == nil {
+			values = make(map[string][]int)
+			ix.Postings[field] = values
+		}
+		if ids := values[s]; len(ids) == 0 || ids[len(ids)-1] != id {
+			values[s] = append(ids, id)
+		}
+	}
+}
+
+// end is where block id stops.
+func (ix *segmentIndex) end(id int) int64 {
+	if id+1 < len(ix.Blocks) {
+		return ix.Blocks[id+1].Offset
+	}
+	return ix.Size
+}
+
+// candidates are the ids of the blocks that can hold a match for q, in file
+// order.
+func (ix *segmentIndex) candidates(q Query) []int {
+	allowed := q.Where.blocks(ix)
+	var out []int
+	for id, b := range ix.Blocks {
+		if allowed != nil {
+			if _, ok := allowed[id]; !ok {
+				continue
+			}
+		}
+		if !q.Since.IsZero() || !q.Until.IsZero() {
+			if b.MinTime == 0 && b.MaxTime == 0 {
+				// Nothing in the block has a time, and an event without
+				// one fails any time bound.
+				continue
+			}
+			if !q.Since.IsZero() && b.MaxTime <= q.Since.UnixNano() {
+				continue
+			}
+			if !q.Until.IsZero() && b.MinTime >= q.Until.UnixNano() {
+				continue
+			}
+		}
+		out = append(out, id)
+	}
+	return out
+}
+
+// pruneSidecars drops the indexes of segments that no longer exist. live is
+// the set of inodes still in use.
+func pruneSidecars(logPath string, live map[uint64]bool) {
+	entries, err := os.ReadDir(indexDir(logPath))
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		name := e.Name()
+		num, ok := strings.CutPrefix(name, "seg-")
+		if !ok {
+			continue
+		}
+		num, ok = strings.CutSuffix(num, ".json")
+		if !ok {
+			// A temp file from a writer that died mid-save.
+			if strings.HasSuffix(name, ".tmp") {
+				if info, err := e.Info(); err == nil && time.Since(info.ModTime()) > time.Hour {
+					os.Remove(filepath.Join(indexDir(logPath), name))
+				}
+			}
+			continue
+		}
+		inode, err := strconv.ParseUint(num, 10, 64)
+		if err != nil || live[inode] {
+			continue
+		}
+		os.Remove(filepath.Join(indexDir(logPath), name))
+	}
+}
+
+// sortedKeys is m's keys in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,520 @@
+package events
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Predicate is a parsed `--where` expression over an event's fields.
+//
+// Filter answers exact matches on type and agent; the questions a diagnostic
+// actually asks ("which spawns failed", "which merges took over a minute")
+// are about details, and every such caller used to read the whole log and
+// test the details map by hand. The grammar is small on purpose:
+//
+//	expr  = or
+//	or    = and { ("or" | "||") and }
+//	and   = unary { ("and" | "&&") unary }
+//	unary = ("not" | "!") unary | "(" expr ")" | field op value
+//	op    = "=" | "==" | "!=" | "<" | "<=" | ">" | ">=" | "~" | "!~"
+//
+// A field is an envelope key — event_type (or type), agent, work_item_id (or
+// work_item), repo, timestamp, schema_version — or details.KEY, dotted for
+// nested objects. A value is a quoted string, a number, true, false, null, or
+// a bare word of letters, digits and `_.-/:+@` (`agent = cat-mg-1234`); a
+// regular expression usually needs quoting.
+//
+// Comparisons are numeric when both sides are numbers (a numeric string on
+// the event counts), by time when both sides are RFC3339 timestamps, and by
+// string otherwise; `~` is a regular-expression match. A comparison against a
+// field the event does not have is false, `!=` included, so `details.exit_code
+// != 0` is the failures and not every event without an exit code. `= null`
+// matches a missing or null field and `!= null` a present one.
+type Predicate struct {
+	root predNode
+}
+
+// predNode is one node of a parsed predicate.
+type predNode interface {
+	match(ev Event) bool
+	// blocks narrows a segment's blocks to those that can hold a match, from
+	// its postings; nil means every block can.
+	blocks(ix *segmentIndex) blockSet
+}
+
+// ParsePredicate parses a `--where` expression. An empty expression is a nil
+// Predicate, which matches everything.
+func ParsePredicate(s string) (*Predicate, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	p := &predParser{src: s}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, p.errorf("unexpected %q", p.toks[p.pos].text)
+	}
+	return &Predicate{root: root}, nil
+}
+
+// Equals is the predicate `field = value`.
+func Equals(field, value string) *Predicate {
+	return &Predicate{root: &cmpNode{field: canonicalField(field), op: "=", val: literal{str: value}}}
+}
+
+// And matches events every non-nil predicate matches.
+func And(ps ...*Predicate) *Predicate {
+	return joinPredicates(ps, func(a, b predNode) predNode { return &andNode{a, b} })
+}
+
+// Or matches events any of the predicates matches. A nil predicate matches
+// everything, so it makes the whole Or match everything.
+func Or(ps ...*Predicate) *Predicate {
+	for _, p := range ps {
+		if p == nil {
+			return nil
+		}
+	}
+	return joinPredicates(ps, func(a, b predNode) predNode { return &orNode{a, b} })
+}
+
+func joinPredicates(ps []*Predicate, join func(a, b predNode) predNode) *Predicate {
+	var root predNode
+	for _, p := range ps {
+		switch {
+		case p == nil:
+		case root == nil:
+			root = p.root
+		default:
+			root = join(root, p.root)
+		}
+	}
+	if root == nil {
+		return nil
+	}
+	return &Predicate{root: root}
+}
+
+// Match reports whether ev satisfies p. A nil Predicate matches every event.
+func (p *Predicate) Match(ev Event) bool {
+	return p == nil || p.root.match(ev)
+}
+
+func (p *Predicate) blocks(ix *segmentIndex) blockSet {
+	if p == nil {
+		return nil
+	}
+	return p.root.blocks(ix)
+}
+
+type andNode struct{ a, b predNode }
+
+func (n *andNode) match(ev Event) bool { return n.a.match(ev) && n.b.match(ev) }
+
+func (n *andNode) blocks(ix *segmentIndex) blockSet {
+	return n.a.blocks(ix).intersect(n.b.blocks(ix))
+}
+
+type orNode struct{ a, b predNode }
+
+func (n *orNode) match(ev Event) bool { return n.a.match(ev) || n.b.match(ev) }
+
+func (n *orNode) blocks(ix *segmentIndex) blockSet {
+	return n.a.blocks(ix).union(n.b.blocks(ix))
+}
+
+type notNode struct{ child predNode }
+
+func (n *notNode) match(ev Event) bool { return !n.child.match(ev) }
+
+// blocks cannot narrow anything: a block holding a match for the child can
+// hold events that do not match it too.
+func (n *notNode) blocks(ix *segmentIndex) blockSet { return nil }
+
+// literal is a value on the right of a comparison.
+type literal struct {
+	str   string
+	num   float64
+	isNum bool
+	// null is the literal null; str is empty.
+	null bool
+}
+
+type cmpNode struct {
+	field string
+	op    string
+	val   literal
+	re    *regexp.Regexp
+}
+
+func (n *cmpNode) match(ev Event) bool {
+	v, ok := fieldValue(ev, n.field)
+	if n.val.null {
+		present := ok && v != nil
+		switch n.op {
+		case "=", "==":
+			return !present
+		case "!=":
+			return present
+		}
+		return false
+	}
+	if !ok || v == nil {
+		return false
+	}
+	switch n.op {
+	case "~":
+		s, ok := scalarString(v)
+		return ok && n.re.MatchString(s)
+	case "!~":
+		s, ok := scalarString(v)
+		return ok && !n.re.MatchString(s)
+	}
+	c, ok := compareValue(v, n.val)
+	if !ok {
+		return false
+	}
+	switch n.op {
+	case "=", "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+// blocks narrows by the postings for an equality on an indexed field.
+func (n *cmpNode) blocks(ix *segmentIndex) blockSet {
+	if (n.op != "=" && n.op != "==") || n.val.null {
+		return nil
+	}
+	postings, indexed := ix.Postings[n.field]
+	if !indexed {
+		return nil
+	}
+	if n.val.isNum {
+		// Compared as a number, `agent = 1.0` matches "1": the posting for
+		// the literal's spelling is not all of it.
+		return nil
+	}
+	return newBlockSet(postings[n.val.str])
+}
+
+// canonicalField resolves a field's aliases.
+func canonicalField(f string) string {
+	switch f {
+	case "type":
+		return "event_type"
+	case "work_item":
+		return "work_item_id"
+	}
+	return f
+}
+
+// fieldValue looks field up on ev. ok is false when ev does not have it.
+func fieldValue(ev Event, field string) (any, bool) {
+	switch field {
+	case "event_type":
+		return ev.EventType, true
+	case "agent":
+		return ev.Agent, true
+	case "work_item_id":
+		return ev.WorkItemID, ev.WorkItemID != ""
+	case "repo":
+		return ev.Repo, ev.Repo != ""
+	case "timestamp":
+		return ev.Timestamp, true
+	case "schema_version":
+		return float64(ev.SchemaVersion), true
+	}
+	rest, ok := strings.CutPrefix(field, "details.")
+	if !ok {
+		return nil, false
+	}
+	var cur any = ev.Details
+	for _, key := range strings.Split(rest, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// scalarString is v's text when v is a string, number or bool.
+func scalarString(v any) (string, bool) {
+	switch x := v.(type) {
+	case string:
+		return x, true
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(x), true
+	}
+	return "", false
+}
+
+// compareValue orders an event's value against a literal: -1, 0 or 1. ok is
+// false when the two cannot be compared (an object against a number).
+func compareValue(v any, lit literal) (int, bool) {
+	s, ok := scalarString(v)
+	if !ok {
+		return 0, false
+	}
+	if lit.isNum {
+		f, ok := v.(float64)
+		if !ok {
+			var err error
+			if f, err = strconv.ParseFloat(s, 64); err != nil {
+				return 0, false
+			}
+		}
+		return cmpOrdered(f, lit.num), true
+	}
+	if a, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		if b, err := time.Parse(time.RFC3339Nano, lit.str); err == nil {
+			return a.Compare(b), true
+		}
+	}
+	return strings.Compare(s, lit.str), true
+}
+
+func cmpOrdered(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// predToken is one lexeme of a predicate.
+type predToken struct {
+	kind byte // 'w' word, 's' quoted string, 'o' operator, '(' or ')'
+	text string
+	at   int
+}
+
+type predParser struct {
+	src  string
+	toks []predToken
+	pos  int
+}
+
+func (p *predParser) errorf(format string, args ...any) error {
+	at := len(p.src)
+	if p.pos < len(p.toks) {
+		at = p.toks[p.pos].at
+	}
+	return fmt.Errorf("where: at offset %d: %s", at, fmt.Sprintf(format, args...))
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.-/:+@", r)
+}
+
+func (p *predParser) lex() error {
+	src := p.src
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')':
+			p.toks = append(p.toks, predToken{kind: c, text: string(c), at: i})
+			i++
+		case c == '"' || c == '\'':
+			j := i + 1
+			var b strings.Builder
+			for ; j < len(src) && src[j] != c; j++ {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+				}
+				b.WriteByte(src[j])
+			}
+			if j >= len(src) {
+				return fmt.Errorf("where: at offset %d: unterminated string", i)
+			}
+			p.toks = append(p.toks, predToken{kind: 's', text: b.String(), at: i})
+			i = j + 1
+		case strings.ContainsRune("=!<>~&|", rune(c)):
+			op := string(c)
+			if i+1 < len(src) {
+				if two := src[i : i+2]; two == "==" || two == "!=" || two == "<=" || two == ">=" || two == "!~" || two == "&&" || two == "||" {
+					op = two
+				}
+			}
+			if op == "&" || op == "|" {
+				return fmt.Errorf("where: at offset %d: %q is not an operator (use && or ||)", i, op)
+			}
+			p.toks = append(p.toks, predToken{kind: 'o', text: op, at: i})
+			i += len(op)
+		default:
+			j := i
+			for j < len(src) {
+				r := rune(src[j])
+				if r >= 0x80 || isWordRune(r) {
+					j++
+					continue
+				}
+				break
+			}
+			if j == i {
+				return fmt.Errorf("where: at offset %d: unexpected %q", i, string(c))
+			}
+			p.toks = append(p.toks, predToken{kind: 'w', text: src[i:j], at: i})
+			i = j
+		}
+	}
+	return nil
+}
+
+func (p *predParser) peekKeyword(words ...string) bool {
+	if p.pos >= len(p.toks) {
+		return false
+	}
+	t := p.toks[p.pos]
+	for _, w := range words {
+		if (t.kind == 'w' && strings.EqualFold(t.text, w)) || (t.kind == 'o' && t.text == w) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *predParser) parseOr() (predNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("or", "||") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *predParser) parseAnd() (predNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("and", "&&") {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *predParser) parseUnary() (predNode, error) {
+	if p.pos >= len(p.toks) {
+		return nil, p.errorf("expression ends early")
+	}
+	if p.peekKeyword("not", "!") {
+		p.pos++
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{child}, nil
+	}
+	if p.toks[p.pos].kind == '(' {
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.toks) || p.toks[p.pos].kind != ')' {
+			return nil, p.errorf("missing )")
+		}
+		p.pos++
+		return inner, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *predParser) parseComparison() (predNode, error) {
+	field := p.toks[p.pos]
+	if field.kind != 'w' {
+		return nil, p.errorf("expected a field, got %q", field.text)
+	}
+	name := canonicalField(field.text)
+	if !knownField(name) {
+		return nil, p.errorf("unknown field %q (want event_type, agent, work_item_id, repo, timestamp, schema_version or details.KEY)", field.text)
+	}
+	p.pos++
+	if p.pos >= len(p.toks) || p.toks[p.pos].kind != 'o' || p.toks[p.pos].text == "!" {
+		return nil, p.errorf("expected an operator after %s", field.text)
+	}
+	op := p.toks[p.pos].text
+	p.pos++
+	if p.pos >= len(p.toks) || (p.toks[p.pos].kind != 'w' && p.toks[p.pos].kind != 's') {
+		return nil, p.errorf("expected a value after %s %s", field.text, op)
+	}
+	tok := p.toks[p.pos]
+	p.pos++
+
+	n := &cmpNode{field: name, op: op, val: literal{str: tok.text}}
+	if tok.kind == 'w' {
+		switch tok.text {
+		case "null":
+			n.val = literal{null: true}
+		default:
+			if f, err := strconv.ParseFloat(tok.text, 64); err == nil {
+				n.val.num, n.val.isNum = f, true
+			}
+		}
+	}
+	switch op {
+	case "~", "!~":
+		re, err := regexp.Compile(tok.text)
+		if err != nil {
+			return nil, fmt.Errorf("where: at offset %d: %v", tok.at, err)
+		}
+		n.re = re
+	case "=", "==", "!=":
+	default:
+		if n.val.null {
+			return nil, fmt.Errorf("where: at offset %d: null only compares with = and !=", tok.at)
+		}
+	}
+	return n, nil
+}
+
+func knownField(f string) bool {
+	switch f {
+	case "event_type", "agent", "work_item_id", "repo", "timestamp", "schema_version":
+		return true
+	}
+	rest, ok := strings.CutPrefix(f, "details.")
+	return ok && rest != "" && !strings.HasSuffix(rest, ".") && !strings.Contains(rest, "..")
+}
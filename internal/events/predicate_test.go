@@ -0,0 +1,84 @@
+package events
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPredicateMatch(t *testing.T) {
+	ev := Event{
+		Timestamp:  "2026-04-25T10:00:00Z",
+		EventType:  "agent_stopped",
+		Agent:      "cat-mg-156b",
+		WorkItemID: "mg-156b",
+		Details: map[string]any{
+			"exit_code": float64(2),
+			"reason":    "killed by stallwatch",
+			"code_str":  "17",
+			"clean":     false,
+			"nested":    map[string]any{"depth": float64(3)},
+		},
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"type = agent_stopped", true},
+		{"event_type == 'agent_stopped'", true},
+		{"agent = mayor", false},
+		{"work_item = mg-156b", true},
+		{"details.exit_code != 0", true},
+		{"details.exit_code > 1 and details.exit_code <= 2", true},
+		{"details.code_str > 9", true}, // numeric, not "17" < "9"
+		{"details.clean = false", true},
+		{"details.nested.depth >= 3", true},
+		{"details.reason ~ 'stall(watch)?'", true},
+		{"details.reason !~ stall", false},
+		{"agent ~ '^cat-' && !(details.exit_code = 0)", true},
+		{"type = agent_spawned or type = agent_stopped", true},
+		{"not type = agent_stopped", false},
+		{"timestamp > 2026-04-25T09:00:00+00:00", true},
+		{"timestamp < 2026-04-25T10:00:00Z", false},
+		// A missing field fails every comparison, != included.
+		{"details.signal != 9", false},
+		{"repo != x", false},
+		{"details.signal = null", true},
+		{"details.exit_code != null", true},
+		{"details.nested = 3", false},
+	}
+	for _, tt := range tests {
+		p, err := ParsePredicate(tt.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if got := p.Match(ev); got != tt.want {
+			t.Errorf("%s: matched %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParsePredicateErrors(t *testing.T) {
+	for expr, want := range map[string]string{
+		"type =":                  "expected a value",
+		"type":                    "expected an operator",
+		"colour = red":            "unknown field",
+		"(type = a":               "missing )",
+		"type = a b":              "unexpected",
+		"details.reason ~ '('":    "missing closing )",
+		"details.x > null":        "null only compares",
+		"type = 'open":            "unterminated string",
+		"type = a & agent = b":    "not an operator",
+		"type = a and":            "ends early",
+		"details. = 1":            "unknown field",
+		"agent = mayor or or x=1": "unknown field \"or\"",
+	} {
+		_, err := ParsePredicate(expr)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: error %v, want one containing %q", expr, err, want)
+		}
+	}
+	if p, err := ParsePredicate("  "); p != nil || err != nil {
+		t.Errorf("empty expression = %v, %v; want nil, nil", p, err)
+	}
+}
@@ -0,0 +1,163 @@
+package events
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// Query selects events from the whole retained log: the live file and every
+// rotated one.
+//
+// Since and Until bound the window the way Filter's SinceMin does: events
+// strictly after Since and strictly before Until pass, and a zero bound is no
+// bound. An event whose timestamp does not parse fails a set bound. Where is
+// the rest; nil matches everything.
+type Query struct {
+	Since time.Time
+	Until time.Time
+	Where *Predicate
+}
+
+// Query is f as a Query, for callers moving from ReadFiltered to Select.
+func (f Filter) Query() Query {
+	var where *Predicate
+	if f.Type != "" {
+		where = Equals("event_type", f.Type)
+	}
+	if f.Agent != "" {
+		where = And(where, Equals("agent", f.Agent))
+	}
+	return Query{Since: f.SinceMin, Where: where}
+}
+
+// Match reports whether ev passes q.
+func (q Query) Match(ev Event) bool {
+	if !q.Since.IsZero() || !q.Until.IsZero() {
+		ts, err := time.Parse(time.RFC3339Nano, ev.Timestamp)
+		if err != nil {
+			return false
+		}
+		if !q.Since.IsZero() && !ts.After(q.Since) {
+			return false
+		}
+		if !q.Until.IsZero() && !ts.Before(q.Until) {
+			return false
+		}
+	}
+	return q.Where.Match(ev)
+}
+
+// Select calls visit for every event in path's retained log that matches q,
+// oldest file first and in file order within each — the order LogFiles
+// gives. A missing log is no events, not an error, as in ScanFile.
+//
+// Unlike ScanFile it does not read every line: each file's sidecar index (see
+// index.go) names the blocks q's time bounds and its equality predicates on
+// event_type, agent and work_item_id allow, and only those are read. Every
+// event read is still tested against all of q.
+func Select(path string, q Query, visit func(Event)) error {
+	live := make(map[uint64]bool)
+	for _, file := range LogFiles(path) {
+		inode, err := selectSegment(path, file, q, visit)
+		if err != nil {
+			return err
+		}
+		live[inode] = true
+	}
+	pruneSidecars(path, live)
+	return nil
+}
+
+// selectSegment runs q over one file of path's log and returns its inode.
+func selectSegment(logPath, file string, q Query, visit func(Event)) (uint64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Rotated away between LogFiles and here; what it held is now
+			// in the next file, already read or about to be.
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer f.Close()
+	ix, err := loadSegmentIndex(logPath, f)
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range ix.candidates(q) {
+		start, end := ix.Blocks[id].Offset, ix.end(id)
+		buf := make([]byte, end-start)
+		if _, err := f.ReadAt(buf, start); err != nil && err != io.EOF {
+			return ix.Inode, err
+		}
+		for len(buf) > 0 {
+			line := buf
+			if i := bytes.IndexByte(buf, '\n'); i >= 0 {
+				line, buf = buf[:i], buf[i+1:]
+			} else {
+				buf = nil
+			}
+			line = bytes.TrimSpace(line)
+			if len(line) == 0 {
+				continue
+			}
+			ev, perr := ParseLine(line)
+			if perr != nil {
+				fmt.Fprintf(os.Stderr, "events: skipping malformed line in %s: %v\n", file, perr)
+				continue
+			}
+			if q.Match(ev) {
+				visit(ev)
+			}
+		}
+	}
+	return ix.Inode, nil
+}
+
+// GroupCount is how many matching events share one value of the grouped
+// field.
+type GroupCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Count counts the events in path's retained log that match q, grouped by
+// field (any field a predicate can name) when it is not empty. Groups come
+// back largest first, ties by value; events without the field are counted
+// under "". total is the number of matches.
+func Count(path string, q Query, field string) (groups []GroupCount, total int, err error) {
+	if field != "" {
+		field = canonicalField(field)
+		if !knownField(field) {
+			return nil, 0, fmt.Errorf("group by: unknown field %q", field)
+		}
+	}
+	counts := make(map[string]int)
+	err = Select(path, q, func(ev Event) {
+		total++
+		if field == "" {
+			return
+		}
+		var key string
+		if v, ok := fieldValue(ev, field); ok && v != nil {
+			if s, ok := scalarString(v); ok {
+				key = s
+			} else {
+				key = valueStr(v)
+			}
+		}
+		counts[key]++
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, value := range sortedKeys(counts) {
+		groups = append(groups, GroupCount{Value: value, Count: counts[value]})
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Count > groups[j].Count })
+	return groups, total, nil
+}
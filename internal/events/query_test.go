@@ -0,0 +1,197 @@
+package events
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeHistory writes n events a minute apart from start, cycling through
+// three types and two agents, and returns them.
+func writeHistory(t *testing.T, path string, start time.Time, n int) []Event {
+	t.Helper()
+	types := []string{"agent_spawned", "agent_stopped", "mail_sent"}
+	var evs []Event
+	for i := 0; i < n; i++ {
+		ev := Event{
+			Timestamp: start.Add(time.Duration(i) * time.Minute).Format(time.RFC3339Nano),
+			EventType: types[i%3],
+			Agent:     fmt.Sprintf("cat-%d", i%2),
+			Details:   map[string]any{"exit_code": float64(i % 4)},
+		}
+		evs = append(evs, ev)
+	}
+	mustWriteEvents(t, path, evs)
+	return evs
+}
+
+func selectAll(t *testing.T, path string, q Query) []Event {
+	t.Helper()
+	var out []Event
+	if err := Select(path, q, func(ev Event) { out = append(out, ev) }); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// TestSelectAgreesWithAScan: across rotated files and the live log, the
+// indexed read returns exactly what testing every event would.
+func TestSelectAgreesWithAScan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	start := time.Date(2026, 4, 25, 0, 0, 0, 0, time.UTC)
+	var all []Event
+	all = append(all, writeHistory(t, path+".2", start, 1500)...)
+	all = append(all, writeHistory(t, path+".1", start.Add(1500*time.Minute), 1500)...)
+	all = append(all, writeHistory(t, path, start.Add(3000*time.Minute), 700)...)
+
+	where, err := ParsePredicate("type = agent_stopped and details.exit_code != 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range []Query{
+		{},
+		{Where: where},
+		{Since: start.Add(2000 * time.Minute), Until: start.Add(3100 * time.Minute)},
+		{Since: start.Add(3500 * time.Minute), Where: Equals("agent", "cat-1")},
+		{Where: Or(Equals("type", "mail_sent"), Equals("agent", "nobody"))},
+	} {
+		var want []Event
+		for _, ev := range all {
+			if q.Match(ev) {
+				want = append(want, ev)
+			}
+		}
+		// Twice: once building the indexes, once reading them back.
+		for pass := 0; pass < 2; pass++ {
+			got := selectAll(t, path, q)
+			if len(got) != len(want) {
+				t.Fatalf("%+v pass %d: %d events, want %d", q, pass, len(got), len(want))
+			}
+			for i := range got {
+				if got[i].Timestamp != want[i].Timestamp || got[i].EventType != want[i].EventType {
+					t.Fatalf("%+v pass %d: event %d is %+v, want %+v", q, pass, i, got[i], want[i])
+				}
+			}
+		}
+	}
+	entries, _ := os.ReadDir(indexDir(path))
+	if len(entries) != 3 {
+		t.Errorf("%d sidecars, want one per file", len(entries))
+	}
+}
+
+// TestIndexSkipsBlocksThatCannotMatch: a type that appears once, and a
+// window late in the log, each need only the blocks that can hold them. The
+// rare event is stamped an hour in, so its block is outside the window too.
+func TestIndexSkipsBlocksThatCannotMatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	start := time.Date(2026, 4, 25, 0, 0, 0, 0, time.UTC)
+	writeHistory(t, path, start, 3*indexBlockEvents)
+	mustWriteEvents(t, path, []Event{{Timestamp: start.Add(time.Hour).Format(time.RFC3339Nano), EventType: "rare", Agent: "x"}})
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	ix, err := loadSegmentIndex(path, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ix.Blocks) != 4 {
+		t.Fatalf("%d blocks, want 4", len(ix.Blocks))
+	}
+	if got := ix.candidates(Query{Where: Equals("event_type", "rare")}); fmt.Sprint(got) != "[3]" {
+		t.Errorf("blocks for the rare type = %v, want [3]", got)
+	}
+	late := start.Add(time.Duration(2*indexBlockEvents+10) * time.Minute)
+	if got := ix.candidates(Query{Since: late}); fmt.Sprint(got) != "[2]" {
+		t.Errorf("blocks after %s = %v, want [2]", late, got)
+	}
+	if got := ix.candidates(Query{Where: Equals("event_type", "never")}); len(got) != 0 {
+		t.Errorf("blocks for an absent type = %v, want none", got)
+	}
+}
+
+// TestIndexFollowsRotation: the live log's index is kept by inode, so after
+// a rotation it serves events.log.1, the new live log gets its own, and
+// indexes of files rotated away are dropped.
+func TestIndexFollowsRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	start := time.Date(2026, 4, 25, 0, 0, 0, 0, time.UTC)
+	writeHistory(t, path, start, 100)
+	if got := len(selectAll(t, path, Query{})); got != 100 {
+		t.Fatalf("%d events, want 100", got)
+	}
+	// Grown past what was indexed: the new lines are read too.
+	writeHistory(t, path, start.Add(100*time.Minute), 10)
+	if got := len(selectAll(t, path, Query{})); got != 110 {
+		t.Fatalf("%d events after appending, want 110", got)
+	}
+
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	writeHistory(t, path, start.Add(200*time.Minute), 5)
+	if got := len(selectAll(t, path, Query{})); got != 115 {
+		t.Fatalf("%d events after rotating, want 115", got)
+	}
+	if err := os.Remove(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(selectAll(t, path, Query{})); got != 5 {
+		t.Fatalf("%d events after dropping the rotated file, want 5", got)
+	}
+	if entries, _ := os.ReadDir(indexDir(path)); len(entries) != 1 {
+		t.Errorf("%d sidecars left, want only the live log's", len(entries))
+	}
+}
+
+// TestReplacedFileIsReindexed: a sidecar whose file was replaced by another
+// with the same inode but different contents is not trusted.
+func TestReplacedFileIsReindexed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	start := time.Date(2026, 4, 25, 0, 0, 0, 0, time.UTC)
+	writeHistory(t, path, start, 50)
+	selectAll(t, path, Query{})
+
+	// Truncate in place: same inode, new history.
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	writeHistory(t, path, start.Add(24*time.Hour), 60)
+	got := selectAll(t, path, Query{})
+	if len(got) != 60 || got[0].Timestamp != start.Add(24*time.Hour).Format(time.RFC3339Nano) {
+		t.Errorf("%d events from %s, want 60 from the new history", len(got), got[0].Timestamp)
+	}
+}
+
+func TestCountGroupsLargestFirst(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	mustWriteEvents(t, path, []Event{
+		{EventType: "a", Agent: "mayor"},
+		{EventType: "a", Agent: "cat-1"},
+		{EventType: "a", Agent: "cat-1"},
+		{EventType: "b", Agent: "cat-1"},
+		{EventType: "a", Agent: "cat-2", Details: map[string]any{"n": float64(1)}},
+	})
+	groups, total, err := Count(path, Query{Where: Equals("type", "a")}, "agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 4 || fmt.Sprint(groups) != "[{cat-1 2} {cat-2 1} {mayor 1}]" {
+		t.Errorf("total %d, groups %v; want 4, [{cat-1 2} {cat-2 1} {mayor 1}]", total, groups)
+	}
+	groups, _, err = Count(path, Query{}, "details.n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(groups) != "[{ 4} {1 1}]" {
+		t.Errorf("groups by details.n = %v, want the four without it under \"\"", groups)
+	}
+	if _, _, err := Count(path, Query{}, "colour"); err == nil {
+		t.Error("grouping by an unknown field did not fail")
+	}
+}
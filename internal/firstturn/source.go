@@ -151,18 +151,30 @@ func ReadEvidence(logPath string, agents []Agent, now time.Time, lookback time.D
 	}
 	// The live log AND the rotated chunks that reach back into the window. A
 	// read of the live file alone is a read of however much of the window
-	// happens to have survived the last rotation (mg-9d55).
+	// happens to have survived the last rotation (mg-9d55). Select reads
+	// every retained chunk, and its index skips what is older than the
+	// earliest spawn or of another type; files is what the coverage check
+	// below needs.
+	if len(anchors) == 0 {
+		return out, ""
+	}
 	files := events.LogFilesCovering(logPath, floor)
-	// ScanFile rather than ReadFiltered: this is an aggregate over a
-	// potentially multi-day window of a log that runs to tens of MB, and
-	// materialising every matching event to count them is the pattern
-	// events.ReadFiltered's own doc comment tells callers not to use.
-	visit := func(ev events.Event) {
-		switch ev.EventType {
-		case "scheduler_fire_delivered", "scheduler_fire_completed":
-		default:
-			return
+	var since time.Time
+	for _, anchor := range anchors {
+		if since.IsZero() || anchor.Before(since) {
+			since = anchor
 		}
+	}
+	q := events.Query{
+		// Since is exclusive; an event at the very instant of a spawn is
+		// that incarnation's.
+		Since: since.Add(-time.Nanosecond),
+		Where: events.Or(
+			events.Equals("event_type", "scheduler_fire_delivered"),
+			events.Equals("event_type", "scheduler_fire_completed"),
+		),
+	}
+	visit := func(ev events.Event) {
 		to, _ := ev.Details["to"].(string)
 		if to == "" {
 			return
@@ -191,14 +203,12 @@ func ReadEvidence(logPath string, agents []Agent, now time.Time, lookback time.D
 		}
 		out[to] = e
 	}
-	for _, p := range files {
-		// A chunk that exists and cannot be read is a HOLE in the window, not
-		// an empty one. It leaves as blindness for the same reason a missing
-		// live log does: the alternative is a short count that looks like a
-		// measurement.
-		if err := events.ScanFile(p, visit); err != nil {
-			return nil, "scheduler event log unreadable: " + err.Error()
-		}
+	// A chunk that exists and cannot be read is a HOLE in the window, not an
+	// empty one. It leaves as blindness for the same reason a missing live
+	// log does: the alternative is a short count that looks like a
+	// measurement.
+	if err := events.Select(logPath, q, visit); err != nil {
+		return nil, "scheduler event log unreadable: " + err.Error()
 	}
 	// Coverage: the oldest record the scan could actually see. The files are
 	// append-ordered and chronologically contiguous, so it is the first record
@@ -111,6 +111,12 @@ type Sample struct {
 	// separate work from none. The core figures are then quantisation noise
 	// rather than a measurement, and must not be read as "nothing is running".
 	Unresolvable string `json:"unresolvable,omitempty"`
+
+	// Subtrees is the cores each of the reader's Split pids and its
+	// descendants used over the window, keyed by that pid. A process under
+	// two of them counts for the nearer. A Split pid that used nothing is
+	// present at 0; one that is gone is absent.
+	Subtrees map[int]float64 `json:"subtrees,omitempty"`
 }
 
 // Resolved reports whether the sample's numbers are a measurement at all.
@@ -203,6 +209,10 @@ type Reader struct {
 	// Roots are the pids whose subtrees count as fleet. Normally one entry,
 	// pogod's pid.
 	Roots []int
+	// Split are pids whose subtrees are also reported one by one, in
+	// Sample.Subtrees: the agents, so a view can say which of them the fleet's
+	// cores are going to. They change nothing about FleetCores.
+	Split []int
 	// Window overrides DefaultWindow.
 	Window time.Duration
 	// Source overrides the detected process-table source. Zero value means
@@ -285,18 +295,31 @@ func (r *Reader) Read(ctx context.Context) (Sample, error) {
 		// difference quantises to zero over a window this short, so the cores
 		// figures would read as an idle host whatever the host is doing.
 		// Returning the reason instead is the whole point of the field.
-		return Sample{
+		s := Sample{
 			Time:         t1,
 			Window:       elapsed,
 			Cores:        r.numCores(),
 			LoadAvg1:     r.load(),
 			Source:       src.Name,
 			Unresolvable: reason,
-		}, nil
+		}
+		r.record(s)
+		return s, nil
 	}
 
 	fleetPIDs, attributed := fleetSubtree(second, r.Roots)
 
+	owner := splitOwners(second, r.Split)
+	var subtreeCPU map[int]time.Duration
+	if len(r.Split) > 0 {
+		subtreeCPU = make(map[int]time.Duration, len(r.Split))
+		for _, pid := range r.Split {
+			if _, ok := second[pid]; ok {
+				subtreeCPU[pid] = 0
+			}
+		}
+	}
+
 	var fleetCPU, externalCPU time.Duration
 	fleetProcs := 0
 	for pid, now := range second {
@@ -322,9 +345,12 @@ func (r *Reader) Read(ctx context.Context) (Sample, error) {
 		} else {
 			externalCPU += delta
 		}
+		if root, ok := owner[pid]; ok {
+			subtreeCPU[root] += delta
+		}
 	}
 
-	return Sample{
+	s := Sample{
 		Time:          t1,
 		Window:        elapsed,
 		Cores:         r.numCores(),
@@ -334,7 +360,47 @@ func (r *Reader) Read(ctx context.Context) (Sample, error) {
 		LoadAvg1:      r.load(),
 		Attributed:    attributed,
 		Source:        src.Name,
-	}, nil
+	}
+	if subtreeCPU != nil {
+		s.Subtrees = make(map[int]float64, len(subtreeCPU))
+		for pid, cpu := range subtreeCPU {
+			s.Subtrees[pid] = float64(cpu) / float64(elapsed)
+		}
+	}
+	r.record(s)
+	return s, nil
+}
+
+// splitOwners maps every pid in rows that is one of roots or descends from one
+// to the nearest such root. Like fleetSubtree, each walk is bounded by the
+// table size.
+func splitOwners(rows map[int]procRow, roots []int) map[int]int {
+	if len(roots) == 0 {
+		return nil
+	}
+	isRoot := make(map[int]bool, len(roots))
+	for _, pid := range roots {
+		if pid > 0 {
+			isRoot[pid] = true
+		}
+	}
+	owner := map[int]int{}
+	for pid := range rows {
+		cur, hops := pid, 0
+		for hops <= len(rows) {
+			if isRoot[cur] {
+				owner[pid] = cur
+				break
+			}
+			row, ok := rows[cur]
+			if !ok || row.ppid <= 0 || row.ppid == cur {
+				break
+			}
+			cur = row.ppid
+			hops++
+		}
+	}
+	return owner
 }
 
 // fleetSubtree returns the set of pids that are a root or descend from one.
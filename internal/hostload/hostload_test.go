@@ -464,3 +464,41 @@ func TestReadStampsTheSourceOnEverySample(t *testing.T) {
 		t.Errorf("a 1s window must resolve on this host: %s", s.Unresolvable)
 	}
 }
+
+// TestSubtreesSplitTheFleetByAgent: `pogo top` shows each agent's share, so
+// a split root is charged for its whole subtree, a nested root takes its own
+// subtree away from the one above it, an idle root reads 0 and a gone one is
+// absent — and none of it changes the fleet's total.
+func TestSubtreesSplitTheFleetByAgent(t *testing.T) {
+	procs := []proc{
+		{pid: 100, ppid: 1, before: 0, at: 0.1},   // pogod
+		{pid: 200, ppid: 100, before: 0, at: 0.5}, // agent A
+		{pid: 201, ppid: 200, before: 0, at: 2.0}, // A's compute child
+		{pid: 300, ppid: 100, before: 0, at: 0},   // agent B, idle
+		{pid: 400, ppid: 201, before: 0, at: 1.0}, // agent C, spawned under A's child
+	}
+	snap, _ := snapshots(procs, time.Second)
+	r := &Reader{
+		Roots: []int{100}, Split: []int{200, 300, 400, 999},
+		Window: time.Millisecond, snapshot: snap, loadavg: func() float64 { return 0 }, cores: 10,
+	}
+	s, err := r.Read(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pid, want := range map[int]float64{200: 2.5, 300: 0, 400: 1.0} {
+		got, ok := s.Subtrees[pid]
+		if !ok || !near(got, want) {
+			t.Errorf("Subtrees[%d] = %.2f (present %v), want %.2f", pid, got, ok, want)
+		}
+	}
+	if _, ok := s.Subtrees[999]; ok {
+		t.Error("a split pid that is not running has a subtree")
+	}
+	if !near(s.FleetCores, 3.6) {
+		t.Errorf("FleetCores = %.2f, want 3.6 — splitting must not change the total", s.FleetCores)
+	}
+	if read(t, procs, []int{100}).Subtrees != nil {
+		t.Error("a reader with no Split reported subtrees")
+	}
+}
@@ -0,0 +1,50 @@
+package hostload
+
+import (
+	"os"
+
+	"github.com/drellem2/pogo/internal/metrics"
+)
+
+// The host half of pogod's /metrics (user-022).
+//
+// A sample takes a window to read, so none is taken for a scrape. The gauges
+// hold the last FLEET sample someone else took — the dispatch gate, the
+// refinery's gate-contention check, wedgewatch — and
+// pogo_host_sample_timestamp_seconds says how old it is. A sample over some
+// other subtree (the progress watch's worker roots) attributes a different
+// set of processes and is not recorded.
+var (
+	samplesTotal = metrics.NewCounter("pogo_host_samples_total",
+		"Fleet host-load samples taken, by whether the host could resolve them.", "resolved")
+	gaugeHostCores = metrics.NewGauge("pogo_host_cores",
+		"Logical cores on the host, as of the last fleet sample.")
+	gaugeFleetCores = metrics.NewGauge("pogo_host_fleet_cores",
+		"Cores the fleet's process subtree used over the last resolved fleet sample.")
+	gaugeExternalCores = metrics.NewGauge("pogo_host_external_cores",
+		"Cores everything outside the fleet used over the last resolved fleet sample.")
+	gaugeFleetProcs = metrics.NewGauge("pogo_host_fleet_procs",
+		"Fleet processes that used CPU over the last resolved fleet sample.")
+	gaugeLoadAvg1 = metrics.NewGauge("pogo_host_load1",
+		"The host's 1-minute load average at the last fleet sample. Context only; see internal/hostload.")
+	gaugeSampleTime = metrics.NewGauge("pogo_host_sample_timestamp_seconds",
+		"Unix time of the last fleet sample.")
+)
+
+// record publishes s if r is the fleet reader, rooted at this process alone.
+func (r *Reader) record(s Sample) {
+	if len(r.Roots) != 1 || r.Roots[0] != os.Getpid() {
+		return
+	}
+	gaugeHostCores.Set(float64(s.Cores))
+	gaugeLoadAvg1.Set(s.LoadAvg1)
+	gaugeSampleTime.Set(float64(s.Time.UnixNano()) / 1e9)
+	if !s.Resolved() {
+		samplesTotal.Inc("false")
+		return
+	}
+	samplesTotal.Inc("true")
+	gaugeFleetCores.Set(s.FleetCores)
+	gaugeExternalCores.Set(s.ExternalCores)
+	gaugeFleetProcs.Set(float64(s.FleetProcs))
+}
@@ -0,0 +1,34 @@
+package hostload
+
+import (
+	"os"
+	"testing"
+)
+
+// Only the fleet reading — rooted at this process — is published; a reading
+// over some other subtree attributes different processes and must not
+// overwrite it.
+func TestOnlyTheFleetSampleIsRecorded(t *testing.T) {
+	self := os.Getpid()
+	read(t, []proc{
+		{pid: self, ppid: 1, before: 0, at: 0},
+		{pid: self + 1, ppid: self, before: 0, at: 2},
+		{pid: self + 2, ppid: 1, before: 0, at: 0.5},
+	}, []int{self})
+	if got := gaugeFleetCores.Value(); !near(got, 2) {
+		t.Errorf("pogo_host_fleet_cores = %.2f, want 2", got)
+	}
+	if got := gaugeExternalCores.Value(); !near(got, 0.5) {
+		t.Errorf("pogo_host_external_cores = %.2f, want 0.5", got)
+	}
+	if got := gaugeHostCores.Value(); got != 10 {
+		t.Errorf("pogo_host_cores = %v, want 10", got)
+	}
+
+	read(t, []proc{
+		{pid: 100, ppid: 1, before: 0, at: 7},
+	}, []int{100})
+	if got := gaugeFleetCores.Value(); !near(got, 2) {
+		t.Errorf("a worker-subtree sample overwrote the fleet's: pogo_host_fleet_cores = %.2f", got)
+	}
+}
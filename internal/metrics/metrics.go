@@ -0,0 +1,474 @@
+// Package metrics is pogod's /metrics endpoint: counters, gauges and
+// histograms rendered in the Prometheus text exposition format (version
+// 0.0.4), with no client library behind them (user-022).
+//
+// Two kinds of metric live here, and the difference matters to whoever adds
+// one:
+//
+//   - Counters and histograms are RECORDED at the moment something happens —
+//     a crew agent respawned, a gate finished, a merge landed. They are
+//     package-level vars in the package that owns the event, created with
+//     NewCounter/NewHistogram, and they count from zero at each pogod start,
+//     which is what Prometheus's rate() and increase() expect.
+//   - Gauges that describe current state — agents by lifecycle state, the
+//     refinery queue, schedule streaks — are COLLECTED at scrape time by a
+//     function registered with SetGaugeFunc. They read the live object, so
+//     there is no second copy of the state to drift from the first.
+//
+// Names follow Prometheus convention: a pogo_ prefix, the subsystem, a unit
+// suffix (_seconds, _cores) and _total on counters. Labels are for values from
+// a small, bounded set. Anything per-merge-request belongs in the event log,
+// which already has it.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry is a set of metric families rendered together.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]family
+}
+
+// family is one metric name's HELP, TYPE and samples.
+type family interface {
+	describe() (help, typ string)
+	write(w *bufio.Writer, name string)
+}
+
+// NewRegistry returns an empty registry. pogod uses Default; tests that want
+// to render a metric in isolation make their own.
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]family{}}
+}
+
+// Default is the registry pogod serves on /metrics.
+var Default = NewRegistry()
+
+// register adds f under name. A second metric recorded under the same name is
+// a programming error — two packages counting into one series — and panics at
+// init, where it cannot be missed.
+func (r *Registry) register(name string, f family) {
+	if !validName(name) {
+		panic(fmt.Sprintf("metrics: invalid metric name %q", name))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, dup := r.families[name]; dup {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	r.families[name] = f
+}
+
+// WriteText renders every family, sorted by name, in the text exposition
+// format.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	fams := make(map[string]family, len(r.families))
+	for name, f := range r.families {
+		fams[name] = f
+	}
+	r.mu.Unlock()
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		f := fams[name]
+		help, typ := f.describe()
+		fmt.Fprintf(bw, "# HELP %s %s\n", name, escapeHelp(help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", name, typ)
+		f.write(bw, name)
+	}
+	return bw.Flush()
+}
+
+// Handler serves the registry to a Prometheus scrape.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			http.Error(w, "", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+// Handler serves Default.
+func Handler() http.Handler { return Default.Handler() }
+
+// series is one labelled value set of a vector metric, keyed by its label
+// values joined with a separator no label value is expected to contain.
+type series struct {
+	values []string
+}
+
+func seriesKey(values []string) string { return strings.Join(values, "\xff") }
+
+// checkLabels panics on a label count that does not match the declaration:
+// a miscounted call site would otherwise render a series Prometheus rejects,
+// and reject the whole scrape with it.
+func checkLabels(name string, names, values []string) {
+	if len(names) != len(values) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", name, len(names), len(values)))
+	}
+}
+
+// Counter is a monotonically increasing count, optionally split by labels.
+type Counter struct {
+	name, help string
+	labels     []string
+
+	mu   sync.Mutex
+	vals map[string]*counterSeries
+}
+
+type counterSeries struct {
+	series
+	v float64
+}
+
+// NewCounter declares a counter on Default. labels names the dimensions every
+// Inc/Add must supply values for, in order.
+func NewCounter(name, help string, labels ...string) *Counter {
+	return Default.NewCounter(name, help, labels...)
+}
+
+// NewCounter declares a counter on r.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{name: name, help: help, labels: labels, vals: map[string]*counterSeries{}}
+	r.register(name, c)
+	return c
+}
+
+// Inc adds one to the series for labelValues.
+func (c *Counter) Inc(labelValues ...string) { c.Add(1, labelValues...) }
+
+// Add adds v, which must not be negative, to the series for labelValues.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	checkLabels(c.name, c.labels, labelValues)
+	if v < 0 {
+		panic(fmt.Sprintf("metrics: %s: a counter cannot go down (%v)", c.name, v))
+	}
+	key := seriesKey(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.vals[key]
+	if !ok {
+		s = &counterSeries{series: series{values: append([]string(nil), labelValues...)}}
+		c.vals[key] = s
+	}
+	s.v += v
+}
+
+// Value returns the series' current count, for tests.
+func (c *Counter) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.vals[seriesKey(labelValues)]; ok {
+		return s.v
+	}
+	return 0
+}
+
+func (c *Counter) describe() (string, string) { return c.help, "counter" }
+
+func (c *Counter) write(w *bufio.Writer, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.vals) {
+		s := c.vals[key]
+		writeSample(w, name, c.labels, s.values, "", "", s.v)
+	}
+}
+
+// Histogram counts observations into cumulative buckets, optionally split by
+// labels.
+type Histogram struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+
+	mu   sync.Mutex
+	vals map[string]*histSeries
+}
+
+type histSeries struct {
+	series
+	counts []uint64 // per bucket, not cumulative; the last is +Inf
+	sum    float64
+	count  uint64
+}
+
+// DurationBuckets are bucket bounds, in seconds, for things that take from
+// about a second to about an hour: gates, merges, index passes.
+var DurationBuckets = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600}
+
+// NewHistogram declares a histogram on Default. buckets are upper bounds in
+// increasing order; +Inf is implied.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return Default.NewHistogram(name, help, buckets, labels...)
+}
+
+// NewHistogram declares a histogram on r.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("metrics: %s: buckets out of order", name))
+	}
+	h := &Histogram{name: name, help: help, labels: labels, buckets: buckets, vals: map[string]*histSeries{}}
+	r.register(name, h)
+	return h
+}
+
+// Observe records v in the series for labelValues.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	checkLabels(h.name, h.labels, labelValues)
+	key := seriesKey(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.vals[key]
+	if !ok {
+		s = &histSeries{
+			series: series{values: append([]string(nil), labelValues...)},
+			counts: make([]uint64, len(h.buckets)+1),
+		}
+		h.vals[key] = s
+	}
+	i := sort.SearchFloat64s(h.buckets, v)
+	s.counts[i]++
+	s.sum += v
+	s.count++
+}
+
+// Count returns how many observations the series has, for tests.
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.vals[seriesKey(labelValues)]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) describe() (string, string) { return h.help, "histogram" }
+
+func (h *Histogram) write(w *bufio.Writer, name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.vals) {
+		s := h.vals[key]
+		var cum uint64
+		for i, n := range s.counts {
+			cum += n
+			le := math.Inf(1)
+			if i < len(h.buckets) {
+				le = h.buckets[i]
+			}
+			writeSample(w, name+"_bucket", h.labels, s.values, "le", formatFloat(le), float64(cum))
+		}
+		writeSample(w, name+"_sum", h.labels, s.values, "", "", s.sum)
+		writeSample(w, name+"_count", h.labels, s.values, "", "", float64(s.count))
+	}
+}
+
+// Gauge is a value that goes up and down, set by whoever owns it. Prefer
+// SetGaugeFunc for anything that can be read off a live object at scrape
+// time; a Gauge is for a reading that only exists when it is taken, such as a
+// host load sample.
+type Gauge struct {
+	name, help string
+	labels     []string
+
+	mu   sync.Mutex
+	vals map[string]*counterSeries
+}
+
+// NewGauge declares a gauge on Default.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return Default.NewGauge(name, help, labels...)
+}
+
+// NewGauge declares a gauge on r.
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{name: name, help: help, labels: labels, vals: map[string]*counterSeries{}}
+	r.register(name, g)
+	return g
+}
+
+// Set sets the series for labelValues to v.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	checkLabels(g.name, g.labels, labelValues)
+	key := seriesKey(labelValues)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	s, ok := g.vals[key]
+	if !ok {
+		s = &counterSeries{series: series{values: append([]string(nil), labelValues...)}}
+		g.vals[key] = s
+	}
+	s.v = v
+}
+
+// Value returns the series' current value, for tests.
+func (g *Gauge) Value(labelValues ...string) float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if s, ok := g.vals[seriesKey(labelValues)]; ok {
+		return s.v
+	}
+	return 0
+}
+
+func (g *Gauge) describe() (string, string) { return g.help, "gauge" }
+
+func (g *Gauge) write(w *bufio.Writer, name string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, key := range sortedKeys(g.vals) {
+		s := g.vals[key]
+		writeSample(w, name, g.labels, s.values, "", "", s.v)
+	}
+}
+
+// Emit reports one series of a collected gauge.
+type Emit func(v float64, labelValues ...string)
+
+// gaugeFunc is a gauge whose series are produced by a function at scrape
+// time.
+type gaugeFunc struct {
+	help   string
+	labels []string
+	fn     func(emit Emit)
+}
+
+// SetGaugeFunc registers (or replaces) a gauge on Default collected by fn at
+// each scrape. fn calls emit once per series.
+//
+// Unlike the New* constructors this REPLACES a registration of the same name
+// rather than panicking, because the object a gauge reads can be replaced
+// under it: pogod rebuilds its refinery when orchestration restarts, and the
+// gauge must follow the live one.
+func SetGaugeFunc(name, help string, labels []string, fn func(emit Emit)) {
+	Default.SetGaugeFunc(name, help, labels, fn)
+}
+
+// SetGaugeFunc registers (or replaces) a collected gauge on r.
+func (r *Registry) SetGaugeFunc(name, help string, labels []string, fn func(emit Emit)) {
+	if !validName(name) {
+		panic(fmt.Sprintf("metrics: invalid metric name %q", name))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if prev, ok := r.families[name]; ok {
+		if _, isFunc := prev.(*gaugeFunc); !isFunc {
+			panic(fmt.Sprintf("metrics: %s is already a recorded metric", name))
+		}
+	}
+	r.families[name] = &gaugeFunc{help: help, labels: labels, fn: fn}
+}
+
+func (g *gaugeFunc) describe() (string, string) { return g.help, "gauge" }
+
+func (g *gaugeFunc) write(w *bufio.Writer, name string) {
+	type row struct {
+		values []string
+		v      float64
+	}
+	var rows []row
+	g.fn(func(v float64, labelValues ...string) {
+		if len(labelValues) != len(g.labels) {
+			// A collector runs at scrape time, where a panic would take
+			// the endpoint down with it; a miscounted series is dropped.
+			return
+		}
+		rows = append(rows, row{append([]string(nil), labelValues...), v})
+	})
+	sort.SliceStable(rows, func(i, j int) bool {
+		return seriesKey(rows[i].values) < seriesKey(rows[j].values)
+	})
+	for _, r := range rows {
+		writeSample(w, name, g.labels, r.values, "", "", r.v)
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeSample writes one sample line. extraName/extraValue is the histogram's
+// le label, appended after the declared ones.
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraName, extraValue string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", l, escapeLabel(values[i]))
+		}
+		if extraName != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", extraName, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+
+// validName reports whether name is a legal Prometheus metric name.
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c == '_' || c == ':':
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func render(t *testing.T, r *Registry) string {
+	t.Helper()
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestWriteTextRendersTheExpositionFormat(t *testing.T) {
+	r := NewRegistry()
+	restarts := r.NewCounter("pogo_test_restarts_total", "Restarts.\nPer agent.", "agent")
+	restarts.Inc("mayor")
+	restarts.Add(2, `we"ird\name`)
+	gates := r.NewHistogram("pogo_test_gate_seconds", "Gate time.", []float64{1, 10}, "result")
+	gates.Observe(0.5, "pass")
+	gates.Observe(10, "pass")
+	gates.Observe(42, "pass")
+	r.SetGaugeFunc("pogo_test_queue", "Queue depth.", nil, func(emit Emit) { emit(3) })
+
+	want := `# HELP pogo_test_gate_seconds Gate time.
+# TYPE pogo_test_gate_seconds histogram
+pogo_test_gate_seconds_bucket{result="pass",le="1"} 1
+pogo_test_gate_seconds_bucket{result="pass",le="10"} 2
+pogo_test_gate_seconds_bucket{result="pass",le="+Inf"} 3
+pogo_test_gate_seconds_sum{result="pass"} 52.5
+pogo_test_gate_seconds_count{result="pass"} 3
+# HELP pogo_test_queue Queue depth.
+# TYPE pogo_test_queue gauge
+pogo_test_queue 3
+# HELP pogo_test_restarts_total Restarts.\nPer agent.
+# TYPE pogo_test_restarts_total counter
+pogo_test_restarts_total{agent="mayor"} 1
+pogo_test_restarts_total{agent="we\"ird\\name"} 2
+`
+	if got := render(t, r); got != want {
+		t.Errorf("WriteText:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// A gauge func follows the object it reads when that object is replaced.
+func TestSetGaugeFuncReplaces(t *testing.T) {
+	r := NewRegistry()
+	r.SetGaugeFunc("pogo_test_depth", "d", []string{"lane"}, func(emit Emit) { emit(1, "old") })
+	r.SetGaugeFunc("pogo_test_depth", "d", []string{"lane"}, func(emit Emit) {
+		emit(2, "new")
+		emit(9) // miscounted: dropped, not a panic mid-scrape
+	})
+	got := render(t, r)
+	if !strings.Contains(got, `pogo_test_depth{lane="new"} 2`) || strings.Contains(got, "old") || strings.Contains(got, " 9\n") {
+		t.Errorf("after replacement:\n%s", got)
+	}
+}
+
+func TestRegistrationMistakesPanic(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("pogo_test_total", "t", "a")
+	for name, fn := range map[string]func(){
+		"a duplicate name":       func() { r.NewCounter("pogo_test_total", "t") },
+		"an invalid name":        func() { r.NewGauge("pogo-test", "t") },
+		"a missing label value":  func() { c.Inc() },
+		"a negative counter add": func() { c.Add(-1, "x") },
+		"a func over a counter":  func() { r.SetGaugeFunc("pogo_test_total", "t", nil, func(Emit) {}) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s did not panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewGauge("pogo_test_cores", "c").Set(4)
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "pogo_test_cores 4\n") {
+		t.Errorf("body:\n%s", rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("POST", "/metrics", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: %d", rec.Code)
+	}
+}
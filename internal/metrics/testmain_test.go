@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"os"
+	"testing"
+
+	"github.com/drellem2/pogo/internal/testsandbox"
+)
+
+// sandbox is the package's private, CHECKED envelope (internal/testsandbox).
+// The registry is in-memory and touches no pogo state; the envelope is
+// adopted so the next test here, and anything it imports, inherits it.
+var sandbox *testsandbox.Sandbox
+
+func TestMain(m *testing.M) {
+	sb, down := testsandbox.Main("metrics")
+	sandbox = sb
+
+	code := m.Run()
+
+	down()
+	os.Exit(code)
+}
+
+func TestSandboxIsVerified(t *testing.T) {
+	testsandbox.Verify(t, sandbox)
+}
@@ -0,0 +1,256 @@
+package cgroup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// cpuPeriod is the cpu.max period, in microseconds: the kernel default.
+const cpuPeriod = 100000
+
+// controllers are the ones every group is limited by.
+var controllers = []string{"cpu", "memory", "pids"}
+
+// Limits are what one group may use. A zero field is no limit.
+type Limits struct {
+	// Cores is the CPU bandwidth, in cores: cpu.max's quota is Cores periods
+	// per period.
+	Cores int `json:"cores,omitempty"`
+	// MemoryBytes is memory.max.
+	MemoryBytes int64 `json:"memory_bytes,omitempty"`
+	// Pids is pids.max.
+	Pids int `json:"pids,omitempty"`
+}
+
+// String renders l for logs.
+func (l Limits) String() string {
+	mem := "max"
+	if l.MemoryBytes > 0 {
+		mem = fmt.Sprintf("%dMiB", l.MemoryBytes>>20)
+	}
+	pids := "max"
+	if l.Pids > 0 {
+		pids = strconv.Itoa(l.Pids)
+	}
+	cores := "max"
+	if l.Cores > 0 {
+		cores = strconv.Itoa(l.Cores)
+	}
+	return fmt.Sprintf("cpu %s cores, memory %s, pids %s", cores, mem, pids)
+}
+
+// files renders l as the interface files it is written to.
+func (l Limits) files() [][2]string {
+	cpu, mem, pids := "max", "max", "max"
+	if l.Cores > 0 {
+		cpu = strconv.Itoa(l.Cores * cpuPeriod)
+	}
+	cpu += " " + strconv.Itoa(cpuPeriod)
+	if l.MemoryBytes > 0 {
+		mem = strconv.FormatInt(l.MemoryBytes, 10)
+	}
+	if l.Pids > 0 {
+		pids = strconv.Itoa(l.Pids)
+	}
+	return [][2]string{{"cpu.max", cpu}, {"memory.max", mem}, {"pids.max", pids}}
+}
+
+// Manager is the delegated subtree groups are created in.
+type Manager struct {
+	root string
+}
+
+// Root is the subtree's directory.
+func (m *Manager) Root() string { return m.root }
+
+// open is Open without the filesystem check: it enables the controllers in
+// root and checks they took.
+func open(root string) (*Manager, error) {
+	if !filepath.IsAbs(root) {
+		return nil, fmt.Errorf("cgroup root %q is not absolute", root)
+	}
+	root = filepath.Clean(root)
+	if err := os.Mkdir(root, 0o755); err != nil && !os.IsExist(err) {
+		return nil, fmt.Errorf("cgroup root: %w", err)
+	}
+	have, err := readFields(filepath.Join(root, "cgroup.controllers"))
+	if err != nil {
+		return nil, fmt.Errorf("cgroup root %s: %w", root, err)
+	}
+	var missing []string
+	for _, c := range controllers {
+		if !have[c] {
+			missing = append(missing, c)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("cgroup root %s: controllers %s are not delegated to it (enable them in the parent's cgroup.subtree_control)",
+			root, strings.Join(missing, ", "))
+	}
+	enable := "+" + strings.Join(controllers, " +")
+	if err := writeFile(filepath.Join(root, "cgroup.subtree_control"), enable); err != nil {
+		return nil, fmt.Errorf("cgroup root %s: enable %s: %w (a cgroup with processes of its own cannot delegate; pick a directory pogod is not in)",
+			root, enable, err)
+	}
+	return &Manager{root: root}, nil
+}
+
+// Group is one process's cgroup, from Create until the process has started.
+type Group struct {
+	// Path is the group's directory.
+	Path string
+	dir  *os.File
+}
+
+// Create makes (or reuses) the group name under the root and sets its limits.
+// The caller attaches it to the command it starts and closes it once the
+// command has started.
+func (m *Manager) Create(name string, l Limits) (*Group, error) {
+	if name == "" || strings.ContainsAny(name, "/") || name == "." || name == ".." {
+		return nil, fmt.Errorf("cgroup name %q is not a directory name", name)
+	}
+	path := filepath.Join(m.root, name)
+	if err := os.Mkdir(path, 0o755); err != nil && !os.IsExist(err) {
+		return nil, fmt.Errorf("cgroup %s: %w", name, err)
+	}
+	for _, f := range l.files() {
+		if err := writeFile(filepath.Join(path, f[0]), f[1]); err != nil {
+			os.Remove(path)
+			return nil, fmt.Errorf("cgroup %s: %s: %w", name, f[0], err)
+		}
+	}
+	dir, err := os.Open(path)
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("cgroup %s: %w", name, err)
+	}
+	return &Group{Path: path, dir: dir}, nil
+}
+
+// Close releases the group's directory once the process is in it. The group
+// itself stays until Remove. Safe on nil.
+func (g *Group) Close() error {
+	if g == nil || g.dir == nil {
+		return nil
+	}
+	err := g.dir.Close()
+	g.dir = nil
+	return err
+}
+
+// Usage is what one group is using against its limits, as read from its
+// interface files. A limit of 0 is none.
+type Usage struct {
+	Name string `json:"name"`
+	// CPUSeconds is CPU consumed since the group was created, and
+	// ThrottledSeconds how long its processes were held back by cpu.max — the
+	// number that says the limit is doing something.
+	CPUSeconds       float64 `json:"cpu_seconds"`
+	ThrottledSeconds float64 `json:"throttled_seconds"`
+	CPUCores         float64 `json:"cpu_max_cores,omitempty"`
+	MemoryBytes      int64   `json:"memory_bytes"`
+	MemoryMaxBytes   int64   `json:"memory_max_bytes,omitempty"`
+	Pids             int     `json:"pids"`
+	PidsMax          int     `json:"pids_max,omitempty"`
+}
+
+// Usage reads every group under the root, by name.
+func (m *Manager) Usage() ([]Usage, error) {
+	entries, err := os.ReadDir(m.root)
+	if err != nil {
+		return nil, err
+	}
+	var out []Usage
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		out = append(out, readUsage(filepath.Join(m.root, e.Name())))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// readUsage reads one group's interface files. A file that cannot be read
+// leaves its fields zero: a group can be removed between the listing and the
+// read, and one missing number is no reason to drop the others.
+func readUsage(path string) Usage {
+	u := Usage{Name: filepath.Base(path)}
+	if stat, err := readKeyed(filepath.Join(path, "cpu.stat")); err == nil {
+		u.CPUSeconds = float64(stat["usage_usec"]) / 1e6
+		u.ThrottledSeconds = float64(stat["throttled_usec"]) / 1e6
+	}
+	if data, err := os.ReadFile(filepath.Join(path, "cpu.max")); err == nil {
+		if f := strings.Fields(string(data)); len(f) == 2 {
+			quota, err1 := strconv.ParseFloat(f[0], 64)
+			period, err2 := strconv.ParseFloat(f[1], 64)
+			if err1 == nil && err2 == nil && period > 0 {
+				u.CPUCores = quota / period
+			}
+		}
+	}
+	u.MemoryBytes = readInt(filepath.Join(path, "memory.current"))
+	u.MemoryMaxBytes = readInt(filepath.Join(path, "memory.max"))
+	u.Pids = int(readInt(filepath.Join(path, "pids.current")))
+	u.PidsMax = int(readInt(filepath.Join(path, "pids.max")))
+	return u
+}
+
+// readInt reads a one-number interface file; "max" and anything unreadable
+// are 0.
+func readInt(path string) int64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	return n
+}
+
+// readKeyed reads a flat-keyed interface file such as cpu.stat.
+func readKeyed(path string) (map[string]int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]int64{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if f := strings.Fields(line); len(f) == 2 {
+			if n, err := strconv.ParseInt(f[1], 10, 64); err == nil {
+				out[f[0]] = n
+			}
+		}
+	}
+	return out, nil
+}
+
+// readFields reads a space-separated list file such as cgroup.controllers.
+func readFields(path string) (map[string]bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]bool{}
+	for _, f := range strings.Fields(string(data)) {
+		out[f] = true
+	}
+	return out, nil
+}
+
+// writeFile writes one value to an interface file. Not os.WriteFile: an
+// interface file exists once its group does, and must not be created.
+func writeFile(path, value string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(value)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
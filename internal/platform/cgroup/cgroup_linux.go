@@ -0,0 +1,93 @@
+//go:build linux
+
+package cgroup
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Open returns the Manager for the delegated subtree at root, creating root if
+// it is missing. It fails unless root is on a cgroup v2 filesystem, writable,
+// and can hand the cpu, memory and pids controllers to its children.
+func Open(root string) (*Manager, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(filepath.Dir(filepath.Clean(root)), &st); err != nil {
+		return nil, fmt.Errorf("cgroup root %s: %w", root, err)
+	}
+	if st.Type != unix.CGROUP2_SUPER_MAGIC {
+		return nil, fmt.Errorf("cgroup root %s: not on a cgroup v2 filesystem", root)
+	}
+	return open(root)
+}
+
+// Attach makes cmd start inside g. Call before Start, after anything that
+// replaces cmd.SysProcAttr; nil does nothing.
+func (g *Group) Attach(cmd *exec.Cmd) {
+	if g == nil || g.dir == nil {
+		return
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(g.dir.Fd())
+}
+
+// removeWait bounds how long Remove waits for a killed group to empty.
+const removeWait = 2 * time.Second
+
+// Remove kills whatever is still running in the group at path and deletes it.
+// The process it was made for has exited by then, so anything left is
+// something it started and walked away from — the stray build processes the
+// limit was for. A group that is already gone is not an error.
+func Remove(path string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	// cgroup.kill is Linux 5.14; on older kernels the rmdir below fails with
+	// EBUSY while anything is left, and the group stays for the operator.
+	_ = writeFile(filepath.Join(path, "cgroup.kill"), "1")
+	deadline := time.Now().Add(removeWait)
+	for {
+		err := os.Remove(path)
+		if err == nil || os.IsNotExist(err) {
+			return nil
+		}
+		if !errors.Is(err, syscall.EBUSY) || time.Now().After(deadline) {
+			return fmt.Errorf("remove cgroup %s: %w", path, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// HostMemory is the host's total memory in bytes, from /proc/meminfo, or 0
+// when it cannot be read.
+func HostMemory() int64 {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if f := strings.Fields(sc.Text()); len(f) >= 2 && f[0] == "MemTotal:" {
+			kb, err := strconv.ParseInt(f[1], 10, 64)
+			if err != nil {
+				return 0
+			}
+			return kb << 10
+		}
+	}
+	return 0
+}
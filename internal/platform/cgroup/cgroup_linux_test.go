@@ -0,0 +1,48 @@
+//go:build linux
+
+package cgroup
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestAGroupHoldsItsProcess runs a real process in a real group, where this
+// host has a cgroup v2 root the test may delegate from: set POGO_TEST_CGROUP
+// to a writable directory on it. Skipped otherwise — most CI runners and
+// containers have none.
+func TestAGroupHoldsItsProcess(t *testing.T) {
+	parent := os.Getenv("POGO_TEST_CGROUP")
+	if parent == "" {
+		t.Skip("POGO_TEST_CGROUP is not set")
+	}
+	m, err := Open(filepath.Join(parent, "pogo-test"))
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() { os.Remove(m.Root()) })
+
+	g, err := m.Create("proc", Limits{Cores: 1, MemoryBytes: 256 << 20, Pids: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("cat", "/proc/self/cgroup")
+	g.Attach(cmd)
+	out, err := cmd.Output()
+	g.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "/pogo-test/proc") {
+		t.Errorf("the process ran in %q, not the group", out)
+	}
+	if err := Remove(g.Path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(g.Path); !os.IsNotExist(err) {
+		t.Error("the group was not removed")
+	}
+}
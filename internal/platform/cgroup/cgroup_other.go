@@ -0,0 +1,23 @@
+//go:build !linux
+
+package cgroup
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// Open always fails: cgroups are Linux's.
+func Open(root string) (*Manager, error) {
+	return nil, fmt.Errorf("cgroup root %s: cgroups are not supported on %s", root, runtime.GOOS)
+}
+
+// Attach does nothing; no Group exists off Linux.
+func (g *Group) Attach(cmd *exec.Cmd) {}
+
+// Remove does nothing; no group exists off Linux.
+func Remove(path string) error { return nil }
+
+// HostMemory is 0: not read off Linux.
+func HostMemory() int64 { return 0 }
@@ -0,0 +1,136 @@
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeRoot lays out a directory shaped like a delegated cgroup v2 subtree, so
+// the bookkeeping can be tested on hosts with no cgroup pogod may write.
+// Interface files are never created by the code under test, so each group's
+// are laid out here too.
+func fakeRoot(t *testing.T, controllers string, groups ...string) string {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "pogo")
+	files := map[string]string{"cgroup.controllers": controllers, "cgroup.subtree_control": ""}
+	write(t, root, files)
+	for _, g := range groups {
+		write(t, filepath.Join(root, g), map[string]string{
+			"cpu.max": "max 100000", "memory.max": "max", "pids.max": "max",
+		})
+	}
+	return root
+}
+
+func write(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func read(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// TestOpenNeedsTheControllers: a root whose parent has not handed it the
+// cpu, memory and pids controllers cannot enforce anything, and says which
+// are missing.
+func TestOpenNeedsTheControllers(t *testing.T) {
+	root := fakeRoot(t, "cpu pids hugetlb")
+	if _, err := open(root); err == nil || !strings.Contains(err.Error(), "memory") {
+		t.Fatalf("open = %v, want an error naming memory", err)
+	}
+
+	root = fakeRoot(t, "cpuset cpu io memory pids")
+	m, err := open(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, filepath.Join(m.Root(), "cgroup.subtree_control")); got != "+cpu +memory +pids" {
+		t.Errorf("subtree_control = %q", got)
+	}
+	if _, err := open("relative/pogo"); err == nil {
+		t.Error("a relative root was accepted")
+	}
+}
+
+// TestCreateWritesTheLimits: the limits land in the group's interface files,
+// and a zero limit is the kernel's "max".
+func TestCreateWritesTheLimits(t *testing.T) {
+	m, err := open(fakeRoot(t, "cpu memory pids", "polecat-a", "polecat-b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := m.Create("polecat-a", Limits{Cores: 3, MemoryBytes: 4 << 30, Pids: 1536})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	for file, want := range map[string]string{"cpu.max": "300000 100000", "memory.max": "4294967296", "pids.max": "1536"} {
+		if got := read(t, filepath.Join(g.Path, file)); got != want {
+			t.Errorf("%s = %q, want %q", file, got, want)
+		}
+	}
+
+	g2, err := m.Create("polecat-b", Limits{Cores: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g2.Close()
+	if got := read(t, filepath.Join(g2.Path, "memory.max")); got != "max" {
+		t.Errorf("unlimited memory.max = %q, want max", got)
+	}
+
+	for _, bad := range []string{"", "..", "a/b"} {
+		if _, err := m.Create(bad, Limits{}); err == nil {
+			t.Errorf("Create(%q) succeeded", bad)
+		}
+	}
+}
+
+// TestUsageReadsEachGroup: usage is read per group, by name, with limits in
+// the units they were set in.
+func TestUsageReadsEachGroup(t *testing.T) {
+	root := fakeRoot(t, "cpu memory pids")
+	write(t, filepath.Join(root, "polecat-a"), map[string]string{
+		"cpu.stat":       "usage_usec 2500000\nuser_usec 2000000\nnr_throttled 4\nthrottled_usec 750000\n",
+		"cpu.max":        "300000 100000\n",
+		"memory.current": "1048576\n",
+		"memory.max":     "max\n",
+		"pids.current":   "12\n",
+		"pids.max":       "1536\n",
+	})
+	write(t, filepath.Join(root, "gate-x"), map[string]string{"pids.current": "1\n"})
+	m, err := open(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := m.Usage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Name != "gate-x" || got[1].Name != "polecat-a" {
+		t.Fatalf("Usage = %+v, want gate-x then polecat-a", got)
+	}
+	want := Usage{Name: "polecat-a", CPUSeconds: 2.5, ThrottledSeconds: 0.75, CPUCores: 3,
+		MemoryBytes: 1 << 20, Pids: 12, PidsMax: 1536}
+	if got[1] != want {
+		t.Errorf("polecat-a = %+v, want %+v", got[1], want)
+	}
+	if got[0].Pids != 1 || got[0].CPUSeconds != 0 {
+		t.Errorf("a group with missing files = %+v", got[0])
+	}
+}
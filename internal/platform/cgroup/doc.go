@@ -0,0 +1,27 @@
+// Package cgroup places the processes pogod starts — polecats and refinery
+// quality gates — in cgroups of their own under a delegated cgroup v2 subtree,
+// with cpu.max, memory.max and pids.max set from the worker budget (user-013).
+//
+// The worker budget (internal/agent/workerbudget.go) was advice: a number in
+// $POGO_WORKER_CORES that a self-parallelising toolchain was free to ignore,
+// and the host gate only noticed after the box was full. A cgroup makes the
+// same number a limit the kernel holds the worker to, whatever its toolchain
+// decides: a build that forks eleven compilers gets its share of the CPU
+// between all eleven, not eleven shares.
+//
+// # What it needs
+//
+// A cgroup v2 hierarchy, and a directory in it pogod may write — a delegated
+// subtree, e.g. a systemd unit with Delegate=yes, or a directory the operator
+// created and chowned — whose parent has the cpu, memory and pids controllers
+// enabled. Open checks all of that once, at boot, so a host that cannot
+// enforce says so there rather than at every spawn.
+//
+// Each group is created as a child of that root and the process is started
+// directly inside it (clone3 with CLONE_INTO_CGROUP), so there is no window in
+// which it or its first children run unlimited. Remove kills whatever is left
+// in a group and deletes it once the process it was made for has exited.
+//
+// Nothing here exists off Linux: Open fails, and with no Manager nothing is
+// placed anywhere.
+package cgroup
@@ -0,0 +1,30 @@
+// Package sandbox confines a process pogod starts — a polecat, a crew agent, a
+// refinery quality gate — to a profile, the Linux half of the sandbox design
+// (docs/design/sandbox-design.md, user-012). Without one a spawned process has
+// pogod's full ambient authority: nothing stops a polecat acting on a
+// prompt-injected ticket body from writing ~/.ssh or ~/.zshrc, and the
+// worktree it was given is a convention, not a boundary.
+//
+// A Profile names a Mode and the trees it is confined to:
+//
+//   - none: no confinement, the historic behaviour and the default.
+//   - landlock: Landlock filesystem rules. The process may write only its
+//     Root, the profile's Writable trees, $TMPDIR and /dev, and may read only
+//     those, the system trees (/usr, /etc, …), the directories on its PATH and
+//     the profile's ReadOnly trees. Everything else — the rest of the home
+//     directory above all — cannot even be read.
+//   - namespaces: new user, mount and network namespaces. The home directory
+//     and the ReadOnly trees are remounted read-only except for the Root and the
+//     Writable trees, and the process has a network of its own with nothing but
+//     loopback in it.
+//
+// Both are applied by re-executing the calling binary with Arg, which confines
+// itself and then execs the real command, so the process pogod sees — its pid,
+// its PTY, its exit — is the command's own. Every binary that calls Apply must
+// therefore dispatch Arg to Run at the very top of main, before flag parsing,
+// the way pogod dispatches its PTY holder.
+//
+// On other platforms every mode but none is refused at Apply: a profile that
+// asked for confinement and silently got none would be worse than a spawn that
+// fails.
+package sandbox
@@ -0,0 +1,210 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Arg is the hidden first argument that makes a binary the sandbox wrapper
+// rather than itself. See Run.
+const Arg = "__sandbox"
+
+// Mode selects how a Profile confines its process.
+type Mode string
+
+const (
+	ModeNone       Mode = "none"
+	ModeLandlock   Mode = "landlock"
+	ModeNamespaces Mode = "namespaces"
+)
+
+// ParseMode reads a `sandbox` value from config or prompt frontmatter. Empty
+// is ModeNone. An unknown value is an error rather than none, so a typo in a
+// profile name cannot quietly leave an agent unconfined.
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.TrimSpace(s)); m {
+	case "", ModeNone:
+		return ModeNone, nil
+	case ModeLandlock, ModeNamespaces:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown sandbox profile %q (want none, landlock or namespaces)", s)
+}
+
+// Confines reports whether m confines at least as much as o: none, then
+// landlock, then namespaces, which adds a read-only home and no network to
+// what landlock allows.
+func (m Mode) Confines(o Mode) bool {
+	return m.rank() >= o.rank()
+}
+
+func (m Mode) rank() int {
+	switch m {
+	case ModeLandlock:
+		return 1
+	case ModeNamespaces:
+		return 2
+	}
+	return 0
+}
+
+// Profile is what one confined process may touch.
+type Profile struct {
+	Mode Mode `json:"mode"`
+	// Root is the one tree the process owns: a polecat's worktree, a crew
+	// agent's working directory, a gate's checkout. Required for every mode
+	// but none.
+	Root string `json:"root"`
+	// ReadOnly are further trees the process may read and not write. Under
+	// landlock they are added to what is readable; under namespaces they are
+	// remounted read-only alongside the home directory.
+	ReadOnly []string `json:"read_only,omitempty"`
+	// Writable are further trees the process may write, e.g. the harness's own
+	// state directory (~/.claude) or a build cache.
+	Writable []string `json:"writable,omitempty"`
+	// Hidden are files the process must not read even inside a tree it may:
+	// pogod's API key and the human's token, either of which makes whoever
+	// holds it the human to pogod (user-021). Under namespaces each is covered
+	// with an empty file; landlock cannot carve a file out of a tree it
+	// allows, so a landlock profile that would expose one fails instead.
+	Hidden []string `json:"hidden,omitempty"`
+}
+
+// Enabled reports whether p confines anything.
+func (p Profile) Enabled() bool {
+	return p.Mode != "" && p.Mode != ModeNone
+}
+
+// String is the mode and root, for logs and `pogo agent diagnose`.
+func (p Profile) String() string {
+	if !p.Enabled() {
+		return string(ModeNone)
+	}
+	return fmt.Sprintf("%s (root %s)", p.Mode, p.Root)
+}
+
+// spec is what Apply hands the wrapper: the profile with every path made
+// absolute, and the command to exec once confined.
+type spec struct {
+	Profile
+	// Home is the home directory the namespaces mode makes read-only.
+	Home string `json:"home"`
+	// GitDirs are the parts of the worktree's git directories outside Root
+	// that a commit in the worktree writes to.
+	GitDirs []string `json:"git_dirs,omitempty"`
+	// GitCommon is the repository's common git directory, which the process
+	// may read and not write beyond GitDirs. See gitDirs.
+	GitCommon string   `json:"git_common,omitempty"`
+	Path      string   `json:"path"`
+	Args      []string `json:"args"`
+}
+
+// resolve builds p's spec for running path with args.
+func resolve(p Profile, path string, args []string) (spec, error) {
+	if p.Root == "" {
+		return spec{}, fmt.Errorf("sandbox %s: no root to confine to", p.Mode)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return spec{}, fmt.Errorf("sandbox %s: %w", p.Mode, err)
+	}
+	s := spec{Home: home, Path: path, Args: args}
+	s.Mode = p.Mode
+	if s.Root, err = absPath(p.Root, home); err != nil {
+		return spec{}, err
+	}
+	for _, ro := range p.ReadOnly {
+		abs, err := absPath(ro, home)
+		if err != nil {
+			return spec{}, err
+		}
+		s.ReadOnly = append(s.ReadOnly, abs)
+	}
+	for _, w := range p.Writable {
+		abs, err := absPath(w, home)
+		if err != nil {
+			return spec{}, err
+		}
+		s.Writable = append(s.Writable, abs)
+	}
+	for _, h := range p.Hidden {
+		abs, err := absPath(h, home)
+		if err != nil {
+			return spec{}, err
+		}
+		s.Hidden = append(s.Hidden, abs)
+	}
+	s.GitDirs, s.GitCommon = gitDirs(s.Root)
+	return s, nil
+}
+
+// exposed returns the first of hidden that lies in one of trees, and that
+// tree, or "" when none does.
+func exposed(hidden, trees []string) (file, tree string) {
+	for _, h := range hidden {
+		for _, t := range trees {
+			if t == "" {
+				continue
+			}
+			if rel, err := filepath.Rel(t, h); err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+				return h, t
+			}
+		}
+	}
+	return "", ""
+}
+
+// absPath expands a leading ~ and makes p absolute.
+func absPath(p, home string) (string, error) {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		p = filepath.Join(home, strings.TrimPrefix(p, "~"))
+	}
+	if !filepath.IsAbs(p) {
+		return "", fmt.Errorf("sandbox path %q is not absolute", p)
+	}
+	return filepath.Clean(p), nil
+}
+
+// commonWritable are the entries of a common git directory a commit in a
+// linked worktree writes: new objects, the branch it moves and that branch's
+// reflog. packed-refs is listed for the rare in-place update; a rewrite of it
+// goes through a lock file in the common directory and is refused.
+var commonWritable = []string{"objects", "refs", "logs", "packed-refs"}
+
+// gitDirs returns what a linked worktree at root needs of the git directories
+// it keeps outside itself. write is what its commits write to: its own
+// directory (<repo>/.git/worktrees/<name>) and commonWritable in the common
+// directory. common is the common directory itself, readable and otherwise
+// not writable under every mode: its config and hooks/ are obeyed by every
+// git command run in the repository — the refinery's rebase and merge,
+// pogod's, the human's — so a confined process that could plant a hook or set
+// core.hooksPath there would be running unconfined on the next one. Both
+// empty for a root that is not a linked worktree.
+func gitDirs(root string) (write []string, common string) {
+	data, err := os.ReadFile(filepath.Join(root, ".git"))
+	if err != nil {
+		return nil, ""
+	}
+	line := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(line, "gitdir:") {
+		return nil, ""
+	}
+	dir := strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	write = []string{filepath.Clean(dir)}
+	if data, err := os.ReadFile(filepath.Join(dir, "commondir")); err == nil {
+		common = strings.TrimSpace(string(data))
+		if !filepath.IsAbs(common) {
+			common = filepath.Join(dir, common)
+		}
+		common = filepath.Clean(common)
+		for _, name := range commonWritable {
+			write = append(write, filepath.Join(common, name))
+		}
+	}
+	return write, common
+}
@@ -0,0 +1,425 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Apply rewrites cmd to run under p: cmd starts this binary as the wrapper,
+// which confines itself and execs what cmd used to run. Call it after cmd's
+// Dir, Env and SysProcAttr are set and before Start; the namespaces mode adds
+// to SysProcAttr rather than replacing it. A disabled profile leaves cmd
+// alone.
+//
+// It checks what it can before the process exists — landlock support in the
+// running kernel above all — so an unusable profile fails the spawn here
+// rather than as a child that dies on its first line of output.
+func Apply(cmd *exec.Cmd, p Profile) error {
+	if !p.Enabled() {
+		return nil
+	}
+	if cmd.Err != nil {
+		return cmd.Err
+	}
+	if _, err := ParseMode(string(p.Mode)); err != nil {
+		return err
+	}
+	if p.Mode == ModeLandlock {
+		if _, err := landlockABI(); err != nil {
+			return err
+		}
+	}
+	s, err := resolve(p, cmd.Path, cmd.Args)
+	if err != nil {
+		return err
+	}
+	if p.Mode == ModeLandlock {
+		if err := landlockHides(s, envPath(cmd.Env)); err != nil {
+			return err
+		}
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("sandbox %s: find wrapper: %w", p.Mode, err)
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	cmd.Path = self
+	cmd.Args = []string{self, Arg, string(data)}
+
+	if p.Mode == ModeNamespaces {
+		if cmd.SysProcAttr == nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		attr := cmd.SysProcAttr
+		attr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWNET
+		// The same ids inside as out: files keep their owners, and a harness
+		// that refuses to run as root is not run as root.
+		uid, gid := os.Getuid(), os.Getgid()
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}}
+		// Which means the wrapper would lose the namespace's capabilities at
+		// its own exec; it keeps CAP_SYS_ADMIN for the mounts, and drops it
+		// before exec'ing the command.
+		attr.AmbientCaps = append(attr.AmbientCaps, unix.CAP_SYS_ADMIN)
+	}
+	return nil
+}
+
+// Run is the wrapper: args is what follows Arg. It confines this process to
+// the profile and execs the command, so it returns only on failure, with the
+// exit status the caller should exit with.
+func Run(args []string) int {
+	// Landlock domains, no_new_privs and ambient capabilities belong to a
+	// thread; the one that sets them must be the one that execs.
+	runtime.LockOSThread()
+
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "pogo sandbox: want one argument, got %d\n", len(args))
+		return 2
+	}
+	var s spec
+	if err := json.Unmarshal([]byte(args[0]), &s); err != nil {
+		fmt.Fprintf(os.Stderr, "pogo sandbox: %v\n", err)
+		return 2
+	}
+	var err error
+	switch s.Mode {
+	case ModeLandlock:
+		err = confineLandlock(s)
+	case ModeNamespaces:
+		err = confineNamespaces(s)
+	default:
+		err = fmt.Errorf("unknown mode %q", s.Mode)
+	}
+	if err == nil {
+		err = syscall.Exec(s.Path, s.Args, os.Environ())
+	}
+	// Said on the agent's own terminal, where whoever looks at why it exited
+	// at once will read it.
+	fmt.Fprintf(os.Stderr, "pogo sandbox %s: %v\n", s.Mode, err)
+	return 126
+}
+
+// landlockABI returns the running kernel's Landlock ABI version.
+func landlockABI() (int, error) {
+	v, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return 0, fmt.Errorf("sandbox landlock: not available in this kernel (%w); use the namespaces profile or none", errno)
+	}
+	return int(v), nil
+}
+
+// Landlock access rights, grouped the way the rules grant them.
+const (
+	llRead = unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_READ_DIR
+	// llFile is what a rule on a regular file (rather than a directory) may
+	// carry; the kernel refuses the directory rights there.
+	llFile = unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE | unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	llDev = unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_DIR | unix.LANDLOCK_ACCESS_FS_TRUNCATE
+)
+
+// llHandled is every filesystem right ABI abi knows, except ioctl on devices:
+// a harness's terminal ioctls (window size, raw mode) on a /dev/tty it opens
+// itself must keep working, and the rest of /dev is not what this protects.
+func llHandled(abi int) uint64 {
+	h := uint64(unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_READ_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_DIR | unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR | unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG | unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_FIFO | unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM)
+	if abi >= 2 {
+		h |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		h |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	return h
+}
+
+// systemReadOnly are the trees every confined process may read and execute
+// from: without them there is no shell, no libc and no certificates.
+var systemReadOnly = []string{
+	"/usr", "/bin", "/sbin", "/lib", "/lib32", "/lib64", "/libx32",
+	"/etc", "/opt", "/nix", "/snap", "/proc", "/sys", "/run", "/var/lib",
+}
+
+// confineLandlock restricts this thread, and so what it execs, to s.
+func confineLandlock(s spec) error {
+	abi, err := landlockABI()
+	if err != nil {
+		return err
+	}
+	handled := llHandled(abi)
+	attr := unix.LandlockRulesetAttr{Access_fs: handled}
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("create ruleset: %w", errno)
+	}
+	ruleset := int(fd)
+	defer unix.Close(ruleset)
+
+	// Checked again here, against the PATH the command really runs with.
+	if err := landlockHides(s, os.Getenv("PATH")); err != nil {
+		return err
+	}
+	read, write := s.landlockTrees(os.Getenv("PATH"))
+	for _, p := range read {
+		if err := llAddRule(ruleset, p, llRead&handled); err != nil {
+			return err
+		}
+	}
+	for _, p := range write {
+		if err := llAddRule(ruleset, p, handled); err != nil {
+			return err
+		}
+	}
+	if err := llAddRule(ruleset, "/dev", llDev&handled); err != nil {
+		return err
+	}
+
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("no_new_privs: %w", err)
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, uintptr(ruleset), 0, 0); errno != 0 {
+		return fmt.Errorf("restrict self: %w", errno)
+	}
+	return nil
+}
+
+// envPath is PATH in env, an exec.Cmd's environment; a nil env is this
+// process's.
+func envPath(env []string) string {
+	if env == nil {
+		return os.Getenv("PATH")
+	}
+	path := ""
+	for _, kv := range env {
+		if v, ok := strings.CutPrefix(kv, "PATH="); ok {
+			path = v
+		}
+	}
+	return path
+}
+
+// landlockTrees returns the trees a landlock profile lets s read, and those
+// it lets s write, given the PATH the command runs with.
+func (s spec) landlockTrees(path string) (read, write []string) {
+	read = append([]string(nil), systemReadOnly...)
+	read = append(read, filepath.SplitList(path)...)
+	// The command's own directory, and where a symlinked launcher
+	// (~/.local/bin/claude) really lives.
+	read = append(read, filepath.Dir(s.Path))
+	if real, err := filepath.EvalSymlinks(s.Path); err == nil {
+		read = append(read, filepath.Dir(real))
+	}
+	read = append(read, s.ReadOnly...)
+	read = append(read, s.GitCommon)
+	write = []string{s.Root, os.TempDir(), "/dev/shm"}
+	write = append(write, s.GitDirs...)
+	write = append(write, s.Writable...)
+	return read, write
+}
+
+// landlockHides refuses a landlock profile under which one of s.Hidden would
+// be readable with PATH path: landlock only adds access, so a file inside an
+// allowed tree cannot be taken back out of it.
+func landlockHides(s spec, path string) error {
+	read, write := s.landlockTrees(path)
+	if file, tree := exposed(s.Hidden, append(read, write...)); file != "" {
+		return fmt.Errorf("sandbox landlock: %s would be readable through %s; move that tree out of the profile or use namespaces", file, tree)
+	}
+	return nil
+}
+
+// llAddRule allows access beneath path. A path that does not exist is
+// skipped: the system list names trees not every distribution has, and a
+// declared tree that is absent grants nothing to miss.
+func llAddRule(ruleset int, path string, access uint64) error {
+	if path == "" || !filepath.IsAbs(path) {
+		return nil
+	}
+	fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		if errors.Is(err, unix.ENOENT) || errors.Is(err, unix.EACCES) {
+			return nil
+		}
+		return fmt.Errorf("open %s: %w", path, err)
+	}
+	defer unix.Close(fd)
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return fmt.Errorf("stat %s: %w", path, err)
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+		access &= llFile
+	}
+	rule := unix.LandlockPathBeneathAttr{Allowed_access: access, Parent_fd: int32(fd)}
+	if _, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(ruleset), unix.LANDLOCK_RULE_PATH_BENEATH,
+		uintptr(unsafe.Pointer(&rule)), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("allow %s: %w", path, errno)
+	}
+	return nil
+}
+
+// confineNamespaces runs in the new user, mount and network namespaces Apply
+// asked for. It makes the home directory, the common git directory and the
+// ReadOnly trees read-only except for the Root, the Writable trees and what a
+// commit writes of the worktree's git directories, brings up loopback, and
+// gives up the capability it did that with.
+func confineNamespaces(s spec) error {
+	// Nothing done here may reach the mount namespace the mounts came from.
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+	// Bind every tree onto itself first, so each is a mount of its own; a
+	// writable tree bound after the home directory sits on top of it and
+	// keeps its write access when the home directory's mount goes read-only,
+	// and a ReadOnly tree bound after that does the same inside a writable
+	// one.
+	// The common git directory is bound before the writable trees too: the
+	// parts of it a commit writes are among them, and must sit on top.
+	readOnly := []string{s.Home}
+	if s.GitCommon != "" {
+		readOnly = append(readOnly, s.GitCommon)
+	}
+	for _, p := range readOnly {
+		if err := bindSelf(p); err != nil {
+			return err
+		}
+	}
+	writable := append([]string{s.Root}, s.GitDirs...)
+	writable = append(writable, s.Writable...)
+	for _, p := range writable {
+		if err := bindSelf(p); err != nil {
+			return err
+		}
+	}
+	for _, p := range s.ReadOnly {
+		if err := bindSelf(p); err != nil {
+			return err
+		}
+		readOnly = append(readOnly, p)
+	}
+	for _, p := range readOnly {
+		if err := remountReadOnly(p); err != nil {
+			return err
+		}
+	}
+	// Last, so nothing bound above sits on top of them.
+	for _, p := range s.Hidden {
+		if err := hide(p); err != nil {
+			return err
+		}
+	}
+	if err := loopbackUp(); err != nil {
+		return err
+	}
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil {
+		return fmt.Errorf("drop capabilities: %w", err)
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("no_new_privs: %w", err)
+	}
+	return nil
+}
+
+// bindSelf bind-mounts path onto itself. A path that does not exist is
+// skipped, as in llAddRule.
+func bindSelf(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := unix.Mount(path, path, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind %s: %w", path, err)
+	}
+	return nil
+}
+
+// hide covers the file at path with /dev/null, read-only, so it reads as
+// empty. A path that does not exist is skipped, as in bindSelf.
+func hide(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := unix.Mount("/dev/null", path, "", unix.MS_BIND, ""); err != nil {
+		return fmt.Errorf("hide %s: %w", path, err)
+	}
+	return remountReadOnly(path)
+}
+
+// remountReadOnly makes the mount at path read-only. The flags the mount
+// already has are kept: a user namespace may not clear nosuid, nodev or
+// noexec on a mount it inherited, and a remount that omits them is refused.
+func remountReadOnly(path string) error {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("statfs %s: %w", path, err)
+	}
+	flags := uintptr(unix.MS_REMOUNT | unix.MS_BIND | unix.MS_RDONLY)
+	for stFlag, msFlag := range map[int64]uintptr{
+		unix.ST_NOSUID:     unix.MS_NOSUID,
+		unix.ST_NODEV:      unix.MS_NODEV,
+		unix.ST_NOEXEC:     unix.MS_NOEXEC,
+		unix.ST_NOATIME:    unix.MS_NOATIME,
+		unix.ST_NODIRATIME: unix.MS_NODIRATIME,
+		unix.ST_RELATIME:   unix.MS_RELATIME,
+	} {
+		if int64(st.Flags)&stFlag != 0 {
+			flags |= msFlag
+		}
+	}
+	if err := unix.Mount("", path, "", flags, ""); err != nil {
+		return fmt.Errorf("remount %s read-only: %w", path, err)
+	}
+	return nil
+}
+
+// loopbackUp brings up lo in the new network namespace, which starts with
+// only lo and with it down: a test that listens on localhost still can.
+func loopbackUp() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("loopback: %w", err)
+	}
+	defer unix.Close(fd)
+	ifr, err := unix.NewIfreq("lo")
+	if err != nil {
+		return fmt.Errorf("loopback: %w", err)
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifr); err != nil {
+		return fmt.Errorf("loopback flags: %w", err)
+	}
+	ifr.SetUint16(ifr.Uint16() | unix.IFF_UP)
+	if err := unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifr); err != nil {
+		return fmt.Errorf("loopback up: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseMode(t *testing.T) {
+	for in, want := range map[string]Mode{"": ModeNone, "none": ModeNone, " landlock ": ModeLandlock, "namespaces": ModeNamespaces} {
+		if got, err := ParseMode(in); err != nil || got != want {
+			t.Errorf("ParseMode(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseMode("landlok"); err == nil {
+		t.Error("a misspelt profile parsed; it must not fall back to none")
+	}
+}
+
+// probe is the shell run under a profile: it writes inside root, then tries
+// to read and write outside it, and reports what it managed.
+const probe = `echo in > "$1/in"; cat "$2/secret" >/dev/null 2>&1 && echo READ; (echo x > "$2/out") 2>/dev/null && echo WROTE; cat /proc/self/net/dev`
+
+func runProbe(t *testing.T, p Profile, outside string) string {
+	t.Helper()
+	cmd := exec.Command("sh", "-c", probe, "sh", p.Root, outside)
+	cmd.Env = append(os.Environ(), "TMPDIR="+p.Root)
+	if err := Apply(cmd, p); err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Args[1] != Arg {
+		t.Fatalf("Apply left the command as %v, want it run through the wrapper", cmd.Args)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if strings.Contains(string(out), "operation not permitted") || strings.Contains(err.Error(), "operation not permitted") {
+			t.Skipf("this host does not allow the profile: %v: %s", err, out)
+		}
+		t.Fatalf("%v: %s", err, out)
+	}
+	if _, err := os.Stat(filepath.Join(p.Root, "in")); err != nil {
+		t.Errorf("the root was not writable: %v\n%s", err, out)
+	}
+	return string(out)
+}
+
+func setupTrees(t *testing.T) (root, outside string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	root = filepath.Join(home, "work")
+	outside = filepath.Join(home, "dotfiles")
+	for _, d := range []string{root, outside} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return root, outside
+}
+
+// TestLandlockConfinesTheHome: under landlock the root is writable and the
+// rest of the home directory can be neither read nor written.
+func TestLandlockConfinesTheHome(t *testing.T) {
+	if _, err := landlockABI(); err != nil {
+		t.Skip(err)
+	}
+	root, outside := setupTrees(t)
+	out := runProbe(t, Profile{Mode: ModeLandlock, Root: root}, outside)
+	if strings.Contains(out, "READ") || strings.Contains(out, "WROTE") {
+		t.Errorf("landlock let the probe outside its root:\n%s", out)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "out")); err == nil {
+		t.Error("a file was written outside the root")
+	}
+
+	// Declared read-only: readable now, still not writable.
+	out = runProbe(t, Profile{Mode: ModeLandlock, Root: root, ReadOnly: []string{outside}}, outside)
+	if !strings.Contains(out, "READ") || strings.Contains(out, "WROTE") {
+		t.Errorf("a declared read-only tree should read and not write:\n%s", out)
+	}
+}
+
+// TestNamespacesMakeTheHomeReadOnly: under namespaces the home directory is
+// readable but not writable outside the root, and there is no network but
+// loopback.
+func TestNamespacesMakeTheHomeReadOnly(t *testing.T) {
+	root, outside := setupTrees(t)
+	out := runProbe(t, Profile{Mode: ModeNamespaces, Root: root}, outside)
+	if !strings.Contains(out, "READ") || strings.Contains(out, "WROTE") {
+		t.Errorf("the home directory should read and not write:\n%s", out)
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if name, _, ok := strings.Cut(strings.TrimSpace(line), ":"); ok && !strings.Contains(name, "|") && name != "lo" {
+			t.Errorf("interface %q is visible in the sandbox's network namespace:\n%s", name, out)
+		}
+	}
+}
+
+// TestHiddenFilesStayUnread: the API key and the human's token make their
+// reader the human to pogod, so a profile hides them even inside a tree it
+// lets the process read — or, under landlock, which cannot, refuses to run.
+func TestHiddenFilesStayUnread(t *testing.T) {
+	root, outside := setupTrees(t)
+	secret := filepath.Join(outside, "secret")
+
+	// The home directory is readable under namespaces, the secret with it
+	// but for Hidden.
+	cmd := exec.Command("cat", secret)
+	if err := Apply(cmd, Profile{Mode: ModeNamespaces, Root: root, Hidden: []string{secret}}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := cmd.CombinedOutput()
+	if err != nil && strings.Contains(string(got), "operation not permitted") {
+		t.Skipf("this host does not allow the profile: %v: %s", err, got)
+	}
+	if strings.Contains(string(got), "key") {
+		t.Errorf("a hidden file was read under namespaces: %q", got)
+	}
+
+	if _, err := landlockABI(); err != nil {
+		return
+	}
+	// Landlock lets the process write the temp directory, which here holds
+	// the test's home; a real $POGO_HOME is not under it.
+	t.Setenv("TMPDIR", root)
+	if err := Apply(exec.Command("true"), Profile{Mode: ModeLandlock, Root: root, ReadOnly: []string{outside}, Hidden: []string{secret}}); err == nil {
+		t.Error("landlock applied a profile whose read-only tree holds a hidden file")
+	}
+	if err := Apply(exec.Command("true"), Profile{Mode: ModeLandlock, Root: root, Hidden: []string{secret}}); err != nil {
+		t.Errorf("landlock refused a profile that does not expose the hidden file: %v", err)
+	}
+}
+
+func TestApplyNeedsARoot(t *testing.T) {
+	cmd := exec.Command("true")
+	if err := Apply(cmd, Profile{Mode: ModeNamespaces}); err == nil {
+		t.Error("a profile with no root applied")
+	}
+	if err := Apply(cmd, Profile{}); err != nil || cmd.Args[0] != "true" {
+		t.Errorf("the none profile changed the command: %v, %v", cmd.Args, err)
+	}
+}
+
+// linkedWorktree makes a repository with one commit under dir and adds a
+// linked worktree to it, as a polecat's is. Returns the worktree and the
+// repository's common git directory.
+func linkedWorktree(t *testing.T, dir string) (wt, common string) {
+	t.Helper()
+	repo := filepath.Join(dir, "repo")
+	wt = filepath.Join(dir, "cat")
+	for _, args := range [][]string{
+		{"init", "-q", repo},
+		{"-C", repo, "-c", "user.email=a@b", "-c", "user.name=a", "commit", "-q", "--allow-empty", "-m", "x"},
+		{"-C", repo, "worktree", "add", "-q", wt},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Skipf("git %v: %v: %s", args, err, out)
+		}
+	}
+	common, _ = filepath.EvalSymlinks(filepath.Join(repo, ".git"))
+	return wt, common
+}
+
+// TestGitDirsOfALinkedWorktree: a worktree's commits land in the repository
+// it was added from, so the parts of it a commit writes come with its root,
+// and the rest of it is only read.
+func TestGitDirsOfALinkedWorktree(t *testing.T) {
+	wt, common := linkedWorktree(t, t.TempDir())
+	write, gotCommon := gitDirs(wt)
+	if c, _ := filepath.EvalSymlinks(gotCommon); c != common {
+		t.Errorf("common dir = %s, want %s", gotCommon, common)
+	}
+	var rel []string
+	for _, w := range write {
+		r, err := filepath.Rel(gotCommon, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel = append(rel, r)
+	}
+	want := "worktrees/cat objects refs logs packed-refs"
+	if got := strings.Join(rel, " "); got != want {
+		t.Errorf("writable git dirs = %s, want %s", got, want)
+	}
+	if w, c := gitDirs(filepath.Dir(common)); w != nil || c != "" {
+		t.Error("a main checkout has no git dirs outside itself")
+	}
+}
+
+// TestConfinedWorktreeCannotPlantAHook: a confined process in a linked
+// worktree can commit, but cannot write the common directory's hooks or
+// config, which the next unconfined git command there would obey.
+func TestConfinedWorktreeCannotPlantAHook(t *testing.T) {
+	for _, mode := range []Mode{ModeLandlock, ModeNamespaces} {
+		t.Run(string(mode), func(t *testing.T) {
+			if _, err := landlockABI(); err != nil && mode == ModeLandlock {
+				t.Skip(err)
+			}
+			// The repository is outside HOME, so nothing but the profile's
+			// own handling of the common directory keeps its hooks unwritable.
+			t.Setenv("HOME", t.TempDir())
+			wt, common := linkedWorktree(t, t.TempDir())
+			const script = `git -c user.email=a@b -c user.name=a commit -q --allow-empty -m confined && echo COMMITTED
+(echo 'touch owned' > "$1/hooks/pre-commit") 2>/dev/null && echo HOOK
+git config core.hooksPath /tmp 2>/dev/null && echo CONFIG
+true`
+			cmd := exec.Command("sh", "-c", script, "sh", common)
+			cmd.Dir = wt
+			cmd.Env = append(os.Environ(), "TMPDIR="+wt)
+			if err := Apply(cmd, Profile{Mode: mode, Root: wt}); err != nil {
+				t.Fatal(err)
+			}
+			out, err := cmd.CombinedOutput()
+			if err != nil {
+				if strings.Contains(string(out), "operation not permitted") || strings.Contains(err.Error(), "operation not permitted") {
+					t.Skipf("this host does not allow the profile: %v: %s", err, out)
+				}
+				t.Fatalf("%v: %s", err, out)
+			}
+			if !strings.Contains(string(out), "COMMITTED") {
+				t.Errorf("a confined worktree could not commit:\n%s", out)
+			}
+			if strings.Contains(string(out), "HOOK") {
+				t.Errorf("a confined process wrote %s/hooks/pre-commit", common)
+			}
+			if _, err := os.Stat(filepath.Join(common, "hooks", "pre-commit")); err == nil {
+				t.Errorf("%s/hooks/pre-commit exists after the confined run", common)
+			}
+			if strings.Contains(string(out), "CONFIG") {
+				t.Errorf("a confined process set core.hooksPath in %s/config", common)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// Apply refuses every mode but none: neither landlock nor namespaces exist
+// here, and an agent that asked for a profile must not run without one.
+func Apply(_ *exec.Cmd, p Profile) error {
+	if !p.Enabled() {
+		return nil
+	}
+	return fmt.Errorf("sandbox %s: not supported on %s", p.Mode, runtime.GOOS)
+}
+
+// Run is never reached on these platforms, since Apply never produces a
+// wrapper invocation; it fails rather than run anything unconfined.
+func Run(_ []string) int {
+	fmt.Fprintf(os.Stderr, "pogo sandbox: not supported on %s\n", runtime.GOOS)
+	return 126
+}
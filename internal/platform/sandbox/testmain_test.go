@@ -0,0 +1,48 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/drellem2/pogo/internal/testsandbox"
+)
+
+// testSandbox is the package's private, CHECKED envelope (internal/testsandbox).
+// Profiles here resolve ~ against HOME, and a test that got that wrong would
+// confine a probe to — and let it write into — the developer's real home.
+var testSandbox *testsandbox.Sandbox
+
+// TestMain makes this test binary the wrapper when Apply re-executes it, as
+// pogod is, and otherwise runs the suite inside the envelope.
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == Arg {
+		os.Exit(Run(os.Args[2:]))
+	}
+	sb, down := testsandbox.Main("sandbox")
+	testSandbox = sb
+
+	code := m.Run()
+
+	down()
+	os.Exit(code)
+}
+
+// TestHomeRelativePathsResolveInTheSandbox is the positive control for the
+// envelope: a ~ in a profile lands under the throwaway root.
+func TestHomeRelativePathsResolveInTheSandbox(t *testing.T) {
+	testsandbox.Verify(t, testSandbox)
+
+	s, err := resolve(Profile{Mode: ModeLandlock, Root: "~/work", Writable: []string{"~/.claude"}}, "/bin/true", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{s.Home, s.Root, s.Writable[0]} {
+		if !testSandbox.Contains(p) {
+			t.Errorf("%s resolved outside the sandbox root %s", p, testSandbox.Root)
+		}
+	}
+	if s.Root != filepath.Join(s.Home, "work") {
+		t.Errorf("Root = %s, want %s/work", s.Root, s.Home)
+	}
+}
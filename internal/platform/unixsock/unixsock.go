@@ -0,0 +1,90 @@
+// Package unixsock creates unix sockets that are never reachable by anyone
+// their final mode does not admit.
+//
+// net.Listen("unix") creates the socket file with the process umask applied
+// to 0777, and a Chmod after it leaves a window in which that file is
+// connectable as created: under a 002 umask, by the whole group. For a socket
+// that runs commands, like the refinery's gate runner, that window is the
+// hole the mode was meant to close. Setting the umask around the bind is no
+// fix in a Go
+// program: the umask is process-wide, and every goroutine creating a file at
+// that moment would get it too.
+//
+// Listen instead binds inside a fresh directory only this user can enter,
+// sets the socket's mode there, and renames it into place. The path is
+// connectable only once it has the mode it was asked for.
+package unixsock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// Listen listens on a unix socket at path with permissions perm. A file
+// already at path is replaced, as rename replaces it; callers that must not
+// replace something check first.
+//
+// Closing the listener removes path, as net.Listen's does.
+//
+// The socket is bound at <dir>/.<6 hex>/s before the rename, which is no
+// longer than path for a file name of 9 bytes or more, so a path that fits
+// the AF_UNIX limit binds.
+func Listen(path string, perm os.FileMode) (net.Listener, error) {
+	dir, err := privateDir(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, "s")
+	ln, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	if ul, ok := ln.(*net.UnixListener); ok {
+		ul.SetUnlinkOnClose(false)
+	}
+	if err := os.Chmod(tmp, perm); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return &listener{Listener: ln, path: path}, nil
+}
+
+// privateDir makes a new 0700 directory in parent with a short random name.
+func privateDir(parent string) (string, error) {
+	for i := 0; i < 10; i++ {
+		b := make([]byte, 3)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		dir := filepath.Join(parent, "."+hex.EncodeToString(b))
+		err := os.Mkdir(dir, 0o700)
+		if err == nil {
+			return dir, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("no free directory name in %s for a socket", parent)
+}
+
+type listener struct {
+	net.Listener
+	path string
+}
+
+func (l *listener) Close() error {
+	err := l.Listener.Close()
+	os.Remove(l.path)
+	return err
+}
@@ -0,0 +1,349 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/drellem2/pogo/internal/agent"
+	"github.com/drellem2/pogo/internal/config"
+	"github.com/drellem2/pogo/internal/events"
+)
+
+// Providers declared in config (user-018).
+//
+// A harness pogo ships is a Go package — internal/claude, internal/codex —
+// because each grew detectors measured against its own TUI. Most of a
+// Provider is data all the same, and a team trying out a harness of its own
+// should not have to fork pogo to describe one. A [providers.<id>] table in
+// config.toml is that description (config.ProviderConfig); FromConfig builds
+// the Provider from it, and Configure adds the ones that build to what All and
+// Resolve know.
+//
+// The hooks a Go provider writes become screen rules. A trust rule answers one
+// dialog at startup and stops watching as soon as the ready sentinel is on
+// screen, so a task that quotes the dialog is never answered — the guard
+// codex.composerReady exists for. A modal rule answers its dialog whenever it
+// comes up, once per appearance, after the screen has matched for its idle
+// window. Both match the rendered screen (user-014), never the byte stream.
+//
+// A table that does not build is refused whole rather than run with the parts
+// that did: a harness missing its non-interactive flag or answering the wrong
+// dialog is worse than one pogo says it cannot start. An id that names a
+// built-in provider is refused the same way — a config that could replace
+// Claude's descriptor would make every fleet's behaviour depend on a file
+// nobody thinks to read.
+
+// trustScreenPoll and modalScreenPoll are how often the screen rules look at
+// the screen. The trust poll matches codex.TrustDialogPollInterval, fast
+// enough to answer before the initial nudge's idle window opens.
+const (
+	trustScreenPoll = 250 * time.Millisecond
+	modalScreenPoll = 500 * time.Millisecond
+)
+
+// trustSettle is how long a trust rule lets the dialog finish drawing before
+// answering it, as the built-in trust hooks do.
+const trustSettle = 300 * time.Millisecond
+
+// providerIDPattern is what a [providers.<id>] id may be: it is typed on the
+// command line and written in prompt frontmatter.
+var providerIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+var (
+	customMu sync.RWMutex
+	custom   []*agent.Provider
+)
+
+// configured returns the providers Configure accepted, sorted by id.
+func configured() []*agent.Provider {
+	customMu.RLock()
+	defer customMu.RUnlock()
+	return custom
+}
+
+// Configure replaces the config-declared providers with the ones defs
+// builds, and returns an error for each table it refused, in id order. Call it
+// once config is loaded and before providers are registered or resolved; a
+// process that never calls it knows only the built-in providers.
+func Configure(defs map[string]config.ProviderConfig) []error {
+	var (
+		built []*agent.Provider
+		errs  []error
+	)
+	for _, id := range sortedIDs(defs) {
+		p, err := FromConfig(defs[id])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		built = append(built, p)
+	}
+	customMu.Lock()
+	custom = built
+	customMu.Unlock()
+	return errs
+}
+
+func sortedIDs(defs map[string]config.ProviderConfig) []string {
+	ids := make([]string, 0, len(defs))
+	for id := range defs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// FromConfig builds the Provider a [providers.<id>] table declares, or returns
+// an error naming everything wrong with it.
+func FromConfig(pc config.ProviderConfig) (*agent.Provider, error) {
+	problems := append([]string(nil), pc.Errors...)
+	bad := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if !providerIDPattern.MatchString(pc.ID) {
+		bad("id must be lower-case letters, digits, '-' and '_'")
+	}
+	if slices.ContainsFunc(builtins(), func(p *agent.Provider) bool { return p.ID == pc.ID }) {
+		bad("%q is a built-in provider and cannot be redefined", pc.ID)
+	}
+	if pc.Binary == "" {
+		bad("binary is required")
+	}
+	if pc.Command == "" {
+		bad("command is required")
+	} else if _, err := agent.ExpandCommand(pc.Command, agent.CommandTemplateVars{
+		PromptFile: "prompt.md", AgentName: "agent", AgentType: "polecat", WorkDir: ".",
+	}); err != nil {
+		bad("command: %v", err)
+	}
+
+	injection := agent.PromptInjection{Flag: pc.PromptFlag, ContextFile: pc.ContextFile, ContextFileHeader: pc.ContextFileHeader}
+	kind := pc.PromptInjection
+	if kind == "" {
+		switch {
+		case pc.PromptFlag != "":
+			kind = "flag"
+		case pc.ContextFile != "":
+			kind = "context_file"
+		default:
+			kind = "env"
+		}
+	}
+	switch kind {
+	case "flag":
+		injection.Kind = agent.InjectAppendFlag
+		if pc.PromptFlag == "" {
+			bad("prompt_injection = \"flag\" needs prompt_flag")
+		}
+	case "context_file":
+		injection.Kind = agent.InjectContextFile
+		if pc.ContextFile == "" {
+			bad("prompt_injection = \"context_file\" needs context_file")
+		} else if !filepath.IsLocal(pc.ContextFile) {
+			bad("context_file %q must be a relative path inside the working directory", pc.ContextFile)
+		}
+	case "env":
+		injection.Kind = agent.InjectEnvOnly
+	default:
+		bad("prompt_injection %q is not flag, context_file or env", kind)
+	}
+
+	nudge := agent.NudgeProfile{
+		NeedsInitialNudge:     !pc.InitialPromptViaArgv,
+		InitialNudgeTimeout:   orDuration(pc.InitialNudgeTimeout, agent.DefaultNudgeProfile.InitialNudgeTimeout),
+		SubmitTerminator:      pc.SubmitTerminator,
+		SubmitDelay:           orDuration(pc.SubmitDelay, agent.DefaultNudgeProfile.SubmitDelay),
+		IdleThreshold:         orDuration(pc.IdleThreshold, agent.DefaultNudgeProfile.IdleThreshold),
+		PromptReadySentinel:   pc.ReadySentinel,
+		PromptReadyAlternates: pc.ReadyAlternates,
+	}
+	if pc.NeedsInitialNudge != nil {
+		nudge.NeedsInitialNudge = *pc.NeedsInitialNudge
+	}
+	if nudge.SubmitTerminator == "" {
+		nudge.SubmitTerminator = agent.DefaultNudgeProfile.SubmitTerminator
+	}
+
+	var size *agent.PTYSize
+	switch {
+	case pc.PTYCols == 0 && pc.PTYRows == 0:
+	case pc.PTYCols <= 0 || pc.PTYRows <= 0 || pc.PTYCols > 0xffff || pc.PTYRows > 0xffff:
+		bad("pty_cols and pty_rows must both be set, between 1 and 65535")
+	default:
+		size = &agent.PTYSize{Cols: uint16(pc.PTYCols), Rows: uint16(pc.PTYRows)}
+	}
+
+	var trust *regexp.Regexp
+	switch {
+	case pc.TrustScreen == "" && pc.TrustKeys != "":
+		bad("trust_keys needs trust_screen")
+	case pc.TrustScreen != "":
+		re, err := regexp.Compile(pc.TrustScreen)
+		if err != nil {
+			bad("trust_screen: %v", err)
+		}
+		if pc.TrustKeys == "" {
+			bad("trust_screen needs trust_keys")
+		}
+		trust = re
+	}
+
+	if len(pc.StreamFlags) > 0 && !pc.Stream {
+		bad("stream_flags needs stream = true")
+	}
+
+	var modals []modalRule
+	for _, m := range pc.Modals {
+		re, err := regexp.Compile(m.Screen)
+		switch {
+		case m.Screen == "":
+			bad("modals.%s: screen is required", m.Name)
+		case err != nil:
+			bad("modals.%s: screen: %v", m.Name, err)
+		}
+		if m.Keys == "" {
+			bad("modals.%s: keys is required", m.Name)
+		}
+		modals = append(modals, modalRule{name: m.Name, re: re, keys: m.Keys, idle: orDuration(m.Idle, config.DefaultModalIdle)})
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("provider %q: %s", pc.ID, strings.Join(problems, "; "))
+	}
+
+	p := &agent.Provider{
+		ID:                   pc.ID,
+		Binary:               pc.Binary,
+		CommandTemplate:      pc.Command,
+		ModelFlag:            pc.ModelFlag,
+		PromptInjection:      injection,
+		NonInteractiveFlags:  pc.NonInteractiveFlags,
+		InitialPromptViaArgv: pc.InitialPromptViaArgv,
+		Nudge:                nudge,
+		PTYSize:              size,
+	}
+	if pc.Stream {
+		stream := agent.NativeStream
+		stream.Flags = pc.StreamFlags
+		p.Stream = &stream
+	}
+	if trust != nil {
+		sentinels := append([]string{nudge.PromptReadySentinel}, nudge.PromptReadyAlternates...)
+		keys, budget := pc.TrustKeys, nudge.InitialNudgeTimeout
+		p.PostSpawnHook = func(a *agent.Agent) {
+			screen := func() string { return a.Screen().Text() }
+			ready := func() bool {
+				return slices.ContainsFunc(sentinels, func(s string) bool { return s != "" && a.ScreenContains(s) })
+			}
+			if watchTrustScreen(a.Done(), trust, keys, screen, ready, a.SendRaw, budget, trustScreenPoll) {
+				log.Printf("agent %s: answered %s startup dialog matching trust_screen", a.Name, p.ID)
+			}
+		}
+	}
+	if len(modals) > 0 {
+		p.SessionHook = func(ctx context.Context, a *agent.Agent) {
+			screen := func() string { return a.Screen().Text() }
+			watchModalScreens(ctx, modals, screen, a.SendRaw, func(name string) {
+				log.Printf("agent %s: dismissed %s modal %q", a.Name, p.ID, name)
+				events.Emit(context.Background(), events.Event{
+					EventType: "modal_dismissed",
+					Agent:     a.EventAgent(),
+					Details:   map[string]any{"matcher": name, "provider": p.ID},
+				})
+			}, modalScreenPoll, time.Now)
+		}
+	}
+	return p, nil
+}
+
+func orDuration(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
+
+// watchTrustScreen is a trust rule's PostSpawnHook body. It answers the
+// dialog with keys the first time screen matches re, and gives up — returning
+// false — when done closes, budget passes or ready reports the harness's
+// prompt on screen first.
+func watchTrustScreen(done <-chan struct{}, re *regexp.Regexp, keys string, screen func() string, ready func() bool, send func(string) error, budget, poll time.Duration) bool {
+	deadline := time.Now().Add(budget)
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return false
+		case <-ticker.C:
+		}
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		if ready() {
+			return false
+		}
+		if re.MatchString(screen()) {
+			time.Sleep(trustSettle)
+			if err := send(keys); err != nil {
+				log.Printf("trust_screen: sending trust_keys: %v", err)
+				return false
+			}
+			return true
+		}
+	}
+}
+
+// modalRule is one [providers.<id>.modals.<name>] table, built.
+type modalRule struct {
+	name string
+	re   *regexp.Regexp
+	keys string
+	idle time.Duration
+}
+
+// watchModalScreens is the modal rules' SessionHook body. A rule fires when
+// screen has matched it for the rule's idle window, and not again until the
+// screen has stopped matching: a dialog the keys did not close is left for a
+// human rather than typed at every poll.
+func watchModalScreens(ctx context.Context, rules []modalRule, screen func() string, send func(string) error, dismissed func(name string), poll time.Duration, now func() time.Time) {
+	since := make([]time.Time, len(rules))
+	fired := make([]bool, len(rules))
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		text, t := screen(), now()
+		for i, r := range rules {
+			if !r.re.MatchString(text) {
+				since[i], fired[i] = time.Time{}, false
+				continue
+			}
+			if since[i].IsZero() {
+				since[i] = t
+			}
+			if fired[i] || t.Sub(since[i]) < r.idle {
+				continue
+			}
+			fired[i] = true
+			if err := send(r.keys); err != nil {
+				log.Printf("modal %q: sending keys: %v", r.name, err)
+				continue
+			}
+			dismissed(r.name)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package providers
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drellem2/pogo/internal/agent"
+	"github.com/drellem2/pogo/internal/config"
+)
+
+func aiderConfig() config.ProviderConfig {
+	return config.ProviderConfig{
+		ID:                  "aider",
+		Binary:              "aider",
+		Command:             "aider --yes-always --read {{.PromptFile}}",
+		ModelFlag:           "--model",
+		NonInteractiveFlags: []string{"--yes-always"},
+		ReadySentinel:       "tokens",
+		SubmitDelay:         80 * time.Millisecond,
+		PTYCols:             120,
+		PTYRows:             40,
+		TrustScreen:         `(?i)add \.aider\* to \.gitignore`,
+		TrustKeys:           "n\r",
+		Modals:              []config.ModalRuleConfig{{Name: "update", Screen: "newer version", Keys: "n\r"}},
+		Stream:              true,
+		StreamFlags:         []string{"--json-events"},
+	}
+}
+
+// A table builds the Provider it describes, with pogo's nudge defaults for
+// what it leaves out, and Configure makes it something Resolve and All know.
+func TestFromConfigBuildsAProvider(t *testing.T) {
+	p, err := FromConfig(aiderConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != "aider" || p.Binary != "aider" || p.ModelFlag != "--model" || p.PromptInjection.Kind != agent.InjectEnvOnly {
+		t.Errorf("provider = %+v", p)
+	}
+	if n := p.Nudge; !n.NeedsInitialNudge || n.SubmitDelay != 80*time.Millisecond || n.SubmitTerminator != "\r" ||
+		n.IdleThreshold != agent.DefaultNudgeProfile.IdleThreshold || n.PromptReadySentinel != "tokens" {
+		t.Errorf("nudge = %+v", n)
+	}
+	if p.PTYSize == nil || *p.PTYSize != (agent.PTYSize{Cols: 120, Rows: 40}) {
+		t.Errorf("PTYSize = %v", p.PTYSize)
+	}
+	if p.PostSpawnHook == nil || p.SessionHook == nil {
+		t.Error("screen rules built no hooks")
+	}
+	if p.Stream == nil || len(p.Stream.Flags) != 1 || p.Stream.Decode == nil {
+		t.Errorf("Stream = %+v, want the native dialect with the table's flags", p.Stream)
+	}
+
+	t.Cleanup(func() { Configure(nil) })
+	if errs := Configure(map[string]config.ProviderConfig{"aider": aiderConfig()}); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if got, ok := Resolve("aider"); !ok || got.ID != "aider" {
+		t.Errorf("Resolve(aider) = %v, %v", got.ID, ok)
+	}
+	if all := All(); all[len(all)-1].ID != "aider" {
+		t.Errorf("All() does not end with the configured provider")
+	}
+}
+
+// Everything wrong with a table is reported at once, and a table that would
+// redefine a built-in provider is refused.
+func TestFromConfigRefusesABadTable(t *testing.T) {
+	pc := aiderConfig()
+	pc.ID = "codex"
+	pc.Command = "aider {{.Nope}}"
+	pc.PromptInjection = "flag"
+	pc.PTYRows = 0
+	pc.TrustScreen = "("
+	pc.Modals = append(pc.Modals, config.ModalRuleConfig{Name: "empty"})
+	pc.Stream = false
+	pc.Errors = []string{"submit_delay: bad"}
+	_, err := FromConfig(pc)
+	if err == nil {
+		t.Fatal("FromConfig accepted a bad table")
+	}
+	for _, want := range []string{
+		"submit_delay: bad", "built-in", "command:", "needs prompt_flag", "pty_cols and pty_rows",
+		"trust_screen:", "modals.empty: screen is required", "stream_flags needs stream", "modals.empty: keys is required",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+
+	t.Cleanup(func() { Configure(nil) })
+	if errs := Configure(map[string]config.ProviderConfig{"codex": pc}); len(errs) != 1 {
+		t.Errorf("Configure errors = %v, want one", errs)
+	}
+	if p, _ := Resolve("codex"); p.Binary != "codex" {
+		t.Errorf("a config table replaced the built-in codex provider: %+v", p)
+	}
+}
+
+// fakeScreen is a screen whose text a test sets, with a recorder for the keys
+// a rule sends.
+type fakeScreen struct {
+	mu   sync.Mutex
+	text string
+	sent []string
+}
+
+func (f *fakeScreen) set(s string) { f.mu.Lock(); f.text = s; f.mu.Unlock() }
+func (f *fakeScreen) screen() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.text
+}
+func (f *fakeScreen) send(s string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, s)
+	return nil
+}
+func (f *fakeScreen) keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.sent...)
+}
+
+// A trust rule answers its dialog once, and never once the harness's prompt
+// is up — by then the text is the task's, not a dialog's.
+func TestTrustScreenAnswersOnlyBeforeReady(t *testing.T) {
+	re := regexp.MustCompile(`(?i)trust this folder`)
+	f := &fakeScreen{text: "Do you trust this folder?"}
+	never := func() bool { return false }
+	if !watchTrustScreen(make(chan struct{}), re, "y\r", f.screen, never, f.send, time.Second, time.Millisecond) {
+		t.Fatal("the dialog was not answered")
+	}
+	if got := f.keys(); len(got) != 1 || got[0] != "y\r" {
+		t.Errorf("sent %q", got)
+	}
+
+	quoted := &fakeScreen{text: "> task: why does it ask 'do you trust this folder'?"}
+	ready := func() bool { return true }
+	if watchTrustScreen(make(chan struct{}), re, "y\r", quoted.screen, ready, quoted.send, time.Second, time.Millisecond) {
+		t.Errorf("answered a dialog quoted at the ready prompt: sent %q", quoted.keys())
+	}
+}
+
+// A modal rule fires after its idle window, once per appearance of the dialog.
+func TestModalScreenFiresOncePerAppearance(t *testing.T) {
+	f := &fakeScreen{}
+	rules := []modalRule{{name: "update", re: regexp.MustCompile("newer version"), keys: "n\r", idle: 20 * time.Millisecond}}
+	var (
+		mu        sync.Mutex
+		dismissed []string
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		watchModalScreens(ctx, rules, f.screen, f.send, func(name string) {
+			mu.Lock()
+			dismissed = append(dismissed, name)
+			mu.Unlock()
+		}, time.Millisecond, time.Now)
+	}()
+	waitKeys := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for len(f.keys()) < n {
+			if time.Now().After(deadline) {
+				t.Fatalf("sent %q, want %d dismissals", f.keys(), n)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	f.set("A newer version is available. Update? (y/n)")
+	waitKeys(1)
+	time.Sleep(50 * time.Millisecond) // still on screen: not typed at again
+	if got := f.keys(); len(got) != 1 {
+		t.Fatalf("a dialog that stayed up was answered %d times", len(got))
+	}
+	f.set("> ")
+	time.Sleep(20 * time.Millisecond)
+	f.set("A newer version is available. Update? (y/n)")
+	waitKeys(2)
+	cancel()
+	<-done
+	mu.Lock()
+	defer mu.Unlock()
+	if len(dismissed) != 2 || dismissed[0] != "update" {
+		t.Errorf("dismissed = %q", dismissed)
+	}
+}
@@ -23,7 +23,15 @@ import (
 // agent registry at startup so a provider can be resolved per-spawn — the
 // mixed-fleet capability from mg-b31b — instead of once globally. Use Resolve
 // when mapping a single id; use All when you need the complete set.
+//
+// Providers declared in config follow the built-in ones, sorted by id, once
+// Configure has accepted them.
 func All() []*agent.Provider {
+	return append(builtins(), configured()...)
+}
+
+// builtins are the providers pogo ships, in All's order.
+func builtins() []*agent.Provider {
 	return []*agent.Provider{&claude.Provider, &codex.Provider, &pi.Provider, &cursor.Provider}
 }
 
@@ -102,7 +110,9 @@ func AgentMemoryStoreIndexes(workdir string) []string {
 // Resolve maps a config provider id to its agent.Provider descriptor.
 //
 // "" and "claude" resolve to Claude (the default); "codex" resolves to Codex;
-// "pi" resolves to pi; "cursor" resolves to the Cursor CLI.
+// "pi" resolves to pi; "cursor" resolves to the Cursor CLI. Any other id
+// resolves to the [providers.<id>] table of that name, if Configure accepted
+// one.
 //
 // ok is false when id names no known provider. The returned *agent.Provider is
 // still safe to use in that case — it is the Claude fallback — so a stale or
@@ -117,7 +127,11 @@ func Resolve(id string) (provider *agent.Provider, ok bool) {
 		return &pi.Provider, true
 	case cursor.Provider.ID:
 		return &cursor.Provider, true
-	default:
-		return &claude.Provider, false
 	}
+	for _, p := range configured() {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return &claude.Provider, false
 }
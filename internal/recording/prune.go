@@ -0,0 +1,86 @@
+package recording
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Prune removes the recordings in dir that limits no longer keeps: every file
+// last written more than MaxAge before now, then the oldest of the rest until
+// what is left fits in MaxBytes. A file a Recorder in this process is still
+// writing is never removed and still counts against MaxBytes, so an agent
+// whose current session alone is over budget keeps exactly that session. It
+// returns the paths it removed.
+func Prune(dir string, limits Limits, now time.Time) ([]string, error) {
+	sessions, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	for _, s := range sessions {
+		total += s.Size
+	}
+	var removed []string
+	var errs []error
+	for _, s := range sessions {
+		if _, writing := open.Load(s.Path); writing {
+			continue
+		}
+		expired := limits.MaxAge > 0 && now.Sub(s.Modified) > limits.MaxAge
+		over := limits.MaxBytes > 0 && total > limits.MaxBytes
+		if !expired && !over {
+			continue
+		}
+		if err := os.Remove(s.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+			continue
+		}
+		total -= s.Size
+		removed = append(removed, s.Path)
+	}
+	return removed, errors.Join(errs...)
+}
+
+// SweepResult is what one Sweep did.
+type SweepResult struct {
+	// Agents is how many recordings directories the sweep looked at.
+	Agents int
+	// Removed lists every file the sweep deleted.
+	Removed []string
+	// Errors holds one entry per directory that could not be fully pruned;
+	// the sweep carries on past each.
+	Errors []string
+}
+
+// Sweep prunes the recordings of every agent under root (see Root): each
+// <root>/<name>/sessions directory, whether or not the agent is still known
+// to pogod — the recordings of a polecat long since reaped are exactly the
+// ones retention exists for.
+func Sweep(root string, limits Limits, now time.Time) (SweepResult, error) {
+	var res SweepResult
+	entries, err := os.ReadDir(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return res, nil
+	}
+	if err != nil {
+		return res, err
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		dir := DirIn(root, e.Name())
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			continue
+		}
+		res.Agents++
+		removed, err := Prune(dir, limits, now)
+		res.Removed = append(res.Removed, removed...)
+		if err != nil {
+			res.Errors = append(res.Errors, fmt.Sprintf("%s: %v", dir, err))
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,239 @@
+package recording
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Session is one recording file.
+type Session struct {
+	// ID is the file's name without Ext: the moment the session began, as
+	// 20261017T101500.000Z.
+	ID    string    `json:"id"`
+	Path  string    `json:"path"`
+	Start time.Time `json:"start"`
+	Size  int64     `json:"size"`
+	// Modified is when the file was last written, which for a finished
+	// session is near when it ended.
+	Modified time.Time `json:"modified"`
+}
+
+// List returns the recordings in dir, oldest first. A directory that does not
+// exist holds no recordings.
+func List(dir string) ([]Session, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []Session
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), Ext)
+		if !ok || e.IsDir() {
+			continue
+		}
+		start, err := time.Parse(sessionLayout, id)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		out = append(out, Session{
+			ID:       id,
+			Path:     filepath.Join(dir, e.Name()),
+			Start:    start,
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// Find returns the session in dir that id names: the whole ID, or a prefix of
+// exactly one (so "20261017T10" picks the one session that began in that
+// hour). An empty id is the latest session.
+func Find(dir, id string) (Session, error) {
+	sessions, err := List(dir)
+	if err != nil {
+		return Session{}, err
+	}
+	if len(sessions) == 0 {
+		return Session{}, fmt.Errorf("no recordings in %s", dir)
+	}
+	if id == "" {
+		return sessions[len(sessions)-1], nil
+	}
+	var found []Session
+	for _, s := range sessions {
+		if s.ID == id {
+			return s, nil
+		}
+		if strings.HasPrefix(s.ID, id) {
+			found = append(found, s)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return Session{}, fmt.Errorf("no recording %q in %s", id, dir)
+	case 1:
+		return found[0], nil
+	}
+	return Session{}, fmt.Errorf("%q matches %d recordings in %s; give more of it", id, len(found), dir)
+}
+
+// Event is one line after the header: Time seconds into the recording, a
+// Kind ("o" for output, "r" for a resize to Data's "COLSxROWS"; other kinds a
+// different recorder may write are passed through), and the Data.
+type Event struct {
+	Time float64
+	Kind string
+	Data string
+}
+
+// Decoder reads a recording one event at a time.
+type Decoder struct {
+	Header Header
+	r      *bufio.Reader
+}
+
+// NewDecoder reads the header from r and returns a decoder positioned at the
+// first event.
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	br := bufio.NewReader(r)
+	line, err := br.ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	d := &Decoder{r: br}
+	if err := json.Unmarshal(line, &d.Header); err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	if d.Header.Version != 2 {
+		return nil, fmt.Errorf("asciicast version %d, want 2", d.Header.Version)
+	}
+	return d, nil
+}
+
+// Next returns the next event, or io.EOF after the last. A last line that
+// does not parse is read as the end: it is what a recording whose writer
+// died mid-line ends in, and everything before it is intact.
+func (d *Decoder) Next() (Event, error) {
+	for {
+		line, err := d.r.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return Event{}, err
+		}
+		if strings.TrimSpace(string(line)) == "" {
+			continue
+		}
+		ev, perr := decodeEvent(line)
+		if perr != nil {
+			if err != nil {
+				return Event{}, io.EOF
+			}
+			return Event{}, perr
+		}
+		return ev, nil
+	}
+}
+
+// decodeEvent parses one event line: a three-element array of time, kind and
+// data.
+func decodeEvent(line []byte) (Event, error) {
+	var raw []json.RawMessage
+	var ev Event
+	if err := json.Unmarshal(line, &raw); err != nil || len(raw) != 3 {
+		return ev, fmt.Errorf("bad event %.40q", line)
+	}
+	if json.Unmarshal(raw[0], &ev.Time) != nil || json.Unmarshal(raw[1], &ev.Kind) != nil || json.Unmarshal(raw[2], &ev.Data) != nil {
+		return ev, fmt.Errorf("bad event %.40q", line)
+	}
+	return ev, nil
+}
+
+// Play writes d's output to w at the pace it was recorded, sped up by speed
+// (2 is twice as fast). A pause longer than maxWait, after speeding up, is
+// cut to maxWait, so an agent that sat idle for an hour does not replay as an
+// hour of nothing; zero keeps every pause. Resize events are not replayed —
+// w is somebody else's terminal — and Play returns at the end of the
+// recording or when ctx is done.
+func Play(ctx context.Context, w io.Writer, d *Decoder, speed float64, maxWait time.Duration) error {
+	if speed <= 0 {
+		speed = 1
+	}
+	last := 0.0
+	for {
+		ev, err := d.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if ev.Kind != "o" {
+			continue
+		}
+		wait := time.Duration((ev.Time - last) / speed * float64(time.Second))
+		last = ev.Time
+		if maxWait > 0 && wait > maxWait {
+			wait = maxWait
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, ev.Data); err != nil {
+			return err
+		}
+	}
+}
+
+// sleep is how Play waits, indirected so a test can play a recording without
+// waiting for it.
+var sleep = sleepTimer
+
+// sleepTimer waits d or until ctx is done.
+func sleepTimer(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// Output returns every byte of output in d, in order, with no pacing: the
+// stream `pogo agent replay --dump` renders.
+func Output(d *Decoder) ([]byte, error) {
+	var out []byte
+	for {
+		ev, err := d.Next()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return out, err
+		}
+		if ev.Kind == "o" {
+			out = append(out, ev.Data...)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Package recording keeps an agent's PTY stream on disk as asciicast v2, so
+// that what a polecat printed is still there after the ring buffer has moved
+// past it, after the agent has exited, and after pogod has restarted
+// (user-015).
+//
+// The ring (agent.OutputRingBytes) is 64KB. That is minutes of a busy TUI, and
+// the post-mortems that need a transcript are exactly the ones where the
+// interesting part is older than that: a polecat that wedged an hour ago, or
+// one that crashed and whose ring went with its Agent. A recording is the
+// whole stream, with the time each chunk arrived and every resize, in the
+// format asciinema and every player built on it already read — so a recording
+// can be replayed with `pogo agent replay`, or copied off the host and played
+// with anything else.
+//
+// # Layout
+//
+// One agent's recordings live in $POGO_HOME/agents/<name>/sessions (Dir), one
+// file per session, named for the moment it began: 20261017T101500.000Z.cast.
+// A session is one run of the agent's process — a spawn, a respawn, or an
+// adoption after a pogod restart — until it passes Limits.RotateBytes, when it
+// continues in a new file with a new header at the terminal's size then. Every
+// file is a complete recording on its own; rotation only decides where one
+// ends.
+//
+// # Retention
+//
+// Recordings are append-only and a chatty agent writes a lot of them, so they
+// are swept the way internal/gitgc sweeps polecat branches: pogod prunes every
+// agent's directory at startup and on an interval (Sweep), and a recorder
+// prunes its own directory each time it rotates, so one agent cannot outgrow
+// its budget between sweeps. A file still being written is never pruned.
+//
+// Recording is off unless [recording] enabled = true: it writes every byte
+// every agent prints, and what an agent prints can include whatever it read.
+package recording
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/drellem2/pogo/internal/config"
+)
+
+// Ext is the extension every recording file carries.
+const Ext = ".cast"
+
+// Limits bounds how much a recording may grow before it rotates and how much
+// of an agent's history retention keeps. A zero field is unbounded.
+type Limits struct {
+	// RotateBytes is the size at which a recording closes and continues in a
+	// new file.
+	RotateBytes int64
+	// MaxBytes is the most one agent's recordings may hold in total; the
+	// oldest files go first.
+	MaxBytes int64
+	// MaxAge is how long a recording is kept after it was last written.
+	MaxAge time.Duration
+}
+
+// Root returns the directory every agent's recordings live under:
+// $POGO_HOME/agents. It mirrors agent.PromptDir()'s construction rather than
+// calling it, because internal/agent imports this package.
+func Root() string {
+	return filepath.Join(config.PogoHome(), "agents")
+}
+
+// Dir returns the directory one agent's recordings live in:
+// $POGO_HOME/agents/<name>/sessions.
+func Dir(name string) string {
+	return DirIn(Root(), name)
+}
+
+// DirIn is Dir under an explicit root.
+func DirIn(root, name string) string {
+	return filepath.Join(root, name, "sessions")
+}
+
+// sessionLayout is the time format a recording's file is named with. It sorts
+// lexically in time order, which is what List and Prune rely on.
+const sessionLayout = "20060102T150405.000Z"
+
+// sessionID returns the name of a session that began at t.
+func sessionID(t time.Time) string {
+	return t.UTC().Format(sessionLayout)
+}
@@ -0,0 +1,223 @@
+package recording
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock steps clock forward by step on every reading, starting at start.
+func fakeClock(t *testing.T, start time.Time, step time.Duration) {
+	t.Helper()
+	now := start
+	clock = func() time.Time {
+		now = now.Add(step)
+		return now
+	}
+	t.Cleanup(func() { clock = time.Now })
+}
+
+func decodeAll(t *testing.T, path string) (Header, []Event) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	d, err := NewDecoder(f)
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+	var evs []Event
+	for {
+		ev, err := d.Next()
+		if err != nil {
+			break
+		}
+		evs = append(evs, ev)
+	}
+	return d.Header, evs
+}
+
+// A recording is asciicast v2: a header at the starting size, output events
+// with rising times, and a resize event for every change of size.
+func TestRecordingRoundTrips(t *testing.T) {
+	fakeClock(t, time.Date(2026, 10, 17, 10, 15, 0, 0, time.UTC), 250*time.Millisecond)
+	dir := filepath.Join(t.TempDir(), "sessions")
+	r, err := Start(dir, 120, 40, "crew-1", Limits{})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	path := r.Path()
+	if got, want := filepath.Base(path), "20261017T101500.250Z.cast"; got != want {
+		t.Errorf("file = %s, want %s", got, want)
+	}
+	r.Write([]byte("\x1b[2Jhello "))
+	// "日" split across two PTY reads.
+	r.Write([]byte("\xe6\x97"))
+	r.Write([]byte("\xa5\r\n"))
+	r.Resize(120, 40)
+	r.Resize(80, 24)
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	hdr, evs := decodeAll(t, path)
+	if hdr.Version != 2 || hdr.Width != 120 || hdr.Height != 40 || hdr.Title != "crew-1" {
+		t.Errorf("header = %+v", hdr)
+	}
+	var kinds, out []string
+	for i, ev := range evs {
+		kinds = append(kinds, ev.Kind)
+		if ev.Kind == "o" {
+			out = append(out, ev.Data)
+		}
+		if i > 0 && ev.Time <= evs[i-1].Time {
+			t.Errorf("event %d at %v, not after %v", i, ev.Time, evs[i-1].Time)
+		}
+	}
+	if got := strings.Join(kinds, ""); got != "oor" {
+		t.Errorf("kinds = %q, want two outputs and one resize (the same-size resize is dropped)", got)
+	}
+	if got := strings.Join(out, ""); got != "\x1b[2Jhello 日\r\n" {
+		t.Errorf("output = %q", got)
+	}
+	if evs[len(evs)-1].Data != "80x24" {
+		t.Errorf("resize = %q", evs[len(evs)-1].Data)
+	}
+}
+
+// Past RotateBytes the session continues in a new file with its own header
+// at the size the terminal has then, and the rotation prunes the directory.
+func TestRecordingRotatesAndPrunes(t *testing.T) {
+	fakeClock(t, time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC), time.Second)
+	dir := t.TempDir()
+	r, err := Start(dir, 80, 24, "p", Limits{RotateBytes: 200, MaxBytes: 600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk := bytes.Repeat([]byte("x"), 100)
+	for range 3 {
+		r.Write(chunk)
+	}
+	r.Resize(100, 30)
+	for range 12 {
+		r.Write(chunk)
+	}
+	current := r.Path()
+	sessions, _ := List(dir)
+	var total int64
+	for _, s := range sessions {
+		total += s.Size
+	}
+	if len(sessions) < 2 || total > 600+200 {
+		t.Errorf("%d sessions holding %d bytes, want rotation and pruning to keep it near 600", len(sessions), total)
+	}
+	if sessions[len(sessions)-1].Path != current {
+		t.Errorf("latest session %s is not the one being written, %s", sessions[len(sessions)-1].Path, current)
+	}
+	r.Close()
+	hdr, _ := decodeAll(t, current)
+	if hdr.Width != 100 || hdr.Height != 30 {
+		t.Errorf("rotated header is %dx%d, want the size at rotation, 100x30", hdr.Width, hdr.Height)
+	}
+}
+
+func TestPruneKeepsTheOpenFileAndHonoursAge(t *testing.T) {
+	root := t.TempDir()
+	dir := DirIn(root, "old-polecat")
+	os.MkdirAll(dir, 0o700)
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	write := func(id string, size int, age time.Duration) string {
+		p := filepath.Join(dir, id+Ext)
+		os.WriteFile(p, bytes.Repeat([]byte("x"), size), 0o600)
+		os.Chtimes(p, now.Add(-age), now.Add(-age))
+		return p
+	}
+	stale := write("20261001T000000.000Z", 10, 16*24*time.Hour)
+	a := write("20261016T000000.000Z", 100, time.Hour)
+	b := write("20261017T000000.000Z", 100, time.Minute)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("kept"), 0o600)
+
+	// A file being written is never pruned, even over budget.
+	open.Store(b, struct{}{})
+	defer open.Delete(b)
+	res, err := Sweep(root, Limits{MaxAge: 7 * 24 * time.Hour, MaxBytes: 50}, now)
+	if err != nil || len(res.Errors) > 0 {
+		t.Fatalf("Sweep: %v %v", err, res.Errors)
+	}
+	if res.Agents != 1 || len(res.Removed) != 2 || res.Removed[0] != stale || res.Removed[1] != a {
+		t.Errorf("sweep = %+v, want the stale file and then the oldest over budget removed", res)
+	}
+	if _, err := os.Stat(b); err != nil {
+		t.Errorf("open recording was pruned: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("a file that is not a recording was pruned: %v", err)
+	}
+}
+
+func TestFindAndPlay(t *testing.T) {
+	fakeClock(t, time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC), time.Hour)
+	dir := t.TempDir()
+	for range 2 {
+		r, err := Start(dir, 80, 24, "p", Limits{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Write([]byte("one "))
+		r.Write([]byte("two"))
+		r.Close()
+	}
+	latest, err := Find(dir, "")
+	if err != nil || latest.ID != "20261017T130000.000Z" {
+		t.Errorf("latest = %+v, %v", latest, err)
+	}
+	if s, err := Find(dir, "20261017T10"); err != nil || s.ID != "20261017T100000.000Z" {
+		t.Errorf("prefix = %+v, %v", s, err)
+	}
+	if _, err := Find(dir, "20261017"); err == nil {
+		t.Error("an ambiguous prefix found a session")
+	}
+
+	var waits []time.Duration
+	sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	defer func() { sleep = sleepTimer }()
+	f, _ := os.Open(latest.Path)
+	defer f.Close()
+	d, _ := NewDecoder(f)
+	var out bytes.Buffer
+	if err := Play(context.Background(), &out, d, 2, 20*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "one two" {
+		t.Errorf("played %q", out.String())
+	}
+	// Every reading of the fake clock is an hour on, so each output is an
+	// hour after the last: 30 minutes at 2x, which maxWait cuts to 20.
+	if len(waits) != 2 || waits[0] != 20*time.Minute || waits[1] != 20*time.Minute {
+		t.Errorf("waits = %v", waits)
+	}
+}
+
+// A recording whose writer died mid-line reads up to the last whole event.
+func TestDecoderToleratesATornLastLine(t *testing.T) {
+	in := `{"version": 2, "width": 80, "height": 24}` + "\n" +
+		`[0.5, "o", "ok"]` + "\n" +
+		`[0.9, "o", "tor`
+	d, err := NewDecoder(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := Output(d)
+	if err != nil || string(out) != "ok" {
+		t.Errorf("Output = %q, %v", out, err)
+	}
+}
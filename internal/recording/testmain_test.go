@@ -0,0 +1,27 @@
+package recording
+
+import (
+	"os"
+	"testing"
+
+	"github.com/drellem2/pogo/internal/testsandbox"
+)
+
+// testSandbox is the package's private, CHECKED envelope (internal/testsandbox).
+// Dir reads $POGO_HOME; the tests write under t.TempDir() through DirIn, and
+// the envelope keeps a test that forgets from writing the real one.
+var testSandbox *testsandbox.Sandbox
+
+func TestMain(m *testing.M) {
+	sb, down := testsandbox.Main("recording")
+	testSandbox = sb
+
+	code := m.Run()
+
+	down()
+	os.Exit(code)
+}
+
+func TestSandboxIsVerified(t *testing.T) {
+	testsandbox.Verify(t, testSandbox)
+}
@@ -0,0 +1,238 @@
+package recording
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// clock is the time source a recorder stamps events with, indirected so a
+// test can produce a file with known timestamps.
+var clock = time.Now
+
+// open holds the path of every recording some Recorder in this process is
+// writing, which Prune must leave alone.
+var open sync.Map
+
+// Header is the first line of an asciicast v2 file.
+type Header struct {
+	Version int `json:"version"`
+	Width   int `json:"width"`
+	Height  int `json:"height"`
+	// Timestamp is when the recording began, in Unix seconds.
+	Timestamp int64  `json:"timestamp"`
+	Title     string `json:"title,omitempty"`
+}
+
+// Recorder writes one agent's PTY stream to its recordings directory. Write
+// and Resize may be called from different goroutines.
+//
+// A recorder that cannot write — the disk is full, the directory went away —
+// logs once and stops recording rather than failing the agent: a recording is
+// a record of the agent, never a reason for it to stop.
+type Recorder struct {
+	mu     sync.Mutex
+	dir    string
+	title  string
+	limits Limits
+
+	f       *os.File
+	path    string
+	start   time.Time
+	written int64
+	cols    int
+	rows    int
+	// partial holds the bytes of a UTF-8 sequence the last Write cut short.
+	// asciicast carries output as JSON strings, and a rune split across two
+	// events would be two replacement characters in the file.
+	partial []byte
+	failed  bool
+}
+
+// Start begins a recording in dir at a terminal of cols×rows. title is
+// written to the header; pogod passes the agent's name.
+func Start(dir string, cols, rows int, title string, limits Limits) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	r := &Recorder{dir: dir, title: title, limits: limits, cols: cols, rows: rows}
+	if err := r.openFile(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Path returns the file the recorder is writing now.
+func (r *Recorder) Path() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.path
+}
+
+// Write records p as output. It never returns an error; see Recorder.
+func (r *Recorder) Write(p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil || r.failed {
+		return
+	}
+	data := append(r.partial, p...)
+	cut := len(data) - incompleteTail(data)
+	r.partial = append([]byte(nil), data[cut:]...)
+	if cut == 0 {
+		return
+	}
+	r.event("o", string(data[:cut]))
+}
+
+// Resize records a change of terminal size. A resize to the current size is
+// not recorded.
+func (r *Recorder) Resize(cols, rows int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cols == r.cols && rows == r.rows {
+		return
+	}
+	r.cols, r.rows = cols, rows
+	if r.f == nil || r.failed {
+		return
+	}
+	r.event("r", fmt.Sprintf("%dx%d", cols, rows))
+}
+
+// Close ends the recording. Bytes of a rune the stream never finished are
+// written as they are.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	if len(r.partial) > 0 && !r.failed {
+		r.event("o", string(r.partial))
+		r.partial = nil
+	}
+	return r.closeFile()
+}
+
+// event appends one event line, then rotates if the file has grown past
+// RotateBytes. Called with r.mu held.
+func (r *Recorder) event(kind, data string) {
+	t := clock().Sub(r.start).Seconds()
+	enc, _ := json.Marshal(data)
+	line := make([]byte, 0, len(enc)+32)
+	line = append(line, '[')
+	line = strconv.AppendFloat(line, t, 'f', 6, 64)
+	line = append(line, `, "`...)
+	line = append(line, kind...)
+	line = append(line, `", `...)
+	line = append(line, enc...)
+	line = append(line, "]\n"...)
+	n, err := r.f.Write(line)
+	r.written += int64(n)
+	if err != nil {
+		r.fail(err)
+		return
+	}
+	if r.limits.RotateBytes > 0 && r.written >= r.limits.RotateBytes {
+		r.rotate()
+	}
+}
+
+// rotate closes the current file, starts the next, and prunes the directory.
+// Called with r.mu held.
+func (r *Recorder) rotate() {
+	if err := r.closeFile(); err != nil {
+		r.fail(err)
+		return
+	}
+	if err := r.openFile(); err != nil {
+		r.fail(err)
+		return
+	}
+	if _, err := Prune(r.dir, r.limits, clock()); err != nil {
+		log.Printf("recording %s: prune after rotation: %v", r.title, err)
+	}
+}
+
+// openFile creates the next recording file and writes its header. Two
+// sessions that begin in the same millisecond — a crash and its immediate
+// respawn — take consecutive milliseconds rather than one file.
+func (r *Recorder) openFile() error {
+	start := clock()
+	for {
+		path := filepath.Join(r.dir, sessionID(start)+Ext)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, fs.ErrExist) {
+			start = start.Add(time.Millisecond)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		hdr, _ := json.Marshal(Header{Version: 2, Width: r.cols, Height: r.rows, Timestamp: start.Unix(), Title: r.title})
+		n, err := f.Write(append(hdr, '\n'))
+		if err != nil {
+			f.Close()
+			os.Remove(path)
+			return err
+		}
+		r.f, r.path, r.start, r.written = f, path, start, int64(n)
+		open.Store(path, struct{}{})
+		return nil
+	}
+}
+
+// closeFile closes the current file. Called with r.mu held.
+func (r *Recorder) closeFile() error {
+	open.Delete(r.path)
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+// fail logs err and stops recording. Called with r.mu held.
+func (r *Recorder) fail(err error) {
+	log.Printf("recording %s: %v — recording stopped", r.title, err)
+	r.failed = true
+	if r.f != nil {
+		r.closeFile()
+	}
+}
+
+// incompleteTail returns how many bytes at the end of p are the start of a
+// UTF-8 sequence that p ends before finishing. Bytes that can never become a
+// rune are not held back; they are written, and JSON replaces them.
+func incompleteTail(p []byte) int {
+	for i := 1; i <= utf8.UTFMax-1 && i <= len(p); i++ {
+		b := p[len(p)-i]
+		if b < 0x80 {
+			return 0
+		}
+		if !utf8.RuneStart(b) {
+			continue
+		}
+		need := 0
+		switch {
+		case b&0xe0 == 0xc0:
+			need = 2
+		case b&0xf0 == 0xe0:
+			need = 3
+		case b&0xf8 == 0xf0:
+			need = 4
+		}
+		if i < need {
+			return i
+		}
+		return 0
+	}
+	return 0
+}
@@ -44,6 +44,10 @@ type SubmitRequest struct {
 	// is still alive to hear about it. See MergeRequest.Verdict for why submit
 	// time is the only moment an auto-done author can record one.
 	Verdict json.RawMessage `json:"verdict,omitempty"`
+	// DependsOn names a merge request, in the same repo and for the same
+	// target, that must merge before this one starts (`--after`). Submit
+	// rejects an unknown, cross-lane or already-failed dependency.
+	DependsOn string `json:"depends_on,omitempty"`
 }
 
 // RegisterHandlers registers refinery API endpoints on the given mux,
@@ -145,6 +149,7 @@ func (r *Refinery) handleSubmit(w http.ResponseWriter, req *http.Request) {
 		DeferDone:           submitReq.DeferDone,
 		PostMergeTag:        submitReq.PostMergeTag,
 		Verdict:             submitReq.Verdict,
+		DependsOn:           submitReq.DependsOn,
 	}
 
 	id, err := r.Submit(mr)
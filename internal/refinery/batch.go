@@ -0,0 +1,587 @@
+package refinery
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultBatchSize is the merge-train length `[gates] batch = true` asks for.
+// Four is small enough that a failed train bisects in two extra gate runs and
+// large enough to turn a ten-branch backlog behind a six-minute gate into
+// three gate runs instead of ten.
+const defaultBatchSize = 4
+
+// maxBatchSize bounds a configured train. A longer train shares one gate run
+// between more branches, but every member waits for the slowest rebase and a
+// failed train's bisection costs log2(N) more gate runs on the lane's single
+// slot — past this the expected saving is gone.
+const maxBatchSize = 16
+
+// parseBatchSize reads a [gates] batch value: a count of merge requests, or a
+// boolean. "true" means defaultBatchSize; "false", "0" and "1" mean off (a
+// train of one is the ordinary pipeline). Off is returned as 1, never 0:
+// loadConfig reads a zero BatchSize as "unset" and falls back to the target
+// branch's value, so a branch that says "0" must still turn trains off.
+// Counts above maxBatchSize are clamped rather than refused, because the
+// operator's intent — "batch" — is clear. Returns ok=false for anything it
+// cannot read.
+func parseBatchSize(raw string) (int, bool) {
+	s := strings.ToLower(strings.TrimSpace(strings.Trim(strings.TrimSpace(raw), `"'`)))
+	switch s {
+	case "true", "yes", "on":
+		return defaultBatchSize, true
+	case "false", "no", "off":
+		return 1, true
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	if n == 0 {
+		return 1, true
+	}
+	if n > maxBatchSize {
+		return maxBatchSize, true
+	}
+	return n, true
+}
+
+// Merge trains (speculative batch merging), opt-in per repo with [gates] batch.
+//
+// A lane merges strictly one request after another because each rebases onto
+// the target the previous one produced. On a repo with a slow gate that makes
+// the queue drain at one gate run per branch. A train keeps the lane's order
+// and its single clone, and changes only how many gate runs the order costs:
+//
+//  1. The lane's head picks up the next N-1 queued requests for the same repo
+//     and target (formTrain). Held requests and requests for other targets
+//     are not eligible.
+//  2. Each member is rebased onto the one ahead of it, so the last member's
+//     tip is the target as it would read after every member had merged in
+//     order (attemptTrain). A member that cannot be stacked — it conflicts
+//     with a member ahead of it, or fails the closing-reference check — is
+//     left out and merged on its own afterwards, which is where its real
+//     verdict is produced.
+//  3. The gates run ONCE on that candidate. On a pass the target is
+//     fast-forwarded to it in one push and every member resolves as merged
+//     at its own rebased tip.
+//  4. On a gate verdict against the candidate the train is split in half and
+//     each half is tried as its own train, the first half first. A half of one
+//     runs the ordinary single-request pipeline, so the culprit fails with the
+//     same record it would have had without batching while the other members
+//     land. Because each half is rebuilt on the target the half before it
+//     produced, a failure that needs two members together still lands on the
+//     second of them.
+//  5. Anything other than a gate verdict — a transport failure, the target
+//     moving before the push, a host-resource gate failure — establishes
+//     nothing about the members, so the train is abandoned and each member is
+//     merged on its own with the full retry machinery of processMerge.
+//
+// The lane stays held for the whole train, so a train is never concurrent
+// with another merge for its repo, and cancelling one member stops only the
+// unit of the train that member is being gated in.
+
+// trainOutcome is what one attempt at a train established.
+type trainOutcome int
+
+const (
+	// trainLanded means the candidate passed its gates and was pushed.
+	trainLanded trainOutcome = iota
+	// trainGateFailed means the gates returned a verdict against the
+	// candidate; it is bisected.
+	trainGateFailed
+	// trainTooShort means fewer than two members could be stacked, so there
+	// is no shared gate run to make; the stacked members merge on their own.
+	trainTooShort
+	// trainCancelled means a member was cancelled while the candidate was
+	// being gated; the train is re-formed without it.
+	trainCancelled
+	// trainFallback means the attempt failed for a reason that says nothing
+	// about the members; each merges on its own.
+	trainFallback
+)
+
+// trainVerdict is the result of attemptTrain.
+type trainVerdict struct {
+	outcome trainOutcome
+	// stacked are the members whose rebased commits made up the candidate, in
+	// order; excluded are members that could not be stacked, each with the
+	// reason, and they merge on their own after the train resolves.
+	stacked  []*MergeRequest
+	excluded []*MergeRequest
+	// tips maps a stacked member's ID to its tip in the candidate, which is
+	// the SHA it lands as when the train lands.
+	tips map[string]string
+	// reason explains a trainFallback or trainGateFailed outcome in one line.
+	reason     string
+	gateOutput string
+}
+
+// formTrain decides whether the lane's head merge request runs as a merge
+// train and, if so, claims its riders. Returns the whole train, head first, or
+// nil when the repo has not opted in or nothing can ride.
+func (r *Refinery) formTrain(ln *lane, head *MergeRequest) []*MergeRequest {
+	wtDir, err := r.ensureWorktree(head.RepoPath)
+	if err != nil {
+		// processMerge repeats the setup and reports the failure on the
+		// merge request; saying it twice here would add nothing.
+		return nil
+	}
+	size := r.loadConfig(wtDir, head.RepoPath).BatchSize
+	if size < 2 {
+		return nil
+	}
+
+	// The QA gate is consulted for each candidate outside the lock, exactly
+	// as holdForQA does for the head. A held candidate is simply not taken:
+	// it stays queued and is held on its own turn, so a rider cannot carry a
+	// branch past its QA item.
+	var eligible []*MergeRequest
+	for _, mr := range r.trainCandidates(head, size-1) {
+		if result, _ := r.checkQAGate(mr.Author); result == QAGateHold {
+			continue
+		}
+		eligible = append(eligible, mr)
+	}
+	if len(eligible) == 0 {
+		return nil
+	}
+	riders := r.boardTrain(ln, eligible)
+	if len(riders) == 0 {
+		return nil
+	}
+	return append([]*MergeRequest{head}, riders...)
+}
+
+// trainCandidates returns up to n queued merge requests that may ride behind
+// head: same repo, same target, still queued, in queue order.
+func (r *Refinery) trainCandidates(head *MergeRequest, n int) []*MergeRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*MergeRequest
+	aboard := map[string]bool{head.ID: true}
+	for _, mr := range r.queue {
+		if len(out) == n {
+			break
+		}
+		if mr.Status != StatusQueued || mr.RepoPath != head.RepoPath || mr.TargetRef != head.TargetRef {
+			continue
+		}
+		// A stacked branch may ride behind the request it builds on, which
+		// the candidate stacks ahead of it anyway; otherwise it waits for
+		// that request to merge (dependson.go).
+		if state, _ := r.dependencyStateLocked(mr); state != depReady && !aboard[mr.DependsOn] {
+			continue
+		}
+		aboard[mr.ID] = true
+		out = append(out, mr)
+	}
+	return out
+}
+
+// boardTrain moves the candidates that are still queued out of the queue and
+// onto ln as riders. A candidate cancelled or claimed while the QA gate was
+// being read is skipped.
+func (r *Refinery) boardTrain(ln *lane, candidates []*MergeRequest) []*MergeRequest {
+	// After the unlock (LIFO), for the reason claimLane gives: a rider is in
+	// flight from here on, and the file must say so before its merge runs.
+	defer r.flushState()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var riders []*MergeRequest
+	for _, mr := range candidates {
+		idx := -1
+		for i, q := range r.queue {
+			if q == mr {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 || mr.Status != StatusQueued {
+			continue
+		}
+		// Its dependency may have been the candidate the QA gate kept off.
+		if state, _ := r.dependencyStateLocked(mr); state != depReady && !r.aboardLocked(ln, riders, mr.DependsOn) {
+			continue
+		}
+		r.queue = append(r.queue[:idx], r.queue[idx+1:]...)
+		mr.StartTime = time.Now()
+		mr.Status = StatusProcessing
+		riders = append(riders, mr)
+	}
+	ln.riders = append(ln.riders, riders...)
+	if len(riders) > 0 {
+		r.saveStateLocked()
+	}
+	return riders
+}
+
+// aboardLocked reports whether id is ln's head or one of riders. Must be
+// called with mu held.
+func (r *Refinery) aboardLocked(ln *lane, riders []*MergeRequest, id string) bool {
+	if ln.mr != nil && ln.mr.ID == id {
+		return true
+	}
+	for _, mr := range riders {
+		if mr.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// runTrain merges every member of a train and releases the lane once the last
+// one has resolved.
+func (r *Refinery) runTrain(ln *lane, train []*MergeRequest) {
+	id := generateTrainID()
+	r.mu.Lock()
+	for _, mr := range train {
+		mr.Train = id
+	}
+	r.mu.Unlock()
+	log.Printf("refinery: merge train %s formed in repo-lane=%s: %d requests [%s] — gates run once on the stacked candidate",
+		id, ln.key, len(train), trainIDs(train))
+	emitTrainFormed(id, train)
+
+	if wtDir, err := r.ensureWorktree(train[0].RepoPath); err != nil {
+		// Each member reports the setup failure on its own record.
+		r.mergeAlone(ln, train, "the train could not set up the refinery's clone; merged on its own")
+	} else {
+		r.mergeTrain(ln, wtDir, id, train)
+	}
+
+	r.mu.Lock()
+	r.endLaneLocked(ln)
+	r.mu.Unlock()
+}
+
+// mergeTrain tries mrs as one train and resolves every member, recursing into
+// halves when the candidate's gate fails. See the overview above.
+func (r *Refinery) mergeTrain(ln *lane, wtDir, id string, mrs []*MergeRequest) {
+	mrs = r.dropCancelledMembers(ln, mrs)
+	switch len(mrs) {
+	case 0:
+		return
+	case 1:
+		r.mergeAlone(ln, mrs, "")
+		return
+	}
+
+	start := time.Now()
+	v := r.attemptTrain(ln, wtDir, mrs)
+	switch v.outcome {
+	case trainLanded:
+		r.landTrain(ln, wtDir, id, v, time.Since(start))
+	case trainGateFailed:
+		mid := len(v.stacked) / 2
+		left, right := v.stacked[:mid], v.stacked[mid:]
+		note := fmt.Sprintf("gated in a candidate of %d that failed; split to find the culprit", len(v.stacked))
+		r.noteTrain(v.stacked, note)
+		log.Printf("refinery: merge train %s gate FAILED on the candidate of %d [%s] — bisecting into [%s] then [%s]",
+			id, len(v.stacked), trainIDs(v.stacked), trainIDs(left), trainIDs(right))
+		emitTrainSplit(id, v.stacked, v.reason)
+		r.mergeTrain(ln, wtDir, id, left)
+		r.mergeTrain(ln, wtDir, id, right)
+	case trainCancelled:
+		log.Printf("refinery: merge train %s: a member was cancelled mid-gate — re-forming without it", id)
+		r.mergeTrain(ln, wtDir, id, v.stacked)
+	case trainTooShort:
+		r.mergeAlone(ln, v.stacked, "")
+	default:
+		log.Printf("refinery: merge train %s abandoned (%s) — merging its %d members one at a time", id, v.reason, len(v.stacked))
+		emitTrainSplit(id, v.stacked, v.reason)
+		r.mergeAlone(ln, v.stacked, "the train could not complete ("+v.reason+"); merged on its own")
+	}
+	if len(v.excluded) > 0 {
+		r.mergeAlone(ln, v.excluded, "")
+	}
+}
+
+// mergeAlone runs each merge request through the ordinary single-request
+// pipeline, in order, under its own gate context, and resolves it. note, when
+// set, is added to each one's TrainNote first.
+func (r *Refinery) mergeAlone(ln *lane, mrs []*MergeRequest, note string) {
+	for _, mr := range mrs {
+		// Checked per member, not once up front: an earlier member failing
+		// cancels the ones stacked on it (dependson.go).
+		if len(r.dropCancelledMembers(ln, []*MergeRequest{mr})) == 0 {
+			continue
+		}
+		if note != "" {
+			r.noteTrain([]*MergeRequest{mr}, note)
+		}
+		r.beginTrainRun(ln, []*MergeRequest{mr})
+		outcome, err := r.processMerge(mr)
+		r.endTrainRun(ln)
+		r.resolveMerge(ln, mr, outcome, err, false)
+	}
+}
+
+// attemptTrain stacks mrs into one candidate, gates it, and pushes it when the
+// gates pass. It never resolves a member; that is mergeTrain's job.
+func (r *Refinery) attemptTrain(ln *lane, wtDir string, mrs []*MergeRequest) trainVerdict {
+	v := trainVerdict{tips: make(map[string]string)}
+	head := mrs[0]
+	target := head.TargetRef
+
+	log.Printf("refinery: train step=fetch members=%d target=%s", len(mrs), target)
+	if out, err := gitCmdOutput(wtDir, "fetch", "origin"); err != nil {
+		v.outcome, v.stacked = trainFallback, mrs
+		v.reason = "fetch: " + firstOutputLine(out)
+		return v
+	}
+	// Same reason as at the top of attemptMerge: a previous gate in this
+	// reused clone may have left tracked files modified (gatedirt.go).
+	r.discardGateSideEffectsAt(wtDir, head, 1, "train-entry")
+
+	candidate, err := gitCmdOutput(wtDir, "rev-parse", "origin/"+target)
+	if err != nil {
+		v.outcome, v.stacked = trainFallback, mrs
+		v.reason = "rev-parse origin/" + target + ": " + firstOutputLine(candidate)
+		return v
+	}
+	candidate = strings.TrimSpace(candidate)
+
+	for _, mr := range mrs {
+		emitMergeAttempted(mr, 1)
+		log.Printf("refinery: MR %s step=train-stack branch=%s onto=%s", mr.ID, mr.Branch, shortSHA(candidate))
+		if out, gerr := gitCmdOutput(wtDir, "checkout", "-B", mr.Branch, "origin/"+mr.Branch); gerr != nil {
+			v.exclude(r, mr, "checkout failed: "+firstOutputLine(out))
+			continue
+		}
+		if mr.DependsOn != "" && v.tips[mr.DependsOn] == "" && !r.dependencyMet(mr) {
+			v.exclude(r, mr, "the request it builds on, "+mr.DependsOn+", is not in this candidate")
+			continue
+		}
+		// Judged on the branch before it is stacked, so the range names this
+		// member's own commits and not the ones ahead of it in the train.
+		if cerr := checkClosingRefs(wtDir, target, mr.Branch); cerr != nil {
+			v.exclude(r, mr, "its commit messages failed the closing-reference check")
+			continue
+		}
+		if out, gerr := gitCmdOutput(wtDir, "rebase", candidate); gerr != nil {
+			gitCmdOutput(wtDir, "rebase", "--abort")
+			v.exclude(r, mr, "it does not rebase cleanly onto the members ahead of it: "+firstOutputLine(out))
+			continue
+		}
+		tip, gerr := gitCmdOutput(wtDir, "rev-parse", "HEAD")
+		if gerr != nil {
+			v.exclude(r, mr, "rev-parse after rebase: "+firstOutputLine(tip))
+			continue
+		}
+		candidate = strings.TrimSpace(tip)
+		v.tips[mr.ID] = candidate
+		v.stacked = append(v.stacked, mr)
+	}
+	if len(v.stacked) < 2 {
+		v.outcome = trainTooShort
+		return v
+	}
+	// The last member's branch is checked out at the candidate, which is the
+	// tree the gates must judge.
+	if out, gerr := gitCmdOutput(wtDir, "checkout", "-B", v.stacked[len(v.stacked)-1].Branch, candidate); gerr != nil {
+		v.outcome = trainFallback
+		v.reason = "checkout candidate: " + firstOutputLine(out)
+		return v
+	}
+
+	log.Printf("refinery: train step=quality-gates members=%d candidate=%s heartbeat_every=%s",
+		len(v.stacked), shortSHA(candidate), r.gateHeartbeat())
+	ctx := r.beginTrainRun(ln, v.stacked)
+	out, gates, qerr := r.runQualityGates(ctx, wtDir, head.RepoPath, v.stacked[0])
+	r.endTrainRun(ln)
+	v.gateOutput = out
+	// The gates were chosen once, for the candidate's whole diff; every member
+	// was gated by that choice, so every member's record names it.
+	r.mu.Lock()
+	for _, mr := range v.stacked[1:] {
+		mr.GateRule = v.stacked[0].GateRule
+	}
+	r.mu.Unlock()
+	if qerr != nil {
+		if r.anyCancelled(v.stacked) {
+			v.outcome = trainCancelled
+			return v
+		}
+		// Only a verdict on the tree is worth bisecting. Everything else the
+		// classifier can say — the host ran out of disk, the gate could not
+		// reach the network, it was killed — would come out the same for every
+		// half, so splitting would spend log2(N) gate runs re-deriving it.
+		disp := classifyFailure(gateStage(gates), rawOf(qerr), qerr)
+		v.reason = summarizeReason(qerr)
+		if disp.Class == ClassDefect {
+			v.outcome = trainGateFailed
+		} else {
+			v.outcome = trainFallback
+			v.reason = fmt.Sprintf("gate failure classified %s, which is not a verdict on the members: %s", disp.Class, v.reason)
+		}
+		return v
+	}
+	if discarded := r.discardGateSideEffectsAt(wtDir, head, 1, "post-gate"); len(discarded) > 0 {
+		v.gateOutput += gateWriteNote(discarded)
+	}
+
+	if r.loadConfig(wtDir, head.RepoPath).PRMode {
+		for _, mr := range v.stacked {
+			r.pushBackForPR(wtDir, mr, 1)
+		}
+	}
+
+	// Land the whole candidate in one fast-forward and one push. Any failure
+	// here is the target moving or the transport failing — neither says
+	// anything about the members, so the caller falls back to merging them
+	// one at a time, where processMerge's retry budgets apply.
+	for _, step := range [][]string{
+		{"fetch", "origin", target},
+		{"checkout", "-B", target, "origin/" + target},
+		{"merge", "--ff-only", candidate},
+		{"push", "origin", target},
+	} {
+		log.Printf("refinery: train step=%s target=%s candidate=%s", step[0], target, shortSHA(candidate))
+		if out, gerr := gitCmdOutput(wtDir, step...); gerr != nil {
+			v.outcome = trainFallback
+			v.reason = fmt.Sprintf("git %s: %s", step[0], firstOutputLine(out))
+			return v
+		}
+	}
+	v.outcome = trainLanded
+	return v
+}
+
+// exclude leaves mr out of the candidate being stacked. It is merged on its
+// own once the train resolves, which is where its own verdict comes from.
+func (v *trainVerdict) exclude(r *Refinery, mr *MergeRequest, why string) {
+	log.Printf("refinery: MR %s left out of its merge train: %s — it will be merged on its own", mr.ID, why)
+	r.noteTrain([]*MergeRequest{mr}, "left out of the train ("+why+"); merged on its own")
+	v.excluded = append(v.excluded, mr)
+}
+
+// landTrain runs the post-merge steps for a landed train and resolves each
+// member as merged at its own rebased tip.
+func (r *Refinery) landTrain(ln *lane, wtDir, id string, v trainVerdict, elapsed time.Duration) {
+	last := v.stacked[len(v.stacked)-1]
+	sha := v.tips[last.ID]
+	log.Printf("refinery: merge train %s LANDED %d requests [%s] on %s at %s in %s — one gate run",
+		id, len(v.stacked), trainIDs(v.stacked), last.TargetRef, shortSHA(sha), elapsed.Round(time.Second))
+	emitTrainLanded(id, v.stacked, sha, elapsed.Seconds())
+
+	header := fmt.Sprintf("=== merge train %s: gated once for %d requests [%s] ===\n", id, len(v.stacked), trainIDs(v.stacked))
+	note := fmt.Sprintf("landed with %s on one gate run", plural(len(v.stacked)-1, "other request"))
+	r.noteTrain(v.stacked, note)
+
+	fastForwardSourceCheckout(last.RepoPath, last.TargetRef)
+	// The deploy hook is about what is on the target now, which is the whole
+	// train: it runs once, and its result belongs to every member.
+	deployErr := r.runDeploy(wtDir, last)
+	for _, mr := range v.stacked {
+		tip := v.tips[mr.ID]
+		emitMerged(mr, 1, tip, elapsed.Seconds(), false)
+		r.closePRAndReap(wtDir, mr, tip)
+		postMergeErr := r.runPostMergeSteps(wtDir, mr, tip)
+		r.resolveMerge(ln, mr, mergeResult{
+			GateOutput:     header + v.gateOutput,
+			DeployError:    deployErr,
+			PostMergeError: postMergeErr,
+			MergedSHA:      tip,
+		}, nil, false)
+	}
+}
+
+// dropCancelledMembers resolves every member of mrs that has been cancelled
+// and returns the rest.
+func (r *Refinery) dropCancelledMembers(ln *lane, mrs []*MergeRequest) []*MergeRequest {
+	var keep []*MergeRequest
+	for _, mr := range mrs {
+		if !r.cancelWasRequested(mr) {
+			keep = append(keep, mr)
+			continue
+		}
+		emitMergeCancelled(mr, 1, "before-attempt", "")
+		r.resolveMerge(ln, mr, mergeResult{}, cancelledMergeError("before-attempt"), false)
+	}
+	return keep
+}
+
+// anyCancelled reports whether a cancel was requested for any of mrs.
+func (r *Refinery) anyCancelled(mrs []*MergeRequest) bool {
+	for _, mr := range mrs {
+		if r.cancelWasRequested(mr) {
+			return true
+		}
+	}
+	return false
+}
+
+// beginTrainRun installs a fresh gate context for the unit of the train about
+// to run. It is derived from the lane's own context, so stopping the lane
+// still stops the train.
+func (r *Refinery) beginTrainRun(ln *lane, mrs []*MergeRequest) context.Context {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ctx, cancel := context.WithCancel(ln.ctx)
+	ln.runCtx, ln.runCancel = ctx, cancel
+	ln.runIDs = make(map[string]bool, len(mrs))
+	for _, mr := range mrs {
+		ln.runIDs[mr.ID] = true
+	}
+	return ctx
+}
+
+// endTrainRun tears down the context beginTrainRun installed.
+func (r *Refinery) endTrainRun(ln *lane) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ln.runCancel != nil {
+		ln.runCancel()
+	}
+	ln.runCtx, ln.runCancel, ln.runIDs = nil, nil, nil
+}
+
+// requestTrainMemberCancelLocked records a cancel for one member of a train.
+// The running gate is killed only when that member is part of the unit being
+// gated; a member waiting its turn is dropped when its turn comes. Must be
+// called with mu held.
+func (r *Refinery) requestTrainMemberCancelLocked(ln *lane, id string) {
+	if ln.cancelledIDs == nil {
+		ln.cancelledIDs = make(map[string]bool)
+	}
+	ln.cancelledIDs[id] = true
+	running := ln.runIDs[id]
+	if running && ln.runCancel != nil {
+		ln.runCancel()
+	}
+	log.Printf("refinery: cancel requested for MR %s in a merge train on repo-lane=%s (gate running for it: %v) — "+
+		"the rest of the train continues without it", id, ln.key, running)
+}
+
+// noteTrain appends a sentence to each member's TrainNote.
+func (r *Refinery) noteTrain(mrs []*MergeRequest, note string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, mr := range mrs {
+		if mr.TrainNote == "" {
+			mr.TrainNote = note
+		} else {
+			mr.TrainNote += "; " + note
+		}
+	}
+}
+
+// trainIDs renders member IDs for a log line.
+func trainIDs(mrs []*MergeRequest) string {
+	return strings.Join(trainMemberIDs(mrs), " ")
+}
+
+// firstOutputLine returns the first non-empty line of s, trimmed.
+func firstOutputLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
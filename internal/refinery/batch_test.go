@@ -0,0 +1,246 @@
+package refinery
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBatchSize(t *testing.T) {
+	cases := []struct {
+		in     string
+		want   int
+		wantOK bool
+	}{
+		{"4", 4, true},
+		{"0", 1, true}, // off, not "unset": see loadConfig
+		{"1", 1, true},
+		{"true", defaultBatchSize, true},
+		{"false", 1, true},
+		{`"8"`, 8, true},
+		{"1000", maxBatchSize, true},
+		{"-2", 0, false},
+		{"lots", 0, false},
+	}
+	for _, c := range cases {
+		got, ok := parseBatchSize(c.in)
+		if got != c.want || ok != c.wantOK {
+			t.Errorf("parseBatchSize(%q) = %d, %v; want %d, %v", c.in, got, ok, c.want, c.wantOK)
+		}
+	}
+}
+
+func TestParseRefineryTomlBatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "refinery.toml")
+	os.WriteFile(path, []byte(`
+[gates]
+commands = ["./build.sh"]
+batch = 3
+`), 0644)
+	if got := parseRefineryConfig(path).BatchSize; got != 3 {
+		t.Errorf("BatchSize = %d, want 3", got)
+	}
+
+	// Unreadable leaves trains off rather than guessing a size.
+	os.WriteFile(path, []byte(`
+[gates]
+batch = several
+`), 0644)
+	if got := parseRefineryConfig(path).BatchSize; got != 0 {
+		t.Errorf("BatchSize = %d for an unreadable value, want 0", got)
+	}
+}
+
+// TestBatchZeroOnTheBranchTurnsTrainsOff. A zero BatchSize is how loadConfig
+// spells "unset", so a branch's `batch = 0` has to arrive as 1 or the target's
+// `batch = true` would fill it back in.
+func TestBatchZeroOnTheBranchTurnsTrainsOff(t *testing.T) {
+	r := newProgressTestRefinery(t, time.Second)
+	origin, wt := t.TempDir(), t.TempDir()
+	writeGateConfig(t, origin, "[gates]\nbatch = true\n")
+	writeGateConfig(t, wt, "[gates]\ncommands = [\"true\"]\nbatch = 0\n")
+	if got := r.loadConfig(wt, origin).BatchSize; got != 1 {
+		t.Errorf("BatchSize = %d with batch = 0 on the branch, want 1 (off)", got)
+	}
+}
+
+// setupTrainRepo creates an origin whose gate appends a line to a counter file
+// outside the repo on every run, and fails when bad.txt is in the tree. It
+// pushes one branch per name, each adding its own file; the branch named in
+// bad adds bad.txt as well. Returns the origin and the counter path.
+func setupTrainRepo(t *testing.T, batch int, branches []string, bad string) (originDir, counter string) {
+	t.Helper()
+	originDir = initBareOrigin(t, "main")
+	counter = filepath.Join(t.TempDir(), "gate-runs")
+
+	workDir := t.TempDir()
+	run(t, workDir, "git", "clone", originDir, ".")
+	run(t, workDir, "git", "config", "user.email", "test@test.com")
+	run(t, workDir, "git", "config", "user.name", "Test")
+	os.MkdirAll(filepath.Join(workDir, ".pogo"), 0755)
+	os.WriteFile(filepath.Join(workDir, ".pogo", "refinery.toml"), []byte(fmt.Sprintf(`
+[gates]
+commands = ["sh gate.sh"]
+batch = %d
+`, batch)), 0644)
+	os.WriteFile(filepath.Join(workDir, "gate.sh"), []byte(fmt.Sprintf(
+		"echo run >> %q\ntest ! -e bad.txt\n", counter)), 0644)
+	run(t, workDir, "git", "add", ".")
+	run(t, workDir, "git", "commit", "-m", "gate")
+	run(t, workDir, "git", "push", "origin", "main")
+
+	for _, b := range branches {
+		run(t, workDir, "git", "checkout", "-b", b, "main")
+		os.WriteFile(filepath.Join(workDir, b+".txt"), []byte(b), 0644)
+		if b == bad {
+			os.WriteFile(filepath.Join(workDir, "bad.txt"), []byte("bad"), 0644)
+		}
+		run(t, workDir, "git", "add", ".")
+		run(t, workDir, "git", "commit", "-m", "add "+b)
+		run(t, workDir, "git", "push", "origin", b)
+	}
+	return originDir, counter
+}
+
+func trainGateRuns(t *testing.T, counter string) int {
+	t.Helper()
+	data, err := os.ReadFile(counter)
+	if err != nil {
+		return 0
+	}
+	return strings.Count(string(data), "run")
+}
+
+func submitAll(t *testing.T, r *Refinery, originDir string, branches []string) []string {
+	t.Helper()
+	var ids []string
+	for _, b := range branches {
+		id, err := r.Submit(MergeRequest{RepoPath: originDir, Branch: b, TargetRef: "main", Author: "cat-" + b})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// TestMergeTrainLandsOnOneGateRun: three queued requests for a repo with
+// batch = 4 are stacked, gated once, and pushed together, each landing at its
+// own rebased tip in queue order.
+func TestMergeTrainLandsOnOneGateRun(t *testing.T) {
+	branches := []string{"feat-a", "feat-b", "feat-c"}
+	originDir, counter := setupTrainRepo(t, 4, branches, "")
+	r, err := New(Config{Enabled: true, PollInterval: time.Hour, WorktreeDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := submitAll(t, r, originDir, branches)
+
+	r.processNext()
+
+	if n := trainGateRuns(t, counter); n != 1 {
+		t.Errorf("gate ran %d times, want 1 for the whole train", n)
+	}
+	var train string
+	for i, id := range ids {
+		mr := r.Get(id)
+		if mr.Status != StatusMerged {
+			t.Fatalf("%s: status %s (error: %s), want merged", branches[i], mr.Status, mr.Error)
+		}
+		if mr.Train == "" || (train != "" && mr.Train != train) {
+			t.Errorf("%s: train %q, want every member in one train", branches[i], mr.Train)
+		}
+		train = mr.Train
+		if !strings.Contains(mr.TrainNote, "one gate run") {
+			t.Errorf("%s: train note %q does not say it shared a gate run", branches[i], mr.TrainNote)
+		}
+	}
+	if len(r.Queue()) != 0 || len(r.InFlight()) != 0 {
+		t.Errorf("queue/in-flight not empty after the train: %d/%d", len(r.Queue()), len(r.InFlight()))
+	}
+
+	head := strings.TrimSpace(runOut(t, originDir, "git", "rev-parse", "main"))
+	if last := r.Get(ids[2]).MergedSHA; last != head {
+		t.Errorf("last member merged as %s, origin main is %s", last, head)
+	}
+	// Queue order is preserved: each member's tip is the parent of the next.
+	for i := 1; i < len(ids); i++ {
+		parent := strings.TrimSpace(runOut(t, originDir, "git", "rev-parse", r.Get(ids[i]).MergedSHA+"^"))
+		if prev := r.Get(ids[i-1]).MergedSHA; parent != prev {
+			t.Errorf("%s is not stacked on %s: parent %s, want %s", branches[i], branches[i-1], parent, prev)
+		}
+	}
+}
+
+// TestMergeTrainBisectsToCulprit: a gate verdict against the candidate splits
+// the train until the member that broke it is gated alone. It fails with its
+// own record; every other member lands.
+func TestMergeTrainBisectsToCulprit(t *testing.T) {
+	branches := []string{"feat-a", "feat-b", "feat-c", "feat-d"}
+	originDir, counter := setupTrainRepo(t, 4, branches, "feat-b")
+	r, err := New(Config{Enabled: true, PollInterval: time.Hour, WorktreeDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := submitAll(t, r, originDir, branches)
+
+	r.processNext()
+
+	for i, id := range ids {
+		mr := r.Get(id)
+		want := StatusMerged
+		if branches[i] == "feat-b" {
+			want = StatusFailed
+		}
+		if mr.Status != want {
+			t.Errorf("%s: status %s (error: %s), want %s", branches[i], mr.Status, mr.Error, want)
+		}
+		if !strings.Contains(mr.TrainNote, "split to find the culprit") {
+			t.Errorf("%s: train note %q does not record the split", branches[i], mr.TrainNote)
+		}
+	}
+	// Whole train, then [a b], then a and b alone, then [c d].
+	if n := trainGateRuns(t, counter); n != 5 {
+		t.Errorf("gate ran %d times, want 5", n)
+	}
+
+	verifyDir := t.TempDir()
+	run(t, verifyDir, "git", "clone", originDir, ".")
+	for _, f := range []string{"feat-a.txt", "feat-c.txt", "feat-d.txt"} {
+		if _, err := os.Stat(filepath.Join(verifyDir, f)); err != nil {
+			t.Errorf("%s not on main after the train: %v", f, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(verifyDir, "bad.txt")); err == nil {
+		t.Error("the culprit's bad.txt landed on main")
+	}
+}
+
+// TestMergeTrainOffByDefault: without [gates] batch every request runs the
+// ordinary pipeline, one gate run each, and carries no train.
+func TestMergeTrainOffByDefault(t *testing.T) {
+	branches := []string{"feat-a", "feat-b"}
+	originDir, counter := setupTrainRepo(t, 0, branches, "")
+	r, err := New(Config{Enabled: true, PollInterval: time.Hour, WorktreeDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := submitAll(t, r, originDir, branches)
+
+	r.processNext()
+	r.processNext()
+
+	for i, id := range ids {
+		mr := r.Get(id)
+		if mr.Status != StatusMerged || mr.Train != "" {
+			t.Errorf("%s: status %s train %q, want merged outside any train", branches[i], mr.Status, mr.Train)
+		}
+	}
+	if n := trainGateRuns(t, counter); n != 2 {
+		t.Errorf("gate ran %d times, want 2", n)
+	}
+}
@@ -45,7 +45,12 @@ func (r *Refinery) gateContext(mr *MergeRequest) context.Context {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	if mr != nil {
-		if ln := r.laneHoldingLocked(mr.ID); ln != nil && ln.ctx != nil {
+		if ln := r.laneHoldingLocked(mr.ID); ln != nil && ln.runCtx != nil {
+			// A merge train gates one unit at a time under its own handle,
+			// so a member's cancel can stop the unit it is in and nothing
+			// else (see batch.go).
+			return ln.runCtx
+		} else if ln != nil && ln.ctx != nil {
 			return ln.ctx
 		}
 	}
@@ -62,7 +67,7 @@ func (r *Refinery) cancelWasRequested(mr *MergeRequest) bool {
 		return false
 	}
 	ln := r.laneHoldingLocked(mr.ID)
-	return ln != nil && ln.cancelRequested
+	return ln != nil && (ln.cancelRequested || ln.cancelledIDs[mr.ID])
 }
 
 // requestInFlightCancelLocked kills the lane's running gate and records the
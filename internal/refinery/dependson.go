@@ -0,0 +1,176 @@
+package refinery
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Dependency-ordered merge requests (stacked branches).
+//
+// A polecat that splits a large work item into stacked branches — B built on
+// top of A — used to have to sequence the submits by hand: submitted first, B
+// rebases onto a target that does not have A yet, conflicts or fails its
+// gates, and burns attempts on a verdict about the order rather than the
+// branch. `pogo refinery submit --after <mr-id>` (MergeRequest.DependsOn)
+// says the order instead:
+//
+//   - B stays queued, and is passed over by dispatch, until A has MERGED. Other
+//     merges for the repo are not held behind it — a waiting dependent is not
+//     a lane-holder, it is simply not ready.
+//   - If A fails, is cancelled, or is lost across a restart, B is cancelled
+//     with a record naming A, and so is anything stacked on B. B cannot land
+//     as written without A, and leaving it queued would wait forever.
+//   - In a merge train (batch.go) B may ride behind A, since the candidate
+//     stacks them in order anyway; it is left out of any candidate that does
+//     not also carry A.
+//
+// The dependency must be in the same repo and for the same target: the order
+// is a statement about one target's history, and a request in another lane
+// could never be rebased onto it.
+
+// depState is where a merge request's dependency stands.
+type depState int
+
+const (
+	// depReady means there is no dependency, or it has merged.
+	depReady depState = iota
+	// depWaiting means the dependency is still queued, held or in flight.
+	depWaiting
+	// depDead means the dependency will never merge: it failed, was
+	// cancelled, or was lost across a restart.
+	depDead
+)
+
+// dependencyStateLocked reports where mr's dependency stands, and a one-word
+// account of its status for records. Must be called with mu held.
+//
+// A dependency that is in neither the index nor the lost list was pruned from
+// history, which only happens to a resolved request. It is taken as merged:
+// had it failed or been cancelled, its dependents were cancelled at that
+// moment and are not here to ask.
+func (r *Refinery) dependencyStateLocked(mr *MergeRequest) (depState, string) {
+	if mr.DependsOn == "" {
+		return depReady, ""
+	}
+	dep, ok := r.byID[mr.DependsOn]
+	if !ok {
+		for _, le := range r.lost {
+			if le.ID == mr.DependsOn {
+				return depDead, string(StatusLost)
+			}
+		}
+		return depReady, "pruned"
+	}
+	switch dep.Status {
+	case StatusMerged:
+		return depReady, string(dep.Status)
+	case StatusFailed, StatusCancelled, StatusLost:
+		return depDead, string(dep.Status)
+	}
+	return depWaiting, string(dep.Status)
+}
+
+// dependencyMet reports whether mr's dependency has merged.
+func (r *Refinery) dependencyMet(mr *MergeRequest) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state, _ := r.dependencyStateLocked(mr)
+	return state == depReady
+}
+
+// validateDependencyLocked checks a submit's depends_on while the submitter is
+// still running to hear about it. Must be called with mu held.
+func (r *Refinery) validateDependencyLocked(req *MergeRequest) error {
+	if req.DependsOn == "" {
+		return nil
+	}
+	dep, ok := r.byID[req.DependsOn]
+	if !ok {
+		return fmt.Errorf("depends_on %q: no such merge request in the queue or retained history", req.DependsOn)
+	}
+	if laneKey(dep.RepoPath) != laneKey(req.RepoPath) || dep.TargetRef != req.TargetRef {
+		return fmt.Errorf("depends_on %q: it merges into %s of %s, not %s of %s — a dependency must be in the same repo and for the same target",
+			dep.ID, dep.TargetRef, laneKey(dep.RepoPath), req.TargetRef, laneKey(req.RepoPath))
+	}
+	switch dep.Status {
+	case StatusFailed, StatusCancelled, StatusLost:
+		return fmt.Errorf("depends_on %q: it has already %s, so this branch could never merge after it — resubmit the dependency first", dep.ID, dep.Status)
+	}
+	return nil
+}
+
+// cancelDependentsLocked cancels every queued merge request that depends on
+// dep, and every one stacked on those, because dep will never merge. A
+// dependent riding in a merge train is asked to stop instead, and cascades
+// when the train resolves it. Returns the requests cancelled outright, for
+// the caller to emit events for once mu is released. Must be called with mu
+// held.
+func (r *Refinery) cancelDependentsLocked(dep *MergeRequest) []*MergeRequest {
+	var cancelled []*MergeRequest
+	for _, ln := range r.lanes {
+		for _, mr := range ln.riders {
+			if mr.DependsOn == dep.ID && mr.Status == StatusProcessing {
+				r.requestTrainMemberCancelLocked(ln, mr.ID)
+			}
+		}
+	}
+	var direct []*MergeRequest
+	kept := r.queue[:0]
+	for _, mr := range r.queue {
+		if mr.DependsOn == dep.ID {
+			direct = append(direct, mr)
+		} else {
+			kept = append(kept, mr)
+		}
+	}
+	r.queue = kept
+	for _, mr := range direct {
+		mr.Status = StatusCancelled
+		mr.Error = fmt.Sprintf("cancelled: depends on %s (%s), which %s — this branch cannot land as written without it",
+			dep.ID, dep.Branch, dep.Status)
+		mr.StartTime = time.Time{}
+		mr.DoneTime = time.Now()
+		r.history = append(r.history, mr)
+		log.Printf("refinery: cancelled MR %s branch=%s author=%s: its dependency %s %s", mr.ID, mr.Branch, mr.Author, dep.ID, dep.Status)
+		cancelled = append(cancelled, mr)
+		cancelled = append(cancelled, r.cancelDependentsLocked(mr)...)
+	}
+	return cancelled
+}
+
+// cancelOrphansLocked cancels queued requests whose dependency will never
+// merge but whose cancel did not happen when it resolved — a dependency lost
+// across a restart has no resolution to hang it on. Must be called with mu
+// held.
+func (r *Refinery) cancelOrphansLocked() []*MergeRequest {
+	var cancelled []*MergeRequest
+	for _, mr := range append([]*MergeRequest(nil), r.queue...) {
+		if mr.Status == StatusCancelled {
+			continue // taken by an earlier orphan's cascade
+		}
+		if state, _ := r.dependencyStateLocked(mr); state != depDead {
+			continue
+		}
+		dep, ok := r.byID[mr.DependsOn]
+		if !ok {
+			dep = &MergeRequest{ID: mr.DependsOn, Status: StatusLost}
+			for _, le := range r.lost {
+				if le.ID == mr.DependsOn {
+					dep.Branch = le.Branch
+				}
+			}
+		}
+		cancelled = append(cancelled, r.cancelDependentsLocked(dep)...)
+	}
+	return cancelled
+}
+
+// emitDependencyCancels writes the events, and sends the webhooks, for
+// requests cancelDependentsLocked resolved. Called with mu released.
+func (r *Refinery) emitDependencyCancels(mrs []*MergeRequest) {
+	for _, mr := range mrs {
+		emitMergeCancelled(mr, 0, "dependency", "")
+	}
+	r.notifyTransition(mrs...)
+}
@@ -0,0 +1,150 @@
+package refinery
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupStackedRepo creates an origin with a stacked pair: part-1 adds
+// part1.txt, and part-2 is built on part-1 and adds part2.txt. When failGate
+// is set, the repo's gate fails any tree holding part1.txt.
+func setupStackedRepo(t *testing.T, failGate bool) string {
+	t.Helper()
+	originDir := initBareOrigin(t, "main")
+	workDir := t.TempDir()
+	run(t, workDir, "git", "clone", originDir, ".")
+	run(t, workDir, "git", "config", "user.email", "test@test.com")
+	run(t, workDir, "git", "config", "user.name", "Test")
+	gate := "true"
+	if failGate {
+		gate = "test ! -e part1.txt"
+	}
+	os.MkdirAll(filepath.Join(workDir, ".pogo"), 0755)
+	os.WriteFile(filepath.Join(workDir, ".pogo", "refinery.toml"), []byte("quality_gate = \""+gate+"\"\n"), 0644)
+	run(t, workDir, "git", "add", ".")
+	run(t, workDir, "git", "commit", "-m", "gate")
+	run(t, workDir, "git", "push", "origin", "main")
+
+	run(t, workDir, "git", "checkout", "-b", "part-1")
+	os.WriteFile(filepath.Join(workDir, "part1.txt"), []byte("one"), 0644)
+	run(t, workDir, "git", "add", ".")
+	run(t, workDir, "git", "commit", "-m", "part 1")
+	run(t, workDir, "git", "push", "origin", "part-1")
+
+	run(t, workDir, "git", "checkout", "-b", "part-2")
+	os.WriteFile(filepath.Join(workDir, "part2.txt"), []byte("two"), 0644)
+	run(t, workDir, "git", "add", ".")
+	run(t, workDir, "git", "commit", "-m", "part 2")
+	run(t, workDir, "git", "push", "origin", "part-2")
+	seedBranch(t, originDir, "other")
+	return originDir
+}
+
+func TestSubmitValidatesDependsOn(t *testing.T) {
+	originDir := setupStackedRepo(t, false)
+	r, err := New(Config{Enabled: true, PollInterval: time.Hour, WorktreeDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Submit(MergeRequest{RepoPath: originDir, Branch: "part-2", DependsOn: "mr-nope"}); err == nil ||
+		!strings.Contains(err.Error(), "no such merge request") {
+		t.Errorf("unknown dependency: err = %v, want it refused", err)
+	}
+
+	a, err := r.Submit(MergeRequest{RepoPath: originDir, Branch: "part-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(t, originDir, "git", "branch", "-f", "release", "main")
+	if _, err := r.Submit(MergeRequest{RepoPath: originDir, Branch: "part-2", TargetRef: "release", DependsOn: a}); err == nil ||
+		!strings.Contains(err.Error(), "same target") {
+		t.Errorf("cross-target dependency: err = %v, want it refused", err)
+	}
+
+	if _, err := r.Cancel(a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Submit(MergeRequest{RepoPath: originDir, Branch: "part-2", DependsOn: a}); err == nil ||
+		!strings.Contains(err.Error(), "cancelled") {
+		t.Errorf("dependency already cancelled: err = %v, want it refused", err)
+	}
+}
+
+// TestDependentWaitsForItsDependency puts the dependent AHEAD of its
+// dependency in the queue — the order a QA hold re-queue can produce — and
+// checks dispatch passes over it, merges the dependency, and only then the
+// dependent.
+func TestDependentWaitsForItsDependency(t *testing.T) {
+	originDir := setupStackedRepo(t, false)
+	r, err := New(Config{Enabled: true, PollInterval: time.Hour, WorktreeDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := r.Submit(MergeRequest{RepoPath: originDir, Branch: "part-1", Author: "cat-a"})
+	b, err := r.Submit(MergeRequest{RepoPath: originDir, Branch: "part-2", Author: "cat-b", DependsOn: a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.mu.Lock()
+	r.queue[0], r.queue[1] = r.queue[1], r.queue[0]
+	r.mu.Unlock()
+
+	r.processNext()
+	if got := r.Get(a).Status; got != StatusMerged {
+		t.Fatalf("dependency status %s after the first dispatch, want merged", got)
+	}
+	if got := r.Get(b).Status; got != StatusQueued {
+		t.Fatalf("dependent status %s while its dependency ran, want queued", got)
+	}
+
+	r.processNext()
+	if mr := r.Get(b); mr.Status != StatusMerged {
+		t.Fatalf("dependent status %s (error: %s), want merged", mr.Status, mr.Error)
+	}
+}
+
+// TestDependentsCancelledWhenDependencyFails: a failed merge takes the whole
+// stack above it, and says why on each record; an unrelated request is not
+// touched.
+func TestDependentsCancelledWhenDependencyFails(t *testing.T) {
+	originDir := setupStackedRepo(t, true)
+	r, err := New(Config{Enabled: true, PollInterval: time.Hour, WorktreeDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var failed []string
+	r.SetOnFailed(func(mr *MergeRequest) { failed = append(failed, mr.ID) })
+
+	a, _ := r.Submit(MergeRequest{RepoPath: originDir, Branch: "part-1", Author: "cat-a"})
+	b, _ := r.Submit(MergeRequest{RepoPath: originDir, Branch: "part-2", Author: "cat-b", DependsOn: a})
+	c, err := r.Submit(MergeRequest{RepoPath: originDir, Branch: "part-2", Author: "cat-c", DependsOn: b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := r.Submit(MergeRequest{RepoPath: originDir, Branch: "other", Author: "cat-o"})
+
+	r.processNext()
+
+	if got := r.Get(a).Status; got != StatusFailed {
+		t.Fatalf("dependency status %s, want failed", got)
+	}
+	for _, id := range []string{b, c} {
+		mr := r.Get(id)
+		if mr.Status != StatusCancelled {
+			t.Errorf("%s: status %s, want cancelled with its dependency", id, mr.Status)
+		}
+		if !strings.Contains(mr.Error, "depends on") {
+			t.Errorf("%s: error %q does not name the dependency", id, mr.Error)
+		}
+	}
+	if got := r.Get(other).Status; got != StatusQueued {
+		t.Errorf("unrelated request status %s, want still queued", got)
+	}
+	if len(failed) != 1 || failed[0] != a {
+		t.Errorf("onFailed fired for %v, want only the dependency %s", failed, a)
+	}
+}
@@ -66,6 +66,7 @@ func summarizeReason(err error) string {
 
 // emitMergeAttempted writes a refinery_merge_attempted event for a new attempt.
 func emitMergeAttempted(mr *MergeRequest, attempt int) {
+	traceAttemptStarted(mr, attempt)
 	events.Emit(context.Background(), events.Event{
 		EventType:  "refinery_merge_attempted",
 		Agent:      "refinery",
@@ -85,6 +86,9 @@ func emitMergeAttempted(mr *MergeRequest, attempt int) {
 // alreadyMerged marks the no-op resolution of a re-submitted branch that had
 // already landed on the target (gh #34) — no gates ran, nothing was pushed.
 func emitMerged(mr *MergeRequest, attempt int, mergeCommit string, durationSec float64, alreadyMerged bool) {
+	observeMerged(mr)
+	traceAttemptEnded(mr, attempt, "merged", "", nil)
+	traceMergeDone(mr, "merged", nil)
 	details := map[string]any{
 		"merge_request_id": mr.ID,
 		"branch":           mr.Branch,
@@ -246,6 +250,9 @@ func emitMergeCancelled(mr *MergeRequest, attempt int, stage string, gateOutput
 	if stage == "" {
 		stage = "unknown"
 	}
+	mergesTotal.Inc("cancelled")
+	traceAttemptEnded(mr, attempt, "cancelled", stage, nil)
+	traceMergeDone(mr, "cancelled", nil)
 	details := map[string]any{
 		"merge_request_id": mr.ID,
 		"branch":           mr.Branch,
@@ -275,6 +282,16 @@ func emitMergeFailed(mr *MergeRequest, attempt int, stage string, err error, ter
 	if stage == "" {
 		stage = "unknown"
 	}
+	class := fail.Class
+	if class == "" {
+		class = ClassUnclassified
+	}
+	attemptFailuresTotal.Inc(stage, string(class))
+	traceAttemptEnded(mr, attempt, "failed", stage, err)
+	if terminal {
+		mergesTotal.Inc("failed")
+		traceMergeDone(mr, "failed", err)
+	}
 	details := map[string]any{
 		"merge_request_id": mr.ID,
 		"branch":           mr.Branch,
@@ -333,3 +350,70 @@ func emitMergeFailed(mr *MergeRequest, attempt int, stage string, err error, ter
 		Details:    details,
 	})
 }
+
+// trainMemberIDs lists a train's merge request IDs for an event's details.
+func trainMemberIDs(mrs []*MergeRequest) []string {
+	ids := make([]string, len(mrs))
+	for i, mr := range mrs {
+		ids[i] = mr.ID
+	}
+	return ids
+}
+
+// emitTrainFormed writes a refinery_train_formed event when a lane's head
+// picks up riders. The members' own refinery_merge_attempted events follow as
+// each is stacked into the candidate.
+func emitTrainFormed(trainID string, mrs []*MergeRequest) {
+	head := mrs[0]
+	events.Emit(context.Background(), events.Event{
+		EventType:  "refinery_train_formed",
+		Agent:      "refinery",
+		WorkItemID: workItemIDFromAuthor(head.Author),
+		Repo:       head.RepoPath,
+		Details: map[string]any{
+			"train_id":          trainID,
+			"target":            head.TargetRef,
+			"merge_request_ids": trainMemberIDs(mrs),
+			"size":              len(mrs),
+		},
+	})
+}
+
+// emitTrainLanded writes a refinery_train_landed event for a candidate that
+// passed its gates and was pushed. Each member also gets its own
+// refinery_merged, so a reader counting merges need not know about trains.
+func emitTrainLanded(trainID string, mrs []*MergeRequest, mergeCommit string, durationSec float64) {
+	head := mrs[0]
+	events.Emit(context.Background(), events.Event{
+		EventType:  "refinery_train_landed",
+		Agent:      "refinery",
+		WorkItemID: workItemIDFromAuthor(head.Author),
+		Repo:       head.RepoPath,
+		Details: map[string]any{
+			"train_id":          trainID,
+			"target":            head.TargetRef,
+			"merge_request_ids": trainMemberIDs(mrs),
+			"merge_commit":      mergeCommit,
+			"duration_seconds":  durationSec,
+		},
+	})
+}
+
+// emitTrainSplit writes a refinery_train_split event when a candidate did not
+// land as one: bisected after a gate verdict, or abandoned so its members
+// merge one at a time.
+func emitTrainSplit(trainID string, mrs []*MergeRequest, reason string) {
+	head := mrs[0]
+	events.Emit(context.Background(), events.Event{
+		EventType:  "refinery_train_split",
+		Agent:      "refinery",
+		WorkItemID: workItemIDFromAuthor(head.Author),
+		Repo:       head.RepoPath,
+		Details: map[string]any{
+			"train_id":          trainID,
+			"target":            head.TargetRef,
+			"merge_request_ids": trainMemberIDs(mrs),
+			"reason":            truncate(reason, reasonCap),
+		},
+	})
+}
@@ -644,6 +644,24 @@ func classifyFailure(stage string, raw string, err error) disposition {
 		}
 	}
 
+	// A gate the GATE RUNNER failed to run — it could not be reached, or hung
+	// up before the gate exited — is judged before the stage table for the
+	// same reason as the two kills above: the failure arrives at the gate's
+	// stage, and no gate returned anything. After them because a runner that
+	// reports a kill has answered; this is the case where nothing answered.
+	var runnerErr *gateRunnerError
+	if errors.As(err, &runnerErr) {
+		return disposition{
+			Class:     ClassInfrastructure,
+			Retryable: true,
+			GateRerun: true,
+			Signal:    "gate-runner " + runnerErr.Op,
+			RetryReason: "the gate runner at " + runnerErr.Socket + " did not run the gate to an exit, so no gate " +
+				"returned a verdict on this tree — a runner that is restarted or reachable again gives a DIFFERENT " +
+				"answer, and the branch is unchanged",
+		}
+	}
+
 	// A gate whose OWN NETWORK I/O failed is judged before the stage table, for
 	// the same reason a host-resource failure is: the gate ran, but what it
 	// reported was the network and not the branch (mg-67c9).
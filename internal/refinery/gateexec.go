@@ -0,0 +1,157 @@
+package refinery
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/drellem2/pogo/internal/platform/cgroup"
+	"github.com/drellem2/pogo/internal/platform/sandbox"
+)
+
+// GateExecutor runs one quality gate command to completion.
+//
+// It is the seam between the merge loop and the process that actually runs a
+// gate. Everything the refinery concludes about a gate — the heartbeat, the
+// output cap, the timeout and cancellation reports, the failure class — is
+// built in runGate from what an executor reports, so an executor only has to
+// run the command faithfully:
+//
+//   - write the gate's combined stdout and stderr to out as it is produced, in
+//     order, so the live excerpt and output counters see it while it runs;
+//   - call started with the gate's pid as soon as it exists, so the process
+//     subtree can be measured (a pid in this host's pid namespace, or none);
+//   - kill the gate AND everything it started when ctx is cancelled — a
+//     timeout and `pogo refinery cancel` both arrive that way;
+//   - return nil on a zero exit, and an error signalThatKilled can read when
+//     the gate died of a signal.
+//
+// The default is localGateExecutor, a child of pogod. `[refinery] gate_runner`
+// swaps in socketGateExecutor, which hands the run to a separate
+// `pogo gate-runner` process (gaterunner.go).
+type GateExecutor interface {
+	Run(ctx context.Context, spec GateSpec, out io.Writer, started func(pid int)) error
+}
+
+// GateSpec is one gate run as the executor sees it.
+type GateSpec struct {
+	// Dir is the worktree the gate runs in.
+	Dir string
+	// Command is run with `sh -c`.
+	Command string
+	// Env is KEY=VALUE pairs added on top of the executor's own environment.
+	Env []string
+}
+
+// gateEnv is the environment every gate is given on top of its executor's.
+var gateEnv = []string{"POGO_REFINERY=1"}
+
+// localGateExecutor runs gates as children of this process, in the worktree,
+// as the same user — the historic behaviour — confined to sandbox when it is
+// enabled ([refinery] sandbox; user-012). The profile's root is each run's
+// worktree. With cgroups, each run is also started in a group of its own held
+// to limits ([dispatch] cgroup_root; user-013), removed with whatever the gate
+// left running when the run ends.
+type localGateExecutor struct {
+	sandbox sandbox.Profile
+	cgroups *cgroup.Manager
+	limits  cgroup.Limits
+}
+
+// gateSeq numbers gate cgroups, which must be unique while they exist and
+// are named before the gate has a pid.
+var gateSeq atomic.Uint64
+
+func (l localGateExecutor) Run(ctx context.Context, spec GateSpec, out io.Writer, started func(pid int)) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", spec.Command)
+	cmd.Dir = spec.Dir
+	cmd.Env = append(os.Environ(), spec.Env...)
+	cmd.WaitDelay = gateWaitDelay
+
+	// Run the gate in its own process group and kill the group, not just the
+	// shell. `sh -c "./build.sh"` forks rather than execs for anything
+	// compound, so killing the shell alone leaves the real work — a test
+	// binary, a compiler — running and still holding the output pipe open.
+	// Wait then blocks on that pipe until WaitDelay expires, so a timeout that
+	// should have taken effect at once instead stalls, and the killed work
+	// keeps consuming the worktree it was told to stop using.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		if cmd.Process == nil {
+			return nil
+		}
+		// Setpgid makes the child's PGID equal its PID, so the negated PID
+		// addresses the whole group. Fall back to the single process if the
+		// group is already gone.
+		if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+			return cmd.Process.Kill()
+		}
+		return nil
+	}
+
+	// After SysProcAttr, which the namespaces profile adds to. The wrapper
+	// execs the shell in place, so the pid published below is still the
+	// gate's and its group is still the one Cancel kills.
+	if l.sandbox.Enabled() {
+		p := l.sandbox
+		p.Root = spec.Dir
+		if err := sandbox.Apply(cmd, p); err != nil {
+			return err
+		}
+	}
+
+	if l.cgroups != nil {
+		name := fmt.Sprintf("gate-%s-%d", filepath.Base(spec.Dir), gateSeq.Add(1))
+		group, err := l.cgroups.Create(name, l.limits)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := cgroup.Remove(group.Path); err != nil {
+				log.Printf("refinery: %v", err)
+			}
+		}()
+		defer group.Close()
+		group.Attach(cmd)
+	}
+
+	// One writer for both streams: os/exec reuses one pipe when Stdout and
+	// Stderr are equal, so ordering and interleaving match CombinedOutput.
+	cmd.Stdout = out
+	cmd.Stderr = out
+
+	// Start/Wait rather than Run so the gate's pid can be published the moment
+	// it exists. The pid roots the process-subtree CPU measurement, which is
+	// the half of the liveness signal that output staleness cannot supply
+	// (mg-0c51) — without it a silent-but-computing gate is indistinguishable
+	// from a hung one.
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	started(cmd.Process.Pid)
+	return cmd.Wait()
+}
+
+// SetGateExecutor replaces how quality gates are run. Call before Start; nil
+// restores the default of running them as children of this process.
+func (r *Refinery) SetGateExecutor(ex GateExecutor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gateExec = ex
+}
+
+// gateExecutor returns the executor gates run under.
+func (r *Refinery) gateExecutor() GateExecutor {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.gateExec == nil {
+		return localGateExecutor{sandbox: r.cfg.Sandbox, cgroups: r.cfg.Cgroups, limits: r.cfg.GateLimits}
+	}
+	return r.gateExec
+}
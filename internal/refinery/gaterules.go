@@ -0,0 +1,197 @@
+package refinery
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"strings"
+)
+
+// Per-path gate selection ([gates.paths] in .pogo/refinery.toml).
+//
+// A flat [gates] commands list runs the whole suite for every merge, so a
+// one-line markdown edit paid for ./build.sh and ./test.sh in full on the
+// lane every other merge to that repo waits behind. Path rules let a repo say
+// which gates a change to which paths needs:
+//
+//	[gates.paths]
+//	"docs/**"              = []
+//	"*.md"                 = []
+//	"internal/refinery/**" = ["go test ./internal/refinery/..."]
+//
+// The rules are judged against the REBASED diff — the files that differ
+// between origin/<target> and the tree the gates would test — because that is
+// what lands, and a branch's own history can touch files its rebased diff no
+// longer does.
+//
+// The rule is written so that it can only ever narrow the gate for a change
+// every one of whose paths the repo has vouched for:
+//
+//   - each changed path is claimed by the FIRST rule whose glob matches it, in
+//     file order, so a narrow rule placed above a broad one wins;
+//   - when every changed path is claimed, the gates are the claiming rules'
+//     commands, in rule order, each run once;
+//   - when ANY changed path is claimed by no rule, the full gate list runs —
+//     the one an unconfigured repo would run — exactly as if there were no
+//     rules. A code change outside every rule is never gated more weakly
+//     than it was before rules existed.
+//
+// The diff failing to read, and an empty diff, also take the full list: the
+// selection cannot stand behind a narrower gate it has no evidence for.
+//
+// The decision is recorded on the merge request (GateRule) and at the top of
+// the gate output, because "no gates ran" is a sentence a reader must be able
+// to tell from "the gates were skipped by mistake".
+
+// gatePathRule is one [gates.paths] entry: a path glob and the gate commands a
+// change under it needs. An empty Gates means such a change needs none.
+type gatePathRule struct {
+	Glob  string
+	Gates []string
+}
+
+// gateSelection is the outcome of judging a rebased diff against path rules.
+type gateSelection struct {
+	// Gates are the commands to run. When full is true they are the repo's
+	// ordinary gate list, unchanged.
+	Gates []string
+	full  bool
+	// Rule is the one-line account recorded on the merge request.
+	Rule string
+}
+
+// selectGates picks the gates for a change touching files, given the repo's
+// path rules and its full gate list. See the overview above for the rule.
+func selectGates(rules []gatePathRule, files, full []string) gateSelection {
+	if len(files) == 0 {
+		return gateSelection{Gates: full, full: true, Rule: "full gates — the rebased diff is empty, so no path rule can vouch for it"}
+	}
+	claimed := make([]int, len(rules))
+	for _, f := range files {
+		idx := matchingRule(rules, f)
+		if idx < 0 {
+			return gateSelection{Gates: full, full: true,
+				Rule: fmt.Sprintf("full gates — %s matches no [gates.paths] rule", f)}
+		}
+		claimed[idx]++
+	}
+
+	var gates, parts []string
+	seen := make(map[string]bool)
+	for i, rule := range rules {
+		if claimed[i] == 0 {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s (%s)", rule.Glob, plural(claimed[i], "file")))
+		for _, g := range rule.Gates {
+			if !seen[g] {
+				seen[g] = true
+				gates = append(gates, g)
+			}
+		}
+	}
+	chose := "no gates"
+	if len(gates) > 0 {
+		chose = strings.Join(gates, ", ")
+	}
+	return gateSelection{Gates: gates, Rule: fmt.Sprintf("path rules %s → %s", strings.Join(parts, ", "), chose)}
+}
+
+// selectGatesFor judges the worktree's rebased diff against rules and records
+// the decision on mr. full is the gate list that runs when the rules cannot
+// narrow it.
+func (r *Refinery) selectGatesFor(wtDir string, mr *MergeRequest, rules []gatePathRule, full []string) gateSelection {
+	var sel gateSelection
+	if files, err := rebasedDiffFiles(wtDir, mr.TargetRef); err != nil {
+		sel = gateSelection{Gates: full, full: true, Rule: "full gates — could not read the rebased diff: " + err.Error()}
+	} else {
+		sel = selectGates(rules, files, full)
+	}
+	log.Printf("refinery: MR %s gate selection: %s", mr.ID, sel.Rule)
+	r.mu.Lock()
+	mr.GateRule = sel.Rule
+	r.saveStateLocked()
+	r.mu.Unlock()
+	return sel
+}
+
+// matchingRule returns the index of the first rule whose glob matches name,
+// or -1.
+func matchingRule(rules []gatePathRule, name string) int {
+	for i, rule := range rules {
+		if matchPathGlob(rule.Glob, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// matchPathGlob reports whether a repo-relative slash path matches pattern.
+// Segments match as path.Match does, and a "**" segment matches any number of
+// segments, including none — so "docs/**" matches everything under docs/ and
+// "**/*.md" matches a markdown file at any depth. A pattern is anchored at the
+// repo root: "*.md" matches README.md and not docs/README.md. A malformed
+// segment matches nothing, which leaves the path unclaimed and so gated in full.
+func matchPathGlob(pattern, name string) bool {
+	return matchGlobSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchGlobSegments(pat, name []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			if len(pat) == 1 {
+				return len(name) > 0
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchGlobSegments(pat[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pat[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// rebasedDiffFiles lists the paths that differ between origin/<target> and
+// HEAD in the worktree — the files the tree under test would change on
+// landing. --no-renames lists both sides of a rename, so a file moved out of
+// a gated directory still counts as a change to it.
+func rebasedDiffFiles(wtDir, target string) ([]string, error) {
+	out, err := gitCmdOutput(wtDir, "diff", "--name-only", "--no-renames", "origin/"+target, "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("git diff origin/%s HEAD: %s", target, firstOutputLine(out))
+	}
+	var files []string
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, line)
+		}
+	}
+	return files, nil
+}
+
+// parseTomlStringArray parses a one-line TOML array of strings:
+// ["./build.sh", "./test.sh"]. An empty array yields an empty, non-nil slice,
+// which a path rule needs to tell "no gates" from "not set".
+func parseTomlStringArray(val string) []string {
+	out := []string{}
+	arr := strings.TrimSpace(val)
+	arr = strings.TrimPrefix(arr, "[")
+	arr = strings.TrimSuffix(arr, "]")
+	for _, item := range strings.Split(arr, ",") {
+		item = strings.TrimSpace(item)
+		item = strings.Trim(item, "\"")
+		if item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
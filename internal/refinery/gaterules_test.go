@@ -0,0 +1,204 @@
+package refinery
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatchPathGlob(t *testing.T) {
+	cases := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"docs/**", "docs/a.md", true},
+		{"docs/**", "docs/deep/er/a.md", true},
+		{"docs/**", "docsx/a.md", false},
+		{"**/*.md", "README.md", true},
+		{"**/*.md", "docs/x/README.md", true},
+		{"*.md", "README.md", true},
+		{"*.md", "docs/README.md", false},
+		{"internal/refinery/**", "internal/refinery/merge.go", true},
+		{"internal/refinery/**", "internal/agent/agent.go", false},
+		{"cmd/*/main.go", "cmd/pogo/main.go", true},
+		{"[", "[", false}, // malformed: matches nothing
+	}
+	for _, c := range cases {
+		if got := matchPathGlob(c.pattern, c.name); got != c.want {
+			t.Errorf("matchPathGlob(%q, %q) = %v, want %v", c.pattern, c.name, got, c.want)
+		}
+	}
+}
+
+func TestSelectGates(t *testing.T) {
+	full := []string{"./build.sh", "./test.sh"}
+	rules := []gatePathRule{
+		{Glob: "internal/refinery/testdata/**", Gates: []string{}},
+		{Glob: "internal/refinery/**", Gates: []string{"go test ./internal/refinery/..."}},
+		{Glob: "docs/**", Gates: []string{}},
+		{Glob: "*.md", Gates: []string{}},
+	}
+
+	sel := selectGates(rules, []string{"docs/a.md", "README.md"}, full)
+	if sel.full || len(sel.Gates) != 0 {
+		t.Errorf("docs-only change: gates %v (full=%v), want none", sel.Gates, sel.full)
+	}
+	if !strings.Contains(sel.Rule, "docs/** (1 file)") || !strings.Contains(sel.Rule, "no gates") {
+		t.Errorf("docs-only rule = %q", sel.Rule)
+	}
+
+	sel = selectGates(rules, []string{"internal/refinery/merge.go", "internal/refinery/lanes.go", "docs/a.md"}, full)
+	if want := []string{"go test ./internal/refinery/..."}; !reflect.DeepEqual(sel.Gates, want) {
+		t.Errorf("refinery change: gates %v, want %v", sel.Gates, want)
+	}
+
+	// First match wins: testdata sits inside the broader refinery rule.
+	sel = selectGates(rules, []string{"internal/refinery/testdata/x.txt"}, full)
+	if len(sel.Gates) != 0 {
+		t.Errorf("testdata change: gates %v, want none (first rule wins)", sel.Gates)
+	}
+
+	// One unmatched path takes the full list, whatever else matched.
+	sel = selectGates(rules, []string{"docs/a.md", "cmd/pogo/main.go"}, full)
+	if !sel.full || !reflect.DeepEqual(sel.Gates, full) {
+		t.Errorf("mixed change: gates %v (full=%v), want the full list", sel.Gates, sel.full)
+	}
+	if !strings.Contains(sel.Rule, "cmd/pogo/main.go") {
+		t.Errorf("full-gates rule should name the unmatched path, got %q", sel.Rule)
+	}
+
+	// No evidence, no narrowing.
+	if sel = selectGates(rules, nil, full); !sel.full {
+		t.Error("an empty diff must take the full gate list")
+	}
+}
+
+func TestParseRefineryTomlPathRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "refinery.toml")
+	os.WriteFile(path, []byte(`
+[gates]
+commands = ["./build.sh", "./test.sh"]
+
+[gates.paths]
+"docs/**"              = []
+"internal/refinery/**" = ["go test ./internal/refinery/...", "go vet ./internal/refinery/"]
+'*.md' = []
+`), 0644)
+	cfg := parseRefineryConfig(path)
+	if want := []string{"./build.sh", "./test.sh"}; !reflect.DeepEqual(cfg.Gates, want) {
+		t.Errorf("Gates = %v, want %v", cfg.Gates, want)
+	}
+	want := []gatePathRule{
+		{Glob: "docs/**", Gates: []string{}},
+		{Glob: "internal/refinery/**", Gates: []string{"go test ./internal/refinery/...", "go vet ./internal/refinery/"}},
+		{Glob: "*.md", Gates: []string{}},
+	}
+	if !reflect.DeepEqual(cfg.PathRules, want) {
+		t.Errorf("PathRules = %#v, want %#v", cfg.PathRules, want)
+	}
+}
+
+// TestParseRefineryTomlTrailingComments parses the example in
+// parseRefineryConfig's doc comment line for line. A trailing comment read as
+// part of the value once turned "docs/**" = [] into a one-command gate list
+// that ran the comment as a shell command.
+func TestParseRefineryTomlTrailingComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "refinery.toml")
+	os.WriteFile(path, []byte(`
+[gates]
+commands       = ["./build.sh", "./test.sh"]
+max_attempts   = 7      # ff-only retry budget; default 7 if omitted
+skip_on_retry  = true   # bypass gates on attempts > 1 (race recovery)
+pr_mode        = true   # push rebased branch back so open PRs read merged
+batch          = 4      # merge trains: gate up to 4 queued MRs at once
+
+[gates.paths]
+"docs/**"              = []   # docs-only changes need no gates
+"internal/refinery/**" = ["go test ./internal/refinery/..."]
+"notes/#*"             = ["echo '# not a comment'"] # this one is
+`), 0644)
+	cfg := parseRefineryConfig(path)
+	if cfg.MaxAttempts != 7 || !cfg.SkipGatesOnRetry || !cfg.PRMode || cfg.BatchSize != 4 {
+		t.Errorf("commented [gates] knobs = max_attempts %d, skip_on_retry %v, pr_mode %v, batch %d; want 7, true, true, 4",
+			cfg.MaxAttempts, cfg.SkipGatesOnRetry, cfg.PRMode, cfg.BatchSize)
+	}
+	want := []gatePathRule{
+		{Glob: "docs/**", Gates: []string{}},
+		{Glob: "internal/refinery/**", Gates: []string{"go test ./internal/refinery/..."}},
+		{Glob: "notes/#*", Gates: []string{"echo '# not a comment'"}},
+	}
+	if !reflect.DeepEqual(cfg.PathRules, want) {
+		t.Errorf("PathRules = %#v, want %#v", cfg.PathRules, want)
+	}
+}
+
+// TestPathRulesSkipGatesForDocsOnlyMerge runs two merges through a repo whose
+// full gate counts its runs: a docs-only change lands without it, and a code
+// change still pays for it in full.
+func TestPathRulesSkipGatesForDocsOnlyMerge(t *testing.T) {
+	originDir := initBareOrigin(t, "main")
+	counter := filepath.Join(t.TempDir(), "full-gate-runs")
+
+	workDir := t.TempDir()
+	run(t, workDir, "git", "clone", originDir, ".")
+	run(t, workDir, "git", "config", "user.email", "test@test.com")
+	run(t, workDir, "git", "config", "user.name", "Test")
+	os.MkdirAll(filepath.Join(workDir, ".pogo"), 0755)
+	os.WriteFile(filepath.Join(workDir, ".pogo", "refinery.toml"), []byte(`
+[gates]
+commands = ["sh full.sh"]
+
+[gates.paths]
+"docs/**" = []
+`), 0644)
+	os.WriteFile(filepath.Join(workDir, "full.sh"), []byte("echo run >> "+counter+"\n"), 0644)
+	run(t, workDir, "git", "add", ".")
+	run(t, workDir, "git", "commit", "-m", "gates")
+	run(t, workDir, "git", "push", "origin", "main")
+
+	for branch, file := range map[string]string{"docs-only": "docs/guide.md", "code": "main.go"} {
+		run(t, workDir, "git", "checkout", "-b", branch, "main")
+		os.MkdirAll(filepath.Dir(filepath.Join(workDir, file)), 0755)
+		os.WriteFile(filepath.Join(workDir, file), []byte(branch), 0644)
+		run(t, workDir, "git", "add", ".")
+		run(t, workDir, "git", "commit", "-m", "change "+file)
+		run(t, workDir, "git", "push", "origin", branch)
+	}
+
+	r, err := New(Config{Enabled: true, PollInterval: time.Hour, WorktreeDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	docsID, _ := r.Submit(MergeRequest{RepoPath: originDir, Branch: "docs-only", TargetRef: "main", Author: "cat-docs"})
+	r.processNext()
+	docs := r.Get(docsID)
+	if docs.Status != StatusMerged {
+		t.Fatalf("docs-only merge: status %s (error: %s)", docs.Status, docs.Error)
+	}
+	if _, err := os.Stat(counter); err == nil {
+		t.Error("the full gate ran for a docs-only change")
+	}
+	if !strings.Contains(docs.GateRule, "docs/**") || !strings.Contains(docs.GateRule, "no gates") {
+		t.Errorf("docs-only GateRule = %q, want the docs/** rule and no gates", docs.GateRule)
+	}
+	if !strings.Contains(docs.GateOutput, "docs/**") {
+		t.Errorf("gate output should say why no gates ran, got %q", docs.GateOutput)
+	}
+
+	codeID, _ := r.Submit(MergeRequest{RepoPath: originDir, Branch: "code", TargetRef: "main", Author: "cat-code"})
+	r.processNext()
+	code := r.Get(codeID)
+	if code.Status != StatusMerged {
+		t.Fatalf("code merge: status %s (error: %s)", code.Status, code.Error)
+	}
+	if data, _ := os.ReadFile(counter); strings.Count(string(data), "run") != 1 {
+		t.Errorf("full gate ran %d times for a code change, want 1", strings.Count(string(data), "run"))
+	}
+	if !strings.Contains(code.GateRule, "full gates") || !strings.Contains(code.GateRule, "main.go") {
+		t.Errorf("code GateRule = %q, want full gates naming main.go", code.GateRule)
+	}
+}
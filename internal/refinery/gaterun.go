@@ -5,10 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
-	"os/exec"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/drellem2/pogo/internal/hostload"
@@ -143,11 +140,11 @@ func (e *gateTimeoutError) contentionClause() string {
 // gateOutputWriter accumulates a gate's combined output while reporting every
 // write to the progress watch.
 //
-// It replaces cmd.CombinedOutput's internal buffer, and is assigned to both
-// Stdout and Stderr: os/exec reuses one pipe when those two interfaces are
-// equal, so ordering and interleaving match what CombinedOutput produced
-// before. The accumulated string is still returned in full and stored on the
-// MR — the watch adds a live count, it does not replace the output.
+// It replaces cmd.CombinedOutput's internal buffer and is the writer every
+// GateExecutor is handed, so a gate run by a separate gate runner feeds the
+// same live count and excerpt as one run here. The accumulated string is
+// still returned in full and stored on the MR — the watch adds a live count,
+// it does not replace the output.
 type gateOutputWriter struct {
 	buf   bytes.Buffer
 	watch *gateWatch
@@ -163,12 +160,12 @@ func (g *gateOutputWriter) Write(p []byte) (int, error) {
 	return n, err
 }
 
-// runGate runs one quality gate command in the worktree.
+// runGate runs one quality gate command in the worktree, under ex.
 //
 // The gate is watched for as long as it runs (see gateWatch) and bounded by
 // timeout when one is set. ctx cancellation kills the gate — that is what
 // makes cancelling an in-flight merge request possible.
-func runGate(ctx context.Context, wtDir, command string, timeout time.Duration, w *gateWatch) (string, error) {
+func runGate(ctx context.Context, ex GateExecutor, wtDir, command string, timeout time.Duration, w *gateWatch) (string, error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
@@ -183,46 +180,8 @@ func runGate(ctx context.Context, wtDir, command string, timeout time.Duration,
 	}
 
 	start := time.Now()
-	cmd := exec.CommandContext(ctx, "sh", "-c", command)
-	cmd.Dir = wtDir
-	cmd.Env = append(os.Environ(), "POGO_REFINERY=1")
-	cmd.WaitDelay = gateWaitDelay
-
-	// Run the gate in its own process group and kill the group, not just the
-	// shell. `sh -c "./build.sh"` forks rather than execs for anything
-	// compound, so killing the shell alone leaves the real work — a test
-	// binary, a compiler — running and still holding the output pipe open.
-	// Wait then blocks on that pipe until WaitDelay expires, so a timeout that
-	// should have taken effect at once instead stalls, and the killed work
-	// keeps consuming the worktree it was told to stop using.
-	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
-	cmd.Cancel = func() error {
-		if cmd.Process == nil {
-			return nil
-		}
-		// Setpgid makes the child's PGID equal its PID, so the negated PID
-		// addresses the whole group. Fall back to the single process if the
-		// group is already gone.
-		if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
-			return cmd.Process.Kill()
-		}
-		return nil
-	}
-
 	out := &gateOutputWriter{watch: w}
-	cmd.Stdout = out
-	cmd.Stderr = out
-
-	// Start/Wait rather than Run so the gate's pid can be published the moment
-	// it exists. The pid roots the process-subtree CPU measurement, which is
-	// the half of the liveness signal that output staleness cannot supply
-	// (mg-0c51) — without it a silent-but-computing gate is indistinguishable
-	// from a hung one.
-	var err error
-	if err = cmd.Start(); err == nil {
-		w.setPID(cmd.Process.Pid)
-		err = cmd.Wait()
-	}
+	err := ex.Run(ctx, GateSpec{Dir: wtDir, Command: command, Env: gateEnv}, out, w.setPID)
 	output := out.buf.String()
 
 	if err != nil && deadlineCause != nil && errors.Is(deadlineCause.Err(), context.DeadlineExceeded) {
@@ -0,0 +1,347 @@
+package refinery
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// The gate runner protocol (`[refinery] gate_runner`, `pogo gate-runner`).
+//
+// A quality gate is the branch's own code — build scripts, tests, whatever a
+// polecat committed — and by default it runs as a child of pogod, in the
+// refinery's clone, as the user pogod runs as. That is the most direct path
+// from a bad branch to the host: the gate can read every credential pogod can,
+// and write to the refinery's state beside the worktree.
+//
+// The runner moves the gate out of pogod without moving anything else. pogod
+// still owns the clone, the rebase, the push and every judgement about the
+// run; only the `sh -c <gate>` is handed over, to a separate process the
+// operator starts however isolation is wanted — as another user, in a
+// container that bind-mounts the worktree dir, under a sandbox profile. With
+// --copy the runner also gates a throwaway copy of the worktree instead of the
+// worktree itself, so nothing the gate writes reaches the refinery's clone.
+//
+// The wire is one unix-socket connection per gate run, newline-delimited JSON:
+//
+//	pogod  → runner   {"version":1,"dir":"…","command":"./build.sh","env":["POGO_REFINERY=1"]}
+//	runner → pogod    {"pid":4856}
+//	runner → pogod    {"output":"<base64 bytes>"}   (repeated, in order)
+//	runner → pogod    {"exit":{"code":1}}           (or "signal", or "error")
+//
+// Closing the connection is the kill. pogod closes it when the gate's context
+// is cancelled — its timeout or `pogo refinery cancel` — and the runner kills
+// the gate's process group the moment it reads EOF. So a runner never outlives
+// the question it was asked, and there is no cancel message to get lost.
+//
+// A runner that cannot be reached, or that drops the connection before an
+// exit, has established nothing about the branch: that is a gateRunnerError,
+// classed infrastructure and retried, never a red gate.
+//
+// The pid is in the runner's pid namespace. On the same host without a pid
+// namespace it roots the subtree CPU measurement exactly as a local gate's
+// does; where it means nothing here, the measurement reads as unavailable.
+
+// gateRunnerVersion is the protocol version a request carries. A runner
+// refuses a version it does not speak rather than guess.
+const gateRunnerVersion = 1
+
+// gateRunnerDialTimeout bounds connecting to the runner. The socket is local,
+// so anything slower than this is a runner that is not answering.
+const gateRunnerDialTimeout = 5 * time.Second
+
+// gateRunnerRequest is the one message pogod sends.
+type gateRunnerRequest struct {
+	Version int      `json:"version"`
+	Dir     string   `json:"dir"`
+	Command string   `json:"command"`
+	Env     []string `json:"env,omitempty"`
+}
+
+// gateRunnerMessage is one message from the runner. Exactly one field is set.
+type gateRunnerMessage struct {
+	PID    int             `json:"pid,omitempty"`
+	Output []byte          `json:"output,omitempty"`
+	Exit   *gateRunnerExit `json:"exit,omitempty"`
+}
+
+// gateRunnerExit is how a gate run ended. All zero is a pass.
+type gateRunnerExit struct {
+	Code int `json:"code,omitempty"`
+	// Signal is the signal that killed the gate, when one did.
+	Signal int `json:"signal,omitempty"`
+	// Error is set when the runner could not run the gate at all — the copy
+	// failed, sh would not start. It is the runner's failure, not the gate's.
+	Error string `json:"error,omitempty"`
+}
+
+// gateExitError is a gate's non-zero exit as reported by a gate runner. It
+// reads like the *exec.ExitError a local gate would have produced, so the
+// summary and the records are worded identically whichever executor ran it,
+// and signalThatKilled understands it.
+type gateExitError struct {
+	Code   int
+	Signal syscall.Signal
+}
+
+func (e *gateExitError) Error() string {
+	if e.Signal != 0 {
+		return "signal: " + e.Signal.String()
+	}
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// gateRunnerError reports that the gate runner, not the gate, failed: it could
+// not be reached, it hung up mid-run, or it could not start the gate. The gate
+// never returned a verdict on the branch.
+type gateRunnerError struct {
+	Socket string
+	// Op is what failed: "connect", "send", "stream" or "run".
+	Op  string
+	Err error
+}
+
+func (e *gateRunnerError) Error() string {
+	return fmt.Sprintf("gate runner at %s: %s failed: %v — THIS IS NOT A VERDICT ON THE BRANCH: the gate did not "+
+		"report a result. Check that `pogo gate-runner` is running on that socket", e.Socket, e.Op, e.Err)
+}
+
+func (e *gateRunnerError) Unwrap() error { return e.Err }
+
+// socketGateExecutor hands each gate to a `pogo gate-runner` listening on a
+// unix socket.
+type socketGateExecutor struct {
+	socket string
+}
+
+func (s socketGateExecutor) Run(ctx context.Context, spec GateSpec, out io.Writer, started func(pid int)) error {
+	d := net.Dialer{Timeout: gateRunnerDialTimeout}
+	conn, err := d.DialContext(ctx, "unix", s.socket)
+	if err != nil {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		return &gateRunnerError{Socket: s.socket, Op: "connect", Err: err}
+	}
+	defer conn.Close()
+	// Closing the connection is the kill; see the overview.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	req := gateRunnerRequest{Version: gateRunnerVersion, Dir: spec.Dir, Command: spec.Command, Env: spec.Env}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		return &gateRunnerError{Socket: s.socket, Op: "send", Err: err}
+	}
+
+	dec := json.NewDecoder(bufio.NewReader(conn))
+	for {
+		var msg gateRunnerMessage
+		if err := dec.Decode(&msg); err != nil {
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+			if errors.Is(err, io.EOF) {
+				err = errors.New("connection closed before the gate exited")
+			}
+			return &gateRunnerError{Socket: s.socket, Op: "stream", Err: err}
+		}
+		switch {
+		case msg.Exit != nil:
+			switch {
+			case msg.Exit.Error != "":
+				return &gateRunnerError{Socket: s.socket, Op: "run", Err: errors.New(msg.Exit.Error)}
+			case msg.Exit.Signal != 0:
+				return &gateExitError{Signal: syscall.Signal(msg.Exit.Signal)}
+			case msg.Exit.Code != 0:
+				return &gateExitError{Code: msg.Exit.Code}
+			}
+			return nil
+		case msg.PID > 0:
+			started(msg.PID)
+		case len(msg.Output) > 0:
+			out.Write(msg.Output)
+		}
+	}
+}
+
+// GateRunnerOptions configures ServeGateRunner.
+type GateRunnerOptions struct {
+	// Copy gates a temporary copy of the requested worktree rather than the
+	// worktree itself, removed when the gate exits.
+	Copy bool
+	// TempDir is where copies are made. Empty means os.TempDir().
+	TempDir string
+}
+
+// ServeGateRunner answers gate runs on ln until ctx is cancelled, running each
+// gate as a child of this process. It is the body of `pogo gate-runner`.
+func ServeGateRunner(ctx context.Context, ln net.Listener, opts GateRunnerOptions) error {
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serveGateRun(ctx, conn, opts)
+		}()
+	}
+}
+
+// runnerStream writes runner messages to one connection. The gate's output
+// and the pid arrive from different goroutines, and each message must reach
+// the wire whole.
+type runnerStream struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (s *runnerStream) send(msg gateRunnerMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(msg)
+}
+
+// Write sends a copy of p as an output message. A write error is the client
+// gone; the gate is being killed by then, so it is reported as written.
+func (s *runnerStream) Write(p []byte) (int, error) {
+	s.send(gateRunnerMessage{Output: append([]byte(nil), p...)})
+	return len(p), nil
+}
+
+// serveGateRun runs the one gate a connection asks for.
+func serveGateRun(ctx context.Context, conn net.Conn, opts GateRunnerOptions) {
+	defer conn.Close()
+	stream := &runnerStream{enc: json.NewEncoder(conn)}
+
+	br := bufio.NewReader(conn)
+	var req gateRunnerRequest
+	if err := json.NewDecoder(br).Decode(&req); err != nil {
+		log.Printf("gate-runner: unreadable request: %v", err)
+		return
+	}
+	if req.Version != gateRunnerVersion {
+		stream.send(gateRunnerMessage{Exit: &gateRunnerExit{
+			Error: fmt.Sprintf("protocol version %d is not supported (this runner speaks %d)", req.Version, gateRunnerVersion)}})
+		return
+	}
+
+	// The client hanging up is the kill: nothing more is ever sent on this
+	// connection, so a read that returns at all means it has gone.
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		io.Copy(io.Discard, br)
+		cancel()
+	}()
+
+	dir := req.Dir
+	if opts.Copy {
+		tmp, err := os.MkdirTemp(opts.TempDir, "pogo-gate-*")
+		if err == nil {
+			defer os.RemoveAll(tmp)
+			err = copyTree(req.Dir, tmp)
+		}
+		if err != nil {
+			stream.send(gateRunnerMessage{Exit: &gateRunnerExit{Error: "copy worktree: " + err.Error()}})
+			return
+		}
+		dir = tmp
+	}
+
+	start := time.Now()
+	log.Printf("gate-runner: running %q in %s", req.Command, dir)
+	err := localGateExecutor{}.Run(runCtx, GateSpec{Dir: dir, Command: req.Command, Env: req.Env}, stream,
+		func(pid int) { stream.send(gateRunnerMessage{PID: pid}) })
+	exit := &gateRunnerExit{}
+	var ee *exec.ExitError
+	switch {
+	case err == nil:
+	case runCtx.Err() != nil:
+		log.Printf("gate-runner: %q killed after %s: the client went away", req.Command, roundDur(time.Since(start)))
+		return
+	case errors.As(err, &ee):
+		if sig, ok := signalThatKilled(err); ok {
+			exit.Signal = int(sig)
+		} else {
+			exit.Code = ee.ExitCode()
+		}
+	default:
+		exit.Error = err.Error()
+	}
+	log.Printf("gate-runner: %q finished after %s: %+v", req.Command, roundDur(time.Since(start)), *exit)
+	stream.send(gateRunnerMessage{Exit: exit})
+}
+
+// copyTree copies the directory src into dst, which must exist: regular files
+// with their modes, directories, and symlinks as symlinks. Anything else —
+// sockets, devices — is skipped, as no gate needs one from a checkout.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.Mkdir(target, info.Mode().Perm())
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
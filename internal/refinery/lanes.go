@@ -69,6 +69,34 @@ type lane struct {
 	cancel context.CancelFunc
 	// cancelRequested records that someone asked this merge to stop.
 	cancelRequested bool
+
+	// riders are the merge requests that joined mr in a merge train (see
+	// batch.go). Empty for an ordinary one-request lane, which is every lane
+	// in a repo that has not opted into [gates] batch.
+	riders []*MergeRequest
+	// cancelledIDs records per-member cancels for a train. A train member is
+	// cancelled on its own rather than through cancelRequested, because that
+	// flag stops the whole lane — and stopping four merges to honour a cancel
+	// of one is the broadcast per-lane handles were introduced to prevent.
+	cancelledIDs map[string]bool
+	// runIDs/runCtx/runCancel describe the unit of a train currently being
+	// gated: the whole candidate, a bisected half, or one member on its own.
+	// A member's cancel kills the run only when that member is part of it;
+	// otherwise the member is dropped before its turn and nothing running is
+	// disturbed.
+	runIDs    map[string]bool
+	runCtx    context.Context
+	runCancel context.CancelFunc
+}
+
+// members returns every merge request the lane holds: its head, then any
+// train riders in the order they were stacked.
+func (ln *lane) members() []*MergeRequest {
+	out := make([]*MergeRequest, 0, 1+len(ln.riders))
+	if ln.mr != nil {
+		out = append(out, ln.mr)
+	}
+	return append(out, ln.riders...)
 }
 
 // LaneStatus reports one in-flight merge, with the repo whose lane it holds.
@@ -104,7 +132,18 @@ func (r *Refinery) maxLanes() int {
 func (r *Refinery) inFlightLocked() []*MergeRequest {
 	out := make([]*MergeRequest, 0, len(r.lanes))
 	for _, ln := range r.lanes {
-		out = append(out, ln.mr)
+		if len(ln.riders) == 0 {
+			out = append(out, ln.mr)
+			continue
+		}
+		// A train resolves its members one at a time while the lane stays
+		// held, so a member already merged or failed is history, not in
+		// flight — listing it here would persist it twice.
+		for _, mr := range ln.members() {
+			if mr.Status == StatusProcessing {
+				out = append(out, mr)
+			}
+		}
 	}
 	sort.Slice(out, func(i, j int) bool {
 		if out[i].StartTime.Equal(out[j].StartTime) {
@@ -119,8 +158,10 @@ func (r *Refinery) inFlightLocked() []*MergeRequest {
 // nil. Must be called with mu held.
 func (r *Refinery) laneHoldingLocked(id string) *lane {
 	for _, ln := range r.lanes {
-		if ln.mr != nil && ln.mr.ID == id {
-			return ln
+		for _, mr := range ln.members() {
+			if mr.ID == id {
+				return ln
+			}
 		}
 	}
 	return nil
@@ -143,6 +184,8 @@ func (r *Refinery) laneHoldingLocked(id string) *lane {
 // IS the change, and it is bounded: it can only ever be by a merge that could
 // not have contended with the one it passed.
 func (r *Refinery) claimLane(examined map[string]bool) (*lane, *MergeRequest) {
+	var orphans []*MergeRequest
+	defer func() { r.emitDependencyCancels(orphans) }()
 	// After the unlock (LIFO). The claim is the moment an item stops being
 	// queued and starts being in-flight; the file has to record that before
 	// the merge runs, or a crash mid-gate leaves it in neither place. The wait
@@ -154,10 +197,19 @@ func (r *Refinery) claimLane(examined map[string]bool) (*lane, *MergeRequest) {
 	if r.stopping || len(r.lanes) >= r.maxLanes() {
 		return nil, nil
 	}
+	if orphans = r.cancelOrphansLocked(); len(orphans) > 0 {
+		r.saveStateLocked()
+	}
 	for i, mr := range r.queue {
 		if examined[mr.ID] {
 			continue
 		}
+		// A stacked branch waits for the one it builds on (dependson.go). It
+		// is passed over, not a lane-holder: the rest of its repo's queue
+		// keeps moving.
+		if state, _ := r.dependencyStateLocked(mr); state != depReady {
+			continue
+		}
 		key := laneKey(mr.RepoPath)
 		if _, busy := r.lanes[key]; busy {
 			continue
@@ -252,10 +304,26 @@ func (r *Refinery) runLane(ln *lane, mr *MergeRequest) {
 	log.Printf("refinery: processing MR %s branch=%s repo-lane=%s (%d/%d lanes busy)",
 		mr.ID, mr.Branch, ln.key, r.laneCount(), r.maxLanes())
 
+	if train := r.formTrain(ln, mr); len(train) > 1 {
+		r.notifyTransition(train...)
+		r.runTrain(ln, train)
+		return
+	}
+	r.notifyTransition(mr)
+
 	outcome, err := r.processMerge(mr)
+	r.resolveMerge(ln, mr, outcome, err, true)
+}
 
+// resolveMerge records one merge request's outcome, moves it into history and
+// fires its terminal callback. endLane releases ln first, which is what a
+// one-request lane wants; a merge train passes false, because the lane stays
+// held until its last member resolves.
+func (r *Refinery) resolveMerge(ln *lane, mr *MergeRequest, outcome mergeResult, err error, endLane bool) {
 	r.mu.Lock()
-	r.endLaneLocked(ln)
+	if endLane {
+		r.endLaneLocked(ln)
+	}
 	// Capped BEFORE it reaches the record, which is what gets persisted. An
 	// uncapped assignment here was 93% of a 6.3 MB state file, re-marshalled
 	// and re-fsynced on every save (mg-538e). The cut is self-describing —
@@ -318,11 +386,18 @@ func (r *Refinery) runLane(ln *lane, mr *MergeRequest) {
 		}
 	}
 	r.history = append(r.history, mr)
+	// A request that will never merge takes the branches stacked on it with
+	// it (dependson.go).
+	var dependents []*MergeRequest
+	if err != nil {
+		dependents = r.cancelDependentsLocked(mr)
+	}
 	r.pruneHistoryLocked()
 	r.saveStateLocked()
 	onMerged := r.onMerged
 	onFailed := r.onFailed
 	r.mu.Unlock()
+	r.emitDependencyCancels(dependents)
 
 	// A terminal resolution is write-through: the callback below marks a work
 	// item done, and a state file that still calls this merge in-flight would
@@ -339,6 +414,7 @@ func (r *Refinery) runLane(ln *lane, mr *MergeRequest) {
 	case err == nil && onMerged != nil:
 		onMerged(mr)
 	}
+	r.notifyTransition(mr)
 }
 
 // laneCount returns how many merges are in flight.
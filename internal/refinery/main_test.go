@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/drellem2/pogo/internal/events"
+	"github.com/drellem2/pogo/internal/platform/sandbox"
 	"github.com/drellem2/pogo/internal/testtmp"
 )
 
@@ -37,6 +38,12 @@ var testEventLogPath string
 // take precedence over auto-derivation but are overridden by per-repo
 // `git config user.email` calls in tests that need a specific identity.
 func TestMain(m *testing.M) {
+	// Sandboxed gates re-execute this test binary as their wrapper, as they
+	// do pogod (user-012).
+	if len(os.Args) > 1 && os.Args[1] == sandbox.Arg {
+		os.Exit(sandbox.Run(os.Args[2:]))
+	}
+
 	// Clear any ambient POGO_HOME (e.g. from the developer's shell) so
 	// PogoHome-derived defaults resolve under the per-test HOME overrides
 	// instead of the real state dir (mg-3dc3). Tests that exercise POGO_HOME
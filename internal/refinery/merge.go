@@ -1116,11 +1116,25 @@ func (r *Refinery) runQualityGates(ctx context.Context, wtDir, repoPath string,
 	if len(gates) == 0 {
 		gates, note = defaultGates(wtDir)
 	}
+	if len(cfg.PathRules) > 0 && mr != nil {
+		sel := r.selectGatesFor(wtDir, mr, cfg.PathRules, gates)
+		if !sel.full {
+			gates, note = sel.Gates, ""
+		}
+		if len(gates) == 0 {
+			return "(no quality gates: " + sel.Rule + ")", nil, nil
+		}
+		if note != "" {
+			note += "\n"
+		}
+		note += "(gate selection: " + sel.Rule + ")"
+	}
 	if len(gates) == 0 {
 		// No gates configured — pass by default
 		return "(no quality gates configured)", nil, nil
 	}
 	timeout := cfg.gateTimeout()
+	ex := r.gateExecutor()
 
 	var allOutput strings.Builder
 	// A gate the defaults dropped is said out loud in the merge's own output.
@@ -1139,8 +1153,11 @@ func (r *Refinery) runQualityGates(ctx context.Context, wtDir, repoPath string,
 			deadline = time.Now().Add(timeout)
 		}
 		watch := startGateWatch(r, mr, "quality-gates", gate, i+1, len(gates), deadline)
-		output, err := runGate(ctx, wtDir, gate, timeout, watch)
+		gateStart := time.Now()
+		output, err := runGate(ctx, ex, wtDir, gate, timeout, watch)
 		watch.finish()
+		observeGate(time.Since(gateStart), err)
+		traceGate(mr, gate, gateStart, err)
 
 		allOutput.WriteString(output)
 		allOutput.WriteString("\n")
@@ -1170,6 +1187,13 @@ func (r *Refinery) runQualityGates(ctx context.Context, wtDir, repoPath string,
 			if errors.As(err, &sigErr) {
 				return allOutput.String(), ran, sigErr
 			}
+			// The gate RUNNER failed — unreachable, or gone before the gate
+			// exited. Returned as-is for the same reason: whatever output
+			// arrived is a fragment, and the summary would read it as findings.
+			var runnerErr *gateRunnerError
+			if errors.As(err, &runnerErr) {
+				return allOutput.String(), ran, runnerErr
+			}
 			// The GATE could not reach the network. Judged here for the same two
 			// reasons the host-resource error is (mg-67c9, gatenetwork.go): this
 			// is the last place the FULL output exists, and the summary below
@@ -1326,6 +1350,12 @@ func (r *Refinery) loadConfig(wtDir, repoPath string) refineryConfig {
 	if !wt.GateTimeoutSet {
 		wt.GateTimeout, wt.GateTimeoutSet = orig.GateTimeout, orig.GateTimeoutSet
 	}
+	if wt.BatchSize == 0 {
+		wt.BatchSize = orig.BatchSize
+	}
+	if len(wt.PathRules) == 0 {
+		wt.PathRules = orig.PathRules
+	}
 	return wt
 }
 
@@ -1343,6 +1373,14 @@ type refineryConfig struct {
 	// bound instead of taking the default.
 	GateTimeout    time.Duration
 	GateTimeoutSet bool
+	// BatchSize is the [gates] batch merge-train length: how many queued
+	// merge requests for one lane may be stacked and gated together. 0 or 1
+	// means batching is off, which is the default — see batch.go.
+	BatchSize int
+	// PathRules are the [gates.paths] rules, in file order: which gates a
+	// change to which paths needs. Empty means every merge runs Gates in
+	// full — see gaterules.go.
+	PathRules []gatePathRule
 }
 
 // parseRefineryToml reads a .pogo/refinery.toml and extracts gate commands.
@@ -1366,6 +1404,11 @@ func parseRefineryToml(path string) []string {
 //	max_attempts   = 7      # ff-only retry budget; default 7 if omitted
 //	skip_on_retry  = true   # bypass gates on attempts > 1 (race recovery)
 //	pr_mode        = true   # push rebased branch back so open PRs read merged
+//	batch          = 4      # merge trains: gate up to 4 queued MRs at once
+//
+//	[gates.paths]
+//	"docs/**"              = []   # docs-only changes need no gates
+//	"internal/refinery/**" = ["go test ./internal/refinery/..."]
 //
 //	[deploy]
 //	command = "./deploy.sh"
@@ -1386,8 +1429,8 @@ func parseRefineryConfig(path string) refineryConfig {
 	section := ""
 
 	for _, line := range strings.Split(string(data), "\n") {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "#") {
+		line = strings.TrimSpace(stripTomlComment(line))
+		if line == "" {
 			continue
 		}
 
@@ -1409,14 +1452,16 @@ func parseRefineryConfig(path string) refineryConfig {
 			cfg.Gates = append(cfg.Gates, val)
 		case section == "gates" && key == "commands":
 			// Parse simple array: ["./build.sh", "./test.sh"]
-			arr := strings.Trim(val, "[]")
-			for _, cmd := range strings.Split(arr, ",") {
-				cmd = strings.TrimSpace(cmd)
-				cmd = strings.Trim(cmd, "\"")
-				if cmd != "" {
-					cfg.Gates = append(cfg.Gates, cmd)
-				}
+			cfg.Gates = append(cfg.Gates, parseTomlStringArray(val)...)
+		case section == "gates.paths":
+			// "glob" = ["cmd", ...]. The glob is quoted in TOML because it
+			// holds characters a bare key cannot.
+			glob := strings.Trim(key, "\"'")
+			if glob == "" || !strings.HasPrefix(val, "[") {
+				log.Printf("refinery: ignoring unreadable [gates.paths] rule %q in %s — paths it would have matched run the full gates", line, path)
+				continue
 			}
+			cfg.PathRules = append(cfg.PathRules, gatePathRule{Glob: glob, Gates: parseTomlStringArray(val)})
 		case section == "gates" && key == "max_attempts":
 			if n, err := strconv.Atoi(val); err == nil && n > 0 {
 				cfg.MaxAttempts = n
@@ -1432,6 +1477,12 @@ func parseRefineryConfig(path string) refineryConfig {
 			} else {
 				log.Printf("refinery: ignoring unreadable [gates] timeout %q in %s — keeping the %s default", val, path, defaultGateTimeout)
 			}
+		case section == "gates" && key == "batch":
+			if n, ok := parseBatchSize(val); ok {
+				cfg.BatchSize = n
+			} else {
+				log.Printf("refinery: ignoring unreadable [gates] batch %q in %s — merge trains stay off", val, path)
+			}
 		case key == "pr_mode":
 			// Accepted top-level or under [gates] — the ticket and design
 			// doc cite both spellings (mg-b828).
@@ -1444,6 +1495,28 @@ func parseRefineryConfig(path string) refineryConfig {
 	return cfg
 }
 
+// stripTomlComment drops a trailing `# comment` from a line of refinery.toml,
+// as the documented examples above write them. A # inside a quoted string — a
+// gate command or a path glob may hold one — is not a comment.
+func stripTomlComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
 // parseTomlBool parses a TOML-ish bool from a string. Accepts true/false
 // (case-insensitive) and 1/0. Anything else is treated as false.
 func parseTomlBool(val string) bool {
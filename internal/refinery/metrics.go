@@ -0,0 +1,96 @@
+package refinery
+
+import (
+	"errors"
+	"time"
+
+	"github.com/drellem2/pogo/internal/metrics"
+)
+
+// The refinery half of pogod's /metrics (user-022).
+//
+// Outcomes are counted in the emit* functions in events.go, the one place
+// every merge request's end already passes through, so the counters and the
+// event log cannot disagree about what happened. The queue itself is read at
+// scrape time; see RegisterMetricsFunc.
+var (
+	mergesTotal = metrics.NewCounter("pogo_refinery_merges_total",
+		"Merge requests resolved, by outcome: merged, failed (the refinery gave up) or cancelled.", "outcome")
+	attemptFailuresTotal = metrics.NewCounter("pogo_refinery_attempt_failures_total",
+		"Failed merge attempts, retried or not, by the stage that failed and its failure class.", "stage", "class")
+	mergeLatency = metrics.NewHistogram("pogo_refinery_merge_latency_seconds",
+		"Seconds from submission to landing, queue wait included, for each merged request.",
+		metrics.DurationBuckets)
+	gateDuration = metrics.NewHistogram("pogo_refinery_gate_duration_seconds",
+		"Seconds each quality gate command ran, by result: passed, failed, timeout, signalled or cancelled.",
+		metrics.DurationBuckets, "result")
+)
+
+// observeGate records one gate run.
+func observeGate(elapsed time.Duration, err error) {
+	gateDuration.Observe(elapsed.Seconds(), gateResult(err))
+}
+
+// gateResult names how a gate run ended, from the error runGate returned.
+func gateResult(err error) string {
+	var timeout *gateTimeoutError
+	var signalled *gateSignalError
+	switch {
+	case err == nil:
+		return "passed"
+	case errors.As(err, &timeout):
+		return "timeout"
+	case errors.As(err, &signalled):
+		return "signalled"
+	case errors.Is(err, errCancelRequested):
+		return "cancelled"
+	}
+	return "failed"
+}
+
+// observeMerged counts a landed merge request and its submit-to-land latency.
+// A request submitted before this pogod started still has its SubmitTime from
+// the persisted queue, so its latency is the real one.
+func observeMerged(mr *MergeRequest) {
+	mergesTotal.Inc("merged")
+	if !mr.SubmitTime.IsZero() {
+		mergeLatency.Observe(time.Since(mr.SubmitTime).Seconds())
+	}
+}
+
+// RegisterMetricsFunc publishes the queue of whichever refinery get returns
+// at scrape time. It takes a getter rather than a *Refinery for the reason
+// RegisterHandlersFunc does: pogod replaces its refinery when orchestration
+// restarts, and the gauges must read the live one (#9).
+func RegisterMetricsFunc(get func() *Refinery) {
+	status := func(emit metrics.Emit, read func(Status) float64) {
+		if r := get(); r != nil {
+			emit(read(r.GetStatus()))
+		}
+	}
+	metrics.SetGaugeFunc("pogo_refinery_queue_depth",
+		"Merge requests waiting for a lane, not counting those in flight.", nil,
+		func(emit metrics.Emit) {
+			status(emit, func(s Status) float64 { return float64(s.QueueLen) })
+		})
+	metrics.SetGaugeFunc("pogo_refinery_in_flight",
+		"Merge requests being merged now, one per busy lane.", nil,
+		func(emit metrics.Emit) {
+			status(emit, func(s Status) float64 { return float64(s.ProcessingCount) })
+		})
+	metrics.SetGaugeFunc("pogo_refinery_lanes_max",
+		"The lane cap: how many merges may run at once.", nil,
+		func(emit metrics.Emit) {
+			status(emit, func(s Status) float64 { return float64(s.MaxConcurrentMerges) })
+		})
+	metrics.SetGaugeFunc("pogo_refinery_oldest_in_flight_seconds",
+		"Seconds the longest-running in-flight merge has been running; 0 when idle.", nil,
+		func(emit metrics.Emit) {
+			status(emit, func(s Status) float64 {
+				if s.ProcessingSince.IsZero() {
+					return 0
+				}
+				return time.Since(s.ProcessingSince).Seconds()
+			})
+		})
+}
@@ -0,0 +1,90 @@
+package refinery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drellem2/pogo/internal/metrics"
+)
+
+func TestGateResultNamesHowAGateEnded(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want string
+	}{
+		{nil, "passed"},
+		{errors.New("exit status 1"), "failed"},
+		{&gateTimeoutError{Gate: "make test", Timeout: time.Minute}, "timeout"},
+		{&gateSignalError{Gate: "make test"}, "signalled"},
+		{fmt.Errorf("gate %q killed by cancellation: %w", "make test", errCancelRequested), "cancelled"},
+		{context.Canceled, "failed"},
+	} {
+		if got := gateResult(tc.err); got != tc.want {
+			t.Errorf("gateResult(%v) = %s, want %s", tc.err, got, tc.want)
+		}
+	}
+}
+
+// Outcomes are counted by the same calls that write them to the event log.
+func TestOutcomeEventsAreCounted(t *testing.T) {
+	useTempEventLog(t)
+	merged, failed, cancelled := mergesTotal.Value("merged"), mergesTotal.Value("failed"), mergesTotal.Value("cancelled")
+	retried := attemptFailuresTotal.Value("quality-gates", string(ClassDefect))
+	latencies := mergeLatency.Count()
+
+	mr := &MergeRequest{ID: "mr-1", Author: "cat-mg-1", SubmitTime: time.Now().Add(-time.Minute)}
+	emitMergeFailed(mr, 1, "quality-gates", errors.New("boom"), false, "", AttemptFailure{Class: ClassDefect})
+	emitMergeFailed(mr, 2, "quality-gates", errors.New("boom"), true, "", AttemptFailure{Class: ClassDefect})
+	emitMergeCancelled(mr, 1, "before-attempt", "")
+	emitMerged(mr, 3, "abc123", 12, false)
+
+	for _, c := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"merged", mergesTotal.Value("merged") - merged, 1},
+		{"failed (terminal only)", mergesTotal.Value("failed") - failed, 1},
+		{"cancelled", mergesTotal.Value("cancelled") - cancelled, 1},
+		{"failed attempts", attemptFailuresTotal.Value("quality-gates", string(ClassDefect)) - retried, 2},
+		{"latencies", float64(mergeLatency.Count() - latencies), 1},
+	} {
+		if c.got != c.want {
+			t.Errorf("%s: counted %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+// The queue gauges read whichever refinery the getter returns, and say
+// nothing while there is none.
+func TestRegisterMetricsFuncReadsTheLiveRefinery(t *testing.T) {
+	var live *Refinery
+	RegisterMetricsFunc(func() *Refinery { return live })
+	scrape := func() string {
+		var b strings.Builder
+		if err := metrics.Default.WriteText(&b); err != nil {
+			t.Fatal(err)
+		}
+		return b.String()
+	}
+	if out := scrape(); strings.Contains(out, "\npogo_refinery_queue_depth ") {
+		t.Errorf("a disabled refinery reported a queue depth:\n%s", out)
+	}
+
+	r, err := New(Config{WorktreeDir: t.TempDir(), StatePath: filepath.Join(t.TempDir(), "state.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.mu.Lock()
+	r.queue = append(r.queue, &MergeRequest{ID: "a"}, &MergeRequest{ID: "b"})
+	r.mu.Unlock()
+	live = r
+	if out := scrape(); !strings.Contains(out, "pogo_refinery_queue_depth 2\n") ||
+		!strings.Contains(out, "pogo_refinery_in_flight 0\n") {
+		t.Errorf("scrape:\n%s", out)
+	}
+}
@@ -108,11 +108,13 @@ func (r *Refinery) resolveRecoveredOne(mr *MergeRequest) {
 
 	if probeErr != nil {
 		emitRecoveryLost(mr, probeErr)
+		r.notifyLost(*mr, probeErr.Error())
 	} else if merged {
 		emitMerged(mr, 0, sha, 0, false)
 		if fire != nil {
 			fire(mr)
 		}
+		r.notifyTransition(mr)
 	}
 }
 
@@ -23,6 +23,8 @@ import (
 
 	"github.com/drellem2/pogo/internal/config"
 	"github.com/drellem2/pogo/internal/hostload"
+	"github.com/drellem2/pogo/internal/platform/cgroup"
+	"github.com/drellem2/pogo/internal/platform/sandbox"
 )
 
 // DefaultPollInterval is how often the refinery checks for new merge requests
@@ -73,6 +75,25 @@ type Config struct {
 	// persisted so it survives pogod restarts. Empty disables persistence
 	// (used by most unit tests). Default: ~/.pogo/refinery-state.json
 	StatePath string
+	// GateRunner is the unix socket of a `pogo gate-runner` to run quality
+	// gates in, instead of as children of this process (gaterunner.go).
+	// Empty runs them here.
+	GateRunner string
+	// Sandbox is the profile quality gates run under when they run in this
+	// process (user-012); its Root is filled in with each run's worktree. The
+	// zero value runs them unconfined. A gate runner does its own isolating
+	// and is not affected.
+	Sandbox sandbox.Profile
+	// Cgroups, when set, is the delegated cgroup v2 subtree each gate run is
+	// started in a group of its own under, held to GateLimits (user-013) — a
+	// worker's budget, so a gate cannot take the box the workers are being
+	// held off. Nil runs gates unlimited. Like Sandbox, it applies only to
+	// gates run in this process.
+	Cgroups    *cgroup.Manager
+	GateLimits cgroup.Limits
+	// Webhooks configures outbound HTTP notifications of merge request
+	// transitions (webhooks.go). No URLs disables them.
+	Webhooks WebhookConfig
 }
 
 // DefaultConfig returns a Config with sensible defaults. Pogo state paths
@@ -89,6 +110,9 @@ func DefaultConfig() Config {
 		WorktreeDir:  filepath.Join(pogoHome, "refinery", "worktrees"),
 		MacguffinDir: filepath.Join(home, ".macguffin", "work"),
 		StatePath:    filepath.Join(pogoHome, "refinery-state.json"),
+		Webhooks: WebhookConfig{
+			DeadLetterPath: filepath.Join(pogoHome, "refinery-webhooks-dead.jsonl"),
+		},
 	}
 }
 
@@ -161,6 +185,28 @@ type MergeRequest struct {
 	// MR resolves as StatusMerged — so poll loops keying off status terminate
 	// normally — but gates, push, and deploy were skipped as no-ops.
 	AlreadyMerged bool `json:"already_merged,omitempty"`
+	// Train names the merge train this request rode in when its repo opts
+	// into [gates] batch (see batch.go), and TrainNote says what the train
+	// did with it: landed on one shared gate run, re-gated alone after the
+	// train's gate failed, or left out of the candidate and merged on its own.
+	// Both are empty for a request that was merged by itself from the start.
+	//
+	// The note is on the record because a train's gate output is shared: a
+	// reader looking at a failure needs to know whether the verdict was on
+	// this branch alone or on a candidate it was stacked into.
+	Train     string `json:"train,omitempty"`
+	TrainNote string `json:"train_note,omitempty"`
+	// GateRule records which gates the repo's [gates.paths] rules chose for
+	// this merge and why — the rule that matched the rebased diff, or the path
+	// that forced the full list (see gaterules.go). Empty when the repo has no
+	// path rules and every merge runs its gates in full.
+	GateRule string `json:"gate_rule,omitempty"`
+	// DependsOn is the ID of a merge request that must MERGE before this one
+	// may start — set by `pogo refinery submit --after` for a branch stacked
+	// on another. Until then this request stays queued and dispatch passes
+	// over it; if the dependency fails or is cancelled, so is this request
+	// (see dependson.go).
+	DependsOn string `json:"depends_on,omitempty"`
 	// DeployError is set when a post-merge deploy hook ran and failed. The
 	// merge itself still succeeded (Status remains StatusMerged); deploy
 	// failure is surfaced for diagnostics, not rolled back. Empty when no
@@ -361,6 +407,14 @@ type Refinery struct {
 	// Override in tests to control time.
 	nowFunc func() time.Time
 
+	// webhooks delivers status transitions to [refinery.webhooks]; nil when
+	// none are configured (webhooks.go).
+	webhooks *webhookSender
+
+	// gateExec runs quality gates; nil means localGateExecutor. Set from
+	// Config.GateRunner, or by SetGateExecutor.
+	gateExec GateExecutor
+
 	// heartbeatInterval overrides gateHeartbeatInterval. Zero means use the
 	// package default; tests set it short so a beat is observable.
 	heartbeatInterval time.Duration
@@ -424,6 +478,9 @@ func New(cfg Config) (*Refinery, error) {
 		nowFunc:       time.Now,
 		wakeCh:        make(chan struct{}, 1),
 	}
+	if cfg.GateRunner != "" {
+		r.gateExec = socketGateExecutor{socket: cfg.GateRunner}
+	}
 	if cfg.StatePath != "" {
 		r.store = &store{path: cfg.StatePath}
 		if err := r.loadState(); err != nil {
@@ -436,6 +493,7 @@ func New(cfg Config) (*Refinery, error) {
 	// this was ONE polecat that had forked three compute processes, and any
 	// count of agents reads that as an idle host.
 	r.loadSampler = &hostload.Reader{Roots: []int{os.Getpid()}}
+	r.webhooks = newWebhookSender(cfg.Webhooks)
 	return r, nil
 }
 
@@ -743,9 +801,30 @@ func (r *Refinery) Submit(req MergeRequest) (string, error) {
 	// must still find the queued MR on restart. That promise is now kept out
 	// here, with r.mu released, instead of by fsyncing under it (mg-538e).
 	defer r.flushState()
+	// The queued webhook and the OnSubmit callback go out the same way: what
+	// they need is captured under the lock below, and this sends it once the
+	// lock is released. No user of the callback today — see OnSubmit.
+	var (
+		queued   *MergeRequest
+		state    WebhookMergeState
+		onSubmit OnSubmit
+	)
+	defer func() {
+		if queued == nil {
+			return
+		}
+		r.webhooks.send(state)
+		if onSubmit != nil {
+			onSubmit(queued)
+		}
+	}()
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	if err := r.validateDependencyLocked(&req); err != nil {
+		return "", err
+	}
+
 	req.ID = generateID()
 	req.Status = StatusQueued
 	req.SubmitTime = time.Now()
@@ -753,20 +832,13 @@ func (r *Refinery) Submit(req MergeRequest) (string, error) {
 	r.queue = append(r.queue, &req)
 	r.byID[req.ID] = &req
 	r.saveStateLocked()
-	onSubmit := r.onSubmit
+	queued, state, onSubmit = &req, webhookStateLocked(&req), r.onSubmit
 
 	log.Printf("refinery: queued MR %s branch=%s repo=%s author=%s", req.ID, req.Branch, req.RepoPath, req.Author)
 
 	// Wake the queue loop so pickup doesn't wait out the poll interval.
 	r.wake()
 
-	// Fire OnSubmit callback outside the lock. No user today — see OnSubmit.
-	if onSubmit != nil {
-		r.mu.Unlock()
-		onSubmit(&req)
-		r.mu.Lock()
-	}
-
 	return req.ID, nil
 }
 
@@ -799,6 +871,9 @@ func (r *Refinery) wakeIfActionable() {
 			if mr.Status == StatusHeld {
 				continue
 			}
+			if state, _ := r.dependencyStateLocked(mr); state != depReady {
+				continue
+			}
 			if _, busy := r.lanes[laneKey(mr.RepoPath)]; busy {
 				continue
 			}
@@ -1029,6 +1104,9 @@ func (r *Refinery) Stop() {
 	// Shutdown is the one place the flush is load-bearing rather than
 	// belt-and-braces: pogod builds a REPLACEMENT Refinery from this file.
 	r.flushState()
+	// Last, so the transitions the shutdown itself made are handed over
+	// before whatever is still undelivered is dead-lettered.
+	r.webhooks.close()
 }
 
 // Queue returns a snapshot of PENDING merge requests. It deliberately excludes
@@ -1258,6 +1336,16 @@ func (r *Refinery) AuthorFailureCount(author string) int {
 //
 // Returns an error if the MR is not found or has already finished.
 func (r *Refinery) Cancel(id string) (CancelOutcome, error) {
+	// After the unlock (LIFO), like the flush below: the events for any
+	// dependents this cancel takes with it are written with r.mu released.
+	var cancelled *MergeRequest
+	var dependents []*MergeRequest
+	defer func() {
+		if cancelled != nil {
+			r.notifyTransition(cancelled)
+		}
+		r.emitDependencyCancels(dependents)
+	}()
 	// After the unlock (LIFO): an operator's cancel is write-through, but the
 	// wait for disk happens with r.mu released (mg-538e).
 	defer r.flushState()
@@ -1278,8 +1366,13 @@ func (r *Refinery) Cancel(id string) (CancelOutcome, error) {
 				"daemon (likely recovered from a restart); it will be resolved by the recovery probe", id)
 		}
 		// Only this lane's gate is killed. Other repos' merges keep running,
-		// which is the point of giving each lane its own cancel handle.
-		r.requestInFlightCancelLocked(ln)
+		// which is the point of giving each lane its own cancel handle. A
+		// merge train narrows it further, to the one member named.
+		if len(ln.riders) > 0 {
+			r.requestTrainMemberCancelLocked(ln, id)
+		} else {
+			r.requestInFlightCancelLocked(ln)
+		}
 		return CancelRequestedInFlight, nil
 	}
 	if mr.Status != StatusQueued {
@@ -1297,6 +1390,9 @@ func (r *Refinery) Cancel(id string) (CancelOutcome, error) {
 	mr.Status = StatusCancelled
 	mr.DoneTime = time.Now()
 	r.history = append(r.history, mr)
+	cancelled = mr
+	// Anything stacked on it can no longer land as written.
+	dependents = r.cancelDependentsLocked(mr)
 	r.saveStateLocked()
 
 	log.Printf("refinery: cancelled MR %s branch=%s author=%s", mr.ID, mr.Branch, mr.Author)
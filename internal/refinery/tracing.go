@@ -0,0 +1,155 @@
+package refinery
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/drellem2/pogo/internal/tracing"
+)
+
+// The refinery's spans in a work item's trace (user-023): one
+// "refinery.merge" per merge request, submission to resolution, with its
+// queue wait, each attempt, and each gate under it.
+//
+// Like the metrics, the merge and attempt spans are recorded from the emit*
+// functions in events.go, so the trace and the event log agree on how every
+// merge request ended. The merge span is a root: nothing the refinery holds
+// says which dispatch produced the branch, and the trace id (the work item)
+// is what joins the two.
+
+// openAttempt is an attempt that has started and not yet resolved.
+type openAttempt struct {
+	n     int
+	start time.Time
+}
+
+// openAttempts holds each merge request's in-flight attempt, by merge request
+// id. A merge request has at most one, and it is removed when the attempt
+// resolves; a pogod that dies mid-attempt forgets it with everything else.
+var openAttempts = struct {
+	sync.Mutex
+	m map[string]openAttempt
+}{m: map[string]openAttempt{}}
+
+func mergeSpanID(mr *MergeRequest) tracing.SpanID {
+	return tracing.SpanIDFor(tracing.TraceIDFor(workItemIDFromAuthor(mr.Author)), "refinery.merge", mr.ID)
+}
+
+func attemptSpanID(mr *MergeRequest, attempt int) tracing.SpanID {
+	return tracing.SpanIDFor(tracing.TraceIDFor(workItemIDFromAuthor(mr.Author)),
+		"refinery.attempt", mr.ID, strconv.Itoa(attempt))
+}
+
+// traceAttemptStarted notes when attempt began, for its span.
+func traceAttemptStarted(mr *MergeRequest, attempt int) {
+	openAttempts.Lock()
+	openAttempts.m[mr.ID] = openAttempt{n: attempt, start: time.Now()}
+	openAttempts.Unlock()
+}
+
+// traceAttemptEnded records attempt's span if it is the one in flight. A
+// cancel "before-attempt" names an attempt that never started, and records
+// nothing. outcome is as for traceMergeDone, and err is the failure, if any.
+func traceAttemptEnded(mr *MergeRequest, attempt int, outcome, stage string, err error) {
+	openAttempts.Lock()
+	open, ok := openAttempts.m[mr.ID]
+	if ok && open.n == attempt {
+		delete(openAttempts.m, mr.ID)
+	}
+	openAttempts.Unlock()
+	if !ok || open.n != attempt {
+		return
+	}
+	span := tracing.Span{
+		WorkItemID: workItemIDFromAuthor(mr.Author),
+		SpanID:     attemptSpanID(mr, attempt),
+		ParentID:   mergeSpanID(mr),
+		Name:       "refinery.attempt",
+		Start:      open.start,
+		End:        time.Now(),
+		Attributes: map[string]any{"attempt": attempt, "outcome": outcome},
+	}
+	if stage != "" {
+		span.Attributes["stage"] = stage
+	}
+	if err != nil {
+		span.Status = tracing.StatusError
+		span.Message = summarizeReason(err)
+	}
+	tracing.Record(span)
+}
+
+// traceGate records one gate run, under the merge request's attempt in
+// flight. mr is nil in unit tests that drive the gates directly.
+func traceGate(mr *MergeRequest, gate string, start time.Time, err error) {
+	if mr == nil {
+		return
+	}
+	parent := mergeSpanID(mr)
+	openAttempts.Lock()
+	if open, ok := openAttempts.m[mr.ID]; ok {
+		parent = attemptSpanID(mr, open.n)
+	}
+	openAttempts.Unlock()
+	span := tracing.Span{
+		WorkItemID: workItemIDFromAuthor(mr.Author),
+		ParentID:   parent,
+		Name:       "refinery.gate",
+		Start:      start,
+		End:        time.Now(),
+		Attributes: map[string]any{"gate": gate, "result": gateResult(err)},
+	}
+	if err != nil {
+		span.Status = tracing.StatusError
+		span.Message = summarizeReason(err)
+	}
+	tracing.Record(span)
+}
+
+// traceMergeDone records the merge request's own span, submission to now,
+// and its queue wait under it. outcome is merged, failed or cancelled; only
+// failed is an error — a cancel is an operator's decision, not the branch's
+// defect, for the reason emitMergeCancelled is not a failure event.
+func traceMergeDone(mr *MergeRequest, outcome string, err error) {
+	item := workItemIDFromAuthor(mr.Author)
+	now := time.Now()
+	submitted := mr.SubmitTime
+	if submitted.IsZero() {
+		submitted = now
+	}
+	queued := tracing.Span{
+		WorkItemID: item,
+		ParentID:   mergeSpanID(mr),
+		Name:       "refinery.queue",
+		Start:      submitted,
+		End:        mr.StartTime,
+	}
+	if mr.StartTime.IsZero() {
+		queued.End = now
+	}
+	tracing.Record(queued)
+
+	span := tracing.Span{
+		WorkItemID: item,
+		SpanID:     mergeSpanID(mr),
+		Name:       "refinery.merge",
+		Start:      submitted,
+		End:        now,
+		Attributes: map[string]any{
+			"merge_request_id": mr.ID,
+			"repo":             mr.RepoPath,
+			"branch":           mr.Branch,
+			"target":           mr.TargetRef,
+			"outcome":          outcome,
+		},
+	}
+	if mr.Train != "" {
+		span.Attributes["train"] = mr.Train
+	}
+	if err != nil {
+		span.Status = tracing.StatusError
+		span.Message = summarizeReason(err)
+	}
+	tracing.Record(span)
+}
@@ -0,0 +1,109 @@
+package refinery
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drellem2/pogo/internal/tracing"
+)
+
+// spanSink is a tracing.Exporter that keeps every span it is given.
+type spanSink struct {
+	mu    sync.Mutex
+	spans []tracing.Span
+}
+
+func (s *spanSink) Name() string { return "test" }
+func (s *spanSink) Export(batch []tracing.Span) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spans = append(s.spans, batch...)
+	return nil
+}
+
+// byName flushes and returns the recorded spans by name; a name recorded more
+// than once keeps every span, in recording order.
+func (s *spanSink) byName(t *testing.T) map[string][]tracing.Span {
+	t.Helper()
+	if !tracing.Flush(5 * time.Second) {
+		t.Fatal("tracing.Flush timed out")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := map[string][]tracing.Span{}
+	for _, sp := range s.spans {
+		out[sp.Name] = append(out[sp.Name], sp)
+	}
+	return out
+}
+
+// TestMergeIsTracedFromItsEvents: a retried-then-merged request yields one
+// merge root in the author's work-item trace, its queue wait, an attempt per
+// try — the failed one in error — and each gate under the attempt it ran in.
+func TestMergeIsTracedFromItsEvents(t *testing.T) {
+	useTempEventLog(t)
+	sink := &spanSink{}
+	t.Cleanup(tracing.Configure(sink))
+
+	submitted := time.Now().Add(-time.Hour)
+	mr := &MergeRequest{ID: "mr-1", Author: "cat-mg-1", Branch: "polecat-x", TargetRef: "main",
+		SubmitTime: submitted, StartTime: submitted.Add(10 * time.Minute)}
+
+	emitMergeAttempted(mr, 1)
+	traceGate(mr, "go test ./...", time.Now(), errors.New("exit status 1"))
+	emitMergeFailed(mr, 1, "quality-gates", errors.New("gate failed"), false, "", AttemptFailure{})
+	emitMergeAttempted(mr, 2)
+	traceGate(mr, "go test ./...", time.Now(), nil)
+	emitMerged(mr, 2, "abc123", 1, false)
+
+	got := sink.byName(t)
+	merges, queues, attempts, gates := got["refinery.merge"], got["refinery.queue"], got["refinery.attempt"], got["refinery.gate"]
+	if len(merges) != 1 || len(queues) != 1 || len(attempts) != 2 || len(gates) != 2 {
+		t.Fatalf("spans = %v", got)
+	}
+	merge := merges[0]
+	if merge.TraceID != tracing.TraceIDFor("mg-1") || !merge.ParentID.IsZero() {
+		t.Errorf("merge is not a root of mg-1's trace: %+v", merge)
+	}
+	if !merge.Start.Equal(submitted) || merge.Status != tracing.StatusOK || merge.Attributes["outcome"] != "merged" {
+		t.Errorf("merge = %+v", merge)
+	}
+	if queues[0].ParentID != merge.SpanID || queues[0].Duration() != 10*time.Minute {
+		t.Errorf("queue = %+v, want 10m under the merge", queues[0])
+	}
+	for i, a := range attempts {
+		if a.ParentID != merge.SpanID || a.Attributes["attempt"] != i+1 {
+			t.Errorf("attempt %d = %+v", i+1, a)
+		}
+		if gates[i].ParentID != a.SpanID {
+			t.Errorf("gate %d is not under attempt %d", i+1, i+1)
+		}
+	}
+	if attempts[0].Status != tracing.StatusError || attempts[1].Status != tracing.StatusOK {
+		t.Errorf("attempt statuses = %s, %s; want error, ok", attempts[0].Status, attempts[1].Status)
+	}
+	if gates[0].Attributes["result"] != "failed" || gates[1].Attributes["result"] != "passed" {
+		t.Errorf("gate results = %v, %v", gates[0].Attributes["result"], gates[1].Attributes["result"])
+	}
+}
+
+// TestCancelledMergeIsNotAnError: a cancel is an operator's decision, and the
+// trace must not read it as the branch failing.
+func TestCancelledMergeIsNotAnError(t *testing.T) {
+	useTempEventLog(t)
+	sink := &spanSink{}
+	t.Cleanup(tracing.Configure(sink))
+
+	mr := &MergeRequest{ID: "mr-2", Author: "mg-2", SubmitTime: time.Now().Add(-time.Minute)}
+	emitMergeCancelled(mr, 0, "dependency", "")
+
+	got := sink.byName(t)
+	if len(got["refinery.merge"]) != 1 || len(got["refinery.attempt"]) != 0 {
+		t.Fatalf("spans = %v", got)
+	}
+	if m := got["refinery.merge"][0]; m.Status != tracing.StatusOK || m.Attributes["outcome"] != "cancelled" {
+		t.Errorf("merge = %+v", m)
+	}
+}
@@ -0,0 +1,391 @@
+package refinery
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// Outbound webhooks ([refinery.webhooks] in config.toml).
+//
+// A merge's outcome reached people three ways — events.log, `pogo refinery
+// history`, and mail to the author agent — and all three are pull, or pogo's
+// own agents. Anything outside the fleet that wanted to react to a merge (a
+// chat bot, a dashboard) had to tail the event log. Webhooks push each
+// transition instead:
+//
+//	queued → processing → merged | failed | cancelled
+//	                   (restart) → lost
+//
+// Each is one POST of a JSON payload, signed with HMAC-SHA256 over the exact
+// body bytes (X-Pogo-Signature: sha256=<hex>), so a receiver can check it came
+// from this pogod before acting on it.
+//
+// Delivery never waits on the merge and the merge never waits on delivery.
+// Every URL has its own in-order queue and sender, so one endpoint that is down
+// delays nothing else. A failed POST — a transport error or any non-2xx — is
+// retried with doubling backoff up to max_attempts; a delivery that exhausts
+// its attempts, overflows its queue, or is still pending when the refinery
+// stops is appended to the dead-letter file (one JSON line per delivery, with
+// the payload and why it was given up on) rather than dropped. Nothing reads
+// that file back: it is there for an operator to replay or to learn from.
+
+// WebhookConfig configures outbound refinery webhooks. Zero URLs disables them.
+type WebhookConfig struct {
+	// URLs each receive every selected transition.
+	URLs []string
+	// Secret is the HMAC-SHA256 key payloads are signed with. Required: an
+	// unsigned merge notification is one anyone on the path can forge.
+	Secret string
+	// Events selects which statuses are delivered. Empty means all of them.
+	Events []string
+	// MaxAttempts bounds POSTs per delivery. Zero means defaultWebhookAttempts.
+	MaxAttempts int
+	// Timeout bounds one POST. Zero means defaultWebhookTimeout.
+	Timeout time.Duration
+	// DeadLetterPath is where given-up deliveries are appended.
+	DeadLetterPath string
+}
+
+const (
+	defaultWebhookAttempts = 5
+	defaultWebhookTimeout  = 10 * time.Second
+	// webhookQueueLen bounds each URL's pending deliveries. A receiver that is
+	// down for long enough to fill it is dead-lettered from then on rather
+	// than let the backlog grow without bound in pogod's memory.
+	webhookQueueLen = 256
+	// webhookRetryCap bounds the doubling backoff between attempts.
+	webhookRetryCap = 5 * time.Minute
+)
+
+// webhookBackoff is the wait before the second attempt, doubled for each one
+// after. A variable so tests need not sleep through it.
+var webhookBackoff = 2 * time.Second
+
+// webhookStatuses are the transitions a webhook can be sent for.
+var webhookStatuses = []MergeStatus{StatusQueued, StatusProcessing, StatusMerged, StatusFailed, StatusCancelled, StatusLost}
+
+// WebhookPayload is the JSON body of one webhook POST.
+type WebhookPayload struct {
+	DeliveryID string `json:"delivery_id"`
+	// Event is "refinery." followed by the new status.
+	Event        string            `json:"event"`
+	Timestamp    time.Time         `json:"timestamp"`
+	MergeRequest WebhookMergeState `json:"merge_request"`
+}
+
+// WebhookMergeState is the merge request as of a transition: the fields a
+// receiver acts on, not the whole record, which carries gate output.
+type WebhookMergeState struct {
+	ID           string      `json:"id"`
+	Status       MergeStatus `json:"status"`
+	Repo         string      `json:"repo"`
+	Branch       string      `json:"branch"`
+	Target       string      `json:"target"`
+	Author       string      `json:"author,omitempty"`
+	DependsOn    string      `json:"depends_on,omitempty"`
+	Train        string      `json:"train,omitempty"`
+	Attempts     int         `json:"attempts,omitempty"`
+	MergedSHA    string      `json:"merged_sha,omitempty"`
+	Error        string      `json:"error,omitempty"`
+	FailureClass string      `json:"failure_class,omitempty"`
+	SubmitTime   time.Time   `json:"submit_time"`
+}
+
+// webhookDelivery is one payload on its way to one URL.
+type webhookDelivery struct {
+	url     string
+	body    []byte
+	id      string
+	event   string
+	attempt int
+}
+
+// webhookSender owns one queue and worker per URL.
+type webhookSender struct {
+	cfg     WebhookConfig
+	events  map[MergeStatus]bool
+	client  *http.Client
+	queues  map[string]chan webhookDelivery
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	deadMu  sync.Mutex
+	stopped sync.Once
+}
+
+// newWebhookSender starts the per-URL workers, or returns nil when no
+// webhooks are configured or they cannot be signed.
+func newWebhookSender(cfg WebhookConfig) *webhookSender {
+	if len(cfg.URLs) == 0 {
+		return nil
+	}
+	if cfg.Secret == "" {
+		log.Printf("refinery: [refinery.webhooks] names %d URL(s) but no secret — webhooks DISABLED, since an unsigned payload cannot be told from a forged one", len(cfg.URLs))
+		return nil
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultWebhookAttempts
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultWebhookTimeout
+	}
+	s := &webhookSender{
+		cfg:    cfg,
+		events: make(map[MergeStatus]bool),
+		client: &http.Client{Timeout: cfg.Timeout},
+		queues: make(map[string]chan webhookDelivery),
+		stop:   make(chan struct{}),
+	}
+	for _, ev := range cfg.Events {
+		s.events[MergeStatus(strings.TrimPrefix(strings.TrimSpace(ev), "refinery."))] = true
+	}
+	if len(s.events) == 0 {
+		for _, st := range webhookStatuses {
+			s.events[st] = true
+		}
+	}
+	for _, u := range cfg.URLs {
+		if _, dup := s.queues[u]; dup {
+			continue
+		}
+		q := make(chan webhookDelivery, webhookQueueLen)
+		s.queues[u] = q
+		s.wg.Add(1)
+		go s.run(q)
+	}
+	log.Printf("refinery: webhooks armed for %d URL(s), events %v, max %d attempts", len(s.queues), cfg.Events, cfg.MaxAttempts)
+	return s
+}
+
+// send queues a payload for every URL. Never blocks.
+func (s *webhookSender) send(state WebhookMergeState) {
+	if s == nil || !s.events[state.Status] {
+		return
+	}
+	p := WebhookPayload{
+		DeliveryID:   "wh-" + xid.New().String(),
+		Event:        "refinery." + string(state.Status),
+		Timestamp:    time.Now().UTC(),
+		MergeRequest: state,
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		log.Printf("refinery: webhook payload for MR %s: %v", state.ID, err)
+		return
+	}
+	for u, q := range s.queues {
+		d := webhookDelivery{url: u, body: body, id: p.DeliveryID, event: p.Event}
+		select {
+		case <-s.stop:
+			s.deadLetter(d, "the refinery was stopping")
+			continue
+		default:
+		}
+		select {
+		case q <- d:
+		default:
+			s.deadLetter(d, fmt.Sprintf("%d deliveries were already pending for this URL", webhookQueueLen))
+		}
+	}
+}
+
+// run delivers one URL's queue in order until the sender stops, then
+// dead-letters whatever is left.
+func (s *webhookSender) run(q chan webhookDelivery) {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.stop:
+			for {
+				select {
+				case d := <-q:
+					s.deadLetter(d, "the refinery stopped before it was delivered")
+				default:
+					return
+				}
+			}
+		case d := <-q:
+			s.deliver(d)
+		}
+	}
+}
+
+// deliver POSTs d until it is accepted, its attempts run out, or the sender
+// stops.
+func (s *webhookSender) deliver(d webhookDelivery) {
+	wait := webhookBackoff
+	var lastErr error
+	for d.attempt < s.cfg.MaxAttempts {
+		d.attempt++
+		if lastErr = s.post(d); lastErr == nil {
+			return
+		}
+		log.Printf("refinery: webhook %s %s to %s: attempt %d/%d failed: %v", d.id, d.event, d.url, d.attempt, s.cfg.MaxAttempts, lastErr)
+		if d.attempt == s.cfg.MaxAttempts {
+			break
+		}
+		select {
+		case <-s.stop:
+			s.deadLetter(d, "the refinery stopped between attempts; last error: "+lastErr.Error())
+			return
+		case <-time.After(wait):
+		}
+		wait = min(wait*2, webhookRetryCap)
+	}
+	s.deadLetter(d, fmt.Sprintf("gave up after %d attempts; last error: %v", d.attempt, lastErr))
+}
+
+// post makes one attempt. Any 2xx is success.
+func (s *webhookSender) post(d webhookDelivery) error {
+	req, err := http.NewRequest(http.MethodPost, d.url, bytes.NewReader(d.body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pogo-refinery")
+	req.Header.Set("X-Pogo-Event", d.event)
+	req.Header.Set("X-Pogo-Delivery", d.id)
+	req.Header.Set("X-Pogo-Attempt", strconv.Itoa(d.attempt))
+	req.Header.Set("X-Pogo-Signature", signWebhook(s.cfg.Secret, d.body))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("HTTP %s", resp.Status)
+	}
+	return nil
+}
+
+// signWebhook returns the X-Pogo-Signature value for body: "sha256=" and the
+// hex HMAC-SHA256 of the exact bytes sent.
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookDeadLetter is one line of the dead-letter file.
+type webhookDeadLetter struct {
+	DeliveryID string          `json:"delivery_id"`
+	URL        string          `json:"url"`
+	Event      string          `json:"event"`
+	Attempts   int             `json:"attempts"`
+	Reason     string          `json:"reason"`
+	Time       time.Time       `json:"time"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// deadLetter appends a delivery that will not be made to the dead-letter file.
+func (s *webhookSender) deadLetter(d webhookDelivery, reason string) {
+	log.Printf("refinery: webhook %s %s to %s DEAD-LETTERED: %s", d.id, d.event, d.url, reason)
+	if s.cfg.DeadLetterPath == "" {
+		return
+	}
+	line, err := json.Marshal(webhookDeadLetter{
+		DeliveryID: d.id, URL: d.url, Event: d.event, Attempts: d.attempt,
+		Reason: reason, Time: time.Now().UTC(), Payload: d.body,
+	})
+	if err != nil {
+		return
+	}
+	s.deadMu.Lock()
+	defer s.deadMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(s.cfg.DeadLetterPath), 0755); err != nil {
+		log.Printf("refinery: webhook dead-letter file: %v", err)
+		return
+	}
+	f, err := os.OpenFile(s.cfg.DeadLetterPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("refinery: webhook dead-letter file: %v", err)
+		return
+	}
+	defer f.Close()
+	f.Write(append(line, '\n'))
+}
+
+// close stops the workers and waits for them. A POST in flight finishes; what
+// is still queued is dead-lettered.
+func (s *webhookSender) close() {
+	if s == nil {
+		return
+	}
+	s.stopped.Do(func() { close(s.stop) })
+	s.wg.Wait()
+}
+
+// notifyTransition sends the webhooks for mr having moved to its current
+// status. The state is read under mu; the send never blocks. Called with mu
+// released.
+func (r *Refinery) notifyTransition(mrs ...*MergeRequest) {
+	if r.webhooks == nil || len(mrs) == 0 {
+		return
+	}
+	states := make([]WebhookMergeState, 0, len(mrs))
+	r.mu.Lock()
+	for _, mr := range mrs {
+		states = append(states, webhookStateLocked(mr))
+	}
+	r.mu.Unlock()
+	for _, st := range states {
+		r.webhooks.send(st)
+	}
+}
+
+// webhookStateLocked is the payload view of mr. Must be called with mu held.
+func webhookStateLocked(mr *MergeRequest) WebhookMergeState {
+	return WebhookMergeState{
+		ID:           mr.ID,
+		Status:       mr.Status,
+		Repo:         mr.RepoPath,
+		Branch:       mr.Branch,
+		Target:       mr.TargetRef,
+		Author:       mr.Author,
+		DependsOn:    mr.DependsOn,
+		Train:        mr.Train,
+		Attempts:     mr.AttemptCount,
+		MergedSHA:    mr.MergedSHA,
+		Error:        oneLineError(mr.Error),
+		FailureClass: string(mr.FailureClass),
+		SubmitTime:   mr.SubmitTime,
+	}
+}
+
+// notifyLost sends the webhooks for a request restart recovery gave up on. It
+// is no longer in the index, so mr is a copy nothing else can reach and is
+// read without mu.
+func (r *Refinery) notifyLost(mr MergeRequest, reason string) {
+	if r.webhooks == nil {
+		return
+	}
+	st := webhookStateLocked(&mr)
+	st.Status = StatusLost
+	st.Error = oneLineError(reason)
+	r.webhooks.send(st)
+}
+
+// oneLineError is a recorded error as the payload carries it: the first line,
+// capped like an event's reason.
+func oneLineError(s string) string {
+	if s == "" {
+		return ""
+	}
+	return summarizeReason(errors.New(s))
+}
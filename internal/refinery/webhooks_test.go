@@ -0,0 +1,204 @@
+package refinery
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hookRecorder is a local stand-in for a webhook receiver. It answers each
+// request with the next status in statuses (200 once they run out) and keeps
+// what it was sent.
+type hookRecorder struct {
+	mu       sync.Mutex
+	statuses []int
+	got      []recordedHook
+}
+
+type recordedHook struct {
+	event, signature, attempt string
+	body                      []byte
+}
+
+func (h *hookRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	h.mu.Lock()
+	h.got = append(h.got, recordedHook{
+		event:     req.Header.Get("X-Pogo-Event"),
+		signature: req.Header.Get("X-Pogo-Signature"),
+		attempt:   req.Header.Get("X-Pogo-Attempt"),
+		body:      body,
+	})
+	status := http.StatusOK
+	if len(h.statuses) > 0 {
+		status, h.statuses = h.statuses[0], h.statuses[1:]
+	}
+	h.mu.Unlock()
+	w.WriteHeader(status)
+}
+
+// waitFor polls until the receiver has seen n requests.
+func (h *hookRecorder) waitFor(t *testing.T, n int) []recordedHook {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		h.mu.Lock()
+		got := append([]recordedHook(nil), h.got...)
+		h.mu.Unlock()
+		if len(got) >= n || time.Now().After(deadline) {
+			if len(got) < n {
+				t.Fatalf("receiver saw %d requests, want %d", len(got), n)
+			}
+			return got
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func shortWebhookBackoff(t *testing.T) {
+	old := webhookBackoff
+	webhookBackoff = 10 * time.Millisecond
+	t.Cleanup(func() { webhookBackoff = old })
+}
+
+// TestWebhooksSignedTransitionsForAMerge runs a real merge with a receiver
+// configured and checks it heard queued → processing → merged, in order, each
+// signed over the exact body it was sent.
+func TestWebhooksSignedTransitionsForAMerge(t *testing.T) {
+	hooks := &hookRecorder{}
+	srv := httptest.NewServer(hooks)
+	defer srv.Close()
+
+	originDir := initBareOrigin(t, "main")
+	workDir := t.TempDir()
+	run(t, workDir, "git", "clone", originDir, ".")
+	run(t, workDir, "git", "config", "user.email", "test@test.com")
+	run(t, workDir, "git", "config", "user.name", "Test")
+	run(t, workDir, "git", "checkout", "-b", "feature")
+	os.WriteFile(filepath.Join(workDir, "feature.txt"), []byte("feature"), 0644)
+	run(t, workDir, "git", "add", ".")
+	run(t, workDir, "git", "commit", "-m", "feature")
+	run(t, workDir, "git", "push", "origin", "feature")
+
+	r, err := New(Config{Enabled: true, PollInterval: time.Hour, WorktreeDir: t.TempDir(),
+		Webhooks: WebhookConfig{URLs: []string{srv.URL}, Secret: "s3cret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+	id, err := r.Submit(MergeRequest{RepoPath: originDir, Branch: "feature", Author: "mg-hook"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.processNext()
+
+	got := hooks.waitFor(t, 3)
+	for i, want := range []string{"refinery.queued", "refinery.processing", "refinery.merged"} {
+		h := got[i]
+		if h.event != want {
+			t.Errorf("delivery %d: event %q, want %q", i, h.event, want)
+		}
+		if h.signature != signWebhook("s3cret", h.body) {
+			t.Errorf("delivery %d: signature %q does not match the body", i, h.signature)
+		}
+		var p WebhookPayload
+		if err := json.Unmarshal(h.body, &p); err != nil {
+			t.Fatalf("delivery %d: %v", i, err)
+		}
+		if p.Event != want || p.MergeRequest.ID != id || p.MergeRequest.Branch != "feature" || p.DeliveryID == "" {
+			t.Errorf("delivery %d: payload %+v", i, p)
+		}
+	}
+	var merged WebhookPayload
+	json.Unmarshal(got[2].body, &merged)
+	if merged.MergeRequest.MergedSHA == "" {
+		t.Error("the merged payload does not carry the merged SHA")
+	}
+}
+
+func TestWebhookRetriesUntilAccepted(t *testing.T) {
+	shortWebhookBackoff(t)
+	hooks := &hookRecorder{statuses: []int{http.StatusServiceUnavailable, http.StatusBadGateway}}
+	srv := httptest.NewServer(hooks)
+	defer srv.Close()
+	dead := filepath.Join(t.TempDir(), "dead.jsonl")
+
+	s := newWebhookSender(WebhookConfig{URLs: []string{srv.URL}, Secret: "k", DeadLetterPath: dead})
+	s.send(WebhookMergeState{ID: "mr-1", Status: StatusMerged})
+	got := hooks.waitFor(t, 3)
+	s.close()
+
+	if got[2].attempt != "3" {
+		t.Errorf("third request carried attempt %q, want 3", got[2].attempt)
+	}
+	if string(got[0].body) != string(got[2].body) {
+		t.Error("a retry must resend the same payload, so its delivery_id and signature still match")
+	}
+	if _, err := os.Stat(dead); err == nil {
+		t.Error("an accepted delivery was dead-lettered")
+	}
+}
+
+func TestWebhookDeadLettersAfterMaxAttempts(t *testing.T) {
+	shortWebhookBackoff(t)
+	hooks := &hookRecorder{statuses: []int{500, 500, 500, 500}}
+	srv := httptest.NewServer(hooks)
+	defer srv.Close()
+	dead := filepath.Join(t.TempDir(), "dead.jsonl")
+
+	s := newWebhookSender(WebhookConfig{URLs: []string{srv.URL}, Secret: "k", MaxAttempts: 3, DeadLetterPath: dead})
+	s.send(WebhookMergeState{ID: "mr-2", Status: StatusFailed})
+	hooks.waitFor(t, 3)
+
+	var data []byte
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if data, _ = os.ReadFile(dead); len(data) > 0 {
+			break
+		}
+	}
+	s.close()
+	var entry webhookDeadLetter
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("dead-letter file %q: %v", data, err)
+	}
+	if entry.Attempts != 3 || entry.URL != srv.URL || entry.Event != "refinery.failed" {
+		t.Errorf("dead letter = %+v", entry)
+	}
+	if !strings.Contains(entry.Reason, "HTTP 500") {
+		t.Errorf("dead letter reason %q should carry the last error", entry.Reason)
+	}
+	var p WebhookPayload
+	if err := json.Unmarshal(entry.Payload, &p); err != nil || p.MergeRequest.ID != "mr-2" {
+		t.Errorf("dead letter payload %s does not carry the delivery (%v)", entry.Payload, err)
+	}
+	if n := len(hooks.waitFor(t, 3)); n != 3 {
+		t.Errorf("receiver saw %d attempts, want exactly 3", n)
+	}
+}
+
+func TestWebhookEventsFilterAndSecretRequired(t *testing.T) {
+	if s := newWebhookSender(WebhookConfig{URLs: []string{"http://127.0.0.1:1/"}}); s != nil {
+		s.close()
+		t.Fatal("webhooks without a secret must stay disabled")
+	}
+
+	hooks := &hookRecorder{}
+	srv := httptest.NewServer(hooks)
+	defer srv.Close()
+	s := newWebhookSender(WebhookConfig{URLs: []string{srv.URL}, Secret: "k", Events: []string{"merged", "refinery.failed"}})
+	s.send(WebhookMergeState{ID: "mr-3", Status: StatusQueued})
+	s.send(WebhookMergeState{ID: "mr-3", Status: StatusProcessing})
+	s.send(WebhookMergeState{ID: "mr-3", Status: StatusFailed})
+	got := hooks.waitFor(t, 1)
+	s.close()
+	if len(got) != 1 || got[0].event != "refinery.failed" {
+		t.Errorf("deliveries %v, want only refinery.failed", got)
+	}
+}
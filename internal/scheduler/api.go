@@ -8,6 +8,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/drellem2/pogo/internal/apiauth"
 )
 
 // AddRequest is the JSON body for POST /scheduler/schedules.
@@ -83,6 +85,10 @@ func (s *Scheduler) handleAck(w http.ResponseWriter, r *http.Request) {
 	if agentName == "" {
 		agentName = r.URL.Query().Get("agent")
 	}
+	agentName, ok := callerAgent(w, r, agentName)
+	if !ok {
+		return
+	}
 
 	res, err := s.Ack(agentName, id, req.Token, s.clock())
 	if err != nil {
@@ -134,6 +140,9 @@ func (s *Scheduler) handleList(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
 			return
 		}
+		if _, ok := callerAgent(w, r, req.Agent); !ok {
+			return
+		}
 		entry, err := s.addFromRequest(req, s.clock())
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
@@ -168,6 +177,10 @@ func (s *Scheduler) handleByID(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(entry)
 	case "DELETE":
+		agent, ok := callerAgent(w, r, agent)
+		if !ok {
+			return
+		}
 		removed, err := s.removeByID(agent, id)
 		if err != nil {
 			if amb, isAmb := err.(*ErrAmbiguousID); isAmb {
@@ -187,6 +200,28 @@ func (s *Scheduler) handleByID(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// callerAgent holds a polecat to its own schedules. The API policy lets a
+// polecat register and ack its mail-check, but a schedule's message is a
+// nudge delivered to whichever agent it names, so a polecat that could name
+// another agent could nudge the coordinator with anything it liked. For a
+// polecat, agent must be the token's own agent name, and an empty one means
+// that name; any other caller may name any agent. A refusal has been written
+// to w when ok is false.
+func callerAgent(w http.ResponseWriter, r *http.Request, agent string) (string, bool) {
+	id, ok := apiauth.FromContext(r.Context())
+	if !ok || id.Role != apiauth.RolePolecat {
+		return agent, true
+	}
+	if agent == "" {
+		return id.Subject, true
+	}
+	if agent != id.Subject {
+		http.Error(w, fmt.Sprintf("%s may not schedule for %s; a polecat's schedules are its own", id, agent), http.StatusForbidden)
+		return "", false
+	}
+	return agent, true
+}
+
 // lookupByID resolves a single entry. With agent set, it's an exact (agent,
 // id) lookup. Without agent, it falls back to id-only disambiguation —
 // returning *ErrAmbiguousID if multiple agents own the id.
@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"strings"
+
+	"github.com/drellem2/pogo/internal/metrics"
+)
+
+// The scheduler half of pogod's /metrics (user-022). Fires are counted where
+// their events are written; the schedules and their unacked streaks are read
+// at scrape time.
+var (
+	firesTotal = metrics.NewCounter("pogo_scheduler_fires_total",
+		"Schedule fires, by outcome: delivered, failed or skipped.", "outcome")
+	missedFiresTotal = metrics.NewCounter("pogo_scheduler_missed_fires_total",
+		"Cron periods that passed without a fire, counted at the late fire that followed them.")
+)
+
+// observeFire counts one fire event.
+func observeFire(eventType string, missed int) {
+	firesTotal.Inc(strings.TrimPrefix(eventType, "scheduler_fire_"))
+	if missed > 0 {
+		missedFiresTotal.Add(float64(missed))
+	}
+}
+
+// RegisterMetrics publishes the live schedules on /metrics.
+//
+// pogo_scheduler_unacked_streak is reported only for a schedule whose agent
+// has acked before (Entry.CompletionTracked): for one that never has, a
+// streak is UNKNOWN rather than failing, and a series an alert can fire on
+// would say otherwise.
+func (s *Scheduler) RegisterMetrics() {
+	metrics.SetGaugeFunc("pogo_scheduler_schedules",
+		"Registered schedules, by kind.",
+		[]string{"kind"}, func(emit metrics.Emit) {
+			counts := map[ScheduleKind]int{}
+			for _, e := range s.List("") {
+				counts[e.Kind]++
+			}
+			for kind, n := range counts {
+				emit(float64(n), string(kind))
+			}
+		})
+	metrics.SetGaugeFunc("pogo_scheduler_unacked_streak",
+		"Consecutive delivered fires the agent has not acked, for schedules that have acked before.",
+		[]string{"schedule", "agent"}, func(emit metrics.Emit) {
+			for _, e := range s.List("") {
+				if e.CompletionTracked() {
+					emit(float64(e.UnackedStreak), e.ID, e.Agent)
+				}
+			}
+		})
+}
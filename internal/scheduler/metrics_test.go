@@ -0,0 +1,51 @@
+package scheduler
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drellem2/pogo/internal/metrics"
+)
+
+// A streak is published only for a schedule that has acked before; for one
+// that never has, the streak is unknown rather than failing.
+func TestRegisterMetricsPublishesOnlyTrackedStreaks(t *testing.T) {
+	s := carryScheduler(t)
+	now := time.Date(2026, 8, 11, 2, 0, 0, 0, time.UTC)
+	for _, agent := range []string{"architect", "mayor"} {
+		if _, err := s.Add(mailCheck(agent), now); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	delivered := firesTotal.Value("delivered")
+
+	at := now.Add(10 * time.Minute)
+	s.Tick(context.Background(), at)
+	e, _ := s.Get("architect", "mail-check-architect")
+	if _, err := s.Ack("architect", "mail-check-architect", e.PendingToken, at.Add(time.Second)); err != nil {
+		t.Fatalf("Ack: %v", err)
+	}
+	s.Tick(context.Background(), at.Add(10*time.Minute))
+	s.Tick(context.Background(), at.Add(20*time.Minute))
+	if got := firesTotal.Value("delivered") - delivered; got != 6 {
+		t.Errorf("counted %v delivered fires, want 6", got)
+	}
+
+	s.RegisterMetrics()
+	var b strings.Builder
+	if err := metrics.Default.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	if !strings.Contains(out, `pogo_scheduler_unacked_streak{schedule="mail-check-architect",agent="architect"} 2`+"\n") {
+		t.Errorf("architect's streak is missing:\n%s", out)
+	}
+	if strings.Contains(out, `agent="mayor"`) {
+		t.Errorf("mayor has never acked, so has no streak to report:\n%s", out)
+	}
+	if !strings.Contains(out, `pogo_scheduler_schedules{kind="mail-check"} 2`+"\n") {
+		t.Errorf("schedule count is missing:\n%s", out)
+	}
+}
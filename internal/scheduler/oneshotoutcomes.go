@@ -168,76 +168,89 @@ func (r OneShotReport) WriterPredatesLabels() bool { return r.Legacy > 0 }
 // AckStaleWindow after its fire, so a removal at the leading edge of any window
 // shorter than a day has its `scheduler_fire_delivered` outside that window; a
 // windowed join would report the reap with no fire time and understate the wait
-// to zero. Every one-shot delivery in the scanned files is kept instead (one
-// entry per (agent, id), the last one wins) and only removals are filtered.
+// to zero. Every one-shot delivery from two reap intervals before the window
+// on is kept instead (one entry per (agent, id), the last one wins) and only
+// removals are filtered.
 func ReadOneShotOutcomes(logPath string, since, until time.Time) (OneShotReport, error) {
 	rep := OneShotReport{Since: since, Until: until, Spilled: events.LogSpilled(logPath)}
 
 	// A LOG THAT IS NOT THERE IS AN ERROR, not an empty window — and this is the
 	// spot where this reader could most easily commit the defect it exists to
-	// close. events.ScanFile treats a missing file as "no events yet" and returns
+	// close. events.Select treats a missing file as "no events yet" and returns
 	// (nil, nil), so an unresolvable POGO_HOME or a renamed log would produce a
 	// clean, confident "no one-shot fired or was reaped" from a run that opened
 	// nothing at all. Callers must be able to tell that from a quiet week.
 	if strings.TrimSpace(logPath) == "" {
 		return rep, fmt.Errorf("no events log path: the scheduler root could not be resolved")
 	}
-	files := scanFilesCovering(logPath, since.Add(-2*AckStaleWindow))
+	floor := since
+	if !floor.IsZero() {
+		floor = floor.Add(-2 * AckStaleWindow)
+	}
+	files := scanFilesCovering(logPath, floor)
 	if len(files) == 0 {
 		return rep, fmt.Errorf("no events log at %s", logPath)
 	}
 	rep.Files = files
+	// Coverage is a property of the LOG, so it is measured off the oldest
+	// record's own write stamp, whatever its type; everything else below is
+	// measured off the scheduler's stamp in the details. See eventTime. The
+	// files are in time order, so that record opens the first of them.
+	if oldest, ok := events.FirstEventTime(files[0]); ok {
+		rep.Oldest = oldest
+	}
 
 	type fireKey struct{ agent, id string }
 	fired := map[fireKey]time.Time{}
 	var removals []OneShotOutcome
 
-	for _, f := range files {
-		err := events.ScanFile(f, func(ev events.Event) {
-			written, perr := time.Parse(time.RFC3339Nano, ev.Timestamp)
-			if perr != nil {
-				return
-			}
-			// Coverage is a property of the LOG, so it is measured off the
-			// record's own write stamp; everything else below is measured off
-			// the scheduler's stamp in the details. See eventTime.
-			if rep.Oldest.IsZero() || written.Before(rep.Oldest) {
-				rep.Oldest = written
+	// The same floor as the files, through the index: only deliveries and
+	// removals from the window and the two reap intervals before it are read.
+	q := events.Query{Where: events.Or(
+		events.Equals("event_type", "scheduler_fire_delivered"),
+		events.Equals("event_type", "schedule_removed"),
+	)}
+	if !floor.IsZero() {
+		q.Since = floor.Add(-time.Nanosecond)
+	}
+	err := events.Select(logPath, q, func(ev events.Event) {
+		written, perr := time.Parse(time.RFC3339Nano, ev.Timestamp)
+		if perr != nil {
+			return
+		}
+		if !detailBool(ev.Details, "one_shot") {
+			return
+		}
+		switch ev.EventType {
+		case "scheduler_fire_delivered":
+			at := eventTime(ev.Details, "fired_at", written)
+			k := fireKey{detailString(ev.Details, "to"), detailString(ev.Details, "schedule_id")}
+			fired[k] = at
+			if inWindow(at, since, until) {
+				rep.Fires++
 			}
-			if !detailBool(ev.Details, "one_shot") {
+		case "schedule_removed":
+			at := eventTime(ev.Details, "removed_at", written)
+			if !inWindow(at, since, until) {
 				return
 			}
-			switch ev.EventType {
-			case "scheduler_fire_delivered":
-				at := eventTime(ev.Details, "fired_at", written)
-				k := fireKey{detailString(ev.Details, "to"), detailString(ev.Details, "schedule_id")}
-				fired[k] = at
-				if inWindow(at, since, until) {
-					rep.Fires++
-				}
-			case "schedule_removed":
-				at := eventTime(ev.Details, "removed_at", written)
-				if !inWindow(at, since, until) {
-					return
-				}
-				removals = append(removals, OneShotOutcome{
-					Reason:   detailString(ev.Details, "reason"),
-					ID:       detailString(ev.Details, "schedule_id"),
-					Agent:    detailString(ev.Details, "to"),
-					Kind:     detailString(ev.Details, "kind"),
-					Message:  detailString(ev.Details, "message"),
-					Delivery: detailString(ev.Details, "delivery"),
-					Removed:  at,
-					Error:    detailString(ev.Details, "error"),
-				})
-			}
-		})
-		if err != nil {
-			// An events log we cannot read is not an empty measurement. The
-			// caller must be able to tell "nothing was missed" from "nothing
-			// was looked at".
-			return rep, fmt.Errorf("read %s: %w", f, err)
+			removals = append(removals, OneShotOutcome{
+				Reason:   detailString(ev.Details, "reason"),
+				ID:       detailString(ev.Details, "schedule_id"),
+				Agent:    detailString(ev.Details, "to"),
+				Kind:     detailString(ev.Details, "kind"),
+				Message:  detailString(ev.Details, "message"),
+				Delivery: detailString(ev.Details, "delivery"),
+				Removed:  at,
+				Error:    detailString(ev.Details, "error"),
+			})
 		}
+	})
+	if err != nil {
+		// An events log we cannot read is not an empty measurement. The
+		// caller must be able to tell "nothing was missed" from "nothing
+		// was looked at".
+		return rep, fmt.Errorf("read %s: %w", logPath, err)
 	}
 
 	for _, o := range removals {
@@ -1295,6 +1295,7 @@ func generateID() string {
 // scheduler's own root (s.logPath), never a globally-resolved path — see the
 // logPath field and mg-e06d.
 func (s *Scheduler) emitSchedulerEvent(eventType string, e Entry, fireTime time.Time, missed int, err error) {
+	observeFire(eventType, missed)
 	details := map[string]any{
 		"schedule_id":   e.ID,
 		"to":            e.Agent,
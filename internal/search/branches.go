@@ -0,0 +1,363 @@
+package search
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	ignore "github.com/sabhiram/go-gitignore"
+	"github.com/sourcegraph/zoekt"
+	"github.com/sourcegraph/zoekt/query"
+)
+
+// Branch indexing (`[search] branches`, `pose --branch`).
+//
+// A shard used to hold the working tree and nothing else, so a polecat's
+// branch or the tip of origin/main was invisible to `pose` until someone
+// checked it out. With branch patterns configured, each index pass also
+// resolves the repo's matching refs and adds their files to the same shard
+// as separate zoekt branches: the working tree is the branch "HEAD", each ref
+// is a branch under its short name ("origin/main", "polecat-mg-1234").
+//
+// A file identical in several branches is stored once, carrying all of their
+// names: the working-tree copy claims every branch whose blob it matches (by
+// git blob hash), and the rest are grouped by blob, so a branch a few commits
+// off main costs only the files it changed. Queries are scoped to one branch —
+// "HEAD" unless the request names another — so ordinary searches never see
+// the extra copies; see branchScope.
+//
+// Freshness follows the refs: the commit each indexed branch pointed at is
+// kept in the project's save file, and a pass whose refs moved rebuilds the
+// shard even when the working tree did not change.
+
+// headBranch is the zoekt branch holding the working tree. Zoekt treats a
+// `branch:HEAD` query as "the first branch", so it is always declared first.
+const headBranch = "HEAD"
+
+// maxIndexedBranches caps how many refs one shard carries besides the working
+// tree. Zoekt's per-file branch mask is 64 bits; the cap leaves room and keeps
+// a repo with hundreds of stale polecat branches from multiplying its index.
+const maxIndexedBranches = 32
+
+// SetBranches sets the ref patterns whose branches are indexed alongside the
+// working tree, from `[search] branches`. A pattern is a glob over the ref's
+// short name, as `git branch -a` prints it without "remotes/": "origin/main",
+// "polecat-*". Empty turns branch indexing off. A change takes effect on each
+// project's next index pass.
+func (g *BasicSearch) SetBranches(patterns []string) {
+	g.branchesMu.Lock()
+	g.branchPatterns = append([]string(nil), patterns...)
+	g.branchesMu.Unlock()
+	if len(patterns) > 0 {
+		g.logger.Info("Indexing branches beside the working tree", "patterns", strings.Join(patterns, ","))
+	}
+}
+
+func (g *BasicSearch) getBranchPatterns() []string {
+	g.branchesMu.RLock()
+	defer g.branchesMu.RUnlock()
+	return g.branchPatterns
+}
+
+// indexedBranches resolves the configured patterns against root's refs and
+// returns the matching branches and the commit each points at, or nil when
+// there are none (or root is not a git repo).
+func (g *BasicSearch) indexedBranches(root string) map[string]string {
+	patterns := g.getBranchPatterns()
+	if len(patterns) == 0 {
+		return nil
+	}
+	cmd := exec.Command("git", "for-each-ref", "--format=%(refname:short)%00%(objectname)%00%(symref)",
+		"refs/heads", "refs/remotes")
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil {
+		g.logger.Debug("Not resolving branches", "root", root, "error", err)
+		return nil
+	}
+	var names []string
+	commits := make(map[string]string)
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Split(line, "\x00")
+		if len(fields) != 3 || fields[2] != "" || fields[0] == headBranch {
+			// Symbolic refs (origin/HEAD) only alias another branch.
+			continue
+		}
+		if !matchesAnyPattern(fields[0], patterns) {
+			continue
+		}
+		names = append(names, fields[0])
+		commits[fields[0]] = fields[1]
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	if len(names) > maxIndexedBranches {
+		g.logger.Warn("More branches match than a shard holds; indexing the first ones by name",
+			"root", root, "matched", len(names), "indexed", maxIndexedBranches)
+		for _, n := range names[maxIndexedBranches:] {
+			delete(commits, n)
+		}
+	}
+	return commits
+}
+
+func matchesAnyPattern(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sameBranches reports whether two resolved branch sets are identical.
+func sameBranches(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, commit := range a {
+		if b[name] != commit {
+			return false
+		}
+	}
+	return true
+}
+
+// branchRepository is the zoekt repository description for a shard holding
+// the working tree at treeHash and the given branches.
+func branchRepository(root, treeHash string, branches map[string]string) *zoekt.Repository {
+	repo := &zoekt.Repository{
+		Name:     root,
+		Branches: []zoekt.RepositoryBranch{{Name: headBranch, Version: treeHash}},
+	}
+	for _, name := range sortedBranchNames(branches) {
+		repo.Branches = append(repo.Branches, zoekt.RepositoryBranch{Name: name, Version: branches[name]})
+	}
+	return repo
+}
+
+func sortedBranchNames(branches map[string]string) []string {
+	names := make([]string, 0, len(branches))
+	for name := range branches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// branchPlan is every file in the indexed branches, grouped so that each
+// distinct (path, blob) becomes one document.
+type branchPlan struct {
+	// blobs maps a root-relative path to its blobs, and each blob to the
+	// branches holding it at that path.
+	blobs map[string]map[string][]string
+}
+
+// planBranches lists the files of each branch. Paths the working-tree walk
+// would not index — default-excluded directories, .pogoignore — are left out
+// here too. A branch whose tree cannot be listed, or holds more files than
+// the per-tree ceiling, is left out with a warning.
+func (g *BasicSearch) planBranches(root string, branches map[string]string, ignored *ignore.GitIgnore) *branchPlan {
+	plan := &branchPlan{blobs: make(map[string]map[string][]string)}
+	for _, name := range sortedBranchNames(branches) {
+		files, err := lsTree(root, branches[name])
+		if err != nil {
+			g.logger.Warn("Could not list branch; leaving it out of the index", "root", root, "branch", name, "error", err)
+			continue
+		}
+		if g.maxFilesPerTree > 0 && len(files) > int(g.maxFilesPerTree) {
+			g.logger.Warn("Branch exceeds max_files_per_tree; leaving it out of the index",
+				"root", root, "branch", name, "files", len(files))
+			continue
+		}
+		for rel, blob := range files {
+			if hasExcludedComponent(rel) || (ignored != nil && ignored.MatchesPath(rel)) {
+				continue
+			}
+			if plan.blobs[rel] == nil {
+				plan.blobs[rel] = make(map[string][]string)
+			}
+			plan.blobs[rel][blob] = append(plan.blobs[rel][blob], name)
+		}
+	}
+	return plan
+}
+
+// claim returns the branches a working-tree file belongs to: HEAD, plus every
+// branch holding the same content at the same path, which no longer need a
+// document of their own.
+func (p *branchPlan) claim(rel string, content []byte) []string {
+	branches := []string{headBranch}
+	byBlob := p.blobs[filepath.ToSlash(rel)]
+	if len(byBlob) == 0 {
+		return branches
+	}
+	for blob := range byBlob {
+		if gitBlobHash(content, len(blob)) == blob {
+			branches = append(branches, byBlob[blob]...)
+			delete(byBlob, blob)
+			break
+		}
+	}
+	return branches
+}
+
+// gitBlobHash is the id git gives content as a blob: SHA-1, or SHA-256 in a
+// repository using it, told apart by the length of the ids it reports.
+func gitBlobHash(content []byte, idLen int) string {
+	header := "blob " + strconv.Itoa(len(content)) + "\x00"
+	if idLen == sha256.Size*2 {
+		h := sha256.New()
+		io.WriteString(h, header)
+		h.Write(content)
+		return hex.EncodeToString(h.Sum(nil))
+	}
+	h := sha1.New()
+	io.WriteString(h, header)
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// lsTree lists the regular files in commit's tree, root-relative path to blob
+// id. Submodules and symlinks are not files to search.
+func lsTree(root, commit string) (map[string]string, error) {
+	cmd := exec.Command("git", "ls-tree", "-r", "-z", commit)
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]string)
+	for _, entry := range bytes.Split(out, []byte{0}) {
+		// "<mode> SP <type> SP <object> TAB <path>"
+		meta, rel, ok := bytes.Cut(entry, []byte{'\t'})
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(string(meta))
+		if len(fields) != 3 || fields[1] != "blob" || fields[0] == "120000" {
+			continue
+		}
+		files[string(rel)] = fields[2]
+	}
+	return files, nil
+}
+
+// addBranchDocuments adds what is left of plan after the working tree claimed
+// its files: one document per distinct (path, blob), read from git in one
+// `cat-file --batch` stream.
+func (g *BasicSearch) addBranchDocuments(indexer *zoekt.IndexBuilder, syms symbolParser, root string, plan *branchPlan) symbolParser {
+	type pending struct {
+		rel, blob string
+		branches  []string
+	}
+	var todo []pending
+	rels := make([]string, 0, len(plan.blobs))
+	for rel := range plan.blobs {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	for _, rel := range rels {
+		for blob, branches := range plan.blobs[rel] {
+			todo = append(todo, pending{rel, blob, branches})
+		}
+	}
+	if len(todo) == 0 {
+		return syms
+	}
+
+	cmd := exec.Command("git", "cat-file", "--batch")
+	cmd.Dir = root
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		g.logger.Warn("Could not read branch files", "root", root, "error", err)
+		return syms
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		g.logger.Warn("Could not read branch files", "root", root, "error", err)
+		return syms
+	}
+	if err := cmd.Start(); err != nil {
+		g.logger.Warn("Could not read branch files", "root", root, "error", err)
+		return syms
+	}
+	// Requests are written from their own goroutine: git answers as it
+	// reads, and a full stdout pipe would otherwise stall both sides.
+	go func() {
+		defer stdin.Close()
+		for _, p := range todo {
+			if _, err := io.WriteString(stdin, p.blob+"\n"); err != nil {
+				return
+			}
+		}
+	}()
+	r := bufio.NewReader(stdout)
+	for _, p := range todo {
+		content, err := readBatchBlob(r)
+		if err != nil {
+			g.logger.Warn("Could not read branch file; stopping this pass's branch files here",
+				"root", root, "path", p.rel, "error", err)
+			break
+		}
+		syms = g.addDocument(indexer, syms, filepath.Join(root, filepath.FromSlash(p.rel)), content, p.branches)
+	}
+	stdout.Close()
+	cmd.Wait()
+	return syms
+}
+
+// readBatchBlob reads one object from a `git cat-file --batch` stream.
+func readBatchBlob(r *bufio.Reader) ([]byte, error) {
+	header, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("git cat-file: %s", strings.TrimSpace(header))
+	}
+	size, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("git cat-file: %s", strings.TrimSpace(header))
+	}
+	content := make([]byte, size+1) // and the newline after it
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, err
+	}
+	return content[:size], nil
+}
+
+// branchScope restricts q to one branch of root's shard: the working tree
+// when branch is empty. It reports false when root has no such branch, and
+// the shard should not be searched at all.
+//
+// A shard built without branches has no branch names, and a `branch:HEAD`
+// query matches nothing in it, so it is only scoped when it holds branches.
+func (g *BasicSearch) branchScope(root string, q query.Q, branch string) (query.Q, bool) {
+	g.mu.RLock()
+	indexed := g.projects[root].Branches
+	g.mu.RUnlock()
+	if branch == "" {
+		branch = headBranch
+	}
+	if len(indexed) == 0 {
+		return q, branch == headBranch
+	}
+	if _, ok := indexed[branch]; !ok && branch != headBranch {
+		return nil, false
+	}
+	return query.NewAnd(q, &query.Branch{Pattern: branch, Exact: true}), true
+}
@@ -0,0 +1,133 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newBranchedProject is a git repo checked out on main, with a polecat branch
+// that changed one file and added another.
+func newBranchedProject(t *testing.T) (*BasicSearch, string, chan indexEvent) {
+	t.Helper()
+	gitOrSkip(t)
+	g, root, events := newTestProject(t, map[string]string{
+		"a.go":      "package a // needle on main\n",
+		"shared.go": "package a // shared needle\n",
+	})
+	runGit(t, root, "init", "-q", "-b", "main")
+	runGit(t, root, "add", "a.go", "shared.go")
+	runGit(t, root, "commit", "-q", "-m", "main")
+	runGit(t, root, "checkout", "-q", "-b", "polecat-mg-1")
+	for name, content := range map[string]string{
+		"a.go":  "package a // needle on the branch\n",
+		"b.go":  "package a // needle only on the branch\n",
+		"c.txt": "no match\n",
+	} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runGit(t, root, "add", "a.go", "b.go", "c.txt")
+	runGit(t, root, "commit", "-q", "-m", "polecat work")
+	runGit(t, root, "checkout", "-q", "main")
+	return g, root, events
+}
+
+// TestBranchesAreSearchedOnlyWhenAsked: the working tree answers by default
+// and the branch answers when named, each with its own version of a file.
+func TestBranchesAreSearchedOnlyWhenAsked(t *testing.T) {
+	g, root, _ := newBranchedProject(t)
+	g.SetBranches([]string{"polecat-*"})
+	indexAndWait(t, g, root)
+
+	if got := fmt.Sprint(searchPaths(t, g, root, "needle", QueryOptions{})); got != "[a.go shared.go]" {
+		t.Errorf("working tree: %s, want [a.go shared.go]", got)
+	}
+	if got := fmt.Sprint(searchPaths(t, g, root, "needle", QueryOptions{Branch: "polecat-mg-1"})); got != "[a.go b.go shared.go]" {
+		t.Errorf("branch: %s, want [a.go b.go shared.go]", got)
+	}
+
+	res, err := g.SearchWith(root, "needle", "5s", QueryOptions{Branch: "polecat-mg-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range res.Files {
+		if f.Path == "a.go" && f.Matches[0].Content != "package a // needle on the branch" {
+			t.Errorf("a.go on the branch reads %q", f.Matches[0].Content)
+		}
+	}
+
+	all, err := g.SearchAllWith("needle", "5s", QueryOptions{Branch: "polecat-mg-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all.Files) != 3 || all.Searched != 1 {
+		t.Errorf("search_all on the branch: %d files from %d projects, want 3 from 1", len(all.Files), all.Searched)
+	}
+	if _, err := g.SearchWith(root, "needle", "5s", QueryOptions{Branch: "no-such-branch"}); !errors.Is(err, errInvalidQuery) {
+		t.Errorf("unindexed branch: error %v, want an invalid query", err)
+	}
+}
+
+// TestMovedBranchRebuildsTheShard: a new matching ref is picked up on the
+// next pass even though no file in the working tree changed.
+func TestMovedBranchRebuildsTheShard(t *testing.T) {
+	g, root, events := newBranchedProject(t)
+	g.SetBranches([]string{"polecat-*"})
+	indexAndWait(t, g, root)
+	waitIndexed(t, events, root)
+
+	runGit(t, root, "branch", "polecat-mg-2", "polecat-mg-1")
+	g.ReIndex(root)
+	waitIndexed(t, events, root)
+	g.Quiesce(15 * time.Second)
+
+	if got := fmt.Sprint(searchPaths(t, g, root, "needle", QueryOptions{Branch: "polecat-mg-2"})); got != "[a.go b.go shared.go]" {
+		t.Errorf("new branch: %s, want [a.go b.go shared.go]", got)
+	}
+}
+
+// TestWithoutBranchesOnlyTheWorkingTreeExists: with no patterns configured
+// the shard is the working tree alone, as before.
+func TestWithoutBranchesOnlyTheWorkingTreeExists(t *testing.T) {
+	g, root, _ := newBranchedProject(t)
+	indexAndWait(t, g, root)
+
+	if got := fmt.Sprint(searchPaths(t, g, root, "needle", QueryOptions{Branch: "HEAD"})); got != "[a.go shared.go]" {
+		t.Errorf("HEAD: %s, want [a.go shared.go]", got)
+	}
+	if _, err := g.SearchWith(root, "needle", "5s", QueryOptions{Branch: "polecat-mg-1"}); !errors.Is(err, errInvalidQuery) {
+		t.Errorf("branch without branch indexing: error %v, want an invalid query", err)
+	}
+}
+
+// TestWorkingTreeClaimsUnchangedBranchFiles: a working-tree file identical
+// to a branch's copy is that branch's document too, and only the files the
+// branch changed are left to add on their own.
+func TestWorkingTreeClaimsUnchangedBranchFiles(t *testing.T) {
+	same, changed := []byte("same\n"), []byte("changed\n")
+	plan := &branchPlan{blobs: map[string]map[string][]string{
+		"same.go":    {gitBlobHash(same, 40): {"origin/main", "polecat-mg-1"}},
+		"changed.go": {gitBlobHash(changed, 40): {"polecat-mg-1"}},
+	}}
+	if got := fmt.Sprint(plan.claim("same.go", same)); got != "[HEAD origin/main polecat-mg-1]" {
+		t.Errorf("same.go is in %s, want [HEAD origin/main polecat-mg-1]", got)
+	}
+	if got := fmt.Sprint(plan.claim("changed.go", []byte("edited\n"))); got != "[HEAD]" {
+		t.Errorf("changed.go is in %s, want [HEAD]", got)
+	}
+	if len(plan.blobs["same.go"]) != 0 || len(plan.blobs["changed.go"]) != 1 {
+		t.Errorf("left to add: %v, want only changed.go", plan.blobs)
+	}
+}
+
+func TestGitBlobHashMatchesGit(t *testing.T) {
+	// `printf 'hello\n' | git hash-object --stdin`
+	if got := gitBlobHash([]byte("hello\n"), 40); got != "ce013625030ba8dba906f756967f9e9ca394464a" {
+		t.Errorf("gitBlobHash = %s", got)
+	}
+}
@@ -0,0 +1,43 @@
+package search
+
+import (
+	"strconv"
+
+	"github.com/drellem2/pogo/internal/metrics"
+)
+
+// The search half of pogod's /metrics (user-022): index passes are counted as
+// they land, and each project's index status is read at scrape time.
+var indexPassesTotal = metrics.NewCounter("pogo_search_index_passes_total",
+	"Completed index passes, by whether the project's content had changed.", "changed")
+
+// RegisterMetrics publishes g's projects on /metrics.
+func (g *BasicSearch) RegisterMetrics() {
+	metrics.SetGaugeFunc("pogo_search_projects",
+		"Projects known to the search index, by indexing status.",
+		[]string{"status"}, func(emit metrics.Emit) {
+			counts := map[IndexingStatus]int{}
+			for _, p := range g.GetAllStatuses() {
+				counts[p.Status]++
+			}
+			for status, n := range counts {
+				emit(float64(n), string(status))
+			}
+		})
+	metrics.SetGaugeFunc("pogo_search_indexed_files",
+		"Files in the search index across all projects.", nil,
+		func(emit metrics.Emit) {
+			total := 0
+			for _, p := range g.GetAllStatuses() {
+				total += p.FileCount
+			}
+			emit(float64(total))
+		})
+	metrics.SetGaugeFunc("pogo_search_index_inflight",
+		"Index walks and queued index writes not yet on disk.", nil,
+		func(emit metrics.Emit) { emit(float64(g.inflight.Load())) })
+}
+
+func observeIndexPass(changed bool) {
+	indexPassesTotal.Inc(strconv.FormatBool(changed))
+}
@@ -0,0 +1,33 @@
+package search
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drellem2/pogo/internal/metrics"
+	"github.com/drellem2/pogo/pkg/plugin"
+)
+
+func TestRegisterMetricsReportsIndexStatus(t *testing.T) {
+	g := createBasicSearch()
+	root := makeTestRepo(t, g, "metrics")
+	passes := indexPassesTotal.Value("true") + indexPassesTotal.Value("false")
+
+	req := plugin.IProcessProjectReq(plugin.ProcessProjectReq{PathVar: root})
+	g.Index(&req)
+	waitForStatus(t, g, root, StatusReady)
+	g.Quiesce(10 * time.Second)
+	if got := indexPassesTotal.Value("true") + indexPassesTotal.Value("false") - passes; got != 1 {
+		t.Errorf("counted %v index passes, want 1", got)
+	}
+
+	g.RegisterMetrics()
+	var b strings.Builder
+	if err := metrics.Default.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	if out := b.String(); !strings.Contains(out, `pogo_search_projects{status="ready"} 1`+"\n") {
+		t.Errorf("scrape:\n%s", out)
+	}
+}
@@ -0,0 +1,246 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+
+	"github.com/go-enry/go-enry/v2"
+	"github.com/sourcegraph/zoekt"
+	"github.com/sourcegraph/zoekt/query"
+)
+
+// QueryOptions are the structured parts of a search request — `pose -g`,
+// `--lang`, `-s`/`-i`, `-F`, `-C`, `--max`, `--cursor` and `--branch`.
+//
+// They used to be whatever the caller could splice into the query text, and
+// output was unbounded: an agent running `pose err` in a large repo got every
+// match in every file back into its context window. Each option here is
+// applied as a zoekt query node or search option next to the parsed query,
+// never concatenated into it, so a glob or a language name cannot change how
+// the query itself parses.
+type QueryOptions struct {
+	// Globs restrict the files searched. A glob without a slash matches a
+	// file's base name in any directory (`*.go`); one with a slash matches
+	// from any directory boundary (`cmd/**/*.go`). A leading `!` excludes
+	// instead. Several include globs match a file if any of them does.
+	Globs []string `json:"globs,omitempty"`
+	// Lang restricts the files searched to one language, by name or alias
+	// as linguist spells them: "go", "python", "ts".
+	Lang string `json:"lang,omitempty"`
+	// Case is "yes" or "no" to force case sensitivity on every pattern in
+	// the query. Empty or "auto" keeps zoekt's rule: sensitive when the
+	// pattern has an upper-case letter.
+	Case string `json:"case,omitempty"`
+	// Literal searches for the query text as a fixed string instead of
+	// parsing it, so `foo(` or `a.b` need no escaping.
+	Literal bool `json:"literal,omitempty"`
+	// Context is how many lines around each match to return with it.
+	Context int `json:"context,omitempty"`
+	// Limit caps how many files come back, best ranked first. 0 means no
+	// limit.
+	Limit int `json:"limit,omitempty"`
+	// Cursor continues a limited search where the previous page stopped: it
+	// is the NextCursor that page returned, passed back unchanged.
+	Cursor string `json:"cursor,omitempty"`
+	// Branch searches one of the refs indexed beside the working tree
+	// (`[search] branches`) instead of the working tree: "origin/main",
+	// "polecat-mg-1234". Empty or "HEAD" is the working tree.
+	Branch string `json:"branch,omitempty"`
+}
+
+// errInvalidQuery marks a query or option the caller got wrong, as opposed to
+// a search that failed; Execute answers it with a 400 carrying the reason.
+var errInvalidQuery = errors.New("invalid query")
+
+func invalidQuery(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", errInvalidQuery, fmt.Sprintf(format, args...))
+}
+
+// buildQuery turns the query text and its options into one zoekt query.
+func buildQuery(data string, opts QueryOptions) (query.Q, error) {
+	var q query.Q
+	if opts.Literal {
+		q = &query.Substring{Pattern: data, CaseSensitive: data != strings.ToLower(data)}
+	} else {
+		parsed, err := query.Parse(data)
+		if err != nil {
+			return nil, invalidQuery("%v", err)
+		}
+		q = parsed
+	}
+
+	switch opts.Case {
+	case "", "auto":
+	case "yes":
+		q = withCase(q, true)
+	case "no":
+		q = withCase(q, false)
+	default:
+		return nil, invalidQuery("case %q, want yes, no or auto", opts.Case)
+	}
+
+	parts := []query.Q{q}
+	if len(opts.Globs) > 0 {
+		globs, err := globsQuery(opts.Globs)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, globs)
+	}
+	if opts.Lang != "" {
+		lang, ok := enry.GetLanguageByAlias(opts.Lang)
+		if !ok {
+			return nil, invalidQuery("unknown language %q", opts.Lang)
+		}
+		parts = append(parts, &query.Language{Language: lang})
+	}
+	return query.Simplify(query.NewAnd(parts...)), nil
+}
+
+// withCase forces every pattern in q to the given case sensitivity, the same
+// as a `case:yes`/`case:no` atom would. Nodes are copied, not modified.
+func withCase(q query.Q, sensitive bool) query.Q {
+	return query.Map(q, func(q query.Q) query.Q {
+		switch s := q.(type) {
+		case *query.Substring:
+			c := *s
+			c.CaseSensitive = sensitive
+			return &c
+		case *query.Regexp:
+			c := *s
+			c.CaseSensitive = sensitive
+			return &c
+		case *query.Symbol:
+			return &query.Symbol{Expr: withCase(s.Expr, sensitive)}
+		}
+		return q
+	})
+}
+
+// globsQuery is the file-name restriction for globs: any include glob, and
+// none of the excluded ones.
+func globsQuery(globs []string) (query.Q, error) {
+	var include, exclude []query.Q
+	for _, g := range globs {
+		negate := strings.HasPrefix(g, "!")
+		g = strings.TrimPrefix(g, "!")
+		if g == "" {
+			return nil, invalidQuery("empty glob")
+		}
+		r, err := syntax.Parse(globToRegexp(g), syntax.Perl)
+		if err != nil {
+			return nil, invalidQuery("glob %q: %v", g, err)
+		}
+		// Case-sensitive: a path is a path, and `*.Go` should not match
+		// main.go.
+		re := &query.Regexp{Regexp: r, FileName: true, CaseSensitive: true}
+		if negate {
+			exclude = append(exclude, &query.Not{Child: re})
+		} else {
+			include = append(include, re)
+		}
+	}
+	parts := exclude
+	if len(include) > 0 {
+		parts = append(parts, query.NewOr(include...))
+	}
+	return query.NewAnd(parts...), nil
+}
+
+// globToRegexp translates a glob to a regular expression over the indexed
+// file name, which is the file's absolute path. The glob is anchored at the
+// end and at a directory boundary: `*` and `?` stay within one path
+// component, `**` crosses them, `{a,b}` is an alternation and `[...]` a
+// character class.
+func globToRegexp(glob string) string {
+	glob = strings.TrimPrefix(glob, "/")
+	var b strings.Builder
+	b.WriteString("(^|/)")
+	inClass, inAlt := false, false
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case inClass:
+			b.WriteByte(c)
+			if c == ']' {
+				inClass = false
+			}
+		case c == '*' && strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case c == '*' && strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			inClass = true
+			b.WriteByte(c)
+			if strings.HasPrefix(glob[i+1:], "!") {
+				b.WriteByte('^')
+				i++
+			}
+		case c == '{':
+			inAlt = true
+			b.WriteString("(")
+		case c == '}' && inAlt:
+			inAlt = false
+			b.WriteString(")")
+		case c == ',' && inAlt:
+			b.WriteString("|")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// zoektOptions is the zoekt side of opts: enough files to fill the page the
+// cursor asks for, with the requested context. It also returns the page's
+// offset.
+func zoektOptions(opts QueryOptions) (*zoekt.SearchOptions, int, error) {
+	offset := 0
+	if opts.Cursor != "" {
+		n, err := strconv.Atoi(opts.Cursor)
+		if err != nil || n < 0 {
+			return nil, 0, invalidQuery("cursor %q is not one this service returned", opts.Cursor)
+		}
+		offset = n
+	}
+	if opts.Limit < 0 || opts.Context < 0 {
+		return nil, 0, invalidQuery("limit and context must not be negative")
+	}
+	zopts := &zoekt.SearchOptions{
+		ChunkMatches:    true,
+		NumContextLines: opts.Context,
+	}
+	if opts.Limit > 0 {
+		zopts.MaxDocDisplayCount = offset + opts.Limit
+	}
+	return zopts, offset, nil
+}
+
+// page cuts files, already ranked and truncated to offset+limit, down to the
+// page starting at offset. matched is how many files matched in all; more is
+// set when files beyond the page matched, and next is the cursor for them
+// when the search is limited.
+func page(files []zoekt.FileMatch, matched, offset, limit int) (out []zoekt.FileMatch, more bool, next string) {
+	if offset >= len(files) {
+		files = nil
+	} else {
+		files = files[offset:]
+	}
+	end := offset + len(files)
+	more = matched > end
+	if more && limit > 0 {
+		next = strconv.Itoa(end)
+	}
+	return files, more, next
+}
@@ -0,0 +1,179 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		glob  string
+		match []string
+		miss  []string
+	}{
+		{"*.go", []string{"/r/main.go", "/r/cmd/x/main.go"}, []string{"/r/main.go.orig", "/r/main.gox"}},
+		{"cmd/**/*.go", []string{"/r/cmd/main.go", "/r/cmd/a/b/main.go"}, []string{"/r/internal/main.go", "/r/xcmd/main.go"}},
+		{"*.{ts,tsx}", []string{"/r/a.ts", "/r/a.tsx"}, []string{"/r/a.js"}},
+		{"file?.[ch]", []string{"/r/file1.c", "/r/filex.h"}, []string{"/r/file12.c", "/r/file1.o"}},
+		{"a.b", []string{"/r/a.b"}, []string{"/r/aXb"}},
+	}
+	for _, tt := range tests {
+		re := regexp.MustCompile(globToRegexp(tt.glob))
+		for _, p := range tt.match {
+			if !re.MatchString(p) {
+				t.Errorf("glob %q (%s) does not match %s", tt.glob, re, p)
+			}
+		}
+		for _, p := range tt.miss {
+			if re.MatchString(p) {
+				t.Errorf("glob %q (%s) matches %s", tt.glob, re, p)
+			}
+		}
+	}
+}
+
+var optionsProject = map[string]string{
+	"main.go":   "package main\n\n// Needle in Go\nfunc main() {}\n",
+	"tool.py":   "# needle in python\nprint('hi')\n",
+	"notes.md":  "a needle(here) in the docs\n",
+	"ctx.txt":   "one\ntwo\nneedle\nthree\nfour\n",
+	"other.txt": "no match\n",
+}
+
+func searchPaths(t *testing.T, g *BasicSearch, root, data string, opts QueryOptions) []string {
+	t.Helper()
+	res, err := g.SearchWith(root, data, "5s", opts)
+	if err != nil {
+		t.Fatalf("search %q %+v: %v", data, opts, err)
+	}
+	var paths []string
+	for _, f := range res.Files {
+		paths = append(paths, f.Path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// TestQueryOptionsNarrowTheSearch: each option is applied as its own query
+// node, next to the query rather than spliced into it.
+func TestQueryOptionsNarrowTheSearch(t *testing.T) {
+	g, root, _ := newTestProject(t, optionsProject)
+	indexAndWait(t, g, root)
+
+	tests := []struct {
+		name string
+		data string
+		opts QueryOptions
+		want string
+	}{
+		{"no options", "needle", QueryOptions{}, "[ctx.txt main.go notes.md tool.py]"},
+		{"glob", "needle", QueryOptions{Globs: []string{"*.go"}}, "[main.go]"},
+		{"either glob", "needle", QueryOptions{Globs: []string{"*.go", "*.py"}}, "[main.go tool.py]"},
+		{"excluding glob", "needle", QueryOptions{Globs: []string{"!*.txt", "!*.md"}}, "[main.go tool.py]"},
+		{"language alias", "needle", QueryOptions{Lang: "python"}, "[tool.py]"},
+		{"case sensitive", "needle", QueryOptions{Case: "yes"}, "[ctx.txt notes.md tool.py]"},
+		{"case insensitive", "Needle", QueryOptions{Case: "no"}, "[ctx.txt main.go notes.md tool.py]"},
+		{"literal", "needle(here)", QueryOptions{Literal: true}, "[notes.md]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(searchPaths(t, g, root, tt.data, tt.opts)); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestContextLinesMarkTheMatch(t *testing.T) {
+	g, root, _ := newTestProject(t, optionsProject)
+	indexAndWait(t, g, root)
+
+	res, err := g.SearchWith(root, "needle", "5s", QueryOptions{Globs: []string{"ctx.txt"}, Context: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Files) != 1 || len(res.Files[0].Matches) != 1 {
+		t.Fatalf("got %+v, want one chunk in ctx.txt", res.Files)
+	}
+	m := res.Files[0].Matches[0]
+	if m.Line != 2 || m.Content != "two\nneedle\nthree" || fmt.Sprint(m.MatchLines) != "[3]" {
+		t.Errorf("chunk = line %d %q matching %v, want line 2 \"two\\nneedle\\nthree\" matching [3]", m.Line, m.Content, m.MatchLines)
+	}
+}
+
+// TestLimitPagesThroughEveryFile: pages of a limited search never overlap,
+// together hold every match, and the last one has no cursor.
+func TestLimitPagesThroughEveryFile(t *testing.T) {
+	g, root, _ := newTestProject(t, optionsProject)
+	indexAndWait(t, g, root)
+
+	seen := map[string]bool{}
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 4 {
+			t.Fatal("paging did not end")
+		}
+		res, err := g.SearchWith(root, "needle", "5s", QueryOptions{Limit: 3, Cursor: cursor})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Files) > 3 {
+			t.Fatalf("page of %d files, want at most 3", len(res.Files))
+		}
+		for _, f := range res.Files {
+			if seen[f.Path] {
+				t.Errorf("%s on two pages", f.Path)
+			}
+			seen[f.Path] = true
+		}
+		if res.NextCursor == "" {
+			if res.Truncated {
+				t.Error("the last page says it is truncated")
+			}
+			break
+		}
+		cursor = res.NextCursor
+	}
+	if len(seen) != 4 {
+		t.Errorf("paged through %d files, want 4", len(seen))
+	}
+
+	all, err := g.SearchAllWith("needle", "5s", QueryOptions{Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all.Files) != 3 || !all.Truncated || all.NextCursor != "3" {
+		t.Errorf("search_all page: %d files, truncated %v, cursor %q; want 3, true, \"3\"", len(all.Files), all.Truncated, all.NextCursor)
+	}
+}
+
+// TestInvalidOptionsAreTheCallersError: a bad option is a 400 with the
+// reason, not a 500 "Error executing search."
+func TestInvalidOptionsAreTheCallersError(t *testing.T) {
+	g, root, _ := newTestProject(t, optionsProject)
+	indexAndWait(t, g, root)
+
+	for _, opts := range []QueryOptions{
+		{Lang: "no-such-language"},
+		{Case: "sometimes"},
+		{Cursor: "page two"},
+		{Context: -1},
+	} {
+		if _, err := g.SearchWith(root, "needle", "5s", opts); !errors.Is(err, errInvalidQuery) {
+			t.Errorf("%+v: error %v, want an invalid query", opts, err)
+		}
+	}
+
+	req, _ := json.Marshal(SearchRequest{Type: "search", ProjectRoot: root, Data: "needle",
+		QueryOptions: QueryOptions{Lang: "no-such-language"}})
+	var resp ErrorResponse
+	if err := json.Unmarshal([]byte(g.Execute(string(req))), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.ErrorCode != 400 || !strings.Contains(resp.Error, "no-such-language") {
+		t.Errorf("Execute answered %+v, want a 400 naming the language", resp)
+	}
+}
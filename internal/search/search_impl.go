@@ -2,6 +2,7 @@ package search
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
 	"sort"
@@ -65,6 +66,18 @@ type BasicSearch struct {
 	// while g.mu is taken.
 	unreadableMu     sync.Mutex
 	unreadableWarned map[string]struct{}
+	// shards holds each project's zoekt searcher open between queries. See
+	// shardCache.
+	shards *shardCache
+	// newSymbolParser, when set, starts the ctags parser a build pass uses
+	// to extract symbols; nil means symbols are off. See SetSymbols. Guarded
+	// by symbolsMu.
+	symbolsMu       sync.RWMutex
+	newSymbolParser func() symbolParser
+	// branchPatterns selects the refs indexed beside the working tree; empty
+	// means only the working tree. See SetBranches. Guarded by branchesMu.
+	branchesMu     sync.RWMutex
+	branchPatterns []string
 }
 
 // msgUnreadableFile is the announcement a dropped file gets. It is a constant
@@ -210,12 +223,15 @@ func (g *BasicSearch) notifyIndexed(root string, contentChanged bool) {
 
 // Input to an "Execute" call should be a serialized SearchRequest
 type SearchRequest struct {
-	// Values: "search" or "files"
+	// Values: "search", "search_all", "symbol" or "files"
 	Type        string `json:"type"`
 	ProjectRoot string `json:"projectRoot"`
 	// Command timeout duration - only for 'search'-type requests
 	Duration string `json:"string"`
 	Data     string `json:"data"`
+	// QueryOptions narrow and page a 'search' or 'search_all' request. A
+	// 'symbol' request only honours Limit.
+	QueryOptions
 }
 
 type SearchResponse struct {
@@ -325,13 +341,50 @@ func (g *BasicSearch) Execute(req string) string {
 	switch reqType := searchRequest.Type; reqType {
 	case "search":
 		searchRequest.ProjectRoot = clean(searchRequest.ProjectRoot)
-		results, err := g.Search(searchRequest.ProjectRoot,
-			searchRequest.Data, searchRequest.Duration)
+		results, err := g.SearchWith(searchRequest.ProjectRoot,
+			searchRequest.Data, searchRequest.Duration, searchRequest.QueryOptions)
+		if errors.Is(err, errInvalidQuery) {
+			g.logger.Info("400 Invalid query.", "error", err)
+			return g.errorResponse(400, err.Error())
+		}
 		if err != nil {
 			g.logger.Error("500 Error executing search.", "error", err)
 			return g.errorResponse(500, "Error executing search.")
 		}
 		return g.searchResponse(nil, results)
+	case "search_all":
+		results, err := g.SearchAllWith(searchRequest.Data, searchRequest.Duration, searchRequest.QueryOptions)
+		if errors.Is(err, errInvalidQuery) {
+			g.logger.Info("400 Invalid query.", "error", err)
+			return g.errorResponse(400, err.Error())
+		}
+		if err != nil {
+			g.logger.Error("500 Error executing search.", "error", err)
+			return g.errorResponse(500, "Error executing search.")
+		}
+		bytes, err := json.Marshal(&SearchAllResponse{Results: *results})
+		if err != nil {
+			g.logger.Error("Error writing search response")
+			return g.errorResponse(500, "Error writing search response")
+		}
+		return string(bytes)
+	case "symbol":
+		// Data is the symbol name. An empty ProjectRoot searches every project.
+		if searchRequest.ProjectRoot != "" {
+			searchRequest.ProjectRoot = clean(searchRequest.ProjectRoot)
+		}
+		results, err := g.SymbolSearch(searchRequest.ProjectRoot, searchRequest.Data,
+			searchRequest.Duration, searchRequest.Limit)
+		if err != nil {
+			g.logger.Error("500 Error executing symbol search.", "error", err)
+			return g.errorResponse(500, "Error executing symbol search.")
+		}
+		bytes, err := json.Marshal(&SearchAllResponse{Results: *results})
+		if err != nil {
+			g.logger.Error("Error writing search response")
+			return g.errorResponse(500, "Error writing search response")
+		}
+		return string(bytes)
 	case "files":
 		searchRequest.ProjectRoot = clean(searchRequest.ProjectRoot)
 		proj, err3 := g.GetFiles(searchRequest.ProjectRoot)
@@ -441,6 +494,9 @@ func (g *BasicSearch) Evict(projectRoot string) {
 	// prune set is exactly "everything remembered under here is stale".
 	g.forgetGitTreeHashWarning(projectRoot)
 	g.reconcileUnreadable(projectRoot, nil, true)
+	// The open shard goes too: an evicted project is no longer searched, and
+	// its mapping is the largest thing the daemon holds for it.
+	g.shards.invalidate(projectRoot)
 	if existed {
 		g.logger.Info("Evicted project from in-memory index map: " + projectRoot)
 	}
@@ -500,6 +556,7 @@ func createBasicSearch() *BasicSearch {
 		maxFilesPerTree:  maxF,
 		gitHashWarned:    make(map[string]struct{}),
 		unreadableWarned: make(map[string]struct{}),
+		shards:           newShardCache(),
 	}
 	basicSearch.updater = basicSearch.newProjectUpdater()
 
@@ -1,7 +1,6 @@
 package search
 
 import (
-	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -12,13 +11,12 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/sabhiram/go-gitignore"
 	"github.com/sourcegraph/zoekt"
-	"github.com/sourcegraph/zoekt/query"
 
 	pogoPlugin "github.com/drellem2/pogo/pkg/plugin"
 )
@@ -35,6 +33,24 @@ const indexStartCapacity = 50
 type PogoChunkMatch struct {
 	Line    uint32 `json:"line"`
 	Content string `json:"content"`
+	// MatchLines lists the lines of a multi-line chunk — one carrying context
+	// lines, or matches on adjacent lines — that hold a match. It is empty
+	// for a single-line chunk, whose one line is Line.
+	MatchLines []uint32 `json:"matchLines,omitempty"`
+	// Kind is ChunkDefinition when the match is a symbol section (a `sym:`
+	// query), ChunkReference for the other matches of a symbol search in a
+	// project whose shard has symbols, and empty when nothing is known.
+	Kind string `json:"kind,omitempty"`
+	// Symbol is what ctags said about a definition.
+	Symbol *PogoSymbol `json:"symbol,omitempty"`
+}
+
+// PogoSymbol is the ctags tag behind a definition match.
+type PogoSymbol struct {
+	Name       string `json:"name"`
+	Kind       string `json:"kind,omitempty"`
+	Parent     string `json:"parent,omitempty"`
+	ParentKind string `json:"parentKind,omitempty"`
 }
 
 type PogoFileMatch struct {
@@ -44,6 +60,11 @@ type PogoFileMatch struct {
 
 type SearchResults struct {
 	Files []PogoFileMatch `json:"files"`
+	// Truncated is set when more files matched than the page holds.
+	Truncated bool `json:"truncated,omitempty"`
+	// NextCursor, when set, asks for the next page: send it back as the
+	// request's cursor.
+	NextCursor string `json:"nextCursor,omitempty"`
 }
 
 // IndexingStatus represents the state of a project's search index.
@@ -64,6 +85,13 @@ type IndexedProject struct {
 	FileMtimes  map[string]int64  `json:"file_mtimes,omitempty"`
 	GitTreeHash string            `json:"git_tree_hash,omitempty"`
 	Status      IndexingStatus    `json:"indexing_status"`
+	// Symbols records whether the shard was built with symbol extraction on,
+	// so turning it on or off rebuilds shards whose files have not changed.
+	Symbols bool `json:"symbols,omitempty"`
+	// Branches maps each ref indexed beside the working tree to the commit it
+	// pointed at, so a pass whose refs moved rebuilds the shard. See
+	// SetBranches.
+	Branches map[string]string `json:"branches,omitempty"`
 }
 
 // gitTreeHash returns the SHA of the tree object at HEAD for the given repo.
@@ -86,6 +114,13 @@ func deepCopyProject(p IndexedProject) IndexedProject {
 		Root:        p.Root,
 		GitTreeHash: p.GitTreeHash,
 		Status:      p.Status,
+		Symbols:     p.Symbols,
+	}
+	if p.Branches != nil {
+		cp.Branches = make(map[string]string, len(p.Branches))
+		for k, v := range p.Branches {
+			cp.Branches[k] = v
+		}
 	}
 	cp.Paths = make([]string, len(p.Paths))
 	copy(cp.Paths, p.Paths)
@@ -232,6 +267,7 @@ func (g *BasicSearch) applyUpdate(upd *indexUpdate) {
 	g.projects[proj.Root] = *proj
 	g.mu.Unlock()
 	changed := g.serializeProjectIndex(proj, &prev, prevKnown, upd.contents)
+	observeIndexPass(changed)
 	g.notifyIndexed(proj.Root, changed)
 }
 
@@ -561,6 +597,7 @@ func ParseGitIgnore(path string) (*ignore.GitIgnore, error) {
 }
 
 func (g *BasicSearch) deleteIndexFile(p *IndexedProject) error {
+	g.shards.invalidate(p.Root)
 	searchDir, err := p.makeSearchDir()
 	if err != nil {
 		g.logger.Error("Error making search dir", "root", p.Root, "error", err)
@@ -587,7 +624,7 @@ func (g *BasicSearch) getSearchFile(p *IndexedProject, filename string) (*os.Fil
 		return nil, err
 	}
 	indexPath := filepath.Join(searchDir, filename)
-	indexFile, err := os.OpenFile(indexPath, os.O_CREATE|os.O_WRONLY, 0600)
+	indexFile, err := os.OpenFile(indexPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
 	if err != nil {
 		g.logger.Error("Error opening index file", "root", path, "index_path", indexPath, "error", err)
 		return nil, err
@@ -595,10 +632,6 @@ func (g *BasicSearch) getSearchFile(p *IndexedProject, filename string) (*os.Fil
 	return indexFile, nil
 }
 
-func (g *BasicSearch) getIndexFile(p *IndexedProject) (*os.File, error) {
-	return g.getSearchFile(p, codeSearchIndexFileName)
-}
-
 func (g *BasicSearch) Index(req *pogoPlugin.IProcessProjectReq) {
 	// Held across the whole walk so Quiesce cannot report idle between a
 	// synchronous Index call starting and queueUpdate taking its own count.
@@ -716,6 +749,13 @@ func (g *BasicSearch) serializeProjectIndex(proj *IndexedProject, prev *IndexedP
 		// indexer retries at base cadence instead of backing off.
 		return true
 	}
+	// A shard built with symbols on must be rebuilt when they are turned off,
+	// and the other way round, even if no file changed.
+	proj.Symbols = g.symbolsEnabled()
+	symbolsChanged := prevKnown && prev.Symbols != proj.Symbols
+	// Likewise when an indexed branch moved, appeared or went away.
+	proj.Branches = g.indexedBranches(proj.Root)
+	branchesChanged := prevKnown && !sameBranches(prev.Branches, proj.Branches)
 	saveFilePath := filepath.Join(searchDir, saveFileName)
 	g.writeSaveFile(proj, saveFilePath)
 	// Check if file content actually changed by comparing hashes with the
@@ -758,7 +798,7 @@ func (g *BasicSearch) serializeProjectIndex(proj *IndexedProject, prev *IndexedP
 		g.logger.Debug(indexedMsg)
 	}
 
-	if !contentChanged {
+	if !contentChanged && !symbolsChanged && !branchesChanged {
 		// Verify zoekt index file actually exists before skipping rebuild
 		indexPath := filepath.Join(searchDir, codeSearchIndexFileName)
 		if _, err := os.Lstat(indexPath); err == nil {
@@ -769,20 +809,41 @@ func (g *BasicSearch) serializeProjectIndex(proj *IndexedProject, prev *IndexedP
 		g.logger.Info("Zoekt index missing, rebuilding for " + proj.Root)
 	}
 
-	// Now serialize zoekt index
-
-	// First delete the old index
-	g.deleteIndexFile(proj)
+	// Now serialize zoekt index. The old shard stays in place until the new
+	// one is complete — see writeIndexFile — so queries keep answering from it
+	// while the build runs.
 
 	// Root-relative paths the build could not read after all; see the drop
 	// below the loop.
 	var unbuildable []string
 
-	indexer, err := zoekt.NewIndexBuilder(nil)
+	// With branches, the shard declares the working tree and each of them,
+	// and every document says which it belongs to; see branches.go.
+	var repo *zoekt.Repository
+	var plan *branchPlan
+	if len(proj.Branches) > 0 {
+		repo = branchRepository(proj.Root, proj.GitTreeHash, proj.Branches)
+		ignored, _ := ParseGitIgnore(proj.Root)
+		plan = g.planBranches(proj.Root, proj.Branches, ignored)
+	}
+	inBranches := func(path string, content []byte) []string {
+		if plan == nil {
+			return nil
+		}
+		return plan.claim(path, content)
+	}
+	indexer, err := zoekt.NewIndexBuilder(repo)
 	if err != nil {
-		g.logger.Error("Error creating search index")
+		g.logger.Error("Error creating search index", "root", proj.Root, "error", err)
+		g.deleteIndexFile(proj)
 		return contentChanged
 	}
+	syms := g.symbolParserForPass()
+	defer func() {
+		if syms != nil {
+			syms.Close()
+		}
+	}()
 
 	// Next create the code search index
 	// TODO - add some useful repository metadata
@@ -794,7 +855,7 @@ func (g *BasicSearch) serializeProjectIndex(proj *IndexedProject, prev *IndexedP
 		// carries its trailing separator, so fullPath matches what absolute()
 		// would return for an existing file.
 		if data, ok := contents[path]; ok {
-			indexer.AddFile(fullPath, data)
+			syms = g.addDocument(indexer, syms, fullPath, data, inBranches(path, data))
 			continue
 		}
 		// Both failure arms below name their path as a field rather than
@@ -816,10 +877,13 @@ func (g *BasicSearch) serializeProjectIndex(proj *IndexedProject, prev *IndexedP
 				g.logger.Error("Error reading file", "path", absPath)
 				unbuildable = append(unbuildable, path)
 			} else {
-				indexer.AddFile(absPath, bytes)
+				syms = g.addDocument(indexer, syms, absPath, bytes, inBranches(path, bytes))
 			}
 		}
 	}
+	if plan != nil {
+		syms = g.addBranchDocuments(indexer, syms, proj.Root, plan)
+	}
 	// A path that failed above was in the census with a valid cached hash: the
 	// walk read it successfully, and between the walk and here the file
 	// stopped being readable — a chmod, a delete, a mount going away.
@@ -841,22 +905,73 @@ func (g *BasicSearch) serializeProjectIndex(proj *IndexedProject, prev *IndexedP
 		g.projects[proj.Root] = *proj
 		g.mu.Unlock()
 	}
-	indexFile, err := g.getIndexFile(proj)
-	if err != nil {
-		g.logger.Error("Error getting index file", "root", proj.Root, "error", err)
-		return contentChanged
-	}
-	defer indexFile.Close()
-	err = indexer.Write(indexFile)
-	if err != nil {
+	if err := g.writeIndexFile(proj, indexer); err != nil {
 		// One line, not two: the root and the error describe the same failure,
 		// and the second call's whole message was "Error: ".
 		g.logger.Error("Error writing index file", "root", proj.Root, "error", err)
+		// A missing shard is what makes the next pass rebuild even when it
+		// finds no content change; a stale one left in place would be served
+		// until the content changed again.
+		g.deleteIndexFile(proj)
 		return contentChanged
 	}
 	return contentChanged
 }
 
+// addDocument adds one file to the shard being built, with its symbols when
+// syms is non-nil. It returns the parser to use for the rest of the pass: a
+// ctags failure is usually the process dying or hanging, so the first one
+// turns symbols off until the next pass rather than failing every file after
+// it. The file itself is always indexed, with or without its symbols.
+// branches names the shard's branches the file belongs to; nil when the shard
+// has none.
+func (g *BasicSearch) addDocument(indexer *zoekt.IndexBuilder, syms symbolParser, name string, content []byte, branches []string) symbolParser {
+	doc := zoekt.Document{Name: name, Content: content, Branches: branches}
+	if syms != nil {
+		if err := attachSymbols(&doc, syms); err != nil {
+			g.logger.Warn("Symbol extraction failed; indexing the rest of this pass without symbols", "path", name, "error", err)
+			syms.Close()
+			syms = nil
+		}
+	}
+	if err := indexer.Add(doc); err != nil && doc.Symbols != nil {
+		// The builder refuses sections it cannot place; the content is still
+		// worth having.
+		indexer.Add(zoekt.Document{Name: name, Content: content, Branches: branches})
+	}
+	return syms
+}
+
+// writeIndexFile writes the built shard beside the live one and renames it
+// into place, then drops the cached searcher for the old one.
+//
+// Writing in place — delete, then create and fill — was fine while every
+// query opened the file afresh and could only fail on the gap. With shards
+// held open across queries (shardCache) a reader must never map a half-written
+// file, and the rename makes the swap atomic: a query sees the old shard or the
+// new one. A searcher still reading the old shard keeps its inode alive until
+// it is released.
+func (g *BasicSearch) writeIndexFile(proj *IndexedProject, indexer *zoekt.IndexBuilder) error {
+	tmpFile, err := g.getSearchFile(proj, codeSearchIndexFileName+".tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+	err = indexer.Write(tmpFile)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, filepath.Join(filepath.Dir(tmpPath), codeSearchIndexFileName))
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	g.shards.invalidate(proj.Root)
+	return nil
+}
+
 func (g *BasicSearch) Load(projectRoot string) (*IndexedProject, error) {
 	project := &IndexedProject{
 		Root:   projectRoot,
@@ -944,6 +1059,22 @@ func (g *BasicSearch) GetFiles(projectRoot string) (*IndexedProject, error) {
 }
 
 func (g *BasicSearch) Search(projectRoot string, data string, duration string) (*SearchResults, error) {
+	return g.SearchWith(projectRoot, data, duration, QueryOptions{})
+}
+
+// SearchWith searches one project's shard for data, narrowed and paged by
+// opts. Files come back best ranked first.
+func (g *BasicSearch) SearchWith(projectRoot string, data string, duration string, opts QueryOptions) (*SearchResults, error) {
+	q, err := buildQuery(data, opts)
+	if err != nil {
+		g.logger.Info("Invalid query", "error", err)
+		return nil, err
+	}
+	zopts, offset, err := zoektOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
 	g.mu.RLock()
 	project, ok := g.projects[projectRoot]
 	var knownProjects string
@@ -954,83 +1085,93 @@ func (g *BasicSearch) Search(projectRoot string, data string, duration string) (
 	if !ok {
 		return nil, errors.New("Unknown project " + projectRoot + ". Known projects: " + knownProjects)
 	}
-	// Open index file
 	searchDir, err := project.makeSearchDir()
 	if err != nil {
 		g.logger.Error("Error making search dir", "root", projectRoot, "error", err)
 		return nil, err
 	}
 	indexPath := filepath.Join(searchDir, codeSearchIndexFileName)
-	indexFile, err := os.Open(indexPath)
+	shard, err := g.shards.acquire(projectRoot, indexPath)
 	if err != nil {
 		g.logger.Error("Error opening index file", "index_path", indexPath, "error", err)
 		return nil, err
 	}
-	defer indexFile.Close()
-	index, err2 := zoekt.NewIndexFile(indexFile)
-	if err2 != nil {
-		g.logger.Error("Error reading index file", "index_path", indexPath, "error", err2)
-		return nil, err2
-	}
-	// Search
-	searcher, err := zoekt.NewSearcher(index)
-	if err != nil {
-		g.logger.Error("Error creating searcher", "index_path", indexPath, "error", err)
-		return nil, err
+	defer g.shards.release(shard)
+	q, ok = g.branchScope(projectRoot, q, opts.Branch)
+	if !ok {
+		return nil, invalidQuery("branch %q is not indexed for %s", opts.Branch, projectRoot)
 	}
-	defer searcher.Close()
-
-	var (
-		ctx    context.Context
-		cancel context.CancelFunc
-	)
 
-	timeout, err := time.ParseDuration(duration)
-	if err == nil {
-		// The request has a timeout, so create a context that is
-		// canceled automatically when the timeout expires.
-		ctx, cancel = context.WithTimeout(context.Background(), timeout)
-	} else {
-		ctx, cancel = context.WithCancel(context.Background())
-	}
+	ctx, cancel := searchContext(duration)
 	defer cancel()
 
-	query, err := query.Parse(data)
+	result, err := shard.searcher.Search(ctx, q, zopts)
 	if err != nil {
-		g.logger.Error("Error parsing query")
+		g.logger.Error("Error searching index", "error", err)
 		return nil, err
 	}
 
-	queryOptions := &zoekt.SearchOptions{
-		ChunkMatches: true,
+	// A single shard's searcher neither ranks nor truncates its files; the
+	// multi-shard searcher that would is not used here.
+	files := zoekt.SortAndTruncateFiles(result.Files, zopts)
+	files, more, next := page(files, result.Stats.FileCount, offset, opts.Limit)
+	fileMatches := make([]PogoFileMatch, len(files))
+	for i := range files {
+		fileMatches[i] = toPogoFileMatch(&files[i], projectRoot)
 	}
+	return &SearchResults{
+		Files:      fileMatches,
+		Truncated:  more,
+		NextCursor: next,
+	}, nil
+}
 
-	result, err := searcher.Search(ctx, query, queryOptions)
-	if err != nil {
-		g.logger.Error("Error searching index")
-		return nil, err
+// matchLines returns the lines match's ranges cover, in order.
+func matchLines(match zoekt.ChunkMatch) []uint32 {
+	var lines []uint32
+	seen := make(map[uint32]bool)
+	for _, r := range match.Ranges {
+		for l := r.Start.LineNumber; l <= r.End.LineNumber; l++ {
+			if !seen[uint32(l)] {
+				seen[uint32(l)] = true
+				lines = append(lines, uint32(l))
+			}
+		}
 	}
+	sort.Slice(lines, func(a, b int) bool { return lines[a] < lines[b] })
+	return lines
+}
 
-	// Create PogoFileMatch array of same size as result.Files
-	fileMatches := make([]PogoFileMatch, len(result.Files))
-
-	for i, file := range result.Files {
-		chunkMatches := make([]PogoChunkMatch, len(file.ChunkMatches))
-		for j, match := range file.ChunkMatches {
-			chunkMatches[j] = PogoChunkMatch{
-				Line:    match.ContentStart.LineNumber,
-				Content: "",
-			}
-			if len(match.Content) > 0 {
-				chunkMatches[j].Content = strings.TrimSpace(string(match.Content))
-			}
+// toPogoFileMatch converts one zoekt file match to the plugin's wire shape,
+// with its path relative to root. Everything is copied out of the match: its
+// bytes point into the shard's mapping, which is only valid while the shard
+// is held.
+func toPogoFileMatch(file *zoekt.FileMatch, root string) PogoFileMatch {
+	chunkMatches := make([]PogoChunkMatch, len(file.ChunkMatches))
+	for j, match := range file.ChunkMatches {
+		chunkMatches[j] = PogoChunkMatch{
+			Line:    match.ContentStart.LineNumber,
+			Content: "",
+		}
+		content := strings.TrimRight(string(match.Content), "\r\n")
+		if strings.Contains(content, "\n") {
+			// Several lines: keep their indentation so they still line up,
+			// and say which of them matched.
+			chunkMatches[j].Content = content
+			chunkMatches[j].MatchLines = matchLines(match)
+		} else {
+			chunkMatches[j].Content = strings.TrimSpace(content)
 		}
-		fileMatches[i] = PogoFileMatch{
-			Path:    strings.Replace(file.FileName, projectRoot, "", 1),
-			Matches: chunkMatches,
+		for _, sym := range match.SymbolInfo {
+			if sym != nil {
+				chunkMatches[j].Kind = ChunkDefinition
+				chunkMatches[j].Symbol = &PogoSymbol{Name: sym.Sym, Kind: sym.Kind, Parent: sym.Parent, ParentKind: sym.ParentKind}
+				break
+			}
 		}
 	}
-	return &SearchResults{
-		Files: fileMatches,
-	}, nil
+	return PogoFileMatch{
+		Path:    strings.Replace(file.FileName, root, "", 1),
+		Matches: chunkMatches,
+	}
 }
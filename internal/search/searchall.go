@@ -0,0 +1,215 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/zoekt"
+	"github.com/sourcegraph/zoekt/query"
+)
+
+// RankedFileMatch is one file in a cross-repo result, carrying the project it
+// came from and the score it was ranked by.
+type RankedFileMatch struct {
+	Root string `json:"root"`
+	PogoFileMatch
+	Score float64 `json:"score"`
+}
+
+// SearchAllResults is the answer to a "search_all" request: the best files
+// across every project's shard, best first.
+type SearchAllResults struct {
+	Files []RankedFileMatch `json:"files"`
+	// Searched is how many project shards the query ran against. A project
+	// with no shard on disk yet (still indexing, or skipped as too large) is
+	// not counted and not an error.
+	Searched int `json:"searched"`
+	// Truncated is set when more files matched than the limit let through.
+	Truncated bool `json:"truncated"`
+	// NextCursor, when set, asks for the next page of a "search_all": send it
+	// back as the request's cursor.
+	NextCursor string `json:"nextCursor,omitempty"`
+	// Errors maps a project root to why its shard could not be searched. The
+	// other projects' results are still returned.
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+type SearchAllResponse struct {
+	Results SearchAllResults `json:"results"`
+	Error   string           `json:"error"`
+}
+
+// SearchAll runs one query against every project's shard in a single pass
+// and ranks the union globally, returning at most limit files (0 means no
+// limit).
+//
+// This is the path `pose --all` takes. It used to be the client's job: list
+// the projects, then send one "search" request per project and print each
+// repo's results as they came back, so a query over ~80 repos was ~80 plugin
+// round trips, each opening its shard from scratch, and the output was ordered
+// by which repo answered first. Here the shards are already open (shardCache),
+// the searches run in parallel in-process, and the results are ordered by
+// score across repos — the best match in the fleet first, not the best match
+// in whichever repo was fastest.
+//
+// Ranking uses zoekt.SortFiles, the same ordering zoekt's own multi-shard
+// searcher applies when merging shards, so a per-repo search and the global
+// one rank files the same way.
+func (g *BasicSearch) SearchAll(data string, duration string, limit int) (*SearchAllResults, error) {
+	return g.SearchAllWith(data, duration, QueryOptions{Limit: limit})
+}
+
+// SearchAllWith is SearchAll narrowed and paged by opts.
+func (g *BasicSearch) SearchAllWith(data string, duration string, opts QueryOptions) (*SearchAllResults, error) {
+	q, err := buildQuery(data, opts)
+	if err != nil {
+		g.logger.Info("Invalid query", "error", err)
+		return nil, err
+	}
+	zopts, offset, err := zoektOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := searchContext(duration)
+	defer cancel()
+	pass := g.searchShards(ctx, g.projectRoots(), q, zopts, opts.Branch)
+	// The shards are held until the merged results are copied out below: the
+	// matches point into the shards' mappings.
+	defer pass.release()
+
+	files, more, next := page(pass.files, pass.matched, offset, opts.Limit)
+	out := &SearchAllResults{Files: []RankedFileMatch{}, Searched: pass.searched, Errors: pass.errors}
+	out.Truncated, out.NextCursor = more, next
+	for i := range files {
+		out.Files = append(out.Files, toRankedFileMatch(&files[i]))
+	}
+	return out, nil
+}
+
+// searchContext is the context a search runs under: bounded by duration when
+// it parses, unbounded otherwise.
+func searchContext(duration string) (context.Context, context.CancelFunc) {
+	if timeout, err := time.ParseDuration(duration); err == nil {
+		return context.WithTimeout(context.Background(), timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// projectRoots returns every known project root, sorted so that ties in
+// score come back in a stable order.
+func (g *BasicSearch) projectRoots() []string {
+	g.mu.RLock()
+	roots := make([]string, 0, len(g.projects))
+	for root := range g.projects {
+		roots = append(roots, root)
+	}
+	g.mu.RUnlock()
+	sort.Strings(roots)
+	return roots
+}
+
+// shardPass is one query's results across a set of project shards, merged
+// and ranked. Each file's Repository holds the project root it came from.
+// The shards stay held until release: the files point into their mappings.
+type shardPass struct {
+	files    []zoekt.FileMatch
+	searched int
+	// matched is how many files matched across all shards, before the
+	// display limit.
+	matched int
+	errors  map[string]string
+	held    []*openShard
+	shards  *shardCache
+}
+
+func (p *shardPass) release() {
+	for _, s := range p.held {
+		p.shards.release(s)
+	}
+	p.held = nil
+}
+
+// searchShards runs q against each root's shard in parallel and merges the
+// results, ranked and truncated by opts. Each shard is searched on branch —
+// its working tree when empty — and a shard without that branch is left out.
+func (g *BasicSearch) searchShards(ctx context.Context, roots []string, q query.Q, opts *zoekt.SearchOptions, branch string) *shardPass {
+	type shardResult struct {
+		shard  *openShard
+		result *zoekt.SearchResult
+		err    error
+	}
+	results := make([]shardResult, len(roots))
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i, root := range roots {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			scoped, ok := g.branchScope(root, q, branch)
+			if !ok {
+				return
+			}
+			indexPath := filepath.Join(root, pogoDir, searchDir, codeSearchIndexFileName)
+			shard, err := g.shards.acquire(root, indexPath)
+			if err != nil {
+				results[i].err = err
+				return
+			}
+			results[i].shard = shard
+			results[i].result, results[i].err = shard.searcher.Search(ctx, scoped, opts)
+		}()
+	}
+	wg.Wait()
+
+	pass := &shardPass{shards: g.shards}
+	for i, r := range results {
+		root := roots[i]
+		if r.shard != nil {
+			pass.held = append(pass.held, r.shard)
+		}
+		switch {
+		case errors.Is(r.err, os.ErrNotExist):
+			continue
+		case r.err != nil:
+			g.logger.Warn("Error searching project shard", "root", root, "error", r.err)
+			if pass.errors == nil {
+				pass.errors = make(map[string]string)
+			}
+			pass.errors[root] = r.err.Error()
+			continue
+		case r.shard == nil:
+			// Not on the requested branch.
+			continue
+		}
+		pass.searched++
+		pass.matched += r.result.Stats.FileCount
+		for _, f := range r.result.Files {
+			// The shard's own repository name, when it has one, is its root
+			// too; the field says which project each file came from through
+			// the merge.
+			f.Repository = root
+			pass.files = append(pass.files, f)
+		}
+	}
+	pass.files = zoekt.SortAndTruncateFiles(pass.files, opts)
+	return pass
+}
+
+// toRankedFileMatch converts one merged file, whose Repository carries its
+// project root.
+func toRankedFileMatch(f *zoekt.FileMatch) RankedFileMatch {
+	return RankedFileMatch{
+		Root:          f.Repository,
+		PogoFileMatch: toPogoFileMatch(f, f.Repository),
+		Score:         f.Score,
+	}
+}
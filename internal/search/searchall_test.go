@@ -0,0 +1,89 @@
+package search
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// addTestProject creates another project directory for g and indexes it.
+func addTestProject(t *testing.T, g *BasicSearch, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("write fixture %s: %v", name, err)
+		}
+	}
+	root, err := absolute(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quiesceOnCleanup(t, g)
+	indexAndWait(t, g, root)
+	return root
+}
+
+// TestSearchAllRanksAcrossProjects: one query covers every project, results
+// are ordered by score across repos rather than per repo, and the limit is
+// applied to the merged ranking.
+func TestSearchAllRanksAcrossProjects(t *testing.T) {
+	g, weak, _ := newTestProject(t, map[string]string{"weak.txt": "one rankedtoken mention\n"})
+	indexAndWait(t, g, weak)
+	strong := addTestProject(t, g, map[string]string{
+		"rankedtoken.txt": strings.Repeat("rankedtoken rankedtoken\n", 10),
+	})
+
+	res, err := g.SearchAll("rankedtoken", "5s", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Searched != 2 || len(res.Errors) != 0 {
+		t.Fatalf("searched %d shards with errors %v, want 2 and none", res.Searched, res.Errors)
+	}
+	if len(res.Files) != 2 {
+		t.Fatalf("got %d files, want one from each project: %+v", len(res.Files), res.Files)
+	}
+	if res.Files[0].Root != strong || res.Files[0].Path != "rankedtoken.txt" {
+		t.Errorf("best file = %s%s, want %srankedtoken.txt", res.Files[0].Root, res.Files[0].Path, strong)
+	}
+	if res.Files[1].Root != weak || res.Files[1].Path != "weak.txt" {
+		t.Errorf("second file = %s%s, want %sweak.txt", res.Files[1].Root, res.Files[1].Path, weak)
+	}
+	if res.Files[0].Score < res.Files[1].Score {
+		t.Errorf("scores %v, %v are not best first", res.Files[0].Score, res.Files[1].Score)
+	}
+	if res.Truncated {
+		t.Error("an unlimited search reported truncation")
+	}
+
+	limited, err := g.SearchAll("rankedtoken", "5s", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(limited.Files) != 1 || limited.Files[0].Root != strong || !limited.Truncated {
+		t.Errorf("limit 1: %+v, want only the best file, marked truncated", limited)
+	}
+}
+
+// TestSearchAllSkipsProjectsWithoutAShard: a project still being indexed has
+// nothing to search yet, which is not an error for the rest of the fleet.
+func TestSearchAllSkipsProjectsWithoutAShard(t *testing.T) {
+	g, root, _ := newTestProject(t, map[string]string{"a.txt": "present\n"})
+	indexAndWait(t, g, root)
+	g.mu.Lock()
+	g.projects["/not/indexed/yet/"] = IndexedProject{Root: "/not/indexed/yet/", Status: StatusIndexing}
+	g.mu.Unlock()
+
+	res, err := g.SearchAll("present", "5s", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Searched != 1 || len(res.Errors) != 0 || len(res.Files) != 1 {
+		t.Errorf("got %+v, want the one indexed project searched and no errors", res)
+	}
+}
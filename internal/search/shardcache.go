@@ -0,0 +1,161 @@
+package search
+
+import (
+	"os"
+	"sync"
+
+	"github.com/sourcegraph/zoekt"
+)
+
+// shardCache keeps each project's zoekt shard open between queries.
+//
+// Search used to open the index file, map it, parse its table of contents and
+// build a zoekt.Searcher for every single query, then tear it all down again.
+// For one repo that is a few milliseconds of overhead; for `pose --all`, which
+// asked the same question of every registered repo, it was the whole cost —
+// ~80 repos meant ~80 opens and ~80 TOC parses per keystroke-sized query. The
+// cache holds one open, memory-mapped searcher per project for as long as its
+// shard on disk is unchanged, so a query pays only for the search itself.
+//
+// FRESHNESS. A cached searcher is only reused while the file it was opened
+// from is still the one at the index path: acquire compares the path's
+// current FileInfo (inode, size, mtime) against the one recorded at open, so
+// a shard replaced by anything — a rebuild in this process, another pogod, a
+// hand-deleted .pogo dir — is reopened on the next query rather than served
+// stale. serializeProjectIndex and Evict also invalidate explicitly, which is
+// what releases the old mapping promptly instead of at the next query.
+//
+// LIFETIME. A searcher's memory is an mmap of its file; closing it while a
+// query is still reading would fault the process, not return an error. Every
+// acquire is therefore paired with a release, and an invalidated searcher is
+// only closed once the last query holding it lets go. Replacing the file on
+// disk is safe on its own: the mapping holds the old inode, not the path.
+type shardCache struct {
+	mu     sync.Mutex
+	shards map[string]*openShard
+}
+
+// openShard is one project's open searcher and what it was opened from.
+type openShard struct {
+	root     string
+	searcher zoekt.Searcher
+	file     os.FileInfo
+	// refs counts queries currently holding the searcher. Guarded by the
+	// cache's mu.
+	refs int
+	// dropped is set once the shard has left the cache; the last release
+	// closes it. Guarded by the cache's mu.
+	dropped bool
+}
+
+func newShardCache() *shardCache {
+	return &shardCache{shards: make(map[string]*openShard)}
+}
+
+// sameFile reports whether fi still describes the file s was opened from.
+func (s *openShard) sameFile(fi os.FileInfo) bool {
+	return os.SameFile(s.file, fi) && s.file.Size() == fi.Size() && s.file.ModTime().Equal(fi.ModTime())
+}
+
+// acquire returns root's searcher for the shard at indexPath, opening it if
+// the cache has none or the one it has is out of date. The caller must hand
+// the shard back with release.
+func (c *shardCache) acquire(root, indexPath string) (*openShard, error) {
+	fi, err := os.Stat(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if s, ok := c.shards[root]; ok && s.sameFile(fi) {
+		s.refs++
+		c.mu.Unlock()
+		return s, nil
+	}
+	c.mu.Unlock()
+
+	// Opened outside the lock: a large shard takes a while to map and parse,
+	// and queries against every other project must not wait on it.
+	fresh, err := openShardFile(root, indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.shards[root]; ok && s.sameFile(fresh.file) {
+		// A concurrent query opened the same file first; keep theirs.
+		fresh.searcher.Close()
+		s.refs++
+		return s, nil
+	}
+	if old, ok := c.shards[root]; ok {
+		c.dropLocked(old)
+	}
+	fresh.refs = 1
+	c.shards[root] = fresh
+	return fresh, nil
+}
+
+// openShardFile maps the shard at indexPath and builds its searcher. The
+// FileInfo is taken from the open descriptor, so it describes exactly the
+// file that was mapped even if the path is replaced in between.
+func openShardFile(root, indexPath string) (*openShard, error) {
+	f, err := os.Open(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	// NewIndexFile takes ownership of f and closes it; the mapping outlives
+	// the descriptor.
+	index, err := zoekt.NewIndexFile(f)
+	if err != nil {
+		return nil, err
+	}
+	searcher, err := zoekt.NewSearcher(index)
+	if err != nil {
+		index.Close()
+		return nil, err
+	}
+	return &openShard{root: root, searcher: searcher, file: fi}, nil
+}
+
+// release hands back a shard taken with acquire.
+func (c *shardCache) release(s *openShard) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s.refs--
+	if s.dropped && s.refs == 0 {
+		s.searcher.Close()
+	}
+}
+
+// invalidate drops root's searcher, if any. Queries already holding it finish
+// on the old shard; the next acquire opens the file afresh.
+func (c *shardCache) invalidate(root string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.shards[root]; ok {
+		c.dropLocked(s)
+	}
+}
+
+func (c *shardCache) dropLocked(s *openShard) {
+	if c.shards[s.root] == s {
+		delete(c.shards, s.root)
+	}
+	s.dropped = true
+	if s.refs == 0 {
+		s.searcher.Close()
+	}
+}
+
+// size reports how many searchers the cache holds open.
+func (c *shardCache) size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.shards)
+}
@@ -0,0 +1,111 @@
+package search
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/drellem2/pogo/pkg/plugin"
+)
+
+// indexAndWait indexes root synchronously and waits for its shard to land.
+func indexAndWait(t *testing.T, g *BasicSearch, root string) {
+	t.Helper()
+	req := plugin.IProcessProjectReq(plugin.ProcessProjectReq{PathVar: root})
+	g.Index(&req)
+	if !g.Quiesce(15 * time.Second) {
+		t.Fatalf("index of %s did not finish", root)
+	}
+}
+
+func cachedShard(g *BasicSearch, root string) *openShard {
+	g.shards.mu.Lock()
+	defer g.shards.mu.Unlock()
+	return g.shards.shards[root]
+}
+
+// TestSearchKeepsTheShardOpenUntilReIndex: repeated queries share one open
+// searcher, and a re-index that rebuilds the shard replaces it — the new
+// content is found, and the old searcher is closed rather than leaked.
+func TestSearchKeepsTheShardOpenUntilReIndex(t *testing.T) {
+	g, root, events := newTestProject(t, map[string]string{"a.txt": "alpha cachetoken\n"})
+	indexAndWait(t, g, root)
+	waitIndexed(t, events, root)
+
+	if _, err := g.Search(root, "cachetoken", "5s"); err != nil {
+		t.Fatal(err)
+	}
+	first := cachedShard(g, root)
+	if first == nil {
+		t.Fatal("the searcher was not kept after the query")
+	}
+	if _, err := g.Search(root, "alpha", "5s"); err != nil {
+		t.Fatal(err)
+	}
+	if cachedShard(g, root) != first {
+		t.Error("a second query reopened an unchanged shard")
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "b.txt"), []byte("beta freshtoken\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	g.ReIndex(root)
+	waitIndexed(t, events, root)
+	g.Quiesce(15 * time.Second)
+
+	res, err := g.Search(root, "freshtoken", "5s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Files) != 1 {
+		t.Fatalf("content added by the re-index is not searchable: %+v", res.Files)
+	}
+	if cachedShard(g, root) == first {
+		t.Error("the re-index did not replace the cached searcher")
+	}
+	if !first.dropped || first.refs != 0 {
+		t.Errorf("old searcher: dropped=%v refs=%d, want it dropped and released", first.dropped, first.refs)
+	}
+}
+
+// TestInvalidatedShardServesItsHolderUntilReleased: a query already holding a
+// shard must be able to finish on it after invalidation — closing the mapping
+// under it would fault the process — and the last release closes it.
+func TestInvalidatedShardServesItsHolderUntilReleased(t *testing.T) {
+	g, root, _ := newTestProject(t, map[string]string{"a.txt": "held heldtoken\n"})
+	indexAndWait(t, g, root)
+
+	indexPath := filepath.Join(root, pogoDir, searchDir, codeSearchIndexFileName)
+	shard, err := g.shards.acquire(root, indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.shards.invalidate(root)
+	if g.shards.size() != 0 {
+		t.Error("invalidate left the searcher in the cache")
+	}
+	if shard.dropped && shard.refs == 0 {
+		t.Fatal("the searcher was closed while a query still held it")
+	}
+	res, err := g.SearchAll("heldtoken", "5s", 0)
+	if err != nil || len(res.Files) != 1 {
+		t.Fatalf("search after invalidate: %+v, %v", res, err)
+	}
+	g.shards.release(shard)
+	if shard.refs != 0 {
+		t.Errorf("refs = %d after the last release", shard.refs)
+	}
+}
+
+func TestEvictReleasesTheOpenShard(t *testing.T) {
+	g, root, _ := newTestProject(t, map[string]string{"a.txt": "evicted\n"})
+	indexAndWait(t, g, root)
+	if _, err := g.Search(root, "evicted", "5s"); err != nil {
+		t.Fatal(err)
+	}
+	g.Evict(root)
+	if g.shards.size() != 0 {
+		t.Error("an evicted project's shard is still held open")
+	}
+}
@@ -0,0 +1,305 @@
+package search
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"regexp"
+	"regexp/syntax"
+
+	"github.com/sourcegraph/zoekt"
+	"github.com/sourcegraph/zoekt/ctags"
+	"github.com/sourcegraph/zoekt/query"
+)
+
+// Symbol extraction (`[search] symbols`, `pose --sym`).
+//
+// A plain content shard knows where a name occurs but not where it is
+// defined, so `pogo refs` had to guess: xref.classifyRef calls a line a
+// definition when it starts with `func `, `type `, `var ` or `const `. That
+// is Go's grammar, and every other language's definitions came back as
+// calls. When universal-ctags is on PATH the build now runs each file
+// through it and stores the tags as zoekt symbol sections, so `sym:` queries
+// answer from the shard and a definition is whatever ctags says it is.
+//
+// It is optional in both directions. Without ctags, or with `symbols =
+// false`, shards are built exactly as before and symbol searches fall back
+// to the prefix heuristic for those repos — see SymbolSearch.
+
+// symbolParser extracts the tags for one file. It is not safe for concurrent
+// use: each build pass gets its own.
+type symbolParser interface {
+	Parse(name string, content []byte) ([]*ctags.Entry, error)
+	Close()
+}
+
+// ctagsParser is a symbolParser backed by one universal-ctags process in
+// interactive mode, started on first use.
+type ctagsParser struct {
+	p ctags.CTagsParser
+}
+
+func (c *ctagsParser) Parse(name string, content []byte) ([]*ctags.Entry, error) {
+	return c.p.Parse(name, content, ctags.UniversalCTags)
+}
+
+func (c *ctagsParser) Close() { c.p.Close() }
+
+// findCtags resolves the universal-ctags binary to use: bin when given,
+// otherwise the first of `universal-ctags` and `ctags` on PATH. Exuberant
+// ctags, and universal-ctags built without +interactive, are refused — the
+// parser speaks ctags' interactive protocol and would hang or fail on every
+// file.
+func findCtags(bin string) (string, error) {
+	candidates := []string{bin}
+	if bin == "" {
+		candidates = []string{"universal-ctags", "ctags"}
+	}
+	var lastErr error
+	for _, c := range candidates {
+		path, err := exec.LookPath(c)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if _, err := ctags.NewParserBinMap(path, "", nil, true); err != nil {
+			lastErr = err
+			continue
+		}
+		return path, nil
+	}
+	return "", lastErr
+}
+
+// SetSymbols turns symbol extraction on or off for builds from now on. bin
+// names the universal-ctags binary; empty means look it up on PATH. Asking
+// for symbols when no usable ctags is found leaves them off, with one line
+// saying why. A change takes effect on each project's next index pass, which
+// rebuilds its shard even when no file changed.
+func (g *BasicSearch) SetSymbols(enabled bool, bin string) {
+	if !enabled {
+		g.setSymbolParser(nil)
+		g.logger.Info("Symbol indexing is off")
+		return
+	}
+	path, err := findCtags(bin)
+	if err != nil {
+		g.setSymbolParser(nil)
+		g.logger.Info("Symbol indexing is off: no usable universal-ctags", "error", err)
+		return
+	}
+	g.setSymbolParser(func() symbolParser {
+		return &ctagsParser{p: ctags.NewCTagsParser(ctags.ParserBinMap{ctags.UniversalCTags: path})}
+	})
+	g.logger.Info("Symbol indexing with universal-ctags", "ctags", path)
+}
+
+func (g *BasicSearch) setSymbolParser(fn func() symbolParser) {
+	g.symbolsMu.Lock()
+	g.newSymbolParser = fn
+	g.symbolsMu.Unlock()
+}
+
+// symbolParserForPass returns a parser for one build pass, or nil when
+// symbols are off.
+func (g *BasicSearch) symbolParserForPass() symbolParser {
+	g.symbolsMu.RLock()
+	fn := g.newSymbolParser
+	g.symbolsMu.RUnlock()
+	if fn == nil {
+		return nil
+	}
+	return fn()
+}
+
+// symbolsEnabled reports whether builds currently extract symbols.
+func (g *BasicSearch) symbolsEnabled() bool {
+	g.symbolsMu.RLock()
+	defer g.symbolsMu.RUnlock()
+	return g.newSymbolParser != nil
+}
+
+// attachSymbols runs doc through syms and stores the tags it finds as the
+// document's symbol sections.
+func attachSymbols(doc *zoekt.Document, syms symbolParser) error {
+	if len(doc.Content) == 0 || bytes.IndexByte(doc.Content, 0) >= 0 {
+		// Empty, or binary: the builder skips binary content, symbols and all.
+		return nil
+	}
+	entries, err := syms.Parse(doc.Name, doc.Content)
+	if err != nil {
+		return err
+	}
+	doc.Symbols, doc.SymbolsMetaData = tagsToSections(doc.Content, entries)
+	return nil
+}
+
+// tagsToSections converts ctags entries to the byte ranges zoekt indexes as
+// symbols, one per tag, at the first occurrence of the tag's name on its
+// line. It is best effort in the same way zoekt's own builder is: a tag whose
+// name is not on its line (ctags reports the first line of a multi-line
+// declaration) or that would overlap an earlier section is dropped.
+func tagsToSections(content []byte, entries []*ctags.Entry) ([]zoekt.DocumentSection, []*zoekt.Symbol) {
+	var lineStarts []uint32
+	lineStarts = append(lineStarts, 0)
+	for i, b := range content {
+		if b == '\n' {
+			lineStarts = append(lineStarts, uint32(i+1))
+		}
+	}
+
+	var secs []zoekt.DocumentSection
+	var meta []*zoekt.Symbol
+	for _, e := range entries {
+		if e.Line <= 0 || e.Line > len(lineStarts) || e.Name == "" {
+			continue
+		}
+		start := lineStarts[e.Line-1]
+		end := uint32(len(content))
+		if e.Line < len(lineStarts) {
+			end = lineStarts[e.Line]
+		}
+		off := bytes.Index(content[start:end], []byte(e.Name))
+		if off < 0 {
+			continue
+		}
+		s := start + uint32(off)
+		sec := zoekt.DocumentSection{Start: s, End: s + uint32(len(e.Name))}
+		if overlapsAny(secs, sec) {
+			continue
+		}
+		secs = append(secs, sec)
+		meta = append(meta, &zoekt.Symbol{Sym: e.Name, Kind: e.Kind, Parent: e.Parent, ParentKind: e.ParentKind})
+	}
+	return secs, meta
+}
+
+func overlapsAny(secs []zoekt.DocumentSection, sec zoekt.DocumentSection) bool {
+	for _, s := range secs {
+		if sec.Start < s.End && s.Start < sec.End {
+			return true
+		}
+	}
+	return false
+}
+
+// Values of PogoChunkMatch.Kind.
+const (
+	ChunkDefinition = "definition"
+	ChunkReference  = "reference"
+)
+
+// SymbolSearch finds name, as a whole word, in root's shard — or in every
+// project's when root is empty — and returns the files that define it ahead
+// of the files that only use it, at most limit files in all (0 means no
+// limit). It answers a "symbol" request, the path behind `pose --sym`.
+//
+// It is two queries over the same shards. The first is `sym:^name$`, run only
+// against projects whose shard was built with symbols: its matches are the
+// definitions, ranked among themselves. The second is `\bname\b` over every
+// project, with the lines already reported as definitions taken out; what is
+// left is labelled ChunkReference where the project has symbols. In a project
+// without them both kinds come back from the second query unlabelled, and the
+// caller is left to tell them apart as it did before (xref.classifyRef).
+func (g *BasicSearch) SymbolSearch(root, name, duration string, limit int) (*SearchAllResults, error) {
+	if name == "" {
+		return nil, errors.New("empty symbol name")
+	}
+	defRe, err := syntax.Parse("^"+regexp.QuoteMeta(name)+"$", syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	refRe, err := syntax.Parse(`\b`+regexp.QuoteMeta(name)+`\b`, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	defQ := &query.Symbol{Expr: &query.Regexp{Regexp: defRe, Content: true, CaseSensitive: true}}
+	refQ := &query.Regexp{Regexp: refRe, Content: true, CaseSensitive: true}
+
+	roots := g.projectRoots()
+	if root != "" {
+		roots = []string{root}
+	}
+	symbolized := make(map[string]bool)
+	var symRoots []string
+	g.mu.RLock()
+	for _, r := range roots {
+		if g.projects[r].Symbols {
+			symbolized[r] = true
+			symRoots = append(symRoots, r)
+		}
+	}
+	g.mu.RUnlock()
+
+	ctx, cancel := searchContext(duration)
+	defer cancel()
+	opts := &zoekt.SearchOptions{
+		ChunkMatches:       true,
+		MaxDocDisplayCount: limit,
+	}
+	defs := g.searchShards(ctx, symRoots, defQ, opts, "")
+	defer defs.release()
+	refs := g.searchShards(ctx, roots, refQ, opts, "")
+	defer refs.release()
+
+	out := &SearchAllResults{Files: []RankedFileMatch{}, Searched: refs.searched, Errors: refs.errors}
+	out.Truncated = defs.matched > len(defs.files) || refs.matched > len(refs.files)
+	for r, msg := range defs.errors {
+		if out.Errors == nil {
+			out.Errors = make(map[string]string)
+		}
+		out.Errors[r] = msg
+	}
+
+	defLines := make(map[string]map[uint32]bool)
+	for i := range defs.files {
+		f := &defs.files[i]
+		lines := make(map[uint32]bool)
+		for _, cm := range f.ChunkMatches {
+			for _, rg := range cm.Ranges {
+				lines[rg.Start.LineNumber] = true
+			}
+		}
+		defLines[f.Repository+"\x00"+f.FileName] = lines
+		out.Files = append(out.Files, toRankedFileMatch(f))
+	}
+	for i := range refs.files {
+		f := &refs.files[i]
+		lines := defLines[f.Repository+"\x00"+f.FileName]
+		kept := f.ChunkMatches[:0:0]
+		for _, cm := range f.ChunkMatches {
+			if !onlyOnLines(cm, lines) {
+				kept = append(kept, cm)
+			}
+		}
+		if len(kept) == 0 {
+			continue
+		}
+		f.ChunkMatches = kept
+		rf := toRankedFileMatch(f)
+		if symbolized[f.Repository] {
+			for j := range rf.Matches {
+				rf.Matches[j].Kind = ChunkReference
+			}
+		}
+		out.Files = append(out.Files, rf)
+	}
+	if limit > 0 && len(out.Files) > limit {
+		out.Files = out.Files[:limit]
+		out.Truncated = true
+	}
+	return out, nil
+}
+
+// onlyOnLines reports whether every match in cm falls on one of lines.
+func onlyOnLines(cm zoekt.ChunkMatch, lines map[uint32]bool) bool {
+	if len(lines) == 0 || len(cm.Ranges) == 0 {
+		return false
+	}
+	for _, rg := range cm.Ranges {
+		if !lines[rg.Start.LineNumber] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,164 @@
+package search
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/zoekt/ctags"
+)
+
+// fakeSymbols stands in for universal-ctags, which the test machines do not
+// have: every line of the form `def name(` or `class name:` is a tag.
+type fakeSymbols struct{ err error }
+
+func (f *fakeSymbols) Parse(name string, content []byte) ([]*ctags.Entry, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	var entries []*ctags.Entry
+	for i, line := range bytes.Split(content, []byte("\n")) {
+		for prefix, kind := range map[string]string{"def ": "function", "class ": "class"} {
+			rest, ok := bytes.CutPrefix(line, []byte(prefix))
+			if !ok {
+				continue
+			}
+			if end := bytes.IndexAny(rest, "(:"); end > 0 {
+				entries = append(entries, &ctags.Entry{Name: string(rest[:end]), Line: i + 1, Kind: kind})
+			}
+		}
+	}
+	return entries, nil
+}
+
+func (f *fakeSymbols) Close() {}
+
+func useFakeSymbols(g *BasicSearch, err error) {
+	g.setSymbolParser(func() symbolParser { return &fakeSymbols{err: err} })
+}
+
+var pythonProject = map[string]string{
+	"lib.py": "class Widget:\n    pass\n\ndef frobnicate(x):\n    return x\n\nvalue = frobnicate(1)\n",
+	"use.py": "from lib import frobnicate\n\nprint(frobnicate(2))\n",
+}
+
+// TestSymbolSearchRanksDefinitionsAboveReferences: with symbols on, the file
+// that defines the name comes first with its definition labelled from the
+// tag, and every other use is a reference — including the call in the
+// defining file, but not the definition line a second time.
+func TestSymbolSearchRanksDefinitionsAboveReferences(t *testing.T) {
+	g, root, _ := newTestProject(t, pythonProject)
+	useFakeSymbols(g, nil)
+	indexAndWait(t, g, root)
+
+	res, err := g.SymbolSearch(root, "frobnicate", "5s", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Files) < 3 {
+		t.Fatalf("got %d files, want the definition and two referencing files: %+v", len(res.Files), res.Files)
+	}
+	def := res.Files[0]
+	if def.Path != "lib.py" || len(def.Matches) != 1 || def.Matches[0].Line != 4 {
+		t.Fatalf("first file = %+v, want the definition at lib.py:4", def.PogoFileMatch)
+	}
+	if m := def.Matches[0]; m.Kind != ChunkDefinition || m.Symbol == nil || m.Symbol.Kind != "function" {
+		t.Errorf("definition match = %+v, want kind definition with the tag's kind", m)
+	}
+	refs := map[string][]uint32{}
+	for _, f := range res.Files[1:] {
+		for _, m := range f.Matches {
+			if m.Kind != ChunkReference {
+				t.Errorf("%s:%d has kind %q, want reference", f.Path, m.Line, m.Kind)
+			}
+			refs[f.Path] = append(refs[f.Path], m.Line)
+		}
+	}
+	if got := refs["lib.py"]; len(got) != 1 || got[0] != 7 {
+		t.Errorf("references in lib.py at %v, want only line 7", got)
+	}
+	if len(refs["use.py"]) == 0 {
+		t.Error("the uses in use.py were not reported")
+	}
+
+	limited, err := g.SymbolSearch("", "frobnicate", "5s", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(limited.Files) != 1 || limited.Files[0].Path != "lib.py" || !limited.Truncated {
+		t.Errorf("limit 1 across all projects: %+v, want only the definition, marked truncated", limited)
+	}
+}
+
+// TestEnablingSymbolsRebuildsTheShard: without symbols nothing is labelled
+// and the definition is just another match; turning them on rebuilds the
+// shard on the next pass even though no file changed.
+func TestEnablingSymbolsRebuildsTheShard(t *testing.T) {
+	g, root, events := newTestProject(t, pythonProject)
+	indexAndWait(t, g, root)
+	waitIndexed(t, events, root)
+
+	res, err := g.SymbolSearch(root, "frobnicate", "5s", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range res.Files {
+		for _, m := range f.Matches {
+			if m.Kind != "" {
+				t.Errorf("%s:%d labelled %q in a shard without symbols", f.Path, m.Line, m.Kind)
+			}
+		}
+	}
+
+	useFakeSymbols(g, nil)
+	g.ReIndex(root)
+	waitIndexed(t, events, root)
+	g.Quiesce(15 * time.Second)
+
+	res, err = g.SymbolSearch(root, "frobnicate", "5s", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Files) == 0 || res.Files[0].Matches[0].Kind != ChunkDefinition {
+		t.Fatalf("after enabling symbols: %+v, want the definition first", res.Files)
+	}
+	if sym, err := g.Search(root, "sym:Widget", "5s"); err != nil || len(sym.Files) != 1 {
+		t.Errorf("sym:Widget = %+v, %v; want lib.py", sym, err)
+	}
+}
+
+// TestSymbolParserFailureStillBuildsTheShard: a ctags failure costs the
+// symbols, never the content index.
+func TestSymbolParserFailureStillBuildsTheShard(t *testing.T) {
+	g, root, _ := newTestProject(t, pythonProject)
+	useFakeSymbols(g, errors.New("ctags exited"))
+	indexAndWait(t, g, root)
+
+	res, err := g.Search(root, "frobnicate", "5s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Files) != 2 {
+		t.Errorf("content search found %d files, want 2", len(res.Files))
+	}
+}
+
+func TestTagsToSectionsDropsWhatItCannotPlace(t *testing.T) {
+	content := []byte("def a(): pass\nfoo = 1\ndef ab(): pass\n")
+	secs, meta := tagsToSections(content, []*ctags.Entry{
+		{Name: "a", Line: 1},
+		{Name: "missing", Line: 2}, // not on its line
+		{Name: "ab", Line: 3},
+		{Name: "b", Line: 3}, // inside "ab"
+		{Name: "x", Line: 9}, // past the end
+	})
+	var names []string
+	for i, s := range secs {
+		names = append(names, meta[i].Sym+"@"+string(content[s.Start:s.End]))
+	}
+	if got := strings.Join(names, ","); got != "a@a,ab@ab" {
+		t.Errorf("sections = %s, want a@a,ab@ab", got)
+	}
+}
@@ -77,6 +77,11 @@ Type=simple
 ExecStart={{.PogodPath}}
 Restart=on-failure
 RestartSec=5
+{{- if .PTYHolder}}
+# Stop pogod alone: agents under [agents] pty_holder live in holder processes
+# in this unit's cgroup, and must survive a restart of the daemon.
+KillMode=process
+{{- end}}
 
 [Install]
 WantedBy=default.target
@@ -94,6 +99,11 @@ type launchdData struct {
 
 type systemdData struct {
 	PogodPath string
+	// PTYHolder is [agents] pty_holder when the unit is written. Only then
+	// does the unit stop pogod alone: without holders, pogod's agents, gate
+	// shells and git children are its own to clean up, and systemd's default
+	// control-group kill is what makes sure nothing outlives the service.
+	PTYHolder bool
 }
 
 func findPogod() (string, error) {
@@ -661,7 +671,7 @@ func installSystemd() error {
 		return fmt.Errorf("failed to create systemd user directory: %w", err)
 	}
 
-	data := systemdData{PogodPath: pogodPath}
+	data := systemdData{PogodPath: pogodPath, PTYHolder: config.Load().Agents.PTYHolder}
 
 	tmpl, err := template.New("unit").Parse(systemdUnitTemplate)
 	if err != nil {
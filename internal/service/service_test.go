@@ -113,9 +113,24 @@ func TestSystemdUnitTemplate(t *testing.T) {
 	if !strings.Contains(result, "Restart=on-failure") {
 		t.Error("unit missing Restart")
 	}
+	if strings.Contains(result, "KillMode") {
+		t.Error("unit without pty_holder sets KillMode; stopping pogod would orphan its agents")
+	}
 	if !strings.Contains(result, "WantedBy=default.target") {
 		t.Error("unit missing WantedBy")
 	}
+	if !strings.Contains(result, "RestartSec=5\n\n[Install]") {
+		t.Errorf("unit without pty_holder is not laid out as before:\n%s", result)
+	}
+
+	buf.Reset()
+	data.PTYHolder = true
+	if err := tmpl.Execute(&buf, data); err != nil {
+		t.Fatalf("failed to execute template: %v", err)
+	}
+	if !strings.Contains(buf.String(), "\nKillMode=process\n") {
+		t.Error("unit with pty_holder missing KillMode=process; stopping pogod would kill its PTY holders")
+	}
 }
 
 func TestStatusNotInstalled(t *testing.T) {
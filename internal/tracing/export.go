@@ -0,0 +1,187 @@
+package tracing
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/drellem2/pogo/internal/metrics"
+)
+
+// Exporter sends finished spans somewhere. Export is called from the one
+// background goroutine, never concurrently, with a batch it may keep.
+type Exporter interface {
+	Export(spans []Span) error
+	// Name says where spans go, for the log line when they cannot.
+	Name() string
+}
+
+const (
+	// queueLen bounds the spans waiting for export. A dispatch records a
+	// handful and a merge a few per gate, so this is hours of a busy fleet
+	// with the exporter stuck — past which spans are dropped, not waited for.
+	queueLen = 4096
+	// maxBatch and batchInterval are when a batch goes: whichever first.
+	maxBatch      = 256
+	batchInterval = 2 * time.Second
+	// errorLogEvery throttles an exporter's failure log to one line a minute,
+	// so a collector that is down does not fill pogod's log.
+	errorLogEvery = time.Minute
+)
+
+var (
+	spansTotal = metrics.NewCounter("pogo_tracing_spans_total",
+		"Work-item spans recorded, by name.", "name")
+	spansDroppedTotal = metrics.NewCounter("pogo_tracing_spans_dropped_total",
+		"Work-item spans dropped because the export queue was full.")
+	exportErrorsTotal = metrics.NewCounter("pogo_tracing_export_errors_total",
+		"Span batches an exporter failed to send, by exporter.", "exporter")
+)
+
+var (
+	pipeMu sync.Mutex
+	pipe   *pipeline
+)
+
+type pipeline struct {
+	queue     chan Span
+	exporters []Exporter
+	flush     chan chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+	lastLog   map[string]time.Time
+}
+
+// Configure starts exporting recorded spans to exporters, replacing (and
+// flushing) whatever was configured before. With no exporters, recording is
+// off. The returned func flushes and stops; pogod calls it on shutdown.
+func Configure(exporters ...Exporter) (stop func()) {
+	var p *pipeline
+	if len(exporters) > 0 {
+		p = &pipeline{
+			queue:     make(chan Span, queueLen),
+			exporters: exporters,
+			flush:     make(chan chan struct{}),
+			stop:      make(chan struct{}),
+			done:      make(chan struct{}),
+			lastLog:   map[string]time.Time{},
+		}
+		go p.run()
+	}
+	pipeMu.Lock()
+	prev := pipe
+	pipe = p
+	pipeMu.Unlock()
+	if prev != nil {
+		prev.shutdown()
+	}
+	return func() {
+		pipeMu.Lock()
+		if pipe == p {
+			pipe = nil
+		}
+		pipeMu.Unlock()
+		if p != nil {
+			p.shutdown()
+		}
+	}
+}
+
+// Flush waits, up to timeout, until every span recorded so far has been
+// handed to the exporters.
+func Flush(timeout time.Duration) bool {
+	pipeMu.Lock()
+	p := pipe
+	pipeMu.Unlock()
+	if p == nil {
+		return true
+	}
+	ack := make(chan struct{})
+	select {
+	case p.flush <- ack:
+	case <-p.done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+	select {
+	case <-ack:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func enqueue(s Span) {
+	pipeMu.Lock()
+	p := pipe
+	pipeMu.Unlock()
+	if p == nil {
+		return
+	}
+	spansTotal.Inc(s.Name)
+	select {
+	case p.queue <- s:
+	default:
+		spansDroppedTotal.Inc()
+	}
+}
+
+func (p *pipeline) shutdown() {
+	close(p.stop)
+	<-p.done
+}
+
+func (p *pipeline) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(batchInterval)
+	defer ticker.Stop()
+	var batch []Span
+	send := func() {
+		if len(batch) > 0 {
+			p.export(batch)
+			batch = nil
+		}
+	}
+	drain := func() {
+		for {
+			select {
+			case s := <-p.queue:
+				batch = append(batch, s)
+			default:
+				return
+			}
+		}
+	}
+	for {
+		select {
+		case s := <-p.queue:
+			batch = append(batch, s)
+			if len(batch) >= maxBatch {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case ack := <-p.flush:
+			drain()
+			send()
+			close(ack)
+		case <-p.stop:
+			drain()
+			send()
+			return
+		}
+	}
+}
+
+func (p *pipeline) export(batch []Span) {
+	for _, ex := range p.exporters {
+		if err := ex.Export(batch); err != nil {
+			exportErrorsTotal.Inc(ex.Name())
+			if now := time.Now(); now.Sub(p.lastLog[ex.Name()]) >= errorLogEvery {
+				p.lastLog[ex.Name()] = now
+				log.Printf("tracing: %d span(s) not exported to %s: %v", len(batch), ex.Name(), err)
+			}
+		}
+	}
+}
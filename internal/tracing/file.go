@@ -0,0 +1,90 @@
+package tracing
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// maxFileBytes is the size past which the span file is rotated to
+// <path>.1, replacing the generation before it. Two generations of a busy
+// fleet's spans is weeks of work items; the event log is the long record.
+const maxFileBytes = 64 << 20
+
+// FileExporter appends spans to a JSONL file, one span per line. It is the
+// exporter pogod always runs when tracing is on, because it is what
+// `pogo item trace` reads.
+type FileExporter struct {
+	Path string
+}
+
+func (f *FileExporter) Name() string { return f.Path }
+
+// Export appends batch to the file, rotating it first when it has grown past
+// maxFileBytes.
+func (f *FileExporter) Export(batch []Span) error {
+	if fi, err := os.Stat(f.Path); err == nil && fi.Size() > maxFileBytes {
+		if err := os.Rename(f.Path, f.Path+".1"); err != nil {
+			return err
+		}
+	}
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	for _, s := range batch {
+		if err := enc.Encode(s); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ReadTrace returns every span of workItemID's trace in the span file at path
+// and its rotated generation, ordered by start time. A missing file is no
+// spans, not an error: tracing may simply not have seen the item.
+func ReadTrace(path, workItemID string) ([]Span, error) {
+	want := TraceIDFor(workItemID)
+	var spans []Span
+	for _, p := range []string{path + ".1", path} {
+		f, err := os.Open(p)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sc := bufio.NewScanner(f)
+		sc.Buffer(make([]byte, 0, 64<<10), 4<<20)
+		line := 0
+		for sc.Scan() {
+			line++
+			var s Span
+			if err := json.Unmarshal(sc.Bytes(), &s); err != nil {
+				// A torn last line from a crash mid-write costs that
+				// span, not the trace.
+				continue
+			}
+			if s.TraceID == want {
+				spans = append(spans, s)
+			}
+		}
+		err = sc.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", p, line, err)
+		}
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].Start.Before(spans[j].Start) })
+	return spans, nil
+}
@@ -0,0 +1,172 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OTLPExporter posts spans to an OpenTelemetry collector over OTLP/HTTP, in
+// the protocol's JSON encoding, so no protobuf or OpenTelemetry library is
+// needed to speak it.
+type OTLPExporter struct {
+	// Endpoint is the collector's base URL ("http://localhost:4318") or its
+	// full traces URL; /v1/traces is appended when missing.
+	Endpoint string
+	// Headers are sent with every request — an API key, typically.
+	Headers map[string]string
+	// Client defaults to one with a 10s timeout.
+	Client *http.Client
+}
+
+func (o *OTLPExporter) Name() string { return o.url() }
+
+func (o *OTLPExporter) url() string {
+	u := strings.TrimRight(o.Endpoint, "/")
+	if !strings.HasSuffix(u, "/v1/traces") {
+		u += "/v1/traces"
+	}
+	return u
+}
+
+var defaultOTLPClient = &http.Client{Timeout: 10 * time.Second}
+
+// Export posts one batch as a single ExportTraceServiceRequest.
+func (o *OTLPExporter) Export(batch []Span) error {
+	body, err := json.Marshal(otlpRequest(batch))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", o.url(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range o.Headers {
+		req.Header.Set(k, v)
+	}
+	client := o.Client
+	if client == nil {
+		client = defaultOTLPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("collector answered %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// The OTLP JSON shapes, trimmed to what pogod sends. Ids are hex and 64-bit
+// integers are strings, as the protocol's JSON mapping requires.
+type (
+	otlpExport struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+)
+
+const (
+	otlpKindInternal = 1
+	otlpStatusOK     = 1
+	otlpStatusError  = 2
+)
+
+func otlpRequest(batch []Span) otlpExport {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, s := range batch {
+		out := otlpSpan{
+			TraceID:           s.TraceID.String(),
+			SpanID:            s.SpanID.String(),
+			Name:              s.Name,
+			Kind:              otlpKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Status:            otlpStatus{Code: otlpStatusOK},
+			Attributes:        []otlpKeyValue{otlpAttr("pogo.work_item_id", s.WorkItemID)},
+		}
+		if !s.ParentID.IsZero() {
+			out.ParentSpanID = s.ParentID.String()
+		}
+		if s.Status == StatusError {
+			out.Status = otlpStatus{Code: otlpStatusError, Message: s.Message}
+		}
+		for _, k := range s.AttrKeys() {
+			out.Attributes = append(out.Attributes, otlpAttr("pogo."+k, s.Attributes[k]))
+		}
+		spans = append(spans, out)
+	}
+	return otlpExport{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpKeyValue{otlpAttr("service.name", "pogod")}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/drellem2/pogo/internal/tracing"},
+			Spans: spans,
+		}},
+	}}}
+}
+
+// otlpAttr encodes one attribute as an OTLP AnyValue. Numbers that arrive as
+// float64 (from a decoded span) but are whole are sent as ints.
+func otlpAttr(key string, v any) otlpKeyValue {
+	var val map[string]any
+	switch x := v.(type) {
+	case string:
+		val = map[string]any{"stringValue": x}
+	case bool:
+		val = map[string]any{"boolValue": x}
+	case int:
+		val = map[string]any{"intValue": strconv.Itoa(x)}
+	case int64:
+		val = map[string]any{"intValue": strconv.FormatInt(x, 10)}
+	case float64:
+		if x == float64(int64(x)) {
+			val = map[string]any{"intValue": strconv.FormatInt(int64(x), 10)}
+		} else {
+			val = map[string]any{"doubleValue": x}
+		}
+	default:
+		val = map[string]any{"stringValue": fmt.Sprint(x)}
+	}
+	return otlpKeyValue{Key: key, Value: val}
+}
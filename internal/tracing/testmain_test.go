@@ -0,0 +1,28 @@
+package tracing
+
+import (
+	"os"
+	"testing"
+
+	"github.com/drellem2/pogo/internal/testsandbox"
+)
+
+// sandbox is the package's private, CHECKED envelope (internal/testsandbox).
+// The span file pogod writes is $POGO_HOME/traces.jsonl; the tests write
+// theirs under t.TempDir(), and the envelope keeps one that forgets from
+// appending to the real trace history.
+var sandbox *testsandbox.Sandbox
+
+func TestMain(m *testing.M) {
+	sb, down := testsandbox.Main("tracing")
+	sandbox = sb
+
+	code := m.Run()
+
+	down()
+	os.Exit(code)
+}
+
+func TestSandboxIsVerified(t *testing.T) {
+	testsandbox.Verify(t, sandbox)
+}
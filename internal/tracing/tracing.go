@@ -0,0 +1,218 @@
+// Package tracing records a work item's path through pogod as spans, in the
+// OpenTelemetry model, so "why did mg-xxxx take three hours" is answered by
+// one waterfall instead of an afternoon of grepping work_item_id (user-023).
+//
+// # The trace is the work item
+//
+// Every span for a work item carries the same trace id, and that id is not
+// generated: it is derived from the work item id (TraceIDFor). That is what
+// lets the dispatch handler, the polecat's exit, and the refinery — three
+// parts of pogod that share no state and may not even share a process
+// lifetime — put their spans in one trace without passing anything between
+// them. A re-dispatch of the same item lands in the same trace, which is the
+// view someone asking about the item wants.
+//
+// Within a trace, spans are parented where the parent is at hand: a dispatch's
+// phases under its dispatch span, a merge's attempts and gates under its merge
+// span. Where it is not — the refinery has no idea which dispatch produced
+// the branch it is merging — the span is a root of its own, and the trace has
+// more than one. The renderer and OTLP backends both cope with that; a
+// synthetic parent nobody ever ends would be worse.
+//
+// # Recording and export
+//
+// Record is cheap and never blocks the caller: spans go on a bounded queue
+// and a background goroutine hands them, in batches, to the exporters
+// Configure installed — a JSONL file under $POGO_HOME (what `pogo item trace`
+// reads) and, when configured, an OTLP/HTTP collector. With nothing
+// configured, Record does nothing. A full queue drops the span and counts
+// the drop, because tracing must never be the reason a dispatch is slow.
+package tracing
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// TraceID identifies a trace: 16 bytes, rendered as 32 hex digits.
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace: 8 bytes, rendered as 16 hex digits.
+type SpanID [8]byte
+
+// TraceIDFor is the trace id of a work item's trace. It is a hash of the id,
+// so anything that knows the work item can find or extend its trace.
+func TraceIDFor(workItemID string) TraceID {
+	sum := sha256.Sum256([]byte("pogo/work-item/" + workItemID))
+	var t TraceID
+	copy(t[:], sum[:])
+	return t
+}
+
+// NewSpanID returns a random span id.
+func NewSpanID() SpanID {
+	var s SpanID
+	rand.Read(s[:])
+	return s
+}
+
+// SpanIDFor derives a span id from its trace and the parts that make the span
+// unique within it — the span name and, say, a merge request id. Children
+// recorded before their parent (every child ends first) can name the parent
+// this way without the parent's id having been stored anywhere.
+func SpanIDFor(trace TraceID, parts ...string) SpanID {
+	h := sha256.New()
+	h.Write(trace[:])
+	for _, p := range parts {
+		h.Write([]byte{0})
+		h.Write([]byte(p))
+	}
+	var s SpanID
+	copy(s[:], h.Sum(nil))
+	return s
+}
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+// IsZero reports whether t is unset.
+func (t TraceID) IsZero() bool { return t == TraceID{} }
+
+// IsZero reports whether s is unset.
+func (s SpanID) IsZero() bool { return s == SpanID{} }
+
+func (t TraceID) MarshalText() ([]byte, error) { return []byte(t.String()), nil }
+func (s SpanID) MarshalText() ([]byte, error)  { return []byte(s.String()), nil }
+
+func (t *TraceID) UnmarshalText(b []byte) error { return decodeHex(t[:], b) }
+func (s *SpanID) UnmarshalText(b []byte) error  { return decodeHex(s[:], b) }
+
+func decodeHex(dst, b []byte) error {
+	if hex.DecodedLen(len(b)) != len(dst) {
+		return fmt.Errorf("tracing: id %q is not %d bytes of hex", b, len(dst))
+	}
+	_, err := hex.Decode(dst, b)
+	return err
+}
+
+// Status is how a span ended.
+type Status string
+
+const (
+	StatusOK    Status = "ok"
+	StatusError Status = "error"
+)
+
+// Span is one timed phase of a work item's life.
+type Span struct {
+	TraceID    TraceID   `json:"trace_id"`
+	SpanID     SpanID    `json:"span_id"`
+	ParentID   SpanID    `json:"parent_span_id,omitzero"`
+	WorkItemID string    `json:"work_item_id"`
+	Name       string    `json:"name"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Status     Status    `json:"status"`
+	// Message says why a span ended in error.
+	Message    string         `json:"status_message,omitempty"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// Duration is how long the span ran.
+func (s Span) Duration() time.Duration { return s.End.Sub(s.Start) }
+
+// AttrKeys returns the span's attribute keys in order, for stable output.
+func (s Span) AttrKeys() []string {
+	keys := make([]string, 0, len(s.Attributes))
+	for k := range s.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Active is a span that has started and not yet ended.
+type Active struct {
+	span  Span
+	ended bool
+}
+
+// Start begins a span for workItemID under parent (zero for a root). It
+// returns nil when workItemID is empty — a dispatch without --id has no item
+// to trace — and every Active method is safe on nil, so a caller never has to
+// check.
+func Start(workItemID, name string, parent SpanID) *Active {
+	if workItemID == "" {
+		return nil
+	}
+	return &Active{span: Span{
+		TraceID:    TraceIDFor(workItemID),
+		SpanID:     NewSpanID(),
+		ParentID:   parent,
+		WorkItemID: workItemID,
+		Name:       name,
+		Start:      time.Now(),
+	}}
+}
+
+// ID is the span's id, for parenting children to it. Zero on nil.
+func (a *Active) ID() SpanID {
+	if a == nil {
+		return SpanID{}
+	}
+	return a.span.SpanID
+}
+
+// SetAttr sets one attribute.
+func (a *Active) SetAttr(key string, value any) {
+	if a == nil {
+		return
+	}
+	if a.span.Attributes == nil {
+		a.span.Attributes = map[string]any{}
+	}
+	a.span.Attributes[key] = value
+}
+
+// End ends the span — in error when err is non-nil — and records it. Only the
+// first End records; later ones do nothing, so a deferred End can back up an
+// explicit one.
+func (a *Active) End(err error) {
+	if a == nil || a.ended {
+		return
+	}
+	a.ended = true
+	a.span.End = time.Now()
+	a.span.Status = StatusOK
+	if err != nil {
+		a.span.Status = StatusError
+		a.span.Message = err.Error()
+	}
+	Record(a.span)
+}
+
+// Record records a finished span. It fills in what it can: the trace id from
+// the work item, a span id, an ok status. A span without a work item is
+// dropped.
+func Record(s Span) {
+	if s.WorkItemID == "" {
+		return
+	}
+	if s.TraceID.IsZero() {
+		s.TraceID = TraceIDFor(s.WorkItemID)
+	}
+	if s.SpanID.IsZero() {
+		s.SpanID = NewSpanID()
+	}
+	if s.Status == "" {
+		s.Status = StatusOK
+	}
+	if s.End.Before(s.Start) {
+		s.End = s.Start
+	}
+	enqueue(s)
+}
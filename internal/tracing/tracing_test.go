@@ -0,0 +1,245 @@
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recorder is an Exporter that keeps what it was given.
+type recorder struct{ spans []Span }
+
+func (r *recorder) Name() string { return "recorder" }
+func (r *recorder) Export(batch []Span) error {
+	r.spans = append(r.spans, batch...)
+	return nil
+}
+
+// useExporters installs exporters for one test and removes them after it.
+func useExporters(t *testing.T, exporters ...Exporter) {
+	t.Helper()
+	stop := Configure(exporters...)
+	t.Cleanup(stop)
+}
+
+func flush(t *testing.T) {
+	t.Helper()
+	if !Flush(5 * time.Second) {
+		t.Fatal("Flush timed out")
+	}
+}
+
+// TestTraceIDIsTheWorkItem: the id is what joins spans recorded by parts of
+// pogod that share nothing, so it has to be a pure function of the item.
+func TestTraceIDIsTheWorkItem(t *testing.T) {
+	if TraceIDFor("mg-1") != TraceIDFor("mg-1") {
+		t.Error("the same work item gave two trace ids")
+	}
+	if TraceIDFor("mg-1") == TraceIDFor("mg-2") {
+		t.Error("two work items share a trace id")
+	}
+	tr := TraceIDFor("mg-1")
+	if SpanIDFor(tr, "refinery.merge", "mr-1") != SpanIDFor(tr, "refinery.merge", "mr-1") {
+		t.Error("SpanIDFor is not deterministic")
+	}
+	// The separator keeps ("ab", "c") and ("a", "bc") apart.
+	if SpanIDFor(tr, "ab", "c") == SpanIDFor(tr, "a", "bc") {
+		t.Error("SpanIDFor runs its parts together")
+	}
+}
+
+func TestIDsRoundTripAsHex(t *testing.T) {
+	s := Span{TraceID: TraceIDFor("mg-1"), SpanID: NewSpanID(), WorkItemID: "mg-1", Name: "x"}
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"trace_id":"`+s.TraceID.String()+`"`) {
+		t.Errorf("trace id not hex in %s", b)
+	}
+	if strings.Contains(string(b), "parent_span_id") {
+		t.Errorf("a root span wrote a parent: %s", b)
+	}
+	var back Span
+	if err := json.Unmarshal(b, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.TraceID != s.TraceID || back.SpanID != s.SpanID {
+		t.Errorf("ids did not round-trip: %+v", back)
+	}
+	if err := json.Unmarshal([]byte(`{"trace_id":"abc"}`), &back); err == nil {
+		t.Error("a short trace id decoded")
+	}
+}
+
+// TestActiveIsInertWithoutAWorkItem: callers start spans unconditionally, so
+// an untraced dispatch must cost nothing and record nothing.
+func TestActiveIsInertWithoutAWorkItem(t *testing.T) {
+	rec := &recorder{}
+	useExporters(t, rec)
+	a := Start("", "dispatch", SpanID{})
+	if a != nil {
+		t.Fatal("Start without a work item returned a span")
+	}
+	a.SetAttr("k", "v")
+	a.End(errors.New("boom"))
+	if !a.ID().IsZero() {
+		t.Error("nil span has an id")
+	}
+	flush(t)
+	if len(rec.spans) != 0 {
+		t.Errorf("recorded %d span(s) for no work item", len(rec.spans))
+	}
+}
+
+func TestActiveRecordsOnceWithParentAndStatus(t *testing.T) {
+	rec := &recorder{}
+	useExporters(t, rec)
+	root := Start("mg-1", "dispatch", SpanID{})
+	child := Start("mg-1", "dispatch.gates", root.ID())
+	child.SetAttr("agent", "cat")
+	child.End(errors.New("refused"))
+	child.End(nil) // a deferred backstop must not record twice
+	root.End(nil)
+	flush(t)
+
+	if len(rec.spans) != 2 {
+		t.Fatalf("recorded %d spans, want 2: %+v", len(rec.spans), rec.spans)
+	}
+	c, r := rec.spans[0], rec.spans[1]
+	if c.ParentID != r.SpanID || !r.ParentID.IsZero() {
+		t.Errorf("child parent = %s, root = %s (root parent %s)", c.ParentID, r.SpanID, r.ParentID)
+	}
+	if c.TraceID != TraceIDFor("mg-1") || r.TraceID != c.TraceID {
+		t.Error("spans are not in the work item's trace")
+	}
+	if c.Status != StatusError || c.Message != "refused" || c.Attributes["agent"] != "cat" {
+		t.Errorf("child = %+v", c)
+	}
+	if r.Status != StatusOK {
+		t.Errorf("root status = %q", r.Status)
+	}
+}
+
+// TestRecordWithoutExportersDoesNothing: tracing off is the zero state.
+func TestRecordWithoutExportersDoesNothing(t *testing.T) {
+	Configure()
+	Record(Span{WorkItemID: "mg-1", Name: "x"})
+	if !Flush(time.Second) {
+		t.Error("Flush with tracing off did not return at once")
+	}
+}
+
+// TestFileRoundTrip: what pogod writes is what `pogo item trace` reads, for
+// the item asked about only, in start order, across a rotation and past a
+// torn line.
+func TestFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	f := &FileExporter{Path: path}
+	t0 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	older := Span{WorkItemID: "mg-1", TraceID: TraceIDFor("mg-1"), SpanID: NewSpanID(), Name: "dispatch", Start: t0, End: t0.Add(time.Second)}
+	if err := f.Export([]Span{older}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	newer := Span{WorkItemID: "mg-1", TraceID: TraceIDFor("mg-1"), SpanID: NewSpanID(), Name: "refinery.merge", Start: t0.Add(time.Hour), End: t0.Add(2 * time.Hour)}
+	other := Span{WorkItemID: "mg-2", TraceID: TraceIDFor("mg-2"), SpanID: NewSpanID(), Name: "dispatch", Start: t0, End: t0}
+	if err := f.Export([]Span{newer, other}); err != nil {
+		t.Fatal(err)
+	}
+	fh, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fh.WriteString(`{"trace_id":"` + TraceIDFor("mg-1").String() + `","na`)
+	fh.Close()
+
+	got, err := ReadTrace(path, "mg-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Name != "dispatch" || got[1].Name != "refinery.merge" {
+		t.Fatalf("ReadTrace = %+v, want dispatch then refinery.merge", got)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("span file mode = %v (%v), want 0600", fi.Mode().Perm(), err)
+	}
+
+	none, err := ReadTrace(filepath.Join(t.TempDir(), "missing.jsonl"), "mg-1")
+	if err != nil || len(none) != 0 {
+		t.Errorf("missing file = %v, %v; want no spans and no error", none, err)
+	}
+}
+
+// TestOTLPExporterPostsTheJSONEncoding pins the wire shape a collector
+// decodes: hex ids, string nanoseconds, typed attribute values, the error
+// status code.
+func TestOTLPExporterPostsTheJSONEncoding(t *testing.T) {
+	var path, ctype, key string
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, ctype, key = r.URL.Path, r.Header.Get("Content-Type"), r.Header.Get("x-api-key")
+		b, _ := io.ReadAll(r.Body)
+		json.Unmarshal(b, &body)
+	}))
+	defer srv.Close()
+
+	t0 := time.Unix(1700000000, 5)
+	parent := NewSpanID()
+	s := Span{
+		TraceID: TraceIDFor("mg-1"), SpanID: NewSpanID(), ParentID: parent, WorkItemID: "mg-1",
+		Name: "refinery.gate", Start: t0, End: t0.Add(time.Second),
+		Status: StatusError, Message: "exit 1",
+		Attributes: map[string]any{"gate": "go test ./...", "attempt": 2, "ratio": 0.5, "retried": true},
+	}
+	ex := &OTLPExporter{Endpoint: srv.URL + "/", Headers: map[string]string{"x-api-key": "k"}}
+	if err := ex.Export([]Span{s}); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/v1/traces" || ctype != "application/json" || key != "k" {
+		t.Errorf("path=%q content-type=%q key=%q", path, ctype, key)
+	}
+	rs := body["resourceSpans"].([]any)[0].(map[string]any)
+	span := rs["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)[0].(map[string]any)
+	for k, want := range map[string]any{
+		"traceId":           s.TraceID.String(),
+		"spanId":            s.SpanID.String(),
+		"parentSpanId":      parent.String(),
+		"name":              "refinery.gate",
+		"startTimeUnixNano": "1700000000000000005",
+	} {
+		if span[k] != want {
+			t.Errorf("%s = %v, want %v", k, span[k], want)
+		}
+	}
+	status := span["status"].(map[string]any)
+	if status["code"] != float64(2) || status["message"] != "exit 1" {
+		t.Errorf("status = %v", status)
+	}
+	attrs := map[string]map[string]any{}
+	for _, a := range span["attributes"].([]any) {
+		kv := a.(map[string]any)
+		attrs[kv["key"].(string)] = kv["value"].(map[string]any)
+	}
+	if attrs["pogo.attempt"]["intValue"] != "2" || attrs["pogo.ratio"]["doubleValue"] != 0.5 ||
+		attrs["pogo.retried"]["boolValue"] != true || attrs["pogo.work_item_id"]["stringValue"] != "mg-1" {
+		t.Errorf("attributes = %v", attrs)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	if err := (&OTLPExporter{Endpoint: failing.URL + "/v1/traces"}).Export([]Span{s}); err == nil {
+		t.Error("a 503 from the collector was not an error")
+	}
+}
@@ -0,0 +1,29 @@
+// Package vt is a terminal emulator without a terminal: it parses the byte
+// stream an agent's harness writes to its PTY and keeps the screen that stream
+// would leave on a VT100/xterm — a grid of cells, a cursor, the alternate
+// screen — so pogod can ask what an agent is SHOWING rather than what it last
+// wrote (user-014).
+//
+// The difference is the whole point. Claude Code, Codex and pi are
+// differential-render TUIs: after the first frame they send only what changed,
+// addressed by cursor movement. A ring buffer of those bytes, even with the
+// escapes stripped, is a scramble of redraw fragments — a dialog's footer
+// arrives as "1:Bad2:Fine3:Good0:Dismiss" because its spacing was ESC[3C, a
+// phrase that was drawn and then erased is still in the buffer, and a phrase
+// repainted one word at a time is never in it whole. Every modal detector
+// written against the buffer has broken when a harness changed how it redraws.
+// Against the screen those are all the same text, in the same place, for as
+// long as it is visible and no longer.
+//
+// What it models is what a detector can read: printable text (including wide
+// characters, which take two cells), cursor addressing, erase and
+// insert/delete, scroll regions, autowrap, the alternate screen and the window
+// title. What it does not model is what only a human would see: colours and
+// other attributes (SGR is parsed and dropped), combining marks (dropped with
+// the other zero-width runes), scrollback, and anything that would need an
+// answer — pogod is not the terminal, so device-status queries go unanswered
+// here and are left to whichever client is attached.
+//
+// A Screen is safe for concurrent use: the PTY reader writes it while the API
+// and the detectors read it.
+package vt
@@ -0,0 +1,762 @@
+package vt
+
+import (
+	"sync"
+	"unicode/utf8"
+)
+
+// wideTail marks the second cell of a two-cell character. A zero cell is
+// blank.
+const wideTail rune = -1
+
+// maxParams bounds the parameters a CSI sequence may carry, and maxOSC the
+// bytes an OSC string may; anything past them is dropped, so a malformed or
+// hostile stream cannot grow the parser without limit.
+const (
+	maxParams = 32
+	maxOSC    = 4096
+)
+
+// Parser states. A sequence can be split across any number of writes, so the
+// parser carries its state between them.
+const (
+	stateGround    = iota
+	stateEscape    // after ESC
+	stateEscInter  // ESC followed by an intermediate, e.g. ESC ( B
+	stateCSI       // ESC [
+	stateOSC       // ESC ]
+	stateOSCEsc    // ESC inside an OSC string, expecting the \ of ST
+	stateString    // DCS, SOS, PM, APC: skipped to ST
+	stateStringEsc // ESC inside one of those
+)
+
+// cursor is a position on the grid, zero-based.
+type cursor struct {
+	row, col int
+}
+
+// Screen is the emulated terminal. The zero value is not usable; see New.
+type Screen struct {
+	mu sync.Mutex
+
+	cols, rows int
+	// main and alt are the two screens; grid is whichever is showing.
+	main, alt [][]rune
+	grid      [][]rune
+	altActive bool
+
+	cur cursor
+	// wrapNext is the pending wrap of a cursor that has just written the last
+	// column: the next printable character goes to the start of the next line,
+	// but a CR, a cursor move or an erase in the meantime cancels it, as on a
+	// real VT100.
+	wrapNext bool
+	saved    cursor
+	// top and bottom are the scroll region, inclusive.
+	top, bottom int
+
+	autowrap     bool
+	originMode   bool
+	insertMode   bool
+	cursorHidden bool
+	title        string
+	// last is the most recent printable character, for REP.
+	last rune
+	// gen counts changes to the text on the showing screen; see Generation.
+	gen uint64
+
+	state   int
+	params  []int
+	param   int
+	inParam bool
+	private byte
+	inter   bool
+	osc     []byte
+	utf     []byte
+}
+
+// New returns a blank cols×rows screen with the cursor at the top left.
+// Dimensions below 1 are taken as 1.
+func New(cols, rows int) *Screen {
+	s := &Screen{}
+	s.reset(max(cols, 1), max(rows, 1))
+	return s
+}
+
+// reset is RIS: a blank screen of the given size and every mode at its
+// power-on value.
+func (s *Screen) reset(cols, rows int) {
+	s.cols, s.rows = cols, rows
+	s.main, s.alt = newGrid(cols, rows), newGrid(cols, rows)
+	s.grid, s.altActive = s.main, false
+	s.cur, s.saved, s.wrapNext = cursor{}, cursor{}, false
+	s.top, s.bottom = 0, rows-1
+	s.autowrap, s.originMode, s.insertMode, s.cursorHidden = true, false, false, false
+	s.title, s.last = "", 0
+	s.state = stateGround
+	s.gen++
+}
+
+func newGrid(cols, rows int) [][]rune {
+	g := make([][]rune, rows)
+	for i := range g {
+		g[i] = make([]rune, cols)
+	}
+	return g
+}
+
+// Size returns the screen's dimensions.
+func (s *Screen) Size() (cols, rows int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cols, s.rows
+}
+
+// Resize changes the screen's dimensions, as a TIOCSWINSZ on the agent's PTY
+// does. Text that still fits stays where it is; when the screen loses rows
+// with the cursor below the new bottom, the top rows go, so the cursor's line
+// survives. The scroll region is reset to the whole screen. A resize to the
+// current size, or to a dimension below 1, does nothing.
+func (s *Screen) Resize(cols, rows int) {
+	if cols < 1 || rows < 1 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cols == s.cols && rows == s.rows {
+		return
+	}
+	shift := 0
+	if s.cur.row >= rows {
+		shift = s.cur.row - rows + 1
+	}
+	s.main = resizeGrid(s.main, cols, rows, shift)
+	s.alt = resizeGrid(s.alt, cols, rows, shift)
+	if s.altActive {
+		s.grid = s.alt
+	} else {
+		s.grid = s.main
+	}
+	s.cols, s.rows = cols, rows
+	s.cur.row -= shift
+	s.clampCursor()
+	s.saved.row = min(max(s.saved.row-shift, 0), rows-1)
+	s.saved.col = min(s.saved.col, cols-1)
+	s.top, s.bottom = 0, rows-1
+	s.wrapNext = false
+	s.gen++
+}
+
+// Generation returns a counter that moves whenever the text on the showing
+// screen may have changed: a character written over a different one, a
+// non-blank cell erased, a scroll, a switch of screens, a resize. A reader
+// that renders the screen to match against it can skip the render while the
+// counter stays where it was at its last one. Cursor moves, mode changes and
+// the title do not move it.
+func (s *Screen) Generation() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gen
+}
+
+func resizeGrid(g [][]rune, cols, rows, shift int) [][]rune {
+	out := newGrid(cols, rows)
+	for r := range out {
+		if r+shift < len(g) {
+			copy(out[r], g[r+shift])
+		}
+	}
+	return out
+}
+
+// Write feeds PTY output to the screen. It never fails: bytes the emulator
+// does not understand are dropped, as a terminal drops them.
+func (s *Screen) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, b := range p {
+		s.feed(b)
+	}
+	return len(p), nil
+}
+
+func (s *Screen) feed(b byte) {
+	switch s.state {
+	case stateGround:
+		switch {
+		case len(s.utf) > 0 || b >= 0x80:
+			s.feedUTF8(b)
+		case b < 0x20 || b == 0x7f:
+			s.control(b)
+		default:
+			s.print(rune(b))
+		}
+	case stateEscape:
+		s.escape(b)
+	case stateEscInter:
+		// The designated character set (ESC ( B and friends) is the only
+		// thing these select, and every one of them prints text the same way
+		// here. Swallow the intermediates and the final byte.
+		if b < 0x20 || b > 0x2f {
+			s.state = stateGround
+		}
+	case stateCSI:
+		s.csiByte(b)
+	case stateOSC:
+		switch b {
+		case 0x07:
+			s.oscEnd()
+		case 0x1b:
+			s.state = stateOSCEsc
+		default:
+			if len(s.osc) < maxOSC {
+				s.osc = append(s.osc, b)
+			}
+		}
+	case stateOSCEsc:
+		s.oscEnd()
+		if b != '\\' {
+			s.state = stateEscape
+			s.escape(b)
+		}
+	case stateString:
+		switch b {
+		case 0x07:
+			s.state = stateGround
+		case 0x1b:
+			s.state = stateStringEsc
+		}
+	case stateStringEsc:
+		if b == '\\' {
+			s.state = stateGround
+		} else {
+			s.state = stateString
+		}
+	}
+}
+
+// feedUTF8 collects a multi-byte character, which may arrive split across
+// writes. An invalid sequence prints one U+FFFD and the bytes after its first
+// are parsed again from the ground state.
+func (s *Screen) feedUTF8(b byte) {
+	s.utf = append(s.utf, b)
+	if !utf8.FullRune(s.utf) {
+		return
+	}
+	r, size := utf8.DecodeRune(s.utf)
+	rest := append([]byte(nil), s.utf[size:]...)
+	s.utf = s.utf[:0]
+	s.print(r)
+	for _, b := range rest {
+		s.feed(b)
+	}
+}
+
+// control executes a C0 control character.
+func (s *Screen) control(b byte) {
+	switch b {
+	case '\b':
+		if s.cur.col > 0 {
+			s.cur.col--
+		}
+		s.wrapNext = false
+	case '\t':
+		s.cur.col = min((s.cur.col/8+1)*8, s.cols-1)
+		s.wrapNext = false
+	case '\n', '\v', '\f':
+		s.lineFeed()
+	case '\r':
+		s.cur.col = 0
+		s.wrapNext = false
+	case 0x1b:
+		s.state = stateEscape
+	case 0x18, 0x1a:
+		s.state = stateGround
+	}
+}
+
+// escape handles the byte after ESC.
+func (s *Screen) escape(b byte) {
+	s.state = stateGround
+	switch b {
+	case '[':
+		s.state = stateCSI
+		s.params, s.param, s.inParam, s.private, s.inter = s.params[:0], 0, false, 0, false
+	case ']':
+		s.state = stateOSC
+		s.osc = s.osc[:0]
+	case 'P', 'X', '^', '_':
+		s.state = stateString
+	case '(', ')', '*', '+', '-', '.', '/', '#', '%', ' ':
+		s.state = stateEscInter
+	case 0x1b:
+		s.state = stateEscape
+	case '7':
+		s.saved = s.cur
+	case '8':
+		s.cur = s.saved
+		s.clampCursor()
+		s.wrapNext = false
+	case 'D':
+		s.lineFeed()
+	case 'E':
+		s.cur.col = 0
+		s.lineFeed()
+	case 'M':
+		s.reverseIndex()
+	case 'c':
+		s.reset(s.cols, s.rows)
+	}
+}
+
+// csiByte accumulates one byte of a CSI sequence and dispatches it on the
+// final byte.
+func (s *Screen) csiByte(b byte) {
+	switch {
+	case b >= '0' && b <= '9':
+		s.param = min(s.param*10+int(b-'0'), 1<<16)
+		s.inParam = true
+	case b == ';' || b == ':':
+		s.pushParam()
+	case b >= '<' && b <= '?':
+		if len(s.params) == 0 && !s.inParam {
+			s.private = b
+		}
+	case b >= 0x20 && b <= 0x2f:
+		s.inter = true
+	case b >= 0x40 && b <= 0x7e:
+		if s.inParam || len(s.params) > 0 {
+			s.pushParam()
+		}
+		s.state = stateGround
+		s.csi(b)
+	case b == 0x1b:
+		s.state = stateEscape
+	case b == 0x18 || b == 0x1a:
+		s.state = stateGround
+	case b < 0x20:
+		// C0 controls are executed mid-sequence, as a VT100 does.
+		s.control(b)
+	}
+}
+
+func (s *Screen) pushParam() {
+	if len(s.params) < maxParams {
+		s.params = append(s.params, s.param)
+	}
+	s.param, s.inParam = 0, false
+}
+
+// arg returns parameter i, or def when it is missing or zero.
+func (s *Screen) arg(i, def int) int {
+	if i < len(s.params) && s.params[i] != 0 {
+		return s.params[i]
+	}
+	return def
+}
+
+// csi executes a complete CSI sequence.
+func (s *Screen) csi(final byte) {
+	if s.inter {
+		// DECSCUSR (cursor shape), DECSTR and the like: nothing on the grid.
+		return
+	}
+	switch s.private {
+	case 0:
+	case '?':
+		switch final {
+		case 'h':
+			s.setPrivateModes(true)
+		case 'l':
+			s.setPrivateModes(false)
+		}
+		return
+	default:
+		// CSI > and CSI = are keyboard-protocol and device queries; CSI < is
+		// the kitty keyboard stack. None of them touch the screen.
+		return
+	}
+
+	n := s.arg(0, 1)
+	switch final {
+	case '@':
+		s.insertChars(n)
+	case 'A':
+		s.cursorUp(n)
+	case 'B', 'e':
+		s.cursorDown(n)
+	case 'C', 'a':
+		s.moveTo(s.cur.row, s.cur.col+n)
+	case 'D':
+		s.moveTo(s.cur.row, s.cur.col-n)
+	case 'E':
+		s.cursorDown(n)
+		s.cur.col = 0
+	case 'F':
+		s.cursorUp(n)
+		s.cur.col = 0
+	case 'G', '`':
+		s.moveTo(s.cur.row, n-1)
+	case 'H', 'f':
+		row := s.arg(0, 1) - 1
+		if s.originMode {
+			row = min(row+s.top, s.bottom)
+		}
+		s.moveTo(row, s.arg(1, 1)-1)
+	case 'd':
+		row := n - 1
+		if s.originMode {
+			row = min(row+s.top, s.bottom)
+		}
+		s.moveTo(row, s.cur.col)
+	case 'I':
+		for ; n > 0; n-- {
+			s.control('\t')
+		}
+	case 'Z':
+		for ; n > 0 && s.cur.col > 0; n-- {
+			s.cur.col = (s.cur.col - 1) / 8 * 8
+		}
+		s.wrapNext = false
+	case 'J':
+		s.eraseDisplay(s.arg(0, 0))
+	case 'K':
+		s.eraseLine(s.arg(0, 0))
+	case 'L':
+		s.insertLines(n)
+	case 'M':
+		s.deleteLines(n)
+	case 'P':
+		s.deleteChars(n)
+	case 'X':
+		s.wrapNext = false
+		s.clearCells(s.grid[s.cur.row], s.cur.col, s.cur.col+n)
+	case 'S':
+		s.scrollUp(s.top, s.bottom, n)
+	case 'T':
+		// CSI T with five parameters is xterm's highlight mouse tracking.
+		if len(s.params) <= 1 {
+			s.scrollDown(s.top, s.bottom, n)
+		}
+	case 'b':
+		if s.last != 0 {
+			for ; n > 0; n-- {
+				s.print(s.last)
+			}
+		}
+	case 'h', 'l':
+		for i := range s.params {
+			if s.params[i] == 4 {
+				s.insertMode = final == 'h'
+			}
+		}
+	case 'r':
+		top, bottom := s.arg(0, 1)-1, s.arg(1, s.rows)-1
+		if bottom > s.rows-1 {
+			bottom = s.rows - 1
+		}
+		if top < bottom {
+			s.top, s.bottom = top, bottom
+			s.moveTo(s.originTop(), 0)
+		}
+	case 's':
+		s.saved = s.cur
+	case 'u':
+		s.cur = s.saved
+		s.clampCursor()
+		s.wrapNext = false
+	}
+}
+
+// setPrivateModes applies CSI ? … h (set) or l (reset).
+func (s *Screen) setPrivateModes(set bool) {
+	for _, m := range s.params {
+		switch m {
+		case 6:
+			s.originMode = set
+			s.moveTo(s.originTop(), 0)
+		case 7:
+			s.autowrap = set
+			if !set {
+				s.wrapNext = false
+			}
+		case 25:
+			s.cursorHidden = !set
+		case 47, 1047, 1049:
+			s.switchScreen(set, m)
+		}
+	}
+}
+
+// switchScreen enters or leaves the alternate screen. 1049 saves the cursor
+// on the way in and restores it on the way out; 1049 and 1047 enter a clean
+// alternate screen.
+func (s *Screen) switchScreen(alt bool, mode int) {
+	if alt == s.altActive {
+		return
+	}
+	if alt {
+		if mode == 1049 {
+			s.saved = s.cur
+		}
+		if mode != 47 {
+			for _, line := range s.alt {
+				clear(line)
+			}
+		}
+		s.grid = s.alt
+	} else {
+		s.grid = s.main
+		if mode == 1049 {
+			s.cur = s.saved
+			s.clampCursor()
+		}
+	}
+	s.altActive = alt
+	s.wrapNext = false
+	s.gen++
+}
+
+func (s *Screen) oscEnd() {
+	s.state = stateGround
+	// OSC 0 and OSC 2 set the window title; the rest (colours, hyperlinks,
+	// the clipboard, cwd reports) are nothing a detector reads.
+	if len(s.osc) >= 2 && (s.osc[0] == '0' || s.osc[0] == '2') && s.osc[1] == ';' {
+		s.title = string(s.osc[2:])
+	}
+}
+
+// print writes one character at the cursor.
+func (s *Screen) print(r rune) {
+	w := runeWidth(r)
+	if w == 0 {
+		return
+	}
+	if s.wrapNext {
+		s.cur.col = 0
+		s.lineFeed()
+	}
+	if w == 2 && s.cur.col == s.cols-1 {
+		// A wide character does not split across lines; it wraps whole.
+		if !s.autowrap || s.cols < 2 {
+			return
+		}
+		s.clearCells(s.grid[s.cur.row], s.cur.col, s.cols)
+		s.cur.col = 0
+		s.lineFeed()
+	}
+	line := s.grid[s.cur.row]
+	if s.insertMode {
+		s.insertChars(w)
+	}
+	// A redraw that writes what is already there changes nothing; leaving
+	// the cells alone keeps the generation where it was.
+	if line[s.cur.col] != r || w == 2 && line[s.cur.col+1] != wideTail {
+		s.clearCells(line, s.cur.col, s.cur.col+w)
+		line[s.cur.col] = r
+		if w == 2 {
+			line[s.cur.col+1] = wideTail
+		}
+		s.gen++
+	}
+	s.last = r
+	if s.cur.col+w < s.cols {
+		s.cur.col += w
+		return
+	}
+	s.cur.col = s.cols - 1
+	s.wrapNext = s.autowrap
+}
+
+// clearCells blanks line[from:to], and the other half of any wide character
+// the range cuts in two. Blanking cells that are already blank is no change.
+func (s *Screen) clearCells(line []rune, from, to int) {
+	from, to = max(from, 0), min(to, len(line))
+	if from >= to {
+		return
+	}
+	if line[from] == wideTail && from > 0 {
+		line[from-1] = 0
+	}
+	if to < len(line) && line[to] == wideTail {
+		line[to] = 0
+	}
+	for _, c := range line[from:to] {
+		if c != 0 {
+			clear(line[from:to])
+			s.gen++
+			return
+		}
+	}
+}
+
+func (s *Screen) lineFeed() {
+	s.wrapNext = false
+	switch {
+	case s.cur.row == s.bottom:
+		s.scrollUp(s.top, s.bottom, 1)
+	case s.cur.row < s.rows-1:
+		s.cur.row++
+	}
+}
+
+func (s *Screen) reverseIndex() {
+	s.wrapNext = false
+	switch {
+	case s.cur.row == s.top:
+		s.scrollDown(s.top, s.bottom, 1)
+	case s.cur.row > 0:
+		s.cur.row--
+	}
+}
+
+// scrollUp moves rows top+n..bottom up by n and blanks the n rows freed at
+// the bottom. What scrolls off the top is gone: there is no scrollback.
+func (s *Screen) scrollUp(top, bottom, n int) {
+	n = min(n, bottom-top+1)
+	for i := 0; i < n; i++ {
+		line := s.grid[top]
+		copy(s.grid[top:bottom], s.grid[top+1:bottom+1])
+		clear(line)
+		s.grid[bottom] = line
+	}
+	s.gen++
+}
+
+// scrollDown moves rows top..bottom-n down by n and blanks the n rows freed
+// at the top.
+func (s *Screen) scrollDown(top, bottom, n int) {
+	n = min(n, bottom-top+1)
+	for i := 0; i < n; i++ {
+		line := s.grid[bottom]
+		copy(s.grid[top+1:bottom+1], s.grid[top:bottom])
+		clear(line)
+		s.grid[top] = line
+	}
+	s.gen++
+}
+
+func (s *Screen) insertLines(n int) {
+	if s.cur.row < s.top || s.cur.row > s.bottom {
+		return
+	}
+	s.scrollDown(s.cur.row, s.bottom, n)
+	s.cur.col, s.wrapNext = 0, false
+}
+
+func (s *Screen) deleteLines(n int) {
+	if s.cur.row < s.top || s.cur.row > s.bottom {
+		return
+	}
+	s.scrollUp(s.cur.row, s.bottom, n)
+	s.cur.col, s.wrapNext = 0, false
+}
+
+// insertChars shifts the rest of the cursor's line right by n blank cells;
+// what passes the right margin is lost.
+func (s *Screen) insertChars(n int) {
+	line := s.grid[s.cur.row]
+	col := s.cur.col
+	n = min(n, s.cols-col)
+	if line[col] == wideTail && col > 0 {
+		line[col-1], line[col] = 0, 0
+	}
+	copy(line[col+n:], line[col:])
+	clear(line[col : col+n])
+	if last := line[s.cols-1]; last != wideTail && runeWidth(last) == 2 {
+		line[s.cols-1] = 0
+	}
+	s.wrapNext = false
+	s.gen++
+}
+
+// deleteChars removes n cells at the cursor, shifting the rest of the line
+// left and blanking the right margin.
+func (s *Screen) deleteChars(n int) {
+	line := s.grid[s.cur.row]
+	col := s.cur.col
+	n = min(n, s.cols-col)
+	s.clearCells(line, col, col+n)
+	copy(line[col:], line[col+n:])
+	clear(line[s.cols-n:])
+	if line[col] == wideTail {
+		line[col] = 0
+	}
+	s.wrapNext = false
+	s.gen++
+}
+
+// eraseDisplay is ED: 0 from the cursor to the end of the screen, 1 from the
+// start to the cursor, 2 and 3 all of it.
+func (s *Screen) eraseDisplay(mode int) {
+	s.wrapNext = false
+	switch mode {
+	case 0:
+		s.clearCells(s.grid[s.cur.row], s.cur.col, s.cols)
+		for _, line := range s.grid[s.cur.row+1:] {
+			s.clearCells(line, 0, s.cols)
+		}
+	case 1:
+		for _, line := range s.grid[:s.cur.row] {
+			s.clearCells(line, 0, s.cols)
+		}
+		s.clearCells(s.grid[s.cur.row], 0, s.cur.col+1)
+	case 2, 3:
+		for _, line := range s.grid {
+			s.clearCells(line, 0, s.cols)
+		}
+	}
+}
+
+// eraseLine is EL: 0 from the cursor to the end of the line, 1 from its start
+// to the cursor, 2 all of it.
+func (s *Screen) eraseLine(mode int) {
+	s.wrapNext = false
+	line := s.grid[s.cur.row]
+	switch mode {
+	case 0:
+		s.clearCells(line, s.cur.col, s.cols)
+	case 1:
+		s.clearCells(line, 0, s.cur.col+1)
+	case 2:
+		s.clearCells(line, 0, s.cols)
+	}
+}
+
+// cursorUp stops at the top margin when the cursor starts inside the scroll
+// region, and at the top of the screen otherwise; cursorDown likewise.
+func (s *Screen) cursorUp(n int) {
+	limit := 0
+	if s.cur.row >= s.top {
+		limit = s.top
+	}
+	s.moveTo(max(s.cur.row-n, limit), s.cur.col)
+}
+
+func (s *Screen) cursorDown(n int) {
+	limit := s.rows - 1
+	if s.cur.row <= s.bottom {
+		limit = s.bottom
+	}
+	s.moveTo(min(s.cur.row+n, limit), s.cur.col)
+}
+
+func (s *Screen) originTop() int {
+	if s.originMode {
+		return s.top
+	}
+	return 0
+}
+
+// moveTo puts the cursor at row, col, clamped to the screen, and cancels a
+// pending wrap.
+func (s *Screen) moveTo(row, col int) {
+	s.cur = cursor{row, col}
+	s.clampCursor()
+	s.wrapNext = false
+}
+
+func (s *Screen) clampCursor() {
+	s.cur.row = min(max(s.cur.row, 0), s.rows-1)
+	s.cur.col = min(max(s.cur.col, 0), s.cols-1)
+}
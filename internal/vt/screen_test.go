@@ -0,0 +1,230 @@
+package vt
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// render feeds chunks to a fresh cols×rows screen and returns its snapshot.
+func render(cols, rows int, chunks ...string) Snapshot {
+	s := New(cols, rows)
+	for _, c := range chunks {
+		s.Write([]byte(c))
+	}
+	return s.Snapshot()
+}
+
+func wantLines(t *testing.T, snap Snapshot, want ...string) {
+	t.Helper()
+	got := snap.Lines[:len(want)]
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+	for i, l := range snap.Lines[len(want):] {
+		if l != "" {
+			t.Errorf("row %d = %q, want blank", len(want)+i, l)
+		}
+	}
+}
+
+func TestTextAndLineDiscipline(t *testing.T) {
+	snap := render(20, 4, "hello\r\nworld\r\n\tx\r\nab\bc")
+	wantLines(t, snap, "hello", "world", "        x", "ac")
+	if snap.Cursor != (Cursor{Row: 3, Col: 2}) {
+		t.Errorf("cursor = %+v", snap.Cursor)
+	}
+}
+
+// A TUI spaces a footer's columns with cursor-forward escapes. On the screen
+// those are blank cells, so the marker reads with its spaces — the case the
+// stripped stream got wrong.
+func TestColumnMovesBecomeBlankCells(t *testing.T) {
+	snap := render(40, 3, "\x1b[2K\x1b[38;5;244m1:Bad\x1b[3C2:Fine\x1b[3C3:Good\x1b[0m")
+	wantLines(t, snap, "1:Bad   2:Fine   3:Good")
+	if !snap.Contains("1:Bad 2:Fine 3:Good") {
+		t.Error("Contains missed the footer")
+	}
+}
+
+// A differential renderer repaints a phrase piecemeal and erases what it
+// replaces; the screen holds the result, not the history.
+func TestRedrawsAndErases(t *testing.T) {
+	s := New(30, 5)
+	s.Write([]byte("\x1b[2;1HStop and wait"))
+	s.Write([]byte("\x1b[2;15Hfor limit to reset"))
+	if !s.Contains("Stop and wait for limit to reset") {
+		t.Fatalf("piecemeal phrase not on screen: %q", s.Snapshot().Lines)
+	}
+	s.Write([]byte("\x1b[2;1H\x1b[K"))
+	if s.Contains("Stop and wait") {
+		t.Errorf("erased line still matches: %q", s.Snapshot().Lines)
+	}
+
+	s.Write([]byte("\x1b[1;1Habc\x1b[3;1Hdef\x1b[2;2H\x1b[J"))
+	wantLines(t, s.Snapshot(), "abc")
+	s.Write([]byte("\x1b[1;1Hxyz\x1b[1;2H\x1b[1K"))
+	wantLines(t, s.Snapshot(), "  z")
+}
+
+func TestAutowrapIsPending(t *testing.T) {
+	// Writing the last column leaves the cursor there until the next
+	// printable character; a CR in between cancels the wrap.
+	snap := render(5, 3, "abcde\rX")
+	wantLines(t, snap, "Xbcde")
+	snap = render(5, 3, "abcdefg")
+	wantLines(t, snap, "abcde", "fg")
+	snap = render(5, 3, "\x1b[?7labcdefg")
+	wantLines(t, snap, "abcdg")
+	if !render(5, 3, "Stop and wait").Contains("Stop and wait") {
+		t.Error("Contains missed a phrase wrapped across rows")
+	}
+}
+
+func TestScrollingAndRegions(t *testing.T) {
+	wantLines(t, render(10, 3, "1\r\n2\r\n3\r\n4"), "2", "3", "4")
+
+	// A status line pinned below a scroll region stays put while the region
+	// scrolls.
+	snap := render(10, 4, "\x1b[4;1Hstatus\x1b[1;3r\x1b[1;1Ha\r\nb\r\nc\r\nd")
+	wantLines(t, snap, "b", "c", "d", "status")
+
+	snap = render(10, 4, "a\r\nb\r\nc\r\nd\x1b[2;1H\x1b[L")
+	wantLines(t, snap, "a", "", "b", "c")
+	snap = render(10, 4, "a\r\nb\r\nc\r\nd\x1b[2;1H\x1b[M")
+	wantLines(t, snap, "a", "c", "d")
+	snap = render(10, 3, "a\r\nb\r\nc\x1b[1;1H\x1bM")
+	wantLines(t, snap, "", "a", "b")
+}
+
+func TestInsertAndDeleteCharacters(t *testing.T) {
+	wantLines(t, render(10, 1, "abcdef\x1b[1;3H\x1b[2P"), "abef")
+	wantLines(t, render(10, 1, "abcdef\x1b[1;3H\x1b[2@"), "ab  cdef")
+	wantLines(t, render(10, 1, "abcdef\x1b[1;3H\x1b[2X"), "ab  ef")
+	wantLines(t, render(10, 1, "abcdef\x1b[1;3H\x1b[4hXY"), "abXYcdef")
+	wantLines(t, render(10, 1, "ab\x1b[3b"), "abbbb")
+}
+
+// A full-screen TUI lives on the alternate screen; leaving it brings back the
+// shell's screen and cursor untouched.
+func TestAlternateScreen(t *testing.T) {
+	s := New(20, 3)
+	s.Write([]byte("$ claude\r\n"))
+	s.Write([]byte("\x1b[?1049h\x1b[?25l\x1b[1;1H1:Bad 2:Fine"))
+	snap := s.Snapshot()
+	if !snap.AltScreen || snap.CursorVisible {
+		t.Errorf("alt=%v cursor visible=%v, want the alternate screen with a hidden cursor", snap.AltScreen, snap.CursorVisible)
+	}
+	wantLines(t, snap, "1:Bad 2:Fine")
+
+	s.Write([]byte("\x1b[?1049l\x1b[?25h"))
+	snap = s.Snapshot()
+	if snap.AltScreen || !snap.CursorVisible {
+		t.Errorf("alt=%v cursor visible=%v after leaving", snap.AltScreen, snap.CursorVisible)
+	}
+	wantLines(t, snap, "$ claude")
+	if snap.Cursor != (Cursor{Row: 1, Col: 0}) {
+		t.Errorf("cursor = %+v, want it restored to 1,0", snap.Cursor)
+	}
+}
+
+// Bytes arrive in PTY-sized chunks, which split escapes and UTF-8 wherever
+// they like.
+func TestSequencesSplitAcrossWrites(t *testing.T) {
+	whole := "\x1b]0;pogo\x07\x1b[2;3H\x1b[1;31m✻ Thinking…\x1b[0m 日本"
+	want := render(20, 3, whole)
+	for i := 1; i < len(whole); i++ {
+		got := render(20, 3, whole[:i], whole[i:])
+		if strings.Join(got.Lines, "|") != strings.Join(want.Lines, "|") || got.Cursor != want.Cursor || got.Title != want.Title {
+			t.Fatalf("split at %d: %q %+v, want %q %+v", i, got.Lines, got.Cursor, want.Lines, want.Cursor)
+		}
+	}
+	wantLines(t, want, "", "  ✻ Thinking… 日本")
+	if want.Title != "pogo" {
+		t.Errorf("title = %q", want.Title)
+	}
+	if want.Cursor.Col != 18 {
+		t.Errorf("cursor col = %d, want wide characters counted twice", want.Cursor.Col)
+	}
+}
+
+func TestWideCharacters(t *testing.T) {
+	// A wide character that would straddle the margin wraps whole.
+	wantLines(t, render(5, 2, "abcd日"), "abcd", "日")
+	// Overwriting either half of a wide character blanks the other.
+	wantLines(t, render(6, 1, "日本\x1b[1;2Hx"), " x本")
+	wantLines(t, render(6, 1, "日本\x1b[1;3Hx"), "日x")
+	// Invalid UTF-8 prints one replacement character and parsing resumes.
+	wantLines(t, render(6, 1, "a\xe2(b"), "a�(b")
+}
+
+func TestUnknownSequencesAreIgnored(t *testing.T) {
+	snap := render(20, 2,
+		"\x1b[>4;1m", "\x1b[<u", "\x1b[2 q", "\x1bP+q544e\x1b\\", "\x1b_Gi=1\x1b\\",
+		"\x1b]8;;https://example.com\x1b\\link\x1b]8;;\x1b\\", "\x1b(B", "\x1b=", "\x1b[6n", "ok")
+	wantLines(t, snap, "linkok")
+}
+
+func TestResize(t *testing.T) {
+	s := New(10, 4)
+	s.Write([]byte("a\r\nb\r\nc\r\nd"))
+	s.Resize(3, 2)
+	snap := s.Snapshot()
+	if snap.Cols != 3 || snap.Rows != 2 {
+		t.Fatalf("size = %dx%d", snap.Cols, snap.Rows)
+	}
+	// The cursor was on the last row; the rows above it go.
+	wantLines(t, snap, "c", "d")
+	if snap.Cursor != (Cursor{Row: 1, Col: 1}) {
+		t.Errorf("cursor = %+v", snap.Cursor)
+	}
+	s.Resize(6, 3)
+	wantLines(t, s.Snapshot(), "c", "d")
+}
+
+func TestFindAndText(t *testing.T) {
+	snap := render(20, 4, "日 [y/n]\r\n\r\nok [Y/n]")
+	got := snap.Find(regexp.MustCompile(`\[[yY]/[nN]\]`))
+	want := []Match{{Row: 0, Col: 3, Text: "[y/n]"}, {Row: 2, Col: 3, Text: "[Y/n]"}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Find = %+v, want %+v", got, want)
+	}
+	if text := snap.Text(); text != "日 [y/n]\n\nok [Y/n]\n" {
+		t.Errorf("Text = %q", text)
+	}
+	if New(3, 3).Snapshot().Text() != "" {
+		t.Error("a blank screen has text")
+	}
+}
+
+// A TUI repaints the same frame over and over; only a repaint that changes
+// the text moves the generation.
+func TestGenerationMovesOnlyWithTheText(t *testing.T) {
+	s := New(20, 3)
+	s.Write([]byte("\x1b[1;1Hready\x1b[2;1H"))
+	g := s.Generation()
+	for _, chunk := range []string{
+		"\x1b[1;1Hready",     // the same text over itself
+		"\x1b[3;1H\x1b[K",    // erasing a blank line
+		"\x1b[?25l\x1b[2;5H", // cursor visibility and position
+		"\x1b]0;title\x07",   // the title
+	} {
+		s.Write([]byte(chunk))
+		if got := s.Generation(); got != g {
+			t.Errorf("after %q: generation %d, want %d", chunk, got, g)
+		}
+	}
+	for _, chunk := range []string{
+		"\x1b[1;1Hsteady", "\x1b[1;1H\x1b[2K", "\r\n\r\n\r\n", "\x1b[?1049h", "日",
+	} {
+		s.Write([]byte(chunk))
+		if got := s.Generation(); got == g {
+			t.Errorf("after %q: generation did not move", chunk)
+		}
+		g = s.Generation()
+	}
+	s.Resize(10, 3)
+	if s.Generation() == g {
+		t.Error("resize did not move the generation")
+	}
+}
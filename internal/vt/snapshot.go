@@ -0,0 +1,151 @@
+package vt
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Snapshot is the screen as it stands at one moment: what `pogo agent screen`
+// prints and /agents/{name}/screen returns.
+type Snapshot struct {
+	Cols int `json:"cols"`
+	Rows int `json:"rows"`
+	// Lines holds one string per row, top to bottom, with trailing blanks
+	// trimmed. A wide character is one rune in its line and two columns on
+	// the screen.
+	Lines  []string `json:"lines"`
+	Cursor Cursor   `json:"cursor"`
+	// CursorVisible is false while the harness has hidden the cursor
+	// (DECTCEM), as a TUI does while it redraws.
+	CursorVisible bool `json:"cursor_visible"`
+	// AltScreen reports whether the alternate screen is showing, as it is for
+	// the whole session of a full-screen TUI.
+	AltScreen bool   `json:"alt_screen"`
+	Title     string `json:"title,omitempty"`
+}
+
+// Cursor is a screen position, zero-based.
+type Cursor struct {
+	Row int `json:"row"`
+	Col int `json:"col"`
+}
+
+// Snapshot returns the screen's current contents.
+func (s *Screen) Snapshot() Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap := Snapshot{
+		Cols:          s.cols,
+		Rows:          s.rows,
+		Lines:         make([]string, len(s.grid)),
+		Cursor:        Cursor{Row: s.cur.row, Col: s.cur.col},
+		CursorVisible: !s.cursorHidden,
+		AltScreen:     s.altActive,
+		Title:         s.title,
+	}
+	var b strings.Builder
+	for i, line := range s.grid {
+		b.Reset()
+		renderLine(&b, line)
+		snap.Lines[i] = strings.TrimRight(b.String(), " ")
+	}
+	return snap
+}
+
+// renderLine writes line's cells as text: a blank is a space and the second
+// cell of a wide character is nothing, unless what was in the first cell has
+// since been overwritten, when it is a space too.
+func renderLine(b *strings.Builder, line []rune) {
+	for i, r := range line {
+		switch {
+		case r == 0:
+			b.WriteByte(' ')
+		case r == wideTail:
+			if i == 0 || runeWidth(line[i-1]) != 2 {
+				b.WriteByte(' ')
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
+
+// Text is the screen as plain text, one row per line, without the blank rows
+// below the last one with anything on it.
+func (s Snapshot) Text() string {
+	last := len(s.Lines)
+	for last > 0 && s.Lines[last-1] == "" {
+		last--
+	}
+	if last == 0 {
+		return ""
+	}
+	return strings.Join(s.Lines[:last], "\n") + "\n"
+}
+
+// Contains reports whether marker is on the screen.
+//
+// The compare ignores whitespace on both sides and reads the rows as one run,
+// so "1:Bad 2:Fine" matches a footer drawn as "1:Bad   2:Fine" and a phrase
+// the screen width wrapped onto a second row. The escapes a TUI spaces its
+// columns with have already become blank cells by the time anything is on the
+// screen, so this is no longer what makes a marker match at all, as it was for
+// the stripped stream (see claude.RatingDialogMarker); it absorbs the drift a
+// harness's layout has from release to release. An empty marker is on every
+// screen.
+func (s Snapshot) Contains(marker string) bool {
+	return strings.Contains(squash(strings.Join(s.Lines, "")), squash(marker))
+}
+
+// Contains is Snapshot().Contains(marker) without keeping the snapshot.
+func (s *Screen) Contains(marker string) bool {
+	return s.Snapshot().Contains(marker)
+}
+
+// Match is one place a pattern matched on the screen.
+type Match struct {
+	Row int `json:"row"`
+	// Col is the screen column the match starts in, counting a wide character
+	// as two.
+	Col  int    `json:"col"`
+	Text string `json:"text"`
+}
+
+// Find returns every match of re on the screen, row by row and left to right.
+// A pattern is matched against one row at a time, so it cannot span rows; use
+// Contains for a phrase the screen may wrap.
+func (s Snapshot) Find(re *regexp.Regexp) []Match {
+	var out []Match
+	for row, line := range s.Lines {
+		for _, loc := range re.FindAllStringIndex(line, -1) {
+			out = append(out, Match{Row: row, Col: columnOf(line, loc[0]), Text: line[loc[0]:loc[1]]})
+		}
+	}
+	return out
+}
+
+// Find is Snapshot().Find(re) without keeping the snapshot.
+func (s *Screen) Find(re *regexp.Regexp) []Match {
+	return s.Snapshot().Find(re)
+}
+
+// columnOf converts a byte offset into line to the screen column it is drawn
+// in.
+func columnOf(line string, offset int) int {
+	col := 0
+	for _, r := range line[:offset] {
+		col += max(runeWidth(r), 1)
+	}
+	return col
+}
+
+// squash drops every whitespace rune from s.
+func squash(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,27 @@
+package vt
+
+import (
+	"os"
+	"testing"
+
+	"github.com/drellem2/pogo/internal/testsandbox"
+)
+
+// testSandbox is the package's private, CHECKED envelope (internal/testsandbox).
+// Nothing here reads HOME, and the envelope keeps it that way for the next
+// test that might.
+var testSandbox *testsandbox.Sandbox
+
+func TestMain(m *testing.M) {
+	sb, down := testsandbox.Main("vt")
+	testSandbox = sb
+
+	code := m.Run()
+
+	down()
+	os.Exit(code)
+}
+
+func TestSandboxIsVerified(t *testing.T) {
+	testsandbox.Verify(t, testSandbox)
+}
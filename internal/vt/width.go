@@ -0,0 +1,88 @@
+package vt
+
+import "unicode"
+
+// wideRanges are the code points a terminal gives two cells: the East Asian
+// Wide and Fullwidth blocks, and the emoji blocks harnesses draw status glyphs
+// from. It is the common subset of what xterm, iTerm2 and the kitty family
+// agree on, not the full Unicode East Asian Width table — a detector matches
+// on text, and a one-cell disagreement in a rare block moves a column, not a
+// word.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115f},   // Hangul Jamo initials
+	{0x231a, 0x231b},   // watch, hourglass
+	{0x23e9, 0x23ec},   // media controls
+	{0x23f0, 0x23f0},   // alarm clock
+	{0x23f3, 0x23f3},   // hourglass with sand
+	{0x25fd, 0x25fe},   // medium small squares
+	{0x2614, 0x2615},   // umbrella, hot beverage
+	{0x2648, 0x2653},   // zodiac
+	{0x26a1, 0x26a1},   // high voltage
+	{0x26aa, 0x26ab},   // medium circles
+	{0x26bd, 0x26be},   // soccer, baseball
+	{0x26c4, 0x26c5},   // snowman, sun behind cloud
+	{0x26d4, 0x26d4},   // no entry
+	{0x26ea, 0x26ea},   // church
+	{0x26f2, 0x26f5},   // fountain … sailboat
+	{0x26fa, 0x26fd},   // tent … fuel pump
+	{0x2705, 0x2705},   // check mark button
+	{0x270a, 0x270b},   // raised fist, hand
+	{0x2728, 0x2728},   // sparkles
+	{0x274c, 0x274e},   // cross marks
+	{0x2753, 0x2757},   // question and exclamation marks
+	{0x2795, 0x2797},   // heavy plus, minus, divide
+	{0x27b0, 0x27bf},   // curly loops
+	{0x2b1b, 0x2b1c},   // large squares
+	{0x2b50, 0x2b55},   // star, heavy circle
+	{0x2e80, 0x303e},   // CJK radicals … CJK symbols and punctuation
+	{0x3041, 0x33ff},   // Hiragana … CJK compatibility
+	{0x3400, 0x4dbf},   // CJK extension A
+	{0x4e00, 0x9fff},   // CJK unified ideographs
+	{0xa000, 0xa4cf},   // Yi
+	{0xa960, 0xa97f},   // Hangul Jamo extended A
+	{0xac00, 0xd7a3},   // Hangul syllables
+	{0xf900, 0xfaff},   // CJK compatibility ideographs
+	{0xfe10, 0xfe19},   // vertical forms
+	{0xfe30, 0xfe6f},   // CJK compatibility forms, small forms
+	{0xff00, 0xff60},   // fullwidth forms
+	{0xffe0, 0xffe6},   // fullwidth signs
+	{0x16fe0, 0x16fe4}, // ideographic symbols
+	{0x17000, 0x18cff}, // Tangut
+	{0x1b000, 0x1b2ff}, // Kana supplement and extensions
+	{0x1f004, 0x1f004}, // mahjong red dragon
+	{0x1f0cf, 0x1f0cf}, // joker
+	{0x1f18e, 0x1f18e}, // AB button
+	{0x1f191, 0x1f19a}, // squared words
+	{0x1f200, 0x1f2ff}, // enclosed ideographic supplement
+	{0x1f300, 0x1f64f}, // pictographs, emoticons
+	{0x1f680, 0x1f6ff}, // transport and map
+	{0x1f7e0, 0x1f7eb}, // coloured circles and squares
+	{0x1f90c, 0x1f9ff}, // supplemental symbols and pictographs
+	{0x1fa70, 0x1faff}, // symbols and pictographs extended A
+	{0x20000, 0x3fffd}, // CJK extensions B onward
+}
+
+// runeWidth is the number of cells r takes: 2 for wide characters, 0 for
+// controls and the zero-width runes (combining marks, joiners, variation
+// selectors), which this emulator drops, and 1 for everything else.
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x20 || (r >= 0x7f && r < 0xa0):
+		return 0
+	case r < 0x300:
+		// Latin-1 and Latin Extended, the overwhelming majority of what a
+		// harness draws, before the first combining mark.
+		return 1
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	}
+	for _, rg := range wideRanges {
+		if r < rg[0] {
+			break
+		}
+		if r <= rg[1] {
+			return 2
+		}
+	}
+	return 1
+}
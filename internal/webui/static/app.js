@@ -0,0 +1,440 @@
+// pogo dashboard (user-024). Polls pogod's JSON API and draws it; see
+// internal/webui/webui.go for what this page is and is not.
+"use strict";
+
+const REFRESH_MS = 5000;
+const SCREEN_THROTTLE_MS = 150;
+const HISTORY_ROWS = 25;
+const EVENT_ROWS = 100;
+
+// --- token -------------------------------------------------------------
+//
+// `pogo ui` opens /ui/#token=...: the fragment never reaches the server or
+// its logs. It is moved into this tab's sessionStorage and out of the
+// address bar at once, so it is not bookmarked or shared with the URL.
+
+const TOKEN_KEY = "pogo-token";
+(function takeToken() {
+  const m = location.hash.match(/(?:^#|&)token=([^&]+)/);
+  if (m) {
+    sessionStorage.setItem(TOKEN_KEY, decodeURIComponent(m[1]));
+    history.replaceState(null, "", location.pathname + location.search);
+  }
+})();
+const token = () => sessionStorage.getItem(TOKEN_KEY) || "";
+
+async function api(path) {
+  const headers = {};
+  if (token()) headers.Authorization = "Bearer " + token();
+  const resp = await fetch(path, { headers, cache: "no-store" });
+  if (!resp.ok) {
+    const text = (await resp.text()).trim();
+    const err = new Error(text || resp.status + " " + resp.statusText);
+    err.status = resp.status;
+    throw err;
+  }
+  const type = resp.headers.get("Content-Type") || "";
+  return type.includes("json") ? resp.json() : resp.text();
+}
+
+// --- rendering helpers -------------------------------------------------
+//
+// Everything is built with textContent: agent output, event details and
+// work-item titles are written by agents, and none of it is markup.
+
+function el(tag, attrs, ...children) {
+  const e = document.createElement(tag);
+  for (const [k, v] of Object.entries(attrs || {})) {
+    if (v === undefined || v === null || v === false) continue;
+    if (k === "class") e.className = v;
+    else if (k === "onclick") e.addEventListener("click", v);
+    else e.setAttribute(k, v);
+  }
+  for (const c of children) {
+    if (c === undefined || c === null) continue;
+    e.append(c instanceof Node ? c : String(c));
+  }
+  return e;
+}
+
+const td = (text, cls, title) => el("td", { class: cls, title }, text ?? "");
+
+function fill(section, rows, columns, empty) {
+  const tbody = section.querySelector("tbody");
+  tbody.replaceChildren(...(rows.length ? rows : [el("tr", {}, el("td", { class: "empty", colspan: columns }, empty))]));
+}
+
+function fail(section, err, columns) {
+  fill(section, [], columns, err.message);
+  setCount(section, "");
+}
+
+function setCount(section, text) {
+  const c = section.querySelector("h2 .count");
+  if (c) c.textContent = text;
+}
+
+const isZero = (ts) => !ts || ts.startsWith("0001-");
+
+// dur renders seconds as the CLI does: the two largest units.
+function dur(seconds) {
+  if (seconds === undefined || seconds === null || isNaN(seconds)) return "";
+  seconds = Math.max(0, Math.round(seconds));
+  const d = Math.floor(seconds / 86400), h = Math.floor(seconds / 3600) % 24,
+        m = Math.floor(seconds / 60) % 60, s = seconds % 60;
+  if (d) return d + "d" + (h ? h + "h" : "");
+  if (h) return h + "h" + (m ? m + "m" : "");
+  if (m) return m + "m" + (s ? s + "s" : "");
+  return s + "s";
+}
+
+const since = (ts) => (isZero(ts) ? "" : dur((Date.now() - Date.parse(ts)) / 1000));
+const until = (ts) => (isZero(ts) ? "" : dur((Date.parse(ts) - Date.now()) / 1000));
+
+function clock(ts) {
+  if (isZero(ts)) return "";
+  const t = new Date(ts);
+  const sameDay = t.toDateString() === new Date().toDateString();
+  return sameDay ? t.toLocaleTimeString() : t.toLocaleString();
+}
+
+const stateClass = { healthy: "ok", idle: "dim", starting: "warn", stalled: "bad", stopped: "dim" };
+const mergeClass = { merged: "ok", processing: "warn", queued: "", held: "warn", failed: "bad", lost: "bad", cancelled: "dim" };
+
+// --- sections ----------------------------------------------------------
+
+async function loadServer() {
+  const out = document.getElementById("server");
+  try {
+    const [v, mode] = await Promise.all([api("/version"), api("/server/mode").catch(() => null)]);
+    const build = v.build && v.build.version ? v.build.version : (v.revision || "").slice(0, 12);
+    out.textContent = "pogod " + build + " · pid " + v.pid + " · up " + since(v.start_time) +
+      (mode ? " · " + mode.mode : "");
+  } catch (err) {
+    out.textContent = "";
+    throw err;
+  }
+}
+
+async function loadAgents() {
+  const section = document.getElementById("agents");
+  try {
+    const agents = (await api("/agents")) || [];
+    agents.sort((a, b) => a.type.localeCompare(b.type) || a.name.localeCompare(b.name));
+    setCount(section, agents.length);
+    fill(section, agents.map((a) => {
+      const state = a.state ? a.state.name : "";
+      let status = a.status;
+      if (a.rate_limited) status += ", rate limited";
+      if (a.mail_warn) status += ", mail " + a.mail_warn;
+      const writer = (a.attached || []).find((c) => c.writer);
+      const attached = (a.attached || []).length
+        ? a.attached.length + (writer ? " (" + writer.who + " typing)" : "")
+        : "";
+      return el("tr", { class: "click", title: "Watch " + a.name + "'s terminal", onclick: () => openTerminal(a.name) },
+        td(a.name, "mono"),
+        td(a.type),
+        td(state, stateClass[state], a.state && a.state.reason),
+        td(a.state ? since(a.state.since_ts) : ""),
+        td(status, a.status === "running" ? "" : "warn", a.mail_warn_detail),
+        td(a.work_item_id, "mono"),
+        td(a.model),
+        td(a.restart_count || "", a.restart_count ? "warn" : ""),
+        td(a.last_activity),
+        td(attached));
+    }), 10, "No agents.");
+  } catch (err) {
+    fail(section, err, 10);
+    throw err;
+  }
+}
+
+async function loadRefinery() {
+  const section = document.getElementById("refinery");
+  const status = document.getElementById("refinery-status");
+  const queue = document.getElementById("queue");
+  const history = document.getElementById("history");
+  try {
+    const [st, q, h] = await Promise.all([api("/refinery/status"), api("/refinery/queue"), api("/refinery/history")]);
+    status.textContent = (st.running ? "running" : "stopped") + " · polls every " + st.poll_interval +
+      " · " + st.queue_len + " queued · " + st.history_len + " in history";
+    setCount(section, (q || []).length ? (q || []).length + " in flight or queued" : "");
+    fill(queue, (q || []).map((mr) => {
+      const p = mr.progress;
+      const gate = p && p.gate ? p.gate + (p.gate_count ? " (" + p.gate_index + "/" + p.gate_count + ")" : "") +
+        " · " + since(p.start_time) : (p ? p.step : "");
+      return el("tr", {},
+        td(mr.id, "mono"), td(mr.status, mergeClass[mr.status]), td(mr.author), td(mr.branch, "mono"),
+        td(mr.target_ref, "mono"), td(since(mr.submit_time)), td(gate, "mono"));
+    }), 7, "The queue is empty.");
+    const recent = (h || []).slice().sort((a, b) => Date.parse(b.done_time) - Date.parse(a.done_time)).slice(0, HISTORY_ROWS);
+    fill(history, recent.map((mr) => el("tr", {},
+      td(mr.id, "mono"), td(mr.status, mergeClass[mr.status]), td(mr.author), td(mr.branch, "mono"),
+      td(mr.target_ref, "mono"), td(clock(mr.done_time)),
+      td(isZero(mr.start_time) || isZero(mr.done_time) ? "" : dur((Date.parse(mr.done_time) - Date.parse(mr.start_time)) / 1000)),
+      td(mr.error, "wrap bad"))), 8, "No merges yet.");
+  } catch (err) {
+    status.textContent = err.message;
+    fail(queue, err, 7);
+    fail(history, err, 8);
+    throw err;
+  }
+}
+
+async function loadSchedules() {
+  const section = document.getElementById("schedules");
+  try {
+    const entries = (await api("/scheduler/schedules")) || [];
+    entries.sort((a, b) => a.agent.localeCompare(b.agent) || Date.parse(a.next_fire) - Date.parse(b.next_fire));
+    setCount(section, entries.length);
+    fill(section, entries.map((e) => {
+      const delivered = e.fires_delivered || 0, completed = e.fires_completed || 0;
+      const streak = e.unacked_streak || 0;
+      return el("tr", { title: e.message },
+        td(e.agent, "mono"), td(e.kind), td(e.cron || (e.one_shot ? "once" : ""), "mono"),
+        td(until(e.next_fire) ? "in " + until(e.next_fire) : clock(e.next_fire)),
+        td(since(e.last_fire) ? since(e.last_fire) + " ago" : ""),
+        td(delivered ? completed + "/" + delivered : ""),
+        td(streak || "", streak >= 2 ? "bad" : streak ? "warn" : ""),
+        td(e.missed_fires || "", e.missed_fires ? "warn" : ""),
+        td(since(e.last_completion) ? since(e.last_completion) + " ago" : ""));
+    }), 9, "No schedules.");
+  } catch (err) {
+    fail(section, err, 9);
+    throw err;
+  }
+}
+
+async function loadWorkItems() {
+  const section = document.getElementById("workitems");
+  const status = document.getElementById("workitem-status").value;
+  try {
+    const items = (await api("/workitems" + (status ? "?status=" + encodeURIComponent(status) : ""))) || [];
+    setCount(section, items.length);
+    fill(section, items.map((w) => el("tr", { title: w.depends ? "depends on " + w.depends : null },
+      td(w.id, "mono"), td(w.status), td(w.title, "wrap"), td(w.assignee), td(w.type), td(w.priority),
+      td(w.repo ? w.repo.split("/").pop() : "", "mono", w.repo))), 7, "No work items.");
+  } catch (err) {
+    fail(section, err, 7);
+    throw err;
+  }
+}
+
+function summarize(details) {
+  return Object.entries(details || {}).map(([k, v]) =>
+    k + "=" + (typeof v === "object" ? JSON.stringify(v) : v)).join(" ");
+}
+
+async function loadEvents() {
+  const section = document.getElementById("events");
+  const params = new URLSearchParams({ since: document.getElementById("events-since").value, limit: EVENT_ROWS });
+  for (const [id, key] of [["events-type", "type"], ["events-agent", "agent"]]) {
+    const v = document.getElementById(id).value.trim();
+    if (v) params.set(key, v);
+  }
+  try {
+    const evs = ((await api("/events?" + params)) || []).reverse();
+    setCount(section, evs.length === EVENT_ROWS ? "newest " + EVENT_ROWS : evs.length);
+    fill(section, evs.map((ev) => el("tr", {},
+      td(clock(ev.timestamp), "", ev.timestamp), td(ev.event_type, "mono"), td(ev.agent, "mono"),
+      td(ev.work_item_id, "mono"), td(summarize(ev.details), "wrap mono"))), 5, "No events in the window.");
+  } catch (err) {
+    fail(section, err, 5);
+    throw err;
+  }
+}
+
+// --- refresh loop ------------------------------------------------------
+
+let timer = null;
+
+async function refresh() {
+  clearTimeout(timer);
+  const results = await Promise.allSettled([loadServer(), loadAgents(), loadRefinery(), loadSchedules(), loadWorkItems(), loadEvents()]);
+  const banner = document.getElementById("banner");
+  const denied = results.find((r) => r.status === "rejected" && r.reason.status === 401);
+  const down = results.every((r) => r.status === "rejected");
+  if (denied) {
+    banner.textContent = "pogod wants an API token. Run `pogo ui` and open the URL it prints.";
+  } else if (down) {
+    banner.textContent = "pogod is not answering: " + results[0].reason.message;
+  }
+  banner.hidden = !(denied || down);
+  document.getElementById("updated").textContent = "updated " + new Date().toLocaleTimeString();
+  schedule();
+}
+
+function schedule() {
+  clearTimeout(timer);
+  if (document.getElementById("live").checked && !document.hidden) timer = setTimeout(refresh, REFRESH_MS);
+}
+
+// --- terminal ----------------------------------------------------------
+//
+// The page does not emulate a terminal. pogod already keeps each agent's
+// screen (internal/vt), so the WebSocket's output frames are used only as a
+// signal that the screen changed, and the screen is fetched and drawn as
+// text. Keystrokes go back over the WebSocket once the viewer has taken the
+// keyboard; until then it attaches read-only (?role=ro) and cannot type. The
+// page never resizes the agent's terminal: the agent's size is the agent's.
+
+const term = { name: null, ws: null, role: "ro", you: 0, writer: 0, pending: null, last: 0, poll: null };
+
+function openTerminal(name) {
+  closeTerminal();
+  term.name = name;
+  document.getElementById("terminal-name").textContent = name;
+  document.getElementById("terminal").hidden = false;
+  connect("ro");
+  drawScreen();
+  term.poll = setInterval(drawScreen, 2000);
+}
+
+function closeTerminal() {
+  if (term.ws) term.ws.close();
+  clearInterval(term.poll);
+  clearTimeout(term.pending);
+  Object.assign(term, { name: null, ws: null, you: 0, writer: 0, pending: null, poll: null });
+  document.getElementById("terminal").hidden = true;
+}
+
+function connect(role) {
+  if (term.ws) {
+    term.ws.onclose = null;
+    term.ws.close();
+  }
+  term.role = role;
+  const scheme = location.protocol === "https:" ? "wss:" : "ws:";
+  const url = scheme + "//" + location.host + "/agents/" + encodeURIComponent(term.name) +
+    "/terminal?role=" + role + "&who=" + encodeURIComponent("dashboard");
+  // The token rides as a subprotocol, the only header a page may set on a
+  // WebSocket; pogod answers with pogo-terminal and never echoes it.
+  const protocols = ["pogo-terminal"];
+  if (token()) protocols.push("pogo-token." + token());
+  const ws = new WebSocket(url, protocols);
+  ws.binaryType = "arraybuffer";
+  ws.onopen = () => {
+    if (role === "rw") ws.send(JSON.stringify({ type: "takeover" }));
+    showRole();
+  };
+  ws.onmessage = (m) => {
+    if (typeof m.data !== "string") {
+      screenChanged();
+      return;
+    }
+    const msg = JSON.parse(m.data);
+    if (msg.type === "presence" && msg.presence) showPresence(msg.presence);
+  };
+  ws.onclose = () => {
+    if (term.ws !== ws) return;
+    document.getElementById("terminal-role").textContent = "disconnected";
+  };
+  term.ws = ws;
+}
+
+function showRole() {
+  const rw = term.role === "rw";
+  const typing = rw && term.writer === term.you && term.you !== 0;
+  document.getElementById("terminal-role").textContent = typing ? "you have the keyboard" : rw ? "read-write" : "watching";
+  document.getElementById("terminal-takeover").hidden = typing;
+  document.getElementById("terminal-release").hidden = !rw;
+  document.getElementById("terminal-screen").classList.toggle("writer", typing);
+  if (typing) document.getElementById("terminal-screen").focus();
+}
+
+function showPresence(p) {
+  term.you = p.you;
+  term.writer = p.writer;
+  const others = (p.clients || []).filter((c) => c.id !== p.you);
+  const writer = (p.clients || []).find((c) => c.id === p.writer);
+  let text = others.length ? "also here: " + others.map((c) => c.who + (c.role === "ro" ? " (ro)" : "")).join(", ") : "";
+  if (writer && writer.id !== p.you) text += (text ? " · " : "") + writer.who + " has the keyboard";
+  document.getElementById("terminal-presence").textContent = text;
+  showRole();
+}
+
+function screenChanged() {
+  if (term.pending) return;
+  const wait = Math.max(0, term.last + SCREEN_THROTTLE_MS - Date.now());
+  term.pending = setTimeout(() => {
+    term.pending = null;
+    drawScreen();
+  }, wait);
+}
+
+async function drawScreen() {
+  const name = term.name;
+  if (!name) return;
+  term.last = Date.now();
+  const pre = document.getElementById("terminal-screen");
+  let snap;
+  try {
+    snap = await api("/agents/" + encodeURIComponent(name) + "/screen?format=json");
+  } catch (err) {
+    if (term.name === name) pre.textContent = err.message;
+    return;
+  }
+  if (term.name !== name) return;
+  const lines = snap.lines || [];
+  const nodes = [];
+  lines.forEach((line, row) => {
+    if (row > 0) nodes.push("\n");
+    if (!snap.cursor_visible || row !== snap.cursor.row) {
+      nodes.push(line);
+      return;
+    }
+    const chars = Array.from(line.padEnd(snap.cursor.col + 1));
+    nodes.push(chars.slice(0, snap.cursor.col).join(""),
+      el("span", { class: "cursor" }, chars[snap.cursor.col]),
+      chars.slice(snap.cursor.col + 1).join(""));
+  });
+  pre.replaceChildren(...nodes);
+}
+
+const keys = {
+  Enter: "\r", Backspace: "\x7f", Tab: "\t", Escape: "\x1b", Delete: "\x1b[3~",
+  ArrowUp: "\x1b[A", ArrowDown: "\x1b[B", ArrowRight: "\x1b[C", ArrowLeft: "\x1b[D",
+  Home: "\x1b[H", End: "\x1b[F", PageUp: "\x1b[5~", PageDown: "\x1b[6~",
+};
+
+function keyBytes(e) {
+  if (e.metaKey) return null;
+  if (e.ctrlKey && !e.altKey && e.key.length === 1) {
+    const c = e.key.toUpperCase().charCodeAt(0);
+    if (c >= 64 && c <= 95) return String.fromCharCode(c - 64);
+    return null;
+  }
+  if (keys[e.key]) return (e.shiftKey && e.key === "Tab") ? "\x1b[Z" : keys[e.key];
+  if (e.key.length === 1 || Array.from(e.key).length === 1) return (e.altKey ? "\x1b" : "") + e.key;
+  return null;
+}
+
+function send(text) {
+  if (!term.ws || term.ws.readyState !== WebSocket.OPEN || term.role !== "rw") return;
+  term.ws.send(new TextEncoder().encode(text));
+}
+
+// --- wiring ------------------------------------------------------------
+
+document.addEventListener("DOMContentLoaded", () => {
+  const screen = document.getElementById("terminal-screen");
+  screen.addEventListener("keydown", (e) => {
+    const b = keyBytes(e);
+    if (b === null || term.role !== "rw") return;
+    e.preventDefault();
+    send(b);
+  });
+  screen.addEventListener("paste", (e) => {
+    if (term.role !== "rw") return;
+    e.preventDefault();
+    send(e.clipboardData.getData("text"));
+  });
+  document.getElementById("terminal-takeover").onclick = () => connect("rw");
+  document.getElementById("terminal-release").onclick = () => connect("ro");
+  document.getElementById("terminal-close").onclick = closeTerminal;
+  document.getElementById("live").onchange = schedule;
+  document.addEventListener("visibilitychange", () => (document.hidden ? clearTimeout(timer) : refresh()));
+  for (const id of ["workitem-status", "events-since"]) document.getElementById(id).onchange = refresh;
+  for (const id of ["events-type", "events-agent"]) document.getElementById(id).onchange = refresh;
+  refresh();
+});
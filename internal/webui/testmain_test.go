@@ -0,0 +1,28 @@
+package webui
+
+import (
+	"os"
+	"testing"
+
+	"github.com/drellem2/pogo/internal/testsandbox"
+)
+
+// sandbox is the package's private, CHECKED envelope (internal/testsandbox).
+// The dashboard's assets are embedded and its tests touch no disk; the
+// envelope is here so that stays true if a test ever reaches for the config
+// or the event log the page reads through pogod.
+var sandbox *testsandbox.Sandbox
+
+func TestMain(m *testing.M) {
+	sb, down := testsandbox.Main("webui")
+	sandbox = sb
+
+	code := m.Run()
+
+	down()
+	os.Exit(code)
+}
+
+func TestSandboxIsVerified(t *testing.T) {
+	testsandbox.Verify(t, sandbox)
+}
@@ -0,0 +1,59 @@
+// Package webui is pogod's browser dashboard (user-024): a page, a script and
+// a stylesheet, embedded in the binary and served under /ui/.
+//
+// It is read-mostly and holds no state of its own. Everything it shows comes
+// from the API the CLI already uses — /agents, /refinery/queue and /history,
+// /scheduler/schedules, /events and /workitems — polled from the browser, so
+// the dashboard can never disagree with `pogo status` about what the fleet is
+// doing. The one thing it writes is keystrokes, through the terminal bridge at
+// /agents/{name}/terminal, and only after the viewer takes the keyboard.
+//
+// There is no build step and no framework: the assets are the files in
+// static/, as written. A teammate who opens the page should not need node, and
+// a maintainer who changes it should not need to regenerate anything.
+//
+// The assets carry no fleet data, so pogod's API guard serves them to anyone
+// (Prefix is in Guard.Public). The data the page fetches is guarded as it is
+// for any caller: with [server] require_token the page needs a token, which
+// `pogo ui` puts in the URL's fragment — never sent to the server, kept in the
+// tab's sessionStorage, and sent as a bearer token on every request.
+package webui
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// Prefix is where the dashboard is served.
+const Prefix = "/ui/"
+
+//go:embed static
+var static embed.FS
+
+// Handler serves the dashboard's assets under Prefix.
+//
+// The assets are revalidated on every load rather than cached, so a pogod
+// upgrade shows its own dashboard without a hard refresh; they are a few
+// kilobytes from localhost. The content security policy confines the page to
+// pogod: its scripts are its own, and it connects nowhere else.
+func Handler() http.Handler {
+	sub, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err)
+	}
+	files := http.StripPrefix(Prefix, http.FileServer(http.FS(sub)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			http.Error(w, "", http.StatusMethodNotAllowed)
+			return
+		}
+		h := w.Header()
+		h.Set("Cache-Control", "no-cache")
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("Referrer-Policy", "no-referrer")
+		h.Set("Content-Security-Policy",
+			"default-src 'self'; connect-src 'self' ws: wss:; frame-ancestors 'none'; base-uri 'none'")
+		files.ServeHTTP(w, r)
+	})
+}
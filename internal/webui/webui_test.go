@@ -0,0 +1,57 @@
+package webui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func get(t *testing.T, method, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec
+}
+
+// TestHandlerServesThePage: /ui/ is the page, and every asset it names is
+// served with a type the browser will run under nosniff — a renamed script
+// is a blank dashboard, not an error anyone sees.
+func TestHandlerServesThePage(t *testing.T) {
+	rec := get(t, "GET", "/ui/")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "<title>pogo</title>") {
+		t.Fatalf("GET /ui/: %d\n%s", rec.Code, rec.Body)
+	}
+	for _, h := range []string{"Content-Security-Policy", "X-Content-Type-Options", "Cache-Control"} {
+		if rec.Header().Get(h) == "" {
+			t.Errorf("GET /ui/ has no %s header", h)
+		}
+	}
+	refs := regexp.MustCompile(`(?:src|href)="([^"]+)"`).FindAllStringSubmatch(rec.Body.String(), -1)
+	if len(refs) == 0 {
+		t.Fatal("the page names no assets")
+	}
+	want := map[string]string{".js": "text/javascript", ".css": "text/css"}
+	for _, ref := range refs {
+		asset := get(t, "GET", Prefix+ref[1])
+		if asset.Code != http.StatusOK {
+			t.Errorf("%s: %d", ref[1], asset.Code)
+			continue
+		}
+		for ext, ctype := range want {
+			if strings.HasSuffix(ref[1], ext) && !strings.HasPrefix(asset.Header().Get("Content-Type"), ctype) {
+				t.Errorf("%s served as %q, want %s", ref[1], asset.Header().Get("Content-Type"), ctype)
+			}
+		}
+	}
+}
+
+func TestHandlerIsReadOnly(t *testing.T) {
+	if rec := get(t, "POST", "/ui/"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /ui/: %d, want 405", rec.Code)
+	}
+	if rec := get(t, "GET", "/ui/missing.js"); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /ui/missing.js: %d, want 404", rec.Code)
+	}
+}
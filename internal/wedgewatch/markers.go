@@ -24,8 +24,8 @@ import (
 // never matched in production. It was not broken; it was invisible.
 //
 // So both the buffer and the marker have ASCII whitespace removed before
-// comparison, identically to internal/claude's matchNormalize. The spaces in
-// the constants below are for the reader.
+// comparison, as vt.Snapshot.Contains does for the rendered screen. The spaces
+// in the constants below are for the reader.
 
 // Marker is one enumerated dead-end state.
 type Marker struct {
@@ -30,6 +30,9 @@ type FileMatch struct {
 type ChunkMatch struct {
 	Line    uint32 `json:"line"`
 	Content string `json:"content"`
+	// Kind is "definition" or "reference" when the index knows which the
+	// match is (a repo indexed with ctags symbols), and empty otherwise.
+	Kind string `json:"kind,omitempty"`
 }
 
 // SearchAllFunc is the function signature for streaming search across all repos.
@@ -56,6 +59,23 @@ func classifyRef(content string) RefKind {
 	return RefCall
 }
 
+// classifyMatch classifies m, trusting the index over the line's text. The
+// index only knows definition from reference, so a reference is still told
+// apart into import or call by classifyRef — but can no longer be mistaken
+// for a definition because it happens to start with `var `.
+func classifyMatch(m ChunkMatch) RefKind {
+	switch m.Kind {
+	case "definition":
+		return RefDefinition
+	case "reference":
+		if kind := classifyRef(m.Content); kind != RefDefinition {
+			return kind
+		}
+		return RefCall
+	}
+	return classifyRef(m.Content)
+}
+
 // FindReferences searches for a symbol across all indexed repos and classifies
 // each match. It streams results per-repo.
 func FindReferences(searchAll SearchAllFunc, symbol string, onRepo func(*RepoRefs)) error {
@@ -73,7 +93,7 @@ func FindReferences(searchAll SearchAllFunc, symbol string, onRepo func(*RepoRef
 					File:    f.Path,
 					Line:    m.Line,
 					Content: m.Content,
-					Kind:    classifyRef(m.Content),
+					Kind:    classifyMatch(m),
 				})
 			}
 		}
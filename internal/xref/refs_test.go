@@ -29,6 +29,26 @@ func TestClassifyRef(t *testing.T) {
 	}
 }
 
+// TestClassifyMatchTrustsTheIndex: a symbol-indexed repo's labels win over
+// the Go-shaped prefixes, in both directions.
+func TestClassifyMatchTrustsTheIndex(t *testing.T) {
+	tests := []struct {
+		m    ChunkMatch
+		want RefKind
+	}{
+		{ChunkMatch{Content: "def frobnicate(x):", Kind: "definition"}, RefDefinition},
+		{ChunkMatch{Content: "var Foo = other.Foo", Kind: "reference"}, RefCall},
+		{ChunkMatch{Content: `import "github.com/drellem2/pogo"`, Kind: "reference"}, RefImport},
+		{ChunkMatch{Content: "def frobnicate(x):"}, RefCall},
+		{ChunkMatch{Content: "func Foo() {"}, RefDefinition},
+	}
+	for _, tt := range tests {
+		if got := classifyMatch(tt.m); got != tt.want {
+			t.Errorf("classifyMatch(%+v) = %q, want %q", tt.m, got, tt.want)
+		}
+	}
+}
+
 func TestFindReferences(t *testing.T) {
 	mockSearch := func(query string, onResult func(*SearchResponse)) error {
 		onResult(&SearchResponse{
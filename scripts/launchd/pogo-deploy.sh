@@ -3588,7 +3588,8 @@ is correct not to fire. On 2026-08-08 that was 33 hours of no crew.
   curl -s http://127.0.0.1:10000/version
   curl -s http://127.0.0.1:10000/server/mode      # expect {"mode":"full"}
   pogo agent list                                 # expect a crew, not an empty list
-  curl -s -X POST http://127.0.0.1:10000/agents/drain -d '{"draining":false}'
+  curl -s -X POST http://127.0.0.1:10000/agents/drain -d '{"draining":false}' \
+      -H "Authorization: Bearer \$(cat ~/.pogo/api-token)"
 
 THEN READ WHERE IT STOPPED. The last timestamped line before the silence bounds
 the stage; the gap in the timestamps is the stall:
@@ -257,7 +257,7 @@ for case_desc in body-id body-agent url-path; do
         url-path)
             # Raw curl, NOT pogo_sandbox_curl: this stages the state the guarded
             # DELETE below would really remove, so the removal is a live one.
-            prestage='curl -sf --max-time 10 -X POST "$POGO_SANDBOX_URL/scheduler/schedules" -H "Content-Type: application/json" -d "{\"id\":\"mail-check-pa\",\"agent\":\"pa\",\"cron\":\"*/10 * * * *\",\"delivery\":\"nudge\",\"message\":\"x\"}" >/dev/null || { echo "PRESTAGE FAILED"; exit 3; }'
+            prestage='curl -sf --max-time 10 -X POST "$POGO_SANDBOX_URL/scheduler/schedules" -H "Authorization: Bearer $(cat "$POGO_HOME/api-token")" -H "Content-Type: application/json" -d "{\"id\":\"mail-check-pa\",\"agent\":\"pa\",\"cron\":\"*/10 * * * *\",\"delivery\":\"nudge\",\"message\":\"x\"}" >/dev/null || { echo "PRESTAGE FAILED"; exit 3; }'
             snippet='pogo_sandbox_curl "remove" -- -X DELETE "$POGO_SANDBOX_URL/scheduler/schedules/mail-check-pa"'
             label="a DELETE whose URL names mail-check-pa"
             offender="mail-check-pa" ;;
@@ -435,6 +435,7 @@ fi
 # failure here after the verdict has been reached would replace a real FAIL with
 # exit 99. Guarding the scaffolding is not guarding everything that looks like it.
 curl -sf -X POST "$URL/agents/drain" -H 'Content-Type: application/json' \
+    -H "Authorization: Bearer $(cat "$POGO_HOME/api-token" 2>/dev/null)" \
     -d '{"draining":false}' >/dev/null 2>&1
 
 finish
@@ -3510,6 +3510,22 @@ OUT="$(bash -c 'source "'"$HERE/pogo-self-deploy"'"; ASSUME_YES=false; confirm <
     && pass "and the default noun is unchanged for the caller that was there first" \
     || fail "confirm's default refusal changed (rc=$RC): $OUT"
 
+# --- api_token_file reads the token where pogod wrote it ------------------
+# Parity with internal/config.PogoHome. The legacy POGO_HOME=$HOME names
+# $HOME/.pogo there; a script that read $HOME/api-token instead would drain
+# tokenless and be refused.
+for case in "unset|/h/.pogo/api-token" "/h|/h/.pogo/api-token" "/h/|/h/.pogo/api-token" "/srv/pogo|/srv/pogo/api-token"; do
+    ph="${case%%|*}" want="${case#*|}"
+    if [ "$ph" = unset ]; then
+        got="$(HOME=/h; unset POGO_HOME; api_token_file)"
+    else
+        got="$(HOME=/h POGO_HOME="$ph" api_token_file)"
+    fi
+    [ "$got" = "$want" ] \
+        && pass "api_token_file with POGO_HOME=$ph is $want, as config.PogoHome resolves it" \
+        || fail "api_token_file with POGO_HOME=$ph is $got, want $want"
+done
+
 echo ""
 PASS_COUNT=$(grep -c '^PASS:' "$RESULTS_FILE" 2>/dev/null || true)
 FAIL_COUNT=$(grep -c '^FAIL:' "$RESULTS_FILE" 2>/dev/null || true)